@@ -0,0 +1,4 @@
+// Package petstorev1 holds the gRPC stubs generated from petstore.proto.
+package petstorev1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative petstore.proto
@@ -0,0 +1,717 @@
+// The petstore API for internal services, served by internal/grpcapi on grpc.address. It
+// mirrors the REST routes in api/petstore.json over the same repository.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: petstore.proto
+
+package petstorev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PetChange_Op int32
+
+const (
+	PetChange_OP_UNSPECIFIED PetChange_Op = 0
+	PetChange_OP_CREATE      PetChange_Op = 1
+	PetChange_OP_UPDATE      PetChange_Op = 2
+	PetChange_OP_DELETE      PetChange_Op = 3
+)
+
+// Enum value maps for PetChange_Op.
+var (
+	PetChange_Op_name = map[int32]string{
+		0: "OP_UNSPECIFIED",
+		1: "OP_CREATE",
+		2: "OP_UPDATE",
+		3: "OP_DELETE",
+	}
+	PetChange_Op_value = map[string]int32{
+		"OP_UNSPECIFIED": 0,
+		"OP_CREATE":      1,
+		"OP_UPDATE":      2,
+		"OP_DELETE":      3,
+	}
+)
+
+func (x PetChange_Op) Enum() *PetChange_Op {
+	p := new(PetChange_Op)
+	*p = x
+	return p
+}
+
+func (x PetChange_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PetChange_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_petstore_proto_enumTypes[0].Descriptor()
+}
+
+func (PetChange_Op) Type() protoreflect.EnumType {
+	return &file_petstore_proto_enumTypes[0]
+}
+
+func (x PetChange_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PetChange_Op.Descriptor instead.
+func (PetChange_Op) EnumDescriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{9, 0}
+}
+
+type Pet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Tag           *string                `protobuf:"bytes,3,opt,name=tag,proto3,oneof" json:"tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Pet) Reset() {
+	*x = Pet{}
+	mi := &file_petstore_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Pet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pet) ProtoMessage() {}
+
+func (x *Pet) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pet.ProtoReflect.Descriptor instead.
+func (*Pet) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Pet) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Pet) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Pet) GetTag() string {
+	if x != nil && x.Tag != nil {
+		return *x.Tag
+	}
+	return ""
+}
+
+type ListPetsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// page_size defaults to 20 and is capped at 100.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// page_token is a previous response's next_page_token.
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// mine restricts the page to pets the caller created.
+	Mine          bool `protobuf:"varint,3,opt,name=mine,proto3" json:"mine,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPetsRequest) Reset() {
+	*x = ListPetsRequest{}
+	mi := &file_petstore_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPetsRequest) ProtoMessage() {}
+
+func (x *ListPetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPetsRequest.ProtoReflect.Descriptor instead.
+func (*ListPetsRequest) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListPetsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListPetsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListPetsRequest) GetMine() bool {
+	if x != nil {
+		return x.Mine
+	}
+	return false
+}
+
+type ListPetsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Pets  []*Pet                 `protobuf:"bytes,1,rep,name=pets,proto3" json:"pets,omitempty"`
+	// next_page_token is empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPetsResponse) Reset() {
+	*x = ListPetsResponse{}
+	mi := &file_petstore_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPetsResponse) ProtoMessage() {}
+
+func (x *ListPetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPetsResponse.ProtoReflect.Descriptor instead.
+func (*ListPetsResponse) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListPetsResponse) GetPets() []*Pet {
+	if x != nil {
+		return x.Pets
+	}
+	return nil
+}
+
+func (x *ListPetsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetPetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPetRequest) Reset() {
+	*x = GetPetRequest{}
+	mi := &file_petstore_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPetRequest) ProtoMessage() {}
+
+func (x *GetPetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPetRequest.ProtoReflect.Descriptor instead.
+func (*GetPetRequest) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetPetRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type CreatePetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pet           *Pet                   `protobuf:"bytes,1,opt,name=pet,proto3" json:"pet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePetRequest) Reset() {
+	*x = CreatePetRequest{}
+	mi := &file_petstore_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePetRequest) ProtoMessage() {}
+
+func (x *CreatePetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePetRequest.ProtoReflect.Descriptor instead.
+func (*CreatePetRequest) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreatePetRequest) GetPet() *Pet {
+	if x != nil {
+		return x.Pet
+	}
+	return nil
+}
+
+type UpdatePetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pet           *Pet                   `protobuf:"bytes,1,opt,name=pet,proto3" json:"pet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdatePetRequest) Reset() {
+	*x = UpdatePetRequest{}
+	mi := &file_petstore_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdatePetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdatePetRequest) ProtoMessage() {}
+
+func (x *UpdatePetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdatePetRequest.ProtoReflect.Descriptor instead.
+func (*UpdatePetRequest) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdatePetRequest) GetPet() *Pet {
+	if x != nil {
+		return x.Pet
+	}
+	return nil
+}
+
+type DeletePetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePetRequest) Reset() {
+	*x = DeletePetRequest{}
+	mi := &file_petstore_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePetRequest) ProtoMessage() {}
+
+func (x *DeletePetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePetRequest.ProtoReflect.Descriptor instead.
+func (*DeletePetRequest) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeletePetRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type DeletePetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePetResponse) Reset() {
+	*x = DeletePetResponse{}
+	mi := &file_petstore_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePetResponse) ProtoMessage() {}
+
+func (x *DeletePetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePetResponse.ProtoReflect.Descriptor instead.
+func (*DeletePetResponse) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{7}
+}
+
+type WatchPetsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// since is the last seq the client has seen; 0 replays the whole feed.
+	Since         int64 `protobuf:"varint,1,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchPetsRequest) Reset() {
+	*x = WatchPetsRequest{}
+	mi := &file_petstore_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchPetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchPetsRequest) ProtoMessage() {}
+
+func (x *WatchPetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchPetsRequest.ProtoReflect.Descriptor instead.
+func (*WatchPetsRequest) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchPetsRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+type PetChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Seq   int64                  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Op    PetChange_Op           `protobuf:"varint,2,opt,name=op,proto3,enum=petstore.v1.PetChange_Op" json:"op,omitempty"`
+	PetId int64                  `protobuf:"varint,3,opt,name=pet_id,json=petId,proto3" json:"pet_id,omitempty"`
+	// pet is the pet after the write; unset for deletes.
+	Pet           *Pet                   `protobuf:"bytes,4,opt,name=pet,proto3" json:"pet,omitempty"`
+	ChangedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PetChange) Reset() {
+	*x = PetChange{}
+	mi := &file_petstore_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PetChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PetChange) ProtoMessage() {}
+
+func (x *PetChange) ProtoReflect() protoreflect.Message {
+	mi := &file_petstore_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PetChange.ProtoReflect.Descriptor instead.
+func (*PetChange) Descriptor() ([]byte, []int) {
+	return file_petstore_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PetChange) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PetChange) GetOp() PetChange_Op {
+	if x != nil {
+		return x.Op
+	}
+	return PetChange_OP_UNSPECIFIED
+}
+
+func (x *PetChange) GetPetId() int64 {
+	if x != nil {
+		return x.PetId
+	}
+	return 0
+}
+
+func (x *PetChange) GetPet() *Pet {
+	if x != nil {
+		return x.Pet
+	}
+	return nil
+}
+
+func (x *PetChange) GetChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return nil
+}
+
+var File_petstore_proto protoreflect.FileDescriptor
+
+const file_petstore_proto_rawDesc = "" +
+	"\n" +
+	"\x0epetstore.proto\x12\vpetstore.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"H\n" +
+	"\x03Pet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x15\n" +
+	"\x03tag\x18\x03 \x01(\tH\x00R\x03tag\x88\x01\x01B\x06\n" +
+	"\x04_tag\"a\n" +
+	"\x0fListPetsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x12\n" +
+	"\x04mine\x18\x03 \x01(\bR\x04mine\"`\n" +
+	"\x10ListPetsResponse\x12$\n" +
+	"\x04pets\x18\x01 \x03(\v2\x10.petstore.v1.PetR\x04pets\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\x1f\n" +
+	"\rGetPetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"6\n" +
+	"\x10CreatePetRequest\x12\"\n" +
+	"\x03pet\x18\x01 \x01(\v2\x10.petstore.v1.PetR\x03pet\"6\n" +
+	"\x10UpdatePetRequest\x12\"\n" +
+	"\x03pet\x18\x01 \x01(\v2\x10.petstore.v1.PetR\x03pet\"\"\n" +
+	"\x10DeletePetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\x13\n" +
+	"\x11DeletePetResponse\"(\n" +
+	"\x10WatchPetsRequest\x12\x14\n" +
+	"\x05since\x18\x01 \x01(\x03R\x05since\"\x85\x02\n" +
+	"\tPetChange\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x03R\x03seq\x12)\n" +
+	"\x02op\x18\x02 \x01(\x0e2\x19.petstore.v1.PetChange.OpR\x02op\x12\x15\n" +
+	"\x06pet_id\x18\x03 \x01(\x03R\x05petId\x12\"\n" +
+	"\x03pet\x18\x04 \x01(\v2\x10.petstore.v1.PetR\x03pet\x129\n" +
+	"\n" +
+	"changed_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tchangedAt\"E\n" +
+	"\x02Op\x12\x12\n" +
+	"\x0eOP_UNSPECIFIED\x10\x00\x12\r\n" +
+	"\tOP_CREATE\x10\x01\x12\r\n" +
+	"\tOP_UPDATE\x10\x02\x12\r\n" +
+	"\tOP_DELETE\x10\x032\x99\x03\n" +
+	"\bPetStore\x12G\n" +
+	"\bListPets\x12\x1c.petstore.v1.ListPetsRequest\x1a\x1d.petstore.v1.ListPetsResponse\x126\n" +
+	"\x06GetPet\x12\x1a.petstore.v1.GetPetRequest\x1a\x10.petstore.v1.Pet\x12<\n" +
+	"\tCreatePet\x12\x1d.petstore.v1.CreatePetRequest\x1a\x10.petstore.v1.Pet\x12<\n" +
+	"\tUpdatePet\x12\x1d.petstore.v1.UpdatePetRequest\x1a\x10.petstore.v1.Pet\x12J\n" +
+	"\tDeletePet\x12\x1d.petstore.v1.DeletePetRequest\x1a\x1e.petstore.v1.DeletePetResponse\x12D\n" +
+	"\tWatchPets\x12\x1d.petstore.v1.WatchPetsRequest\x1a\x16.petstore.v1.PetChange0\x01B Z\x1edemo/api/petstorev1;petstorev1b\x06proto3"
+
+var (
+	file_petstore_proto_rawDescOnce sync.Once
+	file_petstore_proto_rawDescData []byte
+)
+
+func file_petstore_proto_rawDescGZIP() []byte {
+	file_petstore_proto_rawDescOnce.Do(func() {
+		file_petstore_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_petstore_proto_rawDesc), len(file_petstore_proto_rawDesc)))
+	})
+	return file_petstore_proto_rawDescData
+}
+
+var file_petstore_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_petstore_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_petstore_proto_goTypes = []any{
+	(PetChange_Op)(0),             // 0: petstore.v1.PetChange.Op
+	(*Pet)(nil),                   // 1: petstore.v1.Pet
+	(*ListPetsRequest)(nil),       // 2: petstore.v1.ListPetsRequest
+	(*ListPetsResponse)(nil),      // 3: petstore.v1.ListPetsResponse
+	(*GetPetRequest)(nil),         // 4: petstore.v1.GetPetRequest
+	(*CreatePetRequest)(nil),      // 5: petstore.v1.CreatePetRequest
+	(*UpdatePetRequest)(nil),      // 6: petstore.v1.UpdatePetRequest
+	(*DeletePetRequest)(nil),      // 7: petstore.v1.DeletePetRequest
+	(*DeletePetResponse)(nil),     // 8: petstore.v1.DeletePetResponse
+	(*WatchPetsRequest)(nil),      // 9: petstore.v1.WatchPetsRequest
+	(*PetChange)(nil),             // 10: petstore.v1.PetChange
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_petstore_proto_depIdxs = []int32{
+	1,  // 0: petstore.v1.ListPetsResponse.pets:type_name -> petstore.v1.Pet
+	1,  // 1: petstore.v1.CreatePetRequest.pet:type_name -> petstore.v1.Pet
+	1,  // 2: petstore.v1.UpdatePetRequest.pet:type_name -> petstore.v1.Pet
+	0,  // 3: petstore.v1.PetChange.op:type_name -> petstore.v1.PetChange.Op
+	1,  // 4: petstore.v1.PetChange.pet:type_name -> petstore.v1.Pet
+	11, // 5: petstore.v1.PetChange.changed_at:type_name -> google.protobuf.Timestamp
+	2,  // 6: petstore.v1.PetStore.ListPets:input_type -> petstore.v1.ListPetsRequest
+	4,  // 7: petstore.v1.PetStore.GetPet:input_type -> petstore.v1.GetPetRequest
+	5,  // 8: petstore.v1.PetStore.CreatePet:input_type -> petstore.v1.CreatePetRequest
+	6,  // 9: petstore.v1.PetStore.UpdatePet:input_type -> petstore.v1.UpdatePetRequest
+	7,  // 10: petstore.v1.PetStore.DeletePet:input_type -> petstore.v1.DeletePetRequest
+	9,  // 11: petstore.v1.PetStore.WatchPets:input_type -> petstore.v1.WatchPetsRequest
+	3,  // 12: petstore.v1.PetStore.ListPets:output_type -> petstore.v1.ListPetsResponse
+	1,  // 13: petstore.v1.PetStore.GetPet:output_type -> petstore.v1.Pet
+	1,  // 14: petstore.v1.PetStore.CreatePet:output_type -> petstore.v1.Pet
+	1,  // 15: petstore.v1.PetStore.UpdatePet:output_type -> petstore.v1.Pet
+	8,  // 16: petstore.v1.PetStore.DeletePet:output_type -> petstore.v1.DeletePetResponse
+	10, // 17: petstore.v1.PetStore.WatchPets:output_type -> petstore.v1.PetChange
+	12, // [12:18] is the sub-list for method output_type
+	6,  // [6:12] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_petstore_proto_init() }
+func file_petstore_proto_init() {
+	if File_petstore_proto != nil {
+		return
+	}
+	file_petstore_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_petstore_proto_rawDesc), len(file_petstore_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_petstore_proto_goTypes,
+		DependencyIndexes: file_petstore_proto_depIdxs,
+		EnumInfos:         file_petstore_proto_enumTypes,
+		MessageInfos:      file_petstore_proto_msgTypes,
+	}.Build()
+	File_petstore_proto = out.File
+	file_petstore_proto_goTypes = nil
+	file_petstore_proto_depIdxs = nil
+}
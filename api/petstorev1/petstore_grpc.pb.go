@@ -0,0 +1,324 @@
+// The petstore API for internal services, served by internal/grpcapi on grpc.address. It
+// mirrors the REST routes in api/petstore.json over the same repository.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: petstore.proto
+
+package petstorev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PetStore_ListPets_FullMethodName  = "/petstore.v1.PetStore/ListPets"
+	PetStore_GetPet_FullMethodName    = "/petstore.v1.PetStore/GetPet"
+	PetStore_CreatePet_FullMethodName = "/petstore.v1.PetStore/CreatePet"
+	PetStore_UpdatePet_FullMethodName = "/petstore.v1.PetStore/UpdatePet"
+	PetStore_DeletePet_FullMethodName = "/petstore.v1.PetStore/DeletePet"
+	PetStore_WatchPets_FullMethodName = "/petstore.v1.PetStore/WatchPets"
+)
+
+// PetStoreClient is the client API for PetStore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PetStoreClient interface {
+	// ListPets pages through pets in ID order.
+	ListPets(ctx context.Context, in *ListPetsRequest, opts ...grpc.CallOption) (*ListPetsResponse, error)
+	GetPet(ctx context.Context, in *GetPetRequest, opts ...grpc.CallOption) (*Pet, error)
+	CreatePet(ctx context.Context, in *CreatePetRequest, opts ...grpc.CallOption) (*Pet, error)
+	UpdatePet(ctx context.Context, in *UpdatePetRequest, opts ...grpc.CallOption) (*Pet, error)
+	DeletePet(ctx context.Context, in *DeletePetRequest, opts ...grpc.CallOption) (*DeletePetResponse, error)
+	// WatchPets streams every change after since from the change feed, then keeps the
+	// stream open and sends new changes as they are sequenced.
+	WatchPets(ctx context.Context, in *WatchPetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PetChange], error)
+}
+
+type petStoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPetStoreClient(cc grpc.ClientConnInterface) PetStoreClient {
+	return &petStoreClient{cc}
+}
+
+func (c *petStoreClient) ListPets(ctx context.Context, in *ListPetsRequest, opts ...grpc.CallOption) (*ListPetsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPetsResponse)
+	err := c.cc.Invoke(ctx, PetStore_ListPets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petStoreClient) GetPet(ctx context.Context, in *GetPetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, PetStore_GetPet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petStoreClient) CreatePet(ctx context.Context, in *CreatePetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, PetStore_CreatePet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petStoreClient) UpdatePet(ctx context.Context, in *UpdatePetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, PetStore_UpdatePet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petStoreClient) DeletePet(ctx context.Context, in *DeletePetRequest, opts ...grpc.CallOption) (*DeletePetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePetResponse)
+	err := c.cc.Invoke(ctx, PetStore_DeletePet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petStoreClient) WatchPets(ctx context.Context, in *WatchPetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PetChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PetStore_ServiceDesc.Streams[0], PetStore_WatchPets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchPetsRequest, PetChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PetStore_WatchPetsClient = grpc.ServerStreamingClient[PetChange]
+
+// PetStoreServer is the server API for PetStore service.
+// All implementations must embed UnimplementedPetStoreServer
+// for forward compatibility.
+type PetStoreServer interface {
+	// ListPets pages through pets in ID order.
+	ListPets(context.Context, *ListPetsRequest) (*ListPetsResponse, error)
+	GetPet(context.Context, *GetPetRequest) (*Pet, error)
+	CreatePet(context.Context, *CreatePetRequest) (*Pet, error)
+	UpdatePet(context.Context, *UpdatePetRequest) (*Pet, error)
+	DeletePet(context.Context, *DeletePetRequest) (*DeletePetResponse, error)
+	// WatchPets streams every change after since from the change feed, then keeps the
+	// stream open and sends new changes as they are sequenced.
+	WatchPets(*WatchPetsRequest, grpc.ServerStreamingServer[PetChange]) error
+	mustEmbedUnimplementedPetStoreServer()
+}
+
+// UnimplementedPetStoreServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPetStoreServer struct{}
+
+func (UnimplementedPetStoreServer) ListPets(context.Context, *ListPetsRequest) (*ListPetsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListPets not implemented")
+}
+func (UnimplementedPetStoreServer) GetPet(context.Context, *GetPetRequest) (*Pet, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetPet not implemented")
+}
+func (UnimplementedPetStoreServer) CreatePet(context.Context, *CreatePetRequest) (*Pet, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreatePet not implemented")
+}
+func (UnimplementedPetStoreServer) UpdatePet(context.Context, *UpdatePetRequest) (*Pet, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdatePet not implemented")
+}
+func (UnimplementedPetStoreServer) DeletePet(context.Context, *DeletePetRequest) (*DeletePetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeletePet not implemented")
+}
+func (UnimplementedPetStoreServer) WatchPets(*WatchPetsRequest, grpc.ServerStreamingServer[PetChange]) error {
+	return status.Error(codes.Unimplemented, "method WatchPets not implemented")
+}
+func (UnimplementedPetStoreServer) mustEmbedUnimplementedPetStoreServer() {}
+func (UnimplementedPetStoreServer) testEmbeddedByValue()                  {}
+
+// UnsafePetStoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PetStoreServer will
+// result in compilation errors.
+type UnsafePetStoreServer interface {
+	mustEmbedUnimplementedPetStoreServer()
+}
+
+func RegisterPetStoreServer(s grpc.ServiceRegistrar, srv PetStoreServer) {
+	// If the following call panics, it indicates UnimplementedPetStoreServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PetStore_ServiceDesc, srv)
+}
+
+func _PetStore_ListPets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetStoreServer).ListPets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetStore_ListPets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetStoreServer).ListPets(ctx, req.(*ListPetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetStore_GetPet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetStoreServer).GetPet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetStore_GetPet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetStoreServer).GetPet(ctx, req.(*GetPetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetStore_CreatePet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetStoreServer).CreatePet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetStore_CreatePet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetStoreServer).CreatePet(ctx, req.(*CreatePetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetStore_UpdatePet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetStoreServer).UpdatePet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetStore_UpdatePet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetStoreServer).UpdatePet(ctx, req.(*UpdatePetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetStore_DeletePet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetStoreServer).DeletePet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetStore_DeletePet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetStoreServer).DeletePet(ctx, req.(*DeletePetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetStore_WatchPets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPetsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PetStoreServer).WatchPets(m, &grpc.GenericServerStream[WatchPetsRequest, PetChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PetStore_WatchPetsServer = grpc.ServerStreamingServer[PetChange]
+
+// PetStore_ServiceDesc is the grpc.ServiceDesc for PetStore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PetStore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "petstore.v1.PetStore",
+	HandlerType: (*PetStoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPets",
+			Handler:    _PetStore_ListPets_Handler,
+		},
+		{
+			MethodName: "GetPet",
+			Handler:    _PetStore_GetPet_Handler,
+		},
+		{
+			MethodName: "CreatePet",
+			Handler:    _PetStore_CreatePet_Handler,
+		},
+		{
+			MethodName: "UpdatePet",
+			Handler:    _PetStore_UpdatePet_Handler,
+		},
+		{
+			MethodName: "DeletePet",
+			Handler:    _PetStore_DeletePet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchPets",
+			Handler:       _PetStore_WatchPets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "petstore.proto",
+}
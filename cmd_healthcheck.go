@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"demo/internal/config"
+)
+
+// healthcheck probes the local /readyz endpoint, exiting 0 when ready and 1 otherwise. It is
+// meant for container HEALTHCHECK instructions in images without curl.
+func healthcheck(args []string) {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 3*time.Second, "request timeout")
+	target := fs.String("url", "", "readiness URL (default: derived from server.address)")
+	cfg, logger := loadConfig(fs, args)
+
+	url, client := readinessTarget(cfg.Server)
+	if *target != "" {
+		url = *target
+	}
+	client.Timeout = *timeout
+
+	resp, err := client.Get(url)
+	if err != nil {
+		fatal(logger, "healthcheck failed", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fatal(logger, "healthcheck failed", fmt.Errorf("%s returned %s", url, resp.Status))
+	}
+}
+
+// readinessTarget maps the listen address onto a loopback URL and a client able to reach it.
+func readinessTarget(server config.ServerConfig) (string, *http.Client) {
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if server.TLS.Enabled || server.ACME.Enabled {
+		scheme = "https"
+		// The certificate names the public hostname, not loopback; this probe only checks
+		// liveness of the local process.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	addr := server.Address
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		return scheme + "://unix/readyz", &http.Client{Transport: transport}
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = "", "8080"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + "/readyz", &http.Client{Transport: transport}
+}
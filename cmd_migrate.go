@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"demo/internal/app"
+	"demo/internal/database"
+)
+
+// migrate applies pending migrations ("up", the default) or rolls back --steps of them ("down").
+func migrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back with down")
+	direction := "up"
+	if len(args) > 0 && (args[0] == "up" || args[0] == "down") {
+		direction, args = args[0], args[1:]
+	}
+	cfg, logger := loadConfig(fs, args)
+	if *steps < 1 {
+		fatal(logger, "invalid migrate flags", fmt.Errorf("--steps must be at least 1, got %d", *steps))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	poolConfig, err := app.NewPoolConfig(cfg.Database, logger)
+	if err != nil {
+		fatal(logger, "invalid database configuration", err)
+	}
+	pool, err := database.Connect(ctx, poolConfig, cfg.Database.Retry, logger)
+	if err != nil {
+		fatal(logger, "failed to connect to database", err)
+	}
+	defer pool.Close()
+
+	if direction == "down" {
+		err = database.MigrateDown(ctx, pool, *steps, logger)
+	} else {
+		err = database.MigrateUp(ctx, pool, logger)
+	}
+	if err != nil {
+		pool.Close()
+		fatal(logger, "migration failed", err)
+	}
+	logger.Info("migrate_complete", "direction", direction)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"demo/internal/app"
+	"demo/internal/database"
+	"demo/internal/petstore"
+)
+
+// normalizeBatchSize is how many pets normalize reads per query.
+const normalizeBatchSize = 500
+
+// normalize rewrites stored pets whose name or tag changes under petstore.Pet.Normalize,
+// for rows written before writes were normalized. Pets that would fail validation once
+// normalized, such as names of only whitespace, are logged and left for a person to fix.
+// Running it again finds nothing to do. Caches of a running server keep the old values
+// until their entries expire.
+func normalize(args []string) {
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report the pets that would change without writing")
+	cfg, logger := loadConfig(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	poolConfig, err := app.NewPoolConfig(cfg.Database, logger)
+	if err != nil {
+		fatal(logger, "invalid database configuration", err)
+	}
+	pool, err := database.Connect(ctx, poolConfig, cfg.Database.Retry, logger)
+	if err != nil {
+		fatal(logger, "failed to connect to database", err)
+	}
+	defer pool.Close()
+
+	repo, err := petstore.NewPostgresRepository(ctx, pool, petstore.WithLogger(logger))
+	if err != nil {
+		pool.Close()
+		fatal(logger, "failed to initialize pet repository", err)
+	}
+
+	var scanned, updated, skipped int
+	after := int64(math.MinInt64)
+	for {
+		batch, err := repo.ListPetsAfter(ctx, after, normalizeBatchSize, "")
+		if err != nil {
+			pool.Close()
+			fatal(logger, "failed to read pets", err)
+		}
+		for _, pet := range batch {
+			scanned++
+			normalized := pet.Normalize()
+			if normalized.Name == pet.Name && equalTags(normalized.Tag, pet.Tag) {
+				continue
+			}
+			if err := petstore.ValidatePet(normalized); err != nil {
+				skipped++
+				logger.Warn("normalize_skipped", "pet_id", pet.Id, "error", err)
+				continue
+			}
+			updated++
+			logger.Info("normalize_pet", "pet_id", pet.Id, "name", normalized.Name, "dry_run", *dryRun)
+			if *dryRun {
+				continue
+			}
+			// An empty ownedBy rewrites the pet whoever owns it.
+			err := repo.UpdatePet(ctx, normalized, "")
+			if errors.Is(err, petstore.ErrPetNotFound) {
+				// Deleted since the batch was read.
+				continue
+			}
+			if err != nil {
+				pool.Close()
+				fatal(logger, "failed to update pet", fmt.Errorf("pet %d: %w", pet.Id, err))
+			}
+		}
+		if len(batch) < normalizeBatchSize {
+			break
+		}
+		after = batch[len(batch)-1].Id
+	}
+	logger.Info("normalize_complete", "scanned", scanned, "updated", updated, "skipped", skipped, "dry_run", *dryRun)
+}
+
+func equalTags(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
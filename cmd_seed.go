@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"demo/internal/app"
+	"demo/internal/database"
+	"demo/internal/petstore"
+)
+
+// seed loads pets from a JSON array or a CSV file with an id,name,tag header through the
+// repository. Pets that already exist are skipped, so seeding is safe to repeat.
+func seed(args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := fs.String("file", "", "JSON or CSV file of pets to load")
+	cfg, logger := loadConfig(fs, args)
+	if *file == "" {
+		fatal(logger, "invalid seed flags", errors.New("--file is required"))
+	}
+
+	pets, err := readSeedFile(*file)
+	if err != nil {
+		fatal(logger, "failed to read seed file", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	poolConfig, err := app.NewPoolConfig(cfg.Database, logger)
+	if err != nil {
+		fatal(logger, "invalid database configuration", err)
+	}
+	pool, err := database.Connect(ctx, poolConfig, cfg.Database.Retry, logger)
+	if err != nil {
+		fatal(logger, "failed to connect to database", err)
+	}
+	defer pool.Close()
+
+	repo, err := petstore.NewPostgresRepository(ctx, pool, petstore.WithLogger(logger))
+	if err != nil {
+		pool.Close()
+		fatal(logger, "failed to initialize pet repository", err)
+	}
+
+	var created, skipped int
+	for _, pet := range pets {
+		err := repo.CreatePet(ctx, pet, "")
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, petstore.ErrPetExists):
+			skipped++
+		default:
+			pool.Close()
+			fatal(logger, "failed to seed pet", fmt.Errorf("pet %d: %w", pet.Id, err))
+		}
+	}
+	logger.Info("seed_complete", "file", *file, "created", created, "skipped", skipped)
+}
+
+func readSeedFile(path string) ([]petstore.Pet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pets []petstore.Pet
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.NewDecoder(f).Decode(&pets); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", path, err)
+		}
+	case ".csv":
+		pets, err = readSeedCSV(f)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported seed format, want .json or .csv", path)
+	}
+
+	for i, pet := range pets {
+		pets[i] = pet.Normalize()
+		if err := petstore.ValidatePet(pets[i]); err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+	}
+	return pets, nil
+}
+
+func readSeedCSV(r io.Reader) ([]petstore.Pet, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idCol, hasID := columns["id"]
+	nameCol, hasName := columns["name"]
+	tagCol, hasTag := columns["tag"]
+	if !hasID || !hasName {
+		return nil, errors.New("header must include id and name columns")
+	}
+
+	var pets []petstore.Pet
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return pets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(record[idCol]), 10, 64)
+		if err != nil {
+			line, _ := reader.FieldPos(idCol)
+			return nil, fmt.Errorf("line %d: invalid id: %w", line, err)
+		}
+		pet := petstore.Pet{Id: id, Name: record[nameCol]}
+		if hasTag && record[tagCol] != "" {
+			tag := record[tagCol]
+			pet.Tag = &tag
+		}
+		pets = append(pets, pet)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"demo/internal/config"
+	"demo/internal/logging"
+)
+
+// logLevel is the process-wide log level, adjustable at runtime by config reloads.
+var logLevel = new(slog.LevelVar)
+
+// loadConfig registers --config on fs, parses args, loads configuration, and installs the
+// configured logger as the slog default. Failures exit the process.
+func loadConfig(fs *flag.FlagSet, args []string) (config.Config, *slog.Logger) {
+	configPath := fs.String("config", "", "config file, or directory holding config.*; overrides $"+config.FileEnv+" (default: search ./config.yaml and ./config/config.yaml)")
+	fs.Parse(args)
+	if *configPath == "" {
+		// Written back into the flag so reloads read the same file.
+		*configPath = os.Getenv(config.FileEnv)
+	}
+
+	cfg, err := config.Load(*configPath)
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		// One line per key reads better on a terminal than a single structured log entry.
+		fmt.Fprintln(os.Stderr, "invalid configuration:")
+		for _, problem := range invalid.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", problem)
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		fatal(slog.Default(), "failed to load configuration", err)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Logging, logLevel)
+	if err != nil {
+		fatal(slog.Default(), "failed to initialize logging", err)
+	}
+	slog.SetDefault(logger)
+	logger.Info("config_loaded", "files", cfg.Files)
+	return cfg, logger
+}
@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/auth/store"
+	"demo/internal/config"
+	"demo/internal/maintenance"
+	"demo/internal/petstore"
+)
+
+// Options configures the admin mux.
+type Options struct {
+	Pool *pgxpool.Pool
+	// MetricsHandler, when non-nil, is mounted at MetricsPath.
+	MetricsPath    string
+	MetricsHandler http.Handler
+	// Maintenance, when non-nil, is toggled via /admin/maintenance.
+	Maintenance *maintenance.Mode
+	// Users, when non-nil, backs the /admin/users endpoints, and Sessions, when also set,
+	// revokes the sessions of disabled and deleted users.
+	Users    store.UserRepository
+	Sessions SessionRevoker
+	// Config, when non-nil, returns the running configuration served redacted at
+	// /admin/config.
+	Config func() config.Config
+	// Purger, when non-nil, backs POST /admin/pets/purge, deleting PurgeBatchSize pets
+	// per transaction.
+	Purger         petstore.Purger
+	PurgeBatchSize int
+	Logger         *slog.Logger
+}
+
+// NewHandler builds the admin mux exposing pprof, expvar, a pool stats dump, and the
+// optional metrics, maintenance, effective configuration, user administration, and purge
+// endpoints.
+func NewHandler(opts Options) http.Handler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/pool", poolStats(opts.Pool, logger))
+	if opts.MetricsHandler != nil {
+		mux.Handle(opts.MetricsPath, opts.MetricsHandler)
+	}
+	if opts.Maintenance != nil {
+		mux.HandleFunc("/admin/maintenance", opts.Maintenance.Toggle)
+	}
+	if opts.Config != nil {
+		mux.HandleFunc("GET /admin/config", effectiveConfig(opts.Config, logger))
+	}
+	if opts.Users != nil {
+		users := NewUsersHandler("", opts.Users, opts.Sessions, logger)
+		mux.Handle("/admin/users", users)
+		mux.Handle("/admin/users/", users)
+	}
+	if opts.Purger != nil {
+		mux.Handle("/admin/pets/purge", NewPurgeHandler("", opts.Purger, opts.PurgeBatchSize, logger))
+	}
+	return mux
+}
+
+type poolStatsResponse struct {
+	AcquireCount            int64  `json:"acquire_count"`
+	AcquireDuration         string `json:"acquire_duration"`
+	AcquiredConns           int32  `json:"acquired_conns"`
+	CanceledAcquireCount    int64  `json:"canceled_acquire_count"`
+	ConstructingConns       int32  `json:"constructing_conns"`
+	EmptyAcquireCount       int64  `json:"empty_acquire_count"`
+	IdleConns               int32  `json:"idle_conns"`
+	MaxConns                int32  `json:"max_conns"`
+	TotalConns              int32  `json:"total_conns"`
+	NewConnsCount           int64  `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64  `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64  `json:"max_idle_destroy_count"`
+}
+
+func poolStats(pool *pgxpool.Pool, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stat := pool.Stat()
+		resp := poolStatsResponse{
+			AcquireCount:            stat.AcquireCount(),
+			AcquireDuration:         stat.AcquireDuration().String(),
+			AcquiredConns:           stat.AcquiredConns(),
+			CanceledAcquireCount:    stat.CanceledAcquireCount(),
+			ConstructingConns:       stat.ConstructingConns(),
+			EmptyAcquireCount:       stat.EmptyAcquireCount(),
+			IdleConns:               stat.IdleConns(),
+			MaxConns:                stat.MaxConns(),
+			TotalConns:              stat.TotalConns(),
+			NewConnsCount:           stat.NewConnsCount(),
+			MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+			MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(resp); err != nil {
+			logger.Error("admin_pool_stats_write_failed", "error", err)
+		}
+	}
+}
+
+// effectiveConfig serves the running configuration, after file, environment, and defaults
+// were merged, with secrets masked.
+func effectiveConfig(current func() config.Config, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(current().Redacted().Settings()); err != nil {
+			logger.Error("admin_config_write_failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+	"demo/internal/httpmw"
+	"demo/internal/petstore"
+)
+
+// NewPurgeHandler serves POST basePath+"/admin/pets/purge" with {"older_than": "720h"},
+// hard-deleting pets soft-deleted longer ago than older_than, batchSize per transaction,
+// and answering {"purged": n, "before": cutoff}.
+func NewPurgeHandler(basePath string, purger petstore.Purger, batchSize int, logger *slog.Logger) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	h := &purgeHandler{purger: purger, batchSize: batchSize, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+basePath+"/admin/pets/purge", h.purge)
+	return mux
+}
+
+type purgeHandler struct {
+	purger    petstore.Purger
+	batchSize int
+	logger    *slog.Logger
+}
+
+type purgeResponse struct {
+	Purged int64     `json:"purged"`
+	Before time.Time `json:"before"`
+}
+
+func (h *purgeHandler) purge(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OlderThan string `json:"older_than"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, `body must be {"older_than": duration}, e.g. {"older_than": "720h"}`)
+		return
+	}
+	olderThan, err := time.ParseDuration(req.OlderThan)
+	if err != nil || olderThan <= 0 {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, "older_than must be a positive duration such as 720h")
+		return
+	}
+
+	before := time.Now().Add(-olderThan).UTC()
+	attrs := []any{"older_than", olderThan.String(), "before", before}
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		attrs = append(attrs, "user_id", user.ID)
+	}
+	n, err := h.purger.PurgeDeletedPets(r.Context(), before, h.batchSize)
+	if err != nil {
+		// Batches that committed stay purged, so the count is worth recording.
+		h.logger.ErrorContext(r.Context(), "admin_pets_purge_failed", append(attrs, "purged", n, "error", err)...)
+		httpmw.WriteError(w, r, apierr.ErrInternal, "failed to purge pets")
+		return
+	}
+	h.logger.InfoContext(r.Context(), "admin_pets_purged", append(attrs, "purged", n)...)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(purgeResponse{Purged: n, Before: before}); err != nil {
+		h.logger.Error("admin_pets_purge_write_failed", "error", err)
+	}
+}
@@ -0,0 +1,308 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+	"demo/internal/auth/store"
+	"demo/internal/httpmw"
+)
+
+// SessionRevoker ends every session of a user; *session.Manager implements it.
+type SessionRevoker interface {
+	RevokeAll(ctx context.Context, userID string) (int64, error)
+}
+
+// Page sizes for GET /admin/users.
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 100
+)
+
+// userResponse is a user as the admin endpoints show it; stored tokens are never
+// included.
+type userResponse struct {
+	ID          int64     `json:"id"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	Name        string    `json:"name"`
+	Picture     string    `json:"picture,omitempty"`
+	Role        string    `json:"role"`
+	Disabled    bool      `json:"disabled"`
+	OrgID       string    `json:"org_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+func newUserResponse(u store.User) userResponse {
+	return userResponse{
+		ID:          u.ID,
+		Provider:    u.Provider,
+		Subject:     u.Subject,
+		Email:       u.Email,
+		Name:        u.Name,
+		Picture:     u.Picture,
+		Role:        u.Role,
+		Disabled:    u.Disabled,
+		OrgID:       u.OrgID,
+		CreatedAt:   u.CreatedAt,
+		LastLoginAt: u.LastLoginAt,
+	}
+}
+
+// NewUsersHandler serves user administration under basePath+"/admin/users". Sessions
+// must be able to revoke sessions (sessions.store) for users to be disabled, deleted, or
+// given a new role; when it is nil those requests are refused, since the user's session
+// cookies would otherwise stay valid until they expire.
+func NewUsersHandler(basePath string, users store.UserRepository, sessions SessionRevoker, logger *slog.Logger) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	h := &usersHandler{users: users, sessions: sessions, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+basePath+"/admin/users", h.list)
+	mux.HandleFunc("GET "+basePath+"/admin/users/{id}", h.get)
+	mux.HandleFunc("PATCH "+basePath+"/admin/users/{id}", h.update)
+	mux.HandleFunc("DELETE "+basePath+"/admin/users/{id}", h.delete)
+	mux.HandleFunc("PUT "+basePath+"/admin/users/{id}/role", h.setRole)
+	return mux
+}
+
+type usersHandler struct {
+	users    store.UserRepository
+	sessions SessionRevoker
+	logger   *slog.Logger
+}
+
+// list serves GET /admin/users?email=&role=&limit=&after=, paginated by ID; the x-next
+// header links to the next page.
+func (h *usersHandler) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := store.UserFilter{EmailContains: query.Get("email"), Limit: defaultUserPageSize}
+	if role := query.Get("role"); role != "" {
+		parsed, err := auth.ParseRole(role)
+		if err != nil {
+			httpmw.WriteError(w, r, apierr.ErrInvalidRequest, err.Error())
+			return
+		}
+		filter.Role = string(parsed)
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			httpmw.WriteError(w, r, apierr.ErrInvalidRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = min(limit, maxUserPageSize)
+	}
+	if raw := query.Get("after"); raw != "" {
+		after, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || after < 0 {
+			httpmw.WriteError(w, r, apierr.ErrInvalidRequest, "after must be a non-negative integer")
+			return
+		}
+		filter.AfterID = after
+	}
+
+	pageSize := filter.Limit
+	filter.Limit++
+	users, err := h.users.ListUsers(r.Context(), filter)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "admin_list_users_failed", "error", err)
+		httpmw.WriteError(w, r, apierr.ErrInternal, "failed to list users")
+		return
+	}
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		next := url.Values{"limit": {strconv.Itoa(pageSize)}, "after": {strconv.FormatInt(users[pageSize-1].ID, 10)}}
+		if filter.EmailContains != "" {
+			next.Set("email", filter.EmailContains)
+		}
+		if filter.Role != "" {
+			next.Set("role", filter.Role)
+		}
+		w.Header().Set("x-next", r.URL.Path+"?"+next.Encode())
+	}
+
+	out := make([]userResponse, 0, len(users))
+	for _, u := range users {
+		out = append(out, newUserResponse(u))
+	}
+	h.writeJSON(w, r, out)
+}
+
+// get serves GET /admin/users/{id}.
+func (h *usersHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.users.User(r.Context(), id)
+	if !h.checkStoreError(w, r, "admin_get_user_failed", id, err) {
+		return
+	}
+	h.writeJSON(w, r, newUserResponse(user))
+}
+
+// update serves PATCH /admin/users/{id} with {"role": ..., "disabled": ...}; omitted
+// fields are left alone. Disabling a user or changing their role revokes their sessions.
+func (h *usersHandler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role     *string `json:"role"`
+		Disabled *bool   `json:"disabled"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, `body must be {"role": "viewer"|"editor"|"admin", "disabled": true|false}`)
+		return
+	}
+	var update store.UserUpdate
+	if req.Role != nil {
+		role, err := auth.ParseRole(*req.Role)
+		if err != nil {
+			httpmw.WriteError(w, r, apierr.ErrInvalidRequest, err.Error())
+			return
+		}
+		roleName := string(role)
+		update.Role = &roleName
+	}
+	update.Disabled = req.Disabled
+	revoke := update.Role != nil || (update.Disabled != nil && *update.Disabled)
+	if revoke && !h.canRevoke(w, r) {
+		return
+	}
+
+	user, err := h.users.UpdateUser(r.Context(), id, update)
+	if !h.checkStoreError(w, r, "admin_update_user_failed", id, err) {
+		return
+	}
+	h.logger.InfoContext(r.Context(), "admin_user_updated", "user_id", id, "role", user.Role, "disabled", user.Disabled)
+	if revoke && !h.revokeSessions(w, r, id) {
+		return
+	}
+	h.writeJSON(w, r, newUserResponse(user))
+}
+
+// delete serves DELETE /admin/users/{id}, removing the user and their stored tokens and
+// revoking their sessions. Pets they own keep their owner_id.
+func (h *usersHandler) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok || !h.canRevoke(w, r) {
+		return
+	}
+	err := h.users.DeleteUser(r.Context(), id)
+	if !h.checkStoreError(w, r, "admin_delete_user_failed", id, err) {
+		return
+	}
+	h.logger.InfoContext(r.Context(), "admin_user_deleted", "user_id", id)
+	if !h.revokeSessions(w, r, id) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setRole serves PUT /admin/users/{id}/role with {"role": "viewer"|"editor"|"admin"}.
+// The user's sessions are revoked, so the new role applies from their next login.
+func (h *usersHandler) setRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, `body must be {"role": "viewer"|"editor"|"admin"}`)
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, err.Error())
+		return
+	}
+	if !h.canRevoke(w, r) {
+		return
+	}
+
+	user, err := h.users.SetRole(r.Context(), id, string(role))
+	if !h.checkStoreError(w, r, "admin_set_user_role_failed", id, err) {
+		return
+	}
+	h.logger.InfoContext(r.Context(), "admin_user_role_changed", "user_id", id, "role", role)
+	if !h.revokeSessions(w, r, id) {
+		return
+	}
+	h.writeJSON(w, r, newUserResponse(user))
+}
+
+// canRevoke refuses a change that must sign the user out when there is no session store
+// to revoke their sessions in, and reports whether the change may go ahead. Sessions
+// carry the role and are not checked against the user afterwards, so without revocation
+// a disabled user would stay signed in with their old role.
+func (h *usersHandler) canRevoke(w http.ResponseWriter, r *http.Request) bool {
+	if h.sessions != nil {
+		return true
+	}
+	httpmw.WriteError(w, r, apierr.ErrConflict, "sessions.store must be enabled to disable, delete, or change the role of a user")
+	return false
+}
+
+// revokeSessions ends the user's sessions so a disabled, deleted, or re-roled account is
+// signed out at once, and reports whether it did. The change to the user is already
+// stored, so a failure answers 500 for the admin to retry.
+func (h *usersHandler) revokeSessions(w http.ResponseWriter, r *http.Request, id int64) bool {
+	n, err := h.sessions.RevokeAll(r.Context(), strconv.FormatInt(id, 10))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "admin_user_sessions_revoke_failed", "user_id", id, "error", err)
+		httpmw.WriteError(w, r, apierr.ErrInternal, fmt.Sprintf("user %d was updated but their sessions could not be revoked", id))
+		return false
+	}
+	h.logger.InfoContext(r.Context(), "admin_user_sessions_revoked", "user_id", id, "count", n)
+	return true
+}
+
+func userID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, "user id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// checkStoreError answers a failed repository call and reports whether err was nil.
+func (h *usersHandler) checkStoreError(w http.ResponseWriter, r *http.Request, event string, id int64, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, store.ErrNotFound):
+		httpmw.WriteError(w, r, apierr.ErrNotFound, "user not found")
+	default:
+		h.logger.ErrorContext(r.Context(), event, "user_id", id, "error", err)
+		httpmw.WriteError(w, r, apierr.ErrInternal, fmt.Sprintf("failed to access user %d", id))
+	}
+	return false
+}
+
+func (h *usersHandler) writeJSON(w http.ResponseWriter, r *http.Request, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		h.logger.ErrorContext(r.Context(), "admin_users_write_failed", "error", err)
+	}
+}
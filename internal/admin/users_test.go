@@ -0,0 +1,192 @@
+package admin_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"demo/internal/admin"
+	"demo/internal/auth"
+	"demo/internal/auth/session"
+	"demo/internal/auth/store"
+	appconfig "demo/internal/config"
+)
+
+// memoryUsers is a store.UserRepository over a map, implementing what the admin handler
+// calls; the other methods panic through the nil embedded interface.
+type memoryUsers struct {
+	store.UserRepository
+	mu    sync.Mutex
+	users map[int64]store.User
+}
+
+func newMemoryUsers(users ...store.User) *memoryUsers {
+	m := &memoryUsers{users: make(map[int64]store.User)}
+	for _, u := range users {
+		m.users[u.ID] = u
+	}
+	return m
+}
+
+func (m *memoryUsers) User(_ context.Context, id int64) (store.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return store.User{}, store.ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *memoryUsers) UpdateUser(_ context.Context, id int64, update store.UserUpdate) (store.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return store.User{}, store.ErrNotFound
+	}
+	if update.Role != nil {
+		u.Role = *update.Role
+	}
+	if update.Disabled != nil {
+		u.Disabled = *update.Disabled
+	}
+	m.users[id] = u
+	return u, nil
+}
+
+func (m *memoryUsers) SetRole(ctx context.Context, id int64, role string) (store.User, error) {
+	return m.UpdateUser(ctx, id, store.UserUpdate{Role: &role})
+}
+
+func (m *memoryUsers) DeleteUser(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(m.users, id)
+	return nil
+}
+
+// sessionFixture is a session manager with an optional server-side store, an API handler
+// behind the same authentication the router uses, and a session issued to user 1.
+type sessionFixture struct {
+	manager *session.Manager
+	api     http.Handler
+	cookie  *http.Cookie
+	csrf    string
+}
+
+func newSessionFixture(t *testing.T, opts ...session.Option) *sessionFixture {
+	t.Helper()
+	logger := slog.New(slog.DiscardHandler)
+	manager, err := session.NewManager(appconfig.SessionsConfig{
+		CookieName: "session",
+		Lifetime:   time.Hour,
+		Keys:       []string{"test-session-key-0123456789abcdef"},
+		Path:       "/",
+	}, logger, opts...)
+	if err != nil {
+		t.Fatalf("session.NewManager: %v", err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	api := manager.Middleware(auth.NewAuthenticator(manager, nil, logger).Middleware(true)(ok))
+
+	rec := httptest.NewRecorder()
+	if err := manager.Issue(rec, httptest.NewRequest(http.MethodGet, "/", nil), session.Session{UserID: "1", Role: "editor", CSRF: "csrf-token"}); err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Issue: got cookies %v, want one", cookies)
+	}
+	return &sessionFixture{manager: manager, api: api, cookie: cookies[0], csrf: "csrf-token"}
+}
+
+// write sends a cookie-authenticated POST to the API and returns its status.
+func (f *sessionFixture) write(t *testing.T) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/pets", nil)
+	req.AddCookie(f.cookie)
+	req.Header.Set(auth.CSRFHeader, f.csrf)
+	rec := httptest.NewRecorder()
+	f.api.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func serve(t *testing.T, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestUserChangesRevokeSessions checks that disabling, re-roling, or deleting a user ends
+// their session at once: the next request with their cookie is rejected.
+func TestUserChangesRevokeSessions(t *testing.T) {
+	for _, tc := range []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"Disable", http.MethodPatch, "/admin/users/1", `{"disabled": true}`, http.StatusOK},
+		{"ChangeRole", http.MethodPut, "/admin/users/1/role", `{"role": "viewer"}`, http.StatusOK},
+		{"Delete", http.MethodDelete, "/admin/users/1", "", http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newSessionFixture(t, session.WithStore(session.NewMemoryStore(t.Context()), 0))
+			users := newMemoryUsers(store.User{ID: 1, Email: "ada@example.com", Role: "editor"})
+			handler := admin.NewUsersHandler("", users, f.manager, slog.New(slog.DiscardHandler))
+
+			if code := f.write(t); code != http.StatusNoContent {
+				t.Fatalf("write before %s: got %d, want 204", tc.name, code)
+			}
+			if rec := serve(t, handler, tc.method, tc.path, tc.body); rec.Code != tc.want {
+				t.Fatalf("%s %s: got %d %s, want %d", tc.method, tc.path, rec.Code, rec.Body, tc.want)
+			}
+			if code := f.write(t); code != http.StatusUnauthorized {
+				t.Fatalf("write after %s: got %d, want 401", tc.name, code)
+			}
+		})
+	}
+}
+
+// TestUserChangesNeedSessionStore checks that, with cookie-only sessions, changes that
+// would have to sign the user out answer 409 and leave the user and their session alone,
+// while changes that need no sign-out still go through.
+func TestUserChangesNeedSessionStore(t *testing.T) {
+	f := newSessionFixture(t)
+	users := newMemoryUsers(store.User{ID: 1, Email: "ada@example.com", Role: "editor"})
+	handler := admin.NewUsersHandler("", users, nil, slog.New(slog.DiscardHandler))
+
+	for _, tc := range []struct {
+		name, method, path, body string
+	}{
+		{"Disable", http.MethodPatch, "/admin/users/1", `{"disabled": true}`},
+		{"PatchRole", http.MethodPatch, "/admin/users/1", `{"role": "admin"}`},
+		{"SetRole", http.MethodPut, "/admin/users/1/role", `{"role": "admin"}`},
+		{"Delete", http.MethodDelete, "/admin/users/1", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := serve(t, handler, tc.method, tc.path, tc.body); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "sessions.store") {
+				t.Fatalf("%s %s: got %d %s, want 409 naming sessions.store", tc.method, tc.path, rec.Code, rec.Body)
+			}
+		})
+	}
+	if u, err := users.User(t.Context(), 1); err != nil || u.Disabled || u.Role != "editor" {
+		t.Fatalf("user after refused changes: got %+v, %v; want unchanged", u, err)
+	}
+	if code := f.write(t); code != http.StatusNoContent {
+		t.Fatalf("write after refused changes: got %d, want 204", code)
+	}
+
+	if rec := serve(t, handler, http.MethodPatch, "/admin/users/1", `{"disabled": false}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH enabling the user: got %d %s, want 200", rec.Code, rec.Body)
+	}
+}
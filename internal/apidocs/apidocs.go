@@ -0,0 +1,94 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/yaml"
+)
+
+// Handler serves a pre-rendered copy of the OpenAPI document and an optional Redoc page.
+type Handler struct {
+	logger   *slog.Logger
+	json     []byte
+	yaml     []byte
+	docsPage []byte
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="{{.SpecURL}}"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`))
+
+// NewHandler renders spec with its servers list replaced by serverURL (the external URL
+// plus base path, or just the base path when the external URL is unknown). The spec is
+// not modified.
+func NewHandler(spec *openapi3.T, serverURL string, logger *slog.Logger) (*Handler, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	rendered := *spec
+	if serverURL == "" {
+		serverURL = "/"
+	}
+	rendered.Servers = openapi3.Servers{{URL: serverURL}}
+
+	jsonDoc, err := json.MarshalIndent(&rendered, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render openapi json: %w", err)
+	}
+	yamlDoc, err := yaml.JSONToYAML(jsonDoc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render openapi yaml: %w", err)
+	}
+
+	title := "API reference"
+	if spec.Info != nil && spec.Info.Title != "" {
+		title = spec.Info.Title
+	}
+	var page strings.Builder
+	if err := docsTemplate.Execute(&page, struct{ Title, SpecURL string }{title, "openapi.json"}); err != nil {
+		return nil, fmt.Errorf("failed to render docs page: %w", err)
+	}
+
+	return &Handler{logger: logger, json: jsonDoc, yaml: yamlDoc, docsPage: []byte(page.String())}, nil
+}
+
+// JSON serves the OpenAPI document as JSON.
+func (h *Handler) JSON(w http.ResponseWriter, r *http.Request) {
+	h.write(w, "application/json", h.json)
+}
+
+// YAML serves the OpenAPI document as YAML.
+func (h *Handler) YAML(w http.ResponseWriter, r *http.Request) {
+	h.write(w, "application/yaml", h.yaml)
+}
+
+// Docs serves a Redoc page that loads openapi.json relative to its own URL, so it must be
+// mounted next to JSON.
+func (h *Handler) Docs(w http.ResponseWriter, r *http.Request) {
+	h.write(w, "text/html; charset=utf-8", h.docsPage)
+}
+
+func (h *Handler) write(w http.ResponseWriter, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if _, err := w.Write(body); err != nil {
+		h.logger.Warn("apidocs_write_failed", "error", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package apierr defines the categories every API error falls into and a sentinel error
+// for each. A category is coarse and closed, e.g. PET_NOT_FOUND or RATE_LIMITED, so
+// clients can branch on it; it is sent as Error.category alongside the status, while
+// error_code keeps the finer message code the text was rendered from. The list here,
+// the Error schema's category enum, and the constants in pkg/petstoreclient must agree.
+package apierr
+
+import (
+	"net/http"
+
+	"demo/internal/i18n"
+)
+
+// Category is the error category sent to clients. Categories must never change meaning.
+type Category string
+
+// Categories, sorted.
+const (
+	BodyTooLarge         Category = "BODY_TOO_LARGE"
+	Conflict             Category = "CONFLICT"
+	ExportNotFound       Category = "EXPORT_NOT_FOUND"
+	Forbidden            Category = "FORBIDDEN"
+	Internal             Category = "INTERNAL"
+	InvalidRequest       Category = "INVALID_REQUEST"
+	MethodNotAllowed     Category = "METHOD_NOT_ALLOWED"
+	NotFound             Category = "NOT_FOUND"
+	NotPetOwner          Category = "NOT_PET_OWNER"
+	PetExists            Category = "PET_EXISTS"
+	PetNotFound          Category = "PET_NOT_FOUND"
+	PhotoNotFound        Category = "PHOTO_NOT_FOUND"
+	RateLimited          Category = "RATE_LIMITED"
+	Timeout              Category = "TIMEOUT"
+	Unauthenticated      Category = "UNAUTHENTICATED"
+	Unavailable          Category = "UNAVAILABLE"
+	UnsupportedMediaType Category = "UNSUPPORTED_MEDIA_TYPE"
+	ValidationFailed     Category = "VALIDATION_FAILED"
+)
+
+// Sentinel errors, one per category. Compare with errors.Is, which matches any Error of
+// the same category whatever its message; use With to attach a specific message.
+var (
+	ErrBodyTooLarge         = newSentinel(BodyTooLarge, http.StatusRequestEntityTooLarge)
+	ErrConflict             = newSentinel(Conflict, http.StatusConflict)
+	ErrExportNotFound       = newSentinel(ExportNotFound, http.StatusNotFound)
+	ErrForbidden            = newSentinel(Forbidden, http.StatusForbidden)
+	ErrInternal             = newSentinel(Internal, http.StatusInternalServerError)
+	ErrInvalidRequest       = newSentinel(InvalidRequest, http.StatusBadRequest)
+	ErrMethodNotAllowed     = newSentinel(MethodNotAllowed, http.StatusMethodNotAllowed)
+	ErrNotFound             = newSentinel(NotFound, http.StatusNotFound)
+	ErrNotPetOwner          = newSentinel(NotPetOwner, http.StatusForbidden)
+	ErrPetExists            = newSentinel(PetExists, http.StatusConflict)
+	ErrPetNotFound          = newSentinel(PetNotFound, http.StatusNotFound)
+	ErrPhotoNotFound        = newSentinel(PhotoNotFound, http.StatusNotFound)
+	ErrRateLimited          = newSentinel(RateLimited, http.StatusTooManyRequests)
+	ErrTimeout              = newSentinel(Timeout, http.StatusGatewayTimeout)
+	ErrUnauthenticated      = newSentinel(Unauthenticated, http.StatusUnauthorized)
+	ErrUnavailable          = newSentinel(Unavailable, http.StatusServiceUnavailable)
+	ErrUnsupportedMediaType = newSentinel(UnsupportedMediaType, http.StatusUnsupportedMediaType)
+	ErrValidationFailed     = newSentinel(ValidationFailed, http.StatusBadRequest)
+)
+
+// sentinels lists the sentinel errors in category order.
+var sentinels = []*Error{
+	ErrBodyTooLarge, ErrConflict, ErrExportNotFound, ErrForbidden, ErrInternal,
+	ErrInvalidRequest, ErrMethodNotAllowed, ErrNotFound, ErrNotPetOwner, ErrPetExists,
+	ErrPetNotFound, ErrPhotoNotFound, ErrRateLimited, ErrTimeout, ErrUnauthenticated,
+	ErrUnavailable, ErrUnsupportedMediaType, ErrValidationFailed,
+}
+
+// Error is an API failure: its category, the status it is answered with, and the i18n
+// message code (sent as error_code) and arguments its text is rendered from.
+type Error struct {
+	Category Category
+	Status   int
+	// Message is an i18n message code; a sentinel's is its category.
+	Message string
+	// Args fill Message's placeholders, as alternating names and values.
+	Args []any
+}
+
+func newSentinel(category Category, status int) *Error {
+	return &Error{Category: category, Status: status, Message: string(category)}
+}
+
+// With returns a copy of e rendered from message code and args instead.
+func (e *Error) With(message string, args ...any) *Error {
+	return &Error{Category: e.Category, Status: e.Status, Message: message, Args: args}
+}
+
+// Error returns the English message.
+func (e *Error) Error() string {
+	return i18n.Message(i18n.Default, e.Message, e.Args...)
+}
+
+// Is reports whether target is an Error of the same category.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Category == e.Category
+}
+
+// Categories returns every category, sorted.
+func Categories() []Category {
+	out := make([]Category, len(sentinels))
+	for i, s := range sentinels {
+		out[i] = s.Category
+	}
+	return out
+}
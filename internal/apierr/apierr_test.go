@@ -0,0 +1,32 @@
+package apierr_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"demo/internal/apierr"
+	"demo/internal/i18n"
+)
+
+// TestCategoriesHaveMessages checks that a bare sentinel renders text in every locale
+// rather than its code.
+func TestCategoriesHaveMessages(t *testing.T) {
+	for _, category := range apierr.Categories() {
+		for _, locale := range i18n.Locales() {
+			if got := i18n.Message(locale, string(category)); got == string(category) {
+				t.Errorf("category %s has no %s message", category, locale)
+			}
+		}
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := apierr.ErrNotFound.With("PHOTOS_DISABLED")
+	if !errors.Is(err, apierr.ErrNotFound) || errors.Is(err, apierr.ErrPetNotFound) {
+		t.Fatalf("errors.Is(%v): want a match on its category only", err)
+	}
+	if err.Status != http.StatusNotFound || err.Error() != "pet photos are disabled" {
+		t.Fatalf("With: got status %d and %q, want 404 with the code's English text", err.Status, err.Error())
+	}
+}
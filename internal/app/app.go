@@ -0,0 +1,836 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"demo/internal/admin"
+	"demo/internal/auth"
+	githubauth "demo/internal/auth/github"
+	googleauth "demo/internal/auth/google"
+	mockauth "demo/internal/auth/mock"
+	"demo/internal/auth/session"
+	"demo/internal/auth/store"
+	"demo/internal/blob"
+	"demo/internal/buildinfo"
+	"demo/internal/config"
+	"demo/internal/database"
+	"demo/internal/dblock"
+	"demo/internal/errreport"
+	"demo/internal/export"
+	"demo/internal/features"
+	"demo/internal/grpcapi"
+	"demo/internal/health"
+	"demo/internal/httpclient"
+	"demo/internal/httpmw"
+	"demo/internal/listen"
+	"demo/internal/logging"
+	"demo/internal/maintenance"
+	"demo/internal/metrics"
+	"demo/internal/petstore"
+	"demo/internal/purge"
+	"demo/internal/systemd"
+	"demo/internal/telemetry"
+	"demo/internal/tenant"
+	"demo/internal/tlsserver"
+)
+
+// App is the petstore server. New checks the configuration; Run owns everything with a
+// lifetime: connections, listeners, background workers, and the shutdown sequence.
+type App struct {
+	watcher        *config.Watcher
+	logger         *slog.Logger
+	logLevel       *slog.LevelVar
+	load           func() (config.Config, error)
+	configChecksum string
+}
+
+// Option configures optional App behaviour.
+type Option func(*App)
+
+// WithLogger sets the logger, and the level variable it was built with so a reload can
+// change logging.level. A nil level leaves the level fixed.
+func WithLogger(logger *slog.Logger, level *slog.LevelVar) Option {
+	return func(a *App) {
+		if logger != nil {
+			a.logger = logger
+		}
+		if level != nil {
+			a.logLevel = level
+		}
+	}
+}
+
+// WithReload sets how SIGHUP re-reads the configuration, usually a closure over
+// config.Load with the original path. Without it SIGHUP only reloads TLS certificates.
+func WithReload(load func() (config.Config, error)) Option {
+	return func(a *App) {
+		if load != nil {
+			a.load = load
+		}
+	}
+}
+
+// New returns an App for cfg, which must have come from config.Load or pass
+// Config.Validate. Nothing is connected or started until Run.
+func New(cfg config.Config, opts ...Option) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	a := &App{
+		logger:         slog.Default(),
+		logLevel:       new(slog.LevelVar),
+		load:           func() (config.Config, error) { return cfg, nil },
+		configChecksum: cfg.Checksum(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.watcher = config.NewWatcher(cfg, a.load, a.logger)
+	return a, nil
+}
+
+// Run builds the dependencies, starts the server, and blocks until ctx is cancelled or a
+// listener fails, then shuts down in order: readiness off, drain delay, HTTP server,
+// auxiliary listeners, background workers, telemetry, and finally the database pools.
+// Errors are returned rather than exiting so deferred cleanup always runs.
+func (a *App) Run(ctx context.Context) error {
+	cfg, watcher, logger := a.watcher.Current(), a.watcher, a.logger
+	serverErrs := make(chan error, 6)
+	startServer := func(name string, serve func() error) {
+		go func() {
+			if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrs <- fmt.Errorf("%s: %w", name, err)
+			}
+		}()
+	}
+
+	// Background workers outlive the signal so they keep running through the drain period;
+	// they are stopped explicitly once the HTTP server has shut down.
+	workerCtx, stopWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopWorkers()
+	var workers sync.WaitGroup
+
+	shutdownTelemetry, err := telemetry.Setup(ctx, cfg.Telemetry)
+	if err != nil {
+		return fmt.Errorf("failed to initialize telemetry: %w", err)
+	}
+	reporter, err := errreport.New(cfg.Telemetry.Sentry, buildinfo.Get().Version)
+	if err != nil {
+		return fmt.Errorf("failed to initialize error reporting: %w", err)
+	}
+
+	if cfg.Pagination.CursorKey == "" {
+		logger.Warn("pagination_cursor_key_missing",
+			"impact", "GET /pets issues no cursors; set pagination.cursor_key outside development")
+	}
+
+	flags := features.New(cfg.Features, logger)
+	maintenanceMode := maintenance.New(cfg.Maintenance.Enabled, cfg.Maintenance.RetryAfter, logger)
+
+	poolConfig, err := NewPoolConfig(cfg.Database, logger)
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	logger.Info("database_pool_config",
+		"max_conns", poolConfig.MaxConns,
+		"min_conns", poolConfig.MinConns,
+		"max_conn_lifetime", poolConfig.MaxConnLifetime,
+		"max_conn_idle_time", poolConfig.MaxConnIdleTime,
+		"health_check_period", poolConfig.HealthCheckPeriod,
+		"query_exec_mode", poolConfig.ConnConfig.DefaultQueryExecMode.String(),
+	)
+
+	pool, err := database.Connect(ctx, poolConfig, cfg.Database.Retry, logger)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	var sessions *session.Manager
+	if len(cfg.Sessions.Keys) > 0 {
+		var sessionOpts []session.Option
+		switch cfg.Sessions.Store {
+		case "memory":
+			sessionOpts = append(sessionOpts, session.WithStore(session.NewMemoryStore(workerCtx), cfg.Sessions.CacheTTL))
+		case "postgres":
+			store, err := session.NewPostgresStore(ctx, pool, logger)
+			if err != nil {
+				return fmt.Errorf("failed to initialize session store: %w", err)
+			}
+			sessionOpts = append(sessionOpts, session.WithStore(store, cfg.Sessions.CacheTTL))
+		}
+		sessions, err = session.NewManager(cfg.Sessions, logger, sessionOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to initialize sessions: %w", err)
+		}
+	}
+
+	var readPool *pgxpool.Pool
+	if cfg.Database.ReadDSN != "" {
+		readCfg := config.DatabaseConfig{DSN: cfg.Database.ReadDSN, Pool: cfg.Database.Pool, TLS: cfg.Database.TLS}
+		readPoolConfig, err := database.NewPoolConfig(readCfg)
+		if err != nil {
+			return fmt.Errorf("invalid database read replica configuration: %w", err)
+		}
+		readPoolConfig.ConnConfig.Tracer = poolConfig.ConnConfig.Tracer
+		if cfg.Database.QueryExecMode != "" {
+			readPoolConfig.ConnConfig.DefaultQueryExecMode = poolConfig.ConnConfig.DefaultQueryExecMode
+		}
+		readPool, err = database.Connect(ctx, readPoolConfig, cfg.Database.Retry, logger)
+		if err != nil {
+			logger.Warn("database_replica_unavailable", "fallback", "primary", "error", err)
+		} else {
+			defer readPool.Close()
+		}
+	}
+
+	repo, err := petstore.NewPostgresRepository(ctx, pool,
+		petstore.WithQueryTimeouts(cfg.Database.QueryTimeout, cfg.Database.QueryTimeouts),
+		petstore.WithReadReplica(readPool),
+		petstore.WithLogger(logger),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to initialize pet repository: %w", err)
+	}
+
+	// One client serves every feature keeping its files in the bucket.
+	var s3Store *blob.S3Store
+	if (cfg.Exports.Enabled && cfg.Exports.Store == "s3") || (cfg.Photos.Enabled && cfg.Photos.Store == "s3") {
+		s3 := cfg.Storage.S3
+		s3Store, err = blob.NewS3Store(ctx, blob.S3Options{
+			Endpoint:        s3.Endpoint,
+			Bucket:          s3.Bucket,
+			Region:          s3.Region,
+			AccessKeyID:     s3.AccessKeyID,
+			SecretAccessKey: s3.SecretAccessKey,
+			Prefix:          s3.Prefix,
+			PresignTTL:      s3.PresignTTL,
+			PartSize:        uint64(s3.PartSize),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize S3 storage: %w", err)
+		}
+	}
+
+	var exports petstore.Exports
+	if cfg.Exports.Enabled {
+		var storage export.Storage
+		if cfg.Exports.Store == "s3" {
+			storage = export.NewBlobStorage(s3Store)
+		} else {
+			dir := cfg.Exports.Dir
+			if dir == "" {
+				dir = filepath.Join(os.TempDir(), "petstore-exports")
+			}
+			if storage, err = export.NewFileStorage(dir); err != nil {
+				return fmt.Errorf("failed to initialize export storage: %w", err)
+			}
+		}
+		service, err := export.New(ctx, pool, storage, export.Options{
+			Retention:    cfg.Exports.Retention,
+			PollInterval: cfg.Exports.PollInterval,
+			BatchSize:    cfg.Exports.BatchSize,
+			Logger:       logger,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize exports: %w", err)
+		}
+		// Stopping the worker requeues the export it is running.
+		workers.Go(func() { service.Run(workerCtx) })
+		exports = service
+	}
+
+	locks := dblock.New(pool, dblock.Options{Logger: logger})
+	if cfg.Purge.Enabled {
+		scheduler := purge.NewScheduler(locks, repo, purge.Options{
+			Retention: cfg.Purge.Retention,
+			Interval:  cfg.Purge.Interval,
+			BatchSize: cfg.Purge.BatchSize,
+			Logger:    logger,
+		})
+		workers.Go(func() { scheduler.Run(workerCtx) })
+	}
+
+	var photos petstore.BlobStore
+	if cfg.Photos.Enabled {
+		switch cfg.Photos.Store {
+		case "file":
+			dir := cfg.Photos.Dir
+			if dir == "" {
+				dir = filepath.Join(os.TempDir(), "petstore-photos")
+			}
+			photos, err = blob.NewFileStore(dir)
+		case "s3":
+			photos = s3Store
+		default:
+			photos, err = blob.NewPostgresStore(ctx, pool)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to initialize photo store: %w", err)
+		}
+	}
+
+	var users store.UserRepository
+	if cfg.LoginEnabled() {
+		if users, err = store.NewPostgresUserRepository(ctx, pool, cfg.Login.TokenEncryptionKey); err != nil {
+			return fmt.Errorf("failed to initialize user repository: %w", err)
+		}
+	}
+	// Without sessions.store a session cannot be revoked, and user administration refuses
+	// the changes that need it.
+	var sessionRevoker admin.SessionRevoker
+	if sessions != nil && cfg.Sessions.Store != "" && cfg.Sessions.Store != "cookie" {
+		sessionRevoker = sessions
+	}
+
+	healthHandler := health.NewHandler(0, logger)
+	healthHandler.Register("database", pool.Ping)
+	if readPool != nil {
+		healthHandler.Register("database_replica", readPool.Ping)
+	}
+	healthHandler.RegisterStatus("maintenance", maintenanceMode.Status)
+	if cfg.Purge.Enabled {
+		healthHandler.RegisterStatus("lock_"+purge.LockKey, func() string { return locks.Status(purge.LockKey) })
+	}
+
+	// Collectors built by this run go in its own registry rather than the default one,
+	// which would refuse them on a second Run of the same process.
+	registry := prometheus.NewRegistry()
+
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Address != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle(cfg.Metrics.Path, metrics.Handler(registry))
+		metricsServer = &http.Server{
+			Addr:              cfg.Metrics.Address,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: cfg.Server.Timeouts.ReadHeader,
+		}
+		logger.Info("metrics_listen", "addr", cfg.Metrics.Address)
+		startServer("metrics server", metricsServer.ListenAndServe)
+	}
+
+	var adminServer *http.Server
+	if cfg.Server.AdminAddress != "" {
+		var metricsHandler http.Handler
+		if cfg.Metrics.Enabled {
+			metricsHandler = metrics.Handler(registry)
+		}
+		// Only the header timeout applies: pprof profiles and traces stream for longer
+		// than the public write timeout.
+		adminServer = &http.Server{
+			Addr: cfg.Server.AdminAddress,
+			Handler: admin.NewHandler(admin.Options{
+				Pool:           pool,
+				MetricsPath:    cfg.Metrics.Path,
+				MetricsHandler: metricsHandler,
+				Maintenance:    maintenanceMode,
+				Users:          users,
+				Sessions:       sessionRevoker,
+				Config:         watcher.Current,
+				Purger:         repo,
+				PurgeBatchSize: cfg.Purge.BatchSize,
+				Logger:         logger,
+			}),
+			ReadHeaderTimeout: cfg.Server.Timeouts.ReadHeader,
+		}
+		logger.Info("admin_listen", "addr", cfg.Server.AdminAddress)
+		startServer("admin server", adminServer.ListenAndServe)
+	}
+
+	instrumentedRepo := petstore.NewInstrumentedRepository(repo, pool)
+	registry.MustRegister(instrumentedRepo)
+
+	var petRepo petstore.PetRepository = instrumentedRepo
+	if bc := cfg.Database.Breaker; bc.Enabled {
+		// Caches sit above the breaker so cached reads keep working while it is open.
+		breaker := petstore.NewCircuitBreaker(bc.FailureThreshold, bc.Cooldown, logger)
+		registry.MustRegister(breaker)
+		healthHandler.Register("database_breaker", breaker.Check)
+		petRepo = petstore.NewBreakerRepository(petRepo, breaker)
+	}
+	// invalidatePet drops a pet from the caches in front of the repository after writes,
+	// such as merges, that go to the repository directly.
+	invalidatePet := func(context.Context, int64) {}
+	var redisRepo *petstore.RedisCachingRepository
+	var redisClient *redis.Client
+	if redisCfg := cfg.Cache.Redis; redisCfg.Enabled {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:         redisCfg.Address,
+			Password:     redisCfg.Password,
+			DB:           redisCfg.DB,
+			DialTimeout:  redisCfg.Timeout,
+			ReadTimeout:  redisCfg.Timeout,
+			WriteTimeout: redisCfg.Timeout,
+		})
+		defer redisClient.Close()
+
+		redisRepo, err = petstore.NewRedisCachingRepository(petRepo, redisClient, petstore.RedisCacheOptions{
+			TTL:       redisCfg.TTL,
+			KeyPrefix: redisCfg.KeyPrefix,
+			Channel:   redisCfg.Channel,
+			Logger:    logger,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize redis pet cache: %w", err)
+		}
+		petRepo = redisRepo
+		invalidatePet = redisRepo.Invalidate
+	}
+
+	if cfg.Cache.Enabled {
+		cachingRepo, err := petstore.NewCachingRepository(petRepo, petstore.CacheOptions{
+			Size:        cfg.Cache.Size,
+			TTL:         cfg.Cache.TTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize pet cache: %w", err)
+		}
+		registry.MustRegister(cachingRepo)
+		petRepo = cachingRepo
+		if redisRepo != nil {
+			workers.Go(func() { redisRepo.Subscribe(workerCtx, cachingRepo.Invalidate) })
+		}
+		invalidateShared := invalidatePet
+		invalidatePet = func(ctx context.Context, id int64) {
+			cachingRepo.Invalidate(tenant.FromContext(ctx), id)
+			invalidateShared(ctx, id)
+		}
+	}
+	var versions petstore.CollectionVersions
+	if cfg.Cache.ListETags.Enabled {
+		versionCache := petstore.NewVersionCache(petRepo, repo, cfg.Cache.ListETags.Refresh)
+		petRepo = versionCache
+		versions = versionCache
+		invalidateCaches := invalidatePet
+		invalidatePet = func(ctx context.Context, id int64) {
+			versionCache.Invalidate(tenant.FromContext(ctx))
+			invalidateCaches(ctx, id)
+		}
+	}
+	if photos != nil {
+		// Outermost, so a delete through any API also removes the pet's photo.
+		petRepo = petstore.NewPhotoCleanupRepository(petRepo, photos, logger)
+	}
+
+	var readLimiter, writeLimiter *httpmw.RateLimiter
+	if rl := cfg.RateLimit; rl.Enabled {
+		readLimiter = httpmw.NewRateLimiter("read", rl.Read.RPS, rl.Read.Burst, rl.IdleTTL, httpmw.ClientIP)
+		writeLimiter = httpmw.NewRateLimiter("write", rl.Write.RPS, rl.Write.Burst, rl.IdleTTL, httpmw.ClientIP)
+	}
+	slowRequests := logging.NewSlowRequests(cfg.Logging.SlowRequestThreshold, logger)
+
+	httpClients, err := httpclient.New(cfg.HTTPClients, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize http clients: %w", err)
+	}
+	registry.MustRegister(httpClients)
+
+	var (
+		mockIdP      *mockauth.Server
+		loginHandler *auth.LoginHandler
+	)
+	if cfg.LoginEnabled() {
+		if cfg.GoogleOAuth.Enabled && cfg.GoogleOAuth.Mode == "mock" {
+			mockIdP, err = mockauth.New(mockauth.Options{
+				BaseURL:     cfg.GoogleOAuth.MockBaseURL(),
+				ClientID:    cfg.GoogleOAuth.ClientID,
+				RedirectURL: cfg.GoogleOAuth.RedirectURL,
+				Logger:      logger,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to initialize mock oauth provider: %w", err)
+			}
+			logger.Warn("google_oauth_mock_enabled", "url", cfg.GoogleOAuth.MockBaseURL())
+		}
+		providers, err := loginProviders(ctx, cfg, mockIdP, httpClients, logger)
+		if err != nil {
+			return err
+		}
+		for _, p := range providers {
+			// Deferred provider setup degrades sign-in, not the whole instance.
+			if preparer, lazy := p.(auth.Preparer); lazy {
+				healthHandler.RegisterStatus(p.Name()+"_oauth", preparer.Status)
+			}
+		}
+		loginOpts := []auth.LoginOption{
+			auth.WithErrorReporter(reporter),
+			auth.WithUserRepository(users),
+			auth.WithBootstrapAdmin(cfg.Security.BootstrapAdminEmail),
+		}
+		if arl := cfg.Security.AuthRateLimit; arl.Enabled && arl.LockoutThreshold > 0 {
+			loginOpts = append(loginOpts, auth.WithLockout(auth.NewLockout(arl.LockoutThreshold, arl.LockoutWindow, arl.LockoutDuration)))
+		}
+		switch cfg.Login.StateStore {
+		case "memory":
+			loginOpts = append(loginOpts, auth.WithStateStore(auth.NewMemoryStateStore(workerCtx)))
+		case "postgres":
+			states, err := auth.NewPostgresStateStore(ctx, pool, logger)
+			if err != nil {
+				return fmt.Errorf("failed to initialize oauth state store: %w", err)
+			}
+			loginOpts = append(loginOpts, auth.WithStateStore(states))
+		}
+		loginHandler, err = auth.NewLoginHandler(cfg.Login, providers, sessions, logger, loginOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to initialize login handler: %w", err)
+		}
+	}
+
+	handler, err := newRouter(cfg, routerDeps{
+		logger:         logger,
+		reporter:       reporter,
+		flags:          flags,
+		maintenance:    maintenanceMode,
+		health:         healthHandler,
+		pets:           petRepo,
+		exports:        exports,
+		changes:        repo,
+		pager:          repo,
+		deduper:        repo,
+		versions:       versions,
+		purger:         repo,
+		photos:         photos,
+		photoOwners:    repo,
+		invalidatePet:  invalidatePet,
+		graphqlPets:    repo,
+		sessions:       sessions,
+		users:          users,
+		sessionRevoker: sessionRevoker,
+		login:          loginHandler,
+		mockIdP:        mockIdP,
+		readLimiter:    readLimiter,
+		writeLimiter:   writeLimiter,
+		slowRequests:   slowRequests,
+		metrics:        registry,
+		configChecksum: a.configChecksum,
+	})
+	if err != nil {
+		return err
+	}
+
+	var grpcServer *grpcapi.Server
+	if cfg.GRPC.Enabled {
+		var tokens auth.TokenVerifier
+		if len(cfg.Security.APITokens) > 0 {
+			tokens = auth.NewStaticTokens(cfg.Security.APITokens)
+		}
+		grpcServer = grpcapi.New(grpcapi.Options{
+			Pets:                 petRepo,
+			Pager:                repo,
+			Changes:              repo,
+			WatchInterval:        cfg.GRPC.WatchInterval,
+			Tokens:               tokens,
+			Orgs:                 tenant.NewResolver(users, logger),
+			EnforceRoles:         cfg.Security.EnforceRoles,
+			ReadRole:             auth.Role(cfg.Security.ReadRole),
+			RequireAuthForWrites: cfg.Security.RequireAuthForWrites,
+			Reflection:           cfg.GRPC.Reflection,
+			Logger:               logger,
+		})
+		grpcListener, err := net.Listen("tcp", cfg.GRPC.Address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s for grpc: %w", cfg.GRPC.Address, err)
+		}
+		logger.Info("grpc_listen", "addr", grpcListener.Addr().String())
+		startServer("grpc server", func() error { return grpcServer.Serve(grpcListener) })
+	}
+
+	addr := cfg.Server.Address
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	timeouts := cfg.Server.Timeouts
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       timeouts.Read,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+		MaxHeaderBytes:    int(cfg.Server.MaxHeaderBytes),
+	}
+
+	var redirectServer *http.Server
+	var certReloader *tlsserver.CertReloader
+	tlsCfg := cfg.Server.TLS
+	if tlsCfg.Enabled {
+		certs, err := tlsserver.NewCertReloader(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+		httpServer.TLSConfig = tlsserver.Config(certs.GetCertificate)
+		certReloader = certs
+
+		if tlsCfg.RedirectHTTPFrom != "" {
+			redirectServer = &http.Server{
+				Addr:              tlsCfg.RedirectHTTPFrom,
+				Handler:           tlsserver.RedirectHandler(addr),
+				ReadHeaderTimeout: timeouts.ReadHeader,
+			}
+			logger.Info("http_redirect_listen", "addr", tlsCfg.RedirectHTTPFrom)
+			startServer("http redirect server", redirectServer.ListenAndServe)
+		}
+	}
+
+	if acmeCfg := cfg.Server.ACME; acmeCfg.Enabled {
+		manager, err := tlsserver.NewAutocertManager(acmeCfg.Hostnames, acmeCfg.CacheDir, acmeCfg.DirectoryURL, acmeCfg.Email)
+		if err != nil {
+			return fmt.Errorf("failed to initialize acme: %w", err)
+		}
+		httpServer.TLSConfig = tlsserver.Config(manager.GetCertificate)
+
+		// The challenge listener redirects everything that isn't an HTTP-01 challenge to HTTPS.
+		redirectServer = &http.Server{
+			Addr:              acmeCfg.HTTPAddress,
+			Handler:           manager.HTTPHandler(nil),
+			ReadHeaderTimeout: timeouts.ReadHeader,
+		}
+		logger.Info("acme_http_listen", "addr", acmeCfg.HTTPAddress, "hostnames", acmeCfg.Hostnames)
+		startServer("acme http server", redirectServer.ListenAndServe)
+	}
+	serveTLS := httpServer.TLSConfig != nil
+
+	if cfg.Server.H2C && !serveTLS {
+		if err := enableH2C(httpServer, timeouts.Idle); err != nil {
+			return fmt.Errorf("failed to configure h2c: %w", err)
+		}
+	}
+
+	socketMode, err := cfg.Server.SocketFileMode()
+	if err != nil {
+		return fmt.Errorf("invalid server configuration: %w", err)
+	}
+	ln, err := systemd.Listener()
+	if err != nil {
+		return fmt.Errorf("failed to use systemd socket: %w", err)
+	}
+	if ln != nil {
+		addr = ln.Addr().String()
+		logger.Info("systemd_socket_activated", "addr", addr)
+	} else if ln, err = listen.Listen(addr, socketMode); err != nil {
+		return fmt.Errorf("failed to bind server address: %w", err)
+	}
+
+	watcher.OnChange(func(old, updated config.Config) {
+		if updated.Logging.Level != old.Logging.Level {
+			if level, err := logging.ParseLevel(updated.Logging.Level); err == nil {
+				a.logLevel.Set(level)
+			}
+		}
+		if tracer, ok := poolConfig.ConnConfig.Tracer.(*database.QueryTracer); ok {
+			tracer.SetSlowThreshold(updated.Database.Tracer.SlowThreshold)
+		}
+		slowRequests.SetThreshold(updated.Logging.SlowRequestThreshold)
+		flags.Set(updated.Features)
+		if readLimiter != nil {
+			readLimiter.SetLimit(updated.RateLimit.Read.RPS, updated.RateLimit.Read.Burst)
+			writeLimiter.SetLimit(updated.RateLimit.Write.RPS, updated.RateLimit.Write.Burst)
+		}
+	})
+
+	// SIGHUP re-reads the config file (applying hot-reloadable keys) and TLS certificates.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	workers.Go(func() {
+		for {
+			select {
+			case <-workerCtx.Done():
+				return
+			case <-hup:
+			}
+			if err := watcher.Reload(); err != nil {
+				logger.Error("config_reload_failed", "error", err)
+			}
+			if certReloader != nil {
+				if err := certReloader.Reload(); err != nil {
+					logger.Error("tls_certificate_reload_failed", "error", err)
+				} else {
+					logger.Info("tls_certificate_reloaded", "cert_file", tlsCfg.CertFile)
+				}
+			}
+		}
+	})
+
+	logger.Info("server_listen", "addr", ln.Addr().String(), "pid", os.Getpid(), "tls", serveTLS)
+	startServer("server", func() error {
+		if serveTLS {
+			return httpServer.ServeTLS(ln, "", "")
+		}
+		return httpServer.Serve(ln)
+	})
+
+	if err := systemd.Notify("READY=1"); err != nil {
+		logger.Warn("systemd_notify_failed", "state", "READY=1", "error", err)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutdown signal received, closing server...")
+	case runErr = <-serverErrs:
+		logger.Error("listener_failed", "error", runErr)
+	}
+	if err := systemd.Notify("STOPPING=1"); err != nil {
+		logger.Warn("systemd_notify_failed", "state", "STOPPING=1", "error", err)
+	}
+
+	httpErr := stopHTTPServer(logger, healthHandler, httpServer, timeouts, runErr == nil)
+	if grpcServer != nil {
+		// Open WatchPets streams are ended rather than waited for.
+		shutdownPhase(logger, "grpc_server", func() error {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), timeouts.Shutdown)
+			defer cancel()
+			return grpcServer.Shutdown(shutdownCtx)
+		})
+	}
+	shutdownPhase(logger, "auxiliary_servers", func() error {
+		var errs []error
+		for _, srv := range []*http.Server{redirectServer, metricsServer, adminServer} {
+			if srv != nil {
+				errs = append(errs, shutdownWithTimeout(srv, timeouts.Shutdown))
+			}
+		}
+		return errors.Join(errs...)
+	})
+	shutdownPhase(logger, "background_workers", func() error {
+		stopWorkers()
+		workers.Wait()
+		return nil
+	})
+	shutdownPhase(logger, "telemetry", func() error {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeouts.Shutdown)
+		defer cancel()
+		return errors.Join(shutdownTelemetry(shutdownCtx), reporter.Flush(shutdownCtx))
+	})
+	shutdownPhase(logger, "database", func() error {
+		if readPool != nil {
+			readPool.Close()
+		}
+		pool.Close()
+		if redisClient != nil {
+			return redisClient.Close()
+		}
+		return nil
+	})
+
+	if httpErr != nil {
+		httpErr = fmt.Errorf("graceful shutdown failed: %w", httpErr)
+	}
+	return errors.Join(runErr, httpErr)
+}
+
+// stopHTTPServer runs the first shutdown phases: readiness off, the drain delay when drain
+// is set, and a graceful shutdown of srv that lets in-flight requests finish.
+func stopHTTPServer(logger *slog.Logger, healthHandler *health.Handler, srv *http.Server, timeouts config.ServerTimeoutsConfig, drain bool) error {
+	shutdownPhase(logger, "readiness", func() error {
+		healthHandler.SetShuttingDown()
+		return nil
+	})
+	if drain && timeouts.Drain > 0 {
+		// Give load balancers time to observe the failing readiness probe before the
+		// listener stops accepting connections.
+		shutdownPhase(logger, "drain", func() error {
+			time.Sleep(timeouts.Drain)
+			return nil
+		})
+	}
+	return shutdownPhase(logger, "http_server", func() error {
+		return shutdownWithTimeout(srv, timeouts.Shutdown)
+	})
+}
+
+// enableH2C makes srv accept HTTP/2 without TLS next to HTTP/1.1. ConfigureServer hooks
+// the HTTP/2 server into srv.Shutdown so h2c connections get a GOAWAY and finish in-flight
+// streams during graceful shutdown.
+func enableH2C(srv *http.Server, idleTimeout time.Duration) error {
+	h2s := &http2.Server{IdleTimeout: idleTimeout}
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return err
+	}
+	srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	return nil
+}
+
+// shutdownWithTimeout gracefully stops srv, giving it timeout to finish in-flight requests.
+func shutdownWithTimeout(srv *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// shutdownPhase runs one step of the shutdown sequence, logging its duration and outcome.
+func shutdownPhase(logger *slog.Logger, name string, fn func() error) error {
+	start := time.Now()
+	if err := fn(); err != nil {
+		logger.Warn("shutdown_phase_failed", "phase", name, "duration", time.Since(start), "error", err)
+		return err
+	}
+	logger.Info("shutdown_phase_complete", "phase", name, "duration", time.Since(start))
+	return nil
+}
+
+// NewPoolConfig builds the primary pool configuration shared by every command, applying
+// database.query_exec_mode and the query tracer on top of database.NewPoolConfig.
+func NewPoolConfig(cfg config.DatabaseConfig, logger *slog.Logger) (*pgxpool.Config, error) {
+	poolConfig, err := database.NewPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	execMode, err := database.ParseQueryExecMode(cfg.QueryExecMode)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QueryExecMode != "" {
+		poolConfig.ConnConfig.DefaultQueryExecMode = execMode
+	}
+	if cfg.Tracer.Enabled {
+		poolConfig.ConnConfig.Tracer = database.NewQueryTracer(cfg.Tracer.SlowThreshold, logger)
+	}
+	return poolConfig, nil
+}
+
+// loginProviders builds the enabled OAuth login providers. A non-nil mockIdP stands in
+// for Google.
+func loginProviders(ctx context.Context, cfg config.Config, mockIdP *mockauth.Server, clients *httpclient.Registry, logger *slog.Logger) ([]auth.Provider, error) {
+	var providers []auth.Provider
+	if cfg.GoogleOAuth.Enabled {
+		opts := []googleauth.Option{googleauth.WithHTTPClient(clients.Client("google"))}
+		if mockIdP != nil {
+			opts = append(opts, googleauth.WithStaticIssuer(mockIdP.Issuer(), mockIdP.Endpoint(), mockIdP.UserInfoURL(), mockIdP.KeySet()))
+		}
+		p, err := googleauth.NewProvider(ctx, cfg.GoogleOAuth, logger, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize google oauth provider: %w", err)
+		}
+		providers = append(providers, p)
+	}
+	if cfg.GitHubOAuth.Enabled {
+		p, err := githubauth.NewProvider(cfg.GitHubOAuth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize github oauth provider: %w", err)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
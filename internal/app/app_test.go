@@ -0,0 +1,365 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"demo/internal/config"
+	"demo/internal/database/databasetest"
+	"demo/internal/health"
+)
+
+// loadConfig loads yaml as the only configuration file, with no deployment overlay.
+func loadConfig(t *testing.T, yaml string) config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	t.Setenv(config.DeploymentEnv, "")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	return cfg
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	cfg := loadConfig(t, `
+pagination:
+  cursor_key: test-cursor-key-0123456789abcdef
+`)
+	cfg.Sessions.Keys = []string{"short"}
+	if _, err := New(cfg); err == nil {
+		t.Fatal("New with a short session key: got nil error")
+	}
+}
+
+// TestNewRefusesMockProvider checks that the mock identity provider is not started for a
+// configuration that was changed after loading to drop allow_mock.
+func TestNewRefusesMockProvider(t *testing.T) {
+	cfg := loadConfig(t, `
+pagination:
+  cursor_key: test-cursor-key-0123456789abcdef
+sessions:
+  keys: [test-session-key-0123456789abcdef]
+login:
+  token_encryption_key: test-token-key-0123456789abcdefgh
+google_oauth:
+  enabled: true
+  mode: mock
+  allow_mock: true
+  redirect_url: http://localhost:8080/auth/google/callback
+`)
+	cfg.GoogleOAuth.AllowMock = false
+	if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), "allow_mock") {
+		t.Fatalf("New in mock mode without allow_mock: got %v", err)
+	}
+}
+
+// TestRunWithoutDatabase checks that Run gives up, rather than serving, when the database
+// stays unreachable for the whole connect budget.
+func TestRunWithoutDatabase(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	cfg := loadConfig(t, `
+database:
+  dsn: postgres://petstore@`+addr+`/petstore?sslmode=disable&connect_timeout=1
+  connect_retry:
+    attempts: 2
+    interval: 10ms
+server:
+  address: 127.0.0.1:0
+pagination:
+  cursor_key: test-cursor-key-0123456789abcdef
+`)
+	a, err := New(cfg, WithLogger(slog.New(slog.DiscardHandler), nil))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = a.Run(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to database") {
+		t.Fatalf("Run without a database: got %v, want a connect failure", err)
+	}
+}
+
+// TestRunServesUntilCanceled starts the whole application on a unix socket, waits for it
+// to serve /healthz and /readyz, and cancels it: Run must shut down cleanly and remove the
+// socket. It runs the same App twice, as a process restarting it after a reload would, so
+// collectors registered by the first run must not stop the second. It needs PostgreSQL
+// through databasetest.
+func TestRunServesUntilCanceled(t *testing.T) {
+	dsn := databasetest.DSN(t)
+	socket := filepath.Join(t.TempDir(), "petstore.sock")
+	cfg := loadConfig(t, `
+database:
+  dsn: `+dsn+`
+  breaker:
+    enabled: true
+cache:
+  enabled: true
+metrics:
+  enabled: true
+server:
+  address: unix://`+socket+`
+  timeouts:
+    shutdown: 5s
+`)
+	a, err := New(cfg, WithLogger(slog.New(slog.DiscardHandler), nil))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	get := func(path string) (int, string, error) {
+		resp, err := client.Get("http://petstore" + path)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), err
+	}
+
+	for run := 1; run <= 2; run++ {
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error, 1)
+		go func() { done <- a.Run(ctx) }()
+
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			status, _, err := get("/healthz")
+			if err == nil && status == http.StatusOK {
+				break
+			}
+			select {
+			case err := <-done:
+				cancel()
+				t.Fatalf("run %d: Run returned before serving: %v", run, err)
+			default:
+			}
+			if time.Now().After(deadline) {
+				cancel()
+				t.Fatalf("run %d: GET /healthz: got %d, %v after 30s, want 200", run, status, err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if status, _, err := get("/readyz"); err != nil || status != http.StatusOK {
+			cancel()
+			t.Fatalf("run %d: GET /readyz: got %d, %v; want 200", run, status, err)
+		}
+		if status, body, err := get("/metrics"); err != nil || status != http.StatusOK || !strings.Contains(body, "petstore_db_pool_max_conns") {
+			cancel()
+			t.Fatalf("run %d: GET /metrics: got %d, %v; want 200 with petstore_db_pool_max_conns", run, status, err)
+		}
+
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("run %d: Run after cancel: %v", run, err)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatalf("run %d: Run did not return within 30s of cancel", run)
+		}
+		if _, err := os.Lstat(socket); !os.IsNotExist(err) {
+			t.Fatalf("run %d: socket after shutdown: got %v, want it removed", run, err)
+		}
+	}
+}
+
+// h2cClient speaks HTTP/2 with prior knowledge over cleartext connections.
+func h2cClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestEnableH2C(t *testing.T) {
+	router := newTestRouter(t, "")
+	srv := httptest.NewUnstartedServer(router.Config.Handler)
+	if err := enableH2C(srv.Config, time.Minute); err != nil {
+		t.Fatalf("enableH2C: %v", err)
+	}
+	srv.Start()
+	defer srv.Close()
+	tr := &testRouter{Server: srv, pets: router.pets}
+
+	h2 := h2cClient()
+	resp, body := tr.do(t, h2, http.MethodPost, "/pets", `{"id":1,"name":"Rex"}`, "Authorization", "Bearer "+testAPIToken)
+	if resp.StatusCode != http.StatusCreated || resp.ProtoMajor != 2 {
+		t.Fatalf("POST /pets over h2c: got %s %d %s, want HTTP/2.0 201", resp.Proto, resp.StatusCode, body)
+	}
+	resp, body = tr.do(t, h2, http.MethodGet, "/pets/1", "")
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Fatalf("GET /pets/1 over h2c: got %s %d %s, want HTTP/2.0 200", resp.Proto, resp.StatusCode, body)
+	}
+
+	// HTTP/1.1 clients share the listener.
+	resp, body = tr.do(t, nil, http.MethodGet, "/pets/1", "")
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Fatalf("GET /pets/1 over HTTP/1.1: got %s %d %s, want HTTP/1.1 200", resp.Proto, resp.StatusCode, body)
+	}
+}
+
+// TestEnableH2CShutdown checks that a graceful shutdown lets an h2c stream already in
+// flight finish.
+func TestEnableH2CShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	}))
+	if err := enableH2C(srv.Config, time.Minute); err != nil {
+		t.Fatalf("enableH2C: %v", err)
+	}
+	srv.Start()
+	defer srv.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := h2cClient().Get(srv.URL)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{string(body), err}
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- shutdownWithTimeout(srv.Config, 10*time.Second) }()
+	close(release)
+
+	if got := <-results; got.err != nil || got.body != "done" {
+		t.Fatalf("in-flight h2c request during shutdown: got %q, %v; want done", got.body, got.err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+// TestStopHTTPServerDrains shuts down a server with a request in flight: readiness fails
+// at once, new requests are still served during the drain delay, and once the listener
+// has closed the in-flight request still completes.
+func TestStopHTTPServerDrains(t *testing.T) {
+	healthHandler := health.NewHandler(0, slog.New(slog.DiscardHandler))
+	started, release := make(chan struct{}), make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /readyz", healthHandler.Readyz)
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	slow := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(srv.URL + "/slow")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+		slow <- err
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	timeouts := config.ServerTimeoutsConfig{Drain: 500 * time.Millisecond, Shutdown: 10 * time.Second}
+	go func() {
+		stopped <- stopHTTPServer(slog.New(slog.DiscardHandler), healthHandler, srv.Config, timeouts, true)
+	}()
+
+	deadline := time.Now().Add(timeouts.Drain / 2)
+	for {
+		resp, err := http.Get(srv.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("GET /readyz during the drain: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /readyz during the drain: got %d, want 503", resp.StatusCode)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Once the drain is over the listener closes, while the slow request holds up shutdown.
+	deadline = time.Now().Add(10 * time.Second)
+	for {
+		resp, err := http.Get(srv.URL + "/readyz")
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("listener still accepting 10s into shutdown")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case err := <-stopped:
+		t.Fatalf("stopHTTPServer returned before the in-flight request finished: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("stopHTTPServer: %v", err)
+	}
+}
+
+func TestStopHTTPServerSkipsDrain(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	timeouts := config.ServerTimeoutsConfig{Drain: time.Hour, Shutdown: 10 * time.Second}
+
+	started := time.Now()
+	if err := stopHTTPServer(slog.New(slog.DiscardHandler), health.NewHandler(0, slog.New(slog.DiscardHandler)), srv.Config, timeouts, false); err != nil {
+		t.Fatalf("stopHTTPServer: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("stopHTTPServer without drain: took %s, want no drain delay", elapsed)
+	}
+}
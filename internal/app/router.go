@@ -0,0 +1,310 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"demo/internal/admin"
+	"demo/internal/apidocs"
+	"demo/internal/auth"
+	mockauth "demo/internal/auth/mock"
+	"demo/internal/auth/session"
+	"demo/internal/auth/store"
+	"demo/internal/buildinfo"
+	"demo/internal/config"
+	"demo/internal/errreport"
+	"demo/internal/features"
+	"demo/internal/graphqlapi"
+	"demo/internal/health"
+	"demo/internal/httpmw"
+	"demo/internal/logging"
+	"demo/internal/maintenance"
+	"demo/internal/metrics"
+	"demo/internal/petstore"
+	"demo/internal/telemetry"
+	"demo/internal/tenant"
+)
+
+// routerDeps are the collaborators newRouter wires into the public routes. Run builds
+// them from the configuration and its connections; anything that only needs the
+// configuration is derived inside newRouter so tests exercise the same wiring.
+type routerDeps struct {
+	logger      *slog.Logger
+	reporter    errreport.Reporter
+	flags       *features.Flags
+	maintenance *maintenance.Mode
+	health      *health.Handler
+	pets        petstore.PetRepository
+	// exports is nil unless exports.enabled is set.
+	exports petstore.Exports
+	changes petstore.ChangeFeed
+	pager   petstore.Pager
+	deduper petstore.Deduper
+	// versions is nil unless cache.list_etags.enabled is set.
+	versions petstore.CollectionVersions
+	// purger backs POST /admin/pets/purge for signed-in admins and admin API tokens.
+	purger petstore.Purger
+	// photos is nil unless photos.enabled is set; photoOwners checks owners on photo writes.
+	photos      petstore.BlobStore
+	photoOwners petstore.PetOwnerLookup
+	// invalidatePet drops a pet from the caches in front of pets after a merge.
+	invalidatePet func(ctx context.Context, id int64)
+	// graphqlPets pages pets and looks up their owners for graphql.enabled.
+	graphqlPets interface {
+		graphqlapi.Pager
+		graphqlapi.OwnerLookup
+	}
+	// sessions is nil when sessions.keys is empty.
+	sessions *session.Manager
+	// users, sessionRevoker, and login are nil unless cfg.LoginEnabled(); sessionRevoker is
+	// also nil without sessions.store.
+	users          store.UserRepository
+	sessionRevoker admin.SessionRevoker
+	login          *auth.LoginHandler
+	// mockIdP, when set, is served under /auth/mock in place of Google.
+	mockIdP *mockauth.Server
+	// readLimiter and writeLimiter are set together when rate_limit is enabled; Run keeps
+	// them to apply reloaded limits.
+	readLimiter, writeLimiter *httpmw.RateLimiter
+	// slowRequests logs requests over logging.slow_request_threshold; Run keeps it to
+	// apply a reloaded threshold.
+	slowRequests *logging.SlowRequests
+	// metrics holds Run's own collectors, served with the default registry.
+	metrics        prometheus.Gatherer
+	configChecksum string
+}
+
+// newRouter builds the public HTTP handler: the global middleware chain, probes, API
+// docs, the auth and user administration routes, and the generated petstore routes with
+// their per-operation middlewares.
+func newRouter(cfg config.Config, deps routerDeps) (http.Handler, error) {
+	logger := deps.logger
+	trustedProxies, err := cfg.Server.TrustedProxyPrefixes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid server configuration: %w", err)
+	}
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(deps.flags.Middleware)
+	router.Use(httpmw.RealIP(trustedProxies))
+	router.Use(logging.RequestIDHeader)
+	router.Use(logging.RequestLogger(logger))
+	router.Use(logging.RequestFields)
+	if deps.slowRequests != nil {
+		router.Use(deps.slowRequests.Middleware)
+	}
+	router.Use(httpmw.Recover(logger, errreport.PanicHook(deps.reporter)))
+	if cfg.Metrics.Enabled {
+		router.Use(metrics.Middleware(cfg.Metrics.Path))
+	}
+	if cfg.Telemetry.Enabled {
+		router.Use(telemetry.Middleware(cfg.Telemetry.ServiceName))
+	}
+	if c := cfg.Server.CORS; c.Enabled {
+		router.Use(httpmw.CORS(httpmw.CORSOptions{
+			AllowedOrigins:   c.AllowedOrigins,
+			AllowedMethods:   c.AllowedMethods,
+			AllowedHeaders:   c.AllowedHeaders,
+			ExposedHeaders:   c.ExposedHeaders,
+			AllowCredentials: c.AllowCredentials,
+			MaxAge:           c.MaxAge,
+		}))
+	}
+	router.Use(deps.maintenance.Middleware("/healthz", "/readyz", cfg.Metrics.Path))
+	if c := cfg.Server.Compression; c.Enabled {
+		router.Use(httpmw.Compress(int(c.MinSize), c.ContentTypes, c.Encodings))
+	}
+	if deps.sessions != nil {
+		router.Use(deps.sessions.Middleware)
+	}
+	router.Use(httpmw.Head)
+	router.MethodNotAllowed(httpmw.MethodNotAllowed)
+
+	router.Get("/healthz", deps.health.Healthz)
+	router.Get("/readyz", deps.health.Readyz)
+	router.Get("/version", buildinfo.Handler(deps.configChecksum))
+
+	spec, err := petstore.GetSwagger()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded openapi spec: %w", err)
+	}
+	docsHandler, err := apidocs.NewHandler(spec, cfg.Server.ExternalURL+cfg.Server.BasePath, logger)
+	if err != nil {
+		return nil, err
+	}
+	router.Get(cfg.Server.BasePath+"/openapi.json", docsHandler.JSON)
+	router.Get(cfg.Server.BasePath+"/openapi.yaml", docsHandler.YAML)
+	if cfg.Docs.Enabled {
+		router.Get(cfg.Server.BasePath+"/docs", docsHandler.Docs)
+	}
+	if cfg.Metrics.Enabled && cfg.Metrics.Address == "" {
+		router.Method(http.MethodGet, cfg.Metrics.Path, metrics.Handler(deps.metrics))
+	}
+
+	basePath := cfg.Server.BasePath
+	requestTimeout := httpmw.Timeout(cfg.Server.RequestTimeout, cfg.Server.RouteTimeouts)
+	var rateLimit func(http.Handler) http.Handler
+	if deps.readLimiter != nil {
+		rateLimit = httpmw.ByMethod(deps.readLimiter, deps.writeLimiter)
+	}
+	var tokens auth.TokenVerifier
+	if len(cfg.Security.APITokens) > 0 {
+		tokens = auth.NewStaticTokens(cfg.Security.APITokens)
+	}
+	authenticator := auth.NewAuthenticator(deps.sessions, tokens, logger)
+	// deps.users is nil without login, leaving every signed-in caller in the default
+	// organization unless their API token names one.
+	orgs := tenant.NewResolver(deps.users, logger)
+	// Generated handlers apply middlewares last-to-first, so the rate limiter runs before the
+	// timeout and rejected requests never start the clock. Authentication runs before
+	// validation; GETs stay public and writes are only rejected when
+	// security.require_auth_for_writes is set. The caller's organization is resolved just
+	// inside authentication, so everything after it reads a scoped context.
+	routeBodyLimits := make(map[string]int64, len(cfg.Server.RouteBodyLimits))
+	for route, limit := range cfg.Server.RouteBodyLimits {
+		routeBodyLimits[route] = int64(limit)
+	}
+	if deps.photos != nil {
+		// Photo uploads are capped by photos.max_bytes unless the route has its own limit.
+		photoRoute := "PUT " + basePath + "/pets/{petId}/photo"
+		if _, ok := routeBodyLimits[photoRoute]; !ok {
+			routeBodyLimits[photoRoute] = int64(cfg.Photos.MaxBytes)
+		}
+	}
+	bodyLimit := httpmw.BodyLimit(int64(cfg.Server.MaxBodyBytes), routeBodyLimits)
+	apiMiddlewares := []petstore.MiddlewareFunc{
+		orgs.Middleware, authenticator.Middleware(cfg.Security.RequireAuthForWrites), bodyLimit, requestTimeout,
+	}
+	if cfg.Security.EnforceRoles {
+		// Role checks run inside authentication, which attaches the user they read.
+		roles := auth.RequireRoleByMethod(auth.Role(cfg.Security.ReadRole))
+		apiMiddlewares = append([]petstore.MiddlewareFunc{roles}, apiMiddlewares...)
+	}
+	if cfg.Server.ValidateRequests {
+		validator := petstore.NewRequestValidator(spec, petstore.ValidatorOptions{BasePath: basePath})
+		apiMiddlewares = append([]petstore.MiddlewareFunc{validator}, apiMiddlewares...)
+	}
+	if rateLimit != nil {
+		apiMiddlewares = append(apiMiddlewares, rateLimit)
+	}
+	serverOpts := []petstore.ServerOption{
+		petstore.WithBasePath(basePath),
+		petstore.WithErrorReporter(deps.reporter),
+		petstore.WithCursorKey([]byte(cfg.Pagination.CursorKey), cfg.Pagination.CursorTTL),
+	}
+	if cfg.Security.EnforceRoles {
+		serverOpts = append(serverOpts, petstore.WithOwnerChecks())
+	}
+	if deps.changes != nil {
+		serverOpts = append(serverOpts, petstore.WithChangeFeed(deps.changes))
+	}
+	if deps.pager != nil {
+		serverOpts = append(serverOpts, petstore.WithPager(deps.pager))
+	}
+	if deps.versions != nil {
+		serverOpts = append(serverOpts, petstore.WithListETags(deps.versions))
+	}
+	if deps.deduper != nil {
+		serverOpts = append(serverOpts, petstore.WithDeduper(deps.deduper, deps.invalidatePet))
+	}
+	if deps.exports != nil {
+		serverOpts = append(serverOpts, petstore.WithExports(deps.exports))
+	}
+	if deps.photos != nil {
+		serverOpts = append(serverOpts, petstore.WithPhotos(deps.photos, deps.photoOwners, petstore.PhotoOptions{
+			MaxBytes:    int64(cfg.Photos.MaxBytes),
+			CacheMaxAge: cfg.Photos.CacheMaxAge,
+			Redirect:    cfg.Photos.Redirect,
+		}))
+	}
+	serverImpl := petstore.NewServer(deps.pets, logger, serverOpts...)
+
+	if deps.mockIdP != nil {
+		router.Mount(basePath+"/auth/mock", deps.mockIdP.Handler())
+	}
+	if loginHandler := deps.login; loginHandler != nil {
+		var loginLimit func(http.Handler) http.Handler
+		if arl := cfg.Security.AuthRateLimit; arl.Enabled {
+			loginLimit = httpmw.NewRateLimiter("auth", arl.RPS, arl.Burst, cfg.RateLimit.IdleTTL, httpmw.ClientIP).Middleware
+		}
+		router.Group(func(r chi.Router) {
+			if rateLimit != nil {
+				r.Use(rateLimit)
+			}
+			r.Use(requestTimeout, bodyLimit)
+			r.Group(func(r chi.Router) {
+				// Login and callback start upstream OAuth requests, so they get a tighter
+				// per-IP budget than the rest of the API.
+				if loginLimit != nil {
+					r.Use(loginLimit)
+				}
+				r.Get(basePath+"/auth/{provider}/login", loginHandler.Login)
+				r.Get(basePath+"/auth/{provider}/callback", loginHandler.Callback)
+			})
+			r.Get(basePath+"/auth/csrf", loginHandler.CSRFToken)
+			r.Get(basePath+"/auth/sessions", loginHandler.ListSessions)
+			r.Delete(basePath+"/auth/sessions", loginHandler.RevokeOtherSessions)
+			r.Delete(basePath+"/auth/sessions/{id}", loginHandler.RevokeSession)
+			r.Post(basePath+"/auth/logout", loginHandler.Logout)
+		})
+		// The admin listener's user administration, also offered to signed-in admins.
+		usersAdmin := admin.NewUsersHandler(basePath, deps.users, deps.sessionRevoker, logger)
+		router.Group(func(r chi.Router) {
+			if rateLimit != nil {
+				r.Use(rateLimit)
+			}
+			r.Use(requestTimeout, bodyLimit, authenticator.Middleware(true), auth.RequireRole(auth.RoleAdmin))
+			r.Handle(basePath+"/admin/users", usersAdmin)
+			r.Handle(basePath+"/admin/users/*", usersAdmin)
+		})
+	}
+	if deps.purger != nil {
+		purgeAdmin := admin.NewPurgeHandler(basePath, deps.purger, cfg.Purge.BatchSize, logger)
+		router.Group(func(r chi.Router) {
+			if rateLimit != nil {
+				r.Use(rateLimit)
+			}
+			r.Use(requestTimeout, bodyLimit, authenticator.Middleware(true), auth.RequireRole(auth.RoleAdmin))
+			r.Handle(basePath+"/admin/pets/purge", purgeAdmin)
+		})
+	}
+	if cfg.GraphQL.Enabled {
+		graphqlHandler, err := graphqlapi.New(graphqlapi.Options{
+			Pets:                 deps.pets,
+			Pager:                deps.graphqlPets,
+			Owners:               deps.graphqlPets,
+			Users:                deps.users,
+			Introspection:        cfg.GraphQL.Introspection,
+			EnforceRoles:         cfg.Security.EnforceRoles,
+			ReadRole:             auth.Role(cfg.Security.ReadRole),
+			RequireAuthForWrites: cfg.Security.RequireAuthForWrites,
+			Logger:               logger,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("graphql: %w", err)
+		}
+		router.Group(func(r chi.Router) {
+			if rateLimit != nil {
+				r.Use(rateLimit)
+			}
+			// Queries and mutations are all POSTs, so authentication lets every request
+			// through and the resolvers apply the role and write rules per operation.
+			r.Use(requestTimeout, bodyLimit, authenticator.Middleware(false), orgs.Middleware)
+			r.Post(basePath+"/graphql", graphqlHandler.ServeHTTP)
+		})
+	}
+
+	return petstore.HandlerWithOptions(serverImpl, petstore.ChiServerOptions{
+		BaseURL:          basePath,
+		BaseRouter:       router,
+		Middlewares:      apiMiddlewares,
+		ErrorHandlerFunc: petstore.ParamErrorHandler,
+	}), nil
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"demo/internal/apierr"
+	"demo/internal/auth/session"
+	appconfig "demo/internal/config"
+	"demo/internal/httpmw"
+	"demo/internal/logging"
+)
+
+// ErrInvalidToken is returned by a TokenVerifier that does not recognise the token.
+var ErrInvalidToken = errors.New("invalid token")
+
+// User is the authenticated caller of a request.
+type User struct {
+	ID    string
+	Email string
+	Name  string
+	// Method is "session" or "token", depending on how the user authenticated.
+	Method string
+	Role   Role
+	// Org is the organization an API token is bound to. Session users leave it empty;
+	// their organizations are their memberships.
+	Org string
+
+	// csrf is the session's CSRF token; token-authenticated users have none.
+	csrf string
+}
+
+// CSRFHeader carries the session's CSRF token on cookie-authenticated writes.
+const CSRFHeader = "X-CSRF-Token"
+
+// TokenVerifier resolves a bearer token into a User.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (User, error)
+}
+
+type contextKey struct{}
+
+// UserFromContext returns the user attached by Authenticator.Middleware.
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(contextKey{}).(User)
+	return u, ok
+}
+
+// ContextWithUser attaches user to ctx as Middleware does, for transports other than HTTP
+// that authenticate the caller themselves.
+func ContextWithUser(ctx context.Context, user User) context.Context {
+	return logging.With(context.WithValue(ctx, contextKey{}, user), "user_id", user.ID)
+}
+
+// Authenticator resolves the caller from a bearer token or the session cookie.
+type Authenticator struct {
+	sessions *session.Manager
+	tokens   TokenVerifier
+	logger   *slog.Logger
+}
+
+// NewAuthenticator builds an Authenticator. Either source may be nil, in which case
+// credentials of that kind are never accepted.
+func NewAuthenticator(sessions *session.Manager, tokens TokenVerifier, logger *slog.Logger) *Authenticator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Authenticator{sessions: sessions, tokens: tokens, logger: logger}
+}
+
+// Middleware attaches the caller, when known, to the request context. With
+// requireForWrites set, requests other than GET, HEAD, and OPTIONS without valid
+// credentials are answered with 401 and the standard Error JSON. Writes authenticated by
+// the session cookie must also send the session's CSRF token in X-CSRF-Token, or get 403;
+// bearer tokens are not sent by browsers on their own, so token clients are exempt.
+func (a *Authenticator) Middleware(requireForWrites bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := a.authenticate(r)
+			if err == nil {
+				if user.Method == "session" && !isSafeMethod(r.Method) {
+					if reason := csrfRejection(r, user.csrf); reason != "" {
+						a.logger.InfoContext(r.Context(), "csrf_rejected", "method", r.Method, "path", r.URL.Path, "user_id", user.ID, "reason", reason)
+						httpmw.WriteError(w, r, apierr.ErrForbidden, reason)
+						return
+					}
+				}
+				next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), user)))
+				return
+			}
+			if !requireForWrites || isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			message := "authentication required"
+			challenge := `Bearer realm="petstore"`
+			switch {
+			case errors.Is(err, session.ErrExpired):
+				message = "session expired"
+				challenge += `, error="invalid_token", error_description="session expired"`
+			case errors.Is(err, session.ErrRevoked):
+				message = "session revoked"
+				challenge += `, error="invalid_token", error_description="session revoked"`
+			case errors.Is(err, ErrInvalidToken), errors.Is(err, session.ErrInvalid):
+				message = "invalid credentials"
+				challenge += `, error="invalid_token"`
+			}
+			a.logger.InfoContext(r.Context(), "auth_rejected", "method", r.Method, "path", r.URL.Path, "reason", err.Error())
+			w.Header().Set("WWW-Authenticate", challenge)
+			httpmw.WriteError(w, r, apierr.ErrUnauthenticated, message)
+		})
+	}
+}
+
+// errNoCredentials means the request carries neither a bearer token nor a session.
+var errNoCredentials = errors.New("no credentials")
+
+// authenticate prefers an Authorization header over the session cookie, so API clients
+// are never affected by a stray browser cookie.
+func (a *Authenticator) authenticate(r *http.Request) (User, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" || a.tokens == nil {
+			return User{}, ErrInvalidToken
+		}
+		user, err := a.tokens.VerifyToken(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			return User{}, err
+		}
+		user.Method = "token"
+		return user, nil
+	}
+
+	if a.sessions == nil {
+		return User{}, errNoCredentials
+	}
+	s, ok := session.FromContext(r.Context())
+	if !ok {
+		var err error
+		if s, err = a.sessions.Read(r); err != nil {
+			if errors.Is(err, session.ErrNoSession) {
+				return User{}, errNoCredentials
+			}
+			return User{}, err
+		}
+	}
+	// Sessions from before roles existed carry none and count as viewers.
+	role := Role(s.Role)
+	if role == "" {
+		role = RoleViewer
+	}
+	return User{ID: s.UserID, Email: s.Email, Name: s.Name, Method: "session", Role: role, csrf: s.CSRF}, nil
+}
+
+// csrfRejection returns why the request's CSRF token does not match the session's, or ""
+// when it does. Sessions issued before CSRF tokens existed have none and never match.
+func csrfRejection(r *http.Request, expected string) string {
+	token := r.Header.Get(CSRFHeader)
+	switch {
+	case token == "":
+		return "missing CSRF token"
+	case expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1:
+		return "invalid CSRF token"
+	}
+	return ""
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// StaticTokens verifies bearer tokens against the security.api_tokens config list.
+type StaticTokens struct {
+	tokens []staticToken
+}
+
+type staticToken struct {
+	name string
+	role Role
+	org  string
+	hash [sha256.Size]byte
+}
+
+// NewStaticTokens builds a verifier for tokens; each token authenticates as the user
+// "token:<name>" with its configured role, or viewer when none is set, in its configured
+// organization.
+func NewStaticTokens(tokens []appconfig.APITokenConfig) *StaticTokens {
+	s := &StaticTokens{tokens: make([]staticToken, 0, len(tokens))}
+	for _, t := range tokens {
+		role := Role(t.Role)
+		if role == "" {
+			role = RoleViewer
+		}
+		s.tokens = append(s.tokens, staticToken{name: t.Name, role: role, org: t.Org, hash: sha256.Sum256([]byte(t.Token))})
+	}
+	return s
+}
+
+// VerifyToken compares hashes in constant time so timing does not leak token prefixes.
+func (s *StaticTokens) VerifyToken(_ context.Context, token string) (User, error) {
+	hash := sha256.Sum256([]byte(token))
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.hash[:]) == 1 {
+			return User{ID: "token:" + t.name, Name: t.name, Role: t.role, Org: t.org}, nil
+		}
+	}
+	return User{}, ErrInvalidToken
+}
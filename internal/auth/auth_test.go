@@ -0,0 +1,175 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"demo/internal/auth"
+	"demo/internal/auth/session"
+	appconfig "demo/internal/config"
+)
+
+const testToken = "test-token-0123456789"
+
+// authFixture is an Authenticator over a cookie session manager and one editor API
+// token, guarding a handler that answers 204.
+type authFixture struct {
+	sessions *session.Manager
+	handler  http.Handler
+}
+
+// sessionsConfig issues "session" cookies for an hour.
+var sessionsConfig = appconfig.SessionsConfig{
+	CookieName: "session",
+	Lifetime:   time.Hour,
+	Keys:       []string{"test-session-key-0123456789abcdef"},
+	Path:       "/",
+}
+
+// newSessions returns a cookie session manager for sessionsConfig.
+func newSessions(t *testing.T, opts ...session.Option) *session.Manager {
+	t.Helper()
+	sessions, err := session.NewManager(sessionsConfig, slog.New(slog.DiscardHandler), opts...)
+	if err != nil {
+		t.Fatalf("session.NewManager: %v", err)
+	}
+	return sessions
+}
+
+func newAuthFixture(t *testing.T, opts ...session.Option) *authFixture {
+	t.Helper()
+	logger := slog.New(slog.DiscardHandler)
+	sessions := newSessions(t, opts...)
+	tokens := auth.NewStaticTokens([]appconfig.APITokenConfig{{Name: "test", Token: testToken, Role: "editor"}})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	return &authFixture{
+		sessions: sessions,
+		handler:  sessions.Middleware(auth.NewAuthenticator(sessions, tokens, logger).Middleware(true)(ok)),
+	}
+}
+
+// issue signs in user and returns the session cookie and its CSRF token.
+func (f *authFixture) issue(t *testing.T, user string) (*http.Cookie, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := f.sessions.Issue(rec, httptest.NewRequest(http.MethodGet, "/", nil), session.Session{UserID: user}); err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	cookie := rec.Result().Cookies()[0]
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	s, err := f.sessions.Read(req)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return cookie, s.CSRF
+}
+
+// seal builds a session cookie for s as is, e.g. without the CSRF token Issue adds.
+func (f *authFixture) seal(t *testing.T, s session.Session) *http.Cookie {
+	t.Helper()
+	payload, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("encoding session: %v", err)
+	}
+	value, err := f.sessions.Seal("session", payload)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	return &http.Cookie{Name: "session", Value: value}
+}
+
+// post sends a POST with the cookie, when not nil, and header name-value pairs.
+func (f *authFixture) post(t *testing.T, cookie *http.Cookie, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/pets", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	f.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthenticatorCSRF(t *testing.T) {
+	f := newAuthFixture(t)
+	cookie, csrf := f.issue(t, "1")
+	_, otherCSRF := f.issue(t, "2")
+	legacy := f.seal(t, session.Session{UserID: "3", ExpiresAt: time.Now().Add(time.Hour)})
+
+	for _, tc := range []struct {
+		name   string
+		cookie *http.Cookie
+		header []string
+		want   int
+	}{
+		{"OwnToken", cookie, []string{auth.CSRFHeader, csrf}, http.StatusNoContent},
+		{"MissingToken", cookie, nil, http.StatusForbidden},
+		{"OtherSessionsToken", cookie, []string{auth.CSRFHeader, otherCSRF}, http.StatusForbidden},
+		{"SessionWithoutToken", legacy, []string{auth.CSRFHeader, csrf}, http.StatusForbidden},
+		{"SessionWithoutTokenEmptyHeader", legacy, []string{auth.CSRFHeader, ""}, http.StatusForbidden},
+		{"BearerToken", nil, []string{"Authorization", "Bearer " + testToken}, http.StatusNoContent},
+		{"BearerTokenBesideCookie", cookie, []string{"Authorization", "Bearer " + testToken}, http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := f.post(t, tc.cookie, tc.header...); rec.Code != tc.want {
+				t.Fatalf("POST /pets: got %d %s, want %d", rec.Code, rec.Body, tc.want)
+			}
+		})
+	}
+}
+
+// TestAuthenticatorRejections checks that writes with credentials that were once good, or
+// never were, get 401 with a message and an RFC 6750 invalid_token challenge saying why.
+func TestAuthenticatorRejections(t *testing.T) {
+	f := newAuthFixture(t, session.WithStore(session.NewMemoryStore(t.Context()), 0))
+	revoked, revokedCSRF := f.issue(t, "1")
+	if _, err := f.sessions.RevokeAll(t.Context(), "1"); err != nil {
+		t.Fatalf("RevokeAll: %v", err)
+	}
+	cookieOnly := newAuthFixture(t)
+	expired := cookieOnly.seal(t, session.Session{UserID: "2", CSRF: "csrf-token", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	for _, tc := range []struct {
+		name      string
+		fixture   *authFixture
+		cookie    *http.Cookie
+		header    []string
+		message   string
+		challenge string
+	}{
+		{"ExpiredSession", cookieOnly, expired, []string{auth.CSRFHeader, "csrf-token"}, "session expired",
+			`Bearer realm="petstore", error="invalid_token", error_description="session expired"`},
+		{"RevokedSession", f, revoked, []string{auth.CSRFHeader, revokedCSRF}, "session revoked",
+			`Bearer realm="petstore", error="invalid_token", error_description="session revoked"`},
+		{"InvalidBearer", f, nil, []string{"Authorization", "Bearer not-a-token"}, "invalid credentials",
+			`Bearer realm="petstore", error="invalid_token"`},
+		{"NotBearer", f, nil, []string{"Authorization", "Basic dXNlcjpwYXNz"}, "invalid credentials",
+			`Bearer realm="petstore", error="invalid_token"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := tc.fixture.post(t, tc.cookie, tc.header...)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("POST /pets: got %d %s, want 401", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tc.challenge {
+				t.Fatalf("WWW-Authenticate: got %q, want %q", got, tc.challenge)
+			}
+			var body struct {
+				Category string `json:"category"`
+				Code     int    `json:"code"`
+				Message  string `json:"message"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Category != "UNAUTHENTICATED" || body.Code != http.StatusUnauthorized || body.Message != tc.message {
+				t.Fatalf("body: got %s (%v), want UNAUTHENTICATED %q", rec.Body, err, tc.message)
+			}
+		})
+	}
+}
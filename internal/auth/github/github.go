@@ -0,0 +1,156 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+
+	"demo/internal/auth"
+	appconfig "demo/internal/config"
+)
+
+const defaultAPIBaseURL = "https://api.github.com"
+
+// Provider signs users in with GitHub. GitHub is not an OpenID provider, so the identity
+// comes from the REST API using the access token.
+type Provider struct {
+	oauthConfig *oauth2.Config
+	apiBaseURL  string
+}
+
+var _ auth.Provider = (*Provider)(nil)
+
+// user is the subset of GET /user the provider uses.
+type user struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// email is one entry of GET /user/emails.
+type email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// NewProvider constructs the GitHub provider using application configuration.
+func NewProvider(cfg appconfig.GitHubOAuthConfig) (*Provider, error) {
+	if !cfg.Enabled {
+		return nil, errors.New("github oauth is disabled")
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("github oauth client id is required")
+	}
+	if cfg.ClientSecret == "" {
+		return nil, errors.New("github oauth client secret is required")
+	}
+	redirectURL := strings.TrimSpace(cfg.RedirectURL)
+	if redirectURL == "" {
+		return nil, errors.New("github oauth redirect url is required")
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+
+	return &Provider{
+		oauthConfig: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       append([]string(nil), scopes...),
+			Endpoint:     github.Endpoint,
+		},
+		apiBaseURL: defaultAPIBaseURL,
+	}, nil
+}
+
+// Name implements auth.Provider.
+func (p *Provider) Name() string { return "github" }
+
+// AuthCodeURL implements auth.Provider; GitHub has no nonce parameter and takes the login
+// hint as its login parameter.
+func (p *Provider) AuthCodeURL(state, _, verifier, loginHint string) string {
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+	if loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login", loginHint))
+	}
+	return p.oauthConfig.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements auth.Provider.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	return p.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+}
+
+// FetchIdentity reads the profile and the primary verified email address; accounts without
+// one are refused, since the email is what we recognise users by.
+func (p *Provider) FetchIdentity(ctx context.Context, token *oauth2.Token, _ string) (auth.Identity, error) {
+	client := p.oauthConfig.Client(ctx, token)
+
+	var u user
+	if err := p.get(ctx, client, "/user", &u); err != nil {
+		return auth.Identity{}, err
+	}
+	if u.ID == 0 {
+		return auth.Identity{}, errors.New("github user response has no id")
+	}
+
+	var emails []email
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return auth.Identity{}, err
+	}
+	primary := ""
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			primary = e.Email
+			break
+		}
+	}
+	if primary == "" {
+		return auth.Identity{}, fmt.Errorf("%w: no primary verified email", auth.ErrAccountNotAllowed)
+	}
+
+	name := u.Name
+	if name == "" {
+		name = u.Login
+	}
+	return auth.Identity{
+		Provider:  p.Name(),
+		Subject:   strconv.FormatInt(u.ID, 10),
+		Email:     primary,
+		Name:      name,
+		AvatarURL: u.AvatarURL,
+	}, nil
+}
+
+func (p *Provider) get(ctx context.Context, client *http.Client, path string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiBaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github %s returned status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("decode github %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,544 @@
+package google
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"demo/internal/auth"
+	appconfig "demo/internal/config"
+)
+
+const (
+	defaultUserInfoEndpoint = "https://www.googleapis.com/oauth2/v3/userinfo"
+	googleIssuer            = "https://accounts.google.com"
+	googleJWKSURL           = "https://www.googleapis.com/oauth2/v3/certs"
+	defaultRevokeEndpoint   = "https://oauth2.googleapis.com/revoke"
+	discoveryTimeout        = 10 * time.Second
+	// Failed discovery is retried on first use after minDiscoveryRetry, doubling up to
+	// maxDiscoveryRetry while the issuer stays unreachable.
+	minDiscoveryRetry = time.Second
+	maxDiscoveryRetry = 5 * time.Minute
+)
+
+// Provider signs users in with Google, deriving the identity from the verified ID token.
+type Provider struct {
+	logger           *slog.Logger
+	userInfoFallback bool
+	httpClient       *http.Client
+	allowedDomains   map[string]bool
+	allowedEmails    map[string]bool
+	prompt           string
+	accessType       string
+	static           *staticIssuer
+
+	// endpoints is nil until discovery against issuerURL succeeds.
+	endpoints  atomic.Pointer[endpoints]
+	issuerURL  string
+	oidcConfig *oidc.Config
+	// base is the OAuth configuration discovery fills the endpoint into.
+	base oauth2.Config
+
+	discoveryMu       sync.Mutex
+	discoveryErr      error
+	discoveryRetryAt  time.Time
+	discoveryDelay    time.Duration
+	discoveryInFlight atomic.Bool
+}
+
+// endpoints are the issuer-specific parts of the provider, fixed at construction for
+// Google and the static issuer and resolved by discovery for google_oauth.issuer_url.
+type endpoints struct {
+	oauthConfig      *oauth2.Config
+	verifier         *oidc.IDTokenVerifier
+	userInfoEndpoint string
+	revokeEndpoint   string
+}
+
+// Option configures optional Provider behaviour.
+type Option func(*Provider)
+
+// staticIssuer is a fixed set of endpoints that replaces Google's.
+type staticIssuer struct {
+	issuer      string
+	endpoint    oauth2.Endpoint
+	userInfoURL string
+	keys        oidc.KeySet
+}
+
+// WithStaticIssuer points the provider at issuer's endpoints and verifies ID tokens with
+// keys instead of Google's, as the built-in mock provider needs. Revocation is disabled.
+func WithStaticIssuer(issuer string, endpoint oauth2.Endpoint, userInfoURL string, keys oidc.KeySet) Option {
+	return func(p *Provider) {
+		p.static = &staticIssuer{issuer: issuer, endpoint: endpoint, userInfoURL: userInfoURL, keys: keys}
+	}
+}
+
+// WithHTTPClient sends every request to the issuer, including userinfo and revocation,
+// through client instead of http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Provider) {
+		p.httpClient = client
+	}
+}
+
+// GoogleUser is the identity Google asserts in the ID token or userinfo response.
+type GoogleUser struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	// HostedDomain is the Google Workspace domain of the account; consumer accounts have none.
+	HostedDomain string `json:"hd"`
+	Locale       string `json:"locale"`
+}
+
+// UnmarshalJSON accepts email_verified as a boolean or as the strings "true" and "false",
+// which some Google endpoints send.
+func (u *GoogleUser) UnmarshalJSON(data []byte) error {
+	type plain GoogleUser
+	var aux struct {
+		plain
+		EmailVerified json.RawMessage `json:"email_verified"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*u = GoogleUser(aux.plain)
+	switch v := strings.Trim(string(aux.EmailVerified), `"`); v {
+	case "", "null", "false":
+		u.EmailVerified = false
+	case "true":
+		u.EmailVerified = true
+	default:
+		return fmt.Errorf("email_verified: unexpected value %s", aux.EmailVerified)
+	}
+	return nil
+}
+
+// knownClaims are fields decodeUser expects besides GoogleUser's own: registered JWT
+// claims and Google's token metadata.
+var knownClaims = map[string]bool{
+	"sub": true, "email": true, "email_verified": true, "name": true, "given_name": true,
+	"family_name": true, "picture": true, "hd": true, "locale": true,
+	"iss": true, "aud": true, "azp": true, "exp": true, "iat": true, "nbf": true,
+	"nonce": true, "at_hash": true, "c_hash": true, "jti": true, "auth_time": true,
+}
+
+// loggedClaims remembers unknown fields already logged so each is reported once.
+var loggedClaims sync.Map
+
+// decodeUser strictly decodes a GoogleUser: sub and email must be present. Unknown fields
+// are ignored, and logged at debug level the first time each is seen.
+func (p *Provider) decodeUser(ctx context.Context, data []byte) (GoogleUser, error) {
+	var user GoogleUser
+	if err := json.Unmarshal(data, &user); err != nil {
+		return GoogleUser{}, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil {
+		for name := range fields {
+			if _, seen := loggedClaims.LoadOrStore(name, true); !knownClaims[name] && !seen {
+				p.logger.DebugContext(ctx, "google_oauth_unknown_claim", "claim", name)
+			}
+		}
+	}
+	switch {
+	case user.Subject == "":
+		return GoogleUser{}, errors.New("google identity has no sub")
+	case user.Email == "":
+		return GoogleUser{}, errors.New("google identity has no email")
+	}
+	return user, nil
+}
+
+var (
+	_ auth.Provider = (*Provider)(nil)
+	_ auth.Preparer = (*Provider)(nil)
+)
+
+// NewProvider constructs the Google provider using application configuration. With
+// google_oauth.issuer_url set, endpoints come from the issuer's discovery document,
+// fetched on first use so an unreachable issuer does not stop the API from starting;
+// google_oauth.required fetches it here instead and fails when it cannot.
+func NewProvider(ctx context.Context, cfg appconfig.GoogleOAuthConfig, logger *slog.Logger, opts ...Option) (*Provider, error) {
+	if !cfg.Enabled {
+		return nil, errors.New("google oauth is disabled")
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("google oauth client id is required")
+	}
+	if cfg.ClientSecret == "" {
+		return nil, errors.New("google oauth client secret is required")
+	}
+	redirectURL := strings.TrimSpace(cfg.RedirectURL)
+	if redirectURL == "" {
+		return nil, errors.New("google oauth redirect url is required")
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p := &Provider{
+		logger:           logger,
+		userInfoFallback: cfg.UserInfoFallback,
+		httpClient:       http.DefaultClient,
+		allowedDomains:   lowerSet(cfg.AllowedDomains),
+		allowedEmails:    lowerSet(cfg.AllowedEmails),
+		prompt:           cfg.Prompt,
+		accessType:       cfg.AccessType,
+		issuerURL:        cfg.IssuerURL,
+		oidcConfig:       &oidc.Config{ClientID: cfg.ClientID},
+		base: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       append([]string(nil), scopes...),
+			Endpoint:     google.Endpoint,
+		},
+		discoveryDelay: minDiscoveryRetry,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if st := p.static; st != nil {
+		oauthConfig := p.base
+		oauthConfig.Endpoint = st.endpoint
+		p.endpoints.Store(&endpoints{
+			oauthConfig:      &oauthConfig,
+			verifier:         oidc.NewVerifier(st.issuer, st.keys, p.oidcConfig),
+			userInfoEndpoint: st.userInfoURL,
+		})
+		return p, nil
+	}
+	if cfg.IssuerURL == "" {
+		// The remote key set fetches Google's signing keys lazily and caches them until a
+		// token with an unknown key ID forces a refresh.
+		keySet := oidc.NewRemoteKeySet(oidc.ClientContext(context.Background(), p.httpClient), googleJWKSURL)
+		oauthConfig := p.base
+		p.endpoints.Store(&endpoints{
+			oauthConfig:      &oauthConfig,
+			verifier:         oidc.NewVerifier(googleIssuer, keySet, p.oidcConfig),
+			userInfoEndpoint: defaultUserInfoEndpoint,
+			revokeEndpoint:   defaultRevokeEndpoint,
+		})
+		return p, nil
+	}
+	if cfg.Required {
+		if err := p.discover(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// discover fetches the issuer's discovery document and publishes its endpoints.
+func (p *Provider) discover(ctx context.Context) error {
+	discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	discoveryCtx = oidc.ClientContext(discoveryCtx, p.httpClient)
+	// oidc.NewProvider rejects documents whose issuer differs from IssuerURL; its key set
+	// caches keys the same way as Google's.
+	discovered, err := oidc.NewProvider(discoveryCtx, p.issuerURL)
+	if err != nil {
+		return fmt.Errorf("google oauth discovery for %s: %w", p.issuerURL, err)
+	}
+	var claims struct {
+		UserInfo   string `json:"userinfo_endpoint"`
+		Revocation string `json:"revocation_endpoint"`
+	}
+	if err := discovered.Claims(&claims); err != nil {
+		return fmt.Errorf("google oauth discovery for %s: %w", p.issuerURL, err)
+	}
+	oauthConfig := p.base
+	oauthConfig.Endpoint = discovered.Endpoint()
+	p.endpoints.Store(&endpoints{
+		oauthConfig:      &oauthConfig,
+		verifier:         discovered.Verifier(p.oidcConfig),
+		userInfoEndpoint: claims.UserInfo,
+		revokeEndpoint:   claims.Revocation,
+	})
+	p.logger.Info("google_oauth_discovery_loaded", "issuer", p.issuerURL)
+	return nil
+}
+
+// resolve returns the provider's endpoints, running discovery when it has not succeeded
+// yet. After a failure the error is returned as is until the retry delay passes, so an
+// outage costs each login a fast 503 rather than a discovery timeout.
+func (p *Provider) resolve(ctx context.Context) (*endpoints, error) {
+	if e := p.endpoints.Load(); e != nil {
+		return e, nil
+	}
+	p.discoveryMu.Lock()
+	defer p.discoveryMu.Unlock()
+	if e := p.endpoints.Load(); e != nil {
+		return e, nil
+	}
+	if p.discoveryErr != nil && time.Now().Before(p.discoveryRetryAt) {
+		return nil, p.discoveryErr
+	}
+	if err := p.discover(ctx); err != nil {
+		p.discoveryErr = err
+		p.discoveryRetryAt = time.Now().Add(p.discoveryDelay)
+		p.logger.WarnContext(ctx, "google_oauth_discovery_failed", "issuer", p.issuerURL, "retry_in", p.discoveryDelay, "error", err)
+		p.discoveryDelay = min(2*p.discoveryDelay, maxDiscoveryRetry)
+		return nil, err
+	}
+	p.discoveryErr = nil
+	return p.endpoints.Load(), nil
+}
+
+// Prepare implements auth.Preparer.
+func (p *Provider) Prepare(ctx context.Context) error {
+	_, err := p.resolve(ctx)
+	return err
+}
+
+// Status implements auth.Preparer. While discovery has not succeeded it also starts a
+// background attempt once the retry delay has passed, so polling /readyz notices the
+// issuer recovering without waiting for a login.
+func (p *Provider) Status() string {
+	if p.endpoints.Load() != nil {
+		return "ok"
+	}
+	p.discoveryMu.Lock()
+	err, due := p.discoveryErr, !time.Now().Before(p.discoveryRetryAt)
+	p.discoveryMu.Unlock()
+	if due && p.discoveryInFlight.CompareAndSwap(false, true) {
+		go func() {
+			defer p.discoveryInFlight.Store(false)
+			_ = p.Prepare(context.Background())
+		}()
+	}
+	if err == nil {
+		return "pending discovery"
+	}
+	return "degraded: " + err.Error()
+}
+
+// Name implements auth.Provider.
+func (p *Provider) Name() string { return "google" }
+
+// AuthCodeURL sends google_oauth.access_type, so the default of offline makes Google
+// return a refresh token, and google_oauth.prompt when set. Callers must have had
+// Prepare succeed first.
+func (p *Provider) AuthCodeURL(state, nonce, verifier, loginHint string) string {
+	opts := []oauth2.AuthCodeOption{oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)}
+	if p.accessType == "online" {
+		opts = append(opts, oauth2.AccessTypeOnline)
+	} else {
+		opts = append(opts, oauth2.AccessTypeOffline)
+	}
+	if p.prompt != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", p.prompt))
+	}
+	if loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+	if len(p.allowedDomains) == 1 && len(p.allowedEmails) == 0 {
+		// With a single domain, Google only offers accounts from it on the chooser.
+		for domain := range p.allowedDomains {
+			opts = append(opts, oauth2.SetAuthURLParam("hd", domain))
+		}
+	}
+	return p.endpoints.Load().oauthConfig.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements auth.Provider.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	e, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.oauthConfig.Exchange(p.clientContext(ctx), code, oauth2.VerifierOption(verifier))
+}
+
+// FetchIdentity verifies the token response's ID token, falling back to the userinfo
+// endpoint when there is none and google_oauth.userinfo_fallback is set, then applies
+// the domain and email allowlists.
+func (p *Provider) FetchIdentity(ctx context.Context, token *oauth2.Token, nonce string) (auth.Identity, error) {
+	e, err := p.resolve(ctx)
+	if err != nil {
+		return auth.Identity{}, err
+	}
+	var info GoogleUser
+	rawIDToken, _ := token.Extra("id_token").(string)
+	switch {
+	case rawIDToken != "":
+		var claims json.RawMessage
+		if claims, err = p.verifyIDToken(ctx, e.verifier, rawIDToken, nonce); err != nil {
+			return auth.Identity{}, fmt.Errorf("%w: %v", auth.ErrIdentityRejected, err)
+		}
+		if info, err = p.decodeUser(ctx, claims); err != nil {
+			return auth.Identity{}, fmt.Errorf("decode id token claims: %w", err)
+		}
+	case p.userInfoFallback && e.userInfoEndpoint != "":
+		if info, err = p.fetchUserInfo(ctx, e, token); err != nil {
+			return auth.Identity{}, err
+		}
+	default:
+		return auth.Identity{}, errors.New("token response did not include an id token")
+	}
+
+	if reason := p.rejectReason(info); reason != "" {
+		p.logger.WarnContext(ctx, "google_oauth_login_denied",
+			"email", info.Email, "hosted_domain", info.HostedDomain, "reason", reason)
+		return auth.Identity{}, fmt.Errorf("%w: %s", auth.ErrAccountNotAllowed, reason)
+	}
+
+	name := info.Name
+	if name == "" {
+		name = strings.TrimSpace(info.GivenName + " " + info.FamilyName)
+	}
+	return auth.Identity{
+		Provider:  p.Name(),
+		Subject:   info.Subject,
+		Email:     info.Email,
+		Name:      name,
+		AvatarURL: info.Picture,
+	}, nil
+}
+
+// Refresh implements auth.TokenRefresher.
+func (p *Provider) Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	e, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.oauthConfig.TokenSource(p.clientContext(ctx), token).Token()
+}
+
+// Revoke implements auth.TokenRevoker. Revoking a refresh token also invalidates the
+// access tokens issued from it.
+func (p *Provider) Revoke(ctx context.Context, token string) error {
+	e, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if e.revokeEndpoint == "" {
+		return errors.New("issuer does not advertise a revocation endpoint")
+	}
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.revokeEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// Google answers 400 for tokens that are already invalid, which is as good as revoked.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("revocation endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// verifyIDToken checks the token's signature against Google's cached JWKS, its issuer,
+// audience, and expiry, and that it carries the nonce sent on the authorization request.
+// It returns the raw claims.
+func (p *Provider) verifyIDToken(ctx context.Context, verifier *oidc.IDTokenVerifier, raw, nonce string) (json.RawMessage, error) {
+	idToken, err := verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	var claims json.RawMessage
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decode id token claims: %w", err)
+	}
+	return claims, nil
+}
+
+// fetchUserInfo asks the userinfo endpoint for the identity, retrying once when the
+// request fails before any response arrives. Timeouts are not retried, so a hung endpoint
+// costs one timeout of the provider's client.
+func (p *Provider) fetchUserInfo(ctx context.Context, e *endpoints, token *oauth2.Token) (GoogleUser, error) {
+	client := e.oauthConfig.Client(p.clientContext(ctx), token)
+	resp, err := client.Get(e.userInfoEndpoint)
+	var netErr net.Error
+	if err != nil && ctx.Err() == nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		p.logger.WarnContext(ctx, "google_oauth_userinfo_retry", "error", err)
+		resp, err = client.Get(e.userInfoEndpoint)
+	}
+	if err != nil {
+		return GoogleUser{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return GoogleUser{}, fmt.Errorf("userinfo endpoint returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return GoogleUser{}, fmt.Errorf("read userinfo: %w", err)
+	}
+	info, err := p.decodeUser(ctx, body)
+	if err != nil {
+		return GoogleUser{}, fmt.Errorf("decode userinfo: %w", err)
+	}
+	return info, nil
+}
+
+// clientContext makes oauth2 send its requests through the provider's client.
+func (p *Provider) clientContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+}
+
+// rejectReason applies google_oauth.allowed_domains and allowed_emails, returning why
+// the account is refused or "" when it may sign in. Without either list every account is
+// accepted. Domains are matched against the hd claim rather than the email address, since
+// a consumer Google account can be registered with any email, including ours.
+func (p *Provider) rejectReason(info GoogleUser) string {
+	if len(p.allowedDomains) == 0 && len(p.allowedEmails) == 0 {
+		return ""
+	}
+	if !info.EmailVerified {
+		return "email not verified"
+	}
+	if p.allowedEmails[strings.ToLower(info.Email)] {
+		return ""
+	}
+	if info.HostedDomain != "" && p.allowedDomains[strings.ToLower(info.HostedDomain)] {
+		return ""
+	}
+	return "account not in allowlist"
+}
+
+func lowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
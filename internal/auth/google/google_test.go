@@ -0,0 +1,312 @@
+package google_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-jose/go-jose/v4"
+	"golang.org/x/oauth2"
+
+	"demo/internal/auth"
+	"demo/internal/auth/google"
+	"demo/internal/auth/session"
+	appconfig "demo/internal/config"
+)
+
+const (
+	clientID = "client-id.apps.example"
+	nonce    = "nonce-0123456789"
+)
+
+// issuer is an OpenID provider on httptest serving discovery, a JWKS with one RSA key,
+// and a token endpoint that answers after tokenDelay.
+type issuer struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	tokenDelay time.Duration
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	i := &issuer{key: generateKey(t)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                 i.server.URL,
+			"authorization_endpoint": i.server.URL + "/authorize",
+			"token_endpoint":         i.server.URL + "/token",
+			"jwks_uri":               i.server.URL + "/jwks",
+			"userinfo_endpoint":      i.server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &i.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(i.tokenDelay):
+		case <-r.Context().Done():
+			return
+		}
+		writeJSON(w, map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 3600})
+	})
+	i.server = httptest.NewServer(mux)
+	t.Cleanup(i.server.Close)
+	return i
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	return key
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// claims returns valid ID token claims for a verified example.com Workspace account.
+func (i *issuer) claims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            i.server.URL,
+		"aud":            clientID,
+		"sub":            "108",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"hd":             "example.com",
+		"name":           "Alice Example",
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+// sign returns claims as a compact JWS signed by key under the issuer's key ID.
+func (i *issuer) sign(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "test"}}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatalf("jose.NewSigner: %v", err)
+	}
+	payload, _ := json.Marshal(claims)
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	raw, _ := signed.CompactSerialize()
+	return raw
+}
+
+// provider returns a Google provider discovering the issuer, after mutate adjusts its
+// configuration.
+func (i *issuer) provider(t *testing.T, mutate func(*appconfig.GoogleOAuthConfig), opts ...google.Option) *google.Provider {
+	t.Helper()
+	cfg := appconfig.GoogleOAuthConfig{
+		Enabled:      true,
+		ClientID:     clientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/auth/google/callback",
+		IssuerURL:    i.server.URL,
+	}
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	p, err := google.NewProvider(t.Context(), cfg, slog.New(slog.DiscardHandler), opts...)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return p
+}
+
+// identify runs FetchIdentity on a token response carrying rawIDToken.
+func identify(t *testing.T, p *google.Provider, rawIDToken string) (auth.Identity, error) {
+	t.Helper()
+	token := (&oauth2.Token{AccessToken: "access"}).WithExtra(map[string]any{"id_token": rawIDToken})
+	return p.FetchIdentity(t.Context(), token, nonce)
+}
+
+func TestFetchIdentityVerifiesIDToken(t *testing.T) {
+	i := newIssuer(t)
+	p := i.provider(t, nil)
+
+	identity, err := identify(t, p, i.sign(t, i.key, i.claims()))
+	if err != nil {
+		t.Fatalf("valid ID token: %v", err)
+	}
+	if want := (auth.Identity{Provider: "google", Subject: "108", Email: "alice@example.com", Name: "Alice Example"}); identity != want {
+		t.Fatalf("identity: got %+v, want %+v", identity, want)
+	}
+
+	otherKey := generateKey(t)
+	for _, tc := range []struct {
+		name   string
+		key    *rsa.PrivateKey
+		mutate func(claims map[string]any)
+	}{
+		{"BadSignature", otherKey, func(map[string]any) {}},
+		{"WrongAudience", i.key, func(c map[string]any) { c["aud"] = "someone-else.apps.example" }},
+		{"Expired", i.key, func(c map[string]any) {
+			c["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		}},
+		{"WrongIssuer", i.key, func(c map[string]any) { c["iss"] = "https://accounts.google.com" }},
+		{"WrongNonce", i.key, func(c map[string]any) { c["nonce"] = "replayed-nonce" }},
+		{"NoNonce", i.key, func(c map[string]any) { delete(c, "nonce") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := i.claims()
+			tc.mutate(claims)
+			if _, err := identify(t, p, i.sign(t, tc.key, claims)); !errors.Is(err, auth.ErrIdentityRejected) {
+				t.Fatalf("FetchIdentity: got %v, want ErrIdentityRejected", err)
+			}
+		})
+	}
+
+	t.Run("Tampered", func(t *testing.T) {
+		raw := []byte(i.sign(t, i.key, i.claims()))
+		raw[len(raw)-5] ^= 1
+		if _, err := identify(t, p, string(raw)); !errors.Is(err, auth.ErrIdentityRejected) {
+			t.Fatalf("FetchIdentity: got %v, want ErrIdentityRejected", err)
+		}
+	})
+}
+
+func TestFetchIdentityAllowlist(t *testing.T) {
+	i := newIssuer(t)
+	byDomain := i.provider(t, func(c *appconfig.GoogleOAuthConfig) { c.AllowedDomains = []string{"Example.com"} })
+	byEmail := i.provider(t, func(c *appconfig.GoogleOAuthConfig) {
+		c.AllowedDomains = []string{"example.com"}
+		c.AllowedEmails = []string{"Carol@Gmail.com"}
+	})
+	open := i.provider(t, nil)
+
+	for _, tc := range []struct {
+		name     string
+		provider *google.Provider
+		mutate   func(claims map[string]any)
+		allowed  bool
+	}{
+		{"AllowedDomain", byDomain, func(map[string]any) {}, true},
+		{"AllowedDomainCase", byDomain, func(c map[string]any) { c["hd"] = "EXAMPLE.COM" }, true},
+		{"OtherDomain", byDomain, func(c map[string]any) { c["hd"], c["email"] = "example.org", "bob@example.org" }, false},
+		// A consumer account may be registered with any address; only hd proves Workspace
+		// membership.
+		{"EmailDomainWithoutHD", byDomain, func(c map[string]any) { delete(c, "hd") }, false},
+		{"EmailDomainOtherHD", byDomain, func(c map[string]any) { c["hd"] = "evil.example" }, false},
+		{"HDSuffix", byDomain, func(c map[string]any) { c["hd"] = "example.com.evil.example" }, false},
+		{"HDSubdomain", byDomain, func(c map[string]any) { c["hd"] = "eu.example.com" }, false},
+		{"UnverifiedEmail", byDomain, func(c map[string]any) { c["email_verified"] = false }, false},
+		{"UnverifiedEmailString", byDomain, func(c map[string]any) { c["email_verified"] = "false" }, false},
+		{"VerifiedEmailString", byDomain, func(c map[string]any) { c["email_verified"] = "true" }, true},
+		{"AllowedEmail", byEmail, func(c map[string]any) { delete(c, "hd"); c["email"] = "carol@gmail.com" }, true},
+		{"OtherEmail", byEmail, func(c map[string]any) { delete(c, "hd"); c["email"] = "mallory@gmail.com" }, false},
+		{"NoAllowlist", open, func(c map[string]any) { delete(c, "hd"); c["email_verified"] = false }, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := i.claims()
+			tc.mutate(claims)
+			_, err := identify(t, tc.provider, i.sign(t, i.key, claims))
+			if tc.allowed && err != nil {
+				t.Fatalf("FetchIdentity: %v, want the account allowed", err)
+			}
+			if !tc.allowed && !errors.Is(err, auth.ErrAccountNotAllowed) {
+				t.Fatalf("FetchIdentity: got %v, want ErrAccountNotAllowed", err)
+			}
+		})
+	}
+
+	// With one allowed domain the consent screen only offers accounts from it.
+	for _, tc := range []struct {
+		provider *google.Provider
+		hd       string
+	}{{byDomain, "example.com"}, {byEmail, ""}} {
+		consent, _ := url.Parse(tc.provider.AuthCodeURL("state", nonce, "verifier", ""))
+		if got := consent.Query().Get("hd"); got != tc.hd {
+			t.Errorf("consent URL hd: got %q, want %q", got, tc.hd)
+		}
+	}
+}
+
+// signIn runs a login through a LoginHandler for p and returns the callback's response.
+func signIn(t *testing.T, p *google.Provider) *http.Response {
+	t.Helper()
+	sessions, err := session.NewManager(appconfig.SessionsConfig{CookieName: "session", Lifetime: time.Hour, Keys: []string{"test-session-key-0123456789abcdef"}, Path: "/"}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("session.NewManager: %v", err)
+	}
+	h, err := auth.NewLoginHandler(appconfig.LoginConfig{}, []auth.Provider{p}, sessions, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("NewLoginHandler: %v", err)
+	}
+	r := chi.NewRouter()
+	r.Get("/auth/{provider}/login", h.Login)
+	r.Get("/auth/{provider}/callback", h.Callback)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := client.Get(server.URL + "/auth/google/login")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	resp.Body.Close()
+	consent, err := resp.Location()
+	if err != nil {
+		return resp
+	}
+	resp, err = client.Get(server.URL + "/auth/google/callback?" + url.Values{"state": {consent.Query().Get("state")}, "code": {"code"}}.Encode())
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// TestSlowTokenEndpoint checks that a token endpoint slower than the provider's client
+// timeout fails the callback with 502 instead of holding it.
+func TestSlowTokenEndpoint(t *testing.T) {
+	i := newIssuer(t)
+	i.tokenDelay = 500 * time.Millisecond
+	p := i.provider(t, nil, google.WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+
+	start := time.Now()
+	resp := signIn(t, p)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("callback: got %d, want 502", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed >= i.tokenDelay {
+		t.Fatalf("callback took %v, want it bounded by the client timeout", elapsed)
+	}
+}
+
+// TestUnreachableIssuer checks that an issuer that cannot be discovered answers logins 503
+// and reports the provider degraded.
+func TestUnreachableIssuer(t *testing.T) {
+	i := newIssuer(t)
+	p := i.provider(t, nil)
+	i.server.Close()
+
+	if resp := signIn(t, p); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("login: got %d, want 503", resp.StatusCode)
+	}
+	if status := p.Status(); !strings.HasPrefix(status, "degraded: ") {
+		t.Fatalf("Status: got %q, want degraded", status)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var loginRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "petstore",
+	Subsystem: "auth",
+	Name:      "login_rejected_total",
+	Help:      "OAuth callbacks rejected for an invalid state, a locked-out client, or a disabled account, by reason.",
+}, []string{"reason"})
+
+// Lockout turns away clients after repeated OAuth state-validation failures. Failures
+// are counted over a sliding window and a lockout lasts a fixed duration, after which
+// the client starts afresh, so users behind a shared NAT are never banned for good.
+type Lockout struct {
+	threshold int
+	window    time.Duration
+	duration  time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*lockoutEntry
+	lastSweep time.Time
+}
+
+type lockoutEntry struct {
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout locks a client out for duration once it fails threshold times within window.
+func NewLockout(threshold int, window, duration time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		window:    window,
+		duration:  duration,
+		clients:   make(map[string]*lockoutEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Locked reports whether key is locked out and for how much longer.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	return l.locked(key, time.Now())
+}
+
+// Fail records a failure for key, reporting whether it triggered a lockout.
+func (l *Lockout) Fail(key string) bool {
+	return l.fail(key, time.Now())
+}
+
+func (l *Lockout) locked(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.clients[key]; ok && now.Before(e.lockedUntil) {
+		return true, e.lockedUntil.Sub(now)
+	}
+	return false, 0
+}
+
+func (l *Lockout) fail(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	e, ok := l.clients[key]
+	if !ok {
+		e = &lockoutEntry{}
+		l.clients[key] = e
+	}
+	e.failures = append(recent(e.failures, now.Add(-l.window)), now)
+	if len(e.failures) < l.threshold {
+		return false
+	}
+	e.failures = nil
+	e.lockedUntil = now.Add(l.duration)
+	return true
+}
+
+// sweep drops clients with no recent failures and no active lockout, bounding memory to
+// recently failing clients.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	cutoff := now.Add(-l.window)
+	for key, e := range l.clients {
+		if e.failures = recent(e.failures, cutoff); len(e.failures) == 0 && !now.Before(e.lockedUntil) {
+			delete(l.clients, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// recent drops failures at or before cutoff; failures are in chronological order.
+func recent(failures []time.Time, cutoff time.Time) []time.Time {
+	for i, t := range failures {
+		if t.After(cutoff) {
+			return failures[i:]
+		}
+	}
+	return failures[:0]
+}
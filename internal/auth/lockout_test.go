@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	l := NewLockout(3, time.Minute, 5*time.Minute)
+	t0 := time.Now()
+
+	for i, at := range []time.Duration{0, 10 * time.Second} {
+		if l.fail("203.0.113.9", t0.Add(at)) {
+			t.Fatalf("failure %d: locked out below the threshold", i+1)
+		}
+	}
+	if !l.fail("203.0.113.9", t0.Add(20*time.Second)) {
+		t.Fatal("third failure within the window: not locked out")
+	}
+	if locked, remaining := l.locked("203.0.113.9", t0.Add(80*time.Second)); !locked || remaining != 4*time.Minute {
+		t.Fatalf("a minute later: got locked %v for %v, want 4m0s left", locked, remaining)
+	}
+	if locked, _ := l.locked("198.51.100.7", t0.Add(80*time.Second)); locked {
+		t.Fatal("another client: locked out")
+	}
+
+	expiry := t0.Add(20*time.Second + 5*time.Minute)
+	if locked, _ := l.locked("203.0.113.9", expiry); locked {
+		t.Fatal("after the lockout duration: still locked out")
+	}
+	// The client starts afresh rather than being locked again by its next failure.
+	if l.fail("203.0.113.9", expiry) {
+		t.Fatal("first failure after the lockout: locked out again")
+	}
+}
+
+// TestLockoutWindow checks that failures spread wider than the window never lock a
+// client out, and that idle clients are forgotten.
+func TestLockoutWindow(t *testing.T) {
+	l := NewLockout(3, time.Minute, 5*time.Minute)
+	t0 := time.Now()
+	for i := range 10 {
+		if l.fail("203.0.113.9", t0.Add(time.Duration(i)*31*time.Second)) {
+			t.Fatalf("failure %d: locked out with at most two failures per window", i+1)
+		}
+	}
+
+	l.fail("198.51.100.7", t0.Add(10*time.Minute))
+	if _, ok := l.clients["203.0.113.9"]; ok {
+		t.Fatal("client idle for longer than the window: still tracked")
+	}
+}
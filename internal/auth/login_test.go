@@ -0,0 +1,265 @@
+package auth_test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+
+	"demo/internal/auth"
+	"demo/internal/auth/session"
+	appconfig "demo/internal/config"
+)
+
+// fakeProvider signs every login in as one account without leaving the process.
+type fakeProvider struct{ name string }
+
+func (p fakeProvider) Name() string { return p.name }
+
+func (p fakeProvider) AuthCodeURL(state, nonce, verifier, loginHint string) string {
+	return "https://provider.example/authorize?state=" + url.QueryEscape(state)
+}
+
+func (p fakeProvider) Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "access-" + code}, nil
+}
+
+func (p fakeProvider) FetchIdentity(ctx context.Context, token *oauth2.Token, nonce string) (auth.Identity, error) {
+	return auth.Identity{Provider: p.name, Subject: "subject-1", Email: "alice@example.com"}, nil
+}
+
+// loginFixture serves a LoginHandler for the providers "fake" and "other" to a browser-like
+// client that keeps cookies and does not follow redirects.
+type loginFixture struct {
+	server *httptest.Server
+	client *http.Client
+}
+
+func newLoginFixture(t *testing.T, cfg appconfig.LoginConfig, opts ...auth.LoginOption) *loginFixture {
+	t.Helper()
+	providers := []auth.Provider{fakeProvider{"fake"}, fakeProvider{"other"}}
+	h, err := auth.NewLoginHandler(cfg, providers, newSessions(t), slog.New(slog.DiscardHandler), opts...)
+	if err != nil {
+		t.Fatalf("NewLoginHandler: %v", err)
+	}
+	r := chi.NewRouter()
+	r.Get("/auth/{provider}/login", h.Login)
+	r.Get("/auth/{provider}/callback", h.Callback)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &loginFixture{server: server, client: client}
+}
+
+func (f *loginFixture) get(t *testing.T, path string, query url.Values) *http.Response {
+	t.Helper()
+	resp, err := f.client.Get(f.server.URL + path + "?" + query.Encode())
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// login starts a login at provider and returns the state sent to the provider.
+func (f *loginFixture) login(t *testing.T, provider string, query url.Values) string {
+	t.Helper()
+	resp := f.get(t, "/auth/"+provider+"/login", query)
+	location, err := resp.Location()
+	if resp.StatusCode != http.StatusFound || err != nil {
+		t.Fatalf("login: got %d to %v, want a redirect to the provider", resp.StatusCode, err)
+	}
+	return location.Query().Get("state")
+}
+
+// callback returns from provider with state and an authorization code.
+func (f *loginFixture) callback(t *testing.T, provider, state string) *http.Response {
+	t.Helper()
+	return f.get(t, "/auth/"+provider+"/callback", url.Values{"state": {state}, "code": {"code-1"}})
+}
+
+func TestLoginReturnTo(t *testing.T) {
+	cfg := appconfig.LoginConfig{PostLoginURL: "/home", AllowedRedirectHosts: []string{"app.example.com", "localhost:3000"}}
+	for _, tc := range []struct {
+		name, returnTo string
+		allowed        bool
+	}{
+		{"Path", "/pets?status=available#top", true},
+		{"AllowedHost", "https://app.example.com/pets", true},
+		{"AllowedHostCase", "HTTPS://App.Example.com/pets", true},
+		{"AllowedHostAndPort", "http://localhost:3000/", true},
+		{"ProtocolRelative", "//evil.com", false},
+		{"ProtocolRelativeWithPath", "//evil.com/pets", false},
+		{"Backslash", `/\evil.com`, false},
+		{"OtherHost", "https://evil.com", false},
+		{"AllowedHostAsSubdomain", "https://app.example.com.evil.com/", false},
+		{"AllowedHostOtherPort", "http://localhost:4000/", false},
+		{"UserInfo", "https://app.example.com@evil.com/", false},
+		{"CRLF", "/pets\r\nSet-Cookie: session=stolen", false},
+		{"LF", "/pets\nLocation: https://evil.com", false},
+		{"JavaScript", "javascript:alert(document.cookie)", false},
+		{"JavaScriptCase", "JavaScript://app.example.com/%0Aalert(1)", false},
+		{"Data", "data:text/html,<script>alert(1)</script>", false},
+		{"RelativeWithoutSlash", "pets", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newLoginFixture(t, cfg)
+			state := f.login(t, "fake", url.Values{"return_to": {tc.returnTo}})
+			resp := f.callback(t, "fake", state)
+			want := cfg.PostLoginURL
+			if tc.allowed {
+				want = tc.returnTo
+			}
+			if got := resp.Header.Get("Location"); resp.StatusCode != http.StatusFound || got != want {
+				t.Fatalf("callback: got %d to %q, want a redirect to %q", resp.StatusCode, got, want)
+			}
+		})
+	}
+}
+
+func TestCallbackRejectsState(t *testing.T) {
+	cfg := appconfig.LoginConfig{ErrorRedirectURL: "https://app.example.com/signin-failed"}
+	stores := map[string][]auth.LoginOption{
+		"CookieStore": nil,
+		"MemoryStore": {auth.WithStateStore(auth.NewMemoryStateStore(t.Context()))},
+	}
+	for _, tc := range []struct {
+		name string
+		// callback completes the logins the test started, returning the rejected response.
+		callback func(t *testing.T, f *loginFixture) *http.Response
+		stores   []string
+	}{
+		{"Replayed", func(t *testing.T, f *loginFixture) *http.Response {
+			state := f.login(t, "fake", nil)
+			if resp := f.callback(t, "fake", state); resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/" {
+				t.Fatalf("first callback: got %d to %q, want the login to succeed", resp.StatusCode, resp.Header.Get("Location"))
+			}
+			return f.callback(t, "fake", state)
+		}, []string{"CookieStore", "MemoryStore"}},
+		{"Missing", func(t *testing.T, f *loginFixture) *http.Response {
+			f.login(t, "fake", nil)
+			return f.callback(t, "fake", "")
+		}, []string{"CookieStore", "MemoryStore"}},
+		{"Forged", func(t *testing.T, f *loginFixture) *http.Response {
+			f.login(t, "fake", nil)
+			return f.callback(t, "fake", "c3RhdGUtdGhlLWF0dGFja2VyLW1hZGUtdXA")
+		}, []string{"CookieStore", "MemoryStore"}},
+		// A state from another login attempt in the same browser does not match its cookie.
+		{"OtherAttempt", func(t *testing.T, f *loginFixture) *http.Response {
+			first := f.login(t, "fake", nil)
+			f.login(t, "fake", nil)
+			return f.callback(t, "fake", first)
+		}, []string{"CookieStore"}},
+		{"OtherProvider", func(t *testing.T, f *loginFixture) *http.Response {
+			return f.callback(t, "other", f.login(t, "fake", nil))
+		}, []string{"CookieStore", "MemoryStore"}},
+	} {
+		for _, store := range tc.stores {
+			t.Run(tc.name+"/"+store, func(t *testing.T) {
+				resp := tc.callback(t, newLoginFixture(t, cfg, stores[store]...))
+				location, err := resp.Location()
+				if resp.StatusCode != http.StatusFound || err != nil || location.Query().Get("error") != "invalid_state" {
+					t.Fatalf("callback: got %d to %v (%v), want a redirect with error=invalid_state", resp.StatusCode, location, err)
+				}
+			})
+		}
+	}
+}
+
+// TestCookieAttributes checks the state and session cookies across same_site and secure.
+// same_site none without secure is refused by config validation and not covered here.
+func TestCookieAttributes(t *testing.T) {
+	for _, sameSite := range []struct {
+		value string
+		want  http.SameSite
+	}{{"", http.SameSiteLaxMode}, {"lax", http.SameSiteLaxMode}, {"Strict", http.SameSiteStrictMode}, {"none", http.SameSiteNoneMode}} {
+		for _, secure := range []bool{false, true} {
+			if sameSite.want == http.SameSiteNoneMode && !secure {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/secure=%v", cmp.Or(sameSite.value, "default"), secure), func(t *testing.T) {
+				f := newLoginFixture(t, appconfig.LoginConfig{StateCookie: appconfig.OAuthStateCookieConfig{SameSite: sameSite.value, Secure: secure}})
+				state := findCookie(t, f.get(t, "/auth/fake/login", nil).Cookies(), "oauth_state")
+
+				cfg := sessionsConfig
+				cfg.SameSite, cfg.Secure = sameSite.value, secure
+				sessions, err := session.NewManager(cfg, slog.New(slog.DiscardHandler))
+				if err != nil {
+					t.Fatalf("session.NewManager: %v", err)
+				}
+				rec := httptest.NewRecorder()
+				if err := sessions.Issue(rec, httptest.NewRequest(http.MethodGet, "/", nil), session.Session{UserID: "alice"}); err != nil {
+					t.Fatalf("Issue: %v", err)
+				}
+				sess := findCookie(t, rec.Result().Cookies(), "session")
+
+				for _, c := range []*http.Cookie{state, sess} {
+					if c.SameSite != sameSite.want || c.Secure != secure || !c.HttpOnly {
+						t.Errorf("%s cookie: got SameSite %v, Secure %v, HttpOnly %v; want %v, %v, true", c.Name, c.SameSite, c.Secure, c.HttpOnly, sameSite.want, secure)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestStateCookieHostPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		cookie appconfig.OAuthStateCookieConfig
+		ok     bool
+	}{
+		{"Secure", appconfig.OAuthStateCookieConfig{Name: "__Host-state", Secure: true}, true},
+		{"Insecure", appconfig.OAuthStateCookieConfig{Name: "__Host-state"}, false},
+		{"Path", appconfig.OAuthStateCookieConfig{Name: "__Host-state", Secure: true, Path: "/auth"}, false},
+		{"Domain", appconfig.OAuthStateCookieConfig{Name: "__Host-state", Secure: true, Domain: "example.com"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := auth.NewLoginHandler(appconfig.LoginConfig{StateCookie: tc.cookie}, []auth.Provider{fakeProvider{"fake"}}, newSessions(t), slog.New(slog.DiscardHandler))
+			if (err == nil) != tc.ok {
+				t.Fatalf("NewLoginHandler: got %v, want ok %v", err, tc.ok)
+			}
+		})
+	}
+
+	f := newLoginFixture(t, appconfig.LoginConfig{StateCookie: appconfig.OAuthStateCookieConfig{Name: "__Host-state", Secure: true}})
+	if c := findCookie(t, f.get(t, "/auth/fake/login", nil).Cookies(), "__Host-state"); c.Path != "/" || c.Domain != "" || !c.Secure {
+		t.Fatalf("__Host- cookie: got path %q, domain %q, secure %v", c.Path, c.Domain, c.Secure)
+	}
+}
+
+func findCookie(t *testing.T, cookies []*http.Cookie, name string) *http.Cookie {
+	t.Helper()
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("cookie %s not set, got %v", name, cookies)
+	return nil
+}
+
+func TestCallbackLockout(t *testing.T) {
+	f := newLoginFixture(t, appconfig.LoginConfig{}, auth.WithLockout(auth.NewLockout(2, time.Minute, 5*time.Minute)))
+	for range 2 {
+		f.callback(t, "fake", "forged-state")
+	}
+	resp := f.callback(t, "fake", f.login(t, "fake", nil))
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "300" {
+		t.Fatalf("callback after two failures: got %d with Retry-After %q, want 429 and 300", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
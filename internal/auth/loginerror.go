@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error codes a failed callback reports, following RFC 6749 section 4.1.2.1. Failures on
+// our side use these instead of the underlying error so internals are not leaked.
+const (
+	loginErrInvalidRequest = "invalid_request"
+	loginErrInvalidState   = "invalid_state"
+	loginErrAccessDenied   = "access_denied"
+	loginErrServer         = "server_error"
+	loginErrUnavailable    = "temporarily_unavailable"
+)
+
+// maxLoginErrorLen caps error and error_description, which a provider error echoes from
+// the callback URL and so are attacker-controlled.
+const maxLoginErrorLen = 200
+
+var loginErrorPage = template.Must(template.New("login_error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign-in failed</title></head>
+<body>
+<h1>Sign-in failed</h1>
+<p>{{.Description}}</p>
+<p><small>Error: {{.Code}}</small></p>
+</body>
+</html>
+`))
+
+// loginError ends a failed callback: with login.error_redirect_url set it redirects there
+// with error and error_description, otherwise it renders the error page with status.
+func (h *LoginHandler) loginError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	code, description = truncateUTF8(code, maxLoginErrorLen), truncateUTF8(description, maxLoginErrorLen)
+	if h.errorRedirectURL != "" {
+		target, err := url.Parse(h.errorRedirectURL)
+		if err == nil {
+			query := target.Query()
+			query.Set("error", code)
+			query.Set("error_description", description)
+			target.RawQuery = query.Encode()
+			http.Redirect(w, r, target.String(), http.StatusFound)
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "oauth_error_redirect_invalid", "url", h.errorRedirectURL, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := loginErrorPage.Execute(w, struct{ Code, Description string }{code, description}); err != nil {
+		h.logger.ErrorContext(r.Context(), "oauth_error_page_write_failed", "error", err)
+	}
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,371 @@
+// Package mock is a fake OpenID provider for local development. It stands in for Google
+// under google_oauth.mode: mock so the login flow can be exercised without real
+// credentials: the authorize page lists canned identities, and the token endpoint issues
+// ID tokens signed with a key generated at startup.
+package mock
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-jose/go-jose/v4"
+	"golang.org/x/oauth2"
+)
+
+// codeTTL and tokenTTL bound how long issued codes and access tokens stay redeemable.
+const (
+	codeTTL  = time.Minute
+	tokenTTL = time.Hour
+)
+
+// Identity is a canned account offered on the authorize page.
+type Identity struct {
+	Subject      string
+	Email        string
+	Name         string
+	HostedDomain string
+}
+
+// Identities are the accounts the authorize page offers.
+var Identities = []Identity{
+	{Subject: "mock-alice", Email: "alice@example.com", Name: "Alice Example", HostedDomain: "example.com"},
+	{Subject: "mock-bob", Email: "bob@example.org", Name: "Bob Example", HostedDomain: "example.org"},
+	{Subject: "mock-carol", Email: "carol@gmail.com", Name: "Carol Consumer"},
+}
+
+// Options configures the mock provider.
+type Options struct {
+	// BaseURL is the absolute URL the provider is mounted at, e.g.
+	// "http://localhost:8080/auth/mock"; it doubles as the issuer.
+	BaseURL string
+	// ClientID is the audience of issued ID tokens.
+	ClientID string
+	// RedirectURL is the only redirect_uri the authorize endpoint accepts.
+	RedirectURL string
+	Logger      *slog.Logger
+}
+
+// Server implements the authorize, token, and userinfo endpoints.
+type Server struct {
+	opts   Options
+	key    *rsa.PrivateKey
+	signer jose.Signer
+
+	mu     sync.Mutex
+	codes  map[string]grant
+	tokens map[string]grant
+}
+
+// grant is an authorization code or access token and what it was issued for.
+type grant struct {
+	identity  Identity
+	nonce     string
+	challenge string
+	expires   time.Time
+}
+
+// New generates the signing key and returns the provider.
+func New(opts Options) (*Server, error) {
+	if opts.BaseURL == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, errors.New("mock provider requires a base url, client id, and redirect url")
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "mock"}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		opts:   opts,
+		key:    key,
+		signer: signer,
+		codes:  make(map[string]grant),
+		tokens: make(map[string]grant),
+	}, nil
+}
+
+// Issuer is the iss claim of issued ID tokens.
+func (s *Server) Issuer() string { return s.opts.BaseURL }
+
+// Endpoint is the OAuth endpoint the Google provider should use instead of Google's.
+func (s *Server) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:   s.opts.BaseURL + "/authorize",
+		TokenURL:  s.opts.BaseURL + "/token",
+		AuthStyle: oauth2.AuthStyleInHeader,
+	}
+}
+
+// UserInfoURL is the userinfo endpoint.
+func (s *Server) UserInfoURL() string { return s.opts.BaseURL + "/userinfo" }
+
+// KeySet verifies ID tokens issued by this provider.
+func (s *Server) KeySet() oidc.KeySet {
+	return &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&s.key.PublicKey}}
+}
+
+// Handler serves the provider; mount it at the path of Options.BaseURL.
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/authorize", s.authorize)
+	r.Get("/approve", s.approve)
+	r.Post("/token", s.token)
+	r.Get("/userinfo", s.userInfo)
+	return r
+}
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Mock sign-in</title></head>
+<body>
+<h1>Mock sign-in</h1>
+<p>Development only: choose an account to sign in as.</p>
+<ul>
+{{range .}}<li><a href="{{.Link}}">{{.Identity.Name}} &lt;{{.Identity.Email}}&gt;</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// authorize renders the account chooser. Each link carries the original query on to
+// approve together with the chosen account.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	if !s.checkRequest(w, r) {
+		return
+	}
+	type choice struct {
+		Identity Identity
+		Link     string
+	}
+	choices := make([]choice, 0, len(Identities))
+	for i, id := range Identities {
+		query := r.URL.Query()
+		query.Set("account", strconv.Itoa(i))
+		choices = append(choices, choice{Identity: id, Link: s.opts.BaseURL + "/approve?" + query.Encode()})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := authorizePage.Execute(w, choices); err != nil {
+		s.opts.Logger.Error("mock_oauth_page_write_failed", "error", err)
+	}
+}
+
+// approve issues an authorization code for the chosen account and redirects back to the
+// client.
+func (s *Server) approve(w http.ResponseWriter, r *http.Request) {
+	if !s.checkRequest(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	index, err := strconv.Atoi(query.Get("account"))
+	if err != nil || index < 0 || index >= len(Identities) {
+		http.Error(w, "unknown account", http.StatusBadRequest)
+		return
+	}
+	code, err := randomToken()
+	if err != nil {
+		http.Error(w, "failed to issue code", http.StatusInternalServerError)
+		return
+	}
+	s.remember(s.codes, code, grant{
+		identity:  Identities[index],
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+		expires:   time.Now().Add(codeTTL),
+	})
+
+	target, _ := url.Parse(s.opts.RedirectURL)
+	values := target.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	target.RawQuery = values.Encode()
+	s.opts.Logger.Info("mock_oauth_approved", "email", Identities[index].Email)
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// checkRequest validates the authorization request parameters shared by authorize and
+// approve.
+func (s *Server) checkRequest(w http.ResponseWriter, r *http.Request) bool {
+	query := r.URL.Query()
+	switch {
+	case query.Get("client_id") != s.opts.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+	case query.Get("redirect_uri") != s.opts.RedirectURL:
+		http.Error(w, "redirect_uri does not match the configured redirect url", http.StatusBadRequest)
+	case query.Get("response_type") != "code":
+		http.Error(w, "response_type must be code", http.StatusBadRequest)
+	case query.Get("state") == "":
+		http.Error(w, "missing state", http.StatusBadRequest)
+	default:
+		return true
+	}
+	return false
+}
+
+// token redeems an authorization code, checking the PKCE verifier when the authorization
+// request carried a challenge.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if clientID, _, ok := r.BasicAuth(); (ok && clientID != s.opts.ClientID) || (!ok && r.PostForm.Get("client_id") != s.opts.ClientID) {
+		tokenError(w, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+	g, ok := s.take(s.codes, r.PostForm.Get("code"))
+	if !ok {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	if g.challenge != "" {
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+			tokenError(w, "invalid_grant")
+			return
+		}
+	}
+
+	now := time.Now()
+	claims := s.claims(g.identity)
+	claims["iss"] = s.Issuer()
+	claims["aud"] = s.opts.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(tokenTTL).Unix()
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	signed, err := s.signer.Sign(payload)
+	if err != nil {
+		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	idToken, err := signed.CompactSerialize()
+	if err != nil {
+		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	accessToken, err := randomToken()
+	if err != nil {
+		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	s.remember(s.tokens, accessToken, grant{identity: g.identity, expires: now.Add(tokenTTL)})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+// userInfo answers with the claims of the account the bearer token was issued to.
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	g, found := s.tokens[token]
+	s.mu.Unlock()
+	if !found || time.Now().After(g.expires) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.claims(g.identity))
+}
+
+func (s *Server) claims(id Identity) map[string]any {
+	claims := map[string]any{
+		"sub":            id.Subject,
+		"email":          id.Email,
+		"email_verified": true,
+		"name":           id.Name,
+	}
+	if id.HostedDomain != "" {
+		claims["hd"] = id.HostedDomain
+	}
+	return claims
+}
+
+// remember stores g under key, dropping expired entries so the maps stay small.
+func (s *Server) remember(grants map[string]grant, key string, g grant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, existing := range grants {
+		if now.After(existing.expires) {
+			delete(grants, k)
+		}
+	}
+	grants[key] = g
+}
+
+// take removes and returns the unexpired grant for key, making codes single-use.
+func (s *Server) take(grants map[string]grant, key string) (grant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := grants[key]
+	delete(grants, key)
+	if !ok || time.Now().After(g.expires) {
+		return grant{}, false
+	}
+	return g, true
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrAccountNotAllowed is returned by FetchIdentity when the account authenticated
+	// but is excluded by the provider's allowlist; the login is answered with 403.
+	ErrAccountNotAllowed = errors.New("account not allowed")
+	// ErrIdentityRejected is returned by FetchIdentity when the provider's identity
+	// assertion fails verification (signature, audience, nonce); the login is answered
+	// with 401.
+	ErrIdentityRejected = errors.New("identity rejected")
+)
+
+// Identity is a provider account normalized across providers.
+type Identity struct {
+	Provider  string
+	Subject   string
+	Email     string
+	Name      string
+	AvatarURL string
+}
+
+// Provider is one OAuth 2.0 login provider mounted at /auth/{Name}/login and
+// /auth/{Name}/callback.
+type Provider interface {
+	Name() string
+	// AuthCodeURL returns the provider's consent URL. nonce should be forwarded when the
+	// provider supports OpenID Connect, and verifier is the PKCE code verifier whose S256
+	// challenge goes on the URL. loginHint, when not empty, suggests the account to sign
+	// in with.
+	AuthCodeURL(state, nonce, verifier, loginHint string) string
+	// Exchange redeems code, sending the PKCE verifier passed to AuthCodeURL.
+	Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error)
+	// FetchIdentity resolves token into the signed-in account; nonce is the value passed to
+	// AuthCodeURL for this login.
+	FetchIdentity(ctx context.Context, token *oauth2.Token, nonce string) (Identity, error)
+}
+
+// TokenRefresher is implemented by providers that issue refresh tokens.
+type TokenRefresher interface {
+	Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+}
+
+// Preparer is implemented by providers whose setup needs the network, such as OIDC
+// discovery, and is deferred to first use so an unreachable provider does not stop the
+// API from starting.
+type Preparer interface {
+	// Prepare completes the setup, returning an error while the provider is unusable.
+	// Login calls it before AuthCodeURL and answers 503 when it fails.
+	Prepare(ctx context.Context) error
+	// Status summarizes the setup for /readyz: "ok", or why the provider is degraded.
+	Status() string
+}
+
+// TokenRevoker is implemented by providers that can revoke tokens on logout.
+type TokenRevoker interface {
+	Revoke(ctx context.Context, token string) error
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"demo/internal/apierr"
+	"demo/internal/httpmw"
+)
+
+// Role is a user's permission level; each role includes the ones below it.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// ParseRole validates a role name from config, the database, or an admin request.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := roleRank[role]; !ok {
+		return "", fmt.Errorf("unknown role %q (want viewer, editor, or admin)", name)
+	}
+	return role, nil
+}
+
+// Allows reports whether r grants at least required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// ErrAuthenticationRequired and ErrForbidden are returned by Authorize.
+var (
+	ErrAuthenticationRequired = errors.New("authentication required")
+	ErrForbidden              = errors.New("forbidden")
+)
+
+// Authorize applies the RequireRole check to the user in ctx, for handlers such as GraphQL
+// that choose the required role per operation rather than per route. Failures wrap
+// ErrAuthenticationRequired or ErrForbidden.
+func Authorize(ctx context.Context, role Role) error {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return ErrAuthenticationRequired
+	}
+	if !user.Role.Allows(role) {
+		return fmt.Errorf("%w: requires the %s role", ErrForbidden, role)
+	}
+	return nil
+}
+
+// RequireRole rejects requests whose user lacks role: anonymous callers get 401 and
+// users with a lower role get 403 naming the role required. It must run after
+// Authenticator.Middleware.
+func RequireRole(role Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="petstore"`)
+				httpmw.WriteError(w, r, apierr.ErrUnauthenticated, "authentication required")
+				return
+			}
+			if !user.Role.Allows(role) {
+				httpmw.WriteError(w, r, apierr.ErrForbidden, fmt.Sprintf("requires the %s role", role))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRoleByMethod applies the pet route policy: DELETE needs admin, other writes need
+// editor, and reads need readRole, or nothing when it is empty. Bulk and import routes
+// are admin-only and belong under RequireRole(RoleAdmin) directly.
+func RequireRoleByMethod(readRole Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		readHandler := next
+		if readRole != "" {
+			readHandler = RequireRole(readRole)(next)
+		}
+		writeHandler, deleteHandler := RequireRole(RoleEditor)(next), RequireRole(RoleAdmin)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case isSafeMethod(r.Method):
+				readHandler.ServeHTTP(w, r)
+			case r.Method == http.MethodDelete:
+				deleteHandler.ServeHTTP(w, r)
+			default:
+				writeHandler.ServeHTTP(w, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "demo/internal/config"
+	"demo/internal/httpmw"
+)
+
+var (
+	// ErrNoSession means the request carries no session cookie.
+	ErrNoSession = errors.New("no session")
+	// ErrInvalid means the cookie failed decryption or authentication, e.g. after tampering
+	// or a key rotation that dropped its key.
+	ErrInvalid = errors.New("invalid session")
+	// ErrExpired means the session authenticated but is past its expiry.
+	ErrExpired = errors.New("session expired")
+	// ErrRevoked means the cookie is valid but its server-side record is gone, because it
+	// was revoked or predates sessions.store.
+	ErrRevoked = errors.New("session revoked")
+	// ErrNoStore means the operation needs sessions.store, which is not configured.
+	ErrNoStore = errors.New("server-side sessions are not enabled")
+)
+
+// Session is the identity carried by the session cookie.
+type Session struct {
+	// ID names the server-side Record when sessions.store is set; empty otherwise.
+	ID       string `json:"sid,omitempty"`
+	UserID   string `json:"uid"`
+	Provider string `json:"prv,omitempty"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	// Role is the user's role at login; changes apply from the next login.
+	Role string `json:"rol,omitempty"`
+	// CSRF is the token cookie-authenticated writes must echo in X-CSRF-Token.
+	CSRF      string    `json:"csrf,omitempty"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// Manager issues and reads sessions stored as AES-GCM sealed cookies, so the client can
+// neither read nor forge them. The first configured key seals new cookies; all keys are
+// tried when opening, which allows rotation without logging everyone out.
+//
+// With a Store, each cookie also names a server-side Record that can be listed and
+// revoked. The record's expiry is authoritative and slides forward with activity; lookups
+// are cached for cacheTTL, so a revocation made on another instance takes up to that long
+// to apply there.
+type Manager struct {
+	cfg    appconfig.SessionsConfig
+	aeads  []cipher.AEAD
+	logger *slog.Logger
+
+	store    Store
+	cacheTTL time.Duration
+	cacheMu  sync.Mutex
+	cache    map[string]cachedRecord
+}
+
+// cachedRecord is a Record as of fetched.
+type cachedRecord struct {
+	rec     Record
+	fetched time.Time
+}
+
+// touchInterval limits how often activity is written back to the store per session.
+const touchInterval = time.Minute
+
+// maxCachedRecords bounds the record cache; stale entries are dropped when it is reached.
+const maxCachedRecords = 10000
+
+// Option customises a Manager at construction time.
+type Option func(*Manager)
+
+// WithStore keeps a server-side record for every session in store, caching lookups for
+// cacheTTL.
+func WithStore(store Store, cacheTTL time.Duration) Option {
+	return func(m *Manager) {
+		m.store = store
+		m.cacheTTL = cacheTTL
+	}
+}
+
+type contextKey struct{}
+
+// NewManager builds a Manager from the sessions config section.
+func NewManager(cfg appconfig.SessionsConfig, logger *slog.Logger, opts ...Option) (*Manager, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("sessions.keys must contain at least one key")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	aeads := make([]cipher.AEAD, 0, len(cfg.Keys))
+	for i, secret := range cfg.Keys {
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("sessions.keys[%d] must be at least 32 bytes", i)
+		}
+		key := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads = append(aeads, aead)
+	}
+	m := &Manager{cfg: cfg, aeads: aeads, logger: logger, cache: make(map[string]cachedRecord)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Issue seals s (with ExpiresAt set from the configured lifetime) into the session cookie.
+// A session without a CSRF token gets a fresh one, so each login rotates it. With a
+// Store, Issue first records the session with r's user agent and client IP.
+func (m *Manager) Issue(w http.ResponseWriter, r *http.Request, s Session) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	s.ExpiresAt = now.Add(m.cfg.Lifetime)
+	if s.CSRF == "" {
+		token, err := randomID()
+		if err != nil {
+			return err
+		}
+		s.CSRF = token
+	}
+	if m.store != nil {
+		id, err := randomID()
+		if err != nil {
+			return err
+		}
+		rec := Record{
+			ID:         id,
+			UserID:     s.UserID,
+			CreatedAt:  now,
+			ExpiresAt:  s.ExpiresAt,
+			LastSeenAt: now,
+			UserAgent:  r.UserAgent(),
+			IP:         httpmw.ClientIP(r),
+		}
+		if err := m.store.Create(r.Context(), rec); err != nil {
+			return err
+		}
+		m.remember(rec)
+		s.ID = id
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	value, err := m.Seal(m.cfg.CookieName, payload)
+	if err != nil {
+		return err
+	}
+
+	cookie := m.cookie(value)
+	cookie.MaxAge = int(m.cfg.Lifetime.Seconds())
+	cookie.Expires = s.ExpiresAt
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// Read opens the session cookie on r.
+func (m *Manager) Read(r *http.Request) (Session, error) {
+	cookie, err := r.Cookie(m.cfg.CookieName)
+	if err != nil || cookie.Value == "" {
+		return Session{}, ErrNoSession
+	}
+	payload, err := m.Open(m.cfg.CookieName, cookie.Value)
+	if err != nil {
+		return Session{}, err
+	}
+	var s Session
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return Session{}, ErrInvalid
+	}
+	if m.store != nil {
+		// The record's expiry slides with activity, so it replaces the sealed one.
+		rec, err := m.record(r.Context(), s.ID)
+		if err != nil {
+			return s, err
+		}
+		s.ExpiresAt = rec.ExpiresAt
+	}
+	if time.Now().After(s.ExpiresAt) {
+		return s, ErrExpired
+	}
+	return s, nil
+}
+
+// record returns the live record for id, from the cache when it is fresh enough.
+func (m *Manager) record(ctx context.Context, id string) (Record, error) {
+	if id == "" {
+		return Record{}, ErrRevoked
+	}
+	m.cacheMu.Lock()
+	cached, ok := m.cache[id]
+	m.cacheMu.Unlock()
+	if ok && time.Since(cached.fetched) < m.cacheTTL {
+		return cached.rec, nil
+	}
+	rec, err := m.store.Get(ctx, id)
+	if err != nil {
+		m.forget(id)
+		if errors.Is(err, ErrNotFound) {
+			return Record{}, ErrRevoked
+		}
+		return Record{}, err
+	}
+	m.remember(rec)
+	return rec, nil
+}
+
+func (m *Manager) remember(rec Record) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	now := time.Now()
+	if len(m.cache) >= maxCachedRecords {
+		for id, cached := range m.cache {
+			if now.Sub(cached.fetched) >= m.cacheTTL {
+				delete(m.cache, id)
+			}
+		}
+	}
+	m.cache[rec.ID] = cachedRecord{rec: rec, fetched: now}
+}
+
+func (m *Manager) forget(id string) {
+	m.cacheMu.Lock()
+	delete(m.cache, id)
+	m.cacheMu.Unlock()
+}
+
+// touch slides the session's expiry forward when it was last seen over touchInterval ago,
+// re-sending the cookie so the browser keeps it as long as the record lives.
+func (m *Manager) touch(w http.ResponseWriter, r *http.Request, s Session) {
+	rec, err := m.record(r.Context(), s.ID)
+	if err != nil || time.Since(rec.LastSeenAt) < touchInterval {
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(m.cfg.Lifetime)
+	if err := m.store.Touch(r.Context(), rec.ID, now, expires); err != nil {
+		m.logger.WarnContext(r.Context(), "session_touch_failed", "error", err)
+		return
+	}
+	rec.LastSeenAt, rec.ExpiresAt = now, expires
+	m.remember(rec)
+
+	if current, err := r.Cookie(m.cfg.CookieName); err == nil {
+		cookie := m.cookie(current.Value)
+		cookie.MaxAge = int(m.cfg.Lifetime.Seconds())
+		cookie.Expires = expires
+		http.SetCookie(w, cookie)
+	}
+}
+
+// List returns the user's live server-side sessions, most recently seen first.
+func (m *Manager) List(ctx context.Context, userID string) ([]Record, error) {
+	if m.store == nil {
+		return nil, ErrNoStore
+	}
+	return m.store.List(ctx, userID)
+}
+
+// Revoke deletes one of the user's sessions; ErrNotFound means the user has none with id.
+func (m *Manager) Revoke(ctx context.Context, userID, id string) error {
+	if m.store == nil {
+		return ErrNoStore
+	}
+	m.forget(id)
+	return m.store.Delete(ctx, userID, id)
+}
+
+// RevokeAll deletes every session of the user, e.g. when their account is disabled.
+func (m *Manager) RevokeAll(ctx context.Context, userID string) (int64, error) {
+	return m.RevokeOthers(ctx, userID, "")
+}
+
+// RevokeOthers deletes every session of the user except keepID.
+func (m *Manager) RevokeOthers(ctx context.Context, userID, keepID string) (int64, error) {
+	if m.store == nil {
+		return 0, ErrNoStore
+	}
+	m.cacheMu.Lock()
+	for id, cached := range m.cache {
+		if cached.rec.UserID == userID && id != keepID {
+			delete(m.cache, id)
+		}
+	}
+	m.cacheMu.Unlock()
+	return m.store.DeleteOthers(ctx, userID, keepID)
+}
+
+// Seal encrypts and authenticates payload with the current key into a URL-safe string.
+// purpose is bound into the ciphertext so a value sealed for one use (e.g. OAuth state)
+// cannot be replayed as another (e.g. the session cookie).
+func (m *Manager) Seal(purpose string, payload []byte) (string, error) {
+	aead := m.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, payload, []byte(purpose))), nil
+}
+
+// Open reverses Seal with any configured key, returning ErrInvalid when value was not
+// sealed by us for purpose.
+func (m *Manager) Open(purpose, value string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	for _, aead := range m.aeads {
+		if len(sealed) < aead.NonceSize() {
+			return nil, ErrInvalid
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, ciphertext, []byte(purpose)); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, ErrInvalid
+}
+
+// Clear expires the session cookie using the attributes it was issued with.
+func (m *Manager) Clear(w http.ResponseWriter) {
+	cookie := m.cookie("")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	http.SetCookie(w, cookie)
+}
+
+// Middleware attaches a valid session, when present, to the request context for
+// FromContext. It never rejects requests; enforcement belongs to the auth middleware,
+// which can tell missing, invalid, and expired sessions apart via Read.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := m.Read(r)
+		if err == nil {
+			if m.store != nil {
+				m.touch(w, r, s)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, s))
+		} else if !errors.Is(err, ErrNoSession) {
+			m.logger.DebugContext(r.Context(), "session_rejected", "error", err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FromContext returns the session attached by Middleware.
+func FromContext(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(contextKey{}).(Session)
+	return s, ok
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (m *Manager) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    value,
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		Secure:   m.cfg.Secure,
+		HttpOnly: true,
+		SameSite: SameSite(m.cfg.SameSite),
+	}
+}
+
+// SameSite maps a lax/strict/none config value onto http.SameSite, defaulting to Lax.
+func SameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,130 @@
+package session_test
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"demo/internal/auth/session"
+	appconfig "demo/internal/config"
+)
+
+const (
+	key      = "test-session-key-0123456789abcdef"
+	otherKey = "other-session-key-0123456789abcdef"
+)
+
+func newManager(t *testing.T, keys ...string) *session.Manager {
+	t.Helper()
+	m, err := session.NewManager(appconfig.SessionsConfig{CookieName: "session", Lifetime: time.Hour, Keys: keys, Path: "/"}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+// withCookie returns a request carrying value as the session cookie.
+func withCookie(value string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: value})
+	return req
+}
+
+func seal(t *testing.T, m *session.Manager, purpose, payload string) string {
+	t.Helper()
+	value, err := m.Seal(purpose, []byte(payload))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	return value
+}
+
+func TestIssueRead(t *testing.T) {
+	m := newManager(t, key)
+	rec := httptest.NewRecorder()
+	in := session.Session{UserID: "42", Provider: "google", Email: "alice@example.com", Name: "Alice", Role: "editor"}
+	if err := m.Issue(rec, httptest.NewRequest(http.MethodGet, "/", nil), in); err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+
+	got, err := m.Read(req)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got.CSRF == "" || time.Until(got.ExpiresAt) <= 59*time.Minute {
+		t.Fatalf("Read: got CSRF %q expiring at %v, want a token and an hour's lifetime", got.CSRF, got.ExpiresAt)
+	}
+	in.CSRF, in.ExpiresAt = got.CSRF, got.ExpiresAt
+	if got != in {
+		t.Fatalf("Read: got %+v, want %+v", got, in)
+	}
+}
+
+// TestReadClaims pins the cookie's claim names, which sessions issued by earlier releases
+// are still read with.
+func TestReadClaims(t *testing.T) {
+	m := newManager(t, key)
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	payload := `{"sid":"s1","uid":"42","prv":"github","email":"bob@example.org","name":"Bob","rol":"admin","csrf":"token","exp":"` + exp.Format(time.RFC3339) + `"}`
+
+	got, err := m.Read(withCookie(seal(t, m, "session", payload)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := session.Session{ID: "s1", UserID: "42", Provider: "github", Email: "bob@example.org", Name: "Bob", Role: "admin", CSRF: "token", ExpiresAt: exp}
+	if !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Fatalf("exp: got %v, want %v", got.ExpiresAt, want.ExpiresAt)
+	}
+	got.ExpiresAt = want.ExpiresAt
+	if got != want {
+		t.Fatalf("Read: got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	m := newManager(t, key)
+	valid := `{"uid":"42","exp":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`
+	tampered := []byte(seal(t, m, "session", valid))
+	tampered[len(tampered)/2] ^= 1
+
+	for _, tc := range []struct {
+		name string
+		req  *http.Request
+		want error
+	}{
+		{"NoCookie", httptest.NewRequest(http.MethodGet, "/", nil), session.ErrNoSession},
+		{"Empty", withCookie(""), session.ErrNoSession},
+		{"Tampered", withCookie(string(tampered)), session.ErrInvalid},
+		{"Truncated", withCookie(seal(t, m, "session", valid)[:20]), session.ErrInvalid},
+		{"NotBase64", withCookie("not base64!"), session.ErrInvalid},
+		{"OtherKey", withCookie(seal(t, newManager(t, otherKey), "session", valid)), session.ErrInvalid},
+		// An OAuth state is sealed with the same key but cannot stand in for a session.
+		{"OtherPurpose", withCookie(seal(t, m, "oauth_state", valid)), session.ErrInvalid},
+		{"NotJSON", withCookie(seal(t, m, "session", "uid=42")), session.ErrInvalid},
+		{"Expired", withCookie(seal(t, m, "session", `{"uid":"42","exp":"`+time.Now().Add(-time.Second).Format(time.RFC3339)+`"}`)), session.ErrExpired},
+		{"NoExpiry", withCookie(seal(t, m, "session", `{"uid":"42"}`)), session.ErrExpired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := m.Read(tc.req); !errors.Is(err, tc.want) {
+				t.Fatalf("Read: got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestReadRotatedKey checks that cookies sealed with a retired key are still read while
+// it is listed, and rejected once it is dropped.
+func TestReadRotatedKey(t *testing.T) {
+	value := seal(t, newManager(t, otherKey), "session", `{"uid":"42","exp":"`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`)
+	if s, err := newManager(t, key, otherKey).Read(withCookie(value)); err != nil || s.UserID != "42" {
+		t.Fatalf("Read with the old key listed: got %+v, %v", s, err)
+	}
+	if _, err := newManager(t, key).Read(withCookie(value)); !errors.Is(err, session.ErrInvalid) {
+		t.Fatalf("Read after dropping the old key: got %v, want ErrInvalid", err)
+	}
+}
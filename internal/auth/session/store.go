@@ -0,0 +1,280 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound means the store has no live session with that ID, e.g. after revocation.
+var ErrNotFound = errors.New("session not found")
+
+// Record is the server-side half of a session. The cookie carries only its ID; revoking
+// the record ends the session wherever the cookie is.
+type Record struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+}
+
+// Store persists session records. Get returns ErrNotFound for unknown and expired IDs.
+type Store interface {
+	Create(ctx context.Context, rec Record) error
+	Get(ctx context.Context, id string) (Record, error)
+	// Touch records activity at seen and moves the expiry to expiresAt.
+	Touch(ctx context.Context, id string, seen, expiresAt time.Time) error
+	// List returns the user's live sessions, most recently seen first.
+	List(ctx context.Context, userID string) ([]Record, error)
+	// Delete revokes one of the user's sessions, returning ErrNotFound when the user has
+	// no such session.
+	Delete(ctx context.Context, userID, id string) error
+	// DeleteOthers revokes every session of the user except keepID.
+	DeleteOthers(ctx context.Context, userID, keepID string) (int64, error)
+}
+
+// janitorInterval is how often the stores delete expired records.
+const janitorInterval = time.Minute
+
+// MemoryStore keeps session records in process memory, for single-instance deployments.
+// A janitor goroutine evicts expired records until its context is cancelled.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore starts the janitor, which runs until ctx is done.
+func NewMemoryStore(ctx context.Context) *MemoryStore {
+	s := &MemoryStore{records: make(map[string]Record)}
+	go janitor(ctx, janitorInterval, func(now time.Time) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for id, rec := range s.records {
+			if now.After(rec.ExpiresAt) {
+				delete(s.records, id)
+			}
+		}
+	})
+	return s
+}
+
+// Create implements Store.
+func (s *MemoryStore) Create(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = rec
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, id string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok || time.Now().After(rec.ExpiresAt) {
+		return Record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Touch implements Store.
+func (s *MemoryStore) Touch(_ context.Context, id string, seen, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	rec.LastSeenAt, rec.ExpiresAt = seen, expiresAt
+	s.records[id] = rec
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(_ context.Context, userID string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var out []Record
+	for _, rec := range s.records {
+		if rec.UserID == userID && !now.After(rec.ExpiresAt) {
+			out = append(out, rec)
+		}
+	}
+	slices.SortFunc(out, func(a, b Record) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return out, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok || rec.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.records, id)
+	return nil
+}
+
+// DeleteOthers implements Store.
+func (s *MemoryStore) DeleteOthers(_ context.Context, userID, keepID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, rec := range s.records {
+		if rec.UserID == userID && id != keepID {
+			delete(s.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PostgresStore keeps session records in the sessions table so every instance sees
+// revocations. A janitor goroutine deletes expired rows.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore prepares the sessions table and starts the janitor, which runs until
+// ctx is done.
+func NewPostgresStore(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) (*PostgresStore, error) {
+	if pool == nil {
+		return nil, errors.New("pgx pool is nil")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	const ddl = `
+        CREATE TABLE IF NOT EXISTS sessions (
+            id           TEXT PRIMARY KEY,
+            user_id      TEXT NOT NULL,
+            created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+            expires_at   TIMESTAMPTZ NOT NULL,
+            last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            user_agent   TEXT NOT NULL DEFAULT '',
+            ip           TEXT NOT NULL DEFAULT ''
+        );
+        CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);`
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("failed to ensure sessions table: %w", err)
+	}
+
+	go janitor(ctx, janitorInterval, func(time.Time) {
+		if _, err := pool.Exec(ctx, `DELETE FROM sessions WHERE expires_at < now()`); err != nil && ctx.Err() == nil {
+			logger.Warn("session_cleanup_failed", "error", err)
+		}
+	})
+	return &PostgresStore{pool: pool}, nil
+}
+
+// Create implements Store.
+func (s *PostgresStore) Create(ctx context.Context, rec Record) error {
+	_, err := s.pool.Exec(ctx, `
+        INSERT INTO sessions (id, user_id, created_at, expires_at, last_seen_at, user_agent, ip)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		rec.ID, rec.UserID, rec.CreatedAt, rec.ExpiresAt, rec.LastSeenAt, rec.UserAgent, rec.IP)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+const recordColumns = `id, user_id, created_at, expires_at, last_seen_at, user_agent, ip`
+
+func scanRecord(row pgx.Row) (Record, error) {
+	var rec Record
+	err := row.Scan(&rec.ID, &rec.UserID, &rec.CreatedAt, &rec.ExpiresAt, &rec.LastSeenAt, &rec.UserAgent, &rec.IP)
+	return rec, err
+}
+
+// Get implements Store.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Record, error) {
+	rec, err := scanRecord(s.pool.QueryRow(ctx,
+		`SELECT `+recordColumns+` FROM sessions WHERE id = $1 AND expires_at > now()`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Record{}, ErrNotFound
+		}
+		return Record{}, fmt.Errorf("failed to load session: %w", err)
+	}
+	return rec, nil
+}
+
+// Touch implements Store.
+func (s *PostgresStore) Touch(ctx context.Context, id string, seen, expiresAt time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE sessions SET last_seen_at = $2, expires_at = $3 WHERE id = $1`, id, seen, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *PostgresStore) List(ctx context.Context, userID string) ([]Record, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+recordColumns+` FROM sessions
+        WHERE user_id = $1 AND expires_at > now()
+        ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+	var out []Record
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// Delete implements Store.
+func (s *PostgresStore) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteOthers implements Store.
+func (s *PostgresStore) DeleteOthers(ctx context.Context, userID, keepID string) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`, userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func janitor(ctx context.Context, interval time.Duration, sweep func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweep(now)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/auth/session"
+)
+
+// sessionInfo is one entry of the GET /auth/sessions response.
+type sessionInfo struct {
+	session.Record
+	// Current marks the session the request was made with.
+	Current bool `json:"current"`
+}
+
+// ListSessions answers GET /auth/sessions with the caller's active sessions. It needs
+// sessions.store; without it there is nothing server-side to list and it answers 404.
+func (h *LoginHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sess, ok := session.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "no active session", http.StatusUnauthorized)
+		return
+	}
+	records, err := h.sessions.List(r.Context(), sess.UserID)
+	if err != nil {
+		h.sessionStoreError(w, r, "session_list_failed", err)
+		return
+	}
+	out := make([]sessionInfo, 0, len(records))
+	for _, rec := range records {
+		out = append(out, sessionInfo{Record: rec, Current: rec.ID == sess.ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(map[string]any{"sessions": out}); err != nil {
+		h.logger.WarnContext(r.Context(), "session_list_write_failed", "error", err)
+	}
+}
+
+// RevokeSession answers DELETE /auth/sessions/{id}, ending one of the caller's sessions;
+// revoking the current one also clears its cookie.
+func (h *LoginHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.sessionForWrite(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := h.sessions.Revoke(r.Context(), sess.UserID, id); err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		h.sessionStoreError(w, r, "session_revoke_failed", err)
+		return
+	}
+	if id == sess.ID {
+		h.sessions.Clear(w)
+	}
+	h.logger.InfoContext(r.Context(), "session_revoked", "user_id", sess.UserID, "current", id == sess.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions answers DELETE /auth/sessions, ending every session of the caller
+// except the current one, e.g. after a lost device.
+func (h *LoginHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.sessionForWrite(w, r)
+	if !ok {
+		return
+	}
+	n, err := h.sessions.RevokeOthers(r.Context(), sess.UserID, sess.ID)
+	if err != nil {
+		h.sessionStoreError(w, r, "session_revoke_failed", err)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "sessions_revoked", "user_id", sess.UserID, "count", n)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionForWrite returns the caller's session for a request that changes session state,
+// which must be same-origin and carry the session's CSRF token.
+func (h *LoginHandler) sessionForWrite(w http.ResponseWriter, r *http.Request) (session.Session, bool) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-site request rejected", http.StatusForbidden)
+		return session.Session{}, false
+	}
+	sess, ok := session.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "no active session", http.StatusUnauthorized)
+		return session.Session{}, false
+	}
+	if reason := csrfRejection(r, sess.CSRF); reason != "" {
+		http.Error(w, reason, http.StatusForbidden)
+		return session.Session{}, false
+	}
+	return sess, true
+}
+
+func (h *LoginHandler) sessionStoreError(w http.ResponseWriter, r *http.Request, event string, err error) {
+	if errors.Is(err, session.ErrNoStore) {
+		http.Error(w, "server-side sessions are not enabled", http.StatusNotFound)
+		return
+	}
+	h.logger.ErrorContext(r.Context(), event, "error", err)
+	http.Error(w, "failed to access sessions", http.StatusInternalServerError)
+}
+
+// revokeCurrentSession deletes the session's server-side record, if it has one, so the
+// cookie stops working even if the browser keeps it.
+func (h *LoginHandler) revokeCurrentSession(r *http.Request, sess session.Session) {
+	if sess.ID == "" {
+		return
+	}
+	err := h.sessions.Revoke(r.Context(), sess.UserID, sess.ID)
+	if err != nil && !errors.Is(err, session.ErrNotFound) && !errors.Is(err, session.ErrNoStore) {
+		h.logger.WarnContext(r.Context(), "session_revoke_failed", "user_id", sess.UserID, "error", err)
+	}
+}
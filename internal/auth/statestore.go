@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/auth/session"
+	appconfig "demo/internal/config"
+)
+
+// ErrStateNotFound means the state is unknown, expired, or was already used.
+var ErrStateNotFound = errors.New("oauth state not found")
+
+// PendingLogin is what the flow remembers between Login and Callback.
+type PendingLogin struct {
+	Provider  string    `json:"provider"`
+	ReturnTo  string    `json:"return_to,omitempty"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StateStore keeps pending logins keyed by the opaque OAuth state value. Consume must be
+// single-use: a second call with the same state fails with ErrStateNotFound.
+type StateStore interface {
+	Save(ctx context.Context, w http.ResponseWriter, login PendingLogin) (state string, err error)
+	Consume(ctx context.Context, w http.ResponseWriter, r *http.Request, state string) (PendingLogin, error)
+}
+
+// CookieStateStore seals the pending login into the state value itself and binds it to
+// the browser with a cookie holding the same value, so nothing is stored server-side.
+type CookieStateStore struct {
+	sessions *session.Manager
+	cookie   appconfig.OAuthStateCookieConfig
+}
+
+// hostPrefix marks cookies browsers only accept when Secure, with Path "/" and no Domain.
+const hostPrefix = "__Host-"
+
+// NewCookieStateStore builds the default state store. It rejects a __Host- cookie whose
+// attributes the browser would refuse.
+func NewCookieStateStore(sessions *session.Manager, cookie appconfig.OAuthStateCookieConfig) (*CookieStateStore, error) {
+	if strings.HasPrefix(cookie.Name, hostPrefix) {
+		switch {
+		case !cookie.Secure:
+			return nil, fmt.Errorf("state cookie %q requires secure", cookie.Name)
+		case cookie.Path != "/":
+			return nil, fmt.Errorf("state cookie %q requires path \"/\"", cookie.Name)
+		case cookie.Domain != "":
+			return nil, fmt.Errorf("state cookie %q must not set a domain", cookie.Name)
+		}
+	}
+	return &CookieStateStore{sessions: sessions, cookie: cookie}, nil
+}
+
+// stateSealPurpose binds sealed state values to this flow.
+const stateSealPurpose = "oauth_state"
+
+// janitorInterval is how often the server-side stores evict expired state.
+const janitorInterval = time.Minute
+
+// Save implements StateStore.
+func (s *CookieStateStore) Save(_ context.Context, w http.ResponseWriter, login PendingLogin) (string, error) {
+	encoded, err := json.Marshal(login)
+	if err != nil {
+		return "", err
+	}
+	state, err := s.sessions.Seal(stateSealPurpose, encoded)
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, s.buildCookie(state, login.ExpiresAt))
+	return state, nil
+}
+
+// Consume implements StateStore. Clearing the cookie makes the state single-use for this
+// browser.
+func (s *CookieStateStore) Consume(_ context.Context, w http.ResponseWriter, r *http.Request, state string) (PendingLogin, error) {
+	cookie, err := r.Cookie(s.cookie.Name)
+	if err != nil {
+		return PendingLogin{}, fmt.Errorf("%w: oauth state cookie not found", ErrStateNotFound)
+	}
+	if !constantTimeEqual(cookie.Value, state) {
+		return PendingLogin{}, fmt.Errorf("%w: state does not match cookie", ErrStateNotFound)
+	}
+	http.SetCookie(w, s.clearCookie())
+
+	var login PendingLogin
+	decoded, err := s.sessions.Open(stateSealPurpose, state)
+	if err == nil {
+		err = json.Unmarshal(decoded, &login)
+	}
+	if err != nil {
+		return PendingLogin{}, fmt.Errorf("%w: %v", ErrStateNotFound, err)
+	}
+	if time.Now().After(login.ExpiresAt) {
+		return PendingLogin{}, fmt.Errorf("%w: expired", ErrStateNotFound)
+	}
+	return login, nil
+}
+
+func (s *CookieStateStore) buildCookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.cookie.Name,
+		Value:    value,
+		Path:     s.cookie.Path,
+		Domain:   s.cookie.Domain,
+		Secure:   s.cookie.Secure,
+		HttpOnly: true,
+		SameSite: session.SameSite(s.cookie.SameSite),
+		MaxAge:   int(s.cookie.MaxAge.Seconds()),
+		Expires:  expires,
+	}
+}
+
+func (s *CookieStateStore) clearCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     s.cookie.Name,
+		Path:     s.cookie.Path,
+		Domain:   s.cookie.Domain,
+		Value:    "",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   s.cookie.Secure,
+		HttpOnly: true,
+		SameSite: session.SameSite(s.cookie.SameSite),
+	}
+}
+
+// MemoryStateStore keeps pending logins in process memory, for single-instance
+// deployments whose clients drop cookies. A janitor goroutine evicts expired entries
+// until its context is cancelled.
+type MemoryStateStore struct {
+	mu      sync.Mutex
+	pending map[string]PendingLogin
+}
+
+// NewMemoryStateStore starts the janitor, which runs until ctx is done.
+func NewMemoryStateStore(ctx context.Context) *MemoryStateStore {
+	s := &MemoryStateStore{pending: make(map[string]PendingLogin)}
+	go janitor(ctx, janitorInterval, func(now time.Time) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for state, login := range s.pending {
+			if now.After(login.ExpiresAt) {
+				delete(s.pending, state)
+			}
+		}
+	})
+	return s
+}
+
+// Save implements StateStore.
+func (s *MemoryStateStore) Save(_ context.Context, _ http.ResponseWriter, login PendingLogin) (string, error) {
+	state, err := generateState()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.pending[state] = login
+	s.mu.Unlock()
+	return state, nil
+}
+
+// Consume implements StateStore.
+func (s *MemoryStateStore) Consume(_ context.Context, _ http.ResponseWriter, _ *http.Request, state string) (PendingLogin, error) {
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.ExpiresAt) {
+		return PendingLogin{}, ErrStateNotFound
+	}
+	return login, nil
+}
+
+// PostgresStateStore keeps pending logins in the oauth_states table so every instance
+// behind a load balancer can complete a login started on another. Rows are keyed by a
+// hash of the state, and a janitor goroutine deletes expired rows.
+type PostgresStateStore struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+}
+
+// NewPostgresStateStore prepares the oauth_states table and starts the janitor, which
+// runs until ctx is done.
+func NewPostgresStateStore(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) (*PostgresStateStore, error) {
+	if pool == nil {
+		return nil, errors.New("pgx pool is nil")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	const ddl = `
+        CREATE TABLE IF NOT EXISTS oauth_states (
+            state_hash TEXT PRIMARY KEY,
+            provider   TEXT NOT NULL,
+            return_to  TEXT NOT NULL DEFAULT '',
+            nonce      TEXT NOT NULL,
+            verifier   TEXT NOT NULL,
+            expires_at TIMESTAMPTZ NOT NULL
+        );`
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("failed to ensure oauth_states table: %w", err)
+	}
+
+	s := &PostgresStateStore{pool: pool, logger: logger}
+	go janitor(ctx, janitorInterval, func(time.Time) {
+		if _, err := pool.Exec(ctx, `DELETE FROM oauth_states WHERE expires_at < now()`); err != nil && ctx.Err() == nil {
+			logger.Warn("oauth_state_cleanup_failed", "error", err)
+		}
+	})
+	return s, nil
+}
+
+// Save implements StateStore.
+func (s *PostgresStateStore) Save(ctx context.Context, _ http.ResponseWriter, login PendingLogin) (string, error) {
+	state, err := generateState()
+	if err != nil {
+		return "", err
+	}
+	_, err = s.pool.Exec(ctx, `
+        INSERT INTO oauth_states (state_hash, provider, return_to, nonce, verifier, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)`,
+		hashState(state), login.Provider, login.ReturnTo, login.Nonce, login.Verifier, login.ExpiresAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to save oauth state: %w", err)
+	}
+	return state, nil
+}
+
+// Consume implements StateStore; the DELETE ... RETURNING makes it single-use even when
+// two callbacks race.
+func (s *PostgresStateStore) Consume(ctx context.Context, _ http.ResponseWriter, _ *http.Request, state string) (PendingLogin, error) {
+	var login PendingLogin
+	err := s.pool.QueryRow(ctx, `
+        DELETE FROM oauth_states WHERE state_hash = $1
+        RETURNING provider, return_to, nonce, verifier, expires_at`, hashState(state)).
+		Scan(&login.Provider, &login.ReturnTo, &login.Nonce, &login.Verifier, &login.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PendingLogin{}, ErrStateNotFound
+		}
+		return PendingLogin{}, fmt.Errorf("failed to consume oauth state: %w", err)
+	}
+	if time.Now().After(login.ExpiresAt) {
+		return PendingLogin{}, ErrStateNotFound
+	}
+	return login, nil
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+func janitor(ctx context.Context, interval time.Duration, sweep func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweep(now)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound indicates the user or token row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// User is a person who has signed in at least once.
+type User struct {
+	ID       int64
+	Provider string
+	Subject  string
+	Email    string
+	Name     string
+	Picture  string
+	// Role is viewer, editor, or admin; new users are viewers.
+	Role string
+	// Disabled users are refused at login.
+	Disabled bool
+	// OrgID is the user's primary organization; org_memberships may grant more.
+	OrgID       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+	// Created reports whether UpsertUser inserted the row on this login.
+	Created bool
+}
+
+// Profile is the identity asserted by the provider on each login.
+type Profile struct {
+	Provider string
+	Subject  string
+	Email    string
+	Name     string
+	Picture  string
+}
+
+// Tokens are the OAuth tokens held for a user. Google only returns RefreshToken on first
+// consent, so an empty value on save keeps the stored one.
+type Tokens struct {
+	RefreshToken string
+	AccessToken  string
+	Expiry       time.Time
+}
+
+// UserFilter selects users for ListUsers. Results are ordered by ID and start after
+// AfterID; empty fields match every user.
+type UserFilter struct {
+	// EmailContains matches case-insensitively anywhere in the email address.
+	EmailContains string
+	Role          string
+	// IDs, when non-empty, restricts the result to these users.
+	IDs     []int64
+	AfterID int64
+	Limit   int
+}
+
+// UserUpdate changes the non-nil fields of a user.
+type UserUpdate struct {
+	Role     *string
+	Disabled *bool
+}
+
+// UserRepository persists users and their OAuth tokens.
+type UserRepository interface {
+	// UpsertUser creates the user on first login and otherwise refreshes the profile and
+	// last_login_at.
+	UpsertUser(ctx context.Context, profile Profile) (User, error)
+	SaveTokens(ctx context.Context, userID int64, tokens Tokens) error
+	Tokens(ctx context.Context, userID int64) (Tokens, error)
+	DeleteTokens(ctx context.Context, userID int64) error
+	// SetRole changes the user's role, returning ErrNotFound for unknown users.
+	SetRole(ctx context.Context, userID int64, role string) (User, error)
+	ListUsers(ctx context.Context, filter UserFilter) ([]User, error)
+	// User, UpdateUser, and DeleteUser return ErrNotFound for unknown users.
+	User(ctx context.Context, userID int64) (User, error)
+	UpdateUser(ctx context.Context, userID int64, update UserUpdate) (User, error)
+	// DeleteUser removes the user and, by cascade, their stored tokens and memberships.
+	DeleteUser(ctx context.Context, userID int64) error
+	// Orgs lists the user's organizations, primary first, and none for unknown users.
+	Orgs(ctx context.Context, userID int64) ([]string, error)
+}
+
+// PostgresUserRepository implements UserRepository; tokens are stored AES-GCM encrypted
+// so a database dump does not expose them.
+type PostgresUserRepository struct {
+	pool *pgxpool.Pool
+	aead cipher.AEAD
+}
+
+// NewPostgresUserRepository prepares the users and user_tokens tables. encryptionKey is
+// the login.token_encryption_key secret, at least 32 bytes.
+func NewPostgresUserRepository(ctx context.Context, pool *pgxpool.Pool, encryptionKey string) (*PostgresUserRepository, error) {
+	if pool == nil {
+		return nil, errors.New("pgx pool is nil")
+	}
+	if len(encryptionKey) < 32 {
+		return nil, errors.New("token encryption key must be at least 32 bytes")
+	}
+	key := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &PostgresUserRepository{pool: pool, aead: aead}
+	if err := repo.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (r *PostgresUserRepository) ensureSchema(ctx context.Context) error {
+	const ddl = `
+        CREATE TABLE IF NOT EXISTS users (
+            id            BIGSERIAL PRIMARY KEY,
+            provider      TEXT NOT NULL,
+            subject       TEXT NOT NULL,
+            email         TEXT NOT NULL,
+            name          TEXT NOT NULL,
+            picture       TEXT NOT NULL DEFAULT '',
+            created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+            last_login_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            UNIQUE (provider, subject)
+        );
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'viewer'
+            CHECK (role IN ('viewer', 'editor', 'admin'));
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT false;
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+        CREATE TABLE IF NOT EXISTS org_memberships (
+            user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+            org_id  TEXT NOT NULL,
+            PRIMARY KEY (user_id, org_id)
+        );
+        CREATE TABLE IF NOT EXISTS user_tokens (
+            user_id              BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+            refresh_token        BYTEA NOT NULL,
+            access_token         BYTEA,
+            access_token_expiry  TIMESTAMPTZ,
+            updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+        );` + upgradeGoogleOnlyUsers
+
+	if _, err := r.pool.Exec(ctx, ddl); err != nil {
+		return fmt.Errorf("failed to ensure users tables: %w", err)
+	}
+	return nil
+}
+
+// upgradeGoogleOnlyUsers converts the original google_sub keyed users table to
+// (provider, subject); it matches migration 0003 and is a no-op on current schemas.
+const upgradeGoogleOnlyUsers = `
+        DO $$
+        BEGIN
+            IF EXISTS (SELECT 1 FROM information_schema.columns
+                       WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'google_sub') THEN
+                ALTER TABLE users RENAME COLUMN google_sub TO subject;
+                ALTER TABLE users ADD COLUMN provider TEXT NOT NULL DEFAULT 'google';
+                ALTER TABLE users ALTER COLUMN provider DROP DEFAULT;
+                ALTER TABLE users DROP CONSTRAINT IF EXISTS users_google_sub_key;
+                ALTER TABLE users ADD CONSTRAINT users_provider_subject_key UNIQUE (provider, subject);
+            END IF;
+        END $$;`
+
+// UpsertUser creates or updates the user identified by profile.Provider and Subject.
+func (r *PostgresUserRepository) UpsertUser(ctx context.Context, profile Profile) (User, error) {
+	const query = `
+        INSERT INTO users (provider, subject, email, name, picture)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (provider, subject) DO UPDATE
+            SET email = EXCLUDED.email, name = EXCLUDED.name, picture = EXCLUDED.picture, last_login_at = now()
+        RETURNING ` + userColumns + `, xmax = 0`
+
+	var u User
+	// xmax is zero only for rows this statement inserted.
+	err := r.pool.QueryRow(ctx, query, profile.Provider, profile.Subject, profile.Email, profile.Name, profile.Picture).
+		Scan(&u.ID, &u.Provider, &u.Subject, &u.Email, &u.Name, &u.Picture, &u.Role, &u.Disabled, &u.OrgID, &u.CreatedAt, &u.LastLoginAt, &u.Created)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to upsert user: %w", err)
+	}
+	return u, nil
+}
+
+// userColumns are the users columns scanUser reads, in order.
+const userColumns = `id, provider, subject, email, name, picture, role, disabled, org_id, created_at, last_login_at`
+
+func scanUser(row pgx.Row) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Provider, &u.Subject, &u.Email, &u.Name, &u.Picture, &u.Role, &u.Disabled, &u.OrgID, &u.CreatedAt, &u.LastLoginAt)
+	return u, err
+}
+
+// SetRole updates the role of userID.
+func (r *PostgresUserRepository) SetRole(ctx context.Context, userID int64, role string) (User, error) {
+	return r.UpdateUser(ctx, userID, UserUpdate{Role: &role})
+}
+
+// ListUsers returns up to filter.Limit users matching filter.
+func (r *PostgresUserRepository) ListUsers(ctx context.Context, filter UserFilter) ([]User, error) {
+	var email, role *string
+	if filter.EmailContains != "" {
+		pattern := "%" + escapeLike(filter.EmailContains) + "%"
+		email = &pattern
+	}
+	if filter.Role != "" {
+		role = &filter.Role
+	}
+	var ids []int64
+	if len(filter.IDs) > 0 {
+		ids = filter.IDs
+	}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+userColumns+` FROM users
+        WHERE id > $1
+          AND ($2::text IS NULL OR email ILIKE $2)
+          AND ($3::text IS NULL OR role = $3)
+          AND ($5::bigint[] IS NULL OR id = ANY($5))
+        ORDER BY id
+        LIMIT $4`, filter.AfterID, email, role, filter.Limit, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// User loads userID.
+func (r *PostgresUserRepository) User(ctx context.Context, userID int64) (User, error) {
+	u, err := scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return u, nil
+}
+
+// UpdateUser applies update to userID; an empty update just loads the user.
+func (r *PostgresUserRepository) UpdateUser(ctx context.Context, userID int64, update UserUpdate) (User, error) {
+	u, err := scanUser(r.pool.QueryRow(ctx, `
+        UPDATE users SET role = COALESCE($2, role), disabled = COALESCE($3, disabled)
+        WHERE id = $1
+        RETURNING `+userColumns, userID, update.Role, update.Disabled))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("failed to update user: %w", err)
+	}
+	return u, nil
+}
+
+// Orgs returns the organizations userID belongs to: their primary organization first,
+// then their other memberships in name order. It returns none for unknown users.
+func (r *PostgresUserRepository) Orgs(ctx context.Context, userID int64) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+        SELECT org_id FROM (
+            SELECT org_id, 0 AS rank FROM users WHERE id = $1
+            UNION ALL
+            SELECT m.org_id, 1 FROM org_memberships m JOIN users u ON u.id = m.user_id
+            WHERE m.user_id = $1 AND m.org_id <> u.org_id
+        ) orgs ORDER BY rank, org_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user organizations: %w", err)
+	}
+	orgs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// DeleteUser removes userID.
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, userID int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveTokens stores tokens for userID. Without a refresh token only the access token of
+// an existing row is updated.
+func (r *PostgresUserRepository) SaveTokens(ctx context.Context, userID int64, tokens Tokens) error {
+	access, err := r.sealOptional(tokens.AccessToken)
+	if err != nil {
+		return err
+	}
+	var expiry *time.Time
+	if !tokens.Expiry.IsZero() {
+		expiry = &tokens.Expiry
+	}
+
+	if tokens.RefreshToken == "" {
+		_, err := r.pool.Exec(ctx, `
+            UPDATE user_tokens SET access_token = $2, access_token_expiry = $3, updated_at = now()
+            WHERE user_id = $1`, userID, access, expiry)
+		if err != nil {
+			return fmt.Errorf("failed to update user tokens: %w", err)
+		}
+		return nil
+	}
+
+	refresh, err := r.seal(tokens.RefreshToken)
+	if err != nil {
+		return err
+	}
+	_, err = r.pool.Exec(ctx, `
+        INSERT INTO user_tokens (user_id, refresh_token, access_token, access_token_expiry)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (user_id) DO UPDATE
+            SET refresh_token = EXCLUDED.refresh_token, access_token = EXCLUDED.access_token,
+                access_token_expiry = EXCLUDED.access_token_expiry, updated_at = now()`,
+		userID, refresh, access, expiry)
+	if err != nil {
+		return fmt.Errorf("failed to save user tokens: %w", err)
+	}
+	return nil
+}
+
+// Tokens loads and decrypts the tokens stored for userID.
+func (r *PostgresUserRepository) Tokens(ctx context.Context, userID int64) (Tokens, error) {
+	var (
+		refresh, access []byte
+		expiry          *time.Time
+	)
+	err := r.pool.QueryRow(ctx, `
+        SELECT refresh_token, access_token, access_token_expiry FROM user_tokens WHERE user_id = $1`, userID).
+		Scan(&refresh, &access, &expiry)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Tokens{}, ErrNotFound
+		}
+		return Tokens{}, fmt.Errorf("failed to fetch user tokens: %w", err)
+	}
+
+	var tokens Tokens
+	if tokens.RefreshToken, err = r.open(refresh); err != nil {
+		return Tokens{}, err
+	}
+	if access != nil {
+		if tokens.AccessToken, err = r.open(access); err != nil {
+			return Tokens{}, err
+		}
+	}
+	if expiry != nil {
+		tokens.Expiry = *expiry
+	}
+	return tokens, nil
+}
+
+// DeleteTokens forgets the tokens stored for userID, e.g. after revocation.
+func (r *PostgresUserRepository) DeleteTokens(ctx context.Context, userID int64) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM user_tokens WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresUserRepository) seal(plaintext string) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize(), r.aead.NonceSize()+len(plaintext)+r.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+func (r *PostgresUserRepository) sealOptional(plaintext string) ([]byte, error) {
+	if plaintext == "" {
+		return nil, nil
+	}
+	return r.seal(plaintext)
+}
+
+func (r *PostgresUserRepository) open(sealed []byte) (string, error) {
+	if len(sealed) < r.aead.NonceSize() {
+		return "", errors.New("stored token is corrupt")
+	}
+	nonce, ciphertext := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt stored token: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,5 @@
+// Package blob implements petstore.BlobStore: PostgresStore keeps blobs in a bytea column,
+// which needs nothing beyond the database but grows its backups, FileStore keeps them in a
+// local directory, which every instance serving the blobs must share, and S3Store keeps
+// them in an S3-compatible bucket.
+package blob
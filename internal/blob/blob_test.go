@@ -0,0 +1,190 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"demo/internal/blob"
+	"demo/internal/database/databasetest"
+	"demo/internal/petstore"
+	"demo/internal/petstore/petstoretest"
+)
+
+func TestMain(m *testing.M) {
+	code := databasetest.Main(m)
+	if minioContainer != nil {
+		if err := testcontainers.TerminateContainer(minioContainer); err != nil {
+			fmt.Fprintf(os.Stderr, "blob: removing %s container: %v\n", minioImage, err)
+		}
+	}
+	os.Exit(code)
+}
+
+func TestFileStore(t *testing.T) {
+	petstoretest.RunBlobStoreConformanceTests(t, func() petstore.BlobStore {
+		store, err := blob.NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFileStore: %v", err)
+		}
+		return store
+	})
+}
+
+func TestPostgresStore(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunBlobStoreConformanceTests(t, func() petstore.BlobStore {
+		databasetest.Truncate(t, pool)
+		store, err := blob.NewPostgresStore(t.Context(), pool)
+		if err != nil {
+			t.Fatalf("NewPostgresStore: %v", err)
+		}
+		return store
+	})
+}
+
+func TestFileStoreRejectsEscapingKeys(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blob.NewFileStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	for _, key := range []string{"../outside", "photos/../../outside", "/etc/passwd"} {
+		if err := store.Put(t.Context(), key, petstore.BlobInfo{ContentType: "text/plain"}, bytes.NewReader([]byte("x"))); err == nil {
+			t.Errorf("Put(%q): got nil error, want the key rejected", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); !os.IsNotExist(err) {
+		t.Fatalf("a file was written outside the blob directory: %v", err)
+	}
+}
+
+// The S3 tests run against MinIO in a container started by the first of them and removed
+// by TestMain; they skip with -short or without Docker.
+const (
+	minioImage  = "minio/minio:RELEASE.2025-04-22T22-12-26Z"
+	minioUser   = "petstore"
+	minioSecret = "petstore-secret"
+	minioBucket = "petstore"
+)
+
+var (
+	minioOnce                sync.Once
+	minioContainer           *testcontainers.DockerContainer
+	minioEndpoint, minioSkip string
+	// prefixes hands every store its own key prefix, so each starts empty.
+	prefixes atomic.Int64
+)
+
+func startMinIO() {
+	ctx := context.Background()
+	var err error
+	minioContainer, err = testcontainers.Run(ctx, minioImage,
+		testcontainers.WithExposedPorts("9000/tcp"),
+		testcontainers.WithEnv(map[string]string{"MINIO_ROOT_USER": minioUser, "MINIO_ROOT_PASSWORD": minioSecret}),
+		testcontainers.WithCmd("server", "/data"),
+		testcontainers.WithWaitStrategy(wait.ForHTTP("/minio/health/live").WithPort("9000/tcp")),
+	)
+	if err == nil {
+		minioEndpoint, err = minioContainer.PortEndpoint(ctx, "9000/tcp", "http")
+	}
+	if err == nil {
+		err = makeBucket(ctx)
+	}
+	if err != nil {
+		minioEndpoint, minioSkip = "", fmt.Sprintf("no %s container could be started (%v); skipping S3 tests", minioImage, err)
+	}
+}
+
+func makeBucket(ctx context.Context) error {
+	u, err := minioURL()
+	if err != nil {
+		return err
+	}
+	client, err := minio.New(u, &minio.Options{Creds: credentials.NewStaticV4(minioUser, minioSecret, "")})
+	if err != nil {
+		return err
+	}
+	return client.MakeBucket(ctx, minioBucket, minio.MakeBucketOptions{})
+}
+
+// minioURL is the host:port minio-go connects to.
+func minioURL() (string, error) {
+	host, ok := strings.CutPrefix(minioEndpoint, "http://")
+	if !ok {
+		return "", fmt.Errorf("unexpected MinIO endpoint %q", minioEndpoint)
+	}
+	return host, nil
+}
+
+// s3Stores skips t without MinIO and otherwise returns a constructor of empty S3Stores
+// with the given part size.
+func s3Stores(t *testing.T, partSize uint64) func() petstore.BlobStore {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("-short set; skipping S3 tests")
+	}
+	minioOnce.Do(startMinIO)
+	if minioEndpoint == "" {
+		t.Skip(minioSkip)
+	}
+	return func() petstore.BlobStore {
+		store, err := blob.NewS3Store(t.Context(), blob.S3Options{
+			Endpoint:        minioEndpoint,
+			Bucket:          minioBucket,
+			Region:          "us-east-1",
+			AccessKeyID:     minioUser,
+			SecretAccessKey: minioSecret,
+			Prefix:          fmt.Sprintf("test-%d/", prefixes.Add(1)),
+			PresignTTL:      time.Minute,
+			PartSize:        partSize,
+		})
+		if err != nil {
+			t.Fatalf("NewS3Store: %v", err)
+		}
+		return store
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	newStore := s3Stores(t, 0)
+	petstoretest.RunBlobStoreConformanceTests(t, newStore)
+	petstoretest.RunBlobPresignerConformanceTests(t, newStore)
+}
+
+// TestS3StoreMultipart uses the smallest part size S3 allows, so LargeBlob goes up as a
+// multipart upload.
+func TestS3StoreMultipart(t *testing.T) {
+	petstoretest.RunBlobStoreConformanceTests(t, s3Stores(t, 5<<20))
+}
+
+func TestS3StoreMissingBucket(t *testing.T) {
+	s3Stores(t, 0)
+	_, err := blob.NewS3Store(t.Context(), blob.S3Options{
+		Endpoint:        minioEndpoint,
+		Bucket:          "missing",
+		AccessKeyID:     minioUser,
+		SecretAccessKey: minioSecret,
+	})
+	if err == nil {
+		t.Fatal("NewS3Store with a missing bucket: got nil error")
+	}
+}
+
+func TestS3StoreInvalidEndpoint(t *testing.T) {
+	if _, err := blob.NewS3Store(t.Context(), blob.S3Options{Endpoint: "not a url", Bucket: minioBucket}); err == nil {
+		t.Fatal("NewS3Store with an invalid endpoint: got nil error")
+	}
+}
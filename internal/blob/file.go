@@ -0,0 +1,138 @@
+package blob
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"demo/internal/petstore"
+)
+
+// FileStore keeps each blob in a file under a directory, named by its key. The file starts
+// with a line of JSON holding the content type and ETag, so a blob and its metadata are
+// replaced together by one rename.
+type FileStore struct {
+	dir string
+}
+
+// fileHeader is the first line of a blob file.
+type fileHeader struct {
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag"`
+}
+
+// NewFileStore creates dir if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put implements petstore.BlobStore.
+func (s *FileStore) Put(_ context.Context, key string, info petstore.BlobInfo, body io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	header, _ := json.Marshal(fileHeader{ContentType: info.ContentType, ETag: info.ETag})
+	_, err = f.Write(append(header, '\n'))
+	if err == nil {
+		_, err = io.Copy(f, body)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write blob file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to commit blob file: %w", err)
+	}
+	return nil
+}
+
+// Get implements petstore.BlobStore.
+func (s *FileStore) Get(_ context.Context, key string) (petstore.BlobInfo, io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return petstore.BlobInfo{}, nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return petstore.BlobInfo{}, nil, petstore.ErrBlobNotFound
+	}
+	if err != nil {
+		return petstore.BlobInfo{}, nil, fmt.Errorf("failed to open blob file: %w", err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return petstore.BlobInfo{}, nil, fmt.Errorf("failed to open blob file: %w", err)
+	}
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	var header fileHeader
+	if err == nil {
+		err = json.Unmarshal(line, &header)
+	}
+	if err != nil {
+		f.Close()
+		return petstore.BlobInfo{}, nil, fmt.Errorf("failed to read blob file header: %w", err)
+	}
+	info := petstore.BlobInfo{
+		ContentType: header.ContentType,
+		ETag:        header.ETag,
+		Size:        stat.Size() - int64(len(line)),
+		ModTime:     stat.ModTime(),
+	}
+	return info, struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// Delete implements petstore.BlobStore.
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return petstore.ErrBlobNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete blob file: %w", err)
+	}
+	return nil
+}
+
+// path maps key, whose slashes become directories, to a file under s.dir.
+func (s *FileStore) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("blob key %q escapes the blob directory", key)
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+var _ petstore.BlobStore = (*FileStore)(nil)
@@ -0,0 +1,88 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/petstore"
+)
+
+// PostgresStore keeps blobs in the blobs table. Whole blobs are read into memory, so it
+// suits small objects such as photos.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore ensures the blobs table exists.
+func NewPostgresStore(ctx context.Context, pool *pgxpool.Pool) (*PostgresStore, error) {
+	if pool == nil {
+		return nil, errors.New("pgx pool is nil")
+	}
+	const ddl = `
+        CREATE TABLE IF NOT EXISTS blobs (
+            key          TEXT PRIMARY KEY,
+            content_type TEXT NOT NULL,
+            etag         TEXT NOT NULL,
+            data         BYTEA NOT NULL,
+            updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+        );`
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("failed to ensure blobs table: %w", err)
+	}
+	return &PostgresStore{pool: pool}, nil
+}
+
+// Put implements petstore.BlobStore.
+func (s *PostgresStore) Put(ctx context.Context, key string, info petstore.BlobInfo, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	_, err = s.pool.Exec(ctx, `
+        INSERT INTO blobs (key, content_type, etag, data) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (key) DO UPDATE
+        SET content_type = excluded.content_type, etag = excluded.etag, data = excluded.data, updated_at = now()`,
+		key, info.ContentType, info.ETag, data)
+	if err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+	return nil
+}
+
+// Get implements petstore.BlobStore.
+func (s *PostgresStore) Get(ctx context.Context, key string) (petstore.BlobInfo, io.ReadCloser, error) {
+	var (
+		info petstore.BlobInfo
+		data []byte
+	)
+	err := s.pool.QueryRow(ctx, `SELECT content_type, etag, data, updated_at FROM blobs WHERE key = $1`, key).
+		Scan(&info.ContentType, &info.ETag, &data, &info.ModTime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return petstore.BlobInfo{}, nil, petstore.ErrBlobNotFound
+	}
+	if err != nil {
+		return petstore.BlobInfo{}, nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	info.Size = int64(len(data))
+	return info, io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete implements petstore.BlobStore.
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM blobs WHERE key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return petstore.ErrBlobNotFound
+	}
+	return nil
+}
+
+var _ petstore.BlobStore = (*PostgresStore)(nil)
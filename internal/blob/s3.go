@@ -0,0 +1,188 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"demo/internal/petstore"
+)
+
+// etagMeta is the object metadata key holding the writer's ETag. S3 computes its own ETag,
+// which for multipart uploads is not a digest of the content, so the one BlobInfo carries
+// travels as x-amz-meta-etag.
+const etagMeta = "Etag"
+
+// defaultPartSize is the part size minio-go uses when none is configured.
+const defaultPartSize = 16 << 20
+
+// S3Options configures an S3Store.
+type S3Options struct {
+	// Endpoint is the service URL, such as https://s3.eu-central-1.amazonaws.com or
+	// http://localhost:9000 for MinIO; its scheme decides whether TLS is used.
+	Endpoint string
+	Bucket   string
+	Region   string
+	// AccessKeyID and SecretAccessKey sign requests. When both are empty, credentials come
+	// from the AWS_* environment variables or the instance's IAM role.
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix is prepended to every key, so several deployments can share a bucket.
+	Prefix string
+	// PresignTTL is how long URLs from PresignGet stay valid.
+	PresignTTL time.Duration
+	// PartSize is the size of each part of a multipart upload; bodies that fit in one part
+	// are uploaded with a single request. Zero means 16MiB.
+	PartSize uint64
+}
+
+// S3Store keeps blobs as objects in an S3-compatible bucket, which every instance can
+// reach, and can presign downloads so clients fetch large blobs from the store directly.
+type S3Store struct {
+	client *minio.Client
+	opts   S3Options
+}
+
+// NewS3Store connects to the bucket, which must already exist.
+func NewS3Store(ctx context.Context, opts S3Options) (*S3Store, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", opts.Endpoint)
+	}
+	creds := credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, "")
+	if opts.AccessKeyID == "" && opts.SecretAccessKey == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  creds,
+		Secure: endpoint.Scheme == "https",
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	exists, err := client.BucketExists(ctx, opts.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check S3 bucket: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("S3 bucket %q does not exist", opts.Bucket)
+	}
+	return &S3Store{client: client, opts: opts}, nil
+}
+
+// Put implements petstore.BlobStore. Bodies that fit in one part are uploaded with a
+// single request; larger ones are streamed as a multipart upload in parts of
+// opts.PartSize, so they are never held in memory whole. Either way S3 makes the object
+// visible only once the upload completes.
+func (s *S3Store) Put(ctx context.Context, key string, info petstore.BlobInfo, body io.Reader) error {
+	opts := minio.PutObjectOptions{
+		ContentType:  info.ContentType,
+		UserMetadata: map[string]string{etagMeta: info.ETag},
+		PartSize:     s.opts.PartSize,
+	}
+	head := make([]byte, s.partSize())
+	n, err := io.ReadFull(body, head)
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		_, err = s.client.PutObject(ctx, s.opts.Bucket, s.key(key), bytes.NewReader(head[:n]), int64(n), opts)
+	case err == nil:
+		_, err = s.client.PutObject(ctx, s.opts.Bucket, s.key(key), io.MultiReader(bytes.NewReader(head), body), -1, opts)
+	default:
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload blob: %w", err)
+	}
+	return nil
+}
+
+// Get implements petstore.BlobStore.
+func (s *S3Store) Get(ctx context.Context, key string) (petstore.BlobInfo, io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.opts.Bucket, s.key(key), minio.GetObjectOptions{})
+	if err != nil {
+		return petstore.BlobInfo{}, nil, fmt.Errorf("failed to fetch blob: %w", err)
+	}
+	// GetObject is lazy; Stat sends the request and reports a missing object.
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return petstore.BlobInfo{}, nil, s3Error("fetch", err)
+	}
+	return blobInfo(stat), obj, nil
+}
+
+// Delete implements petstore.BlobStore. S3 deletes of missing objects succeed, so the
+// object is looked up first to report ErrBlobNotFound.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if _, err := s.client.StatObject(ctx, s.opts.Bucket, s.key(key), minio.StatObjectOptions{}); err != nil {
+		return s3Error("delete", err)
+	}
+	if err := s.client.RemoveObject(ctx, s.opts.Bucket, s.key(key), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// PresignGet implements petstore.BlobPresigner. The URL serves the object with the content
+// type it was stored with and stays valid for opts.PresignTTL.
+func (s *S3Store) PresignGet(ctx context.Context, key string) (string, error) {
+	stat, err := s.client.StatObject(ctx, s.opts.Bucket, s.key(key), minio.StatObjectOptions{})
+	if err != nil {
+		return "", s3Error("fetch", err)
+	}
+	params := url.Values{"response-content-type": {stat.ContentType}}
+	u, err := s.client.PresignedGetObject(ctx, s.opts.Bucket, s.key(key), s.opts.PresignTTL, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign blob URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// partSize is opts.PartSize, or the client's own default when that is zero.
+func (s *S3Store) partSize() uint64 {
+	if s.opts.PartSize == 0 {
+		return defaultPartSize
+	}
+	return s.opts.PartSize
+}
+
+func (s *S3Store) key(key string) string {
+	if s.opts.Prefix == "" {
+		return key
+	}
+	return strings.TrimSuffix(s.opts.Prefix, "/") + "/" + key
+}
+
+func blobInfo(stat minio.ObjectInfo) petstore.BlobInfo {
+	return petstore.BlobInfo{
+		ContentType: stat.ContentType,
+		ETag:        stat.UserMetadata[etagMeta],
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+	}
+}
+
+// s3Error maps a missing object to petstore.ErrBlobNotFound.
+func s3Error(op string, err error) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return petstore.ErrBlobNotFound
+	}
+	return fmt.Errorf("failed to %s blob: %w", op, err)
+}
+
+var (
+	_ petstore.BlobStore     = (*S3Store)(nil)
+	_ petstore.BlobPresigner = (*S3Store)(nil)
+)
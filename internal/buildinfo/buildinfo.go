@@ -0,0 +1,76 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X demo/internal/buildinfo.Version=v1.2.3 -X demo/internal/buildinfo.Commit=$(git rev-parse HEAD) -X demo/internal/buildinfo.Date=$(date -u +%FT%TZ)"
+//
+// Empty values fall back to the module and VCS information embedded by the Go toolchain.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info describes the running build.
+type Info struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	Date           string `json:"date"`
+	GoVersion      string `json:"go_version"`
+	ConfigChecksum string `json:"config_checksum,omitempty"`
+}
+
+// Get returns the build information, preferring ldflags values over debug.ReadBuildInfo.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	return info
+}
+
+// Handler serves Get as JSON, tagged with the effective configuration checksum.
+func Handler(configChecksum string) http.HandlerFunc {
+	info := Get()
+	info.ConfigChecksum = configChecksum
+	body, err := json.Marshal(info)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "failed to encode build info", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(body, '\n'))
+	}
+}
@@ -0,0 +1,90 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"demo/internal/config"
+)
+
+// writeFile creates dir/name, and any directories it needs, holding contents.
+func writeFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExplicitFile(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "petstore.yaml", "logging:\n  level: debug\n")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Logging.Level != "debug" || !slices.Equal(cfg.Files, []string{path}) {
+		t.Fatalf("Load: got level %q from %v, want debug from %s", cfg.Logging.Level, cfg.Files, path)
+	}
+}
+
+// TestLoadExplicitDir checks that a directory is searched for config.<ext>, in any format.
+func TestLoadExplicitDir(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.json", `{"logging": {"level": "warn"}}`)
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Logging.Level != "warn" || !slices.Equal(cfg.Files, []string{path}) {
+		t.Fatalf("Load: got level %q from %v, want warn from %s", cfg.Logging.Level, cfg.Files, path)
+	}
+}
+
+// TestLoadExplicitMissing checks that a path that was asked for must exist: Load never
+// falls back to the defaults for it.
+func TestLoadExplicitMissing(t *testing.T) {
+	dir := t.TempDir()
+	for name, path := range map[string]string{
+		"MissingFile":      filepath.Join(dir, "missing.yaml"),
+		"DirWithoutConfig": dir,
+		"MissingDirectory": filepath.Join(dir, "missing"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := config.Load(path); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+				t.Fatalf("Load(%s): got %v, want a read error", path, err)
+			}
+		})
+	}
+}
+
+// TestLoadSearchPath checks that without a path Load reads ./config.yaml, else
+// ./config/config.yaml, else runs on the defaults.
+func TestLoadSearchPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load without files: %v", err)
+	}
+	if cfg.Logging.Level != "info" || len(cfg.Files) != 0 {
+		t.Fatalf("Load without files: got level %q from %v, want the default info from none", cfg.Logging.Level, cfg.Files)
+	}
+
+	writeFile(t, dir, "config/config.yaml", "logging:\n  level: warn\n")
+	if cfg, err = config.Load(""); err != nil || cfg.Logging.Level != "warn" {
+		t.Fatalf("Load with ./config/config.yaml: got level %q, %v; want warn", cfg.Logging.Level, err)
+	}
+
+	writeFile(t, dir, "config.yaml", "logging:\n  level: error\n")
+	if cfg, err = config.Load(""); err != nil || cfg.Logging.Level != "error" {
+		t.Fatalf("Load with ./config.yaml too: got level %q, %v; want error from ./config.yaml", cfg.Logging.Level, err)
+	}
+}
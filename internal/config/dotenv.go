@@ -0,0 +1,144 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Dotenv support is for local development: variables from a .env file fill in what the
+// real environment leaves unset, and take part in Load like any other DEMO_* variable.
+const (
+	// DotenvFile is read from the working directory when present.
+	DotenvFile = ".env"
+	// DotenvFileEnv names an additional .env file, which must exist when set.
+	DotenvFileEnv = EnvPrefix + "_ENV_FILE"
+	// DeploymentEnv set to "production" disables dotenv loading entirely.
+	DeploymentEnv = EnvPrefix + "_ENV"
+)
+
+var (
+	dotenvMu sync.Mutex
+	// dotenvSet records the variables a .env file supplied, so a reload may replace them
+	// while variables from the real environment still win.
+	dotenvSet = map[string]bool{}
+)
+
+// loadDotenv applies ./.env and the file named by DEMO_ENV_FILE, in that order, unless
+// DEMO_ENV is production. Variables already present in the real environment are never
+// overridden.
+func loadDotenv() error {
+	if os.Getenv(DeploymentEnv) == "production" {
+		return nil
+	}
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+
+	if err := applyDotenv(DotenvFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if path := os.Getenv(DotenvFileEnv); path != "" {
+		if err := applyDotenv(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyDotenv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer f.Close()
+
+	vars, err := parseDotenv(f, path)
+	if err != nil {
+		return err
+	}
+	for _, kv := range vars {
+		if _, present := os.LookupEnv(kv[0]); present && !dotenvSet[kv[0]] {
+			continue
+		}
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		dotenvSet[kv[0]] = true
+	}
+	return nil
+}
+
+var dotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseDotenv reads NAME=value lines in file order. Blank lines and # comments are
+// skipped and an "export " prefix is allowed. Values may be single-quoted (taken
+// literally), double-quoted (with \n, \t, \", and \\ escapes), or bare, where a " #"
+// starts a trailing comment.
+func parseDotenv(r io.Reader, path string) ([][2]string, error) {
+	var vars [][2]string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !dotenvName.MatchString(name) {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, n)
+		}
+		value, err := dotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, n, name, err)
+		}
+		vars = append(vars, [2]string{name, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return vars, nil
+}
+
+func dotenvValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated single quote")
+		}
+		return raw[1 : end+1], nil
+	case strings.HasPrefix(raw, `"`):
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; {
+			case c == '"':
+				return b.String(), nil
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(raw[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double quote")
+	default:
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+}
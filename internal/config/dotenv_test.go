@@ -0,0 +1,112 @@
+package config_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"demo/internal/config"
+)
+
+// writeDotenv writes dir/.env and unsets what Load exports from it once t ends, since
+// loading a .env file sets process environment variables.
+func writeDotenv(t *testing.T, dir, contents string) {
+	t.Helper()
+	writeFile(t, dir, ".env", contents)
+	for _, line := range strings.Split(contents, "\n") {
+		name, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		if !ok || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if _, present := os.LookupEnv(name); !present {
+			t.Cleanup(func() { os.Unsetenv(name) })
+		}
+	}
+}
+
+// TestLoadPrecedence sets each key in one more layer than the last and checks that the
+// later layer wins: defaults < file < overlay < .env < environment.
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, dir, "config.yaml", `
+metrics: {path: /file}
+grpc: {address: ":9001"}
+server: {address: ":8001"}
+logging: {level: error}
+`)
+	writeFile(t, dir, "config.staging.yaml", `
+grpc: {address: ":9002"}
+server: {address: ":8002"}
+logging: {level: warn}
+`)
+	writeDotenv(t, dir, "DEMO_SERVER_ADDRESS=:8003\nDEMO_LOGGING_LEVEL=info\n")
+	t.Setenv(config.DeploymentEnv, "staging")
+	t.Setenv("DEMO_LOGGING_LEVEL", "debug")
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, tc := range []struct{ key, layer, got, want string }{
+		{"logging.format", "defaults", cfg.Logging.Format, "json"},
+		{"metrics.path", "file", cfg.Metrics.Path, "/file"},
+		{"grpc.address", "overlay", cfg.GRPC.Address, ":9002"},
+		{"server.address", ".env", cfg.Server.Address, ":8003"},
+		{"logging.level", "environment", cfg.Logging.Level, "debug"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %q, want %q from the %s", tc.key, tc.got, tc.want, tc.layer)
+		}
+	}
+}
+
+// TestLoadDotenvSyntax checks comments, quoting, and export prefixes in .env files.
+func TestLoadDotenvSyntax(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeDotenv(t, dir, `# local settings
+export DEMO_TELEMETRY_SERVICE_NAME='pets # not a comment'
+
+DEMO_TELEMETRY_SENTRY_ENVIRONMENT="dev\tlocal \"one\""
+DEMO_LOGIN_POST_LOGIN_URL=/home # trailing comment
+`)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, tc := range []struct{ key, got, want string }{
+		{"telemetry.service_name", cfg.Telemetry.ServiceName, "pets # not a comment"},
+		{"telemetry.sentry.environment", cfg.Telemetry.Sentry.Environment, "dev\tlocal \"one\""},
+		{"login.post_login_url", cfg.Login.PostLoginURL, "/home"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.key, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadDotenvIgnoredInProduction(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeDotenv(t, dir, "DEMO_LOGGING_LEVEL=debug\n")
+	t.Setenv(config.DeploymentEnv, "production")
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Logging.Level != "info" {
+		t.Fatalf("logging.level: got %q, want the default; .env must be ignored in production", cfg.Logging.Level)
+	}
+}
+
+func TestLoadDotenvMalformed(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeDotenv(t, dir, "DEMO_LOGGING_LEVEL=debug\nnot a variable\n")
+	if _, err := config.Load(""); err == nil || !strings.Contains(err.Error(), ".env:2: expected NAME=value") {
+		t.Fatalf("Load: got %v, want the bad line reported", err)
+	}
+}
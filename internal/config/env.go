@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes every environment variable Load reads; database.pool.max_conns is
+// DEMO_DATABASE_POOL_MAX_CONNS.
+const EnvPrefix = "DEMO"
+
+// FileEnv names the config file or directory when no path is passed on the command line.
+const FileEnv = EnvPrefix + "_CONFIG_FILE"
+
+// envReplacer maps a dotted key onto its environment variable suffix.
+var envReplacer = strings.NewReplacer(".", "_")
+
+// Keys returns every dotted mapstructure key in Config, derived from the struct so new
+// fields are picked up automatically. Structs are descended into; slices and maps are
+// single keys.
+func Keys() []string {
+	var keys []string
+	collectKeys("", reflect.TypeFor[Config](), &keys)
+	return keys
+}
+
+func collectKeys(prefix string, t reflect.Type, keys *[]string) {
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if ft := t.Field(i).Type; ft.Kind() == reflect.Struct {
+			collectKeys(name, ft, keys)
+			continue
+		}
+		*keys = append(*keys, name)
+	}
+}
+
+// EnvName returns the environment variable that sets key.
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(envReplacer.Replace(key))
+}
+
+// bindEnv binds every key explicitly. AutomaticEnv alone only consults the environment
+// for keys viper already knows from defaults or the file, so nested keys without a
+// default would otherwise silently ignore their variable.
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(envReplacer)
+	v.AutomaticEnv()
+	for _, key := range Keys() {
+		v.BindEnv(key)
+	}
+}
+
+// checkStrictEnv reports DEMO_* variables that match no key, which are almost always
+// typos such as DEMO_GOOGLE_OUATH_CLIENT_ID.
+func checkStrictEnv(p *problems) {
+	known := map[string]bool{FileEnv: true, DotenvFileEnv: true, DeploymentEnv: true}
+	for _, key := range Keys() {
+		known[EnvName(key)] = true
+	}
+	var unknown []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, EnvPrefix+"_") && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	for _, name := range unknown {
+		p.add(name, "does not match any configuration key (strict_env is set)")
+	}
+}
@@ -0,0 +1,71 @@
+package config_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"demo/internal/config"
+)
+
+// TestLoadNestedEnv checks that variables for nested keys land in the struct with no
+// config file at all, including keys that have no default.
+func TestLoadNestedEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("DEMO_DATABASE_POOL_MAX_CONNS", "7")
+	t.Setenv("DEMO_DATABASE_POOL_MIN_CONNS", "2")
+	t.Setenv("DEMO_SERVER_TIMEOUTS_SHUTDOWN", "9s")
+	t.Setenv("DEMO_SERVER_CORS_ALLOWED_ORIGINS", "https://a.example.com,https://b.example.com")
+	t.Setenv("DEMO_GOOGLE_OAUTH_CLIENT_ID", "client-id")
+	t.Setenv("DEMO_LOGIN_STATE_COOKIE_DOMAIN", "example.com")
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Files) != 0 {
+		t.Fatalf("Load read %v, want no files", cfg.Files)
+	}
+	for _, tc := range []struct {
+		key       string
+		got, want any
+	}{
+		{"database.pool.max_conns", cfg.Database.Pool.MaxConns, int32(7)},
+		{"database.pool.min_conns", cfg.Database.Pool.MinConns, int32(2)},
+		{"server.timeouts.shutdown", cfg.Server.Timeouts.Shutdown, 9 * time.Second},
+		{"google_oauth.client_id", cfg.GoogleOAuth.ClientID, "client-id"},
+		{"login.state_cookie.domain", cfg.Login.StateCookie.Domain, "example.com"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.key, tc.got, tc.want)
+		}
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !slices.Equal(cfg.Server.CORS.AllowedOrigins, want) {
+		t.Errorf("server.cors.allowed_origins: got %q, want %q", cfg.Server.CORS.AllowedOrigins, want)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := config.EnvName("database.pool.max_conns"); got != "DEMO_DATABASE_POOL_MAX_CONNS" {
+		t.Fatalf("EnvName: got %s", got)
+	}
+}
+
+// TestStrictEnv checks that strict_env turns a misspelled variable into a Load error
+// naming it, while the variables Load itself reads stay allowed.
+func TestStrictEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(config.DeploymentEnv, "development")
+	t.Setenv("DEMO_GOOGLE_OUATH_CLIENT_ID", "client-id")
+	if _, err := config.Load(""); err != nil {
+		t.Fatalf("Load without strict_env: %v", err)
+	}
+
+	t.Setenv("DEMO_STRICT_ENV", "true")
+	_, err := config.Load("")
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || verr.Problems[0].Key != "DEMO_GOOGLE_OUATH_CLIENT_ID" {
+		t.Fatalf("Load with strict_env: got %v, want DEMO_GOOGLE_OUATH_CLIENT_ID reported alone", err)
+	}
+}
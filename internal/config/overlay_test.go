@@ -0,0 +1,88 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"demo/internal/config"
+)
+
+const overlayBase = `
+logging: {level: info, format: text}
+server:
+  trusted_proxies: [10.0.0.0/8, 192.168.0.0/16]
+features: {strict_json: true}
+`
+
+func TestLoadOverlay(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", overlayBase)
+	overlay := writeFile(t, dir, "config.staging.yaml", `
+logging: {level: debug}
+server:
+  trusted_proxies: [172.16.0.0/12]
+features: {problem_json: true}
+`)
+	t.Setenv(config.DeploymentEnv, "staging")
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !slices.Equal(cfg.Files, []string{base, overlay}) {
+		t.Errorf("Files: got %v, want the base and the overlay", cfg.Files)
+	}
+	if cfg.Logging.Level != "debug" || cfg.Logging.Format != "text" {
+		t.Errorf("logging: got level %q and format %q, want debug from the overlay and text from the base", cfg.Logging.Level, cfg.Logging.Format)
+	}
+	if want := []string{"172.16.0.0/12"}; !slices.Equal(cfg.Server.TrustedProxies, want) {
+		t.Errorf("server.trusted_proxies: got %v, want the overlay's list %v alone", cfg.Server.TrustedProxies, want)
+	}
+	if !cfg.Features["strict_json"] || !cfg.Features["problem_json"] {
+		t.Errorf("features: got %v, want both maps merged", cfg.Features)
+	}
+}
+
+func TestLoadOverlayMissing(t *testing.T) {
+	base := writeFile(t, t.TempDir(), "config.yaml", overlayBase)
+	t.Setenv(config.DeploymentEnv, "staging")
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		t.Fatalf("Load without config.staging.yaml: %v", err)
+	}
+	if !slices.Equal(cfg.Files, []string{base}) || cfg.Logging.Level != "info" {
+		t.Fatalf("Load: got level %q from %v, want info from the base alone", cfg.Logging.Level, cfg.Files)
+	}
+}
+
+func TestLoadOverlayInvalidEnvName(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", overlayBase)
+	writeFile(t, dir, "secrets.yaml", "logging: {level: debug}\n")
+	for _, env := range []string{"../secrets", "staging/eu", "staging.eu", "staging eu"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(config.DeploymentEnv, env)
+			if _, err := config.Load(base); err == nil || !strings.Contains(err.Error(), config.DeploymentEnv) {
+				t.Fatalf("Load with %s=%q: got %v, want the name rejected", config.DeploymentEnv, env, err)
+			}
+		})
+	}
+}
+
+// TestLoadOverlayStrict checks that strict_env reports a misspelled key in an overlay.
+func TestLoadOverlayStrict(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", overlayBase+"strict_env: true\n")
+	overlay := writeFile(t, dir, "config.staging.yaml", "loging: {level: debug}\n")
+	t.Setenv(config.DeploymentEnv, "staging")
+
+	_, err := config.Load(base)
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || verr.Problems[0].Key != "loging.level" || !strings.Contains(verr.Problems[0].Message, filepath.Base(overlay)) {
+		t.Fatalf("Load: got %v, want loging.level in %s reported", err, overlay)
+	}
+}
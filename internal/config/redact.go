@@ -0,0 +1,167 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redacted returns a copy of c safe to log or serve: every secret is masked. Secrets are
+// recognized by their mapstructure key rather than listed, so a new field named like
+// *secret*, *password*, *key(s), *token, or *dsn is covered without further changes;
+// *_file keys hold paths and are left alone.
+func (c Config) Redacted() Config {
+	redactValue(reflect.ValueOf(&c).Elem(), "")
+	return c
+}
+
+// Settings returns c as nested maps keyed by mapstructure names, the same shape as
+// config.yaml, with durations rendered as strings. Call it on Redacted output before
+// logging or serving.
+func (c Config) Settings() map[string]any {
+	return settingsValue(reflect.ValueOf(c)).(map[string]any)
+}
+
+// isSecretKey reports whether the mapstructure key name holds a credential.
+func isSecretKey(name string) bool {
+	if strings.HasSuffix(name, "_file") {
+		return false
+	}
+	if strings.Contains(name, "secret") || strings.Contains(name, "password") || name == "proxy_url" {
+		return true
+	}
+	for _, suffix := range []string{"key", "keys", "token", "dsn"} {
+		if name == suffix || strings.HasSuffix(name, "_"+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue masks secrets in v in place. Slices are copied before being modified so the
+// original configuration is never touched.
+func redactValue(v reflect.Value, name string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i).Tag.Get("mapstructure")
+			if field == "" || field == "-" {
+				continue
+			}
+			redactValue(v.Field(i), field)
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return
+		}
+		elem := v.Type().Elem()
+		if elem.Kind() != reflect.Struct && !(elem.Kind() == reflect.String && isSecretKey(name)) {
+			return
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		for i := range copied.Len() {
+			redactValue(copied.Index(i), name)
+		}
+		v.Set(copied)
+	case reflect.Map:
+		// String maps such as database.params are checked entry by entry, and sections
+		// keyed by name such as http_clients field by field.
+		elem := v.Type().Elem().Kind()
+		if v.Len() == 0 || (elem != reflect.String && elem != reflect.Struct) {
+			return
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			redactValue(value, iter.Key().String())
+			copied.SetMapIndex(iter.Key(), value)
+		}
+		v.Set(copied)
+	case reflect.String:
+		if !isSecretKey(name) || v.String() == "" {
+			return
+		}
+		if strings.HasSuffix(name, "dsn") || name == "proxy_url" {
+			v.SetString(maskDSN(v.String()))
+		} else {
+			v.SetString(mask(v.String()))
+		}
+	}
+}
+
+// mask hides s, keeping the last four characters of long values so operators can tell
+// which secret is configured.
+func mask(s string) string {
+	if len(s) < 12 {
+		return "***"
+	}
+	return "***" + s[len(s)-4:]
+}
+
+var dsnPasswordPattern = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// maskDSN hides the credential inside a connection string but keeps the host and
+// database visible: the password of a URL (or its user, when it has no password, as in
+// Sentry DSNs) or the password of a keyword/value string.
+func maskDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return dsnPasswordPattern.ReplaceAllString(dsn, "${1}***")
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), mask(password))
+		} else {
+			u.User = url.User(mask(u.User.Username()))
+		}
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", "***")
+		u.RawQuery = q.Encode()
+	}
+	// String percent-encodes the mask's asterisks.
+	return strings.ReplaceAll(u.String(), "%2A", "*")
+}
+
+func settingsValue(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Type() == byteSizeType {
+		return ByteSize(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		out := make(map[string]any, t.NumField())
+		for i := range t.NumField() {
+			field := t.Field(i).Tag.Get("mapstructure")
+			if field == "" || field == "-" {
+				continue
+			}
+			out[field] = settingsValue(v.Field(i))
+		}
+		return out
+	case reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range v.Len() {
+			out[i] = settingsValue(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = settingsValue(iter.Value())
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
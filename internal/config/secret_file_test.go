@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeSecret(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSecretFile(t *testing.T) {
+	for _, tc := range []struct {
+		name, contents, inline string
+		want                   string
+		problem                string
+	}{
+		{"NoNewline", "s3cret", "", "s3cret", ""},
+		{"TrailingNewline", "s3cret\n", "", "s3cret", ""},
+		{"TrailingNewlines", "s3cret\n\n", "", "s3cret", ""},
+		{"CRLF", "s3cret\r\n", "", "s3cret", ""},
+		{"InnerWhitespaceKept", " s3 cret\t\n", "", " s3 cret\t", ""},
+		{"SameInlineValue", "s3cret\n", "s3cret", "s3cret", ""},
+		{"ConflictingInlineValue", "s3cret\n", "other", "s3cret", "database.password: is set together with database.password_file"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p problems
+			value := tc.inline
+			if !readSecretFile(&p, "database.password", &value, writeSecret(t, tc.contents)) {
+				t.Fatal("readSecretFile: reported the file as not read")
+			}
+			if value != tc.want {
+				t.Fatalf("value: got %q, want %q", value, tc.want)
+			}
+			switch {
+			case tc.problem == "" && len(p) > 0:
+				t.Fatalf("problems: got %v, want none", p.err())
+			case tc.problem != "" && (len(p) != 1 || !strings.HasPrefix(p[0].Error(), tc.problem)):
+				t.Fatalf("problems: got %v, want %s", p.err(), tc.problem)
+			}
+		})
+	}
+
+	t.Run("NoPath", func(t *testing.T) {
+		var p problems
+		value := "inline"
+		if readSecretFile(&p, "database.password", &value, "") || value != "inline" || len(p) > 0 {
+			t.Fatalf("readSecretFile without a path: got %q, %v; want the inline value untouched", value, p.err())
+		}
+	})
+	t.Run("MissingFile", func(t *testing.T) {
+		var p problems
+		value := "inline"
+		path := filepath.Join(t.TempDir(), "missing")
+		if readSecretFile(&p, "database.password", &value, path) || value != "inline" {
+			t.Fatalf("readSecretFile of a missing file: got %q, want the inline value untouched", value)
+		}
+		if len(p) != 1 || p[0].Key != "database.password_file" || !strings.Contains(p[0].Message, path) {
+			t.Fatalf("problems: got %v, want database.password_file naming %s", p.err(), path)
+		}
+	})
+}
+
+// TestSessionKeysFile checks that sessions.keys_file holds one key per line, CRLF line
+// endings and blank lines included.
+func TestSessionKeysFile(t *testing.T) {
+	first, second := strings.Repeat("a", 32), strings.Repeat("b", 32)
+	c := Config{Sessions: SessionsConfig{KeysFile: writeSecret(t, first+"\r\n\r\n"+second+"\r\n")}}
+	var p problems
+	c.normalize(&p)
+	if len(p) > 0 || !slices.Equal(c.Sessions.Keys, []string{first, second}) {
+		t.Fatalf("sessions.keys: got %q, %v; want both keys", c.Sessions.Keys, p.err())
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// ByteSize is a size in bytes written either as a bare integer or with a unit: B, the
+// decimal kB/MB/GB (powers of 1000), or the binary KiB/MiB/GiB (powers of 1024). Units are
+// case-insensitive, so "512kb" is 512000 and "1MiB" is 1048576.
+type ByteSize int64
+
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// ParseByteSize parses s as described on ByteSize.
+func ParseByteSize(s string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(s)
+	i := strings.IndexFunc(trimmed, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' })
+	if i < 0 {
+		i = len(trimmed)
+	}
+	number, unit := trimmed[:i], strings.ToLower(strings.TrimSpace(trimmed[i:]))
+	multiplier, ok := byteUnits[unit]
+	if !ok || number == "" {
+		return 0, fmt.Errorf("invalid byte size %q: want an integer with an optional unit such as 512kB or 1MiB", s)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+	}
+	size := n * float64(multiplier)
+	if size != math.Trunc(size) || size > math.MaxInt64 || size < math.MinInt64 {
+		return 0, fmt.Errorf("invalid byte size %q: not a whole number of bytes", s)
+	}
+	return ByteSize(size), nil
+}
+
+// String formats b with the largest binary unit that divides it exactly.
+func (b ByteSize) String() string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if b != 0 && int64(b)%unit.size == 0 {
+			return strconv.FormatInt(int64(b)/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// FileMode is a unix permission written in octal, such as "0660". YAML reads an unquoted
+// 0660 as the number 432, which decodes back to "0660" rather than to "432".
+type FileMode string
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	byteSizeType = reflect.TypeFor[ByteSize]()
+	fileModeType = reflect.TypeFor[FileMode]()
+)
+
+// decodeHook converts config values into durations, byte sizes, and file modes, and
+// splits comma-separated strings into lists. Durations accept Go duration strings ("30s",
+// "5m") and, for compatibility with keys that used to be plain seconds such as
+// login.state_cookie.max_age, bare numbers meaning seconds. Failures surface from
+// Unmarshal with the offending key.
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		func(from, to reflect.Type, data any) (any, error) {
+			switch {
+			case to == durationType && from != durationType:
+				return toDuration(data)
+			case to == byteSizeType && from != byteSizeType:
+				return toByteSize(data)
+			case to == fileModeType && from != fileModeType:
+				return toFileMode(data)
+			}
+			return data, nil
+		},
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+func toDuration(data any) (any, error) {
+	switch v := data.(type) {
+	case string:
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: want a number of seconds or a value such as 30s or 5m", v)
+		}
+		return d, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return time.Duration(reflect.ValueOf(v).Convert(reflect.TypeFor[int64]()).Int()) * time.Second, nil
+	case float32, float64:
+		return time.Duration(reflect.ValueOf(v).Float() * float64(time.Second)), nil
+	}
+	return data, nil
+}
+
+func toByteSize(data any) (any, error) {
+	switch v := data.(type) {
+	case string:
+		return ParseByteSize(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return ByteSize(reflect.ValueOf(v).Convert(reflect.TypeFor[int64]()).Int()), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("invalid byte size %v: not a whole number of bytes", v)
+		}
+		return ByteSize(v), nil
+	}
+	return data, nil
+}
+
+// toFileMode writes numbers, which YAML parsed from an octal literal, back in octal.
+func toFileMode(data any) (any, error) {
+	switch v := data.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return FileMode("0" + strconv.FormatInt(reflect.ValueOf(v).Convert(reflect.TypeFor[int64]()).Int(), 8)), nil
+	case float64:
+		if v != math.Trunc(v) || v < 0 {
+			return nil, fmt.Errorf("invalid file mode %v: want an octal permission such as 0660", v)
+		}
+		return FileMode("0" + strconv.FormatInt(int64(v), 8)), nil
+	}
+	return data, nil
+}
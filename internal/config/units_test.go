@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+type decoded struct {
+	Duration time.Duration `mapstructure:"duration"`
+	Size     ByteSize      `mapstructure:"size"`
+	Mode     FileMode      `mapstructure:"mode"`
+	List     []string      `mapstructure:"list"`
+}
+
+// decode runs input through decodeHook the way Load's Unmarshal does.
+func decode(input map[string]any) (decoded, error) {
+	var out decoded
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       decodeHook(),
+		WeaklyTypedInput: true,
+		Result:           &out,
+	})
+	if err != nil {
+		return out, err
+	}
+	return out, decoder.Decode(input)
+}
+
+func TestDecodeHook(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		key   string
+		value any
+		want  decoded
+	}{
+		{"DurationString", "duration", "1m30s", decoded{Duration: 90 * time.Second}},
+		{"DurationPadded", "duration", " 5m ", decoded{Duration: 5 * time.Minute}},
+		{"DurationSecondsString", "duration", "1.5", decoded{Duration: 1500 * time.Millisecond}},
+		{"DurationSecondsInt", "duration", 90, decoded{Duration: 90 * time.Second}},
+		{"DurationSecondsFloat", "duration", 2.5, decoded{Duration: 2500 * time.Millisecond}},
+		{"SizeBinary", "size", "1MiB", decoded{Size: 1 << 20}},
+		{"SizeDecimal", "size", "512kb", decoded{Size: 512000}},
+		{"SizeFraction", "size", "1.5KiB", decoded{Size: 1536}},
+		{"SizeInt", "size", 2048, decoded{Size: 2048}},
+		{"SizeFloat", "size", 1024.0, decoded{Size: 1024}},
+		{"ModeString", "mode", "0660", decoded{Mode: "0660"}},
+		{"ModeYAMLOctal", "mode", 0o660, decoded{Mode: "0660"}},
+		{"ModeYAMLOctalFloat", "mode", float64(0o755), decoded{Mode: "0755"}},
+		{"CommaList", "list", "gzip,zstd", decoded{List: []string{"gzip", "zstd"}}},
+		{"List", "list", []any{"gzip", "zstd"}, decoded{List: []string{"gzip", "zstd"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decode(map[string]any{tc.key: tc.value})
+			if err != nil {
+				t.Fatalf("decode %v: %v", tc.value, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("decode %v: got %+v, want %+v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHookRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		key     string
+		value   any
+		message string
+	}{
+		{"Duration", "duration", "soon", `invalid duration "soon"`},
+		{"DurationUnit", "duration", "5 fortnights", `invalid duration "5 fortnights"`},
+		{"SizeUnit", "size", "12 parsecs", `invalid byte size "12 parsecs"`},
+		{"SizeFractionalBytes", "size", "1.5", "not a whole number of bytes"},
+		{"SizeFractionalFloat", "size", 1.5, "not a whole number of bytes"},
+		{"SizeEmpty", "size", "MiB", `invalid byte size "MiB"`},
+		{"ModeFraction", "mode", 1.5, "invalid file mode 1.5"},
+		{"ModeNegative", "mode", -1.0, "invalid file mode -1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decode(map[string]any{tc.key: tc.value}); err == nil || !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("decode %v: got %v, want an error containing %q", tc.value, err, tc.message)
+			}
+		})
+	}
+}
+
+// TestLoadDecodes checks the hook through Load: an unquoted YAML octal mode keeps its
+// meaning, and a bad value fails Load naming its key.
+func TestLoadDecodes(t *testing.T) {
+	write := func(contents string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := Load(write("server:\n  socket_mode: 0600\n  max_body_bytes: 64KiB\n  timeouts:\n    read: 15\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if mode, err := cfg.Server.SocketFileMode(); err != nil || mode != 0o600 {
+		t.Errorf("server.socket_mode: got %v, %v; want 0600", mode, err)
+	}
+	if cfg.Server.MaxBodyBytes != 64<<10 || cfg.Server.Timeouts.Read != 15*time.Second {
+		t.Errorf("server: got max_body_bytes %v and timeouts.read %v, want 64KiB and 15s", cfg.Server.MaxBodyBytes, cfg.Server.Timeouts.Read)
+	}
+
+	for _, tc := range []struct{ name, contents, key string }{
+		{"Duration", "server:\n  timeouts:\n    read: soon\n", "server.timeouts.read"},
+		{"ByteSize", "server:\n  max_body_bytes: lots\n", "server.max_body_bytes"},
+		{"DecimalMode", "server:\n  socket_mode: 660\n", "server.socket_mode"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Load(write(tc.contents)); err == nil || !strings.Contains(err.Error(), tc.key) {
+				t.Fatalf("Load: got %v, want an error naming %s", err, tc.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,729 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FieldError is a problem with a single configuration key, named by its dotted path.
+type FieldError struct {
+	Key     string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ValidationError lists every problem Validate found, in the order they were checked.
+type ValidationError struct {
+	Problems []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("%d invalid configuration value(s): %s", len(e.Problems), strings.Join(msgs, "; "))
+}
+
+// Unwrap exposes each problem to errors.Is and errors.As.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, p := range e.Problems {
+		errs[i] = p
+	}
+	return errs
+}
+
+// problems accumulates FieldErrors so Validate can report all of them at once.
+type problems []FieldError
+
+func (p *problems) add(key, format string, args ...any) {
+	*p = append(*p, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: p}
+}
+
+// Validate checks the configuration as Load leaves it and returns a *ValidationError
+// naming every offending key, or nil.
+func (c Config) Validate() error {
+	var p problems
+	c.validate(&p)
+	return p.err()
+}
+
+func (c Config) validate(p *problems) {
+	validateDurations(p, "", reflect.ValueOf(c))
+	c.Server.validate(p)
+	c.GoogleOAuth.validate(p, c.Server.BasePath)
+	c.GitHubOAuth.validate(p)
+	c.Login.validate(p, c.LoginEnabled())
+	c.Sessions.validate(p, c.LoginEnabled())
+	c.Security.validate(p)
+	c.Database.validate(p)
+	c.Cache.validate(p)
+	c.Exports.validate(p)
+	c.Photos.validate(p)
+	c.Purge.validate(p)
+	if (c.Photos.Enabled && c.Photos.Store == "s3") || (c.Exports.Enabled && c.Exports.Store == "s3") {
+		c.Storage.S3.validate(p)
+	}
+	c.GRPC.validate(p)
+	c.Pagination.validate(p)
+	validateHTTPClients(p, c.HTTPClients)
+
+	if c.Metrics.Enabled && !strings.HasPrefix(c.Metrics.Path, "/") {
+		p.add("metrics.path", "%q must start with /", c.Metrics.Path)
+	}
+	if c.Metrics.Address != "" {
+		validateAddress(p, "metrics.address", c.Metrics.Address, false)
+	}
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		p.add("telemetry.sample_ratio", "%v must be between 0 and 1", c.Telemetry.SampleRatio)
+	}
+	if r := c.Telemetry.Sentry.SampleRate; r < 0 || r > 1 {
+		p.add("telemetry.sentry.sample_rate", "%v must be between 0 and 1", r)
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "json", "text":
+	default:
+		p.add("logging.format", "%q must be json or text", c.Logging.Format)
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		p.add("logging.level", "%q must be debug, info, warn, or error", c.Logging.Level)
+	}
+	c.RateLimit.validate(p)
+}
+
+// validateDurations reports every negative time.Duration, including map entries, under
+// its mapstructure key path. Checks that need a strictly positive value live with their
+// section.
+func validateDurations(p *problems, prefix string, v reflect.Value) {
+	switch {
+	case v.Type() == durationType:
+		if v.Int() < 0 {
+			p.add(prefix, "must be non-negative")
+		}
+	case v.Kind() == reflect.Map && v.Type().Elem() == durationType:
+		for _, name := range sortedMapKeys(v) {
+			if v.MapIndex(reflect.ValueOf(name)).Int() < 0 {
+				p.add(fmt.Sprintf("%s[%q]", prefix, name), "must be non-negative")
+			}
+		}
+	case v.Kind() == reflect.Map && v.Type().Elem().Kind() == reflect.Struct:
+		// Sections keyed by name, such as http_clients.
+		for _, name := range sortedMapKeys(v) {
+			validateDurations(p, prefix+"."+name, v.MapIndex(reflect.ValueOf(name)))
+		}
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			name := t.Field(i).Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			validateDurations(p, name, v.Field(i))
+		}
+	}
+}
+
+func sortedMapKeys(v reflect.Value) []string {
+	keys := v.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.String()
+	}
+	slices.Sort(names)
+	return names
+}
+
+// validateAddress checks a listen address: host:port, or unix:///path when allowUnix.
+func validateAddress(p *problems, key, addr string, allowUnix bool) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		switch {
+		case !allowUnix:
+			p.add(key, "%q must be a TCP host:port; unix sockets are not supported here", addr)
+		case !strings.HasPrefix(path, "/"):
+			p.add(key, "%q must name an absolute socket path, e.g. unix:///run/petstore.sock", addr)
+		}
+		return
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		p.add(key, "%q must be host:port", addr)
+		return
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || (n == 0 && port != "0") {
+		p.add(key, "%q has an invalid port", addr)
+	}
+}
+
+// validateAbsoluteURL checks that raw is an absolute http(s) URL with a host.
+func validateAbsoluteURL(p *problems, key, raw string) {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		p.add(key, "%q must be an absolute http(s) URL", raw)
+	}
+}
+
+func (s ServerConfig) validate(p *problems) {
+	if s.Address != "" {
+		validateAddress(p, "server.address", s.Address, true)
+	}
+	if s.AdminAddress != "" {
+		validateAddress(p, "server.admin_address", s.AdminAddress, false)
+		if s.AdminAddress == s.Address {
+			p.add("server.admin_address", "%q must differ from server.address", s.AdminAddress)
+		}
+	}
+	if _, err := s.SocketFileMode(); err != nil {
+		p.add("server.socket_mode", "%q must be an octal permission such as 0660", s.SocketMode)
+	}
+	if u := s.ExternalURL; u != "" {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.Path != "" {
+			p.add("server.external_url", "%q must be an absolute http(s) URL without a path", u)
+		}
+	}
+	if s.Timeouts.Shutdown <= 0 {
+		p.add("server.timeouts.shutdown", "must be positive")
+	}
+	if s.MaxHeaderBytes < 0 || s.MaxHeaderBytes > math.MaxInt32 {
+		p.add("server.max_header_bytes", "must be between 0 and 2GiB")
+	}
+	if s.MaxBodyBytes < 0 {
+		p.add("server.max_body_bytes", "must be non-negative")
+	}
+	for route, n := range s.RouteBodyLimits {
+		if n < 0 {
+			p.add(fmt.Sprintf("server.route_body_limits[%q]", route), "must be non-negative")
+		}
+	}
+	for _, entry := range s.TrustedProxies {
+		if _, err := netip.ParsePrefix(entry); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(entry); err != nil {
+			p.add("server.trusted_proxies", "%q is not an IP or CIDR", entry)
+		}
+	}
+
+	if c := s.Compression; c.Enabled {
+		if c.MinSize < 0 || c.MinSize > math.MaxInt32 {
+			p.add("server.compression.min_size", "must be between 0 and 2GiB")
+		}
+		if len(c.Encodings) == 0 {
+			p.add("server.compression.encodings", "must list at least one of gzip, zstd")
+		}
+		for _, enc := range c.Encodings {
+			switch strings.ToLower(enc) {
+			case "gzip", "zstd":
+			default:
+				p.add("server.compression.encodings", "unsupported encoding %q (want gzip or zstd)", enc)
+			}
+		}
+	}
+
+	if c := s.CORS; c.Enabled {
+		if len(c.AllowedOrigins) == 0 {
+			p.add("server.cors.allowed_origins", "must list at least one origin when enabled")
+		}
+		for _, origin := range c.AllowedOrigins {
+			if origin == "*" && c.AllowCredentials {
+				p.add("server.cors.allowed_origins", `"*" cannot be combined with allow_credentials`)
+			}
+			if strings.Count(origin, "*") > 1 {
+				p.add("server.cors.allowed_origins", "entry %q may contain at most one wildcard", origin)
+			}
+		}
+		if len(c.AllowedMethods) == 0 {
+			p.add("server.cors.allowed_methods", "must list at least one method when enabled")
+		}
+	}
+
+	tls := s.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		p.add("server.tls", "cert_file and key_file must be set together")
+	} else if tls.Enabled && tls.CertFile == "" {
+		p.add("server.tls", "cert_file and key_file are required when enabled")
+	}
+	if tls.Enabled && tls.RedirectHTTPFrom != "" {
+		validateAddress(p, "server.tls.redirect_http_from", tls.RedirectHTTPFrom, false)
+		if tls.RedirectHTTPFrom == s.Address {
+			p.add("server.tls.redirect_http_from", "%q must differ from server.address", tls.RedirectHTTPFrom)
+		}
+	}
+
+	if acme := s.ACME; acme.Enabled {
+		if tls.Enabled || tls.CertFile != "" || tls.KeyFile != "" {
+			p.add("server.acme.enabled", "cannot be combined with static server.tls certificates")
+		}
+		if len(acme.Hostnames) == 0 {
+			p.add("server.acme.hostnames", "must list at least one host")
+		}
+		if acme.CacheDir == "" {
+			p.add("server.acme.cache_dir", "is required")
+		}
+		if acme.HTTPAddress == "" || acme.HTTPAddress == s.Address {
+			p.add("server.acme.http_address", "%q must be set and differ from server.address", acme.HTTPAddress)
+		} else {
+			validateAddress(p, "server.acme.http_address", acme.HTTPAddress, false)
+		}
+	}
+}
+
+// validate checks the authorization request parameters, google_oauth.mode, and, when
+// enabled, the client credentials and redirect URL.
+func (c GoogleOAuthConfig) validate(p *problems, basePath string) {
+	if c.IssuerURL != "" {
+		validateAbsoluteURL(p, "google_oauth.issuer_url", c.IssuerURL)
+	}
+	if c.HTTP.Timeout <= 0 {
+		p.add("google_oauth.http.timeout", "must be positive")
+	}
+	if c.HTTP.ConnectTimeout <= 0 {
+		p.add("google_oauth.http.connect_timeout", "must be positive")
+	}
+	if c.Prompt != "" {
+		values := strings.Fields(c.Prompt)
+		for _, value := range values {
+			switch {
+			case value == "consent", value == "select_account":
+			case value == "none" && len(values) == 1:
+			default:
+				p.add("google_oauth.prompt", "%q must be none, or consent and/or select_account", c.Prompt)
+			}
+		}
+	}
+	switch c.AccessType {
+	case "online", "offline":
+	default:
+		p.add("google_oauth.access_type", "%q must be online or offline", c.AccessType)
+	}
+
+	switch c.Mode {
+	case "google":
+		if c.Enabled {
+			if c.ClientID == "" {
+				p.add("google_oauth.client_id", "is required when google_oauth.enabled is set")
+			}
+			if c.ClientSecret == "" {
+				p.add("google_oauth.client_secret", "is required when google_oauth.enabled is set")
+			}
+			validateAbsoluteURL(p, "google_oauth.redirect_url", c.RedirectURL)
+		}
+	case "mock":
+		if !c.AllowMock {
+			p.add("google_oauth.mode", "mock requires google_oauth.allow_mock; never enable it in production")
+		}
+		if c.IssuerURL != "" {
+			p.add("google_oauth.issuer_url", "cannot be combined with google_oauth.mode mock")
+		}
+		parsed, err := url.Parse(c.RedirectURL)
+		if err != nil || parsed.Host == "" || parsed.Path != basePath+mockCallbackSuffix {
+			p.add("google_oauth.redirect_url", "%q must be an absolute URL ending in %s in mock mode", c.RedirectURL, basePath+mockCallbackSuffix)
+		}
+	default:
+		p.add("google_oauth.mode", "%q must be google or mock", c.Mode)
+	}
+}
+
+func (c GitHubOAuthConfig) validate(p *problems) {
+	if !c.Enabled {
+		return
+	}
+	if c.ClientID == "" {
+		p.add("github_oauth.client_id", "is required when github_oauth.enabled is set")
+	}
+	if c.ClientSecret == "" {
+		p.add("github_oauth.client_secret", "is required when github_oauth.enabled is set")
+	}
+	validateAbsoluteURL(p, "github_oauth.redirect_url", c.RedirectURL)
+}
+
+// State cookie lifetimes outside this range either expire before a user can finish the
+// provider's consent screen or leave replayable state around for too long.
+const (
+	minStateMaxAge = time.Minute
+	maxStateMaxAge = time.Hour
+)
+
+func (c LoginConfig) validate(p *problems, loginEnabled bool) {
+	cookie := c.StateCookie
+	if cookie.Name == "" {
+		p.add("login.state_cookie.name", "is required")
+	}
+	if cookie.MaxAge < minStateMaxAge || cookie.MaxAge > maxStateMaxAge {
+		p.add("login.state_cookie.max_age", "%s must be between %s and %s", cookie.MaxAge, minStateMaxAge, maxStateMaxAge)
+	}
+	validateSameSite(p, "login.state_cookie.same_site", cookie.SameSite, cookie.Secure)
+	if strings.HasPrefix(cookie.Name, "__Host-") {
+		if !cookie.Secure {
+			p.add("login.state_cookie.secure", "is required by the __Host- name prefix")
+		}
+		if cookie.Path != "/" {
+			p.add("login.state_cookie.path", "%q must be \"/\" with the __Host- name prefix", cookie.Path)
+		}
+		if cookie.Domain != "" {
+			p.add("login.state_cookie.domain", "must be empty with the __Host- name prefix")
+		}
+	}
+	if u := c.ErrorRedirectURL; u != "" {
+		parsed, err := url.Parse(u)
+		if err != nil || (!parsed.IsAbs() && !strings.HasPrefix(u, "/")) || (parsed.IsAbs() && parsed.Scheme != "http" && parsed.Scheme != "https") {
+			p.add("login.error_redirect_url", "%q must be an absolute http(s) URL or a path", u)
+		}
+	}
+	switch c.StateStore {
+	case "cookie", "memory", "postgres":
+	default:
+		p.add("login.state_store", "%q must be cookie, memory, or postgres", c.StateStore)
+	}
+	if loginEnabled && len(c.TokenEncryptionKey) < 32 {
+		p.add("login.token_encryption_key", "must be at least 32 bytes when a login provider is enabled")
+	}
+}
+
+func validateSameSite(p *problems, key, sameSite string, secure bool) {
+	switch strings.ToLower(sameSite) {
+	case "lax", "strict":
+	case "none":
+		if !secure {
+			p.add(key, "none requires secure")
+		}
+	default:
+		p.add(key, "%q must be lax, strict, or none", sameSite)
+	}
+}
+
+// validate checks the cookie attributes; keys are only required once a login flow can
+// issue sessions.
+func (c SessionsConfig) validate(p *problems, loginEnabled bool) {
+	if c.CookieName == "" {
+		p.add("sessions.cookie_name", "is required")
+	}
+	if c.Lifetime <= 0 {
+		p.add("sessions.lifetime", "must be positive")
+	}
+	if loginEnabled && len(c.Keys) == 0 {
+		p.add("sessions.keys", "must contain at least one key when a login provider is enabled")
+	}
+	switch c.Store {
+	case "cookie", "memory", "postgres":
+	default:
+		p.add("sessions.store", "%q must be cookie, memory, or postgres", c.Store)
+	}
+	for i, key := range c.Keys {
+		if len(key) < 32 {
+			p.add(fmt.Sprintf("sessions.keys[%d]", i), "must be at least 32 bytes")
+		}
+	}
+	validateSameSite(p, "sessions.same_site", c.SameSite, c.Secure)
+}
+
+func (c SecurityConfig) validate(p *problems) {
+	names := make(map[string]bool, len(c.APITokens))
+	for i, t := range c.APITokens {
+		key := fmt.Sprintf("security.api_tokens[%d]", i)
+		if t.Name == "" {
+			p.add(key+".name", "is required")
+		} else if names[t.Name] {
+			p.add(key+".name", "%q is duplicated", t.Name)
+		}
+		names[t.Name] = true
+		if len(t.Token) < 16 {
+			p.add(key+".token", "must be at least 16 characters")
+		}
+		if t.Role != "" && !validRole(t.Role) {
+			p.add(key+".role", "%q must be viewer, editor, or admin", t.Role)
+		}
+		if t.Org != strings.TrimSpace(t.Org) {
+			p.add(key+".org", "%q must not have surrounding whitespace", t.Org)
+		}
+	}
+	if c.ReadRole != "" && !validRole(c.ReadRole) {
+		p.add("security.read_role", "%q must be empty, viewer, editor, or admin", c.ReadRole)
+	}
+	if l := c.AuthRateLimit; l.Enabled {
+		if l.RPS <= 0 {
+			p.add("security.auth_rate_limit.rps", "must be positive")
+		}
+		if l.Burst <= 0 {
+			p.add("security.auth_rate_limit.burst", "must be positive")
+		}
+		if l.LockoutThreshold < 0 {
+			p.add("security.auth_rate_limit.lockout_threshold", "must be non-negative")
+		}
+		if l.LockoutThreshold > 0 && l.LockoutWindow <= 0 {
+			p.add("security.auth_rate_limit.lockout_window", "must be positive when lockout_threshold is set")
+		}
+		if l.LockoutThreshold > 0 && l.LockoutDuration <= 0 {
+			p.add("security.auth_rate_limit.lockout_duration", "must be positive when lockout_threshold is set")
+		}
+	}
+}
+
+func validRole(role string) bool {
+	switch role {
+	case "viewer", "editor", "admin":
+		return true
+	}
+	return false
+}
+
+func (d DatabaseConfig) validate(p *problems) {
+	if d.DSN != "" {
+		if _, err := pgxpool.ParseConfig(d.DSN); err != nil {
+			p.add("database.dsn", "does not parse: %v", err)
+		}
+	}
+	if d.ReadDSN != "" {
+		if _, err := pgxpool.ParseConfig(d.ReadDSN); err != nil {
+			p.add("database.read_dsn", "does not parse: %v", err)
+		}
+	}
+	if d.Port < 0 || d.Port > 65535 {
+		p.add("database.port", "%d is out of range", d.Port)
+	}
+
+	switch d.TLS.Mode {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		p.add("database.tls.mode", "unknown mode %q", d.TLS.Mode)
+	}
+	if (d.TLS.CertFile == "") != (d.TLS.KeyFile == "") {
+		p.add("database.tls", "cert_file and key_file must be set together")
+	}
+	if (d.TLS.Mode == "verify-ca" || d.TLS.Mode == "verify-full") && d.TLS.CAFile == "" {
+		p.add("database.tls.ca_file", "is required for mode %s", d.TLS.Mode)
+	}
+	if d.TLS.Mode == "" && (d.TLS.CAFile != "" || d.TLS.CertFile != "" || d.TLS.ServerName != "") {
+		p.add("database.tls.mode", "is required when TLS files or server_name are set")
+	}
+
+	pool := d.Pool
+	if pool.MaxConns < 0 {
+		p.add("database.pool.max_conns", "must be non-negative")
+	}
+	if pool.MinConns < 0 {
+		p.add("database.pool.min_conns", "must be non-negative")
+	}
+	if pool.MaxConns > 0 && pool.MinConns > pool.MaxConns {
+		p.add("database.pool.min_conns", "(%d) must not exceed max_conns (%d)", pool.MinConns, pool.MaxConns)
+	}
+
+	if d.Retry.Attempts < 1 {
+		p.add("database.connect_retry.attempts", "must be at least 1")
+	}
+	if d.Retry.Backoff < 1 {
+		p.add("database.connect_retry.backoff", "must be at least 1")
+	}
+
+	for op := range d.QueryTimeouts {
+		if !queryTimeoutOperations[op] {
+			p.add("database.query_timeouts", "unknown operation %q", op)
+		}
+	}
+	if !validQueryExecModes[d.QueryExecMode] {
+		p.add("database.query_exec_mode", "unknown mode %q", d.QueryExecMode)
+	}
+	if d.Breaker.Enabled {
+		if d.Breaker.FailureThreshold < 1 {
+			p.add("database.breaker.failure_threshold", "must be at least 1")
+		}
+		if d.Breaker.Cooldown <= 0 {
+			p.add("database.breaker.cooldown", "must be positive")
+		}
+	}
+}
+
+var validQueryExecModes = map[string]bool{
+	"":                true,
+	"cache_statement": true,
+	"cache_describe":  true,
+	"describe_exec":   true,
+	"exec":            true,
+	"simple_protocol": true,
+}
+
+var queryTimeoutOperations = map[string]bool{
+	"list":   true,
+	"get":    true,
+	"create": true,
+	"update": true,
+	"delete": true,
+}
+
+func (c CacheConfig) validate(p *problems) {
+	if r := c.Redis; r.Enabled {
+		if r.Address == "" {
+			p.add("cache.redis.address", "is required when enabled")
+		} else {
+			validateAddress(p, "cache.redis.address", r.Address, false)
+		}
+		if r.TTL <= 0 {
+			p.add("cache.redis.ttl", "must be positive")
+		}
+		if r.Channel == "" {
+			p.add("cache.redis.channel", "is required")
+		}
+	}
+	if !c.Enabled {
+		return
+	}
+	if c.Size <= 0 {
+		p.add("cache.size", "must be positive")
+	}
+	if c.TTL <= 0 {
+		p.add("cache.ttl", "must be positive")
+	}
+}
+
+func (c ExportsConfig) validate(p *problems) {
+	if !c.Enabled {
+		return
+	}
+	if c.Retention <= 0 {
+		p.add("exports.retention", "must be positive")
+	}
+	if c.PollInterval <= 0 {
+		p.add("exports.poll_interval", "must be positive")
+	}
+	if c.BatchSize <= 0 || c.BatchSize > 10000 {
+		p.add("exports.batch_size", "%d must be between 1 and 10000", c.BatchSize)
+	}
+	if c.Store != "file" && c.Store != "s3" {
+		p.add("exports.store", "%q must be file or s3", c.Store)
+	}
+}
+
+func (c PhotosConfig) validate(p *problems) {
+	if !c.Enabled {
+		return
+	}
+	if c.Store != "postgres" && c.Store != "file" && c.Store != "s3" {
+		p.add("photos.store", "%q must be postgres, file, or s3", c.Store)
+	}
+	if c.Redirect && c.Store != "s3" {
+		p.add("photos.redirect", "needs photos.store s3")
+	}
+	if c.MaxBytes <= 0 {
+		p.add("photos.max_bytes", "must be positive")
+	}
+	if c.CacheMaxAge < 0 {
+		p.add("photos.cache_max_age", "must not be negative")
+	}
+}
+
+func (c PurgeConfig) validate(p *problems) {
+	// The batch size applies to POST /admin/pets/purge whether or not the schedule runs.
+	if c.BatchSize <= 0 || c.BatchSize > 10000 {
+		p.add("purge.batch_size", "%d must be between 1 and 10000", c.BatchSize)
+	}
+	if !c.Enabled {
+		return
+	}
+	if c.Retention <= 0 {
+		p.add("purge.retention", "must be positive")
+	}
+	if c.Interval <= 0 {
+		p.add("purge.interval", "must be positive")
+	}
+}
+
+// validate runs only when a feature keeps its files in the bucket.
+func (c S3Config) validate(p *problems) {
+	if c.Endpoint == "" {
+		p.add("storage.s3.endpoint", "is required")
+	} else {
+		validateAbsoluteURL(p, "storage.s3.endpoint", c.Endpoint)
+	}
+	if c.Bucket == "" {
+		p.add("storage.s3.bucket", "is required")
+	}
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		p.add("storage.s3.access_key_id", "must be set together with storage.s3.secret_access_key")
+	}
+	// S3 refuses presigned URLs valid for longer than a week.
+	if c.PresignTTL <= 0 || c.PresignTTL > 7*24*time.Hour {
+		p.add("storage.s3.presign_ttl", "%s must be between 1s and 168h", c.PresignTTL)
+	}
+	// S3 parts other than the last must be at least 5MiB, and at most 5GiB.
+	if c.PartSize < 5<<20 || c.PartSize > 5<<30 {
+		p.add("storage.s3.part_size", "%d must be between 5MiB and 5GiB", c.PartSize)
+	}
+}
+
+func (c GRPCConfig) validate(p *problems) {
+	if !c.Enabled {
+		return
+	}
+	validateAddress(p, "grpc.address", c.Address, false)
+	if c.WatchInterval <= 0 {
+		p.add("grpc.watch_interval", "must be positive")
+	}
+}
+
+func (c PaginationConfig) validate(p *problems) {
+	if c.CursorKey != "" && len(c.CursorKey) < 32 {
+		p.add("pagination.cursor_key", "must be at least 32 bytes")
+	}
+	if c.CursorTTL <= 0 {
+		p.add("pagination.cursor_ttl", "must be positive")
+	}
+}
+
+func (r RateLimitConfig) validate(p *problems) {
+	if r.Read.RPS < 0 || r.Read.Burst < 0 {
+		p.add("rate_limit.read", "rps and burst must be non-negative")
+	}
+	if r.Write.RPS < 0 || r.Write.Burst < 0 {
+		p.add("rate_limit.write", "rps and burst must be non-negative")
+	}
+}
+
+func validateHTTPClients(p *problems, clients map[string]HTTPClientConfig) {
+	for _, name := range slices.Sorted(maps.Keys(clients)) {
+		c, prefix := clients[name], "http_clients."+name
+		if c.ProxyURL != "" {
+			u, err := url.Parse(c.ProxyURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+				p.add(prefix+".proxy_url", "%q must be an http, https, or socks5 URL", c.ProxyURL)
+			}
+		}
+		if c.MaxIdleConns < 0 {
+			p.add(prefix+".max_idle_conns", "must be non-negative")
+		}
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			p.add(prefix+".tls.cert_file", "must be set together with %s.tls.key_file", prefix)
+		}
+		switch c.TLS.MinVersion {
+		case "", "1.2", "1.3":
+		default:
+			p.add(prefix+".tls.min_version", "%q must be 1.2 or 1.3", c.TLS.MinVersion)
+		}
+	}
+}
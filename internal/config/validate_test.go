@@ -0,0 +1,446 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"demo/internal/config"
+)
+
+// validConfig loads the defaults from an empty config file, which validate.
+func validConfig(t *testing.T) config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load of an empty file: %v", err)
+	}
+	return cfg
+}
+
+// enableGoogle turns on Google login with valid credentials, so a case can break one.
+func enableGoogle(c *config.Config) {
+	c.GoogleOAuth.Enabled = true
+	c.GoogleOAuth.ClientID = "client-id"
+	c.GoogleOAuth.ClientSecret = "client-secret"
+	c.Login.TokenEncryptionKey = strings.Repeat("k", 32)
+	c.Sessions.Keys = []string{strings.Repeat("s", 32)}
+}
+
+// enableS3 keeps photos in a valid bucket, so a case can break one storage.s3 setting.
+func enableS3(c *config.Config) {
+	c.Photos.Enabled = true
+	c.Photos.Store = "s3"
+	c.Storage.S3.Endpoint = "https://s3.example.com"
+	c.Storage.S3.Bucket = "pets"
+}
+
+// TestValidate breaks one setting per case, starting from the defaults, and checks that
+// Validate names the key with the matching message. There is a case for every check.
+func TestValidate(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("Validate of the defaults: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		mutate  func(c *config.Config)
+		key     string
+		message string
+	}{
+		// Durations anywhere in the tree, including maps.
+		{"NegativeDuration", func(c *config.Config) { c.Server.Timeouts.Read = -time.Second },
+			"server.timeouts.read", "must be non-negative"},
+		{"NegativeDurationInMap", func(c *config.Config) { c.Server.RouteTimeouts = map[string]time.Duration{"/pets": -time.Second} },
+			`server.route_timeouts["/pets"]`, "must be non-negative"},
+		{"NegativeDurationInSection", func(c *config.Config) {
+			c.HTTPClients = map[string]config.HTTPClientConfig{"billing": {Timeout: -time.Second}}
+		}, "http_clients.billing.timeout", "must be non-negative"},
+
+		// Listen addresses and URLs, checked the same way wherever they appear.
+		{"AddressUnixNotAllowed", func(c *config.Config) { c.Metrics.Address = "unix:///run/metrics.sock" },
+			"metrics.address", "unix sockets are not supported here"},
+		{"AddressUnixRelative", func(c *config.Config) { c.Server.Address = "unix://petstore.sock" },
+			"server.address", "absolute socket path"},
+		{"AddressNoPort", func(c *config.Config) { c.Metrics.Address = "localhost" },
+			"metrics.address", "must be host:port"},
+		{"AddressBadPort", func(c *config.Config) { c.Metrics.Address = ":99999" },
+			"metrics.address", "invalid port"},
+		{"AbsoluteURL", func(c *config.Config) { c.GoogleOAuth.IssuerURL = "accounts.google.com" },
+			"google_oauth.issuer_url", "absolute http(s) URL"},
+
+		// server
+		{"AdminAddress", func(c *config.Config) { c.Server.AdminAddress = "localhost" },
+			"server.admin_address", "must be host:port"},
+		{"AdminAddressSameAsServer", func(c *config.Config) { c.Server.AdminAddress = c.Server.Address },
+			"server.admin_address", "must differ from server.address"},
+		{"SocketMode", func(c *config.Config) { c.Server.SocketMode = "0999" },
+			"server.socket_mode", "octal permission"},
+		{"ExternalURLWithPath", func(c *config.Config) { c.Server.ExternalURL = "https://pets.example.com/api" },
+			"server.external_url", "without a path"},
+		{"ShutdownTimeout", func(c *config.Config) { c.Server.Timeouts.Shutdown = 0 },
+			"server.timeouts.shutdown", "must be positive"},
+		{"MaxHeaderBytes", func(c *config.Config) { c.Server.MaxHeaderBytes = 3 << 30 },
+			"server.max_header_bytes", "between 0 and 2GiB"},
+		{"MaxBodyBytes", func(c *config.Config) { c.Server.MaxBodyBytes = -1 },
+			"server.max_body_bytes", "must be non-negative"},
+		{"RouteBodyLimit", func(c *config.Config) { c.Server.RouteBodyLimits = map[string]config.ByteSize{"/pets": -1} },
+			`server.route_body_limits["/pets"]`, "must be non-negative"},
+		{"TrustedProxy", func(c *config.Config) { c.Server.TrustedProxies = []string{"10.0.0.0/33"} },
+			"server.trusted_proxies", "not an IP or CIDR"},
+		{"CompressionMinSize", func(c *config.Config) { c.Server.Compression.MinSize = -1 },
+			"server.compression.min_size", "between 0 and 2GiB"},
+		{"CompressionNoEncodings", func(c *config.Config) { c.Server.Compression.Encodings = nil },
+			"server.compression.encodings", "at least one"},
+		{"CompressionEncoding", func(c *config.Config) { c.Server.Compression.Encodings = []string{"br"} },
+			"server.compression.encodings", `unsupported encoding "br"`},
+		{"CORSNoOrigins", func(c *config.Config) { c.Server.CORS.Enabled = true },
+			"server.cors.allowed_origins", "at least one origin"},
+		{"CORSWildcardWithCredentials", func(c *config.Config) {
+			c.Server.CORS.Enabled, c.Server.CORS.AllowedOrigins, c.Server.CORS.AllowCredentials = true, []string{"*"}, true
+		}, "server.cors.allowed_origins", "allow_credentials"},
+		{"CORSTwoWildcards", func(c *config.Config) {
+			c.Server.CORS.Enabled, c.Server.CORS.AllowedOrigins = true, []string{"https://*.*.example.com"}
+		}, "server.cors.allowed_origins", "at most one wildcard"},
+		{"CORSNoMethods", func(c *config.Config) {
+			c.Server.CORS.Enabled, c.Server.CORS.AllowedOrigins, c.Server.CORS.AllowedMethods = true, []string{"https://app.example.com"}, nil
+		}, "server.cors.allowed_methods", "at least one method"},
+		{"TLSHalfPair", func(c *config.Config) { c.Server.TLS.CertFile = "cert.pem" },
+			"server.tls", "set together"},
+		{"TLSEnabledWithoutFiles", func(c *config.Config) { c.Server.TLS.Enabled = true },
+			"server.tls", "required when enabled"},
+		{"TLSRedirectSameAsServer", func(c *config.Config) {
+			c.Server.TLS = config.ServerTLSConfig{Enabled: true, CertFile: "cert.pem", KeyFile: "key.pem", RedirectHTTPFrom: c.Server.Address}
+		}, "server.tls.redirect_http_from", "must differ from server.address"},
+		{"ACMEWithStaticCertificates", func(c *config.Config) {
+			c.Server.ACME.Enabled, c.Server.ACME.Hostnames = true, []string{"pets.example.com"}
+			c.Server.TLS.CertFile, c.Server.TLS.KeyFile = "cert.pem", "key.pem"
+		}, "server.acme.enabled", "static server.tls certificates"},
+		{"ACMENoHostnames", func(c *config.Config) { c.Server.ACME.Enabled = true },
+			"server.acme.hostnames", "at least one host"},
+		{"ACMENoCacheDir", func(c *config.Config) {
+			c.Server.ACME.Enabled, c.Server.ACME.Hostnames, c.Server.ACME.CacheDir = true, []string{"pets.example.com"}, ""
+		}, "server.acme.cache_dir", "is required"},
+		{"ACMEHTTPAddressSameAsServer", func(c *config.Config) {
+			c.Server.ACME.Enabled, c.Server.ACME.Hostnames, c.Server.ACME.HTTPAddress = true, []string{"pets.example.com"}, c.Server.Address
+		}, "server.acme.http_address", "must be set and differ"},
+
+		// google_oauth
+		{"GoogleHTTPTimeout", func(c *config.Config) { c.GoogleOAuth.HTTP.Timeout = 0 },
+			"google_oauth.http.timeout", "must be positive"},
+		{"GoogleConnectTimeout", func(c *config.Config) { c.GoogleOAuth.HTTP.ConnectTimeout = 0 },
+			"google_oauth.http.connect_timeout", "must be positive"},
+		{"GooglePrompt", func(c *config.Config) { c.GoogleOAuth.Prompt = "none consent" },
+			"google_oauth.prompt", "must be none, or consent"},
+		{"GoogleAccessType", func(c *config.Config) { c.GoogleOAuth.AccessType = "forever" },
+			"google_oauth.access_type", "online or offline"},
+		{"GoogleClientID", func(c *config.Config) { enableGoogle(c); c.GoogleOAuth.ClientID = "" },
+			"google_oauth.client_id", "is required"},
+		{"GoogleClientSecret", func(c *config.Config) { enableGoogle(c); c.GoogleOAuth.ClientSecret = "" },
+			"google_oauth.client_secret", "is required"},
+		{"GoogleRedirectURL", func(c *config.Config) { enableGoogle(c); c.GoogleOAuth.RedirectURL = "/auth/google/callback" },
+			"google_oauth.redirect_url", "absolute http(s) URL"},
+		{"MockWithoutAllowMock", func(c *config.Config) { c.GoogleOAuth.Mode = "mock" },
+			"google_oauth.mode", "requires google_oauth.allow_mock"},
+		{"MockWithIssuer", func(c *config.Config) {
+			c.GoogleOAuth.Mode, c.GoogleOAuth.AllowMock, c.GoogleOAuth.IssuerURL = "mock", true, "https://accounts.google.com"
+		}, "google_oauth.issuer_url", "cannot be combined"},
+		{"MockRedirectURL", func(c *config.Config) {
+			c.GoogleOAuth.Mode, c.GoogleOAuth.AllowMock, c.GoogleOAuth.RedirectURL = "mock", true, "http://localhost:8080/callback"
+		}, "google_oauth.redirect_url", "in mock mode"},
+		{"GoogleMode", func(c *config.Config) { c.GoogleOAuth.Mode = "saml" },
+			"google_oauth.mode", "google or mock"},
+
+		// github_oauth
+		{"GitHubClientID", func(c *config.Config) {
+			enableGoogle(c)
+			c.GitHubOAuth.Enabled, c.GitHubOAuth.ClientSecret = true, "secret"
+		}, "github_oauth.client_id", "is required"},
+		{"GitHubClientSecret", func(c *config.Config) { enableGoogle(c); c.GitHubOAuth.Enabled, c.GitHubOAuth.ClientID = true, "id" },
+			"github_oauth.client_secret", "is required"},
+		{"GitHubRedirectURL", func(c *config.Config) {
+			enableGoogle(c)
+			c.GitHubOAuth = config.GitHubOAuthConfig{Enabled: true, ClientID: "id", ClientSecret: "secret", RedirectURL: "github/callback"}
+		}, "github_oauth.redirect_url", "absolute http(s) URL"},
+
+		// login
+		{"StateCookieName", func(c *config.Config) { c.Login.StateCookie.Name = "" },
+			"login.state_cookie.name", "is required"},
+		{"StateCookieMaxAge", func(c *config.Config) { c.Login.StateCookie.MaxAge = 30 * time.Second },
+			"login.state_cookie.max_age", "must be between 1m0s and 1h0m0s"},
+		{"SameSiteNoneInsecure", func(c *config.Config) { c.Login.StateCookie.SameSite = "none" },
+			"login.state_cookie.same_site", "none requires secure"},
+		{"SameSite", func(c *config.Config) { c.Login.StateCookie.SameSite = "sometimes" },
+			"login.state_cookie.same_site", "lax, strict, or none"},
+		{"HostPrefixInsecure", func(c *config.Config) { c.Login.StateCookie.Name = "__Host-state" },
+			"login.state_cookie.secure", "__Host- name prefix"},
+		{"HostPrefixPath", func(c *config.Config) {
+			c.Login.StateCookie.Name, c.Login.StateCookie.Secure, c.Login.StateCookie.Path = "__Host-state", true, "/auth"
+		}, "login.state_cookie.path", "__Host- name prefix"},
+		{"HostPrefixDomain", func(c *config.Config) {
+			c.Login.StateCookie.Name, c.Login.StateCookie.Secure, c.Login.StateCookie.Domain = "__Host-state", true, "example.com"
+		}, "login.state_cookie.domain", "__Host- name prefix"},
+		{"ErrorRedirectURL", func(c *config.Config) { c.Login.ErrorRedirectURL = "javascript:alert(1)" },
+			"login.error_redirect_url", "http(s) URL or a path"},
+		{"StateStore", func(c *config.Config) { c.Login.StateStore = "redis" },
+			"login.state_store", "cookie, memory, or postgres"},
+		{"TokenEncryptionKey", func(c *config.Config) { enableGoogle(c); c.Login.TokenEncryptionKey = "short" },
+			"login.token_encryption_key", "at least 32 bytes"},
+
+		// sessions
+		{"SessionCookieName", func(c *config.Config) { c.Sessions.CookieName = "" },
+			"sessions.cookie_name", "is required"},
+		{"SessionLifetime", func(c *config.Config) { c.Sessions.Lifetime = 0 },
+			"sessions.lifetime", "must be positive"},
+		{"SessionKeysMissing", func(c *config.Config) { enableGoogle(c); c.Sessions.Keys = nil },
+			"sessions.keys", "at least one key"},
+		{"SessionStore", func(c *config.Config) { c.Sessions.Store = "redis" },
+			"sessions.store", "cookie, memory, or postgres"},
+		{"SessionKeyShort", func(c *config.Config) { c.Sessions.Keys = []string{"short"} },
+			"sessions.keys[0]", "at least 32 bytes"},
+		{"SessionSameSite", func(c *config.Config) { c.Sessions.SameSite = "none" },
+			"sessions.same_site", "none requires secure"},
+
+		// security
+		{"TokenName", func(c *config.Config) { c.Security.APITokens = []config.APITokenConfig{{Token: "token-0123456789"}} },
+			"security.api_tokens[0].name", "is required"},
+		{"TokenNameDuplicated", func(c *config.Config) {
+			c.Security.APITokens = []config.APITokenConfig{{Name: "ci", Token: "token-0123456789"}, {Name: "ci", Token: "token-9876543210"}}
+		}, "security.api_tokens[1].name", "is duplicated"},
+		{"TokenShort", func(c *config.Config) { c.Security.APITokens = []config.APITokenConfig{{Name: "ci", Token: "short"}} },
+			"security.api_tokens[0].token", "at least 16 characters"},
+		{"TokenRole", func(c *config.Config) {
+			c.Security.APITokens = []config.APITokenConfig{{Name: "ci", Token: "token-0123456789", Role: "root"}}
+		}, "security.api_tokens[0].role", "viewer, editor, or admin"},
+		{"TokenOrg", func(c *config.Config) {
+			c.Security.APITokens = []config.APITokenConfig{{Name: "ci", Token: "token-0123456789", Org: " acme"}}
+		}, "security.api_tokens[0].org", "surrounding whitespace"},
+		{"ReadRole", func(c *config.Config) { c.Security.ReadRole = "root" },
+			"security.read_role", "empty, viewer, editor, or admin"},
+		{"AuthRateLimitRPS", func(c *config.Config) { c.Security.AuthRateLimit.RPS = 0 },
+			"security.auth_rate_limit.rps", "must be positive"},
+		{"AuthRateLimitBurst", func(c *config.Config) { c.Security.AuthRateLimit.Burst = 0 },
+			"security.auth_rate_limit.burst", "must be positive"},
+		{"LockoutThreshold", func(c *config.Config) { c.Security.AuthRateLimit.LockoutThreshold = -1 },
+			"security.auth_rate_limit.lockout_threshold", "must be non-negative"},
+		{"LockoutWindow", func(c *config.Config) { c.Security.AuthRateLimit.LockoutWindow = 0 },
+			"security.auth_rate_limit.lockout_window", "when lockout_threshold is set"},
+		{"LockoutDuration", func(c *config.Config) { c.Security.AuthRateLimit.LockoutDuration = 0 },
+			"security.auth_rate_limit.lockout_duration", "when lockout_threshold is set"},
+
+		// database
+		{"DSN", func(c *config.Config) { c.Database.DSN = "postgres://u:p@localhost:notaport/db" },
+			"database.dsn", "does not parse"},
+		{"ReadDSN", func(c *config.Config) { c.Database.ReadDSN = "host=localhost port=x" },
+			"database.read_dsn", "does not parse"},
+		{"Port", func(c *config.Config) { c.Database.Port = 70000 },
+			"database.port", "out of range"},
+		{"TLSMode", func(c *config.Config) { c.Database.TLS.Mode = "prefer-ish" },
+			"database.tls.mode", "unknown mode"},
+		{"DatabaseTLSHalfPair", func(c *config.Config) { c.Database.TLS = config.DatabaseTLSConfig{Mode: "require", KeyFile: "key.pem"} },
+			"database.tls", "set together"},
+		{"DatabaseTLSNoCA", func(c *config.Config) { c.Database.TLS.Mode = "verify-full" },
+			"database.tls.ca_file", "is required for mode verify-full"},
+		{"DatabaseTLSNoMode", func(c *config.Config) { c.Database.TLS.ServerName = "db.internal" },
+			"database.tls.mode", "is required when TLS files"},
+		{"PoolMaxConns", func(c *config.Config) { c.Database.Pool.MaxConns = -1 },
+			"database.pool.max_conns", "must be non-negative"},
+		{"PoolMinConns", func(c *config.Config) { c.Database.Pool.MinConns = -1 },
+			"database.pool.min_conns", "must be non-negative"},
+		{"PoolMinAboveMax", func(c *config.Config) { c.Database.Pool.MinConns, c.Database.Pool.MaxConns = 5, 2 },
+			"database.pool.min_conns", "must not exceed max_conns"},
+		{"RetryAttempts", func(c *config.Config) { c.Database.Retry.Attempts = 0 },
+			"database.connect_retry.attempts", "at least 1"},
+		{"RetryBackoff", func(c *config.Config) { c.Database.Retry.Backoff = 0.5 },
+			"database.connect_retry.backoff", "at least 1"},
+		{"QueryTimeoutOperation", func(c *config.Config) { c.Database.QueryTimeouts = map[string]time.Duration{"scan": time.Second} },
+			"database.query_timeouts", `unknown operation "scan"`},
+		{"QueryExecMode", func(c *config.Config) { c.Database.QueryExecMode = "turbo" },
+			"database.query_exec_mode", "unknown mode"},
+		{"BreakerThreshold", func(c *config.Config) { c.Database.Breaker.Enabled, c.Database.Breaker.FailureThreshold = true, 0 },
+			"database.breaker.failure_threshold", "at least 1"},
+		{"BreakerCooldown", func(c *config.Config) { c.Database.Breaker.Enabled, c.Database.Breaker.Cooldown = true, 0 },
+			"database.breaker.cooldown", "must be positive"},
+
+		// cache
+		{"RedisAddressMissing", func(c *config.Config) { c.Cache.Redis.Enabled, c.Cache.Redis.Address = true, "" },
+			"cache.redis.address", "is required"},
+		{"RedisTTL", func(c *config.Config) { c.Cache.Redis.Enabled, c.Cache.Redis.TTL = true, 0 },
+			"cache.redis.ttl", "must be positive"},
+		{"RedisChannel", func(c *config.Config) { c.Cache.Redis.Enabled, c.Cache.Redis.Channel = true, "" },
+			"cache.redis.channel", "is required"},
+		{"CacheSize", func(c *config.Config) { c.Cache.Enabled, c.Cache.Size = true, 0 },
+			"cache.size", "must be positive"},
+		{"CacheTTL", func(c *config.Config) { c.Cache.Enabled, c.Cache.TTL = true, 0 },
+			"cache.ttl", "must be positive"},
+
+		// exports
+		{"ExportsRetention", func(c *config.Config) { c.Exports.Enabled, c.Exports.Retention = true, 0 },
+			"exports.retention", "must be positive"},
+		{"ExportsPollInterval", func(c *config.Config) { c.Exports.Enabled, c.Exports.PollInterval = true, 0 },
+			"exports.poll_interval", "must be positive"},
+		{"ExportsBatchSize", func(c *config.Config) { c.Exports.Enabled, c.Exports.BatchSize = true, 10001 },
+			"exports.batch_size", "between 1 and 10000"},
+		{"ExportsStore", func(c *config.Config) { c.Exports.Enabled, c.Exports.Store = true, "ftp" },
+			"exports.store", "file or s3"},
+
+		// photos
+		{"PhotosStore", func(c *config.Config) { c.Photos.Enabled, c.Photos.Store = true, "ftp" },
+			"photos.store", "postgres, file, or s3"},
+		{"PhotosRedirect", func(c *config.Config) { c.Photos.Enabled, c.Photos.Redirect = true, true },
+			"photos.redirect", "needs photos.store s3"},
+		{"PhotosMaxBytes", func(c *config.Config) { c.Photos.Enabled, c.Photos.MaxBytes = true, 0 },
+			"photos.max_bytes", "must be positive"},
+		{"PhotosCacheMaxAge", func(c *config.Config) { c.Photos.Enabled, c.Photos.CacheMaxAge = true, -time.Second },
+			"photos.cache_max_age", "must not be negative"},
+
+		// purge
+		{"PurgeBatchSize", func(c *config.Config) { c.Purge.BatchSize = 0 },
+			"purge.batch_size", "between 1 and 10000"},
+		{"PurgeRetention", func(c *config.Config) { c.Purge.Enabled, c.Purge.Retention = true, 0 },
+			"purge.retention", "must be positive"},
+		{"PurgeInterval", func(c *config.Config) { c.Purge.Enabled, c.Purge.Interval = true, 0 },
+			"purge.interval", "must be positive"},
+
+		// storage.s3, checked only while a feature uses it
+		{"S3EndpointMissing", func(c *config.Config) { enableS3(c); c.Storage.S3.Endpoint = "" },
+			"storage.s3.endpoint", "is required"},
+		{"S3Endpoint", func(c *config.Config) { enableS3(c); c.Storage.S3.Endpoint = "s3.example.com" },
+			"storage.s3.endpoint", "absolute http(s) URL"},
+		{"S3Bucket", func(c *config.Config) { enableS3(c); c.Storage.S3.Bucket = "" },
+			"storage.s3.bucket", "is required"},
+		{"S3HalfCredentials", func(c *config.Config) { enableS3(c); c.Storage.S3.AccessKeyID = "AKIA0123" },
+			"storage.s3.access_key_id", "together with storage.s3.secret_access_key"},
+		{"S3PresignTTL", func(c *config.Config) { enableS3(c); c.Storage.S3.PresignTTL = 8 * 24 * time.Hour },
+			"storage.s3.presign_ttl", "between 1s and 168h"},
+		{"S3PartSize", func(c *config.Config) { enableS3(c); c.Storage.S3.PartSize = 1 << 20 },
+			"storage.s3.part_size", "between 5MiB and 5GiB"},
+
+		// grpc and pagination
+		{"GRPCAddress", func(c *config.Config) { c.GRPC.Enabled, c.GRPC.Address = true, "9090" },
+			"grpc.address", "must be host:port"},
+		{"GRPCWatchInterval", func(c *config.Config) { c.GRPC.Enabled, c.GRPC.WatchInterval = true, 0 },
+			"grpc.watch_interval", "must be positive"},
+		{"CursorKey", func(c *config.Config) { c.Pagination.CursorKey = "short" },
+			"pagination.cursor_key", "at least 32 bytes"},
+		{"CursorTTL", func(c *config.Config) { c.Pagination.CursorTTL = 0 },
+			"pagination.cursor_ttl", "must be positive"},
+
+		// http_clients
+		{"ProxyURL", func(c *config.Config) {
+			c.HTTPClients = map[string]config.HTTPClientConfig{"billing": {ProxyURL: "ftp://proxy:21"}}
+		}, "http_clients.billing.proxy_url", "http, https, or socks5 URL"},
+		{"MaxIdleConns", func(c *config.Config) {
+			c.HTTPClients = map[string]config.HTTPClientConfig{"billing": {MaxIdleConns: -1}}
+		}, "http_clients.billing.max_idle_conns", "must be non-negative"},
+		{"ClientCertHalfPair", func(c *config.Config) {
+			c.HTTPClients = map[string]config.HTTPClientConfig{"billing": {TLS: config.HTTPClientTLSConfig{CertFile: "cert.pem"}}}
+		}, "http_clients.billing.tls.cert_file", "together with http_clients.billing.tls.key_file"},
+		{"ClientMinVersion", func(c *config.Config) {
+			c.HTTPClients = map[string]config.HTTPClientConfig{"billing": {TLS: config.HTTPClientTLSConfig{MinVersion: "1.1"}}}
+		}, "http_clients.billing.tls.min_version", "1.2 or 1.3"},
+
+		// metrics, telemetry, logging, rate_limit
+		{"MetricsPath", func(c *config.Config) { c.Metrics.Path = "metrics" },
+			"metrics.path", "must start with /"},
+		{"SampleRatio", func(c *config.Config) { c.Telemetry.SampleRatio = 1.5 },
+			"telemetry.sample_ratio", "between 0 and 1"},
+		{"SentrySampleRate", func(c *config.Config) { c.Telemetry.Sentry.SampleRate = -0.1 },
+			"telemetry.sentry.sample_rate", "between 0 and 1"},
+		{"LogFormat", func(c *config.Config) { c.Logging.Format = "xml" },
+			"logging.format", "json or text"},
+		{"LogLevel", func(c *config.Config) { c.Logging.Level = "trace" },
+			"logging.level", "debug, info, warn, or error"},
+		{"RateLimitRead", func(c *config.Config) { c.RateLimit.Read.RPS = -1 },
+			"rate_limit.read", "must be non-negative"},
+		{"RateLimitWrite", func(c *config.Config) { c.RateLimit.Write.Burst = -1 },
+			"rate_limit.write", "must be non-negative"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tc.mutate(&cfg)
+			err := cfg.Validate()
+			var verr *config.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate: got %v, want a *ValidationError", err)
+			}
+			for _, p := range verr.Problems {
+				if p.Key == tc.key && strings.Contains(p.Message, tc.message) {
+					return
+				}
+			}
+			t.Fatalf("Validate: got %v, want %s: ...%s...", err, tc.key, tc.message)
+		})
+	}
+}
+
+// TestValidateReportsEveryProblem checks that problems in different sections come back
+// together, each reachable with errors.As.
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Logging.Level = "trace"
+	cfg.Database.Port = -1
+	cfg.Server.Timeouts.Shutdown = 0
+
+	err := cfg.Validate()
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 3 {
+		t.Fatalf("Validate: got %v, want three problems", err)
+	}
+	if !strings.HasPrefix(err.Error(), "3 invalid configuration value(s): ") {
+		t.Fatalf("Error: got %q", err)
+	}
+	var field config.FieldError
+	if !errors.As(err, &field) || field.Key != "server.timeouts.shutdown" {
+		t.Fatalf("errors.As FieldError: got %+v, want the first problem, server.timeouts.shutdown", field)
+	}
+}
+
+// TestLoadMockProvider checks that google_oauth.mode mock only loads with allow_mock,
+// however the mode is set.
+func TestLoadMockProvider(t *testing.T) {
+	const base = `
+sessions:
+  keys: [test-session-key-0123456789abcdef]
+login:
+  token_encryption_key: test-token-key-0123456789abcdefgh
+google_oauth:
+  enabled: true
+  redirect_url: http://localhost:8080/auth/google/callback
+`
+	refused := func(t *testing.T, err error) {
+		t.Helper()
+		var verr *config.ValidationError
+		if !errors.As(err, &verr) || len(verr.Problems) != 1 || verr.Problems[0].Key != "google_oauth.mode" ||
+			!strings.Contains(verr.Problems[0].Message, "allow_mock") {
+			t.Fatalf("Load: got %v, want google_oauth.mode refused for lack of allow_mock", err)
+		}
+	}
+
+	t.Run("File", func(t *testing.T) {
+		_, err := config.Load(writeFile(t, t.TempDir(), "config.yaml", base+"  mode: mock\n"))
+		refused(t, err)
+	})
+	t.Run("Env", func(t *testing.T) {
+		t.Setenv("DEMO_GOOGLE_OAUTH_MODE", "mock")
+		_, err := config.Load(writeFile(t, t.TempDir(), "config.yaml", base+"  client_id: id\n  client_secret: secret\n"))
+		refused(t, err)
+	})
+	t.Run("AllowMockFalse", func(t *testing.T) {
+		_, err := config.Load(writeFile(t, t.TempDir(), "config.yaml", base+"  mode: mock\n  allow_mock: false\n"))
+		refused(t, err)
+	})
+	t.Run("AllowMock", func(t *testing.T) {
+		cfg, err := config.Load(writeFile(t, t.TempDir(), "config.yaml", base+"  mode: mock\n  allow_mock: true\n"))
+		if err != nil || cfg.GoogleOAuth.ClientID != "mock" {
+			t.Fatalf("Load: got client id %q, %v; want the mock provider's defaults", cfg.GoogleOAuth.ClientID, err)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// hotReloadable maps each key Watcher may apply at runtime onto a function copying that
+// setting from a freshly loaded configuration into the running one.
+var hotReloadable = map[string]func(dst *Config, src Config){
+	"logging.level": func(dst *Config, src Config) {
+		dst.Logging.Level = src.Logging.Level
+	},
+	"logging.slow_request_threshold": func(dst *Config, src Config) {
+		dst.Logging.SlowRequestThreshold = src.Logging.SlowRequestThreshold
+	},
+	"database.tracer.slow_threshold": func(dst *Config, src Config) {
+		dst.Database.Tracer.SlowThreshold = src.Database.Tracer.SlowThreshold
+	},
+	"rate_limit.read.rps":    func(dst *Config, src Config) { dst.RateLimit.Read.RPS = src.RateLimit.Read.RPS },
+	"rate_limit.read.burst":  func(dst *Config, src Config) { dst.RateLimit.Read.Burst = src.RateLimit.Read.Burst },
+	"rate_limit.write.rps":   func(dst *Config, src Config) { dst.RateLimit.Write.RPS = src.RateLimit.Write.RPS },
+	"rate_limit.write.burst": func(dst *Config, src Config) { dst.RateLimit.Write.Burst = src.RateLimit.Write.Burst },
+	"features":               func(dst *Config, src Config) { dst.Features = src.Features },
+}
+
+// Watcher holds the running configuration and applies hot-reloadable changes from a fresh
+// load, typically on SIGHUP. Changes to other keys are logged and ignored until restart.
+type Watcher struct {
+	load      func() (Config, error)
+	logger    *slog.Logger
+	mu        sync.Mutex
+	current   Config
+	listeners []func(old, updated Config)
+}
+
+// NewWatcher wraps the configuration the process started with; load is called on every
+// Reload, usually a closure over Load with the original path.
+func NewWatcher(current Config, load func() (Config, error), logger *slog.Logger) *Watcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Watcher{load: load, logger: logger, current: current}
+}
+
+// OnChange registers fn to run after a reload changes at least one hot-reloadable key.
+func (w *Watcher) OnChange(fn func(old, updated Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Current returns the configuration currently in effect.
+func (w *Watcher) Current() Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload loads the configuration again and applies hot-reloadable changes. If loading or
+// validation fails the running configuration is left untouched.
+func (w *Watcher) Reload() error {
+	next, err := w.load()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	old := w.current
+	updated := old
+	var applied []string
+	for _, key := range Diff(old, next) {
+		apply, ok := hotReloadable[key]
+		if !ok {
+			w.logger.Warn("config_reload_ignored", "key", key, "reason", "requires restart")
+			continue
+		}
+		apply(&updated, next)
+		applied = append(applied, key)
+	}
+
+	if len(applied) == 0 {
+		w.logger.Info("config_reload_unchanged")
+		return nil
+	}
+	w.current = updated
+	for _, fn := range w.listeners {
+		fn(old, updated)
+	}
+	w.logger.Info("config_reloaded", "applied", applied)
+	return nil
+}
+
+// Diff returns the dotted mapstructure keys whose values differ between a and b, sorted.
+// Maps and slices are compared as a whole.
+func Diff(a, b Config) []string {
+	var keys []string
+	diffValues("", reflect.ValueOf(a), reflect.ValueOf(b), &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func diffValues(prefix string, a, b reflect.Value, keys *[]string) {
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*keys = append(*keys, prefix)
+		}
+		return
+	}
+
+	t := a.Type()
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		diffValues(name, a.Field(i), b.Field(i), keys)
+	}
+}
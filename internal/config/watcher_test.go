@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"demo/internal/config"
+)
+
+// TestWatcherReload checks that a reload applies hot-reloadable keys, reports keys that
+// need a restart without applying them, and tells listeners about the change.
+func TestWatcherReload(t *testing.T) {
+	running := validConfig(t)
+	next := running
+	next.Logging.Level = "debug"
+	next.RateLimit.Write.RPS = 1
+	next.Features = map[string]bool{"strict_json": true}
+	next.Server.Address = ":9999"
+	next.Database.DSN = "postgres://other-host/petstore"
+
+	var logs bytes.Buffer
+	w := config.NewWatcher(running, func() (config.Config, error) { return next, nil }, slog.New(slog.NewTextHandler(&logs, nil)))
+	var notified []config.Config
+	w.OnChange(func(old, updated config.Config) { notified = append(notified, old, updated) })
+
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	current := w.Current()
+	if current.Logging.Level != "debug" || current.RateLimit.Write.RPS != 1 || !current.Features["strict_json"] {
+		t.Errorf("hot-reloadable keys: got level %q, write rps %v, features %v; want the reloaded values",
+			current.Logging.Level, current.RateLimit.Write.RPS, current.Features)
+	}
+	if current.Server.Address != running.Server.Address || current.Database.DSN != running.Database.DSN {
+		t.Errorf("restart-only keys: got address %q and dsn %q, want the running values", current.Server.Address, current.Database.DSN)
+	}
+	for _, key := range []string{"server.address", "database.dsn"} {
+		if !strings.Contains(logs.String(), "msg=config_reload_ignored key="+key+" reason=\"requires restart\"") {
+			t.Errorf("logs: got %q, want %s reported as needing a restart", logs.String(), key)
+		}
+	}
+	if len(notified) != 2 || notified[0].Logging.Level != running.Logging.Level || notified[1].Logging.Level != "debug" {
+		t.Errorf("OnChange: got %d calls, want one with the old and the updated config", len(notified)/2)
+	}
+}
+
+// TestWatcherReloadRestartOnly checks that a reload changing nothing hot-reloadable
+// leaves the configuration alone and calls no listener.
+func TestWatcherReloadRestartOnly(t *testing.T) {
+	running := validConfig(t)
+	next := running
+	next.Server.Address = ":9999"
+
+	w := config.NewWatcher(running, func() (config.Config, error) { return next, nil }, slog.New(slog.DiscardHandler))
+	w.OnChange(func(old, updated config.Config) { t.Error("OnChange called for a restart-only change") })
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := w.Current().Server.Address; got != running.Server.Address {
+		t.Fatalf("server.address: got %q, want the running %q", got, running.Server.Address)
+	}
+}
+
+func TestWatcherReloadFailure(t *testing.T) {
+	running := validConfig(t)
+	loadErr := errors.New("config.yaml: malformed")
+	w := config.NewWatcher(running, func() (config.Config, error) { return config.Config{}, loadErr }, slog.New(slog.DiscardHandler))
+	if err := w.Reload(); !errors.Is(err, loadErr) {
+		t.Fatalf("Reload: got %v, want the load error", err)
+	}
+	if diff := config.Diff(w.Current(), running); len(diff) != 0 {
+		t.Fatalf("after a failed reload: %v changed, want the running configuration kept", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := validConfig(t)
+	b := a
+	b.Logging.Level = "debug"
+	b.Server.Timeouts.Read *= 2
+	b.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	if got, want := config.Diff(a, b), []string{"logging.level", "server.timeouts.read", "server.trusted_proxies"}; !slices.Equal(got, want) {
+		t.Fatalf("Diff: got %v, want %v", got, want)
+	}
+	if got := config.Diff(a, a); len(got) != 0 {
+		t.Fatalf("Diff of a config with itself: got %v", got)
+	}
+}
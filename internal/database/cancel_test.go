@@ -0,0 +1,102 @@
+package database_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/database/databasetest"
+)
+
+// sleepFor is how long the test query would run if nothing stopped it; every wait in the
+// tests is well below it.
+const sleepFor = 60 * time.Second
+
+// waitLimit bounds each wait, such as for a canceled query to leave pg_stat_activity.
+const waitLimit = 5 * time.Second
+
+// TestQueryCancellation runs pg_sleep queries and ends their contexts mid-query, by
+// cancellation and by deadline: the query must return promptly and, as pg_stat_activity
+// shows, stop running on the server too.
+func TestQueryCancellation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// start derives the query's context; end ends it once the query is running.
+		start func(parent context.Context) (context.Context, context.CancelFunc)
+		end   func(cancel context.CancelFunc)
+		want  error
+	}{
+		{
+			name:  "Canceled",
+			start: context.WithCancel,
+			end:   func(cancel context.CancelFunc) { cancel() },
+			want:  context.Canceled,
+		},
+		{
+			name: "DeadlineExceeded",
+			start: func(parent context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(parent, 2*time.Second)
+			},
+			end:  func(context.CancelFunc) {},
+			want: context.DeadlineExceeded,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := databasetest.NewPool(t)
+			tag := "cancel_test " + rand.Text()
+			ctx, cancel := tc.start(t.Context())
+			defer cancel()
+
+			result := make(chan error, 1)
+			go func() {
+				// The tag goes in a comment so pg_stat_activity can pick this query out.
+				_, err := pool.Exec(ctx, "SELECT pg_sleep($1) /* "+tag+" */", sleepFor.Seconds())
+				result <- err
+			}()
+			waitForActive(t, pool, tag, 1, "the query to start")
+
+			tc.end(cancel)
+			select {
+			case err := <-result:
+				if err == nil {
+					t.Fatal("pg_sleep returned without error after its context ended")
+				}
+				if !errors.Is(ctx.Err(), tc.want) {
+					t.Fatalf("context ended with %v, want %v", ctx.Err(), tc.want)
+				}
+			case <-time.After(waitLimit):
+				t.Fatal("pg_sleep did not return after its context ended")
+			}
+
+			// Abandoned by the client alone, the query would run until it next wrote to the
+			// socket, a minute from now.
+			waitForActive(t, pool, tag, 0, "the query to stop on the server")
+		})
+	}
+}
+
+// waitForActive polls pg_stat_activity until want queries carrying tag are active.
+func waitForActive(t *testing.T, pool *pgxpool.Pool, tag string, want int, what string) {
+	t.Helper()
+	deadline := time.Now().Add(waitLimit)
+	for {
+		var n int
+		err := pool.QueryRow(t.Context(), `
+			SELECT count(*) FROM pg_stat_activity
+			WHERE state = 'active' AND pid <> pg_backend_pid() AND strpos(query, $1) > 0`, tag).Scan(&n)
+		if err != nil {
+			t.Fatalf("reading pg_stat_activity: %v", err)
+		}
+		if n == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %d matching queries active, want %d", what, n, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	appconfig "demo/internal/config"
+)
+
+// Connect creates a pool and verifies it with a ping, retrying with backoff until the
+// configured attempt budget is spent or ctx is cancelled.
+func Connect(ctx context.Context, poolConfig *pgxpool.Config, retry appconfig.ConnectRetryConfig, logger *slog.Logger) (*pgxpool.Pool, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attempts := retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	wait := retry.Interval
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		pool, err := connectOnce(ctx, poolConfig)
+		if err == nil {
+			if attempt > 1 {
+				logger.InfoContext(ctx, "database_connect_succeeded", "attempt", attempt)
+			}
+			return pool, nil
+		}
+		lastErr = err
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("database connect aborted: %w", ctx.Err())
+		}
+		if attempt == attempts {
+			break
+		}
+
+		logger.WarnContext(ctx, "database_connect_failed", "attempt", attempt, "max_attempts", attempts, "retry_in", wait, "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("database connect aborted: %w", ctx.Err())
+		case <-timer.C:
+		}
+
+		wait = nextInterval(wait, retry)
+	}
+
+	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempts, lastErr)
+}
+
+func connectOnce(ctx context.Context, poolConfig *pgxpool.Config) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+func nextInterval(current time.Duration, retry appconfig.ConnectRetryConfig) time.Duration {
+	if retry.Backoff > 1 {
+		current = time.Duration(float64(current) * retry.Backoff)
+	}
+	if retry.MaxInterval > 0 && current > retry.MaxInterval {
+		current = retry.MaxInterval
+	}
+	return current
+}
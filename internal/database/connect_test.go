@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	appconfig "demo/internal/config"
+)
+
+// fakeServer speaks just enough of the PostgreSQL protocol for a pool to connect and
+// ping: it hangs up on the first refuse connections, as a server still starting up
+// would, and serves the rest. With tls set it accepts SSLRequest and serves over TLS.
+type fakeServer struct {
+	listener net.Listener
+	refuse   int64
+	tls      *tls.Config
+	accepted atomic.Int64
+}
+
+func newFakeServer(t *testing.T, refuse int64) *fakeServer {
+	return startFakeServer(t, &fakeServer{refuse: refuse})
+}
+
+func startFakeServer(t *testing.T, s *fakeServer) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s.listener = listener
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		if s.accepted.Add(1) <= s.refuse {
+			conn.Close()
+			continue
+		}
+		go s.session(conn)
+	}
+}
+
+// session completes the startup handshake, then answers every simple query, which is
+// all a ping sends, with an empty result.
+func (s *fakeServer) session(conn net.Conn) {
+	defer func() { conn.Close() }()
+	backend := pgproto3.NewBackend(conn, conn)
+	msg, err := backend.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+	if _, ok := msg.(*pgproto3.SSLRequest); ok {
+		if s.tls == nil {
+			conn.Write([]byte("N"))
+		} else {
+			conn.Write([]byte("S"))
+			conn = tls.Server(conn, s.tls)
+			backend = pgproto3.NewBackend(conn, conn)
+		}
+		if _, err := backend.ReceiveStartupMessage(); err != nil {
+			return
+		}
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: []byte{0, 0, 0, 1}})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if backend.Flush() != nil {
+		return
+	}
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg.(type) {
+		case *pgproto3.Query:
+			backend.Send(&pgproto3.EmptyQueryResponse{})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if backend.Flush() != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+func (s *fakeServer) poolConfig(t *testing.T) *pgxpool.Config {
+	t.Helper()
+	cfg, err := pgxpool.ParseConfig("postgres://petstore@" + s.listener.Addr().String() + "/petstore?sslmode=disable&connect_timeout=2")
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestConnectRetriesUntilServerAccepts(t *testing.T) {
+	server := newFakeServer(t, 2)
+	retry := appconfig.ConnectRetryConfig{Attempts: 5, Interval: 10 * time.Millisecond, Backoff: 2}
+
+	pool, err := Connect(t.Context(), server.poolConfig(t), retry, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer pool.Close()
+	if got := server.accepted.Load(); got < 3 {
+		t.Fatalf("Connect: server saw %d connections, want 2 refused and then 1 served", got)
+	}
+	if err := pool.Ping(t.Context()); err != nil {
+		t.Fatalf("Ping after Connect: %v", err)
+	}
+}
+
+func TestConnectGivesUpAfterAttempts(t *testing.T) {
+	server := newFakeServer(t, 100)
+	retry := appconfig.ConnectRetryConfig{Attempts: 3, Interval: time.Millisecond}
+
+	_, err := Connect(t.Context(), server.poolConfig(t), retry, slog.New(slog.DiscardHandler))
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("Connect to a server refusing every connection: got %v, want failure after 3 attempts", err)
+	}
+	// pgx may redial within one attempt, so every attempt reaches the server at least once.
+	if got := server.accepted.Load(); got < 3 {
+		t.Fatalf("Connect: server saw %d connections, want at least one per attempt (3)", got)
+	}
+}
+
+func TestConnectStopsWaitingWhenCanceled(t *testing.T) {
+	server := newFakeServer(t, 100)
+	retry := appconfig.ConnectRetryConfig{Attempts: 10, Interval: time.Minute}
+	ctx, cancel := context.WithCancel(t.Context())
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := Connect(ctx, server.poolConfig(t), retry, slog.New(slog.DiscardHandler))
+		result <- err
+	}()
+	// Cancel once the first attempt has failed and Connect is waiting out the interval.
+	for server.accepted.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Connect after cancel: got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connect kept waiting after its context was canceled")
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		current time.Duration
+		retry   appconfig.ConnectRetryConfig
+		want    time.Duration
+	}{
+		{"Constant", time.Second, appconfig.ConnectRetryConfig{}, time.Second},
+		{"Backoff", time.Second, appconfig.ConnectRetryConfig{Backoff: 2}, 2 * time.Second},
+		{"BackoffOfOneIsConstant", time.Second, appconfig.ConnectRetryConfig{Backoff: 1}, time.Second},
+		{"Capped", 8 * time.Second, appconfig.ConnectRetryConfig{Backoff: 2, MaxInterval: 10 * time.Second}, 10 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nextInterval(tc.current, tc.retry); got != tc.want {
+				t.Fatalf("nextInterval(%s): got %s, want %s", tc.current, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package databasetest connects tests to a real PostgreSQL server.
+package databasetest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	appconfig "demo/internal/config"
+	"demo/internal/database"
+)
+
+// DSNEnv names the environment variable holding the DSN of a PostgreSQL server the tests
+// may use instead of a container. Its tables are emptied before every case, so never point
+// it at real data.
+const DSNEnv = "DEMO_TEST_DSN"
+
+// image is the PostgreSQL image Main starts when DSNEnv is unset.
+const image = "postgres:17-alpine"
+
+var (
+	// managed is set by Main, which owns the container's lifetime.
+	managed bool
+	// once starts the container; server is its DSN, or skipReason why there is none.
+	once               sync.Once
+	container          *postgres.PostgresContainer
+	server, skipReason string
+)
+
+// Main runs a package's tests with one PostgreSQL server for all of them: the one DSNEnv
+// names, or else a container started when the first test asks for it and removed once
+// the tests finish. With -short, or when no container can be started, the PostgreSQL
+// tests skip and the rest still run. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(databasetest.Main(m))
+//	}
+func Main(m *testing.M) int {
+	managed = true
+	code := m.Run()
+	if container != nil {
+		if err := testcontainers.TerminateContainer(container); err != nil {
+			fmt.Fprintf(os.Stderr, "databasetest: removing %s container: %v\n", image, err)
+		}
+	}
+	return code
+}
+
+// DSN returns the test server's DSN, skipping t when there is none. Outside Main only
+// DSNEnv is consulted.
+func DSN(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("-short set; skipping PostgreSQL tests")
+	}
+	if dsn := os.Getenv(DSNEnv); dsn != "" {
+		return dsn
+	}
+	if !managed {
+		t.Skipf("%s is not set; skipping PostgreSQL tests", DSNEnv)
+	}
+	once.Do(startContainer)
+	if server == "" {
+		t.Skip(skipReason)
+	}
+	return server
+}
+
+func startContainer() {
+	ctx := context.Background()
+	var err error
+	container, err = postgres.Run(ctx, image, postgres.BasicWaitStrategies())
+	if err == nil {
+		server, err = container.ConnectionString(ctx, "sslmode=disable")
+	}
+	if err != nil {
+		skipReason = fmt.Sprintf("%s is not set and no %s container could be started (%v); skipping PostgreSQL tests", DSNEnv, image, err)
+	}
+}
+
+// Config returns a DatabaseConfig reaching the test server, skipping t when there is none.
+func Config(t *testing.T) appconfig.DatabaseConfig {
+	t.Helper()
+	return appconfig.DatabaseConfig{DSN: DSN(t)}
+}
+
+// NewPool connects to the test server with every migration applied, skipping t when there
+// is none. The pool closes when t ends.
+func NewPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	return NewPoolFor(t, Config(t))
+}
+
+// NewPoolFor is NewPool for cfg, a Config the caller adjusted, e.g. with DSN parameters.
+func NewPoolFor(t *testing.T, cfg appconfig.DatabaseConfig) *pgxpool.Pool {
+	t.Helper()
+	poolConfig, err := database.NewPoolConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewPoolConfig: %v", err)
+	}
+	pool, err := pgxpool.NewWithConfig(t.Context(), poolConfig)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	if err := database.MigrateUp(t.Context(), pool, slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	return pool
+}
+
+// Truncate empties every table but schema_migrations, restarting their sequences, so the
+// next case starts from a freshly migrated database.
+func Truncate(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+	rows, err := pool.Query(t.Context(), `
+		SELECT quote_ident(tablename) FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'`)
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	if len(tables) == 0 {
+		return
+	}
+	if _, err := pool.Exec(t.Context(), "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatalf("truncating tables: %v", err)
+	}
+}
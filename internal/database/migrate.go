@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serialises concurrent migrators via pg_advisory_xact_lock.
+const migrationLockID = 727_165_001
+
+// Migration is one versioned schema change loaded from migrations/NNNN_name.{up,down}.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations returns the embedded migrations in ascending version order.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, path := range entries {
+		base := strings.TrimPrefix(path, "migrations/")
+		stem, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+		prefix, name, _ := strings.Cut(stem, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", base, err)
+		}
+
+		contents, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration, each in its own transaction.
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err := ensureMigrationsTable(ctx, pool); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		applied, err := runMigration(ctx, pool, m.Version, func(tx pgx.Tx, isApplied bool) (bool, error) {
+			if isApplied {
+				return false, nil
+			}
+			if _, err := tx.Exec(ctx, m.Up); err != nil {
+				return false, err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			return err == nil, err
+		})
+		if err != nil {
+			return fmt.Errorf("apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if applied {
+			logger.InfoContext(ctx, "migration_applied", "version", m.Version, "name", m.Name)
+		}
+	}
+	return nil
+}
+
+// MigrateDown rolls back the most recent steps applied migrations.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err := ensureMigrationsTable(ctx, pool); err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if m.Down == "" {
+			return fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		reverted, err := runMigration(ctx, pool, m.Version, func(tx pgx.Tx, isApplied bool) (bool, error) {
+			if !isApplied {
+				return false, nil
+			}
+			if _, err := tx.Exec(ctx, m.Down); err != nil {
+				return false, err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			return err == nil, err
+		})
+		if err != nil {
+			return fmt.Errorf("revert migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if reverted {
+			logger.InfoContext(ctx, "migration_reverted", "version", m.Version, "name", m.Name)
+			steps--
+		}
+	}
+	return nil
+}
+
+func ensureMigrationsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	const ddl = `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version    INTEGER PRIMARY KEY,
+            name       TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        );`
+
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		return fmt.Errorf("failed to ensure schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// runMigration holds the migration advisory lock for the duration of one transaction and
+// commits only if fn reports that it changed something.
+func runMigration(ctx context.Context, pool *pgxpool.Pool, version int, fn func(tx pgx.Tx, isApplied bool) (bool, error)) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, err
+	}
+	var isApplied bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&isApplied); err != nil {
+		return false, err
+	}
+
+	changed, err := fn(tx, isApplied)
+	if err != nil || !changed {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS pets (
+    id   BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    tag  TEXT
+);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users (
+    id            BIGSERIAL PRIMARY KEY,
+    google_sub    TEXT NOT NULL UNIQUE,
+    email         TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    picture       TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_login_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    user_id              BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    refresh_token        BYTEA NOT NULL,
+    access_token         BYTEA,
+    access_token_expiry  TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
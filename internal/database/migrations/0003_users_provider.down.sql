@@ -0,0 +1,5 @@
+DELETE FROM users WHERE provider <> 'google';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_provider_subject_key;
+ALTER TABLE users DROP COLUMN IF EXISTS provider;
+ALTER TABLE users RENAME COLUMN subject TO google_sub;
+ALTER TABLE users ADD CONSTRAINT users_google_sub_key UNIQUE (google_sub);
@@ -0,0 +1,11 @@
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'google_sub') THEN
+        ALTER TABLE users RENAME COLUMN google_sub TO subject;
+        ALTER TABLE users ADD COLUMN provider TEXT NOT NULL DEFAULT 'google';
+        ALTER TABLE users ALTER COLUMN provider DROP DEFAULT;
+        ALTER TABLE users DROP CONSTRAINT IF EXISTS users_google_sub_key;
+        ALTER TABLE users ADD CONSTRAINT users_provider_subject_key UNIQUE (provider, subject);
+    END IF;
+END $$;
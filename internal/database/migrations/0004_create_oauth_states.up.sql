@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state_hash TEXT PRIMARY KEY,
+    provider   TEXT NOT NULL,
+    return_to  TEXT NOT NULL DEFAULT '',
+    nonce      TEXT NOT NULL,
+    verifier   TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS pets_owner_id_idx;
+ALTER TABLE pets DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,2 @@
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS owner_id TEXT;
+CREATE INDEX IF NOT EXISTS pets_owner_id_idx ON pets (owner_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id           TEXT PRIMARY KEY,
+    status       TEXT NOT NULL DEFAULT 'queued',
+    format       TEXT NOT NULL,
+    owner_id     TEXT,
+    rows_written BIGINT NOT NULL DEFAULT 0,
+    total_rows   BIGINT,
+    error        TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS export_jobs_status_idx ON export_jobs (status, created_at);
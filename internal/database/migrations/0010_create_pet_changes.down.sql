@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS pets_record_change ON pets;
+DROP FUNCTION IF EXISTS pets_record_change();
+DROP FUNCTION IF EXISTS pet_changes_sequence();
+DROP TABLE IF EXISTS pet_changes;
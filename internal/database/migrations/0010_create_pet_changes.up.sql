@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS pet_changes (
+    id         BIGSERIAL PRIMARY KEY,
+    seq        BIGINT UNIQUE,
+    txid       XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    op         TEXT NOT NULL,
+    pet_id     BIGINT NOT NULL,
+    payload    JSONB,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS pet_changes_unsequenced_idx ON pet_changes (txid, id) WHERE seq IS NULL;
+
+CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO pet_changes (op, pet_id) VALUES ('delete', OLD.id);
+        RETURN OLD;
+    END IF;
+    INSERT INTO pet_changes (op, pet_id, payload)
+    VALUES (CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+            jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+    RETURN NEW;
+END $$;
+
+-- Numbers settled changes in xid order. The advisory lock serializes callers and
+-- each statement of a function takes a fresh snapshot, so the UPDATE sees the
+-- numbers the previous caller assigned.
+CREATE OR REPLACE FUNCTION pet_changes_sequence() RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('pet_changes_sequence'));
+    UPDATE pet_changes c SET seq = n.seq
+    FROM (
+        SELECT id, (SELECT COALESCE(max(seq), 0) FROM pet_changes)
+                   + row_number() OVER (ORDER BY txid, id) AS seq
+        FROM pet_changes
+        WHERE seq IS NULL AND txid < pg_snapshot_xmin(pg_current_snapshot())
+    ) n
+    WHERE c.id = n.id;
+END $$;
+
+-- The first start with the feed records existing pets as creates, so replaying
+-- from zero reconstructs the table. Creating the trigger locks out writes to pets
+-- until the backfill commits.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'pets_record_change' AND tgrelid = 'pets'::regclass) THEN
+        CREATE TRIGGER pets_record_change AFTER INSERT OR UPDATE OR DELETE ON pets
+            FOR EACH ROW EXECUTE FUNCTION pets_record_change();
+        INSERT INTO pet_changes (op, pet_id, payload)
+        SELECT 'create', id, jsonb_strip_nulls(jsonb_build_object('id', id, 'name', name, 'tag', tag))
+        FROM pets ORDER BY id;
+    END IF;
+END $$;
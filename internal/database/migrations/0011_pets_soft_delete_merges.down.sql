@@ -0,0 +1,16 @@
+CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO pet_changes (op, pet_id) VALUES ('delete', OLD.id);
+        RETURN OLD;
+    END IF;
+    INSERT INTO pet_changes (op, pet_id, payload)
+    VALUES (CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+            jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+    RETURN NEW;
+END $$;
+
+DROP TABLE IF EXISTS pet_merges;
+DROP INDEX IF EXISTS pets_duplicate_key_idx;
+DELETE FROM pets WHERE deleted_at IS NOT NULL;
+ALTER TABLE pets DROP COLUMN IF EXISTS deleted_at;
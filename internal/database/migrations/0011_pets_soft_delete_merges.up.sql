@@ -0,0 +1,28 @@
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS pets_duplicate_key_idx ON pets (lower(btrim(name)), tag) WHERE deleted_at IS NULL;
+CREATE TABLE IF NOT EXISTS pet_merges (
+    pet_id      BIGINT PRIMARY KEY,
+    survivor_id BIGINT NOT NULL,
+    merged_by   TEXT,
+    merged_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS pet_merges_survivor_id_idx ON pet_merges (survivor_id);
+
+CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO pet_changes (op, pet_id) VALUES ('delete', OLD.id);
+        RETURN OLD;
+    END IF;
+    -- Soft-deleting a pet, as a merge does, reads as a delete to consumers.
+    IF NEW.deleted_at IS NOT NULL THEN
+        IF OLD.deleted_at IS NULL THEN
+            INSERT INTO pet_changes (op, pet_id) VALUES ('delete', NEW.id);
+        END IF;
+        RETURN NEW;
+    END IF;
+    INSERT INTO pet_changes (op, pet_id, payload)
+    VALUES (CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+            jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+    RETURN NEW;
+END $$;
@@ -0,0 +1,46 @@
+-- Rolling back keeps only the default organization's rows, since pet identifiers are
+-- unique per organization and would collide under the old primary key.
+DROP TABLE IF EXISTS org_memberships;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+
+DELETE FROM export_jobs WHERE org_id <> 'default';
+ALTER TABLE export_jobs DROP COLUMN IF EXISTS org_id;
+
+CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO pet_changes (op, pet_id) VALUES ('delete', OLD.id);
+        RETURN OLD;
+    END IF;
+    -- Soft-deleting a pet, as a merge does, reads as a delete to consumers.
+    IF NEW.deleted_at IS NOT NULL THEN
+        IF OLD.deleted_at IS NULL THEN
+            INSERT INTO pet_changes (op, pet_id) VALUES ('delete', NEW.id);
+        END IF;
+        RETURN NEW;
+    END IF;
+    INSERT INTO pet_changes (op, pet_id, payload)
+    VALUES (CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+            jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+    RETURN NEW;
+END $$;
+
+DELETE FROM pet_changes WHERE org_id <> 'default';
+DROP INDEX IF EXISTS pet_changes_org_seq_idx;
+ALTER TABLE pet_changes DROP COLUMN IF EXISTS org_id;
+
+DELETE FROM pet_merges WHERE org_id <> 'default';
+DROP INDEX IF EXISTS pet_merges_org_survivor_id_idx;
+ALTER TABLE pet_merges DROP CONSTRAINT pet_merges_pkey, ADD CONSTRAINT pet_merges_pkey PRIMARY KEY (pet_id);
+ALTER TABLE pet_merges DROP COLUMN IF EXISTS org_id;
+CREATE INDEX IF NOT EXISTS pet_merges_survivor_id_idx ON pet_merges (survivor_id);
+
+-- Deleting with the trigger in place would record the other organizations' pets as
+-- deletes in the default feed.
+ALTER TABLE pets DISABLE TRIGGER pets_record_change;
+DELETE FROM pets WHERE org_id <> 'default';
+ALTER TABLE pets ENABLE TRIGGER pets_record_change;
+DROP INDEX IF EXISTS pets_org_duplicate_key_idx;
+ALTER TABLE pets DROP CONSTRAINT pets_pkey, ADD CONSTRAINT pets_pkey PRIMARY KEY (id);
+ALTER TABLE pets DROP COLUMN IF EXISTS org_id;
+CREATE INDEX IF NOT EXISTS pets_duplicate_key_idx ON pets (lower(btrim(name)), tag) WHERE deleted_at IS NULL;
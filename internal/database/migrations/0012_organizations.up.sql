@@ -0,0 +1,40 @@
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE pets DROP CONSTRAINT pets_pkey, ADD CONSTRAINT pets_pkey PRIMARY KEY (org_id, id);
+DROP INDEX IF EXISTS pets_duplicate_key_idx;
+CREATE INDEX IF NOT EXISTS pets_org_duplicate_key_idx ON pets (org_id, lower(btrim(name)), tag) WHERE deleted_at IS NULL;
+
+ALTER TABLE pet_merges ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE pet_merges DROP CONSTRAINT pet_merges_pkey, ADD CONSTRAINT pet_merges_pkey PRIMARY KEY (org_id, pet_id);
+DROP INDEX IF EXISTS pet_merges_survivor_id_idx;
+CREATE INDEX IF NOT EXISTS pet_merges_org_survivor_id_idx ON pet_merges (org_id, survivor_id);
+
+ALTER TABLE pet_changes ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS pet_changes_org_seq_idx ON pet_changes (org_id, seq);
+
+CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO pet_changes (org_id, op, pet_id) VALUES (OLD.org_id, 'delete', OLD.id);
+        RETURN OLD;
+    END IF;
+    -- Soft-deleting a pet, as a merge does, reads as a delete to consumers.
+    IF NEW.deleted_at IS NOT NULL THEN
+        IF OLD.deleted_at IS NULL THEN
+            INSERT INTO pet_changes (org_id, op, pet_id) VALUES (NEW.org_id, 'delete', NEW.id);
+        END IF;
+        RETURN NEW;
+    END IF;
+    INSERT INTO pet_changes (org_id, op, pet_id, payload)
+    VALUES (NEW.org_id, CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+            jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+    RETURN NEW;
+END $$;
+
+ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+CREATE TABLE IF NOT EXISTS org_memberships (
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    org_id  TEXT NOT NULL,
+    PRIMARY KEY (user_id, org_id)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS blobs (
+    key          TEXT PRIMARY KEY,
+    content_type TEXT NOT NULL,
+    etag         TEXT NOT NULL,
+    data         BYTEA NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
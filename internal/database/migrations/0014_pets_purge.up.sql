@@ -0,0 +1,23 @@
+CREATE INDEX IF NOT EXISTS pets_deleted_at_idx ON pets (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        -- Purging a soft-deleted pet was reported when it was soft-deleted.
+        IF OLD.deleted_at IS NULL THEN
+            INSERT INTO pet_changes (org_id, op, pet_id) VALUES (OLD.org_id, 'delete', OLD.id);
+        END IF;
+        RETURN OLD;
+    END IF;
+    -- Soft-deleting a pet, as a merge does, reads as a delete to consumers.
+    IF NEW.deleted_at IS NOT NULL THEN
+        IF OLD.deleted_at IS NULL THEN
+            INSERT INTO pet_changes (org_id, op, pet_id) VALUES (NEW.org_id, 'delete', NEW.id);
+        END IF;
+        RETURN NEW;
+    END IF;
+    INSERT INTO pet_changes (org_id, op, pet_id, payload)
+    VALUES (NEW.org_id, CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+            jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+    RETURN NEW;
+END $$;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS pets_bump_version ON pets;
+DROP FUNCTION IF EXISTS pets_bump_version();
+DROP TABLE IF EXISTS collection_versions;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS collection_versions (
+    org_id     TEXT NOT NULL,
+    collection TEXT NOT NULL,
+    version    BIGINT NOT NULL,
+    PRIMARY KEY (org_id, collection)
+);
+
+CREATE OR REPLACE FUNCTION pets_bump_version() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    -- Purging a soft-deleted pet changes no list.
+    IF TG_OP = 'DELETE' AND OLD.deleted_at IS NOT NULL THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO collection_versions AS v (org_id, collection, version)
+    VALUES (COALESCE(NEW.org_id, OLD.org_id), 'pets', 1)
+    ON CONFLICT (org_id, collection) DO UPDATE SET version = v.version + 1;
+    RETURN NULL;
+END $$;
+
+DROP TRIGGER IF EXISTS pets_bump_version ON pets;
+CREATE TRIGGER pets_bump_version AFTER INSERT OR UPDATE OR DELETE ON pets
+    FOR EACH ROW EXECUTE FUNCTION pets_bump_version();
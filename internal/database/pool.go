@@ -0,0 +1,167 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	appconfig "demo/internal/config"
+)
+
+// ParseQueryExecMode maps a database.query_exec_mode value onto pgx's mode. The empty
+// string maps to pgx's default, QueryExecModeCacheStatement; callers leave the parsed
+// DSN's mode untouched in that case so default_query_exec_mode in the DSN still works.
+func ParseQueryExecMode(mode string) (pgx.QueryExecMode, error) {
+	switch mode {
+	case "", "cache_statement":
+		return pgx.QueryExecModeCacheStatement, nil
+	case "cache_describe":
+		return pgx.QueryExecModeCacheDescribe, nil
+	case "describe_exec":
+		return pgx.QueryExecModeDescribeExec, nil
+	case "exec":
+		return pgx.QueryExecModeExec, nil
+	case "simple_protocol":
+		return pgx.QueryExecModeSimpleProtocol, nil
+	default:
+		return 0, fmt.Errorf("unknown query exec mode %q", mode)
+	}
+}
+
+// cancelDeadlineDelay is how long a query whose context ended waits for the server to
+// acknowledge the cancel request before its connection is closed instead.
+const cancelDeadlineDelay = 2 * time.Second
+
+// NewPoolConfig parses the configured DSN and applies any pool overrides on top of it.
+// Queries whose context ends are canceled on the server: pgx's default only closes the
+// socket, which a backend busy in a long query does not notice until it next writes, so
+// an abandoned request's query would run to completion.
+func NewPoolConfig(cfg appconfig.DatabaseConfig) (*pgxpool.Config, error) {
+	var (
+		poolConfig *pgxpool.Config
+		err        error
+	)
+	switch {
+	case cfg.DSN != "":
+		poolConfig, err = pgxpool.ParseConfig(cfg.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse database dsn: %w", err)
+		}
+		if err := checkDiscreteConflicts(cfg, poolConfig); err != nil {
+			return nil, err
+		}
+	case cfg.HasDiscreteFields():
+		poolConfig, err = poolConfigFromFields(cfg)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("database.dsn or database.host configuration is required")
+	}
+
+	if err := applyTLS(poolConfig, cfg.TLS); err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelDeadlineDelay}
+	}
+
+	pool := cfg.Pool
+	if pool.MaxConns > 0 {
+		poolConfig.MaxConns = pool.MaxConns
+	}
+	if pool.MinConns > 0 {
+		poolConfig.MinConns = pool.MinConns
+	}
+	if pool.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = pool.MaxConnLifetime
+	}
+	if pool.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = pool.MaxConnIdleTime
+	}
+	if pool.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = pool.HealthCheckPeriod
+	}
+
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		return nil, fmt.Errorf("database pool min_conns (%d) exceeds max_conns (%d)", poolConfig.MinConns, poolConfig.MaxConns)
+	}
+
+	return poolConfig, nil
+}
+
+// poolConfigFromFields assembles a pool config from discrete fields. Only non-secret
+// values go through the connection URL; the password is set on the parsed config
+// directly so no escaping is involved.
+func poolConfigFromFields(cfg appconfig.DatabaseConfig) (*pgxpool.Config, error) {
+	host := cfg.Host
+	if host == "" {
+		host = "localhost"
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	query := url.Values{}
+	for key, value := range cfg.Params {
+		query.Set(key, value)
+	}
+	if cfg.SSLMode != "" {
+		query.Set("sslmode", cfg.SSLMode)
+	}
+
+	connURL := url.URL{
+		Scheme:   "postgres",
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
+	}
+	if cfg.User != "" {
+		connURL.User = url.User(cfg.User)
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(connURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build database config from fields: %w", err)
+	}
+
+	if cfg.Password != "" {
+		poolConfig.ConnConfig.Password = cfg.Password
+	}
+
+	return poolConfig, nil
+}
+
+// checkDiscreteConflicts rejects discrete fields that disagree with the DSN.
+func checkDiscreteConflicts(cfg appconfig.DatabaseConfig, poolConfig *pgxpool.Config) error {
+	conn := poolConfig.ConnConfig
+	conflicts := []struct {
+		field string
+		set   bool
+		same  bool
+	}{
+		{"host", cfg.Host != "", cfg.Host == conn.Host},
+		{"port", cfg.Port != 0, uint16(cfg.Port) == conn.Port},
+		{"user", cfg.User != "", cfg.User == conn.User},
+		{"password", cfg.Password != "", cfg.Password == conn.Password},
+		{"database", cfg.Name != "", cfg.Name == conn.Database},
+	}
+	for _, c := range conflicts {
+		if c.set && !c.same {
+			return fmt.Errorf("database.%s conflicts with the value in database.dsn", c.field)
+		}
+	}
+	if cfg.SSLMode != "" || len(cfg.Params) > 0 {
+		return errors.New("database.sslmode and database.params cannot be combined with database.dsn; put them in the DSN")
+	}
+	return nil
+}
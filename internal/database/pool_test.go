@@ -0,0 +1,54 @@
+package database_test
+
+import (
+	"net/url"
+	"testing"
+
+	appconfig "demo/internal/config"
+	"demo/internal/database"
+)
+
+// password has every character with a meaning in a connection URL.
+const password = `p@ss:w/rd?#%41 'q'\`
+
+// dsnWithPassword returns a URL DSN for petstore@db.internal:6432 with password escaped.
+func dsnWithPassword(password string) string {
+	u := url.URL{Scheme: "postgres", User: url.UserPassword("petstore", password), Host: "db.internal:6432", Path: "/petstore", RawQuery: "sslmode=disable"}
+	return u.String()
+}
+
+// TestNewPoolConfigPassword checks that a password full of URL delimiters reaches pgx
+// unchanged from the discrete fields and from both DSN forms.
+func TestNewPoolConfigPassword(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  appconfig.DatabaseConfig
+	}{
+		{"Fields", appconfig.DatabaseConfig{Host: "db.internal", Port: 6432, User: "petstore", Password: password, Name: "petstore", SSLMode: "disable"}},
+		{"URL", appconfig.DatabaseConfig{DSN: dsnWithPassword(password)}},
+		{"KeywordValue", appconfig.DatabaseConfig{DSN: `host=db.internal port=6432 user=petstore dbname=petstore sslmode=disable password='p@ss:w/rd?#%41 \'q\'\\'`}},
+		// Repeating the DSN's password in database.password is not a conflict.
+		{"URLAndField", appconfig.DatabaseConfig{Password: password, DSN: dsnWithPassword(password)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			poolConfig, err := database.NewPoolConfig(tc.cfg)
+			if err != nil {
+				t.Fatalf("NewPoolConfig: %v", err)
+			}
+			conn := poolConfig.ConnConfig
+			if conn.Password != password {
+				t.Fatalf("password: got %q, want %q", conn.Password, password)
+			}
+			if conn.Host != "db.internal" || conn.Port != 6432 || conn.User != "petstore" || conn.Database != "petstore" {
+				t.Fatalf("connection: got %s@%s:%d/%s", conn.User, conn.Host, conn.Port, conn.Database)
+			}
+		})
+	}
+}
+
+func TestNewPoolConfigPasswordConflict(t *testing.T) {
+	_, err := database.NewPoolConfig(appconfig.DatabaseConfig{Password: "other", DSN: dsnWithPassword(password)})
+	if err == nil {
+		t.Fatal("NewPoolConfig with a password differing from the DSN's: got nil error")
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// queryTime accumulates the statements run on behalf of one request.
+type queryTime struct {
+	total   atomic.Int64
+	queries atomic.Int64
+}
+
+type queryTimeKey struct{}
+
+// TrackQueryTime returns a context under which QueryTracer adds every statement's
+// duration to a per-request total, read back with QueryTime.
+func TrackQueryTime(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryTimeKey{}, &queryTime{})
+}
+
+// QueryTime returns the time spent in statements and how many ran under a context from
+// TrackQueryTime; ok is false for any other context.
+func QueryTime(ctx context.Context) (total time.Duration, queries int, ok bool) {
+	qt, ok := ctx.Value(queryTimeKey{}).(*queryTime)
+	if !ok {
+		return 0, 0, false
+	}
+	return time.Duration(qt.total.Load()), int(qt.queries.Load()), true
+}
+
+func addQueryTime(ctx context.Context, d time.Duration) {
+	if qt, ok := ctx.Value(queryTimeKey{}).(*queryTime); ok {
+		qt.total.Add(int64(d))
+		qt.queries.Add(1)
+	}
+}
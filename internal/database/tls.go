@@ -0,0 +1,104 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	appconfig "demo/internal/config"
+)
+
+// applyTLS replaces the TLS settings derived from the DSN with the configured ones. Files
+// are loaded eagerly so a bad path or mismatched key pair fails at startup.
+func applyTLS(poolConfig *pgxpool.Config, cfg appconfig.DatabaseTLSConfig) error {
+	if cfg.Mode == "" {
+		return nil
+	}
+
+	conn := poolConfig.ConnConfig
+	if cfg.Mode == "disable" {
+		conn.TLSConfig = nil
+		conn.Fallbacks = nil
+		return nil
+	}
+
+	tlsConfig, err := buildTLSConfig(cfg, conn.Host)
+	if err != nil {
+		return err
+	}
+
+	conn.TLSConfig = tlsConfig
+	// Fallbacks would otherwise allow a plaintext retry after a TLS failure.
+	conn.Fallbacks = nil
+	return nil
+}
+
+func buildTLSConfig(cfg appconfig.DatabaseTLSConfig, host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	var roots *x509.CertPool
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read database ca_file: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("database ca_file %s contains no PEM certificates", cfg.CAFile)
+		}
+	}
+
+	switch cfg.Mode {
+	case "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Chain verification without hostname checks, as libpq does for verify-ca.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChain(roots)
+	case "verify-full":
+		tlsConfig.RootCAs = roots
+		tlsConfig.ServerName = cfg.ServerName
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+	default:
+		return nil, fmt.Errorf("unknown database tls mode %q", cfg.Mode)
+	}
+
+	return tlsConfig, nil
+}
+
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("database server presented no certificate")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse database server certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
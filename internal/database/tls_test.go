@@ -0,0 +1,185 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	appconfig "demo/internal/config"
+)
+
+// testCA is a self-signed certificate authority whose certificate is written to caFile.
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	caFile string
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key := generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key, caFile: writePEM(t, name+".crt", "CERTIFICATE", der)}
+}
+
+// issue signs a certificate for the given names and IPs and returns its key pair both
+// loaded and as cert and key files.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage, dnsNames []string, ips []net.IP) (tls.Certificate, string, string) {
+	t.Helper()
+	key := generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create %s certificate: %v", name, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal %s key: %v", name, err)
+	}
+	certFile := writePEM(t, name+".crt", "CERTIFICATE", der)
+	keyFile := writePEM(t, name+".key", "EC PRIVATE KEY", keyDER)
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("load %s key pair: %v", name, err)
+	}
+	return pair, certFile, keyFile
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// TestTLSSelfSignedCA connects to a server whose certificate, for db.internal and
+// 127.0.0.1, is issued by a private CA and which requires a client certificate from it.
+func TestTLSSelfSignedCA(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	other := newTestCA(t, "other-ca")
+	serverCert, _, _ := ca.issue(t, "server", x509.ExtKeyUsageServerAuth, []string{"db.internal"}, []net.IP{net.IPv4(127, 0, 0, 1)})
+	_, certFile, keyFile := ca.issue(t, "client", x509.ExtKeyUsageClientAuth, nil, nil)
+	clients := x509.NewCertPool()
+	clients.AddCert(ca.cert)
+	server := startFakeServer(t, &fakeServer{tls: &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	}})
+	addr := server.listener.Addr().String()
+	dsn := "postgres://petstore@" + addr + "/petstore?connect_timeout=2"
+	withClientCert := func(cfg appconfig.DatabaseTLSConfig) appconfig.DatabaseTLSConfig {
+		cfg.CertFile, cfg.KeyFile = certFile, keyFile
+		return cfg
+	}
+
+	for _, tc := range []struct {
+		name string
+		dsn  string
+		tls  appconfig.DatabaseTLSConfig
+		ok   bool
+	}{
+		{"VerifyFullByIP", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: ca.caFile}), true},
+		{"VerifyFullByServerName", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: ca.caFile, ServerName: "db.internal"}), true},
+		{"VerifyFullWrongServerName", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: ca.caFile, ServerName: "other.internal"}), false},
+		{"VerifyFullOtherCA", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: other.caFile}), false},
+		// Without ca_file the system roots apply, which do not include the private CA.
+		{"VerifyFullSystemRoots", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-full"}), false},
+		// verify-ca checks the chain but not the name, as libpq does.
+		{"VerifyCAWrongServerName", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-ca", CAFile: ca.caFile, ServerName: "other.internal"}), true},
+		{"VerifyCAOtherCA", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "verify-ca", CAFile: other.caFile}), false},
+		{"Require", dsn, withClientCert(appconfig.DatabaseTLSConfig{Mode: "require"}), true},
+		{"NoClientCertificate", dsn, appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: ca.caFile}, false},
+		// The libpq parameters in the DSN work the same when database.tls is unset.
+		{"DSNRootCert", dsn + "&" + url.Values{"sslmode": {"verify-full"}, "sslrootcert": {ca.caFile}, "sslcert": {certFile}, "sslkey": {keyFile}}.Encode(), appconfig.DatabaseTLSConfig{}, true},
+		{"DSNOtherRootCert", dsn + "&" + url.Values{"sslmode": {"verify-full"}, "sslrootcert": {other.caFile}, "sslcert": {certFile}, "sslkey": {keyFile}}.Encode(), appconfig.DatabaseTLSConfig{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			poolConfig, err := NewPoolConfig(appconfig.DatabaseConfig{DSN: tc.dsn, TLS: tc.tls})
+			if err != nil {
+				t.Fatalf("NewPoolConfig: %v", err)
+			}
+			pool, err := pgxpool.NewWithConfig(t.Context(), poolConfig)
+			if err != nil {
+				t.Fatalf("NewWithConfig: %v", err)
+			}
+			defer pool.Close()
+			if err := pool.Ping(t.Context()); (err == nil) != tc.ok {
+				t.Fatalf("Ping: got %v, want ok %v", err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestTLSConfigFiles(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	_, certFile, _ := ca.issue(t, "client", x509.ExtKeyUsageClientAuth, nil, nil)
+	notPEM := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write %s: %v", notPEM, err)
+	}
+	for _, tc := range []struct {
+		name string
+		tls  appconfig.DatabaseTLSConfig
+	}{
+		{"MissingCAFile", appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: filepath.Join(t.TempDir(), "missing.crt")}},
+		{"CAFileNotPEM", appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: notPEM}},
+		{"KeyNotMatchingCert", appconfig.DatabaseTLSConfig{Mode: "verify-full", CAFile: ca.caFile, CertFile: certFile, KeyFile: ca.caFile}},
+		{"UnknownMode", appconfig.DatabaseTLSConfig{Mode: "verify"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewPoolConfig(appconfig.DatabaseConfig{DSN: "postgres://petstore@db.internal/petstore", TLS: tc.tls}); err == nil {
+				t.Fatal("NewPoolConfig: got nil error")
+			}
+		})
+	}
+}
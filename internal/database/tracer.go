@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var otelTracer = otel.Tracer("demo/internal/database")
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "petstore",
+	Subsystem: "db",
+	Name:      "query_duration_seconds",
+	Help:      "Duration of SQL statements executed through the pgx pool.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"operation"})
+
+// QueryTracer is a pgx.QueryTracer that records per-query durations, logs slow statements,
+// and emits an OpenTelemetry child span per statement.
+type QueryTracer struct {
+	slowThreshold atomic.Int64
+	logger        *slog.Logger
+}
+
+// NewQueryTracer constructs a tracer logging statements slower than slowThreshold.
+// A zero threshold records metrics without slow-query logging.
+func NewQueryTracer(slowThreshold time.Duration, logger *slog.Logger) *QueryTracer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	t := &QueryTracer{logger: logger}
+	t.SetSlowThreshold(slowThreshold)
+	return t
+}
+
+// SetSlowThreshold changes the slow-query threshold for subsequent statements.
+func (t *QueryTracer) SetSlowThreshold(d time.Duration) {
+	t.slowThreshold.Store(int64(d))
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	start     time.Time
+	sql       string
+	operation string
+	argCount  int
+}
+
+// TraceQueryStart stashes the statement and start time on the query context.
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation := operationName(data.SQL)
+	ctx, _ = otelTracer.Start(ctx, "db "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", compactSQL(data.SQL)),
+		),
+	)
+
+	return context.WithValue(ctx, queryTraceKey{}, queryTrace{
+		start:     time.Now(),
+		sql:       data.SQL,
+		operation: operation,
+		argCount:  len(data.Args),
+	})
+}
+
+// TraceQueryEnd observes the query duration, adds it to the request's TrackQueryTime
+// total, and logs it when above the slow threshold.
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+
+	qt, ok := ctx.Value(queryTraceKey{}).(queryTrace)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(qt.start)
+	operation := qt.operation
+	addQueryTime(ctx, duration)
+	queryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+
+	threshold := time.Duration(t.slowThreshold.Load())
+	if threshold > 0 && duration >= threshold {
+		// Argument values may carry user data, so only their count is logged.
+		t.logger.WarnContext(ctx, "db_slow_query",
+			"operation", operation,
+			"duration", duration,
+			"threshold", threshold,
+			"args", qt.argCount,
+			"failed", data.Err != nil,
+			"sql", compactSQL(qt.sql),
+		)
+	}
+}
+
+// operationName derives a low-cardinality label such as "select_pets" from a statement.
+func operationName(sql string) string {
+	fields := strings.Fields(strings.ToLower(sql))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+
+	verb := fields[0]
+	var marker string
+	switch verb {
+	case "select", "delete":
+		marker = "from"
+	case "insert":
+		marker = "into"
+	case "update":
+		return verb + "_" + tableName(fields, 1)
+	default:
+		return verb
+	}
+
+	for i, field := range fields {
+		if field == marker {
+			return verb + "_" + tableName(fields, i+1)
+		}
+	}
+	return verb
+}
+
+func tableName(fields []string, i int) string {
+	if i >= len(fields) {
+		return "unknown"
+	}
+	return strings.Trim(fields[i], `"(;`)
+}
+
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+var _ pgx.QueryTracer = (*QueryTracer)(nil)
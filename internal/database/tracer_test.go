@@ -0,0 +1,85 @@
+package database_test
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"demo/internal/database"
+)
+
+// TestQueryTracerSpans records spans in memory. The global tracer provider can only be
+// delegated once, so this is the one test in the package that installs it.
+func TestQueryTracerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "GET /pets")
+
+	tracer := database.NewQueryTracer(0, slog.New(slog.DiscardHandler))
+	for _, tc := range []struct {
+		name      string
+		sql       string
+		err       error
+		wantName  string
+		wantQuery string
+	}{
+		{"Select", "SELECT id, name\n\tFROM pets\n\tWHERE id = $1", nil, "db select_pets", "SELECT id, name FROM pets WHERE id = $1"},
+		{"Insert", `INSERT INTO "pet_tags" (pet_id, tag) VALUES ($1, $2)`, nil, "db insert_pet_tags", `INSERT INTO "pet_tags" (pet_id, tag) VALUES ($1, $2)`},
+		{"Failed", "UPDATE pets SET name = $1", errors.New("deadlock detected"), "db update_pets", "UPDATE pets SET name = $1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+			queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: tc.sql})
+			tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{Err: tc.err})
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("spans: got %d, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tc.wantName || span.SpanKind != trace.SpanKindClient {
+				t.Fatalf("span: got %q of kind %v, want %q of kind client", span.Name, span.SpanKind, tc.wantName)
+			}
+			if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Fatalf("parent: got %v, want the request span %v", span.Parent.SpanID(), parent.SpanContext().SpanID())
+			}
+
+			want := map[attribute.Key]string{
+				"db.system":         "postgresql",
+				"db.operation.name": tc.wantName[len("db "):],
+				"db.query.text":     tc.wantQuery,
+			}
+			got := make(map[attribute.Key]string, len(span.Attributes))
+			for _, attr := range span.Attributes {
+				got[attr.Key] = attr.Value.Emit()
+			}
+			for key, value := range want {
+				if got[key] != value {
+					t.Errorf("attribute %s: got %q, want %q", key, got[key], value)
+				}
+			}
+
+			if tc.err == nil {
+				if span.Status.Code != codes.Unset || len(span.Events) != 0 {
+					t.Fatalf("status: got %v with %d events, want unset and none", span.Status, len(span.Events))
+				}
+				return
+			}
+			if span.Status.Code != codes.Error || span.Status.Description != tc.err.Error() {
+				t.Fatalf("status: got %v, want error %q", span.Status, tc.err)
+			}
+			if len(span.Events) != 1 || span.Events[0].Name != "exception" {
+				t.Fatalf("events: got %v, want the recorded error", span.Events)
+			}
+		})
+	}
+}
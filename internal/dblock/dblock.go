@@ -0,0 +1,223 @@
+// Package dblock provides distributed locks for background work that must run on one
+// instance at a time, built on PostgreSQL session advisory locks. Every lock is held on a
+// connection of its own, taken out of the pool, so it lives exactly as long as that
+// session: a crashed instance or a dropped connection releases it on the server, and the
+// holder notices within a heartbeat and stops.
+package dblock
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultHeartbeat = 10 * time.Second
+
+// closeTimeout bounds closing a lock's connection, which may be the one that failed.
+const closeTimeout = 5 * time.Second
+
+// Options configures a Locker.
+type Options struct {
+	// Heartbeat is how often the connection of a held lock is pinged to notice the lock
+	// went with its session. Zero means 10s.
+	Heartbeat time.Duration
+	Logger    *slog.Logger
+}
+
+// Locker takes named advisory locks through pool and tracks which of them it holds.
+type Locker struct {
+	pool      *pgxpool.Pool
+	heartbeat time.Duration
+	logger    *slog.Logger
+
+	mu   sync.Mutex
+	held map[string]*lease
+}
+
+// New builds a Locker taking connections from pool.
+func New(pool *pgxpool.Pool, opts Options) *Locker {
+	heartbeat := opts.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Locker{pool: pool, heartbeat: heartbeat, logger: logger, held: make(map[string]*lease)}
+}
+
+// Acquire tries to take the lock named key without waiting; ok is false when another
+// session holds it, this Locker included. While the lock is held its connection is pinged
+// every heartbeat, and if that fails the lock is lost and Held reports false. release
+// gives the lock up; it may be called more than once, after the lock was lost, and when
+// ok is false.
+func (l *Locker) Acquire(ctx context.Context, key string) (release func(), ok bool, err error) {
+	ls, err := l.acquire(ctx, key)
+	if err != nil || ls == nil {
+		return func() {}, false, err
+	}
+	return ls.release, true, nil
+}
+
+// RunExclusive runs fn while holding the lock named key and releases it afterwards. It
+// reports false without running fn when another session holds the lock. The context fn
+// gets is canceled if the lock is lost, so fn should stop promptly when it is done; the
+// error is fn's.
+func (l *Locker) RunExclusive(ctx context.Context, key string, fn func(ctx context.Context) error) (bool, error) {
+	ls, err := l.acquire(ctx, key)
+	if err != nil || ls == nil {
+		return false, err
+	}
+	defer ls.release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ls.lost:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	err = fn(ctx)
+	l.logger.Info("db_lock_run_finished", "key", key, "duration", time.Since(start), "lost", ls.isLost(), "error", err)
+	return true, err
+}
+
+// Held reports whether this Locker holds the lock named key and its last heartbeat
+// succeeded.
+func (l *Locker) Held(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held[key] != nil
+}
+
+// Status describes the lock named key for /readyz: "held" or "not held".
+func (l *Locker) Status(key string) string {
+	if l.Held(key) {
+		return "held"
+	}
+	return "not held"
+}
+
+func (l *Locker) acquire(ctx context.Context, key string) (*lease, error) {
+	id := lockID(key)
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	var won bool
+	if err := pooled.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, id).Scan(&won); err != nil {
+		pooled.Release()
+		return nil, fmt.Errorf("failed to try lock %q: %w", key, err)
+	}
+	if !won {
+		pooled.Release()
+		return nil, nil
+	}
+
+	// The connection leaves the pool so holding the lock does not shrink it, and so the
+	// session is never handed to other work still holding the lock.
+	ls := &lease{
+		locker: l,
+		key:    key,
+		conn:   pooled.Hijack(),
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	l.mu.Lock()
+	l.held[key] = ls
+	l.mu.Unlock()
+	l.logger.Info("db_lock_acquired", "key", key, "lock_id", id)
+	go ls.beat()
+	return ls, nil
+}
+
+// forget drops ls from the held locks unless the key was taken again since.
+func (l *Locker) forget(ls *lease) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[ls.key] == ls {
+		delete(l.held, ls.key)
+	}
+}
+
+// lease is one held lock and the connection holding it.
+type lease struct {
+	locker *Locker
+	key    string
+	conn   *pgx.Conn
+	// lost is closed when a heartbeat fails, stop asks the heartbeat to end, and done is
+	// closed once it has, after which release may use conn.
+	lost chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// beat pings the connection every heartbeat until release stops it or a ping fails.
+func (ls *lease) beat() {
+	defer close(ls.done)
+	l := ls.locker
+	ticker := time.NewTicker(l.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ls.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), l.heartbeat)
+		err := ls.conn.Ping(ctx)
+		cancel()
+		if err != nil {
+			l.forget(ls)
+			close(ls.lost)
+			l.logger.Warn("db_lock_lost", "key", ls.key, "error", err)
+			return
+		}
+		l.logger.Debug("db_lock_heartbeat", "key", ls.key)
+	}
+}
+
+func (ls *lease) isLost() bool {
+	select {
+	case <-ls.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+// release closes the connection, which ends the session and with it the lock.
+func (ls *lease) release() {
+	ls.once.Do(func() {
+		close(ls.stop)
+		<-ls.done
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		ls.conn.Close(ctx)
+		if !ls.isLost() {
+			ls.locker.forget(ls)
+			ls.locker.logger.Info("db_lock_released", "key", ls.key)
+		}
+	})
+}
+
+// lockID maps key to a bigint advisory lock id. The prefix keeps these ids apart from
+// the fixed ones used elsewhere, such as the migration lock.
+func lockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("dblock:" + key))
+	return int64(h.Sum64())
+}
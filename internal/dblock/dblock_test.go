@@ -0,0 +1,197 @@
+package dblock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/database/databasetest"
+	"demo/internal/dblock"
+)
+
+// heartbeat is short so a lost lock is noticed within the test.
+const heartbeat = 50 * time.Millisecond
+
+// newLockers returns two Lockers on separate pools, standing in for two instances, and
+// a third pool to inspect and terminate their sessions with. The connection loss tests
+// terminate every session holding an advisory lock, so none of the pools is shared.
+func newLockers(t *testing.T) (a, b *dblock.Locker, admin *pgxpool.Pool) {
+	t.Helper()
+	opts := dblock.Options{Heartbeat: heartbeat}
+	return dblock.New(databasetest.NewPool(t), opts), dblock.New(databasetest.NewPool(t), opts), databasetest.NewPool(t)
+}
+
+func TestLockerOneWinner(t *testing.T) {
+	a, b, _ := newLockers(t)
+	key := t.Name()
+	results := make(chan bool, 2)
+	for _, l := range []*dblock.Locker{a, b} {
+		go func() {
+			release, ok, err := l.Acquire(t.Context(), key)
+			if err != nil {
+				t.Errorf("Acquire: %v", err)
+			}
+			t.Cleanup(release)
+			results <- ok
+		}()
+	}
+	if won := countTrue(<-results, <-results); won != 1 {
+		t.Fatalf("%d lockers won the lock, want 1", won)
+	}
+	if a.Held(key) == b.Held(key) {
+		t.Fatalf("Held: a=%v b=%v, want exactly one", a.Held(key), b.Held(key))
+	}
+}
+
+func TestLockerHandoverAfterRelease(t *testing.T) {
+	a, b, _ := newLockers(t)
+	key := t.Name()
+	release := mustAcquire(t, a, key)
+	mustNotAcquire(t, b, key)
+	release()
+	if a.Held(key) {
+		t.Fatal("Held after release, want false")
+	}
+	mustAcquire(t, b, key)
+	mustNotAcquire(t, a, key)
+}
+
+func TestLockerHandoverAfterConnectionLoss(t *testing.T) {
+	a, b, admin := newLockers(t)
+	key := t.Name()
+	mustAcquire(t, a, key)
+	terminateLockHolders(t, admin)
+	waitFor(t, "a to notice the lost lock", func() bool { return !a.Held(key) })
+	mustAcquire(t, b, key)
+}
+
+func TestLockerKeysIndependent(t *testing.T) {
+	a, b, _ := newLockers(t)
+	mustAcquire(t, a, t.Name()+"/a")
+	mustAcquire(t, b, t.Name()+"/b")
+	if a.Status(t.Name()+"/a") != "held" || a.Status(t.Name()+"/b") != "not held" {
+		t.Fatalf("Status: got %q and %q, want held and not held", a.Status(t.Name()+"/a"), a.Status(t.Name()+"/b"))
+	}
+}
+
+func TestLockerReleaseIdempotent(t *testing.T) {
+	a, b, _ := newLockers(t)
+	key := t.Name()
+	release := mustAcquire(t, a, key)
+	release()
+	mustAcquire(t, b, key)
+	// A second release must not touch the lock b now holds.
+	release()
+	mustNotAcquire(t, a, key)
+}
+
+func TestRunExclusiveSkipsWhenHeld(t *testing.T) {
+	a, b, _ := newLockers(t)
+	key := t.Name()
+	release := mustAcquire(t, a, key)
+	ran, err := b.RunExclusive(t.Context(), key, func(context.Context) error {
+		t.Error("fn ran while the lock was held elsewhere")
+		return nil
+	})
+	if ran || err != nil {
+		t.Fatalf("RunExclusive: ran=%v err=%v, want false and nil", ran, err)
+	}
+	release()
+
+	wantErr := errors.New("done")
+	ran, err = b.RunExclusive(t.Context(), key, func(context.Context) error {
+		if !b.Held(key) {
+			t.Error("Held inside fn, want true")
+		}
+		return wantErr
+	})
+	if !ran || !errors.Is(err, wantErr) {
+		t.Fatalf("RunExclusive: ran=%v err=%v, want true and fn's error", ran, err)
+	}
+	if b.Held(key) {
+		t.Fatal("Held after RunExclusive, want false")
+	}
+}
+
+func TestRunExclusiveCancelsOnLoss(t *testing.T) {
+	a, b, admin := newLockers(t)
+	key := t.Name()
+	ran, err := a.RunExclusive(t.Context(), key, func(ctx context.Context) error {
+		terminateLockHolders(t, admin)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return errors.New("fn was not canceled")
+		}
+	})
+	if !ran || !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunExclusive: ran=%v err=%v, want true and context.Canceled", ran, err)
+	}
+	mustAcquire(t, b, key)
+}
+
+func mustAcquire(t *testing.T, l *dblock.Locker, key string) func() {
+	t.Helper()
+	release, ok, err := l.Acquire(t.Context(), key)
+	if err != nil {
+		t.Fatalf("Acquire %q: %v", key, err)
+	}
+	if !ok {
+		t.Fatalf("Acquire %q: lock taken, want it free", key)
+	}
+	t.Cleanup(release)
+	return release
+}
+
+func mustNotAcquire(t *testing.T, l *dblock.Locker, key string) {
+	t.Helper()
+	release, ok, err := l.Acquire(t.Context(), key)
+	if err != nil {
+		t.Fatalf("Acquire %q: %v", key, err)
+	}
+	if ok {
+		release()
+		t.Fatalf("Acquire %q: won a lock held elsewhere", key)
+	}
+}
+
+// terminateLockHolders kills every other session holding an advisory lock, as a dropped
+// connection or a database failover would.
+func terminateLockHolders(t *testing.T, admin *pgxpool.Pool) {
+	t.Helper()
+	var n int
+	err := admin.QueryRow(t.Context(), `
+		SELECT count(pg_terminate_backend(pid)) FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND pid <> pg_backend_pid()`).Scan(&n)
+	if err != nil {
+		t.Fatalf("terminating lock holders: %v", err)
+	}
+	if n == 0 {
+		t.Fatal("no session held an advisory lock")
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(20 * heartbeat)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(heartbeat / 5)
+	}
+}
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
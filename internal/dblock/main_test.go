@@ -0,0 +1,12 @@
+package dblock_test
+
+import (
+	"os"
+	"testing"
+
+	"demo/internal/database/databasetest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(databasetest.Main(m))
+}
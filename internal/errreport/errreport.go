@@ -0,0 +1,99 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	appconfig "demo/internal/config"
+)
+
+// Reporter ships unexpected errors (panics, 5xx responses, upstream failures) to an
+// error tracker. Implementations must be safe for concurrent use.
+type Reporter interface {
+	CaptureError(ctx context.Context, err error, tags map[string]string)
+	// Flush blocks until buffered reports are delivered or ctx is done.
+	Flush(ctx context.Context) error
+}
+
+// Nop discards every report; it is used when no error tracker is configured.
+type Nop struct{}
+
+func (Nop) CaptureError(context.Context, error, map[string]string) {}
+
+func (Nop) Flush(context.Context) error { return nil }
+
+// New returns a Sentry reporter when cfg.DSN is set and Nop otherwise. release is
+// attached to every event, typically the build version.
+func New(cfg appconfig.SentryConfig, release string) (Reporter, error) {
+	if cfg.DSN == "" {
+		return Nop{}, nil
+	}
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          release,
+		SampleRate:       cfg.SampleRate,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+	return &sentryReporter{client: client}, nil
+}
+
+type sentryReporter struct {
+	client *sentry.Client
+}
+
+func (s *sentryReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	scope := sentry.NewScope()
+	scope.SetTags(tags)
+	s.client.CaptureException(err, &sentry.EventHint{Context: ctx, OriginalException: err}, scope)
+}
+
+func (s *sentryReporter) Flush(ctx context.Context) error {
+	if !s.client.FlushWithContext(ctx) {
+		return errors.New("timed out flushing sentry events")
+	}
+	return nil
+}
+
+// RequestTags returns the request ID and chi route pattern of r as report tags.
+func RequestTags(r *http.Request) map[string]string {
+	tags := map[string]string{"method": r.Method}
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		tags["request_id"] = id
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		tags["route"] = rctx.RoutePattern()
+	}
+	return tags
+}
+
+// CaptureStatus reports a 5xx response; other statuses are ignored.
+func CaptureStatus(rep Reporter, r *http.Request, status int, message string) {
+	if rep == nil || status < http.StatusInternalServerError {
+		return
+	}
+	tags := RequestTags(r)
+	tags["status"] = strconv.Itoa(status)
+	rep.CaptureError(r.Context(), errors.New(message), tags)
+}
+
+// PanicHook adapts rep to httpmw.Recover's reporter hook.
+func PanicHook(rep Reporter) func(ctx context.Context, r *http.Request, recovered any, stack []byte) {
+	return func(ctx context.Context, r *http.Request, recovered any, _ []byte) {
+		err, ok := recovered.(error)
+		if !ok {
+			err = fmt.Errorf("%v", recovered)
+		}
+		rep.CaptureError(ctx, fmt.Errorf("panic: %w", err), RequestTags(r))
+	}
+}
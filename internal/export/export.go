@@ -0,0 +1,490 @@
+// Package export runs pet exports in the background. Jobs are rows in the export_jobs
+// table, so a queued or interrupted export survives a restart and any instance may run it;
+// finished files live in a Storage until the retention period expires.
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/petstore"
+	"demo/internal/tenant"
+)
+
+const (
+	// staleAfter is how long a running job may go without progress before another worker
+	// assumes its instance died and queues it again.
+	staleAfter = 2 * time.Minute
+	// cleanupInterval is how often the worker requeues stale jobs and deletes expired ones.
+	cleanupInterval = time.Minute
+	// requeueTimeout bounds handing a job back when the worker stops mid-export.
+	requeueTimeout = 5 * time.Second
+)
+
+// Options configures a Service.
+type Options struct {
+	// Retention is how long finished jobs and their files are kept.
+	Retention time.Duration
+	// PollInterval is how often an idle worker looks for queued jobs that another
+	// instance created.
+	PollInterval time.Duration
+	// BatchSize is how many pets each query reads; progress is recorded after every batch.
+	BatchSize int
+	Logger    *slog.Logger
+}
+
+// Service implements petstore.Exports. Run works through the queue.
+type Service struct {
+	pool    *pgxpool.Pool
+	storage Storage
+	opts    Options
+	logger  *slog.Logger
+	// wake starts the local worker as soon as a job is created instead of at its next poll.
+	wake chan struct{}
+}
+
+// New prepares the export_jobs table.
+func New(ctx context.Context, pool *pgxpool.Pool, storage Storage, opts Options) (*Service, error) {
+	if pool == nil {
+		return nil, errors.New("pgx pool is nil")
+	}
+	if storage == nil {
+		return nil, errors.New("export storage is nil")
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	const ddl = `
+        CREATE TABLE IF NOT EXISTS export_jobs (
+            id           TEXT PRIMARY KEY,
+            status       TEXT NOT NULL DEFAULT 'queued',
+            format       TEXT NOT NULL,
+            owner_id     TEXT,
+            rows_written BIGINT NOT NULL DEFAULT 0,
+            total_rows   BIGINT,
+            error        TEXT,
+            created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+            started_at   TIMESTAMPTZ,
+            updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+            finished_at  TIMESTAMPTZ
+        );
+        CREATE INDEX IF NOT EXISTS export_jobs_status_idx ON export_jobs (status, created_at);
+        ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';`
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("failed to ensure export_jobs table: %w", err)
+	}
+	return &Service{pool: pool, storage: storage, opts: opts, logger: opts.Logger, wake: make(chan struct{}, 1)}, nil
+}
+
+// Create implements petstore.Exports. The job exports the pets of the organization on
+// ctx and is only visible within it.
+func (s *Service) Create(ctx context.Context, format petstore.ExportFormat, ownedBy string) (petstore.ExportJob, error) {
+	id, err := newID()
+	if err != nil {
+		return petstore.ExportJob{}, fmt.Errorf("failed to generate export id: %w", err)
+	}
+	row := s.pool.QueryRow(ctx, `
+        INSERT INTO export_jobs (id, format, owner_id, org_id) VALUES ($1, $2, $3, $4)
+        RETURNING `+jobColumns, id, string(format), nullable(ownedBy), tenant.FromContext(ctx))
+	job, _, err := scanJob(row)
+	if err != nil {
+		return petstore.ExportJob{}, fmt.Errorf("failed to create export job: %w", err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Get implements petstore.Exports.
+func (s *Service) Get(ctx context.Context, id, caller string) (petstore.ExportJob, error) {
+	job, owner, err := scanJob(s.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM export_jobs WHERE id = $1 AND org_id = $2`,
+		id, tenant.FromContext(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return petstore.ExportJob{}, petstore.ErrExportNotFound
+	}
+	if err != nil {
+		return petstore.ExportJob{}, fmt.Errorf("failed to fetch export job: %w", err)
+	}
+	if owner != "" && owner != caller {
+		return petstore.ExportJob{}, petstore.ErrExportNotFound
+	}
+	return job, nil
+}
+
+// Open implements petstore.Exports.
+func (s *Service) Open(ctx context.Context, id, caller string) (petstore.ExportJob, io.ReadCloser, error) {
+	job, err := s.Get(ctx, id, caller)
+	if err != nil {
+		return petstore.ExportJob{}, nil, err
+	}
+	if job.Status != petstore.Succeeded {
+		return petstore.ExportJob{}, nil, petstore.ErrExportNotReady
+	}
+	file, err := s.storage.Open(ctx, fileName(job.Id, job.Format))
+	if err != nil {
+		return petstore.ExportJob{}, nil, err
+	}
+	return job, file, nil
+}
+
+// Run executes queued jobs one at a time until ctx is done. A job interrupted by ctx is
+// discarded and queued again, so the next worker starts it over.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	var lastCleanup time.Time
+	for {
+		if time.Since(lastCleanup) >= cleanupInterval {
+			s.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+		for ctx.Err() == nil {
+			ran, err := s.runNext(ctx)
+			if err != nil && ctx.Err() == nil {
+				s.logger.Warn("export_claim_failed", "error", err)
+			}
+			if !ran {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// claim identifies one execution of a job; a job that was requeued and claimed again has
+// a new startedAt, so a worker that lost its claim stops writing progress.
+type claim struct {
+	id        string
+	format    petstore.ExportFormat
+	org       string
+	ownedBy   string
+	startedAt time.Time
+}
+
+// runNext claims the oldest queued job, if any, and runs it. It reports whether a job was
+// claimed.
+func (s *Service) runNext(ctx context.Context) (bool, error) {
+	var (
+		c     claim
+		owner *string
+	)
+	err := s.pool.QueryRow(ctx, `
+        UPDATE export_jobs SET status = 'running', started_at = now(), updated_at = now()
+        WHERE id = (
+            SELECT id FROM export_jobs WHERE status = 'queued'
+            ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, format, org_id, owner_id, started_at`).Scan(&c.id, &c.format, &c.org, &owner, &c.startedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if owner != nil {
+		c.ownedBy = *owner
+	}
+
+	started := time.Now()
+	s.logger.Info("export_job_started", "export_id", c.id, "format", c.format)
+	rows, err := s.export(ctx, c)
+	switch {
+	case err == nil:
+		s.logger.Info("export_job_succeeded", "export_id", c.id, "rows", rows, "duration", time.Since(started))
+	case ctx.Err() != nil:
+		s.requeue(c)
+	case errors.Is(err, errClaimLost):
+		s.logger.Warn("export_job_claim_lost", "export_id", c.id)
+	default:
+		s.logger.Error("export_job_failed", "export_id", c.id, "error", err)
+		s.finish(context.WithoutCancel(ctx), c, "failed", rows, err.Error())
+	}
+	return true, nil
+}
+
+// errClaimLost means the job row no longer belongs to this execution: it was requeued as
+// stale or deleted.
+var errClaimLost = errors.New("export job claim lost")
+
+// export writes the job's file and marks the job succeeded, returning the rows written.
+func (s *Service) export(ctx context.Context, c claim) (int64, error) {
+	var total int64
+	if err := s.pool.QueryRow(ctx,
+		`SELECT count(*) FROM pets WHERE org_id = $2 AND deleted_at IS NULL AND ($1::text IS NULL OR owner_id = $1)`, nullable(c.ownedBy), c.org,
+	).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count pets: %w", err)
+	}
+	if err := s.progress(ctx, c, 0, &total); err != nil {
+		return 0, err
+	}
+
+	w, err := s.storage.Create(ctx, fileName(c.id, c.format))
+	if err != nil {
+		return 0, err
+	}
+	defer w.Abort()
+	buf := bufio.NewWriter(w)
+	enc := newEncoder(buf, c.format)
+
+	var written int64
+	after := int64(math.MinInt64)
+	for {
+		batch, err := s.batch(ctx, c.org, c.ownedBy, after)
+		if err != nil {
+			return written, err
+		}
+		for _, pet := range batch {
+			// Checked per row: a batch can take a while to encode into a slow store, and a
+			// stopping worker should not finish it first.
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+			if err := enc.encode(pet); err != nil {
+				return written, fmt.Errorf("failed to write export: %w", err)
+			}
+		}
+		if err := enc.flush(); err != nil {
+			return written, fmt.Errorf("failed to write export: %w", err)
+		}
+		written += int64(len(batch))
+		if err := s.progress(ctx, c, written, nil); err != nil {
+			return written, err
+		}
+		if len(batch) < s.opts.BatchSize {
+			break
+		}
+		after = batch[len(batch)-1].Id
+	}
+
+	if err := buf.Flush(); err != nil {
+		return written, fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return written, err
+	}
+	if err := s.finish(ctx, c, "succeeded", written, ""); err != nil {
+		s.storage.Delete(context.WithoutCancel(ctx), fileName(c.id, c.format))
+		return written, err
+	}
+	return written, nil
+}
+
+// batch reads org's next pets after the given ID, in ID order.
+func (s *Service) batch(ctx context.Context, org, ownedBy string, after int64) ([]petstore.Pet, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT id, name, tag FROM pets
+        WHERE org_id = $4 AND id > $1 AND deleted_at IS NULL AND ($2::text IS NULL OR owner_id = $2)
+        ORDER BY id LIMIT $3`, after, nullable(ownedBy), s.opts.BatchSize, org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pets: %w", err)
+	}
+	defer rows.Close()
+	pets := make([]petstore.Pet, 0, s.opts.BatchSize)
+	for rows.Next() {
+		var pet petstore.Pet
+		if err := rows.Scan(&pet.Id, &pet.Name, &pet.Tag); err != nil {
+			return nil, fmt.Errorf("failed to read pets: %w", err)
+		}
+		pets = append(pets, pet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pets: %w", err)
+	}
+	return pets, nil
+}
+
+// progress records rows written, and the total when known, doubling as the job's
+// heartbeat.
+func (s *Service) progress(ctx context.Context, c claim, written int64, total *int64) error {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE export_jobs SET rows_written = $3, total_rows = COALESCE($4, total_rows), updated_at = now()
+        WHERE id = $1 AND status = 'running' AND started_at = $2`, c.id, c.startedAt, written, total)
+	if err != nil {
+		return fmt.Errorf("failed to record export progress: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errClaimLost
+	}
+	return nil
+}
+
+// finish moves the job to its final status.
+func (s *Service) finish(ctx context.Context, c claim, status string, written int64, message string) error {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE export_jobs
+        SET status = $3, rows_written = $4, error = $5, updated_at = now(), finished_at = now()
+        WHERE id = $1 AND status = 'running' AND started_at = $2`,
+		c.id, c.startedAt, status, written, nullable(message))
+	if err != nil {
+		return fmt.Errorf("failed to finish export job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errClaimLost
+	}
+	return nil
+}
+
+// requeue hands an interrupted job back to the queue; its partial file was already
+// discarded.
+func (s *Service) requeue(c claim) {
+	ctx, cancel := context.WithTimeout(context.Background(), requeueTimeout)
+	defer cancel()
+	if _, err := s.pool.Exec(ctx, `
+        UPDATE export_jobs SET status = 'queued', started_at = NULL, rows_written = 0, updated_at = now()
+        WHERE id = $1 AND status = 'running' AND started_at = $2`, c.id, c.startedAt); err != nil {
+		// The job is picked up again once it is stale.
+		s.logger.Warn("export_job_requeue_failed", "export_id", c.id, "error", err)
+		return
+	}
+	s.logger.Info("export_job_requeued", "export_id", c.id)
+}
+
+// cleanup queues jobs whose worker stopped reporting progress and deletes jobs, and their
+// files, that finished more than the retention period ago.
+func (s *Service) cleanup(ctx context.Context) {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE export_jobs SET status = 'queued', started_at = NULL, rows_written = 0, updated_at = now()
+        WHERE status = 'running' AND updated_at < now() - $1 * interval '1 second'`, staleAfter.Seconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("export_cleanup_failed", "error", err)
+		}
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		s.logger.Warn("export_jobs_stale", "requeued", n)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+        DELETE FROM export_jobs WHERE finished_at < now() - $1 * interval '1 second'
+        RETURNING id, format`, s.opts.Retention.Seconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("export_cleanup_failed", "error", err)
+		}
+		return
+	}
+	var (
+		id     string
+		format petstore.ExportFormat
+		n      int
+	)
+	// The rows are gone once the query runs, so their files are deleted even if ctx ends.
+	deleteCtx := context.WithoutCancel(ctx)
+	_, err = pgx.ForEachRow(rows, []any{&id, &format}, func() error {
+		n++
+		if err := s.storage.Delete(deleteCtx, fileName(id, format)); err != nil {
+			s.logger.Warn("export_file_delete_failed", "export_id", id, "error", err)
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		s.logger.Warn("export_cleanup_failed", "error", err)
+	}
+	if n > 0 {
+		s.logger.Info("export_jobs_expired", "deleted", n)
+	}
+}
+
+const jobColumns = `id, status, format, owner_id, rows_written, total_rows, error, created_at, finished_at`
+
+// scanJob reads jobColumns, returning the job and its owner ("" for unrestricted exports).
+func scanJob(row pgx.Row) (petstore.ExportJob, string, error) {
+	var (
+		job   petstore.ExportJob
+		owner *string
+	)
+	err := row.Scan(&job.Id, &job.Status, &job.Format, &owner, &job.RowsWritten, &job.TotalRows, &job.Error, &job.CreatedAt, &job.FinishedAt)
+	if err != nil {
+		return petstore.ExportJob{}, "", err
+	}
+	job.CreatedAt = job.CreatedAt.UTC()
+	if job.FinishedAt != nil {
+		finished := job.FinishedAt.UTC()
+		job.FinishedAt = &finished
+	}
+	if owner == nil {
+		return job, "", nil
+	}
+	return job, *owner, nil
+}
+
+// encoder writes pets in one export format.
+type encoder struct {
+	encode func(petstore.Pet) error
+	flush  func() error
+}
+
+func newEncoder(w io.Writer, format petstore.ExportFormat) encoder {
+	if format == petstore.Ndjson {
+		enc := json.NewEncoder(w)
+		return encoder{encode: func(pet petstore.Pet) error { return enc.Encode(pet) }, flush: func() error { return nil }}
+	}
+	cw := csv.NewWriter(w)
+	header := true
+	return encoder{
+		encode: func(pet petstore.Pet) error {
+			if header {
+				header = false
+				if err := cw.Write([]string{"id", "name", "tag"}); err != nil {
+					return err
+				}
+			}
+			var tag string
+			if pet.Tag != nil {
+				tag = *pet.Tag
+			}
+			return cw.Write([]string{strconv.FormatInt(pet.Id, 10), pet.Name, tag})
+		},
+		flush: func() error {
+			if header {
+				// An empty export still gets its header row.
+				header = false
+				cw.Write([]string{"id", "name", "tag"})
+			}
+			cw.Flush()
+			return cw.Error()
+		},
+	}
+}
+
+func fileName(id string, format petstore.ExportFormat) string {
+	return id + "." + string(format)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// nullable maps "" to SQL NULL.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,213 @@
+package export_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"demo/internal/blob"
+	"demo/internal/database/databasetest"
+	"demo/internal/export"
+	"demo/internal/petstore"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(databasetest.Main(m))
+}
+
+func TestStorage(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		newStorage func(t *testing.T) export.Storage
+	}{
+		{"File", func(t *testing.T) export.Storage {
+			storage, err := export.NewFileStorage(filepath.Join(t.TempDir(), "exports"))
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			return storage
+		}},
+		{"Blob", func(t *testing.T) export.Storage {
+			store, err := blob.NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStore: %v", err)
+			}
+			return export.NewBlobStorage(store)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := t.Context()
+			storage := tc.newStorage(t)
+
+			w, err := storage.Create(ctx, "committed.csv")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			io.WriteString(w, "id,name,tag\n")
+			if _, err := storage.Open(ctx, "committed.csv"); err == nil {
+				t.Fatal("Open before Close: got a file, want none until the writer commits")
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			w.Abort()
+			if got := readFile(t, storage, "committed.csv"); got != "id,name,tag\n" {
+				t.Fatalf("Open after Close: got %q", got)
+			}
+
+			w, err = storage.Create(ctx, "aborted.csv")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			io.WriteString(w, "partial")
+			w.Abort()
+			if _, err := storage.Open(ctx, "aborted.csv"); err == nil {
+				t.Fatal("Open after Abort: got a file, want none")
+			}
+
+			if err := storage.Delete(ctx, "committed.csv"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := storage.Open(ctx, "committed.csv"); err == nil {
+				t.Fatal("Open after Delete: got a file, want none")
+			}
+			if err := storage.Delete(ctx, "committed.csv"); err != nil {
+				t.Fatalf("Delete of a missing file: %v", err)
+			}
+		})
+	}
+}
+
+// TestServiceExportsPets runs the worker over five pets in batches of two, which makes it
+// page through the pets and record progress several times per export.
+func TestServiceExportsPets(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	databasetest.Truncate(t, pool)
+	repo, err := petstore.NewPostgresRepository(t.Context(), pool)
+	if err != nil {
+		t.Fatalf("NewPostgresRepository: %v", err)
+	}
+	dog := "dog"
+	for _, seed := range []struct {
+		pet   petstore.Pet
+		owner string
+	}{
+		{petstore.Pet{Id: 1, Name: "Rex", Tag: &dog}, "alice"},
+		{petstore.Pet{Id: 2, Name: "Tom"}, "bob"},
+		{petstore.Pet{Id: 3, Name: "Max, Jr."}, "alice"},
+		{petstore.Pet{Id: 4, Name: "Kit"}, ""},
+		{petstore.Pet{Id: 5, Name: "Bo"}, "bob"},
+	} {
+		if err := repo.CreatePet(t.Context(), seed.pet, seed.owner); err != nil {
+			t.Fatalf("CreatePet(%d): %v", seed.pet.Id, err)
+		}
+	}
+
+	storage, err := export.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	service, err := export.New(t.Context(), pool, storage, export.Options{
+		Retention:    time.Hour,
+		PollInterval: 10 * time.Millisecond,
+		BatchSize:    2,
+		Logger:       slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatalf("export.New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		job := awaitExport(t, service, petstore.Csv, "")
+		if job.RowsWritten != 5 || job.TotalRows == nil || *job.TotalRows != 5 {
+			t.Fatalf("export job: got %d of %v rows, want 5 of 5", job.RowsWritten, job.TotalRows)
+		}
+		want := "id,name,tag\n1,Rex,dog\n2,Tom,\n3,\"Max, Jr.\",\n4,Kit,\n5,Bo,\n"
+		if got := openExport(t, service, job.Id, ""); got != want {
+			t.Fatalf("CSV export: got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("NDJSONOwnedBy", func(t *testing.T) {
+		job := awaitExport(t, service, petstore.Ndjson, "alice")
+		var ids []int64
+		for line := range strings.Lines(openExport(t, service, job.Id, "alice")) {
+			var pet petstore.Pet
+			if err := json.Unmarshal([]byte(line), &pet); err != nil {
+				t.Fatalf("NDJSON line %q: %v", line, err)
+			}
+			ids = append(ids, pet.Id)
+		}
+		if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+			t.Fatalf("alice's NDJSON export: got pets %v, want [1 3]", ids)
+		}
+		if _, err := service.Get(t.Context(), job.Id, "bob"); !errors.Is(err, petstore.ErrExportNotFound) {
+			t.Fatalf("Get of alice's export as bob: got %v, want ErrExportNotFound", err)
+		}
+	})
+}
+
+// awaitExport creates an export as caller and waits for the worker to finish it.
+func awaitExport(t *testing.T, service *export.Service, format petstore.ExportFormat, caller string) petstore.ExportJob {
+	t.Helper()
+	job, err := service.Create(t.Context(), format, caller)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for job.Status != petstore.Succeeded {
+		if job.Status == petstore.Failed || time.Now().After(deadline) {
+			t.Fatalf("export %s: got status %s (error %v), want succeeded", job.Id, job.Status, job.Error)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if job, err = service.Get(t.Context(), job.Id, caller); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	return job
+}
+
+func openExport(t *testing.T, service *export.Service, id, caller string) string {
+	t.Helper()
+	_, body, err := service.Open(t.Context(), id, caller)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	return string(data)
+}
+
+func readFile(t *testing.T, storage export.Storage, name string) string {
+	t.Helper()
+	body, err := storage.Open(t.Context(), name)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", name, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"demo/internal/petstore"
+)
+
+// Storage holds finished export files by name. Every instance that serves downloads must
+// see the same files, so multi-instance deployments need shared storage: a shared
+// directory for FileStorage, or an object store behind BlobStorage.
+type Storage interface {
+	// Create starts writing name. The file only becomes visible to Open once the writer
+	// is committed with Close; Abort discards it, as does ctx ending before Close.
+	Create(ctx context.Context, name string) (Writer, error)
+	// Open reads name; ctx bounds the read as well as the open, so a download stops
+	// when its request does.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Delete removes name; deleting a missing file is not an error.
+	Delete(ctx context.Context, name string) error
+}
+
+// Writer is an export file being written.
+type Writer interface {
+	io.Writer
+	// Close commits the file.
+	Close() error
+	// Abort discards the file; it is a no-op after Close.
+	Abort()
+}
+
+// FileStorage keeps exports in a local directory, writing each to a temporary file that is
+// renamed into place when committed.
+type FileStorage struct {
+	dir string
+}
+
+// NewFileStorage creates dir if needed.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+// Create implements Storage.
+func (s *FileStorage) Create(_ context.Context, name string) (Writer, error) {
+	f, err := os.CreateTemp(s.dir, "."+name+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	return &fileWriter{File: f, path: filepath.Join(s.dir, name)}, nil
+}
+
+// Open implements Storage.
+func (s *FileStorage) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	return f, nil
+}
+
+// Delete implements Storage.
+func (s *FileStorage) Delete(_ context.Context, name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete export file: %w", err)
+	}
+	return nil
+}
+
+type fileWriter struct {
+	*os.File
+	path string
+	done bool
+}
+
+func (w *fileWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	if err := w.File.Sync(); err != nil {
+		w.File.Close()
+		os.Remove(w.File.Name())
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := os.Rename(w.File.Name(), w.path); err != nil {
+		os.Remove(w.File.Name())
+		return fmt.Errorf("failed to commit export file: %w", err)
+	}
+	return nil
+}
+
+func (w *fileWriter) Abort() {
+	if w.done {
+		return
+	}
+	w.done = true
+	w.File.Close()
+	os.Remove(w.File.Name())
+}
+
+// BlobStorage keeps exports in a petstore.BlobStore, such as blob.S3Store, under
+// "exports/<name>". Files are streamed to the store as they are written, and stores only
+// publish a blob once Put returns, so an aborted export leaves nothing behind.
+type BlobStorage struct {
+	store petstore.BlobStore
+}
+
+// NewBlobStorage wraps store.
+func NewBlobStorage(store petstore.BlobStore) *BlobStorage {
+	return &BlobStorage{store: store}
+}
+
+// errExportAborted fails the upload of an aborted export.
+var errExportAborted = errors.New("export aborted")
+
+// Create implements Storage.
+func (s *BlobStorage) Create(ctx context.Context, name string) (Writer, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	w := &blobWriter{pw: pw, cancel: cancel, done: make(chan error, 1)}
+	go func() {
+		err := s.store.Put(ctx, blobKey(name), petstore.BlobInfo{ContentType: exportContentType(name)}, pr)
+		// Unblocks Write should Put stop reading early.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// Open implements Storage.
+func (s *BlobStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	_, body, err := s.store.Get(ctx, blobKey(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	return body, nil
+}
+
+// Delete implements Storage.
+func (s *BlobStorage) Delete(ctx context.Context, name string) error {
+	err := s.store.Delete(ctx, blobKey(name))
+	if err != nil && !errors.Is(err, petstore.ErrBlobNotFound) {
+		return fmt.Errorf("failed to delete export file: %w", err)
+	}
+	return nil
+}
+
+func blobKey(name string) string {
+	return "exports/" + name
+}
+
+// exportContentType is the media type of an export file, judged by its extension, as the
+// download route serves it.
+func exportContentType(name string) string {
+	switch filepath.Ext(name) {
+	case "." + string(petstore.Csv):
+		return "text/csv; charset=utf-8"
+	case "." + string(petstore.Ndjson):
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+type blobWriter struct {
+	pw       *io.PipeWriter
+	cancel   context.CancelFunc
+	done     chan error
+	finished bool
+}
+
+func (w *blobWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *blobWriter) Close() error {
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+	w.pw.Close()
+	err := <-w.done
+	w.cancel()
+	if err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
+
+func (w *blobWriter) Abort() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	// Put fails on the read error, so the store keeps no partial file.
+	w.cancel()
+	w.pw.CloseWithError(errExportAborted)
+	<-w.done
+}
@@ -0,0 +1,110 @@
+package features
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Flags referenced in code. Each defaults to off unless the features section enables it.
+const (
+	// StrictJSON rejects request bodies with fields the API does not define.
+	StrictJSON = "strict_json"
+	// ProblemJSON answers errors with RFC 9457 application/problem+json documents.
+	ProblemJSON = "problem_json"
+	// LegacyAfterCursor still honours GET /pets?after=<id> from x-next links issued before
+	// signed cursors; it goes away in the next release.
+	LegacyAfterCursor = "legacy_after_cursor"
+)
+
+// Known lists every flag the code evaluates; add new constants here too.
+var Known = []string{StrictJSON, ProblemJSON, LegacyAfterCursor}
+
+// Flags holds the configured feature flags as an immutable snapshot that can be swapped
+// atomically, e.g. when SIGHUP reloads the config.
+type Flags struct {
+	snapshot atomic.Pointer[map[string]bool]
+	logger   *slog.Logger
+}
+
+// New returns Flags holding flags and logs, once, which known flags are left unset and
+// which configured flags the code never reads.
+func New(flags map[string]bool, logger *slog.Logger) *Flags {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	f := &Flags{logger: logger}
+	f.Set(flags)
+
+	for _, name := range Known {
+		if _, ok := flags[name]; !ok {
+			logger.Info("feature_flag_unset", "flag", name, "enabled", false)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		if !slices.Contains(Known, name) {
+			logger.Warn("feature_flag_unknown", "flag", name)
+		}
+	}
+	return f
+}
+
+// Set replaces the snapshot; requests already in flight keep the one they started with.
+func (f *Flags) Set(flags map[string]bool) {
+	snapshot := maps.Clone(flags)
+	if snapshot == nil {
+		snapshot = map[string]bool{}
+	}
+	old := f.snapshot.Swap(&snapshot)
+	if old == nil {
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(snapshot)) {
+		if (*old)[name] != snapshot[name] {
+			f.logger.Info("feature_flag_changed", "flag", name, "enabled", snapshot[name])
+		}
+	}
+	for name, enabled := range *old {
+		if _, ok := snapshot[name]; !ok && enabled {
+			f.logger.Info("feature_flag_changed", "flag", name, "enabled", false)
+		}
+	}
+}
+
+// Enabled reports whether name is on in the current snapshot.
+func (f *Flags) Enabled(name string) bool {
+	return (*f.snapshot.Load())[name]
+}
+
+type contextKey struct{}
+
+// Middleware attaches the current snapshot to each request so a flag reads the same value
+// for the whole request even if it is swapped midway.
+func (f *Flags) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKey{}, f.snapshot.Load())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// unknownOnce remembers names Enabled warned about, so a typo in code logs only once.
+var unknownOnce sync.Map
+
+// Enabled reports whether the flag is on for the request carried by ctx. Outside a
+// request handled by Middleware every flag is off.
+func Enabled(ctx context.Context, name string) bool {
+	if !slices.Contains(Known, name) {
+		if _, seen := unknownOnce.LoadOrStore(name, true); !seen {
+			slog.Default().WarnContext(ctx, "feature_flag_not_registered", "flag", name)
+		}
+	}
+	snapshot, ok := ctx.Value(contextKey{}).(*map[string]bool)
+	if !ok {
+		return false
+	}
+	return (*snapshot)[name]
+}
@@ -0,0 +1,94 @@
+package features_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"demo/internal/features"
+)
+
+// enabledInRequest reports what features.Enabled says about name inside a request
+// handled by f's middleware.
+func enabledInRequest(f *features.Flags, name string) bool {
+	var enabled bool
+	f.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enabled = features.Enabled(r.Context(), name)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	return enabled
+}
+
+func TestFlagsDefaultOff(t *testing.T) {
+	f := features.New(nil, slog.New(slog.DiscardHandler))
+	for _, name := range features.Known {
+		if f.Enabled(name) || enabledInRequest(f, name) {
+			t.Errorf("%s: on without configuration, want off", name)
+		}
+	}
+	if features.Enabled(context.Background(), features.StrictJSON) {
+		t.Error("Enabled outside a request: got on, want off")
+	}
+}
+
+func TestFlagsExplicitlyOn(t *testing.T) {
+	f := features.New(map[string]bool{features.StrictJSON: true, features.ProblemJSON: false}, slog.New(slog.DiscardHandler))
+	if !f.Enabled(features.StrictJSON) || !enabledInRequest(f, features.StrictJSON) {
+		t.Errorf("%s: off, want on", features.StrictJSON)
+	}
+	if f.Enabled(features.ProblemJSON) || enabledInRequest(f, features.ProblemJSON) {
+		t.Errorf("%s: on, want off as configured", features.ProblemJSON)
+	}
+}
+
+// TestFlagsHotSwap checks that Set changes what new requests see while a request already
+// in flight keeps the snapshot it started with.
+func TestFlagsHotSwap(t *testing.T) {
+	var logs bytes.Buffer
+	f := features.New(nil, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var before, after bool
+	f.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before = features.Enabled(r.Context(), features.ProblemJSON)
+		f.Set(map[string]bool{features.ProblemJSON: true})
+		after = features.Enabled(r.Context(), features.ProblemJSON)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if before || after {
+		t.Fatalf("in-flight request: got %v then %v, want off throughout", before, after)
+	}
+	if !f.Enabled(features.ProblemJSON) || !enabledInRequest(f, features.ProblemJSON) {
+		t.Fatal("after Set: off, want on")
+	}
+	if !strings.Contains(logs.String(), "msg=feature_flag_changed flag=problem_json enabled=true") {
+		t.Fatalf("logs: got %q, want the change", logs.String())
+	}
+
+	f.Set(nil)
+	if f.Enabled(features.ProblemJSON) || enabledInRequest(f, features.ProblemJSON) {
+		t.Fatal("after Set(nil): on, want off")
+	}
+	if !strings.Contains(logs.String(), "msg=feature_flag_changed flag=problem_json enabled=false") {
+		t.Fatalf("logs: got %q, want the flag switched back off", logs.String())
+	}
+}
+
+// TestFlagsStartupLog checks that New logs known flags left unset and configured flags no
+// code reads.
+func TestFlagsStartupLog(t *testing.T) {
+	var logs bytes.Buffer
+	features.New(map[string]bool{features.StrictJSON: true, "strict_jsn": true}, slog.New(slog.NewTextHandler(&logs, nil)))
+	for _, want := range []string{
+		"msg=feature_flag_unset flag=problem_json enabled=false",
+		"msg=feature_flag_unknown flag=strict_jsn",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs: got %q, want %q", logs.String(), want)
+		}
+	}
+	if strings.Contains(logs.String(), "flag=strict_json") {
+		t.Errorf("logs: got %q, want nothing about the configured strict_json", logs.String())
+	}
+}
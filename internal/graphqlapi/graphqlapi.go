@@ -0,0 +1,174 @@
+// Package graphqlapi serves the pet API as GraphQL at POST <base_path>/graphql. Resolvers
+// go through the same PetRepository as the REST routes and apply the same validation,
+// role, and owner rules, chosen per operation because every GraphQL request is a POST.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+	"demo/internal/auth/store"
+	"demo/internal/httpmw"
+	"demo/internal/petstore"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// Pager pages through pets by keyset; PostgresRepository implements it.
+type Pager interface {
+	ListPetsAfter(ctx context.Context, after int64, limit int32, ownedBy string) ([]petstore.Pet, error)
+}
+
+// OwnerLookup maps pet IDs to the IDs of the users who created them; PostgresRepository
+// implements it.
+type OwnerLookup interface {
+	PetOwners(ctx context.Context, ids []int64) (map[int64]string, error)
+}
+
+// Options configures a Handler.
+type Options struct {
+	// Pets serves lookups and writes. Pass the same, possibly cached, repository as the
+	// REST routes so writes invalidate its caches.
+	Pets   petstore.PetRepository
+	Pager  Pager
+	Owners OwnerLookup
+	// Users names owners who signed in; nil leaves owner names null.
+	Users store.UserRepository
+	// Introspection answers __schema and __type queries.
+	Introspection bool
+	// EnforceRoles and ReadRole mirror security.enforce_roles and security.read_role.
+	EnforceRoles bool
+	ReadRole     auth.Role
+	// RequireAuthForWrites mirrors security.require_auth_for_writes for mutations.
+	RequireAuthForWrites bool
+	Logger               *slog.Logger
+}
+
+// Handler executes GraphQL requests. It must run after auth.Authenticator.Middleware
+// with writes allowed through, since queries and mutations share a method.
+type Handler struct {
+	schema *graphql.Schema
+	opts   Options
+}
+
+// maxDepth bounds query nesting; the schema is only a few levels deep.
+const maxDepth = 10
+
+// New parses the schema against the resolvers.
+func New(opts Options) (*Handler, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	schemaOpts := []graphql.SchemaOpt{graphql.MaxDepth(maxDepth)}
+	if !opts.Introspection {
+		schemaOpts = append(schemaOpts, graphql.DisableIntrospection())
+	}
+	parsed, err := graphql.ParseSchema(schema, &resolver{opts: opts}, schemaOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema: parsed, opts: opts}, nil
+}
+
+// ServeHTTP answers 400 for bodies that are not a GraphQL request; everything else,
+// including resolver errors, is a 200 with an errors array, as GraphQL clients expect.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpmw.WriteError(w, r, apierr.ErrBodyTooLarge, "request body too large")
+			return
+		}
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, "invalid GraphQL request body")
+		return
+	}
+	if params.Query == "" {
+		httpmw.WriteError(w, r, apierr.ErrInvalidRequest, "query is required")
+		return
+	}
+
+	ctx := withOwners(r.Context(), h.ownerLoader(r.Context()))
+	response := h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.opts.Logger.ErrorContext(r.Context(), "graphql_encode_failed", "error", err)
+	}
+}
+
+// ownerLoader resolves pet IDs to owners in two queries per batch: pet to owner ID, then
+// the users among those owners.
+func (h *Handler) ownerLoader(ctx context.Context) *loader[int64, owner] {
+	r := &resolver{opts: h.opts}
+	return newLoader(ctx, func(ctx context.Context, petIDs []int64) (map[int64]owner, error) {
+		owners, err := h.loadOwners(ctx, petIDs)
+		if err != nil {
+			return nil, r.fail(ctx, "owner", err)
+		}
+		return owners, nil
+	})
+}
+
+func (h *Handler) loadOwners(ctx context.Context, petIDs []int64) (map[int64]owner, error) {
+	ownerIDs, err := h.opts.Owners.PetOwners(ctx, petIDs)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[int64]owner, len(ownerIDs))
+	for petID, ownerID := range ownerIDs {
+		owners[petID] = owner{id: ownerID}
+	}
+	if h.opts.Users == nil || len(owners) == 0 {
+		return owners, nil
+	}
+
+	var userIDs []int64
+	for _, o := range owners {
+		if id, ok := o.userID(); ok {
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) == 0 {
+		return owners, nil
+	}
+	users, err := h.opts.Users.ListUsers(ctx, store.UserFilter{IDs: userIDs, Limit: len(userIDs)})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	for petID, o := range owners {
+		if id, ok := o.userID(); ok {
+			if name, known := names[id]; known {
+				o.name = &name
+				owners[petID] = o
+			}
+		}
+	}
+	return owners, nil
+}
+
+type ownersKey struct{}
+
+func withOwners(ctx context.Context, l *loader[int64, owner]) context.Context {
+	return context.WithValue(ctx, ownersKey{}, l)
+}
+
+func ownersFrom(ctx context.Context) *loader[int64, owner] {
+	return ctx.Value(ownersKey{}).(*loader[int64, owner])
+}
@@ -0,0 +1,206 @@
+package graphqlapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"demo/internal/auth"
+	"demo/internal/graphqlapi"
+	"demo/internal/petstore"
+)
+
+var (
+	alice = auth.User{ID: "1", Method: "session", Role: auth.RoleEditor}
+	bob   = auth.User{ID: "2", Method: "session", Role: auth.RoleEditor}
+	admin = auth.User{ID: "3", Method: "session", Role: auth.RoleAdmin}
+)
+
+func tag(s string) *string { return &s }
+
+// newHandler serves a memory repository holding Rex and Tom, owned by alice, and Fido,
+// owned by bob.
+func newHandler(t *testing.T, opts graphqlapi.Options) (*graphqlapi.Handler, *petstore.MemoryRepository) {
+	t.Helper()
+	repo := petstore.NewMemoryRepository()
+	for _, seed := range []struct {
+		pet   petstore.Pet
+		owner string
+	}{
+		{petstore.Pet{Id: 1, Name: "Rex", Tag: tag("dog")}, alice.ID},
+		{petstore.Pet{Id: 2, Name: "Tom"}, alice.ID},
+		{petstore.Pet{Id: 3, Name: "Fido", Tag: tag("dog")}, bob.ID},
+	} {
+		if err := repo.CreatePet(t.Context(), seed.pet, seed.owner); err != nil {
+			t.Fatalf("CreatePet(%d): %v", seed.pet.Id, err)
+		}
+	}
+	opts.Pets, opts.Pager, opts.Owners = repo, repo, repo
+	opts.Logger = slog.New(slog.DiscardHandler)
+	h, err := graphqlapi.New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h, repo
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// execute posts query as user, or anonymously when user is nil, and decodes the response.
+func execute(t *testing.T, h http.Handler, user *auth.User, query string, variables map[string]any) response {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		t.Fatalf("encode request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	if user != nil {
+		req = req.WithContext(auth.ContextWithUser(req.Context(), *user))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /graphql: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %s: %v", rec.Body, err)
+	}
+	return resp
+}
+
+// assertData compares the response's data with want, ignoring whitespace in want.
+func assertData(t *testing.T, resp response, want string) {
+	t.Helper()
+	if len(resp.Errors) != 0 {
+		t.Fatalf("errors: got %+v, want none", resp.Errors)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(want)); err != nil {
+		t.Fatalf("compact %s: %v", want, err)
+	}
+	if string(resp.Data) != compact.String() {
+		t.Fatalf("data:\ngot  %s\nwant %s", resp.Data, compact.String())
+	}
+}
+
+func TestQuery(t *testing.T) {
+	h, _ := newHandler(t, graphqlapi.Options{})
+
+	t.Run("Pet", func(t *testing.T) {
+		resp := execute(t, h, nil, `query($id: ID!) { pet(id: $id) { id name tag owner { id name } } }`, map[string]any{"id": "3"})
+		assertData(t, resp, `{"pet": {"id": "3", "name": "Fido", "tag": "dog", "owner": {"id": "2", "name": null}}}`)
+	})
+	t.Run("PetNotFound", func(t *testing.T) {
+		assertData(t, execute(t, h, nil, `{ pet(id: "99") { id } }`, nil), `{"pet": null}`)
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		const query = `query($after: String) {
+			pets(first: 2, after: $after) { edges { node { id name owner { id } } } pageInfo { hasNextPage endCursor } }
+		}`
+		first := execute(t, h, nil, query, nil)
+		var page struct {
+			Pets struct {
+				PageInfo struct{ EndCursor string } `json:"pageInfo"`
+			} `json:"pets"`
+		}
+		if err := json.Unmarshal(first.Data, &page); err != nil {
+			t.Fatalf("decode first page: %v", err)
+		}
+		assertData(t, first, `{"pets": {
+			"edges": [{"node": {"id": "1", "name": "Rex", "owner": {"id": "1"}}}, {"node": {"id": "2", "name": "Tom", "owner": {"id": "1"}}}],
+			"pageInfo": {"hasNextPage": true, "endCursor": "`+page.Pets.PageInfo.EndCursor+`"}
+		}}`)
+
+		second := execute(t, h, nil, query, map[string]any{"after": page.Pets.PageInfo.EndCursor})
+		if !strings.Contains(string(second.Data), `"edges":[{"node":{"id":"3","name":"Fido","owner":{"id":"2"}}}]`) ||
+			!strings.Contains(string(second.Data), `"hasNextPage":false`) {
+			t.Fatalf("second page: got %s, want only Fido and no next page", second.Data)
+		}
+	})
+
+	t.Run("Mine", func(t *testing.T) {
+		resp := execute(t, h, &bob, `{ pets(filter: {mine: true}) { edges { node { id } } } }`, nil)
+		assertData(t, resp, `{"pets": {"edges": [{"node": {"id": "3"}}]}}`)
+	})
+}
+
+func TestMutation(t *testing.T) {
+	h, repo := newHandler(t, graphqlapi.Options{EnforceRoles: true, RequireAuthForWrites: true})
+
+	resp := execute(t, h, &alice, `mutation { createPet(input: {id: "4", name: "  Kitty ", tag: "cat"}) { id name tag owner { id } } }`, nil)
+	assertData(t, resp, `{"createPet": {"id": "4", "name": "Kitty", "tag": "cat", "owner": {"id": "1"}}}`)
+
+	resp = execute(t, h, &alice, `mutation($input: PetInput!) { updatePet(input: $input) { id name tag } }`,
+		map[string]any{"input": map[string]any{"id": "4", "name": "Tabby"}})
+	assertData(t, resp, `{"updatePet": {"id": "4", "name": "Tabby", "tag": null}}`)
+	if pet, err := repo.GetPet(t.Context(), 4); err != nil || pet.Name != "Tabby" || pet.Tag != nil {
+		t.Fatalf("GetPet after updatePet: got %+v, %v", pet, err)
+	}
+
+	assertData(t, execute(t, h, &admin, `mutation { deletePet(id: "4") }`, nil), `{"deletePet": "4"}`)
+	if _, err := repo.GetPet(t.Context(), 4); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("GetPet after deletePet: got %v, want ErrPetNotFound", err)
+	}
+}
+
+// TestRejected checks that an operation the REST routes would refuse fails with a coded
+// error and leaves the repository unchanged.
+func TestRejected(t *testing.T) {
+	viewer := auth.User{ID: "4", Method: "token", Role: auth.RoleViewer}
+	for _, tc := range []struct {
+		name  string
+		opts  graphqlapi.Options
+		user  *auth.User
+		query string
+		code  string
+	}{
+		{"WriteWithoutCaller", graphqlapi.Options{RequireAuthForWrites: true}, nil, `mutation { createPet(input: {id: "9", name: "Kitty"}) { id } }`, "UNAUTHENTICATED"},
+		{"WriteAsViewer", graphqlapi.Options{EnforceRoles: true}, &viewer, `mutation { createPet(input: {id: "9", name: "Kitty"}) { id } }`, "FORBIDDEN"},
+		{"DeleteAsEditor", graphqlapi.Options{EnforceRoles: true}, &alice, `mutation { deletePet(id: "1") }`, "FORBIDDEN"},
+		{"UpdateOthersPet", graphqlapi.Options{EnforceRoles: true}, &alice, `mutation { updatePet(input: {id: "3", name: "Rover"}) { id } }`, "FORBIDDEN"},
+		{"ReadBelowReadRole", graphqlapi.Options{EnforceRoles: true, ReadRole: auth.RoleEditor}, &viewer, `{ pets { edges { node { id } } } }`, "FORBIDDEN"},
+		{"ReadWithoutCaller", graphqlapi.Options{EnforceRoles: true, ReadRole: auth.RoleViewer}, nil, `{ pet(id: "1") { id } }`, "UNAUTHENTICATED"},
+		{"MineWithoutCaller", graphqlapi.Options{}, nil, `{ pets(filter: {mine: true}) { edges { node { id } } } }`, "UNAUTHENTICATED"},
+		{"InvalidPet", graphqlapi.Options{}, &alice, `mutation { createPet(input: {id: "9", name: ""}) { id } }`, "BAD_USER_INPUT"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, repo := newHandler(t, tc.opts)
+			resp := execute(t, h, tc.user, tc.query, nil)
+			if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != tc.code {
+				t.Fatalf("errors: got %+v, want one with code %s", resp.Errors, tc.code)
+			}
+
+			pets, err := repo.ListPets(t.Context(), 10, "")
+			if err != nil {
+				t.Fatalf("ListPets: %v", err)
+			}
+			if len(pets) != 3 || pets[0].Name != "Rex" || pets[2].Name != "Fido" {
+				t.Fatalf("pets after a rejected operation: got %+v, want the seeded three unchanged", pets)
+			}
+		})
+	}
+}
+
+func TestInvalidRequest(t *testing.T) {
+	h, _ := newHandler(t, graphqlapi.Options{})
+	for _, body := range []string{`not json`, `{}`, `{"query": ""}`} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: got %d, want 400", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batchWait is how long the first Load of a batch waits for sibling resolvers, which the
+// executor runs concurrently, to add their keys.
+const batchWait = 2 * time.Millisecond
+
+// loader batches and caches lookups for one request, so resolving a field across a list
+// issues one fetch instead of one per item.
+type loader[K comparable, V any] struct {
+	ctx   context.Context
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu        sync.Mutex
+	results   map[K]*loadResult[V]
+	pending   []K
+	scheduled bool
+}
+
+type loadResult[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+func newLoader[K comparable, V any](ctx context.Context, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{ctx: ctx, fetch: fetch, results: make(map[K]*loadResult[V])}
+}
+
+// Prime queues keys for the next batch without starting it, so a list resolver can hand
+// over every key it returned and the first Load fetches them all at once.
+func (l *loader[K, V]) Prime(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		l.enqueue(key)
+	}
+}
+
+// Load returns the value for key, and whether the fetch found one.
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	result := l.enqueue(key)
+	if len(l.pending) > 0 && !l.scheduled {
+		l.scheduled = true
+		time.AfterFunc(batchWait, l.dispatch)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-result.done:
+		return result.value, result.found, result.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+// enqueue returns the result for key, adding key to the pending batch if it is new. The
+// caller holds mu.
+func (l *loader[K, V]) enqueue(key K) *loadResult[V] {
+	if result, ok := l.results[key]; ok {
+		return result
+	}
+	result := &loadResult[V]{done: make(chan struct{})}
+	l.results[key] = result
+	l.pending = append(l.pending, key)
+	return result
+}
+
+func (l *loader[K, V]) dispatch() {
+	l.mu.Lock()
+	keys := l.pending
+	l.pending, l.scheduled = nil, false
+	l.mu.Unlock()
+
+	values, err := l.fetch(l.ctx, keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		result := l.results[key]
+		result.value, result.found = values[key]
+		result.err = err
+		close(result.done)
+	}
+}
@@ -0,0 +1,350 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"demo/internal/auth"
+	"demo/internal/petstore"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type resolver struct {
+	opts Options
+}
+
+// Error codes reported in each error's extensions.
+const (
+	codeBadInput        = "BAD_USER_INPUT"
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeForbidden       = "FORBIDDEN"
+	codeNotFound        = "NOT_FOUND"
+	codeConflict        = "CONFLICT"
+	codeTimeout         = "TIMEOUT"
+	codeUnavailable     = "UNAVAILABLE"
+	codeInternal        = "INTERNAL"
+)
+
+// resolverError is a client-facing error with a machine-readable code.
+type resolverError struct {
+	message string
+	code    string
+}
+
+func (e *resolverError) Error() string { return e.message }
+
+func (e *resolverError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func badInput(message string) error {
+	return &resolverError{message: message, code: codeBadInput}
+}
+
+// fail maps repository and authorization errors onto the messages the REST routes use,
+// logging anything unexpected instead of exposing it.
+func (r *resolver) fail(ctx context.Context, op string, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrAuthenticationRequired):
+		return &resolverError{message: "authentication required", code: codeUnauthenticated}
+	case errors.Is(err, auth.ErrForbidden):
+		_, reason, _ := strings.Cut(err.Error(), ": ")
+		return &resolverError{message: reason, code: codeForbidden}
+	case errors.Is(err, petstore.ErrPetNotFound):
+		return &resolverError{message: "pet not found", code: codeNotFound}
+	case errors.Is(err, petstore.ErrPetExists):
+		return &resolverError{message: "pet already exists", code: codeConflict}
+	case errors.Is(err, petstore.ErrNotPetOwner):
+		return &resolverError{message: "pet is owned by another user", code: codeForbidden}
+	case errors.Is(err, petstore.ErrCircuitOpen):
+		return &resolverError{message: "database is temporarily unavailable", code: codeUnavailable}
+	case errors.Is(err, petstore.ErrQueryTimeout), errors.Is(err, context.DeadlineExceeded):
+		r.opts.Logger.WarnContext(ctx, "graphql "+op+": repo timeout", "error", err)
+		return &resolverError{message: "database query timed out", code: codeTimeout}
+	default:
+		r.opts.Logger.ErrorContext(ctx, "graphql "+op+": repo error", "error", err)
+		return &resolverError{message: "internal error", code: codeInternal}
+	}
+}
+
+// authorize applies the REST route policy to one operation: reads need
+// security.read_role, writes need editor, and deletes need admin, each only with
+// security.enforce_roles; writes always need a caller with security.require_auth_for_writes.
+func (r *resolver) authorize(ctx context.Context, role auth.Role, write bool) error {
+	if write && r.opts.RequireAuthForWrites {
+		if _, ok := auth.UserFromContext(ctx); !ok {
+			return auth.ErrAuthenticationRequired
+		}
+	}
+	if !r.opts.EnforceRoles || role == "" {
+		return nil
+	}
+	return auth.Authorize(ctx, role)
+}
+
+// requiredOwner restricts updates and deletes by non-admins to their own pets, as the
+// REST routes do with security.enforce_roles.
+func (r *resolver) requiredOwner(ctx context.Context) string {
+	if !r.opts.EnforceRoles {
+		return ""
+	}
+	user, ok := auth.UserFromContext(ctx)
+	if !ok || user.Role.Allows(auth.RoleAdmin) {
+		return ""
+	}
+	return user.ID
+}
+
+// audit logs a successful write like the REST handlers do.
+func (r *resolver) audit(ctx context.Context, op string, petID int64) {
+	attrs := []any{"pet_id", petID, "api", "graphql"}
+	if user, ok := auth.UserFromContext(ctx); ok {
+		attrs = append(attrs, "user_id", user.ID, "auth_method", user.Method)
+	}
+	r.opts.Logger.InfoContext(ctx, op+": audit", attrs...)
+}
+
+func (r *resolver) Pet(ctx context.Context, args struct{ ID graphql.ID }) (*petResolver, error) {
+	if err := r.authorize(ctx, r.opts.ReadRole, false); err != nil {
+		return nil, r.fail(ctx, "pet", err)
+	}
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	pet, err := r.opts.Pets.GetPet(ctx, id)
+	if errors.Is(err, petstore.ErrPetNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, r.fail(ctx, "pet", err)
+	}
+	return &petResolver{pet: pet}, nil
+}
+
+type petFilter struct {
+	Mine *bool
+}
+
+type petsArgs struct {
+	Filter *petFilter
+	First  *int32
+	After  *string
+}
+
+func (r *resolver) Pets(ctx context.Context, args petsArgs) (*connectionResolver, error) {
+	if err := r.authorize(ctx, r.opts.ReadRole, false); err != nil {
+		return nil, r.fail(ctx, "pets", err)
+	}
+	var ownedBy string
+	if args.Filter != nil && args.Filter.Mine != nil && *args.Filter.Mine {
+		user, ok := auth.UserFromContext(ctx)
+		if !ok {
+			return nil, &resolverError{message: "mine requires authentication", code: codeUnauthenticated}
+		}
+		ownedBy = user.ID
+	}
+	first := int32(defaultPageSize)
+	if args.First != nil {
+		if first = *args.First; first <= 0 {
+			return nil, badInput("first must be positive")
+		}
+		first = min(first, maxPageSize)
+	}
+	after := int64(math.MinInt64)
+	if args.After != nil {
+		var err error
+		if after, err = decodeCursor(*args.After); err != nil {
+			return nil, badInput("after is not a valid cursor")
+		}
+	}
+
+	// One extra row tells whether another page follows.
+	pets, err := r.opts.Pager.ListPetsAfter(ctx, after, first+1, ownedBy)
+	if err != nil {
+		return nil, r.fail(ctx, "pets", err)
+	}
+	conn := &connectionResolver{hasNext: len(pets) > int(first)}
+	if conn.hasNext {
+		pets = pets[:first]
+	}
+	ids := make([]int64, len(pets))
+	for i, pet := range pets {
+		ids[i] = pet.Id
+		conn.edges = append(conn.edges, &edgeResolver{pet: pet})
+	}
+	// Owners of the whole page load in one batch if the query selects them.
+	ownersFrom(ctx).Prime(ids...)
+	return conn, nil
+}
+
+type petInput struct {
+	ID   graphql.ID
+	Name string
+	Tag  *string
+}
+
+func (in petInput) pet() (petstore.Pet, error) {
+	id, err := parseID(in.ID)
+	if err != nil {
+		return petstore.Pet{}, err
+	}
+	pet := petstore.Pet{Id: id, Name: in.Name, Tag: in.Tag}.Normalize()
+	if err := petstore.ValidatePet(pet); err != nil {
+		return petstore.Pet{}, badInput(err.Error())
+	}
+	return pet, nil
+}
+
+func (r *resolver) CreatePet(ctx context.Context, args struct{ Input petInput }) (*petResolver, error) {
+	if err := r.authorize(ctx, auth.RoleEditor, true); err != nil {
+		return nil, r.fail(ctx, "createPet", err)
+	}
+	pet, err := args.Input.pet()
+	if err != nil {
+		return nil, err
+	}
+	var owner string
+	if user, ok := auth.UserFromContext(ctx); ok {
+		owner = user.ID
+	}
+	if err := r.opts.Pets.CreatePet(ctx, pet, owner); err != nil {
+		return nil, r.fail(ctx, "createPet", err)
+	}
+	r.audit(ctx, "createPet", pet.Id)
+	return &petResolver{pet: pet}, nil
+}
+
+func (r *resolver) UpdatePet(ctx context.Context, args struct{ Input petInput }) (*petResolver, error) {
+	if err := r.authorize(ctx, auth.RoleEditor, true); err != nil {
+		return nil, r.fail(ctx, "updatePet", err)
+	}
+	pet, err := args.Input.pet()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.opts.Pets.UpdatePet(ctx, pet, r.requiredOwner(ctx)); err != nil {
+		return nil, r.fail(ctx, "updatePet", err)
+	}
+	r.audit(ctx, "updatePet", pet.Id)
+	return &petResolver{pet: pet}, nil
+}
+
+func (r *resolver) DeletePet(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	if err := r.authorize(ctx, auth.RoleAdmin, true); err != nil {
+		return "", r.fail(ctx, "deletePet", err)
+	}
+	id, err := parseID(args.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := r.opts.Pets.DeletePet(ctx, id, r.requiredOwner(ctx)); err != nil {
+		return "", r.fail(ctx, "deletePet", err)
+	}
+	r.audit(ctx, "deletePet", id)
+	return args.ID, nil
+}
+
+type petResolver struct {
+	pet petstore.Pet
+}
+
+func (p *petResolver) ID() graphql.ID { return graphql.ID(strconv.FormatInt(p.pet.Id, 10)) }
+func (p *petResolver) Name() string   { return p.pet.Name }
+func (p *petResolver) Tag() *string   { return p.pet.Tag }
+
+func (p *petResolver) Owner(ctx context.Context) (*owner, error) {
+	o, found, err := ownersFrom(ctx).Load(ctx, p.pet.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+// owner is the creator of a pet: a user ID for people who signed in, or an API token's
+// "token:<name>".
+type owner struct {
+	id   string
+	name *string
+}
+
+func (o *owner) ID() graphql.ID { return graphql.ID(o.id) }
+func (o *owner) Name() *string  { return o.name }
+
+// userID parses the users table ID out of o, when it is one.
+func (o *owner) userID() (int64, bool) {
+	id, err := strconv.ParseInt(o.id, 10, 64)
+	return id, err == nil
+}
+
+type connectionResolver struct {
+	edges   []*edgeResolver
+	hasNext bool
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver { return c.edges }
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNext: c.hasNext}
+	if len(c.edges) > 0 {
+		cursor := c.edges[len(c.edges)-1].Cursor()
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+type edgeResolver struct {
+	pet petstore.Pet
+}
+
+func (e *edgeResolver) Cursor() string     { return encodeCursor(e.pet.Id) }
+func (e *edgeResolver) Node() *petResolver { return &petResolver{pet: e.pet} }
+
+type pageInfoResolver struct {
+	hasNext   bool
+	endCursor *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool  { return p.hasNext }
+func (p *pageInfoResolver) EndCursor() *string { return p.endCursor }
+
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, badInput("id must be an integer")
+	}
+	return n, nil
+}
+
+// Cursors are opaque to clients but are just the last pet's ID, so a page is a keyset
+// query for the IDs after it.
+const cursorPrefix = "pet:"
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return 0, errors.New("unknown cursor")
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
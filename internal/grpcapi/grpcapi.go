@@ -0,0 +1,200 @@
+// Package grpcapi serves the petstore.v1.PetStore gRPC service on its own listener
+// (grpc.address) for internal callers, alongside the standard health service and, when
+// enabled, server reflection. RPCs go through the same PetRepository, validation, role,
+// and owner rules as the REST routes.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"demo/api/petstorev1"
+	"demo/internal/auth"
+	"demo/internal/petstore"
+	"demo/internal/tenant"
+)
+
+// Pager pages through pets by keyset; PostgresRepository implements it.
+type Pager interface {
+	ListPetsAfter(ctx context.Context, after int64, limit int32, ownedBy string) ([]petstore.Pet, error)
+}
+
+// Options configures a Server.
+type Options struct {
+	// Pets serves lookups and writes. Pass the same, possibly cached, repository as the
+	// REST routes so writes invalidate its caches.
+	Pets  petstore.PetRepository
+	Pager Pager
+	// Changes feeds WatchPets; nil answers it with Unimplemented.
+	Changes petstore.ChangeFeed
+	// WatchInterval is how often WatchPets polls the feed once it has caught up.
+	WatchInterval time.Duration
+	// Tokens verifies bearer tokens in the authorization metadata; nil treats every call
+	// as anonymous.
+	Tokens auth.TokenVerifier
+	// Orgs resolves the organization named by x-org-id metadata; nil resolves with no
+	// memberships, so callers act in their token's organization or the default one.
+	Orgs *tenant.Resolver
+	// EnforceRoles and ReadRole mirror security.enforce_roles and security.read_role.
+	EnforceRoles bool
+	ReadRole     auth.Role
+	// RequireAuthForWrites mirrors security.require_auth_for_writes.
+	RequireAuthForWrites bool
+	// Reflection registers the server reflection service for tools like grpcurl.
+	Reflection bool
+	Logger     *slog.Logger
+}
+
+// Server is the gRPC server and the services registered on it.
+type Server struct {
+	petstorev1.UnimplementedPetStoreServer
+
+	grpc   *grpc.Server
+	health *health.Server
+	opts   Options
+
+	// stopping is closed by Shutdown so WatchPets streams end instead of holding up
+	// GracefulStop.
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// New builds the server and registers its services.
+func New(opts Options) *Server {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.WatchInterval <= 0 {
+		opts.WatchInterval = time.Second
+	}
+	if opts.Orgs == nil {
+		opts.Orgs = tenant.NewResolver(nil, opts.Logger)
+	}
+	s := &Server{
+		health:   health.NewServer(),
+		opts:     opts,
+		stopping: make(chan struct{}),
+	}
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.authenticateUnary),
+		grpc.ChainStreamInterceptor(s.authenticateStream),
+	)
+	petstorev1.RegisterPetStoreServer(s.grpc, s)
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	s.health.SetServingStatus(petstorev1.PetStore_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	if opts.Reflection {
+		reflection.Register(s.grpc)
+	}
+	return s
+}
+
+// Serve accepts connections on ln until Shutdown.
+func (s *Server) Serve(ln net.Listener) error {
+	return s.grpc.Serve(ln)
+}
+
+// Shutdown reports NOT_SERVING to health checks, ends open WatchPets streams, and waits
+// for in-flight calls until ctx is done, then closes the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	s.stopOnce.Do(func() { close(s.stopping) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate attaches the caller named by a bearer token in the authorization metadata,
+// and the organization they act in. Calls without a token stay anonymous and are judged
+// by each RPC; a token that does not verify is always rejected, since gRPC clients never
+// send one by accident. An organization the caller does not belong to is NotFound, as on
+// the REST routes.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	user, err := s.verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		ctx = auth.ContextWithUser(ctx, *user)
+	}
+	var requested string
+	if values := metadata.ValueFromIncomingContext(ctx, "x-org-id"); len(values) > 0 {
+		requested = values[0]
+	}
+	org, err := s.opts.Orgs.Resolve(ctx, user, requested)
+	if errors.Is(err, tenant.ErrNotMember) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	if err != nil {
+		s.opts.Logger.ErrorContext(ctx, "grpc_org_resolution_failed", "error", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return tenant.WithOrg(ctx, org), nil
+}
+
+// verify returns the caller named by the bearer token, or nil for anonymous calls.
+func (s *Server) verify(ctx context.Context) (*auth.User, error) {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return nil, nil
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" || s.opts.Tokens == nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	user, err := s.opts.Tokens.VerifyToken(ctx, strings.TrimSpace(token))
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	if err != nil {
+		s.opts.Logger.ErrorContext(ctx, "grpc_token_verification_failed", "error", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	user.Method = "token"
+	return &user, nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context { return s.ctx }
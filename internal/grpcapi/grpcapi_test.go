@@ -0,0 +1,307 @@
+package grpcapi_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"demo/api/petstorev1"
+	"demo/internal/auth"
+	"demo/internal/config"
+	"demo/internal/grpcapi"
+	"demo/internal/petstore"
+)
+
+const (
+	viewerToken = "viewer-token-0123456789"
+	editorToken = "editor-token-0123456789"
+	adminToken  = "admin-token-0123456789"
+)
+
+// testServer is a grpcapi.Server over an in-memory repository, reached through an
+// in-process connection.
+type testServer struct {
+	*grpcapi.Server
+	client petstorev1.PetStoreClient
+	health healthpb.HealthClient
+}
+
+// newTestServer serves opts, filling in the repository, a token per role, and a discard
+// logger where opts leaves them unset.
+func newTestServer(t *testing.T, opts grpcapi.Options) *testServer {
+	t.Helper()
+	if opts.Pets == nil {
+		pets := petstore.NewMemoryRepository()
+		opts.Pets, opts.Pager = pets, pets
+	}
+	if opts.Tokens == nil {
+		opts.Tokens = auth.NewStaticTokens([]config.APITokenConfig{
+			{Name: "viewer", Token: viewerToken, Role: "viewer"},
+			{Name: "editor", Token: editorToken, Role: "editor"},
+			{Name: "admin", Token: adminToken, Role: "admin"},
+		})
+	}
+	opts.Logger = slog.New(slog.DiscardHandler)
+	server := grpcapi.New(opts)
+
+	ln := bufconn.Listen(1 << 20)
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testServer{Server: server, client: petstorev1.NewPetStoreClient(conn), health: healthpb.NewHealthClient(conn)}
+}
+
+// as returns a context whose calls carry token, or none when it is empty.
+func as(t *testing.T, token string) context.Context {
+	if token == "" {
+		return t.Context()
+	}
+	return metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer "+token)
+}
+
+func assertCode(t *testing.T, op string, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("%s: got %v, want %s", op, err, want)
+	}
+}
+
+func TestPetLifecycle(t *testing.T) {
+	srv := newTestServer(t, grpcapi.Options{EnforceRoles: true, ReadRole: auth.RoleViewer, RequireAuthForWrites: true})
+	tag := "dog"
+
+	created, err := srv.client.CreatePet(as(t, editorToken), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: 1, Name: "  Rex ", Tag: &tag}})
+	if err != nil || created.GetName() != "Rex" {
+		t.Fatalf("CreatePet: got %v, %v; want Rex with the name normalized", created, err)
+	}
+	got, err := srv.client.GetPet(as(t, viewerToken), &petstorev1.GetPetRequest{Id: 1})
+	if err != nil || got.GetName() != "Rex" || got.GetTag() != "dog" {
+		t.Fatalf("GetPet: got %v, %v; want Rex the dog", got, err)
+	}
+	if _, err := srv.client.UpdatePet(as(t, editorToken), &petstorev1.UpdatePetRequest{Pet: &petstorev1.Pet{Id: 1, Name: "Max"}}); err != nil {
+		t.Fatalf("UpdatePet: %v", err)
+	}
+
+	_, err = srv.client.DeletePet(as(t, editorToken), &petstorev1.DeletePetRequest{Id: 1})
+	assertCode(t, "DeletePet as editor", err, codes.PermissionDenied)
+	if _, err := srv.client.DeletePet(as(t, adminToken), &petstorev1.DeletePetRequest{Id: 1}); err != nil {
+		t.Fatalf("DeletePet as admin: %v", err)
+	}
+	_, err = srv.client.GetPet(as(t, viewerToken), &petstorev1.GetPetRequest{Id: 1})
+	assertCode(t, "GetPet after DeletePet", err, codes.NotFound)
+}
+
+func TestErrors(t *testing.T) {
+	srv := newTestServer(t, grpcapi.Options{EnforceRoles: true, ReadRole: auth.RoleViewer, RequireAuthForWrites: true})
+	if _, err := srv.client.CreatePet(as(t, adminToken), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: 1, Name: "Rex"}}); err != nil {
+		t.Fatalf("CreatePet: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"AnonymousRead", func() error {
+			_, err := srv.client.GetPet(as(t, ""), &petstorev1.GetPetRequest{Id: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"AnonymousWrite", func() error {
+			_, err := srv.client.CreatePet(as(t, ""), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: 2, Name: "Max"}})
+			return err
+		}, codes.Unauthenticated},
+		{"InvalidToken", func() error {
+			_, err := srv.client.GetPet(as(t, "not-a-token"), &petstorev1.GetPetRequest{Id: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"ViewerWrite", func() error {
+			_, err := srv.client.CreatePet(as(t, viewerToken), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: 2, Name: "Max"}})
+			return err
+		}, codes.PermissionDenied},
+		{"NotOwner", func() error {
+			_, err := srv.client.UpdatePet(as(t, editorToken), &petstorev1.UpdatePetRequest{Pet: &petstorev1.Pet{Id: 1, Name: "Max"}})
+			return err
+		}, codes.PermissionDenied},
+		{"Exists", func() error {
+			_, err := srv.client.CreatePet(as(t, editorToken), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: 1, Name: "Rex"}})
+			return err
+		}, codes.AlreadyExists},
+		{"MissingPet", func() error {
+			_, err := srv.client.CreatePet(as(t, editorToken), &petstorev1.CreatePetRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"EmptyName", func() error {
+			_, err := srv.client.CreatePet(as(t, editorToken), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: 2, Name: " "}})
+			return err
+		}, codes.InvalidArgument},
+		{"NegativePageSize", func() error {
+			_, err := srv.client.ListPets(as(t, viewerToken), &petstorev1.ListPetsRequest{PageSize: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"BadPageToken", func() error {
+			_, err := srv.client.ListPets(as(t, viewerToken), &petstorev1.ListPetsRequest{PageToken: "bm90LWEtdG9rZW4"})
+			return err
+		}, codes.InvalidArgument},
+		{"AnonymousMine", func() error {
+			_, err := newTestServer(t, grpcapi.Options{}).client.ListPets(as(t, ""), &petstorev1.ListPetsRequest{Mine: true})
+			return err
+		}, codes.Unauthenticated},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assertCode(t, tc.name, tc.call(), tc.want)
+		})
+	}
+}
+
+func TestListPetsPages(t *testing.T) {
+	srv := newTestServer(t, grpcapi.Options{})
+	for id := int64(1); id <= 5; id++ {
+		token := editorToken
+		if id%2 == 0 {
+			token = adminToken
+		}
+		if _, err := srv.client.CreatePet(as(t, token), &petstorev1.CreatePetRequest{Pet: &petstorev1.Pet{Id: id, Name: "Pet"}}); err != nil {
+			t.Fatalf("CreatePet(%d): %v", id, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		mine bool
+		want []int64
+	}{
+		{"All", false, []int64{1, 2, 3, 4, 5}},
+		{"Mine", true, []int64{1, 3, 5}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int64
+			req := &petstorev1.ListPetsRequest{PageSize: 2, Mine: tc.mine}
+			for range 10 {
+				resp, err := srv.client.ListPets(as(t, editorToken), req)
+				if err != nil {
+					t.Fatalf("ListPets: %v", err)
+				}
+				for _, pet := range resp.GetPets() {
+					got = append(got, pet.GetId())
+				}
+				if resp.GetNextPageToken() == "" {
+					break
+				}
+				req.PageToken = resp.GetNextPageToken()
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("ListPets pages: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// fakeFeed is a ChangeFeed over a slice that tests append to.
+type fakeFeed struct {
+	mu      sync.Mutex
+	changes []petstore.PetChange
+}
+
+func (f *fakeFeed) ListChanges(_ context.Context, since int64, limit int32) ([]petstore.PetChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []petstore.PetChange
+	for _, change := range f.changes {
+		if change.Seq > since && len(out) < int(limit) {
+			out = append(out, change)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeFeed) add(op petstore.PetChangeOp, petID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	change := petstore.PetChange{Seq: int64(len(f.changes) + 1), Op: op, PetId: petID, ChangedAt: time.Now()}
+	if op != petstore.Delete {
+		change.Payload = &petstore.Pet{Id: petID, Name: "Rex"}
+	}
+	f.changes = append(f.changes, change)
+}
+
+func TestWatchPets(t *testing.T) {
+	feed := &fakeFeed{}
+	feed.add(petstore.Create, 1)
+	feed.add(petstore.Update, 1)
+	srv := newTestServer(t, grpcapi.Options{Changes: feed, WatchInterval: 10 * time.Millisecond})
+
+	stream, err := srv.client.WatchPets(t.Context(), &petstorev1.WatchPetsRequest{Since: 1})
+	if err != nil {
+		t.Fatalf("WatchPets: %v", err)
+	}
+	change, err := stream.Recv()
+	if err != nil || change.GetSeq() != 2 || change.GetOp() != petstorev1.PetChange_OP_UPDATE || change.GetPet().GetName() != "Rex" {
+		t.Fatalf("first change after seq 1: got %v, %v; want the update with seq 2", change, err)
+	}
+
+	// Changes committed while the stream waits arrive at the next poll.
+	feed.add(petstore.Delete, 1)
+	change, err = stream.Recv()
+	if err != nil || change.GetSeq() != 3 || change.GetOp() != petstorev1.PetChange_OP_DELETE || change.GetPet() != nil {
+		t.Fatalf("change made while watching: got %v, %v; want the delete with seq 3 and no pet", change, err)
+	}
+
+	// Shutdown ends the stream instead of waiting for the client to leave.
+	if err := srv.Shutdown(t.Context()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := stream.Recv(); err == io.EOF || status.Code(err) != codes.Unavailable {
+		t.Fatalf("Recv after Shutdown: got %v, want Unavailable", err)
+	}
+}
+
+func TestWatchPetsWithoutFeed(t *testing.T) {
+	srv := newTestServer(t, grpcapi.Options{})
+	stream, err := srv.client.WatchPets(t.Context(), &petstorev1.WatchPetsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	assertCode(t, "WatchPets without a feed", err, codes.Unimplemented)
+}
+
+func TestHealth(t *testing.T) {
+	srv := newTestServer(t, grpcapi.Options{})
+	req := &healthpb.HealthCheckRequest{Service: petstorev1.PetStore_ServiceDesc.ServiceName}
+	resp, err := srv.health.Check(t.Context(), req)
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health check: got %v, %v; want SERVING", resp, err)
+	}
+
+	watch, err := srv.health.Watch(t.Context(), req)
+	if err != nil {
+		t.Fatalf("health watch: %v", err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health watch: got %v, %v; want SERVING", resp, err)
+	}
+	go srv.Shutdown(context.Background())
+	if resp, err := watch.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("health watch during Shutdown: got %v, %v; want NOT_SERVING", resp, err)
+	}
+}
@@ -0,0 +1,289 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"demo/api/petstorev1"
+	"demo/internal/auth"
+	"demo/internal/petstore"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// watchBatch is how many changes WatchPets reads per poll; a full batch is followed
+	// by another poll straight away.
+	watchBatch = 500
+)
+
+// statusError maps repository and authorization errors onto gRPC codes with the messages
+// the REST routes use, logging anything unexpected instead of exposing it.
+func (s *Server) statusError(ctx context.Context, op string, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrAuthenticationRequired):
+		return status.Error(codes.Unauthenticated, "authentication required")
+	case errors.Is(err, auth.ErrForbidden):
+		_, reason, _ := strings.Cut(err.Error(), ": ")
+		return status.Error(codes.PermissionDenied, reason)
+	case errors.Is(err, petstore.ErrPetNotFound):
+		return status.Error(codes.NotFound, "pet not found")
+	case errors.Is(err, petstore.ErrPetExists):
+		return status.Error(codes.AlreadyExists, "pet already exists")
+	case errors.Is(err, petstore.ErrNotPetOwner):
+		return status.Error(codes.PermissionDenied, "pet is owned by another user")
+	case errors.Is(err, petstore.ErrCircuitOpen):
+		return status.Error(codes.Unavailable, "database is temporarily unavailable")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, petstore.ErrQueryTimeout), errors.Is(err, context.DeadlineExceeded):
+		s.opts.Logger.WarnContext(ctx, "grpc "+op+": repo timeout", "error", err)
+		return status.Error(codes.DeadlineExceeded, "database query timed out")
+	default:
+		s.opts.Logger.ErrorContext(ctx, "grpc "+op+": repo error", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// authorize applies the REST route policy to one RPC: reads need security.read_role,
+// writes need editor, and deletes need admin, each only with security.enforce_roles;
+// writes always need a caller with security.require_auth_for_writes.
+func (s *Server) authorize(ctx context.Context, role auth.Role, write bool) error {
+	if write && s.opts.RequireAuthForWrites {
+		if _, ok := auth.UserFromContext(ctx); !ok {
+			return auth.ErrAuthenticationRequired
+		}
+	}
+	if !s.opts.EnforceRoles || role == "" {
+		return nil
+	}
+	return auth.Authorize(ctx, role)
+}
+
+// requiredOwner restricts updates and deletes by non-admins to their own pets, as the
+// REST routes do with security.enforce_roles.
+func (s *Server) requiredOwner(ctx context.Context) string {
+	if !s.opts.EnforceRoles {
+		return ""
+	}
+	user, ok := auth.UserFromContext(ctx)
+	if !ok || user.Role.Allows(auth.RoleAdmin) {
+		return ""
+	}
+	return user.ID
+}
+
+// audit logs a successful write like the REST handlers do.
+func (s *Server) audit(ctx context.Context, op string, petID int64) {
+	attrs := []any{"pet_id", petID, "api", "grpc"}
+	if user, ok := auth.UserFromContext(ctx); ok {
+		attrs = append(attrs, "user_id", user.ID, "auth_method", user.Method)
+	}
+	s.opts.Logger.InfoContext(ctx, op+": audit", attrs...)
+}
+
+func (s *Server) ListPets(ctx context.Context, req *petstorev1.ListPetsRequest) (*petstorev1.ListPetsResponse, error) {
+	if err := s.authorize(ctx, s.opts.ReadRole, false); err != nil {
+		return nil, s.statusError(ctx, "ListPets", err)
+	}
+	var ownedBy string
+	if req.GetMine() {
+		user, ok := auth.UserFromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "mine requires authentication")
+		}
+		ownedBy = user.ID
+	}
+	size := req.GetPageSize()
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = defaultPageSize
+	}
+	size = min(size, maxPageSize)
+	after := int64(math.MinInt64)
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		if after, err = decodePageToken(token); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "page_token is not valid")
+		}
+	}
+
+	// One extra row tells whether another page follows.
+	pets, err := s.opts.Pager.ListPetsAfter(ctx, after, size+1, ownedBy)
+	if err != nil {
+		return nil, s.statusError(ctx, "ListPets", err)
+	}
+	resp := &petstorev1.ListPetsResponse{}
+	if len(pets) > int(size) {
+		pets = pets[:size]
+		resp.NextPageToken = encodePageToken(pets[len(pets)-1].Id)
+	}
+	for _, pet := range pets {
+		resp.Pets = append(resp.Pets, toProto(pet))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetPet(ctx context.Context, req *petstorev1.GetPetRequest) (*petstorev1.Pet, error) {
+	if err := s.authorize(ctx, s.opts.ReadRole, false); err != nil {
+		return nil, s.statusError(ctx, "GetPet", err)
+	}
+	pet, err := s.opts.Pets.GetPet(ctx, req.GetId())
+	if err != nil {
+		return nil, s.statusError(ctx, "GetPet", err)
+	}
+	return toProto(pet), nil
+}
+
+func (s *Server) CreatePet(ctx context.Context, req *petstorev1.CreatePetRequest) (*petstorev1.Pet, error) {
+	if err := s.authorize(ctx, auth.RoleEditor, true); err != nil {
+		return nil, s.statusError(ctx, "CreatePet", err)
+	}
+	pet, err := fromProto(req.GetPet())
+	if err != nil {
+		return nil, err
+	}
+	var owner string
+	if user, ok := auth.UserFromContext(ctx); ok {
+		owner = user.ID
+	}
+	if err := s.opts.Pets.CreatePet(ctx, pet, owner); err != nil {
+		return nil, s.statusError(ctx, "CreatePet", err)
+	}
+	s.audit(ctx, "CreatePet", pet.Id)
+	return toProto(pet), nil
+}
+
+func (s *Server) UpdatePet(ctx context.Context, req *petstorev1.UpdatePetRequest) (*petstorev1.Pet, error) {
+	if err := s.authorize(ctx, auth.RoleEditor, true); err != nil {
+		return nil, s.statusError(ctx, "UpdatePet", err)
+	}
+	pet, err := fromProto(req.GetPet())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.opts.Pets.UpdatePet(ctx, pet, s.requiredOwner(ctx)); err != nil {
+		return nil, s.statusError(ctx, "UpdatePet", err)
+	}
+	s.audit(ctx, "UpdatePet", pet.Id)
+	return toProto(pet), nil
+}
+
+func (s *Server) DeletePet(ctx context.Context, req *petstorev1.DeletePetRequest) (*petstorev1.DeletePetResponse, error) {
+	if err := s.authorize(ctx, auth.RoleAdmin, true); err != nil {
+		return nil, s.statusError(ctx, "DeletePet", err)
+	}
+	if err := s.opts.Pets.DeletePet(ctx, req.GetId(), s.requiredOwner(ctx)); err != nil {
+		return nil, s.statusError(ctx, "DeletePet", err)
+	}
+	s.audit(ctx, "DeletePet", req.GetId())
+	return &petstorev1.DeletePetResponse{}, nil
+}
+
+// WatchPets replays the change feed from since and then polls it every WatchInterval.
+// The stream ends when the client goes away or the server shuts down, and the client
+// resumes with the last seq it received.
+func (s *Server) WatchPets(req *petstorev1.WatchPetsRequest, stream grpc.ServerStreamingServer[petstorev1.PetChange]) error {
+	ctx := stream.Context()
+	if s.opts.Changes == nil {
+		return status.Error(codes.Unimplemented, "change feed is not available")
+	}
+	if err := s.authorize(ctx, s.opts.ReadRole, false); err != nil {
+		return s.statusError(ctx, "WatchPets", err)
+	}
+	if req.GetSince() < 0 {
+		return status.Error(codes.InvalidArgument, "since must not be negative")
+	}
+
+	since := req.GetSince()
+	ticker := time.NewTicker(s.opts.WatchInterval)
+	defer ticker.Stop()
+	for {
+		changes, err := s.opts.Changes.ListChanges(ctx, since, watchBatch)
+		if err != nil {
+			return s.statusError(ctx, "WatchPets", err)
+		}
+		for _, change := range changes {
+			if err := stream.Send(changeToProto(change)); err != nil {
+				return err
+			}
+			since = change.Seq
+		}
+		if len(changes) == watchBatch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+func toProto(pet petstore.Pet) *petstorev1.Pet {
+	return &petstorev1.Pet{Id: pet.Id, Name: pet.Name, Tag: pet.Tag}
+}
+
+func fromProto(pet *petstorev1.Pet) (petstore.Pet, error) {
+	if pet == nil {
+		return petstore.Pet{}, status.Error(codes.InvalidArgument, "pet is required")
+	}
+	out := petstore.Pet{Id: pet.GetId(), Name: pet.GetName(), Tag: pet.Tag}.Normalize()
+	if err := petstore.ValidatePet(out); err != nil {
+		return petstore.Pet{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return out, nil
+}
+
+var changeOps = map[petstore.PetChangeOp]petstorev1.PetChange_Op{
+	petstore.Create: petstorev1.PetChange_OP_CREATE,
+	petstore.Update: petstorev1.PetChange_OP_UPDATE,
+	petstore.Delete: petstorev1.PetChange_OP_DELETE,
+}
+
+func changeToProto(change petstore.PetChange) *petstorev1.PetChange {
+	out := &petstorev1.PetChange{
+		Seq:       change.Seq,
+		Op:        changeOps[change.Op],
+		PetId:     change.PetId,
+		ChangedAt: timestamppb.New(change.ChangedAt),
+	}
+	if change.Payload != nil {
+		out.Pet = toProto(*change.Payload)
+	}
+	return out
+}
+
+// Page tokens are opaque to clients but are just the last pet's ID, so a page is a
+// keyset query for the IDs after it.
+const pageTokenPrefix = "pet:"
+
+func encodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(raw), pageTokenPrefix)
+	if !ok {
+		return 0, errors.New("unknown page token")
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Handler serves liveness and readiness probes.
+type Handler struct {
+	logger       *slog.Logger
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       map[string]Check
+	statuses     map[string]func() string
+	shuttingDown atomic.Bool
+}
+
+// NewHandler constructs a probe handler; timeout bounds each readiness run (0 uses a default).
+func NewHandler(timeout time.Duration, logger *slog.Logger) *Handler {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Handler{logger: logger, timeout: timeout, checks: make(map[string]Check), statuses: make(map[string]func() string)}
+}
+
+// Register adds a named dependency check consulted by Readyz.
+func (h *Handler) Register(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// RegisterStatus adds an informational entry to the Readyz report that never fails the
+// probe, e.g. whether maintenance mode is on.
+func (h *Handler) RegisterStatus(name string, status func() string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses[name] = status
+}
+
+// SetShuttingDown makes Readyz fail so load balancers drain the instance.
+func (h *Handler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Healthz reports liveness; it succeeds for as long as the process can serve requests.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz runs every registered check concurrently and returns 503 if any fails.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	statuses := make(map[string]func() string, len(h.statuses))
+	for name, status := range h.statuses {
+		statuses[name] = status
+	}
+	h.mu.RUnlock()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	report := make(map[string]string, len(names)+len(statuses)+1)
+	for i, name := range names {
+		if err := results[i]; err != nil {
+			h.logger.WarnContext(r.Context(), "readiness_check_failed", "dependency", name, "error", err)
+			report[name] = err.Error()
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		report[name] = "ok"
+	}
+
+	for name, status := range statuses {
+		report[name] = status()
+	}
+
+	if h.shuttingDown.Load() {
+		report["server"] = "shutting down"
+		status = http.StatusServiceUnavailable
+	}
+
+	h.writeJSON(w, status, report)
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		h.logger.Error("health_response_write_failed", "error", err)
+	}
+}
@@ -0,0 +1,66 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/health"
+)
+
+func readyz(t *testing.T, h *health.Handler) (int, map[string]string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode /readyz: %v", err)
+	}
+	return rec.Code, report
+}
+
+// TestReadyzClosedPool checks that a pool closed under the handler, as during shutdown,
+// fails readiness with its error rather than panicking.
+func TestReadyzClosedPool(t *testing.T) {
+	// The pool connects lazily, so nothing needs to listen at the address.
+	pool, err := pgxpool.New(t.Context(), "postgres://petstore@127.0.0.1:1/petstore?sslmode=disable")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	pool.Close()
+
+	h := health.NewHandler(0, slog.New(slog.DiscardHandler))
+	h.Register("database", pool.Ping)
+	h.Register("cache", func(context.Context) error { return nil })
+
+	status, report := readyz(t, h)
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("status: got %d, want 503", status)
+	}
+	if report["database"] != "closed pool" || report["cache"] != "ok" {
+		t.Fatalf("report: got %v, want database: closed pool and cache: ok", report)
+	}
+}
+
+func TestReadyzShuttingDown(t *testing.T) {
+	h := health.NewHandler(0, slog.New(slog.DiscardHandler))
+	h.Register("database", func(context.Context) error { return nil })
+	if status, report := readyz(t, h); status != http.StatusOK || report["database"] != "ok" {
+		t.Fatalf("before shutdown: got %d %v, want 200", status, report)
+	}
+
+	h.SetShuttingDown()
+	if status, report := readyz(t, h); status != http.StatusServiceUnavailable || report["server"] != "shutting down" {
+		t.Fatalf("during shutdown: got %d %v, want 503 and server: shutting down", status, report)
+	}
+	rec := httptest.NewRecorder()
+	h.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/healthz during shutdown: got %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,200 @@
+// Package httpclient hands out the outbound HTTP clients, one per name, configured from
+// http_clients: timeouts, proxy, idle connections, and TLS. Every client records request
+// durations and transport errors by client name and host, and can log each request at
+// debug level, so calls to third parties are visible in one place whoever makes them.
+package httpclient
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"demo/internal/config"
+)
+
+// Defaults for settings a client leaves at zero.
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultConnectTimeout = 5 * time.Second
+	defaultMaxIdleConns   = 100
+)
+
+// Registry builds and keeps the clients. It is a prometheus.Collector for their metrics.
+type Registry struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	clients map[string]*http.Client
+
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// New builds a client for every entry of configs, so a bad proxy URL or unreadable
+// certificate fails startup rather than the first request. Names without an entry get
+// the defaults when first asked for.
+func New(configs map[string]config.HTTPClientConfig, logger *slog.Logger) (*Registry, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &Registry{
+		logger:  logger,
+		clients: make(map[string]*http.Client),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "petstore",
+			Subsystem: "http_client",
+			Name:      "request_duration_seconds",
+			Help:      "Outbound HTTP request latency by client name, host, and status class (error when no response arrived).",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"client", "host", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "petstore",
+			Subsystem: "http_client",
+			Name:      "request_errors_total",
+			Help:      "Outbound HTTP requests that got no response, by client name and host.",
+		}, []string{"client", "host"}),
+	}
+	for name, cfg := range configs {
+		client, err := r.build(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.clients[name] = client
+	}
+	return r, nil
+}
+
+// Client returns the client called name, building one with the defaults for a name
+// http_clients does not configure. Callers share it and must not modify it.
+func (r *Registry) Client(name string) *http.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.clients[name]; ok {
+		return client
+	}
+	// The zero config needs no files and no parsing, so it cannot fail.
+	client, _ := r.build(name, config.HTTPClientConfig{})
+	r.clients[name] = client
+	return client
+}
+
+// Describe implements prometheus.Collector.
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+	r.duration.Describe(ch)
+	r.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	r.duration.Collect(ch)
+	r.errors.Collect(ch)
+}
+
+func (r *Registry) build(name string, cfg config.HTTPClientConfig) (*http.Client, error) {
+	connectTimeout := cmp.Or(cfg.ConnectTimeout, defaultConnectTimeout)
+	maxIdle := cmp.Or(cfg.MaxIdleConns, defaultMaxIdleConns)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http_clients.%s.proxy_url: %w", name, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.MaxIdleConns = maxIdle
+	transport.MaxIdleConnsPerHost = maxIdle
+	tlsConfig, err := tlsConfig(name, cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Timeout: cmp.Or(cfg.Timeout, defaultTimeout),
+		Transport: &instrumentedTransport{
+			next:     transport,
+			name:     name,
+			registry: r,
+			log:      cfg.LogRequests,
+		},
+	}, nil
+}
+
+func tlsConfig(name string, cfg config.HTTPClientTLSConfig) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.MinVersion == "1.3" {
+		tc.MinVersion = tls.VersionTLS13
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read http_clients.%s.tls.ca_file: %w", name, err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("http_clients.%s.tls.ca_file %s contains no PEM certificates", name, cfg.CAFile)
+		}
+		tc.RootCAs = roots
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load http_clients.%s.tls client certificate: %w", name, err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// instrumentedTransport records every round trip in the registry's metrics and, with log
+// set, logs it at debug level. Query strings are left out of the log since they may carry
+// credentials.
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	name     string
+	registry *Registry
+	log      bool
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	host := req.URL.Host
+	status := "error"
+	if err != nil {
+		t.registry.errors.WithLabelValues(t.name, host).Inc()
+	} else {
+		status = fmt.Sprintf("%dxx", resp.StatusCode/100)
+	}
+	t.registry.duration.WithLabelValues(t.name, host, status).Observe(elapsed.Seconds())
+
+	if t.log {
+		attrs := []any{"client", t.name, "method", req.Method, "host", host, "path", req.URL.Path, "duration", elapsed}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		} else {
+			attrs = append(attrs, "status", resp.StatusCode)
+		}
+		t.registry.logger.DebugContext(req.Context(), "http_client_request", attrs...)
+	}
+	return resp, err
+}
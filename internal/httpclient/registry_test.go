@@ -0,0 +1,194 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"demo/internal/config"
+	"demo/internal/httpclient"
+)
+
+func TestRegistryProxy(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy receives the absolute URL of the origin it is asked to reach.
+		mu.Lock()
+		seen = append(seen, r.URL.String())
+		mu.Unlock()
+		io.WriteString(w, "via proxy")
+	}))
+	t.Cleanup(proxy.Close)
+
+	reg := mustNew(t, map[string]config.HTTPClientConfig{
+		"proxied": {ProxyURL: proxy.URL},
+	})
+	body := get(t, reg.Client("proxied"), "http://upstream.invalid/hello?x=1")
+	if body != "via proxy" {
+		t.Fatalf("got body %q, want the proxy's answer", body)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 1 || seen[0] != "http://upstream.invalid/hello?x=1" {
+		t.Fatalf("proxy saw %q, want the one request to upstream.invalid", seen)
+	}
+}
+
+func TestRegistryMetricsPerClient(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(origin.Close)
+	host := mustHost(t, origin.URL)
+
+	reg := mustNew(t, map[string]config.HTTPClientConfig{"a": {}, "b": {}})
+	get(t, reg.Client("a"), origin.URL+"/ok")
+	get(t, reg.Client("a"), origin.URL+"/ok")
+	get(t, reg.Client("b"), origin.URL+"/fail")
+
+	metrics := scrape(t, reg)
+	for _, want := range []string{
+		fmt.Sprintf(`petstore_http_client_request_duration_seconds_count{client="a",host=%q,status="2xx"} 2`, host),
+		fmt.Sprintf(`petstore_http_client_request_duration_seconds_count{client="b",host=%q,status="5xx"} 1`, host),
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	for _, unwanted := range []string{`client="a",host="` + host + `",status="5xx"`, `client="b",host="` + host + `",status="2xx"`} {
+		if strings.Contains(metrics, unwanted) {
+			t.Errorf("metrics attribute a request to the wrong client: %s", unwanted)
+		}
+	}
+}
+
+func TestRegistryTransportErrors(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	host := mustHost(t, closed.URL)
+
+	reg := mustNew(t, nil)
+	if resp, err := reg.Client("broken").Get(closed.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	metrics := scrape(t, reg)
+	for _, want := range []string{
+		fmt.Sprintf(`petstore_http_client_request_errors_total{client="broken",host=%q} 1`, host),
+		fmt.Sprintf(`petstore_http_client_request_duration_seconds_count{client="broken",host=%q,status="error"} 1`, host),
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
+
+func TestRegistryUnconfiguredName(t *testing.T) {
+	reg := mustNew(t, nil)
+	client := reg.Client("unconfigured")
+	if client == nil || client.Timeout <= 0 {
+		t.Fatalf("got client %+v, want one with the default timeout", client)
+	}
+	if again := reg.Client("unconfigured"); again != client {
+		t.Fatal("a second Client call built a new client, want the same one")
+	}
+}
+
+func TestRegistryLogRequests(t *testing.T) {
+	origin := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(origin.Close)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	reg, err := httpclient.New(map[string]config.HTTPClientConfig{
+		"logged": {LogRequests: true},
+		"quiet":  {},
+	}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	get(t, reg.Client("logged"), origin.URL+"/path?token=secret")
+	get(t, reg.Client("quiet"), origin.URL+"/quiet")
+
+	logs := buf.String()
+	if !strings.Contains(logs, "msg=http_client_request") || !strings.Contains(logs, "client=logged") || !strings.Contains(logs, "status=404") {
+		t.Fatalf("log_requests client logged %q, want its request with client and status", logs)
+	}
+	if strings.Contains(logs, "secret") {
+		t.Fatalf("request log includes the query string: %q", logs)
+	}
+	if strings.Contains(logs, "client=quiet") {
+		t.Fatalf("client without log_requests was logged: %q", logs)
+	}
+}
+
+func TestNewBadCAFile(t *testing.T) {
+	_, err := httpclient.New(map[string]config.HTTPClientConfig{
+		"bad": {TLS: config.HTTPClientTLSConfig{CAFile: t.TempDir() + "/missing.pem"}},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "http_clients.bad.tls.ca_file") {
+		t.Fatalf("New: got %v, want an error naming http_clients.bad.tls.ca_file", err)
+	}
+}
+
+func mustNew(t *testing.T, clients map[string]config.HTTPClientConfig) *httpclient.Registry {
+	t.Helper()
+	reg, err := httpclient.New(clients, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return reg
+}
+
+func get(t *testing.T, client *http.Client, target string) string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: reading body: %v", target, err)
+	}
+	return string(body)
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse %s: %v", rawURL, err)
+	}
+	return u.Host
+}
+
+// scrape registers reg alone and returns its metrics in the text exposition format.
+func scrape(t *testing.T, reg *httpclient.Registry) string {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(reg)
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
@@ -0,0 +1,38 @@
+package httpmw
+
+import (
+	"net/http"
+	"strings"
+
+	"demo/internal/apierr"
+)
+
+// BodyLimit caps request bodies at def bytes, or at the override for the request's route
+// (keys as in Timeout; 0 means unlimited). Reads past the limit fail with
+// *http.MaxBytesError, which handlers should map to 413. Like Timeout it must be mounted
+// on route groups so the route pattern is known.
+func BodyLimit(def int64, overrides map[string]int64) func(http.Handler) http.Handler {
+	normalized := make(map[string]int64, len(overrides))
+	for key, n := range overrides {
+		normalized[strings.ToLower(strings.TrimSpace(key))] = n
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := def
+			if len(normalized) > 0 {
+				if n, ok := routeOverride(r, normalized); ok {
+					limit = n
+				}
+			}
+			if limit > 0 && r.Body != nil {
+				if r.ContentLength > limit {
+					WriteError(w, r, apierr.ErrBodyTooLarge, "request body too large")
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package httpmw_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/httpmw"
+)
+
+// readBody answers 204 after reading the whole body, or 413 if that hits the limit.
+var readBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var tooLarge *http.MaxBytesError
+	if _, err := io.ReadAll(r.Body); errors.As(err, &tooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func TestBodyLimit(t *testing.T) {
+	var reached bool
+	handler := httpmw.BodyLimit(8, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		readBody(w, r)
+	}))
+
+	rec := serve(handler, httptest.NewRequest(http.MethodPost, "/pets", strings.NewReader(`{"name":"Rex"}`)))
+	assertErrorBody(t, rec, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE")
+	if reached {
+		t.Fatal("oversize Content-Length: handler ran, want the request rejected up front")
+	}
+
+	// Without a Content-Length the limit applies while the handler reads.
+	req := httptest.NewRequest(http.MethodPost, "/pets", io.NopCloser(strings.NewReader(`{"name":"Rex"}`)))
+	req.ContentLength = -1
+	if rec := serve(handler, req); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversize chunked body: got %d, want 413", rec.Code)
+	}
+
+	if rec := serve(handler, httptest.NewRequest(http.MethodPost, "/pets", strings.NewReader(`{"a":1}`))); rec.Code != http.StatusNoContent {
+		t.Fatalf("body within the limit: got %d", rec.Code)
+	}
+}
+
+func TestBodyLimitRouteOverrides(t *testing.T) {
+	limit := httpmw.BodyLimit(8, map[string]int64{"PUT /pets/{petId}/photo": 0})
+	r := chi.NewRouter()
+	r.With(limit).Put("/pets/{petId}/photo", readBody)
+	r.With(limit).Put("/pets/{petId}", readBody)
+
+	body := strings.Repeat("x", 64)
+	if rec := serve(r, httptest.NewRequest(http.MethodPut, "/pets/1/photo", strings.NewReader(body))); rec.Code != http.StatusNoContent {
+		t.Fatalf("unlimited route: got %d, want 204", rec.Code)
+	}
+	assertErrorBody(t, serve(r, httptest.NewRequest(http.MethodPut, "/pets/1", strings.NewReader(body))), http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE")
+}
@@ -0,0 +1,247 @@
+package httpmw
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder is the subset of gzip.Writer and zstd.Encoder used by Compress.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	"zstd": {New: func() any {
+		// Each response is encoded serially, so one encoder goroutine is enough.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// Compress encodes responses with the best encoding the client accepts from encodings
+// (in server preference order; "gzip" and "zstd" are supported). Only bodies of at least
+// minSize bytes whose media type matches contentTypes ("application/json", "text/*") are
+// compressed, so small payloads, streams such as text/event-stream, and already-encoded
+// responses pass through untouched. Flush is honoured for streaming handlers.
+func Compress(minSize int, contentTypes, encodings []string) func(http.Handler) http.Handler {
+	var supported []string
+	for _, name := range encodings {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := encoderPools[name]; ok {
+			supported = append(supported, name)
+		}
+	}
+	allowed := make([]string, 0, len(contentTypes))
+	for _, ct := range contentTypes {
+		allowed = append(allowed, strings.ToLower(strings.TrimSpace(ct)))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), supported)
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        minSize,
+				allowed:        allowed,
+			}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the supported encoding with the highest Accept-Encoding q-value,
+// breaking ties by server preference. It returns "" when none is acceptable.
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range supported {
+		q, ok := weights[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the first minSize bytes so the decision to compress can look at
+// the final status, headers, and body size before anything reaches the client.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	allowed  []string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	switch {
+	case cw.decided, status >= 100 && status < 200 && status != http.StatusSwitchingProtocols:
+		cw.ResponseWriter.WriteHeader(status)
+	case cw.status == 0:
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush commits to a decision with whatever is buffered, then pushes encoded bytes out.
+func (cw *compressWriter) Flush() {
+	if !cw.decided && cw.status != 0 {
+		if err := cw.decide(); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff now: net/http would otherwise sniff the compressed bytes.
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if cw.shouldCompress() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	if len(cw.buf) < cw.minSize {
+		return false
+	}
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, pattern := range cw.allowed {
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// close flushes any still-buffered (small) response uncompressed and finishes the stream.
+func (cw *compressWriter) close() {
+	if !cw.decided && cw.status != 0 {
+		cw.decide()
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+		cw.enc.Reset(nil)
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
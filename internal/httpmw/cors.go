@@ -0,0 +1,147 @@
+package httpmw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures CORS. AllowedOrigins entries are exact origins
+// ("https://app.example.com"), single-wildcard patterns ("https://*.example.com"), or "*"
+// for any origin; "*" is never combined with AllowCredentials, so no origin is reflected
+// blindly when cookies are in play.
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type originPattern struct {
+	prefix, suffix string
+}
+
+// CORS answers preflight requests itself and adds CORS headers to actual requests from
+// allowed origins. Requests from other origins are served without CORS headers, leaving
+// the browser to block them. Mount it with Router.Use ahead of anything that may reject
+// the request, so the rejection is also readable by the browser.
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	var anyOrigin bool
+	exact := make(map[string]bool)
+	var patterns []originPattern
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			anyOrigin = !opts.AllowCredentials
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			patterns = append(patterns, originPattern{prefix: prefix, suffix: suffix})
+		default:
+			exact[origin] = true
+		}
+	}
+
+	methods := make(map[string]bool, len(opts.AllowedMethods))
+	for _, m := range opts.AllowedMethods {
+		methods[strings.ToUpper(m)] = true
+	}
+	anyHeader := false
+	headers := make(map[string]bool, len(opts.AllowedHeaders))
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" {
+			anyHeader = true
+		}
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(h))] = true
+	}
+	allowMethods := strings.Join(opts.AllowedMethods, ", ")
+	exposeHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := ""
+	if opts.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
+		}
+		origin = strings.ToLower(origin)
+		if exact[origin] {
+			return true
+		}
+		for _, p := range patterns {
+			if len(origin) > len(p.prefix)+len(p.suffix) &&
+				strings.HasPrefix(origin, p.prefix) && strings.HasSuffix(origin, p.suffix) &&
+				!strings.ContainsAny(origin[len(p.prefix):len(origin)-len(p.suffix)], "/:") {
+				return true
+			}
+		}
+		return false
+	}
+
+	setOrigin := func(h http.Header, origin string) {
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		if opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			if !anyOrigin {
+				h.Add("Vary", "Origin")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				if origin != "" && allowed(origin) && preflightAllowed(r, methods, headers, anyHeader) {
+					setOrigin(h, origin)
+					h.Set("Access-Control-Allow-Methods", allowMethods)
+					if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+						h.Set("Access-Control-Allow-Headers", requested)
+					}
+					if maxAge != "" {
+						h.Set("Access-Control-Max-Age", maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if origin != "" && allowed(origin) {
+				setOrigin(h, origin)
+				if exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preflightAllowed checks the requested method and every requested header.
+func preflightAllowed(r *http.Request, methods, headers map[string]bool, anyHeader bool) bool {
+	if !methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] {
+		return false
+	}
+	if anyHeader {
+		return true
+	}
+	for _, name := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !headers[http.CanonicalHeaderKey(name)] {
+			return false
+		}
+	}
+	return true
+}
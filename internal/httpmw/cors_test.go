@@ -0,0 +1,128 @@
+package httpmw_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"demo/internal/httpmw"
+)
+
+var corsOptions = httpmw.CORSOptions{
+	AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+	AllowedMethods:   []string{"GET", "POST", "PUT"},
+	AllowedHeaders:   []string{"Content-Type", "X-CSRF-Token"},
+	ExposedHeaders:   []string{"X-Request-Id"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+// corsRequest sends method from origin through CORS(opts), with header name-value pairs,
+// and reports whether the wrapped handler ran.
+func corsRequest(opts httpmw.CORSOptions, method, origin string, header ...string) (*httptest.ResponseRecorder, bool) {
+	var reached bool
+	handler := httpmw.CORS(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(method, "/pets", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return serve(handler, req), reached
+}
+
+func TestCORSPreflight(t *testing.T) {
+	for _, tc := range []struct {
+		name, origin, method, headers string
+		allowed                       bool
+	}{
+		{"ExactOrigin", "https://app.example.com", "PUT", "Content-Type, X-CSRF-Token", true},
+		{"OriginCase", "HTTPS://App.Example.COM", "POST", "", true},
+		{"WildcardOrigin", "https://pets.example.org", "GET", "content-type", true},
+		{"WildcardDeeperOrigin", "https://eu.pets.example.org", "GET", "", true},
+		{"WildcardNeedsSubdomain", "https://example.org", "GET", "", false},
+		{"WildcardNoPath", "https://evil.com/.example.org", "GET", "", false},
+		{"WildcardNoPort", "https://evil.com:1.example.org", "GET", "", false},
+		{"OtherOrigin", "https://evil.example.com", "GET", "", false},
+		{"OtherScheme", "http://app.example.com", "GET", "", false},
+		{"MethodNotAllowed", "https://app.example.com", "DELETE", "", false},
+		{"HeaderNotAllowed", "https://app.example.com", "POST", "Content-Type, X-Secret", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header := []string{"Access-Control-Request-Method", tc.method}
+			if tc.headers != "" {
+				header = append(header, "Access-Control-Request-Headers", tc.headers)
+			}
+			rec, reached := corsRequest(corsOptions, http.MethodOptions, tc.origin, header...)
+			if rec.Code != http.StatusNoContent || reached {
+				t.Fatalf("preflight: got %d, reached handler %v; want 204 answered by CORS", rec.Code, reached)
+			}
+			if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Origin") {
+				t.Fatalf("Vary: got %q, want Origin", vary)
+			}
+			h := rec.Header()
+			if !tc.allowed {
+				if got := h.Get("Access-Control-Allow-Origin"); got != "" || h.Get("Access-Control-Allow-Credentials") != "" {
+					t.Fatalf("Access-Control-Allow-Origin: got %q, want none for a refused preflight", got)
+				}
+				return
+			}
+			for name, want := range map[string]string{
+				"Access-Control-Allow-Origin":      tc.origin,
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST, PUT",
+				"Access-Control-Allow-Headers":     tc.headers,
+				"Access-Control-Max-Age":           "600",
+			} {
+				if got := h.Get(name); got != want {
+					t.Errorf("%s: got %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	rec, reached := corsRequest(corsOptions, http.MethodGet, "https://app.example.com")
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Fatalf("allowed origin: reached %v with headers %v", reached, rec.Header())
+	}
+
+	// Other origins are served without CORS headers; the browser keeps the response from
+	// the page.
+	rec, reached = corsRequest(corsOptions, http.MethodGet, "https://evil.example.com")
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("other origin: reached %v with headers %v, want no CORS headers", reached, rec.Header())
+	}
+	if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Origin") {
+		t.Fatalf("Vary: got %q, want Origin so caches keep responses per origin", vary)
+	}
+
+	// An OPTIONS request without Access-Control-Request-Method is not a preflight.
+	if _, reached := corsRequest(corsOptions, http.MethodOptions, "https://app.example.com"); !reached {
+		t.Fatal("plain OPTIONS: answered by CORS, want it passed on")
+	}
+}
+
+// TestCORSAnyOrigin checks that "*" answers every origin without Vary or credentials,
+// and is ignored when credentials are allowed.
+func TestCORSAnyOrigin(t *testing.T) {
+	opts := httpmw.CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}
+	rec, _ := corsRequest(opts, http.MethodGet, "https://anywhere.example")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" || rec.Header().Get("Vary") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("any origin: got headers %v, want Access-Control-Allow-Origin * alone", rec.Header())
+	}
+
+	opts.AllowCredentials = true
+	rec, _ = corsRequest(opts, http.MethodGet, "https://anywhere.example")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("any origin with credentials: got Access-Control-Allow-Origin %q, want none", got)
+	}
+}
@@ -0,0 +1,65 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"demo/internal/apierr"
+	"demo/internal/features"
+)
+
+// errorBody mirrors the petstore Error schema so middleware rejections share one shape.
+type errorBody struct {
+	Category  apierr.Category `json:"category"`
+	Code      int32           `json:"code"`
+	Message   string          `json:"message"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// problemBody is an RFC 9457 problem details document, sent instead of errorBody when the
+// problem_json feature flag is on.
+type problemBody struct {
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Status    int             `json:"status"`
+	Detail    string          `json:"detail,omitempty"`
+	Instance  string          `json:"instance,omitempty"`
+	Category  apierr.Category `json:"category"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// WriteError answers with err's status and category and a JSON error payload carrying
+// message, tagged with the chi request ID. Middleware messages are not catalogued, so
+// unlike the petstore handlers' errors these have no error_code.
+func WriteError(w http.ResponseWriter, r *http.Request, err *apierr.Error, message string) {
+	if features.Enabled(r.Context(), features.ProblemJSON) {
+		WriteProblem(w, r, err, message)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(errorBody{
+		Category:  err.Category,
+		Code:      int32(err.Status),
+		Message:   message,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// WriteProblem sends message as an application/problem+json document describing the
+// request's path, with err's category as an extension member.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err *apierr.Error, message string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(problemBody{
+		Type:      "about:blank",
+		Title:     http.StatusText(err.Status),
+		Status:    err.Status,
+		Detail:    message,
+		Instance:  r.URL.Path,
+		Category:  err.Category,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
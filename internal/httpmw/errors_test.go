@@ -0,0 +1,93 @@
+package httpmw_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"demo/internal/apierr"
+	"demo/internal/features"
+	"demo/internal/httpmw"
+)
+
+// errorBody is the standard error JSON the middleware answers with.
+type errorBody struct {
+	Category  string `json:"category"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// assertErrorBody checks rec's status and that its body is the standard error JSON with
+// category, returning the decoded body.
+func assertErrorBody(t *testing.T, rec *httptest.ResponseRecorder, status int, category string) errorBody {
+	t.Helper()
+	var body errorBody
+	if rec.Code != status {
+		t.Fatalf("status: got %d %s, want %d", rec.Code, rec.Body, status)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: got %q, want application/json", ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Category != category || body.Code != status || body.Message == "" {
+		t.Fatalf("body: got %s (%v), want %s with code %d and a message", rec.Body, err, category, status)
+	}
+	return body
+}
+
+func TestWriteError(t *testing.T) {
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpmw.WriteError(w, r, apierr.ErrRateLimited, "slow down")
+	}))
+	req := request("a")
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	rec := serve(handler, req)
+	body := assertErrorBody(t, rec, http.StatusTooManyRequests, "RATE_LIMITED")
+	if body.Message != "slow down" || body.RequestID != "req-123" {
+		t.Fatalf("body: got message %q and request_id %q, want slow down and req-123", body.Message, body.RequestID)
+	}
+	var fields map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &fields)
+	if got := slices.Sorted(maps.Keys(fields)); !slices.Equal(got, []string{"category", "code", "message", "request_id"}) {
+		t.Fatalf("fields: got %v, want the Error schema without error_code", got)
+	}
+
+	// Outside the RequestID middleware the field is left out rather than sent empty.
+	rec = serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpmw.WriteError(w, r, apierr.ErrInternal, "internal error")
+	}), request("a"))
+	assertErrorBody(t, rec, http.StatusInternalServerError, "INTERNAL")
+	if strings.Contains(rec.Body.String(), "request_id") {
+		t.Fatalf("body without a request ID: got %s", rec.Body)
+	}
+}
+
+func TestWriteErrorProblemJSON(t *testing.T) {
+	flags := features.New(map[string]bool{features.ProblemJSON: true}, slog.New(slog.DiscardHandler))
+	handler := flags.Middleware(middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpmw.WriteError(w, r, apierr.ErrRateLimited, "slow down")
+	})))
+	req := request("a")
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	rec := serve(handler, req)
+
+	var body struct {
+		Type, Title, Detail, Instance, Category string
+		Status                                  int
+		RequestID                               string `json:"request_id"`
+	}
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusTooManyRequests || ct != "application/problem+json" {
+		t.Fatalf("response: got %d %q, want 429 application/problem+json", rec.Code, ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Type != "about:blank" || body.Title != "Too Many Requests" ||
+		body.Status != 429 || body.Detail != "slow down" || body.Instance != "/pets" || body.Category != "RATE_LIMITED" || body.RequestID != "req-123" {
+		t.Fatalf("body: got %s (%v)", rec.Body, err)
+	}
+}
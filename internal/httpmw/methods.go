@@ -0,0 +1,116 @@
+package httpmw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/apierr"
+)
+
+// routedMethods are the methods AllowedMethods probes, in the order Allow lists them.
+var routedMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// AllowedMethods lists the methods routes serves for path: HEAD wherever GET is served,
+// and OPTIONS whenever anything is. It returns nil when no route matches path.
+func AllowedMethods(routes chi.Routes, path string) []string {
+	var allowed []string
+	for _, method := range routedMethods {
+		if routes.Match(chi.NewRouteContext(), method, path) ||
+			(method == http.MethodHead && routes.Match(chi.NewRouteContext(), http.MethodGet, path)) {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) > 0 {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
+}
+
+// MethodNotAllowed is the router's 405 handler: it answers with the Error JSON and an
+// Allow header listing the methods the route does serve. OPTIONS, which no route
+// registers, gets 204 with the same Allow header instead.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.Routes != nil {
+		if allowed := AllowedMethods(rctx.Routes, requestPath(r)); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	WriteError(w, r, apierr.ErrMethodNotAllowed, "method not allowed")
+}
+
+// Head serves HEAD requests on routes without a HEAD handler from the GET handler, which
+// still sees the HEAD method. The body it writes is discarded and its length reported in
+// Content-Length, so the headers match what GET would send uncompressed.
+func Head(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		if r.Method != http.MethodHead || rctx == nil || rctx.Routes == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path := requestPath(r)
+		if rctx.Routes.Match(chi.NewRouteContext(), http.MethodHead, path) ||
+			!rctx.Routes.Match(chi.NewRouteContext(), http.MethodGet, path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rctx.RouteMethod = http.MethodGet
+		hw := &headWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		hw.finish()
+	})
+}
+
+// requestPath is the path chi routes r by.
+func requestPath(r *http.Request) string {
+	if r.URL.RawPath != "" {
+		return r.URL.RawPath
+	}
+	if r.URL.Path == "" {
+		return "/"
+	}
+	return r.URL.Path
+}
+
+// headWriter holds back the status until the handler returns so Content-Length can be
+// set from the bytes it wrote, which are dropped.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.size += len(p)
+	return len(p), nil
+}
+
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headWriter) finish() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.size > 0 && w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(w.size))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
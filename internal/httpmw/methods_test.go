@@ -0,0 +1,74 @@
+package httpmw_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/httpmw"
+)
+
+func newMethodsRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(httpmw.Head)
+	r.MethodNotAllowed(httpmw.MethodNotAllowed)
+	pet := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id":7,"name":"Rex"}`))
+	})
+	r.Get("/pets", pet)
+	r.Post("/pets", okHandler)
+	r.Get("/pets/{petId}", pet)
+	r.Put("/pets/{petId}", okHandler)
+	r.Delete("/pets/{petId}", okHandler)
+	r.Post("/pets/{petId}/merge", okHandler)
+	return r
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	router := newMethodsRouter()
+	for _, tc := range []struct {
+		name, method, path string
+		allow              string
+	}{
+		{"Collection", http.MethodPatch, "/pets", "GET, HEAD, POST, OPTIONS"},
+		{"Item", http.MethodPost, "/pets/7", "GET, HEAD, PUT, DELETE, OPTIONS"},
+		// HEAD and OPTIONS are only offered where GET is, or anything is, served.
+		{"PostOnly", http.MethodGet, "/pets/7/merge", "POST, OPTIONS"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(router, httptest.NewRequest(tc.method, tc.path, nil))
+			assertErrorBody(t, rec, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED")
+			if got := rec.Header().Get("Allow"); got != tc.allow {
+				t.Fatalf("Allow: got %q, want %q", got, tc.allow)
+			}
+		})
+	}
+
+	rec := serve(router, httptest.NewRequest(http.MethodPatch, "/owners", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Allow") != "" {
+		t.Fatalf("unrouted path: got %d with Allow %q, want 404 and none", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestOptions(t *testing.T) {
+	rec := serve(newMethodsRouter(), httptest.NewRequest(http.MethodOptions, "/pets/7", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != "GET, HEAD, PUT, DELETE, OPTIONS" || rec.Body.Len() != 0 {
+		t.Fatalf("OPTIONS: got %d with Allow %q and body %q, want 204 and the item's methods", rec.Code, rec.Header().Get("Allow"), rec.Body)
+	}
+}
+
+func TestHead(t *testing.T) {
+	rec := serve(newMethodsRouter(), httptest.NewRequest(http.MethodHead, "/pets/7", nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("HEAD: got %d with body %q, want 200 and no body", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "21" {
+		t.Fatalf("Content-Length: got %q, want the GET body's 21", got)
+	}
+	if got := rec.Header().Get("ETag"); got != `"v1"` {
+		t.Fatalf("ETag: got %q, want the GET handler's", got)
+	}
+}
@@ -0,0 +1,147 @@
+package httpmw
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+
+	"demo/internal/apierr"
+)
+
+var rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "petstore",
+	Subsystem: "http",
+	Name:      "rate_limited_total",
+	Help:      "Requests rejected with 429 by the rate limiter, by limiter scope.",
+}, []string{"scope"})
+
+// KeyFunc identifies the client a request is charged to.
+type KeyFunc func(r *http.Request) string
+
+// ClientIP keys requests by the address RealIP resolved, falling back to the remote
+// host when RealIP is not mounted.
+func ClientIP(r *http.Request) string {
+	if ip := ClientIPFromContext(r.Context()); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimiter is a per-key token bucket. Buckets idle for longer than the idle TTL are
+// evicted lazily, bounding memory to the set of recently active clients.
+type RateLimiter struct {
+	scope   string
+	key     KeyFunc
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter allows rps requests per second per key with the given burst. scope labels
+// the rejection metric (e.g. "read", "write").
+func NewRateLimiter(scope string, rps float64, burst int, idleTTL time.Duration, key KeyFunc) *RateLimiter {
+	if idleTTL <= 0 {
+		idleTTL = 10 * time.Minute
+	}
+	return &RateLimiter{
+		scope:     scope,
+		key:       key,
+		idleTTL:   idleTTL,
+		limit:     rate.Limit(rps),
+		burst:     burst,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetLimit changes the rate for new and existing buckets, e.g. after a config reload.
+func (l *RateLimiter) SetLimit(rps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit, l.burst = rate.Limit(rps), burst
+	for _, b := range l.buckets {
+		b.limiter.SetLimit(l.limit)
+		b.limiter.SetBurst(l.burst)
+	}
+}
+
+// reserve takes a token for key, returning how long the caller must wait when none is left.
+func (l *RateLimiter) reserve(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.idleTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) >= l.idleTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	if b.limiter.AllowN(now, 1) {
+		return true, 0
+	}
+	if l.limit <= 0 {
+		return false, time.Second
+	}
+	missing := 1 - b.limiter.TokensAt(now)
+	return false, time.Duration(missing / float64(l.limit) * float64(time.Second))
+}
+
+// Middleware answers 429 with Retry-After once a client exhausts its bucket.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.reserve(l.key(r), time.Now())
+		if !ok {
+			rateLimited.WithLabelValues(l.scope).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			WriteError(w, r, apierr.ErrRateLimited, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ByMethod charges safe methods (GET, HEAD, OPTIONS) to reads and everything else to
+// writes, so writes can be limited more strictly on shared routes.
+func ByMethod(reads, writes *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		readHandler, writeHandler := reads.Middleware(next), writes.Middleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				readHandler.ServeHTTP(w, r)
+			default:
+				writeHandler.ServeHTTP(w, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package httpmw_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"demo/internal/httpmw"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+// byHeader keys requests by X-Client so tests can act as many clients.
+func byHeader(r *http.Request) string { return r.Header.Get("X-Client") }
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func request(client string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/pets", nil)
+	req.Header.Set("X-Client", client)
+	return req
+}
+
+func TestRateLimiterRejects(t *testing.T) {
+	handler := httpmw.NewRateLimiter("test", 0.5, 2, time.Minute, byHeader).Middleware(okHandler)
+	for i := range 2 {
+		if rec := serve(handler, request("a")); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d within the burst: got %d", i+1, rec.Code)
+		}
+	}
+	rec := serve(handler, request("a"))
+	assertErrorBody(t, rec, http.StatusTooManyRequests, "RATE_LIMITED")
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After: got %q, want 2 at half a request per second", got)
+	}
+	if rec := serve(handler, request("b")); rec.Code != http.StatusNoContent {
+		t.Fatalf("another client: got %d, want its own bucket", rec.Code)
+	}
+}
+
+// TestRateLimiterConcurrent sends many requests at once from a few clients and checks
+// that each is admitted exactly its burst, while limits change concurrently.
+func TestRateLimiterConcurrent(t *testing.T) {
+	const clients, burst, perClient = 4, 25, 100
+	limiter := httpmw.NewRateLimiter("test", 0.001, burst, time.Minute, byHeader)
+	handler := limiter.Middleware(okHandler)
+
+	var admitted [clients]atomic.Int64
+	var wg sync.WaitGroup
+	for c := range clients {
+		for range perClient {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				switch rec := serve(handler, request(fmt.Sprint(c))); rec.Code {
+				case http.StatusNoContent:
+					admitted[c].Add(1)
+				case http.StatusTooManyRequests:
+				default:
+					t.Errorf("client %d: got %d", c, rec.Code)
+				}
+			}()
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			limiter.SetLimit(0.001, burst)
+		}
+	}()
+	wg.Wait()
+
+	for c := range clients {
+		if got := admitted[c].Load(); got != burst {
+			t.Errorf("client %d: %d of %d requests admitted, want the burst of %d", c, got, perClient, burst)
+		}
+	}
+}
+
+func BenchmarkRateLimiter(b *testing.B) {
+	handler := httpmw.NewRateLimiter("bench", 1e9, 1e9, time.Minute, byHeader).Middleware(okHandler)
+	var next atomic.Int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		req := request(fmt.Sprint(next.Add(1) % 64))
+		for pb.Next() {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	})
+}
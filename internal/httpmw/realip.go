@@ -0,0 +1,106 @@
+package httpmw
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// RealIP resolves the client address and stores it on the request context for
+// ClientIPFromContext. Forwarding headers (Forwarded, then X-Forwarded-For, then
+// X-Real-IP) are only believed when the direct peer is within trusted; the chain is walked
+// from the right, skipping trusted proxies, and the first untrusted hop is the client.
+// Untrusted peers are taken verbatim, so forged headers from the internet are ignored.
+// r.RemoteAddr is left untouched.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ClientIPFromContext returns the address resolved by RealIP, or "" outside it.
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(peer.Unmap(), trusted) {
+		return host
+	}
+
+	client := peer.Unmap().String()
+	hops := forwardedHops(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = hop.String()
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops returns the client chain, oldest first, from the most expressive header
+// present.
+func forwardedHops(h http.Header) []string {
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		var hops []string
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, value)
+				}
+			}
+		}
+		return hops
+	}
+	if values := h.Values("X-Forwarded-For"); len(values) > 0 {
+		return strings.Split(strings.Join(values, ","), ",")
+	}
+	if v := h.Get("X-Real-IP"); v != "" {
+		return []string{v}
+	}
+	return nil
+}
+
+// parseHop accepts a bare IP, "ip:port", or the quoted and bracketed RFC 7239 forms such as
+// "[2001:db8::1]:4711". Obfuscated identifiers and "unknown" are rejected.
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(hop, "[]")); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
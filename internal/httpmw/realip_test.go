@@ -0,0 +1,69 @@
+package httpmw_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"demo/internal/httpmw"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}
+	for _, tc := range []struct {
+		name, peer string
+		header     []string
+		want       string
+	}{
+		{"UntrustedPeer", "203.0.113.9:4711", nil, "203.0.113.9"},
+		{"UntrustedPeerSpoofsXFF", "203.0.113.9:4711", []string{"X-Forwarded-For", "1.2.3.4"}, "203.0.113.9"},
+		{"UntrustedPeerSpoofsRealIP", "203.0.113.9:4711", []string{"X-Real-IP", "1.2.3.4"}, "203.0.113.9"},
+		{"UntrustedPeerSpoofsForwarded", "203.0.113.9:4711", []string{"Forwarded", "for=1.2.3.4"}, "203.0.113.9"},
+		{"TrustedPeerWithoutHeaders", "10.0.0.1:4711", nil, "10.0.0.1"},
+		{"TrustedPeerXFF", "10.0.0.1:4711", []string{"X-Forwarded-For", "198.51.100.7"}, "198.51.100.7"},
+		{"TrustedSingleHost", "192.168.1.1:4711", []string{"X-Forwarded-For", "198.51.100.7"}, "198.51.100.7"},
+		{"NeighbourOfTrustedHost", "192.168.1.2:4711", []string{"X-Forwarded-For", "198.51.100.7"}, "192.168.1.2"},
+		{"MappedTrustedPeer", "[::ffff:10.0.0.1]:4711", []string{"X-Forwarded-For", "198.51.100.7"}, "198.51.100.7"},
+		{"MultiHop", "10.0.0.1:4711", []string{"X-Forwarded-For", "198.51.100.7, 10.0.0.5, 10.0.0.6"}, "198.51.100.7"},
+		// The client prepends whatever it likes; the hop our proxies added wins.
+		{"SpoofedLeftmostHop", "10.0.0.1:4711", []string{"X-Forwarded-For", "1.2.3.4, 198.51.100.7, 10.0.0.5"}, "198.51.100.7"},
+		{"RepeatedXFFHeaders", "10.0.0.1:4711", []string{"X-Forwarded-For", "1.2.3.4", "X-Forwarded-For", "198.51.100.7"}, "198.51.100.7"},
+		{"AllHopsTrusted", "10.0.0.1:4711", []string{"X-Forwarded-For", "10.0.0.7, 10.0.0.5"}, "10.0.0.7"},
+		{"GarbageHop", "10.0.0.1:4711", []string{"X-Forwarded-For", "198.51.100.7, not-an-ip, 10.0.0.5"}, "10.0.0.5"},
+		{"RealIP", "10.0.0.1:4711", []string{"X-Real-IP", "198.51.100.7"}, "198.51.100.7"},
+		{"Forwarded", "10.0.0.1:4711", []string{"Forwarded", `for="[2001:db8::1]:4711";proto=https, for=10.0.0.5`}, "2001:db8::1"},
+		{"ForwardedBeatsXFF", "10.0.0.1:4711", []string{"Forwarded", "for=198.51.100.7", "X-Forwarded-For", "1.2.3.4"}, "198.51.100.7"},
+		{"ForwardedUnknown", "10.0.0.1:4711", []string{"Forwarded", "for=unknown"}, "10.0.0.1"},
+		{"ForwardedObfuscated", "10.0.0.1:4711", []string{"Forwarded", "for=198.51.100.7, for=_hidden"}, "10.0.0.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := httpmw.RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = httpmw.ClientIP(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.peer
+			for i := 0; i+1 < len(tc.header); i += 2 {
+				req.Header.Add(tc.header[i], tc.header[i+1])
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tc.want {
+				t.Fatalf("client IP: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutRealIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.9:4711"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if got := httpmw.ClientIP(req); got != "203.0.113.9" {
+		t.Fatalf("ClientIP: got %q, want the peer", got)
+	}
+	if got := httpmw.ClientIPFromContext(context.Background()); got != "" {
+		t.Fatalf("ClientIPFromContext outside RealIP: got %q", got)
+	}
+}
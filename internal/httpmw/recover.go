@@ -0,0 +1,75 @@
+package httpmw
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"demo/internal/apierr"
+)
+
+var panicsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "petstore",
+	Subsystem: "http",
+	Name:      "panics_total",
+	Help:      "Handler panics recovered by the HTTP server, by route pattern.",
+}, []string{"route"})
+
+// PanicReporter receives every recovered panic, e.g. to forward it to an error tracker.
+// It runs synchronously on the request goroutine and must not panic itself.
+type PanicReporter func(ctx context.Context, r *http.Request, recovered any, stack []byte)
+
+// Recover replaces chi's Recoverer: it logs the panic and stack through logger, counts it,
+// hands it to report when non-nil, and answers 500 with the JSON error shape. If the
+// handler had already started the response, the connection is aborted instead so the
+// client sees a truncated response rather than a corrupted body with a second header.
+func Recover(logger *slog.Logger, report PanicReporter) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				stack := debug.Stack()
+				route := ""
+				if rctx := chi.RouteContext(r.Context()); rctx != nil {
+					route = rctx.RoutePattern()
+				}
+				panicsTotal.WithLabelValues(route).Inc()
+				logger.ErrorContext(r.Context(), "http_panic",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"route", route,
+					"panic", fmt.Sprint(recovered),
+					"stack", string(stack),
+				)
+				if report != nil {
+					report(r.Context(), r, recovered, stack)
+				}
+
+				if ww.Status() != 0 || ww.BytesWritten() > 0 {
+					panic(http.ErrAbortHandler)
+				}
+				WriteError(w, r, apierr.ErrInternal, "internal error")
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package httpmw_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"demo/internal/httpmw"
+)
+
+func TestRecover(t *testing.T) {
+	var logs bytes.Buffer
+	var reported any
+	report := func(ctx context.Context, r *http.Request, recovered any, stack []byte) { reported = recovered }
+	handler := httpmw.Recover(slog.New(slog.NewTextHandler(&logs, nil)), report)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := serve(handler, request("a"))
+	if body := assertErrorBody(t, rec, http.StatusInternalServerError, "INTERNAL"); strings.Contains(body.Message, "boom") {
+		t.Fatalf("message: got %q, want the panic kept out of the response", body.Message)
+	}
+	if !strings.Contains(logs.String(), "msg=http_panic method=GET path=/pets") || !strings.Contains(logs.String(), "panic=boom") {
+		t.Fatalf("logs: got %q, want an http_panic line with the panic", logs.String())
+	}
+	if reported != "boom" {
+		t.Fatalf("PanicReporter: got %v, want boom", reported)
+	}
+}
+
+// TestRecoverAborts checks that http.ErrAbortHandler, and panics after the response has
+// started, reach net/http as http.ErrAbortHandler so it drops the connection.
+func TestRecoverAborts(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"ErrAbortHandler", func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }},
+		{"AfterWriteHeader", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic("boom")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := httpmw.Recover(slog.New(slog.DiscardHandler), nil)(tc.handler)
+			defer func() {
+				if got := recover(); got != http.ErrAbortHandler {
+					t.Fatalf("panic: got %v, want http.ErrAbortHandler", got)
+				}
+			}()
+			serve(handler, request("a"))
+		})
+	}
+}
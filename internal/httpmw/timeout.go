@@ -0,0 +1,69 @@
+package httpmw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"demo/internal/apierr"
+)
+
+// Timeout bounds each request's context by def, or by the override for its route. Override
+// keys are a chi route pattern optionally prefixed by a method ("GET /pets/{petId}"); a zero
+// duration disables the deadline, which streaming routes need.
+//
+// The route pattern is only known after routing, so mount Timeout on route groups (chi
+// With/Group or generated per-handler middlewares) rather than with Router.Use. Handlers
+// must honour ctx cancellation; if one returns after the deadline without writing a
+// response, Timeout answers 504 with a JSON error.
+func Timeout(def time.Duration, overrides map[string]time.Duration) func(http.Handler) http.Handler {
+	normalized := make(map[string]time.Duration, len(overrides))
+	for key, d := range overrides {
+		normalized[strings.ToLower(strings.TrimSpace(key))] = d
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := def
+			if len(normalized) > 0 {
+				if d, ok := routeOverride(r, normalized); ok {
+					timeout = d
+				}
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				WriteError(w, r, apierr.ErrTimeout, "request timed out")
+			}
+		})
+	}
+}
+
+// routeOverride looks up "method pattern" and then "pattern" in lower-cased overrides.
+func routeOverride[V any](r *http.Request, overrides map[string]V) (V, bool) {
+	var zero V
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return zero, false
+	}
+	pattern := strings.ToLower(rctx.RoutePattern())
+	if v, ok := overrides[strings.ToLower(r.Method)+" "+pattern]; ok {
+		return v, true
+	}
+	v, ok := overrides[pattern]
+	return v, ok
+}
@@ -0,0 +1,70 @@
+package httpmw_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/httpmw"
+)
+
+// slowHandler waits for the request context like a repository call stuck on a slow
+// database would, and answers 204 if it is not cancelled within a second.
+var slowHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+		w.WriteHeader(http.StatusNoContent)
+	}
+})
+
+func TestTimeout(t *testing.T) {
+	handler := httpmw.Timeout(10*time.Millisecond, nil)(slowHandler)
+	assertErrorBody(t, serve(handler, request("a")), http.StatusGatewayTimeout, "TIMEOUT")
+
+	// A handler that answered before giving up keeps its response.
+	handler = httpmw.Timeout(10*time.Millisecond, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		<-r.Context().Done()
+	}))
+	if rec := serve(handler, request("a")); rec.Code != http.StatusAccepted {
+		t.Fatalf("written before the deadline: got %d, want 202", rec.Code)
+	}
+}
+
+func TestTimeoutRouteOverrides(t *testing.T) {
+	timeout := httpmw.Timeout(10*time.Millisecond, map[string]time.Duration{
+		"GET /pets/{petId}": time.Minute,
+		"/pets/export":      0,
+	})
+	deadline := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, ok := r.Context().Deadline(); ok {
+			w.Header().Set("X-Deadline", time.Until(d).Round(time.Minute).String())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r := chi.NewRouter()
+	r.With(timeout).Get("/pets/{petId}", deadline)
+	r.With(timeout).Delete("/pets/{petId}", slowHandler)
+	r.With(timeout).Get("/pets/export", deadline)
+
+	for _, tc := range []struct {
+		name, method, path string
+		status             int
+		deadline           string
+	}{
+		{"MethodAndPattern", http.MethodGet, "/pets/1", http.StatusNoContent, "1m0s"},
+		{"OtherMethodUsesDefault", http.MethodDelete, "/pets/1", http.StatusGatewayTimeout, ""},
+		{"ZeroDisablesDeadline", http.MethodGet, "/pets/export", http.StatusNoContent, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(r, httptest.NewRequest(tc.method, tc.path, nil))
+			if rec.Code != tc.status || rec.Header().Get("X-Deadline") != tc.deadline {
+				t.Fatalf("%s %s: got %d with deadline %q, want %d with %q", tc.method, tc.path, rec.Code, rec.Header().Get("X-Deadline"), tc.status, tc.deadline)
+			}
+		})
+	}
+}
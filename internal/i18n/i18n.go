@@ -0,0 +1,105 @@
+// Package i18n renders user-facing messages from stable codes in the caller's language.
+// Catalogs are embedded JSON files, one per locale, mapping each code to a template whose
+// {name} placeholders are filled from key/value arguments; a code missing from a locale
+// falls back to English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when Accept-Language names none we have, and the fallback
+// for codes a catalog does not translate.
+const Default = "en"
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalogs maps locale to code to template.
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	entries, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := catalogFiles.ReadFile(path.Join("catalogs", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", entry.Name(), err))
+		}
+		out[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+	if _, ok := out[Default]; !ok {
+		panic("i18n: missing the " + Default + " catalog")
+	}
+	return out
+}
+
+// Locales returns the locales with a catalog, sorted.
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Message renders code in locale, filling {name} placeholders from args given as
+// alternating names and values, as with slog. Codes missing from locale use the English
+// template, and codes missing from that render as the code itself.
+func Message(locale, code string, args ...any) string {
+	template, ok := catalogs[locale][code]
+	if !ok {
+		if template, ok = catalogs[Default][code]; !ok {
+			return code
+		}
+	}
+	if len(args) == 0 {
+		return template
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// Negotiate picks the locale with a catalog that header (an Accept-Language value) ranks
+// highest, matching on the primary subtag so de-AT selects de. Earlier entries win ties,
+// and Default is returned when nothing matches.
+func Negotiate(header string) string {
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(tag, "-")
+		if primary == "*" {
+			primary = Default
+		}
+		if _, ok := catalogs[primary]; ok && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,55 @@
+package i18n
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+// TestCatalogsTranslateEveryCode checks that every catalog has the codes of the English
+// one and no others, so no locale silently falls back to English.
+func TestCatalogsTranslateEveryCode(t *testing.T) {
+	want := slices.Sorted(maps.Keys(catalogs[Default]))
+	for _, locale := range Locales() {
+		if got := slices.Sorted(maps.Keys(catalogs[locale])); !slices.Equal(got, want) {
+			t.Errorf("catalog %s has codes %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	for _, tc := range []struct {
+		locale, code string
+		args         []any
+		want         string
+	}{
+		{"en", "PET_NOT_FOUND", nil, "pet not found"},
+		{"de", "PET_NOT_FOUND", nil, "Haustier nicht gefunden"},
+		{"en", "PET_NAME_TOO_LONG", []any{"max", 100}, "name must be 100 characters or fewer"},
+		{"fr", "PET_NOT_FOUND", nil, "pet not found"},
+		{"de", "NO_SUCH_CODE", nil, "NO_SUCH_CODE"},
+	} {
+		if got := Message(tc.locale, tc.code, tc.args...); got != tc.want {
+			t.Errorf("Message(%q, %q, %v): got %q, want %q", tc.locale, tc.code, tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		header, want string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-AT, en;q=0.5", "de"},
+		{"fr, pl;q=0.8, de;q=0.7", "pl"},
+		{"en;q=0.5, pl", "pl"},
+		{"fr", "en"},
+		{"*", "en"},
+		{"de;q=bogus, pl;q=0.1", "pl"},
+	} {
+		if got := Negotiate(tc.header); got != tc.want {
+			t.Errorf("Negotiate(%q): got %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+const unixScheme = "unix://"
+
+// Listen binds address, which is either a TCP host:port or unix:///path/to.sock. Unix sockets
+// replace a stale socket file left by a previous run, are chmod'ed to socketMode, and are
+// unlinked when the listener closes.
+func Listen(address string, socketMode os.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixScheme)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("unix socket address %q has no path", address)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	if socketMode != 0 {
+		if err := os.Chmod(path, socketMode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("chmod unix socket %s: %w", path, err)
+		}
+	}
+	return ln, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Refuse to delete anything that isn't a socket, e.g. a mistyped path to a real file.
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove stale unix socket %s: %w", path, err)
+	}
+	return nil
+}
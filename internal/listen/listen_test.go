@@ -0,0 +1,104 @@
+package listen_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"demo/internal/listen"
+)
+
+func TestListenUnixServesHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "petstore.sock")
+	ln, err := listen.Listen("unix://"+path, 0o660)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go server.Serve(ln)
+	defer server.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o660 {
+		t.Fatalf("socket file: got mode %s, want a socket with permissions 0660", info.Mode())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://petstore/healthz")
+	if err != nil {
+		t.Fatalf("GET over the socket: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Fatalf("GET over the socket: got body %q, want ok", body)
+	}
+
+	server.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file after close: got %v, want it removed", err)
+	}
+}
+
+func TestListenUnixReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "petstore.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("creating stale socket: %v", err)
+	}
+	// A crashed process leaves its socket file behind.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen.Listen("unix://"+path, 0)
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	ln.Close()
+}
+
+func TestListenUnixRefusesOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server: {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if ln, err := listen.Listen("unix://"+path, 0); err == nil {
+		ln.Close()
+		t.Fatal("Listen on a regular file: got nil error")
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.HasPrefix(string(data), "server:") {
+		t.Fatalf("regular file after Listen: got %q, %v; want it untouched", data, err)
+	}
+}
+
+func TestListenRejectsEmptyUnixPath(t *testing.T) {
+	if ln, err := listen.Listen("unix://", 0); err == nil {
+		ln.Close()
+		t.Fatal("Listen(unix://): got nil error")
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	ln, err := listen.Listen("127.0.0.1:0", 0o600)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	if ln.Addr().Network() != "tcp" {
+		t.Fatalf("Listen(127.0.0.1:0): got a %s listener, want tcp", ln.Addr().Network())
+	}
+}
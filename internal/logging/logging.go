@@ -0,0 +1,175 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	appconfig "demo/internal/config"
+	"demo/internal/httpmw"
+)
+
+// New builds a logger writing to w according to the logging configuration. When levelVar
+// is non-nil it is set from cfg.Level and drives the handler, so the level can be changed
+// at runtime (e.g. on config reload).
+func New(w io.Writer, cfg appconfig.LoggingConfig, levelVar *slog.LevelVar) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var leveler slog.Leveler = level
+	if levelVar != nil {
+		levelVar.Set(level)
+		leveler = levelVar
+	}
+	opts := &slog.HandlerOptions{Level: leveler, AddSource: cfg.AddSource}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler adds the chi request ID and the fields attached with With to every
+// record logged with a request context, so repository and cache warnings can be
+// correlated with the request that caused them. A field the log call sets itself wins.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	if id := middleware.GetReqID(ctx); id != "" {
+		fields = append([]slog.Attr{slog.String("request_id", id)}, fields...)
+	}
+	if len(fields) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+	present := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(a slog.Attr) bool {
+		present[a.Key] = true
+		return true
+	})
+	record = record.Clone()
+	for _, field := range fields {
+		if !present[field.Key] {
+			record.AddAttrs(field)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type fieldsKey struct{}
+
+// With returns a copy of ctx carrying args, as key-value pairs or slog.Attrs, which are
+// added to every record logged with it through a logger from New. Handlers and
+// repositories pass the request context to the ...Context logging methods and pick up
+// everything the middlewares attached, such as the route and the user.
+func With(ctx context.Context, args ...any) context.Context {
+	var record slog.Record
+	record.Add(args...)
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	fields = slices.Clip(fields)
+	record.Attrs(func(a slog.Attr) bool {
+		fields = append(fields, a)
+		return true
+	})
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// RequestFields attaches the method and route pattern to the request context for every
+// record logged further down the chain. The route is read when a record is written, so it
+// is complete once chi has matched the request.
+func RequestFields(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := With(r.Context(), "method", r.Method, "route", routePattern{chi.RouteContext(r.Context())})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// routePattern resolves the chi route pattern lazily.
+type routePattern struct{ rctx *chi.Context }
+
+func (p routePattern) LogValue() slog.Value {
+	if p.rctx == nil {
+		return slog.StringValue("")
+	}
+	return slog.StringValue(p.rctx.RoutePattern())
+}
+
+// ParseLevel maps debug, info, warn, or error onto a slog level.
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+	return l, nil
+}
+
+// RequestIDHeader echoes the chi request ID back to the client as X-Request-Id.
+// It must be mounted after middleware.RequestID.
+func RequestIDHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestLogger logs one line per request once the handler returns, including the chi
+// request ID, route pattern, and the client IP resolved by httpmw.RealIP. It replaces chi's middleware.Logger.
+func RequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := ""
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// request_id is attached by the context handler installed in New.
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "http_request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("client_ip", httpmw.ClientIPFromContext(r.Context())),
+			)
+		})
+	}
+}
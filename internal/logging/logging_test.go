@@ -0,0 +1,173 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	appconfig "demo/internal/config"
+	"demo/internal/httpmw"
+	"demo/internal/logging"
+)
+
+// decodeLines parses every JSON record written to buf.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func newLogger(t *testing.T, buf *bytes.Buffer, cfg appconfig.LoggingConfig) *slog.Logger {
+	t.Helper()
+	logger, err := logging.New(buf, cfg, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return logger
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  appconfig.LoggingConfig
+		want []string
+	}{
+		{"DefaultJSON", appconfig.LoggingConfig{Level: "info"}, []string{`"level":"WARN","msg":"pet_lookup","pet_id":7}`}},
+		{"JSON", appconfig.LoggingConfig{Level: "warn", Format: "JSON"}, []string{`"level":"WARN","msg":"pet_lookup","pet_id":7}`}},
+		{"Text", appconfig.LoggingConfig{Level: "debug", Format: "text"}, []string{"level=DEBUG msg=pet_lookup pet_id=7", "level=WARN msg=pet_lookup pet_id=7"}},
+		{"ErrorLevel", appconfig.LoggingConfig{Level: "error"}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(t, &buf, tc.cfg)
+			logger.Debug("pet_lookup", "pet_id", 7)
+			logger.Warn("pet_lookup", "pet_id", 7)
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if buf.Len() == 0 {
+				lines = nil
+			}
+			if len(lines) != len(tc.want) {
+				t.Fatalf("output: got %q, want %d lines", buf.String(), len(tc.want))
+			}
+			for i, line := range lines {
+				if !strings.HasSuffix(line, tc.want[i]) {
+					t.Errorf("line %d: got %s, want it to end with %s", i+1, line, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	for _, cfg := range []appconfig.LoggingConfig{{Level: "verbose"}, {Level: "info", Format: "logfmt"}} {
+		if _, err := logging.New(&bytes.Buffer{}, cfg, nil); err == nil {
+			t.Errorf("New(%+v): got nil error", cfg)
+		}
+	}
+}
+
+func TestNewLevelVar(t *testing.T) {
+	var buf bytes.Buffer
+	var level slog.LevelVar
+	logger, err := logging.New(&buf, appconfig.LoggingConfig{Level: "warn"}, &level)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if level.Level() != slog.LevelWarn {
+		t.Fatalf("level: got %v, want WARN", level.Level())
+	}
+	logger.Info("dropped")
+	level.Set(slog.LevelInfo)
+	logger.Info("kept")
+	if records := decodeLines(t, &buf); len(records) != 1 || records[0]["msg"] != "kept" {
+		t.Fatalf("records: got %v, want only the one logged after lowering the level", records)
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(t, &buf, appconfig.LoggingConfig{Level: "info"})
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, httpmw.RealIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}), logging.RequestLogger(logger))
+	r.Get("/pets/{petId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/pets/7?verbose=1", nil)
+	req.RemoteAddr = "10.0.0.2:41000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := decodeLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("records: got %d, want 1", len(records))
+	}
+	record := records[0]
+	if _, ok := record["duration"].(float64); !ok {
+		t.Fatalf("duration: got %v, want nanoseconds", record["duration"])
+	}
+	delete(record, "duration")
+	delete(record, "time")
+	want := map[string]any{
+		"level":       "INFO",
+		"msg":         "http_request",
+		"request_id":  "req-1",
+		"method":      "GET",
+		"path":        "/pets/7",
+		"route":       "/pets/{petId}",
+		"status":      float64(http.StatusTeapot),
+		"bytes":       float64(len("short and stout")),
+		"remote_addr": "10.0.0.2:41000",
+		"client_ip":   "203.0.113.9",
+	}
+	if len(record) != len(want) {
+		t.Fatalf("fields: got %v, want %v", record, want)
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s: got %v, want %v", key, record[key], value)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(t, &buf, appconfig.LoggingConfig{Level: "info", Format: "text"})
+	ctx := logging.With(t.Context(), "user_id", "alice", "route", "/pets")
+	child := logging.With(ctx, slog.Int("pet_id", 7))
+
+	// A field the call sets itself wins over the context's.
+	logger.InfoContext(child, "pet_lookup", "route", "/pets/{petId}")
+	logger.InfoContext(ctx, "pet_list")
+	want := []string{
+		"msg=pet_lookup route=/pets/{petId} user_id=alice pet_id=7",
+		"msg=pet_list user_id=alice route=/pets",
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("output: got %q, want %d lines", buf.String(), len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d: got %s, want it to end with %s", i+1, line, want[i])
+		}
+	}
+}
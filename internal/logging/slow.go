@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"demo/internal/database"
+)
+
+// SlowRequests logs a warning for every request slower than a threshold, with the time
+// its database statements took when the pool's QueryTracer saw them.
+type SlowRequests struct {
+	threshold atomic.Int64
+	logger    *slog.Logger
+}
+
+// NewSlowRequests logs requests taking threshold or longer; zero disables the log.
+func NewSlowRequests(threshold time.Duration, logger *slog.Logger) *SlowRequests {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &SlowRequests{logger: logger}
+	s.SetThreshold(threshold)
+	return s
+}
+
+// SetThreshold changes the threshold for requests that finish from now on.
+func (s *SlowRequests) SetThreshold(d time.Duration) {
+	s.threshold.Store(int64(d))
+}
+
+// Middleware times each request and tracks its database time on the context.
+func (s *SlowRequests) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ctx := database.TrackQueryTime(r.Context())
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		duration := time.Since(start)
+		threshold := time.Duration(s.threshold.Load())
+		if threshold <= 0 || duration < threshold {
+			return
+		}
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		dbTime, queries, _ := database.QueryTime(ctx)
+		// request_id is attached by the context handler installed in New.
+		s.logger.LogAttrs(r.Context(), slog.LevelWarn, "http_slow_request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("duration", duration),
+			slog.Duration("threshold", threshold),
+			slog.Duration("db_time", dbTime),
+			slog.Int("db_queries", queries),
+		)
+	})
+}
@@ -0,0 +1,92 @@
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
+
+	appconfig "demo/internal/config"
+	"demo/internal/database"
+	"demo/internal/logging"
+)
+
+// newSlowRouter serves /pets/{petId}, which runs two statements through a QueryTracer
+// and then takes sleep to answer.
+func newSlowRouter(slow *logging.SlowRequests, sleep time.Duration) http.Handler {
+	tracer := database.NewQueryTracer(0, slog.New(slog.DiscardHandler))
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, slow.Middleware)
+	r.Get("/pets/{petId}", func(w http.ResponseWriter, r *http.Request) {
+		for range 2 {
+			ctx := tracer.TraceQueryStart(r.Context(), nil, pgx.TraceQueryStartData{SQL: "SELECT id FROM pets WHERE id = $1"})
+			tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+		}
+		time.Sleep(sleep)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return r
+}
+
+func TestSlowRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(t, &buf, appconfig.LoggingConfig{Level: "info"})
+	slow := logging.NewSlowRequests(20*time.Millisecond, logger)
+	req := httptest.NewRequest(http.MethodGet, "/pets/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+
+	newSlowRouter(slow, 30*time.Millisecond).ServeHTTP(httptest.NewRecorder(), req)
+	records := decodeLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("records: got %d, want 1 for the slow request", len(records))
+	}
+	record := records[0]
+	for key, want := range map[string]any{
+		"level":      "WARN",
+		"msg":        "http_slow_request",
+		"request_id": "req-1",
+		"method":     "GET",
+		"route":      "/pets/{petId}",
+		"status":     float64(http.StatusAccepted),
+		"threshold":  float64(20 * time.Millisecond),
+		"db_queries": float64(2),
+	} {
+		if record[key] != want {
+			t.Errorf("%s: got %v, want %v", key, record[key], want)
+		}
+	}
+	duration, _ := record["duration"].(float64)
+	dbTime, _ := record["db_time"].(float64)
+	if duration < float64(30*time.Millisecond) || dbTime <= 0 || dbTime >= duration {
+		t.Errorf("duration %v and db_time %v: want at least 30ms, of which some but not all in the database", duration, dbTime)
+	}
+}
+
+func TestSlowRequestsBelowThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	slow := logging.NewSlowRequests(time.Hour, newLogger(t, &buf, appconfig.LoggingConfig{Level: "info"}))
+	router := newSlowRouter(slow, 0)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pets/7", nil))
+	if buf.Len() != 0 {
+		t.Fatalf("request under the threshold: got %s, want no log", buf.String())
+	}
+
+	// Zero disables the log however slow the request.
+	slow.SetThreshold(0)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pets/7", nil))
+	if buf.Len() != 0 {
+		t.Fatalf("request with the log disabled: got %s, want no log", buf.String())
+	}
+
+	slow.SetThreshold(time.Nanosecond)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pets/7", nil))
+	if records := decodeLines(t, &buf); len(records) != 1 {
+		t.Fatalf("request after lowering the threshold: got %d records, want 1", len(records))
+	}
+}
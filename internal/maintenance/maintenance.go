@@ -0,0 +1,105 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"demo/internal/apierr"
+	"demo/internal/httpmw"
+)
+
+// Mode is a runtime switch that makes the API answer 503 while the backing store is
+// unavailable on purpose, e.g. during migrations.
+type Mode struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+	logger     *slog.Logger
+}
+
+// New returns a Mode starting in the given state. retryAfter is advertised to clients in
+// the Retry-After header, rounded up to whole seconds.
+func New(enabled bool, retryAfter time.Duration, logger *slog.Logger) *Mode {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &Mode{retryAfter: retryAfter, logger: logger}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether maintenance mode is on.
+func (m *Mode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set switches maintenance mode on or off.
+func (m *Mode) Set(enabled bool) {
+	if m.enabled.Swap(enabled) != enabled {
+		m.logger.Info("maintenance_mode_changed", "enabled", enabled)
+	}
+}
+
+// Status describes the current state for readiness output.
+func (m *Mode) Status() string {
+	if m.Enabled() {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// Middleware rejects requests with 503 while maintenance mode is on, except for the exempt
+// paths (health probes) so orchestrators keep the instance alive.
+func (m *Mode) Middleware(exempt ...string) func(http.Handler) http.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[path] = true
+	}
+	retryAfter := strconv.Itoa(int((m.retryAfter + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !m.Enabled() || skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if m.retryAfter > 0 {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			httpmw.WriteError(w, r, apierr.ErrUnavailable, "service is in maintenance mode")
+		})
+	}
+}
+
+// Toggle serves the admin endpoint: POST with {"enabled": true|false} sets the mode, and
+// every request answers with the resulting state.
+func (m *Mode) Toggle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			httpmw.WriteError(w, r, apierr.ErrInvalidRequest, `body must be {"enabled": true|false}`)
+			return
+		}
+		m.Set(*req.Enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httpmw.WriteError(w, r, apierr.ErrMethodNotAllowed, "method not allowed")
+		return
+	}
+	m.writeJSON(w, http.StatusOK, map[string]bool{"enabled": m.Enabled()})
+}
+
+func (m *Mode) writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		m.logger.Error("maintenance_response_write_failed", "error", err)
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "petstore",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route pattern, and status class.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "petstore",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern, and status class.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Handler serves the default Prometheus registry together with registries, such as the
+// one an App registers its per-run collectors in. Nil registries are skipped.
+func Handler(registries ...prometheus.Gatherer) http.Handler {
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer}
+	for _, g := range registries {
+		if g != nil {
+			gatherers = append(gatherers, g)
+		}
+	}
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}))
+}
+
+// Middleware records request counts and durations. Routes are labeled by chi's route
+// pattern, read after routing so /pets/{petId} stays a single series. Requests to
+// excludePath (the metrics endpoint itself) are not recorded.
+func Middleware(excludePath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == excludePath {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" {
+					route = pattern
+				}
+			}
+
+			labels := prometheus.Labels{
+				"method": r.Method,
+				"route":  route,
+				"status": statusClass(ww.Status()),
+			}
+			httpRequests.With(labels).Inc()
+			httpDuration.With(labels).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+func statusClass(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
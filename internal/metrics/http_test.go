@@ -0,0 +1,28 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"demo/internal/metrics"
+)
+
+// TestHandlerServesRegistries checks that Handler serves each given registry next to the
+// default one, so collectors of two runs can live in separate registries.
+func TestHandlerServesRegistries(t *testing.T) {
+	for run := 1; run <= 2; run++ {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "petstore_test_run", Help: "Test gauge."}))
+
+		rec := httptest.NewRecorder()
+		metrics.Handler(registry, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(body, "petstore_test_run") || !strings.Contains(body, "go_goroutines") {
+			t.Fatalf("run %d: GET /metrics: got %d without the run's gauge or the default registry:\n%s", run, rec.Code, body)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is returned, wrapped in a *CircuitOpenError, while the database circuit
+// breaker fails calls fast.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// CircuitOpenError is a call the breaker refused; RetryAfter is what remains of the
+// cool-down.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string { return ErrCircuitOpen.Error() }
+func (e *CircuitOpenError) Unwrap() error { return ErrCircuitOpen }
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half_open"
+	case breakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops calls to the database after threshold consecutive failures, so an
+// outage costs requests a fast 503 instead of a full query timeout and a pool slot each.
+// After cooldown it lets a single probe through: success closes it, failure reopens it.
+// Only errors that point at the database count; not-found, conflicts, ownership, and
+// canceled requests are successes as far as the breaker is concerned.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	stateGauge  prometheus.Gauge
+	transitions *prometheus.CounterVec
+}
+
+// NewCircuitBreaker builds a closed breaker.
+func NewCircuitBreaker(threshold int, cooldown time.Duration, logger *slog.Logger) *CircuitBreaker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		logger:    logger,
+		now:       time.Now,
+		stateGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "petstore",
+			Subsystem: "db_breaker",
+			Name:      "state",
+			Help:      "Database circuit breaker state: 0 closed, 1 half-open, 2 open.",
+		}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "petstore",
+			Subsystem: "db_breaker",
+			Name:      "transitions_total",
+			Help:      "Database circuit breaker state transitions by new state.",
+		}, []string{"state"}),
+	}
+}
+
+// Check fails while the breaker is open, for /readyz.
+func (b *CircuitBreaker) Check(context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// do runs fn unless the breaker is open and records its outcome.
+func (b *CircuitBreaker) do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		remaining := b.cooldown - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return &CircuitOpenError{RetryAfter: remaining}
+		}
+		b.transition(breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{RetryAfter: b.cooldown}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failed := isDatabaseFailure(err)
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if errors.Is(err, context.Canceled) {
+			// The probe told us nothing; let the next call try again.
+			return
+		}
+		if failed {
+			b.open()
+		} else {
+			b.failures = 0
+			b.transition(breakerClosed)
+		}
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerClosed && b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+// open starts a cool-down; the caller holds mu.
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.now()
+	b.transition(breakerOpen)
+}
+
+// transition moves to state, logging and counting the change; the caller holds mu.
+func (b *CircuitBreaker) transition(state breakerState) {
+	if b.state == state {
+		return
+	}
+	b.logger.Warn("db_breaker_state_changed", "from", b.state.String(), "to", state.String(), "failures", b.failures)
+	b.state = state
+	b.stateGauge.Set(float64(state))
+	b.transitions.WithLabelValues(state.String()).Inc()
+}
+
+// isDatabaseFailure reports whether err suggests the database is unhealthy, as opposed
+// to an expected outcome or a caller that gave up.
+func isDatabaseFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrPetNotFound),
+		errors.Is(err, ErrPetExists),
+		errors.Is(err, ErrNotPetOwner),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+// Describe implements prometheus.Collector.
+func (b *CircuitBreaker) Describe(ch chan<- *prometheus.Desc) {
+	b.stateGauge.Describe(ch)
+	b.transitions.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (b *CircuitBreaker) Collect(ch chan<- prometheus.Metric) {
+	b.stateGauge.Collect(ch)
+	b.transitions.Collect(ch)
+}
+
+// BreakerRepository guards a PetRepository with a CircuitBreaker.
+type BreakerRepository struct {
+	next    PetRepository
+	breaker *CircuitBreaker
+}
+
+// NewBreakerRepository wraps next.
+func NewBreakerRepository(next PetRepository, breaker *CircuitBreaker) *BreakerRepository {
+	return &BreakerRepository{next: next, breaker: breaker}
+}
+
+// ListPets calls the wrapped ListPets unless the breaker is open.
+func (r *BreakerRepository) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	var pets []Pet
+	err := r.breaker.do(func() (err error) {
+		pets, err = r.next.ListPets(ctx, limit, ownedBy)
+		return err
+	})
+	return pets, err
+}
+
+// CreatePet calls the wrapped CreatePet unless the breaker is open.
+func (r *BreakerRepository) CreatePet(ctx context.Context, pet Pet, owner string) error {
+	return r.breaker.do(func() error { return r.next.CreatePet(ctx, pet, owner) })
+}
+
+// GetPet calls the wrapped GetPet unless the breaker is open.
+func (r *BreakerRepository) GetPet(ctx context.Context, id int64) (Pet, error) {
+	var pet Pet
+	err := r.breaker.do(func() (err error) {
+		pet, err = r.next.GetPet(ctx, id)
+		return err
+	})
+	return pet, err
+}
+
+// UpdatePet calls the wrapped UpdatePet unless the breaker is open.
+func (r *BreakerRepository) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
+	return r.breaker.do(func() error { return r.next.UpdatePet(ctx, pet, ownedBy) })
+}
+
+// DeletePet calls the wrapped DeletePet unless the breaker is open.
+func (r *BreakerRepository) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	return r.breaker.do(func() error { return r.next.DeletePet(ctx, id, ownedBy) })
+}
+
+var (
+	_ PetRepository        = (*BreakerRepository)(nil)
+	_ prometheus.Collector = (*CircuitBreaker)(nil)
+)
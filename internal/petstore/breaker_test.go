@@ -0,0 +1,113 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// scriptedRepository answers GetPet through getPet and counts the calls that reach it.
+type scriptedRepository struct {
+	*MemoryRepository
+	getPet func(ctx context.Context) error
+	calls  int
+}
+
+func (r *scriptedRepository) GetPet(ctx context.Context, id int64) (Pet, error) {
+	r.calls++
+	return Pet{Id: id}, r.getPet(ctx)
+}
+
+// TestCircuitBreakerCycle walks the breaker from closed to open to half-open and back,
+// on a clock the test advances.
+func TestCircuitBreakerCycle(t *testing.T) {
+	errDown := errors.New("connection refused")
+	now := time.Now()
+	breaker := NewCircuitBreaker(3, time.Minute, slog.New(slog.DiscardHandler))
+	breaker.now = func() time.Time { return now }
+	next := &scriptedRepository{MemoryRepository: NewMemoryRepository()}
+	repo := NewBreakerRepository(next, breaker)
+	get := func(err error) error {
+		next.getPet = func(context.Context) error { return err }
+		_, got := repo.GetPet(t.Context(), 7)
+		return got
+	}
+	assertState := func(want breakerState) {
+		t.Helper()
+		if breaker.state != want {
+			t.Fatalf("state: got %s, want %s", breaker.state, want)
+		}
+		if err := breaker.Check(t.Context()); (err != nil) != (want == breakerOpen) {
+			t.Fatalf("Check in state %s: got %v", want, err)
+		}
+	}
+
+	// Closed: only consecutive database failures count toward the threshold.
+	get(errDown)
+	get(errDown)
+	get(ErrPetNotFound)
+	get(errDown)
+	get(errDown)
+	assertState(breakerClosed)
+	if err := get(errDown); !errors.Is(err, errDown) {
+		t.Fatalf("third consecutive failure: got %v, want the repository's error", err)
+	}
+	assertState(breakerOpen)
+
+	// Open: calls fail fast without reaching the repository.
+	now = now.Add(20 * time.Second)
+	calls := next.calls
+	var open *CircuitOpenError
+	if err := get(nil); !errors.As(err, &open) || !errors.Is(err, ErrCircuitOpen) || open.RetryAfter != 40*time.Second {
+		t.Fatalf("call while open: got %v, want ErrCircuitOpen with 40s left", err)
+	}
+	if next.calls != calls {
+		t.Fatal("call while open reached the repository")
+	}
+
+	// Half-open: after the cool-down a single probe goes through, and its failure reopens.
+	now = now.Add(40 * time.Second)
+	next.getPet = func(ctx context.Context) error {
+		assertState(breakerHalfOpen)
+		if _, err := repo.GetPet(ctx, 8); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("call during the probe: got %v, want ErrCircuitOpen", err)
+		}
+		return errDown
+	}
+	if _, err := repo.GetPet(t.Context(), 7); !errors.Is(err, errDown) {
+		t.Fatalf("failed probe: got %v, want the repository's error", err)
+	}
+	assertState(breakerOpen)
+	if err := get(nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("call after a failed probe: got %v, want a fresh cool-down", err)
+	}
+
+	// A canceled probe says nothing about the database and leaves the breaker half-open.
+	now = now.Add(time.Minute)
+	if err := get(context.Canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled probe: got %v", err)
+	}
+	assertState(breakerHalfOpen)
+
+	// A successful probe closes the breaker and resets the failure count.
+	if err := get(nil); err != nil {
+		t.Fatalf("successful probe: got %v", err)
+	}
+	assertState(breakerClosed)
+	get(errDown)
+	get(errDown)
+	assertState(breakerClosed)
+
+	for state, want := range map[string]float64{"open": 2, "half_open": 2, "closed": 1} {
+		if got := testutil.ToFloat64(breaker.transitions.WithLabelValues(state)); got != want {
+			t.Errorf("transitions to %s: got %v, want %v", state, got, want)
+		}
+	}
+	if got := testutil.ToFloat64(breaker.stateGauge); got != float64(breakerClosed) {
+		t.Errorf("state gauge: got %v, want closed (0)", got)
+	}
+}
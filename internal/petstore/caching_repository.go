@@ -0,0 +1,226 @@
+package petstore
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"demo/internal/tenant"
+)
+
+// CacheOptions configures CachingRepository.
+type CacheOptions struct {
+	// Size bounds the number of cached entries, positive and negative combined.
+	Size int
+	// TTL is how long a found pet stays cached.
+	TTL time.Duration
+	// NegativeTTL caches not-found lookups for this long; zero disables negative caching.
+	NegativeTTL time.Duration
+}
+
+// CachingRepository decorates a PetRepository with a bounded in-memory LRU for GetPet.
+// ListPets always goes to the wrapped repository. Entries are keyed by the organization on
+// the context as well as the id, since ids are only unique within an organization.
+type CachingRepository struct {
+	next PetRepository
+	opts CacheOptions
+
+	mu         sync.Mutex
+	entries    map[cacheKey]*list.Element
+	order      *list.List
+	generation uint64
+
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	hitsMetric   prometheus.CounterFunc
+	missesMetric prometheus.CounterFunc
+}
+
+type cacheKey struct {
+	org string
+	id  int64
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	pet      Pet
+	notFound bool
+	expires  time.Time
+}
+
+// NewCachingRepository wraps next with an LRU cache described by opts.
+func NewCachingRepository(next PetRepository, opts CacheOptions) (*CachingRepository, error) {
+	if opts.Size <= 0 {
+		return nil, errors.New("cache size must be positive")
+	}
+	if opts.TTL <= 0 {
+		return nil, errors.New("cache ttl must be positive")
+	}
+	if opts.NegativeTTL < 0 {
+		return nil, errors.New("cache negative ttl must be non-negative")
+	}
+
+	c := &CachingRepository{
+		next:    next,
+		opts:    opts,
+		entries: make(map[cacheKey]*list.Element, opts.Size),
+		order:   list.New(),
+	}
+	c.hitsMetric = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "petstore",
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "GetPet lookups served from the in-memory cache.",
+	}, func() float64 { return float64(c.hits.Load()) })
+	c.missesMetric = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "petstore",
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "GetPet lookups that went to the wrapped repository.",
+	}, func() float64 { return float64(c.misses.Load()) })
+
+	return c, nil
+}
+
+// ListPets bypasses the cache.
+func (c *CachingRepository) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	return c.next.ListPets(ctx, limit, ownedBy)
+}
+
+// GetPet serves from the cache when possible and populates it on a miss.
+func (c *CachingRepository) GetPet(ctx context.Context, id int64) (Pet, error) {
+	key := cacheKey{org: tenant.FromContext(ctx), id: id}
+	c.mu.Lock()
+	if entry, ok := c.lookup(key); ok {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		if entry.notFound {
+			return Pet{}, ErrPetNotFound
+		}
+		return copyPet(entry.pet), nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	pet, err := c.next.GetPet(ctx, id)
+	switch {
+	case err == nil:
+		c.store(generation, cacheEntry{key: key, pet: copyPet(pet), expires: time.Now().Add(c.opts.TTL)})
+	case errors.Is(err, ErrPetNotFound) && c.opts.NegativeTTL > 0:
+		c.store(generation, cacheEntry{key: key, notFound: true, expires: time.Now().Add(c.opts.NegativeTTL)})
+	}
+	return pet, err
+}
+
+// CreatePet writes through and drops any cached (typically negative) entry for the id.
+func (c *CachingRepository) CreatePet(ctx context.Context, pet Pet, owner string) error {
+	defer c.invalidate(tenant.FromContext(ctx), pet.Id)
+	return c.next.CreatePet(ctx, pet, owner)
+}
+
+// UpdatePet writes through and invalidates the cached entry, whether or not the write succeeded.
+func (c *CachingRepository) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
+	defer c.invalidate(tenant.FromContext(ctx), pet.Id)
+	return c.next.UpdatePet(ctx, pet, ownedBy)
+}
+
+// DeletePet writes through and invalidates the cached entry, whether or not the write succeeded.
+func (c *CachingRepository) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	defer c.invalidate(tenant.FromContext(ctx), id)
+	return c.next.DeletePet(ctx, id, ownedBy)
+}
+
+// Invalidate drops the cached entry for org's pet id, e.g. when another instance reports
+// a write.
+func (c *CachingRepository) Invalidate(org string, id int64) {
+	c.invalidate(org, id)
+}
+
+// Stats returns the cumulative hit and miss counts.
+func (c *CachingRepository) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Describe implements prometheus.Collector.
+func (c *CachingRepository) Describe(ch chan<- *prometheus.Desc) {
+	c.hitsMetric.Describe(ch)
+	c.missesMetric.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *CachingRepository) Collect(ch chan<- prometheus.Metric) {
+	c.hitsMetric.Collect(ch)
+	c.missesMetric.Collect(ch)
+}
+
+// lookup returns a live entry and marks it most recently used. Callers hold c.mu.
+func (c *CachingRepository) lookup(key cacheKey) (cacheEntry, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	entry := elem.Value.(cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return cacheEntry{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+// store inserts entry unless a write happened since generation was read, which would
+// otherwise let a concurrent read repopulate the cache with stale data.
+func (c *CachingRepository) store(generation uint64, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.opts.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(cacheEntry).key)
+	}
+}
+
+func (c *CachingRepository) invalidate(org string, id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	key := cacheKey{org: org, id: id}
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// copyPet detaches the Tag pointer so callers can't mutate cached state.
+func copyPet(pet Pet) Pet {
+	if pet.Tag != nil {
+		tag := *pet.Tag
+		pet.Tag = &tag
+	}
+	return pet
+}
+
+var (
+	_ PetRepository        = (*CachingRepository)(nil)
+	_ prometheus.Collector = (*CachingRepository)(nil)
+)
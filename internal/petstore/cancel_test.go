@@ -0,0 +1,147 @@
+package petstore_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/blob"
+	"demo/internal/petstore"
+)
+
+// blockLimit bounds how long blockingRepository waits for a context to end, and how long
+// the cancellation tests wait for anything.
+const blockLimit = 5 * time.Second
+
+// errNeverCanceled is what a blockingRepository read returns when its context outlived
+// blockLimit.
+var errNeverCanceled = errors.New("no cancellation within 5s")
+
+// blockingRepository is a PetRepository whose ListPets and GetPet park until their
+// context ends and report how it ended on ended, so a test can see whether abandoning a
+// request reaches the bottom of the repository chain. entered is signaled when a read
+// starts blocking. Nothing writes, so the embedded repository is nil.
+type blockingRepository struct {
+	petstore.PetRepository
+	entered chan struct{}
+	ended   chan error
+}
+
+func newBlockingRepository() *blockingRepository {
+	return &blockingRepository{entered: make(chan struct{}, 1), ended: make(chan error, 1)}
+}
+
+func (b *blockingRepository) ListPets(ctx context.Context, _ int32, _ string) ([]petstore.Pet, error) {
+	return nil, b.block(ctx)
+}
+
+func (b *blockingRepository) GetPet(ctx context.Context, _ int64) (petstore.Pet, error) {
+	return petstore.Pet{}, b.block(ctx)
+}
+
+func (b *blockingRepository) block(ctx context.Context) error {
+	b.entered <- struct{}{}
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-time.After(blockLimit):
+		err = errNeverCanceled
+	}
+	b.ended <- err
+	return err
+}
+
+// runCancellationTests serves reads over a real connection to a blockingRepository and
+// disconnects the client while the repository is working on them: the repository's
+// context must be canceled by then, or a query outlives the request that wanted it. wrap
+// builds the repository chain under test over the blocking one, e.g. the caches and
+// circuit breaker the application stacks; nil tests the handlers alone.
+func runCancellationTests(t *testing.T, wrap func(petstore.PetRepository) petstore.PetRepository) {
+	t.Helper()
+	if wrap == nil {
+		wrap = func(repo petstore.PetRepository) petstore.PetRepository { return repo }
+	}
+	for _, tc := range []struct {
+		name   string
+		target string
+	}{
+		{"ListPets", "/pets"},
+		{"ListPetsLimit", "/pets?limit=10"},
+		{"ShowPetById", "/pets/1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			blocking := newBlockingRepository()
+			server := petstore.NewServer(wrap(blocking), nil)
+			srv := httptest.NewServer(petstore.HandlerWithOptions(server, petstore.ChiServerOptions{BaseRouter: chi.NewRouter()}))
+			t.Cleanup(srv.Close)
+			assertCanceledOnDisconnect(t, blocking, srv.URL+tc.target)
+		})
+	}
+}
+
+// assertCanceledOnDisconnect requests target, waits for it to reach blocking, hangs up,
+// and requires the repository's context to end with context.Canceled.
+func assertCanceledOnDisconnect(t *testing.T, blocking *blockingRepository, target string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	select {
+	case <-blocking.entered:
+	case <-done:
+		t.Fatalf("GET %s finished without reaching the repository", target)
+	case <-time.After(blockLimit):
+		t.Fatalf("GET %s never reached the repository", target)
+	}
+	cancel()
+	<-done
+
+	select {
+	case err := <-blocking.ended:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("GET %s: repository read ended with %q after the client disconnected, want context.Canceled", target, err)
+		}
+	case <-time.After(2 * blockLimit):
+		t.Fatalf("GET %s: repository call never returned", target)
+	}
+}
+
+func TestHandlersCancelRepositoryReads(t *testing.T) {
+	runCancellationTests(t, nil)
+}
+
+// TestRepositoryChainCancelsReads stacks the decorators the application puts in front of
+// PostgreSQL, outermost last as in app.Run.
+func TestRepositoryChainCancelsReads(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	photos, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	runCancellationTests(t, func(repo petstore.PetRepository) petstore.PetRepository {
+		repo = petstore.NewBreakerRepository(repo, petstore.NewCircuitBreaker(5, time.Minute, logger))
+		cached, err := petstore.NewCachingRepository(repo, petstore.CacheOptions{Size: 100, TTL: time.Minute, NegativeTTL: time.Minute})
+		if err != nil {
+			t.Fatalf("NewCachingRepository: %v", err)
+		}
+		return petstore.NewPhotoCleanupRepository(cached, photos, logger)
+	})
+}
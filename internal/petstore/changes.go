@@ -0,0 +1,70 @@
+package petstore
+
+import (
+	"context"
+	"net/http"
+
+	"demo/internal/apierr"
+)
+
+// ChangeFeed lists writes to pets in sequence order for incremental consumers such as a
+// search indexer. PostgresRepository implements it; see postgres_changes.go for how
+// sequence numbers are assigned.
+type ChangeFeed interface {
+	// ListChanges returns up to limit changes with a seq greater than since.
+	ListChanges(ctx context.Context, since int64, limit int32) ([]PetChange, error)
+}
+
+// WithChangeFeed serves GET /pets/changes from feed.
+func WithChangeFeed(feed ChangeFeed) ServerOption {
+	return func(s *Server) {
+		s.changes = feed
+	}
+}
+
+// ListPetChanges returns the changes after since. Clients poll again with next_since;
+// replaying from 0 and applying every change, deletes included, reconstructs the table.
+func (s *Server) ListPetChanges(w http.ResponseWriter, r *http.Request, params ListPetChangesParams) {
+	if s.changes == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgChangeFeedUnavailable))
+		return
+	}
+
+	var since int64
+	if params.Since != nil {
+		since = *params.Since
+		if since < 0 {
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgSinceNegative))
+			return
+		}
+	}
+	limit := int32(100)
+	if params.Limit != nil {
+		limit = *params.Limit
+		if limit <= 0 {
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgLimitNotPositive))
+			return
+		}
+		if limit > 1000 {
+			limit = 1000
+		}
+	}
+
+	changes, err := s.changes.ListChanges(r.Context(), since, limit)
+	if err != nil {
+		if isTimeout(err) {
+			s.logger.WarnContext(r.Context(), "ListPetChanges: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "ListPetChanges: repo error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgListChangesFailed))
+		return
+	}
+
+	next := since
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Seq
+	}
+	s.writeJSON(w, r, http.StatusOK, PetChanges{Changes: changes, NextSince: next})
+}
@@ -0,0 +1,108 @@
+package petstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"demo/internal/tenant"
+)
+
+var (
+	// ErrCursorInvalid is returned for a cursor that is malformed, was not signed with the
+	// server's key, or was issued for another ordering.
+	ErrCursorInvalid = errors.New("cursor is not valid")
+	// ErrCursorExpired is returned for a correctly signed cursor past its expiry.
+	ErrCursorExpired = errors.New("cursor has expired")
+)
+
+// Cursor is a position in an ordered listing: the ordering it was read in and the
+// sort-key values of the last row returned. Clients get it as an opaque, HMAC-signed
+// token, so they can neither read nor forge positions and the encoding can change
+// when new orderings arrive.
+type Cursor struct {
+	// Sort names the sort columns, most significant first, e.g. "id" or "name,id".
+	Sort string `json:"s"`
+	// Desc reports a descending ordering.
+	Desc bool `json:"d,omitempty"`
+	// Keys are the last row's values for the Sort columns, in the same order.
+	Keys []string `json:"k"`
+	// Expires is when the cursor stops being accepted, in Unix seconds.
+	Expires int64 `json:"e"`
+	// Org is the organization the listing was read in, empty for tenant.DefaultOrg so
+	// cursors issued before organizations existed stay valid.
+	Org string `json:"o,omitempty"`
+}
+
+// sortByID is the only ordering ListPets offers so far.
+const sortByID = "id"
+
+// idCursor is a cursor positioned after org's pet id in the default ordering.
+func idCursor(org string, id int64) Cursor {
+	c := Cursor{Sort: sortByID, Keys: []string{strconv.FormatInt(id, 10)}}
+	if org != tenant.DefaultOrg {
+		c.Org = org
+	}
+	return c
+}
+
+// afterID returns the id an "id" ordering cursor points after. A cursor issued in another
+// organization is invalid in org, since ids only order pets within one.
+func (c Cursor) afterID(org string) (int64, error) {
+	cursorOrg := c.Org
+	if cursorOrg == "" {
+		cursorOrg = tenant.DefaultOrg
+	}
+	if c.Sort != sortByID || c.Desc || len(c.Keys) != 1 || cursorOrg != org {
+		return 0, ErrCursorInvalid
+	}
+	id, err := strconv.ParseInt(c.Keys[0], 10, 64)
+	if err != nil {
+		return 0, ErrCursorInvalid
+	}
+	return id, nil
+}
+
+// Encode signs c with key and returns it as base64url text. A zero Expires is filled in
+// from ttl.
+func (c Cursor) Encode(key []byte, ttl time.Duration) string {
+	if c.Expires == 0 {
+		c.Expires = time.Now().Add(ttl).Unix()
+	}
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(append(payload, cursorMAC(key, payload)...))
+}
+
+// DecodeCursor verifies token against key and returns the cursor it holds, with
+// ErrCursorInvalid or ErrCursorExpired when it cannot be used.
+func DecodeCursor(token string, key []byte, now time.Time) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) <= sha256.Size {
+		return Cursor{}, ErrCursorInvalid
+	}
+	payload, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if !hmac.Equal(mac, cursorMAC(key, payload)) {
+		return Cursor{}, ErrCursorInvalid
+	}
+	var c Cursor
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil || c.Sort == "" || len(c.Keys) == 0 {
+		return Cursor{}, ErrCursorInvalid
+	}
+	if now.Unix() >= c.Expires {
+		return Cursor{}, ErrCursorExpired
+	}
+	return c, nil
+}
+
+func cursorMAC(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,129 @@
+package petstore_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"demo/internal/petstore"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	now := time.Now()
+	c := petstore.Cursor{Sort: "name,id", Keys: []string{"Rex", "7"}, Org: "acme"}
+	token := c.Encode(cursorKey, time.Hour)
+
+	got, err := petstore.DecodeCursor(token, cursorKey, now)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if got.Sort != c.Sort || !slices.Equal(got.Keys, c.Keys) || got.Org != c.Org || got.Desc {
+		t.Fatalf("DecodeCursor: got %+v, want %+v", got, c)
+	}
+	if expires := time.Unix(got.Expires, 0); expires.Before(now.Add(time.Hour-time.Minute)) || expires.After(now.Add(time.Hour+time.Minute)) {
+		t.Fatalf("DecodeCursor: expires at %s, want an hour from now", expires)
+	}
+
+	if _, err := petstore.DecodeCursor(token, cursorKey, now.Add(2*time.Hour)); !errors.Is(err, petstore.ErrCursorExpired) {
+		t.Fatalf("DecodeCursor after expiry: got %v, want ErrCursorExpired", err)
+	}
+	if _, err := petstore.DecodeCursor(token, []byte("another-key-0123456789abcdefghij"), now); !errors.Is(err, petstore.ErrCursorInvalid) {
+		t.Fatalf("DecodeCursor with another key: got %v, want ErrCursorInvalid", err)
+	}
+	if _, err := petstore.DecodeCursor("", cursorKey, now); !errors.Is(err, petstore.ErrCursorInvalid) {
+		t.Fatalf("DecodeCursor of an empty token: got %v, want ErrCursorInvalid", err)
+	}
+}
+
+// TestCursorSignature checks the token layout: the JSON payload followed by its
+// HMAC-SHA256 under the key, base64url without padding.
+func TestCursorSignature(t *testing.T) {
+	c := petstore.Cursor{Sort: "id", Keys: []string{"2"}, Expires: time.Now().Add(time.Hour).Unix()}
+	token := c.Encode(cursorKey, 0)
+	if again := c.Encode(cursorKey, 0); again != token {
+		t.Fatalf("Encode is not deterministic: %q then %q", token, again)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) <= sha256.Size {
+		t.Fatalf("token %q: %d bytes, %v; want base64url payload and MAC", token, len(raw), err)
+	}
+	payload, sum := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		t.Fatal("token does not end with the HMAC-SHA256 of its payload")
+	}
+	var decoded petstore.Cursor
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.Sort != "id" || !slices.Equal(decoded.Keys, c.Keys) {
+		t.Fatalf("payload %s: got %+v, %v", payload, decoded, err)
+	}
+
+	if other := c.Encode([]byte("another-key-0123456789abcdefghij"), 0); other == token {
+		t.Fatal("cursors signed with different keys are identical")
+	}
+}
+
+// TestCursorTampering checks that changing any byte of a token, or pairing a forged
+// payload with a genuine MAC, is rejected as invalid rather than decoded.
+func TestCursorTampering(t *testing.T) {
+	now := time.Now()
+	c := petstore.Cursor{Sort: "name,id", Keys: []string{"Rex", "7"}}
+	raw, err := base64.RawURLEncoding.DecodeString(c.Encode(cursorKey, time.Hour))
+	if err != nil {
+		t.Fatalf("decoding token: %v", err)
+	}
+
+	for i := range raw {
+		tampered := slices.Clone(raw)
+		tampered[i] ^= 0x01
+		if _, err := petstore.DecodeCursor(base64.RawURLEncoding.EncodeToString(tampered), cursorKey, now); !errors.Is(err, petstore.ErrCursorInvalid) {
+			t.Fatalf("byte %d flipped: got %v, want ErrCursorInvalid", i, err)
+		}
+	}
+
+	payload, sum := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	var forged petstore.Cursor
+	if err := json.Unmarshal(payload, &forged); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	forged.Keys = []string{"Rex", "1"}
+	forgedPayload, err := json.Marshal(forged)
+	if err != nil {
+		t.Fatalf("encoding forged payload: %v", err)
+	}
+	for name, token := range map[string][]byte{
+		"ForgedPayload": append(forgedPayload, sum...),
+		"Truncated":     raw[:len(raw)-1],
+		"MACOnly":       sum,
+	} {
+		if _, err := petstore.DecodeCursor(base64.RawURLEncoding.EncodeToString(token), cursorKey, now); !errors.Is(err, petstore.ErrCursorInvalid) {
+			t.Errorf("%s: got %v, want ErrCursorInvalid", name, err)
+		}
+	}
+}
+
+// TestCursorMultiColumnKeys checks that orderings over several columns keep every key,
+// in order and byte for byte, along with the direction.
+func TestCursorMultiColumnKeys(t *testing.T) {
+	for _, c := range []petstore.Cursor{
+		{Sort: "name,id", Keys: []string{"Rex", "7"}},
+		{Sort: "name,id", Desc: true, Keys: []string{"Rex", "7"}},
+		{Sort: "tag,name,id", Keys: []string{"", "a,b", "42"}},
+		{Sort: "name,id", Keys: []string{"Zoë \"the\" cat", "-9223372036854775808"}, Org: "acme"},
+	} {
+		got, err := petstore.DecodeCursor(c.Encode(cursorKey, time.Hour), cursorKey, time.Now())
+		if err != nil {
+			t.Errorf("%+v: DecodeCursor: %v", c, err)
+			continue
+		}
+		if got.Sort != c.Sort || got.Desc != c.Desc || got.Org != c.Org || !slices.Equal(got.Keys, c.Keys) {
+			t.Errorf("DecodeCursor: got %+v, want %+v", got, c)
+		}
+	}
+}
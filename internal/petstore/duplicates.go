@@ -0,0 +1,134 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+)
+
+// ErrNotDuplicates indicates a merge named a pet whose duplicate key (trimmed, case-folded
+// name and tag) differs from the survivor's.
+var ErrNotDuplicates = errors.New("pets are not duplicates")
+
+// Deduper finds pets that look like duplicates and merges them. PostgresRepository
+// implements it.
+type Deduper interface {
+	// ListDuplicates returns up to limit groups of live pets sharing a duplicate key,
+	// ordered by their lowest id.
+	ListDuplicates(ctx context.Context, limit int32) ([]DuplicateGroup, error)
+	// MergePets soft-deletes duplicates and records them as merged into survivor, in one
+	// transaction, and returns the survivor. A non-empty ownedBy requires every pet to
+	// belong to that owner; mergedBy is recorded with the merge.
+	MergePets(ctx context.Context, survivor int64, duplicates []int64, ownedBy, mergedBy string) (Pet, error)
+}
+
+// WithDeduper serves GET /pets/duplicates and POST /pets/merge from deduper; without one
+// both answer 404. invalidate, when set, is called for every pet a merge changes so
+// caches in front of the repository drop them.
+func WithDeduper(deduper Deduper, invalidate func(ctx context.Context, id int64)) ServerOption {
+	return func(s *Server) {
+		s.deduper = deduper
+		s.invalidate = invalidate
+	}
+}
+
+// maxMergeDuplicates caps the duplicates one merge may name.
+const maxMergeDuplicates = 100
+
+// ListPetDuplicates returns groups of pets whose names match after trimming and case
+// folding and whose tags are identical.
+func (s *Server) ListPetDuplicates(w http.ResponseWriter, r *http.Request, params ListPetDuplicatesParams) {
+	if s.deduper == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgDeduplicationUnavailable))
+		return
+	}
+	limit := int32(100)
+	if params.Limit != nil {
+		limit = *params.Limit
+		if limit <= 0 {
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgLimitNotPositive))
+			return
+		}
+		limit = min(limit, 100)
+	}
+
+	groups, err := s.deduper.ListDuplicates(r.Context(), limit)
+	if err != nil {
+		if isTimeout(err) {
+			s.logger.WarnContext(r.Context(), "ListPetDuplicates: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "ListPetDuplicates: repo error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgListDuplicatesFailed))
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, groups)
+}
+
+// MergePets merges the request's duplicates into its survivor.
+func (s *Server) MergePets(w http.ResponseWriter, r *http.Request) {
+	if s.deduper == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgDeduplicationUnavailable))
+		return
+	}
+	var req MergeRequest
+	if !s.decodeJSON(w, r, "MergePets", &req) {
+		return
+	}
+	switch {
+	case len(req.DuplicateIds) == 0:
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgMergeDuplicatesRequired))
+		return
+	case len(req.DuplicateIds) > maxMergeDuplicates:
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgMergeTooManyDuplicates, "max", maxMergeDuplicates))
+		return
+	case slices.Contains(req.DuplicateIds, req.SurvivorId):
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgMergeIntoSelf))
+		return
+	}
+	duplicates := slices.Clone(req.DuplicateIds)
+	slices.Sort(duplicates)
+	duplicates = slices.Compact(duplicates)
+
+	var mergedBy string
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		mergedBy = user.ID
+	}
+	survivor, err := s.deduper.MergePets(r.Context(), req.SurvivorId, duplicates, s.requiredOwner(r), mergedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPetNotFound):
+			s.logger.InfoContext(r.Context(), "MergePets: pet not found", "survivor_id", req.SurvivorId, "duplicate_ids", duplicates)
+			s.writeError(w, r, apierr.ErrPetNotFound)
+		case errors.Is(err, ErrNotPetOwner):
+			s.logger.InfoContext(r.Context(), "MergePets: not owner", "survivor_id", req.SurvivorId)
+			s.writeError(w, r, apierr.ErrNotPetOwner)
+		case errors.Is(err, ErrNotDuplicates):
+			s.logger.InfoContext(r.Context(), "MergePets: not duplicates", "survivor_id", req.SurvivorId, "duplicate_ids", duplicates)
+			s.writeError(w, r, apierr.ErrConflict.With(msgNotDuplicates))
+		case isTimeout(err):
+			s.logger.WarnContext(r.Context(), "MergePets: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+		default:
+			s.logger.ErrorContext(r.Context(), "MergePets: repo error", "error", err)
+			s.writeError(w, r, apierr.ErrInternal.With(msgMergePetsFailed))
+		}
+		return
+	}
+
+	if s.invalidate != nil {
+		for _, id := range append([]int64{survivor.Id}, duplicates...) {
+			s.invalidate(r.Context(), id)
+		}
+	}
+	if s.photos != nil {
+		deletePhotos(r.Context(), s.photos, s.logger, duplicates...)
+	}
+	s.audit(r, "MergePets", survivor.Id, "duplicate_ids", duplicates)
+	s.writeJSON(w, r, http.StatusOK, survivor)
+}
@@ -0,0 +1,162 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+)
+
+// ErrExportNotFound indicates no export job has the requested identifier, or that it
+// belongs to another user.
+var ErrExportNotFound = errors.New("export not found")
+
+// ErrExportNotReady indicates the export job has not succeeded, so there is nothing to
+// download yet.
+var ErrExportNotReady = errors.New("export has not succeeded")
+
+// Exports queues pet exports and serves their results; internal/export implements it.
+//
+// A non-empty ownedBy on Create limits the export to that owner's pets, and such a job
+// is only visible to Get and Open when caller is the same owner.
+type Exports interface {
+	Create(ctx context.Context, format ExportFormat, ownedBy string) (ExportJob, error)
+	Get(ctx context.Context, id, caller string) (ExportJob, error)
+	// Open returns the finished file of a succeeded job; the caller closes it.
+	Open(ctx context.Context, id, caller string) (ExportJob, io.ReadCloser, error)
+}
+
+// WithExports enables the /pets/exports routes.
+func WithExports(exports Exports) ServerOption {
+	return func(s *Server) {
+		s.exports = exports
+	}
+}
+
+// exportContentTypes maps each format to the Content-Type of its download.
+var exportContentTypes = map[ExportFormat]string{
+	Csv:    "text/csv; charset=utf-8",
+	Ndjson: "application/x-ndjson",
+}
+
+// CreatePetExport queues an export and answers 202 with the job and its status URL.
+func (s *Server) CreatePetExport(w http.ResponseWriter, r *http.Request) {
+	if s.exports == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgExportsDisabled))
+		return
+	}
+
+	var req ExportRequest
+	if r.ContentLength != 0 {
+		if !s.decodeJSON(w, r, "CreatePetExport", &req) {
+			return
+		}
+	}
+	format := Csv
+	if req.Format != nil {
+		format = *req.Format
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidExportFormat))
+		return
+	}
+
+	var ownedBy string
+	if req.Mine != nil && *req.Mine {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			s.writeError(w, r, apierr.ErrUnauthenticated.With(msgAuthRequiredForMine))
+			return
+		}
+		ownedBy = user.ID
+	}
+
+	job, err := s.exports.Create(r.Context(), format, ownedBy)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "CreatePetExport: create error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgQueueExportFailed))
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "CreatePetExport: queued", "export_id", job.Id, "format", format)
+	w.Header().Set("Location", fmt.Sprintf("%s/pets/exports/%s", s.basePath, job.Id))
+	s.writeJSON(w, r, http.StatusAccepted, s.exportView(job))
+}
+
+// ShowPetExport reports an export's status and progress.
+func (s *Server) ShowPetExport(w http.ResponseWriter, r *http.Request, exportId string) {
+	if s.exports == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgExportsDisabled))
+		return
+	}
+
+	job, err := s.exports.Get(r.Context(), exportId, callerID(r))
+	if err != nil {
+		s.writeExportError(w, r, "ShowPetExport", err)
+		return
+	}
+	if job.Status == Queued || job.Status == Running {
+		// Polling clients should not see a cached status.
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	s.writeJSON(w, r, http.StatusOK, s.exportView(job))
+}
+
+// DownloadPetExport streams the file of a succeeded export.
+func (s *Server) DownloadPetExport(w http.ResponseWriter, r *http.Request, exportId string) {
+	if s.exports == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgExportsDisabled))
+		return
+	}
+
+	job, file, err := s.exports.Open(r.Context(), exportId, callerID(r))
+	if err != nil {
+		s.writeExportError(w, r, "DownloadPetExport", err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", exportContentTypes[job.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pets-%s.%s"`, job.Id, job.Format))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		s.logger.WarnContext(r.Context(), "DownloadPetExport: copy error", "export_id", job.Id, "error", err)
+	}
+}
+
+func (s *Server) writeExportError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	switch {
+	case errors.Is(err, ErrExportNotFound):
+		s.logger.InfoContext(r.Context(), op+": export not found")
+		s.writeError(w, r, apierr.ErrExportNotFound)
+	case errors.Is(err, ErrExportNotReady):
+		s.writeError(w, r, apierr.ErrConflict.With(msgExportNotReady))
+	case isTimeout(err):
+		s.logger.WarnContext(r.Context(), op+": store timeout", "error", err)
+		s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+	default:
+		s.logger.ErrorContext(r.Context(), op+": store error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgFetchExportFailed))
+	}
+}
+
+// exportView fills in the download URL of a succeeded job.
+func (s *Server) exportView(job ExportJob) ExportJob {
+	if job.Status == Succeeded {
+		url := fmt.Sprintf("%s/pets/exports/%s/download", s.basePath, job.Id)
+		job.DownloadUrl = &url
+	}
+	return job
+}
+
+// callerID is the authenticated user's ID, or "" for anonymous requests.
+func callerID(r *http.Request) string {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		return user.ID
+	}
+	return ""
+}
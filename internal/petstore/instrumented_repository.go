@@ -0,0 +1,137 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentedRepository decorates a PetRepository with Prometheus metrics. It also acts as a
+// prometheus.Collector exporting the backing pool statistics alongside the operation metrics.
+type InstrumentedRepository struct {
+	next     PetRepository
+	pool     *pgxpool.Pool
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+
+	acquiredConns *prometheus.Desc
+	idleConns     *prometheus.Desc
+	totalConns    *prometheus.Desc
+	maxConns      *prometheus.Desc
+}
+
+// NewInstrumentedRepository wraps next; pool may be nil when no pool statistics are available.
+func NewInstrumentedRepository(next PetRepository, pool *pgxpool.Pool) *InstrumentedRepository {
+	return &InstrumentedRepository{
+		next: next,
+		pool: pool,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "petstore",
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of pet repository operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "petstore",
+			Subsystem: "repository",
+			Name:      "errors_total",
+			Help:      "Pet repository operation errors by kind.",
+		}, []string{"operation", "kind"}),
+		acquiredConns: prometheus.NewDesc("petstore_db_pool_acquired_conns", "Connections currently acquired from the pool.", nil, nil),
+		idleConns:     prometheus.NewDesc("petstore_db_pool_idle_conns", "Idle connections in the pool.", nil, nil),
+		totalConns:    prometheus.NewDesc("petstore_db_pool_total_conns", "Total connections currently open in the pool.", nil, nil),
+		maxConns:      prometheus.NewDesc("petstore_db_pool_max_conns", "Maximum size of the pool.", nil, nil),
+	}
+}
+
+// ListPets records metrics around the wrapped ListPets.
+func (r *InstrumentedRepository) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	start := time.Now()
+	pets, err := r.next.ListPets(ctx, limit, ownedBy)
+	r.observe("list", start, err)
+	return pets, err
+}
+
+// CreatePet records metrics around the wrapped CreatePet.
+func (r *InstrumentedRepository) CreatePet(ctx context.Context, pet Pet, owner string) error {
+	start := time.Now()
+	err := r.next.CreatePet(ctx, pet, owner)
+	r.observe("create", start, err)
+	return err
+}
+
+// GetPet records metrics around the wrapped GetPet.
+func (r *InstrumentedRepository) GetPet(ctx context.Context, id int64) (Pet, error) {
+	start := time.Now()
+	pet, err := r.next.GetPet(ctx, id)
+	r.observe("get", start, err)
+	return pet, err
+}
+
+// UpdatePet records metrics around the wrapped UpdatePet.
+func (r *InstrumentedRepository) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
+	start := time.Now()
+	err := r.next.UpdatePet(ctx, pet, ownedBy)
+	r.observe("update", start, err)
+	return err
+}
+
+// DeletePet records metrics around the wrapped DeletePet.
+func (r *InstrumentedRepository) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	start := time.Now()
+	err := r.next.DeletePet(ctx, id, ownedBy)
+	r.observe("delete", start, err)
+	return err
+}
+
+func (r *InstrumentedRepository) observe(operation string, start time.Time, err error) {
+	r.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		r.errors.WithLabelValues(operation, errorKind(err)).Inc()
+	}
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrPetNotFound):
+		return "not_found"
+	case errors.Is(err, ErrPetExists):
+		return "conflict"
+	default:
+		return "other"
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (r *InstrumentedRepository) Describe(ch chan<- *prometheus.Desc) {
+	r.duration.Describe(ch)
+	r.errors.Describe(ch)
+	if r.pool != nil {
+		ch <- r.acquiredConns
+		ch <- r.idleConns
+		ch <- r.totalConns
+		ch <- r.maxConns
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (r *InstrumentedRepository) Collect(ch chan<- prometheus.Metric) {
+	r.duration.Collect(ch)
+	r.errors.Collect(ch)
+	if r.pool != nil {
+		stat := r.pool.Stat()
+		ch <- prometheus.MustNewConstMetric(r.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+		ch <- prometheus.MustNewConstMetric(r.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+		ch <- prometheus.MustNewConstMetric(r.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+		ch <- prometheus.MustNewConstMetric(r.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	}
+}
+
+var (
+	_ PetRepository        = (*InstrumentedRepository)(nil)
+	_ prometheus.Collector = (*InstrumentedRepository)(nil)
+)
@@ -0,0 +1,21 @@
+package petstore_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+
+	"demo/internal/database/databasetest"
+)
+
+func TestMain(m *testing.M) {
+	code := databasetest.Main(m)
+	if redisContainer != nil {
+		if err := testcontainers.TerminateContainer(redisContainer); err != nil {
+			fmt.Fprintf(os.Stderr, "petstore: removing %s container: %v\n", redisImage, err)
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,185 @@
+package petstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"demo/internal/tenant"
+)
+
+// MemoryRepository implements PetRepository and Pager in process memory. It backs tests
+// and local runs without a database; nothing survives a restart.
+type MemoryRepository struct {
+	mu   sync.Mutex
+	pets map[memoryKey]memoryPet
+}
+
+type memoryKey struct {
+	org string
+	id  int64
+}
+
+type memoryPet struct {
+	pet   Pet
+	owner string
+}
+
+// NewMemoryRepository returns an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{pets: make(map[memoryKey]memoryPet)}
+}
+
+// ListPets returns pets ordered by identifier; limit==0 fetches all records.
+func (r *MemoryRepository) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	return r.listPets(ctx, nil, limit, ownedBy)
+}
+
+// ListPetsAfter is ListPets restricted to identifiers greater than after.
+func (r *MemoryRepository) ListPetsAfter(ctx context.Context, after int64, limit int32, ownedBy string) ([]Pet, error) {
+	return r.listPets(ctx, &after, limit, ownedBy)
+}
+
+func (r *MemoryRepository) listPets(ctx context.Context, after *int64, limit int32, ownedBy string) ([]Pet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	org := tenant.FromContext(ctx)
+
+	r.mu.Lock()
+	pets := make([]Pet, 0)
+	for key, stored := range r.pets {
+		if key.org != org || (ownedBy != "" && stored.owner != ownedBy) || (after != nil && key.id <= *after) {
+			continue
+		}
+		pets = append(pets, copyPet(stored.pet))
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(pets, func(a, b Pet) int { return cmp.Compare(a.Id, b.Id) })
+	if limit > 0 && len(pets) > int(limit) {
+		pets = pets[:limit]
+	}
+	return pets, nil
+}
+
+// CountPets counts all pets, or only those created by ownedBy when it is set.
+func (r *MemoryRepository) CountPets(ctx context.Context, ownedBy string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	org := tenant.FromContext(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for key, stored := range r.pets {
+		if key.org == org && (ownedBy == "" || stored.owner == ownedBy) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// CreatePet stores a new pet, or returns ErrPetExists.
+func (r *MemoryRepository) CreatePet(ctx context.Context, pet Pet, owner string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := memoryKey{org: tenant.FromContext(ctx), id: pet.Id}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pets[key]; ok {
+		return ErrPetExists
+	}
+	r.pets[key] = memoryPet{pet: copyPet(pet), owner: owner}
+	return nil
+}
+
+// GetPet retrieves a pet by identifier.
+func (r *MemoryRepository) GetPet(ctx context.Context, id int64) (Pet, error) {
+	if err := ctx.Err(); err != nil {
+		return Pet{}, err
+	}
+	key := memoryKey{org: tenant.FromContext(ctx), id: id}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.pets[key]
+	if !ok {
+		return Pet{}, ErrPetNotFound
+	}
+	return copyPet(stored.pet), nil
+}
+
+// UpdatePet replaces an existing pet, keeping its owner.
+func (r *MemoryRepository) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := memoryKey{org: tenant.FromContext(ctx), id: pet.Id}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, err := r.owned(key, ownedBy)
+	if err != nil {
+		return err
+	}
+	stored.pet = copyPet(pet)
+	r.pets[key] = stored
+	return nil
+}
+
+// DeletePet removes a pet by identifier.
+func (r *MemoryRepository) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := memoryKey{org: tenant.FromContext(ctx), id: id}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.owned(key, ownedBy); err != nil {
+		return err
+	}
+	delete(r.pets, key)
+	return nil
+}
+
+// owned returns the pet under key when ownedBy may write it. r.mu must be held.
+func (r *MemoryRepository) owned(key memoryKey, ownedBy string) (memoryPet, error) {
+	stored, ok := r.pets[key]
+	switch {
+	case !ok:
+		return memoryPet{}, ErrPetNotFound
+	case ownedBy != "" && stored.owner != ownedBy:
+		return memoryPet{}, ErrNotPetOwner
+	}
+	return stored, nil
+}
+
+// PetOwners returns the owner of each listed pet that exists and has one.
+func (r *MemoryRepository) PetOwners(ctx context.Context, ids []int64) (map[int64]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	org := tenant.FromContext(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owners := make(map[int64]string, len(ids))
+	for _, id := range ids {
+		if stored, ok := r.pets[memoryKey{org: org, id: id}]; ok && stored.owner != "" {
+			owners[id] = stored.owner
+		}
+	}
+	return owners, nil
+}
+
+var (
+	_ PetRepository  = (*MemoryRepository)(nil)
+	_ Pager          = (*MemoryRepository)(nil)
+	_ PetOwnerLookup = (*MemoryRepository)(nil)
+)
@@ -0,0 +1,20 @@
+package petstore_test
+
+import (
+	"testing"
+
+	"demo/internal/petstore"
+	"demo/internal/petstore/petstoretest"
+)
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	petstoretest.RunRepositoryConformanceTests(t, func() petstore.PetRepository {
+		return petstore.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepositoryErrorCategories(t *testing.T) {
+	petstoretest.RunErrorCategoryConformanceTests(t, func() petstore.PetRepository {
+		return petstore.NewMemoryRepository()
+	})
+}
@@ -0,0 +1,72 @@
+package petstore
+
+import "demo/internal/i18n"
+
+// Message codes for writeError and ValidationError. Each is a key in the i18n catalogs
+// and is sent to clients as Error.error_code, so codes must never change meaning. The
+// apierr sentinels handlers send as they are, such as apierr.ErrPetNotFound, use their
+// category as the code and need no constant here.
+const (
+	msgAfterUnsupported         = "AFTER_UNSUPPORTED"
+	msgAuthRequiredForMine      = "AUTH_REQUIRED_FOR_MINE"
+	msgBodyTooLarge             = "BODY_TOO_LARGE"
+	msgChangeFeedUnavailable    = "CHANGE_FEED_UNAVAILABLE"
+	msgCreatePetFailed          = "CREATE_PET_FAILED"
+	msgCursorExpired            = "CURSOR_EXPIRED"
+	msgDatabaseUnavailable      = "DATABASE_UNAVAILABLE"
+	msgDeduplicationUnavailable = "DEDUPLICATION_UNAVAILABLE"
+	msgDeletePetFailed          = "DELETE_PET_FAILED"
+	msgDeletePhotoFailed        = "DELETE_PHOTO_FAILED"
+	msgExportNotReady           = "EXPORT_NOT_READY"
+	msgExportsDisabled          = "EXPORTS_DISABLED"
+	msgFetchExportFailed        = "FETCH_EXPORT_FAILED"
+	msgFetchPetFailed           = "FETCH_PET_FAILED"
+	msgFetchPhotoFailed         = "FETCH_PHOTO_FAILED"
+	msgInvalidCursor            = "INVALID_CURSOR"
+	msgInvalidExportFormat      = "INVALID_EXPORT_FORMAT"
+	msgInvalidJSON              = "INVALID_JSON"
+	msgInvalidPetID             = "INVALID_PET_ID"
+	msgLimitNegative            = "LIMIT_NEGATIVE"
+	msgLimitNotPositive         = "LIMIT_NOT_POSITIVE"
+	msgListChangesFailed        = "LIST_CHANGES_FAILED"
+	msgListDuplicatesFailed     = "LIST_DUPLICATES_FAILED"
+	msgListPetsFailed           = "LIST_PETS_FAILED"
+	msgMergeDuplicatesRequired  = "MERGE_DUPLICATES_REQUIRED"
+	msgMergeIntoSelf            = "MERGE_INTO_SELF"
+	msgMergePetsFailed          = "MERGE_PETS_FAILED"
+	msgMergeTooManyDuplicates   = "MERGE_TOO_MANY_DUPLICATES"
+	msgNotDuplicates            = "NOT_DUPLICATES"
+	msgPagingUnavailable        = "PAGING_UNAVAILABLE"
+	msgPetIDMismatch            = "PET_ID_MISMATCH"
+	msgPetIDNegative            = "PET_ID_NEGATIVE"
+	msgPetIDRequired            = "PET_ID_REQUIRED"
+	msgPetNameControlCharacters = "PET_NAME_CONTROL_CHARACTERS"
+	msgPetNameRequired          = "PET_NAME_REQUIRED"
+	msgPetNameTooLong           = "PET_NAME_TOO_LONG"
+	msgPetTagControlCharacters  = "PET_TAG_CONTROL_CHARACTERS"
+	msgPetTagTooLong            = "PET_TAG_TOO_LONG"
+	msgPhotoUnreadable          = "PHOTO_UNREADABLE"
+	msgPhotoUnsupportedType     = "PHOTO_UNSUPPORTED_TYPE"
+	msgPhotosDisabled           = "PHOTOS_DISABLED"
+	msgQueryTimeout             = "QUERY_TIMEOUT"
+	msgQueueExportFailed        = "QUEUE_EXPORT_FAILED"
+	msgSinceNegative            = "SINCE_NEGATIVE"
+	msgStorePhotoFailed         = "STORE_PHOTO_FAILED"
+	msgUnknownField             = "UNKNOWN_FIELD"
+	msgUpdatePetFailed          = "UPDATE_PET_FAILED"
+)
+
+// ValidationError is a rule a pet failed, as a message code and its arguments so each
+// API can render it in the caller's language. Error returns the English message.
+type ValidationError struct {
+	Code string
+	Args []any
+}
+
+func (e *ValidationError) Error() string {
+	return i18n.Message(i18n.Default, e.Code, e.Args...)
+}
+
+func invalidPet(code string, args ...any) error {
+	return &ValidationError{Code: code, Args: args}
+}
@@ -0,0 +1,35 @@
+package petstore
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize returns p with its name and tag in the form pets are stored in: NFC, so
+// visually identical names compare equal, without leading or trailing whitespace, and with
+// every internal run of whitespace (NBSP, tabs and newlines included) collapsed into one
+// space. Writes normalize before ValidatePet, which then rejects names left empty.
+func (p Pet) Normalize() Pet {
+	p.Name = normalizeText(p.Name)
+	if p.Tag != nil {
+		tag := normalizeText(*p.Tag)
+		p.Tag = &tag
+	}
+	return p
+}
+
+func normalizeText(s string) string {
+	s = norm.NFC.String(s)
+	if !strings.ContainsFunc(s, unicode.IsSpace) {
+		return s
+	}
+	return strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+}
+
+// hasControl reports whether s holds a control character, which Normalize leaves alone
+// unless it is whitespace.
+func hasControl(s string) bool {
+	return strings.ContainsFunc(s, unicode.IsControl)
+}
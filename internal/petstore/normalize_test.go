@@ -0,0 +1,60 @@
+package petstore_test
+
+import (
+	"testing"
+
+	"demo/internal/petstore"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		name, in, want string
+	}{
+		{"Unchanged", "Rex", "Rex"},
+		{"Trimmed", "  Rex\t", "Rex"},
+		{"NBSPInside", "Mr\u00a0Whiskers", "Mr Whiskers"},
+		{"NBSPAround", "\u00a0Rex\u00a0\u00a0", "Rex"},
+		{"NarrowNBSPAndIdeographicSpace", "Mr\u202fWhiskers\u3000Jr", "Mr Whiskers Jr"},
+		{"WhitespaceRuns", "Mr \u00a0\t\n Whiskers", "Mr Whiskers"},
+		// A combining acute accent composes with its base letter.
+		{"CombiningAccent", "Rene\u0301e", "Ren\u00e9e"},
+		{"Precomposed", "Ren\u00e9e", "Ren\u00e9e"},
+		{"CombiningCedillaAndNBSP", "Franc\u0327ois\u00a0II", "Fran\u00e7ois II"},
+		// Stacked marks compose to the same letter in either order.
+		{"StackedMarks", "Vie\u0323\u0302t", "Vi\u1ec7t"},
+		{"StackedMarksReordered", "Vie\u0302\u0323t", "Vi\u1ec7t"},
+		{"WhitespaceOnly", "\u00a0 \t", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := tc.in
+			got := petstore.Pet{Id: 1, Name: tc.in, Tag: &in}.Normalize()
+			if got.Name != tc.want || *got.Tag != tc.want {
+				t.Fatalf("Normalize(%+q): got name %+q and tag %+q, want %+q", tc.in, got.Name, *got.Tag, tc.want)
+			}
+		})
+	}
+
+	if got := (petstore.Pet{Id: 1, Name: "Rex"}).Normalize(); got.Tag != nil {
+		t.Fatalf("Normalize without a tag: got tag %q, want nil", *got.Tag)
+	}
+}
+
+func TestValidateNormalizedPet(t *testing.T) {
+	for _, tc := range []struct {
+		name, in string
+		ok       bool
+	}{
+		{"Composed", "Rene\u0301e", true},
+		{"WhitespaceOnly", "\u00a0\u00a0", false},
+		// Control characters other than whitespace survive Normalize and are rejected.
+		{"Control", "Rex\u0000", false},
+		{"Bell", "Re\u0007x", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := petstore.ValidatePet(petstore.Pet{Id: 1, Name: tc.in}.Normalize())
+			if (err == nil) != tc.ok {
+				t.Fatalf("ValidatePet(%+q): got %v, want ok %v", tc.in, err, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"demo/internal/apierr"
+	"demo/internal/features"
+	"demo/internal/tenant"
+)
+
+// Pager pages through pets by keyset for the PetPage envelope and cursor links.
+// PostgresRepository implements it.
+type Pager interface {
+	// ListPetsAfter is ListPets restricted to identifiers greater than after.
+	ListPetsAfter(ctx context.Context, after int64, limit int32, ownedBy string) ([]Pet, error)
+	// CountPets counts the pets ListPets would return without a limit.
+	CountPets(ctx context.Context, ownedBy string) (int64, error)
+}
+
+// WithPager serves PetPage envelopes and cursor pages from pager; without one, GET /pets
+// answers those requests with 404.
+func WithPager(pager Pager) ServerOption {
+	return func(s *Server) {
+		s.pager = pager
+	}
+}
+
+// WithCursorKey signs pagination cursors with key and accepts them for ttl. Every instance
+// must share the key. Without one ListPets neither issues nor accepts cursors: a limited
+// bare array comes without x-next, and envelopes and cursor parameters answer 404 as
+// without a Pager.
+func WithCursorKey(key []byte, ttl time.Duration) ServerOption {
+	return func(s *Server) {
+		s.cursorKey = key
+		s.cursorTTL = ttl
+	}
+}
+
+const (
+	// defaultPageSize is the PetPage size when the request sets no limit; unlike the bare
+	// array, an envelope never returns every pet at once.
+	defaultPageSize = 100
+	// defaultCursorTTL is how long cursors stay valid unless WithCursorKey says otherwise.
+	defaultCursorTTL = 24 * time.Hour
+)
+
+// wantsEnvelope reports whether a GET /pets request asked for a PetPage, with
+// envelope=true or an Accept entry of application/json;profile="envelope".
+func wantsEnvelope(r *http.Request, params ListPetsParams) bool {
+	if params.Envelope != nil {
+		return *params.Envelope
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, mediaParams, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == "application/json" && mediaParams["profile"] == "envelope" {
+			return true
+		}
+	}
+	return false
+}
+
+// pageStart resolves where a GET /pets page starts from the signed cursor or, with the
+// legacy_after_cursor flag, the numeric after parameter. It returns nil for the first
+// page and reports false after writing a 400.
+func (s *Server) pageStart(w http.ResponseWriter, r *http.Request, params ListPetsParams) (*int64, bool) {
+	switch {
+	case params.Cursor != nil && len(s.cursorKey) == 0:
+		s.writeError(w, r, apierr.ErrNotFound.With(msgPagingUnavailable))
+		return nil, false
+	case params.Cursor != nil:
+		c, err := DecodeCursor(*params.Cursor, s.cursorKey, time.Now())
+		var after int64
+		if err == nil {
+			after, err = c.afterID(tenant.FromContext(r.Context()))
+		}
+		switch {
+		case errors.Is(err, ErrCursorExpired):
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgCursorExpired))
+			return nil, false
+		case err != nil:
+			s.logger.InfoContext(r.Context(), "ListPets: rejected cursor", "error", err)
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidCursor))
+			return nil, false
+		}
+		return &after, true
+	case params.After != nil:
+		if !features.Enabled(r.Context(), features.LegacyAfterCursor) {
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgAfterUnsupported))
+			return nil, false
+		}
+		// Legacy x-next links named the first pet of the next page rather than the last
+		// pet of this one.
+		after := *params.After
+		if after > math.MinInt64 {
+			after--
+		}
+		return &after, true
+	}
+	return nil, true
+}
+
+// listPetPage answers GET /pets with a PetPage holding up to limit pets after the given
+// position, or from the start when after is nil.
+func (s *Server) listPetPage(w http.ResponseWriter, r *http.Request, after *int64, limit int32, ownedBy string) {
+	if s.pager == nil || len(s.cursorKey) == 0 {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgPagingUnavailable))
+		return
+	}
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	start := int64(math.MinInt64)
+	if after != nil {
+		start = *after
+	}
+
+	// One extra row tells whether another page follows.
+	pets, err := s.pager.ListPetsAfter(r.Context(), start, limit+1, ownedBy)
+	var total int64
+	if err == nil {
+		total, err = s.pager.CountPets(r.Context(), ownedBy)
+	}
+	if err != nil {
+		s.writeListError(w, r, err)
+		return
+	}
+
+	page := PetPage{Items: pets, Total: total}
+	if len(pets) > int(limit) {
+		page.Items = pets[:limit]
+		next := idCursor(tenant.FromContext(r.Context()), page.Items[limit-1].Id).Encode(s.cursorKey, s.cursorTTL)
+		page.NextCursor = &next
+	}
+	s.render(w, r, http.StatusOK, page)
+}
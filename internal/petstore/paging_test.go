@@ -0,0 +1,263 @@
+package petstore_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"demo/internal/features"
+	"demo/internal/petstore"
+	"demo/internal/tenant"
+)
+
+var cursorKey = []byte("test-cursor-key-0123456789abcdef")
+
+// newPagingHandler serves five pets, ids 1 to 5, with signed cursors. X-Org picks the
+// caller's organization, which holds no pets unless it is the default.
+func newPagingHandler(t *testing.T) http.Handler {
+	t.Helper()
+	pets := petstore.NewMemoryRepository()
+	for id := int64(1); id <= 5; id++ {
+		if err := pets.CreatePet(t.Context(), petstore.Pet{Id: id, Name: "Pet " + strconv.FormatInt(id, 10)}, ""); err != nil {
+			t.Fatalf("CreatePet(%d): %v", id, err)
+		}
+	}
+	server := petstore.NewServer(pets, slog.New(slog.DiscardHandler),
+		petstore.WithPager(pets),
+		petstore.WithCursorKey(cursorKey, time.Hour),
+	)
+	return petstore.HandlerWithOptions(server, petstore.ChiServerOptions{
+		Middlewares:      []petstore.MiddlewareFunc{orgHeader},
+		ErrorHandlerFunc: petstore.ParamErrorHandler,
+	})
+}
+
+func orgHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if org := r.Header.Get("X-Org"); org != "" {
+			r = r.WithContext(tenant.WithOrg(r.Context(), org))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func get(t *testing.T, handler http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodePage(t *testing.T, rec *httptest.ResponseRecorder) petstore.PetPage {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /pets: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	var page petstore.PetPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decoding page %s: %v", rec.Body, err)
+	}
+	return page
+}
+
+func ids(pets []petstore.Pet) []int64 {
+	out := make([]int64, len(pets))
+	for i, pet := range pets {
+		out[i] = pet.Id
+	}
+	return out
+}
+
+func TestListPetsBareArray(t *testing.T) {
+	handler := newPagingHandler(t)
+	var got []int64
+	target := "/pets?limit=2"
+	for range 10 {
+		rec := get(t, handler, target)
+		var pets []petstore.Pet
+		if err := json.Unmarshal(rec.Body.Bytes(), &pets); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("GET %s: got %d %s, want a bare array", target, rec.Code, rec.Body)
+		}
+		got = append(got, ids(pets)...)
+		if target = rec.Header().Get("x-next"); target == "" {
+			break
+		}
+	}
+	if want := []int64{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Fatalf("following x-next: got %v, want %v", got, want)
+	}
+
+	// Without a limit the bare array holds every pet and no link.
+	rec := get(t, handler, "/pets")
+	if next := rec.Header().Get("x-next"); next != "" || !strings.HasPrefix(rec.Body.String(), "[") {
+		t.Fatalf("GET /pets: got x-next %q and %s, want every pet as an array", next, rec.Body)
+	}
+}
+
+func TestListPetsEnvelope(t *testing.T) {
+	handler := newPagingHandler(t)
+	for _, tc := range []struct {
+		name, query string
+		header      []string
+	}{
+		{"Query", "envelope=true&", nil},
+		{"AcceptProfile", "", []string{"Accept", `application/json;profile="envelope"`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int64
+			target := "/pets?" + tc.query + "limit=2"
+			for pages := 1; ; pages++ {
+				page := decodePage(t, get(t, handler, target, tc.header...))
+				if page.Total != 5 {
+					t.Fatalf("page %d: got total %d, want 5", pages, page.Total)
+				}
+				got = append(got, ids(page.Items)...)
+				if page.NextCursor == nil {
+					break
+				}
+				if pages == 10 {
+					t.Fatal("envelope pages never end")
+				}
+				target = "/pets?" + tc.query + "limit=2&cursor=" + url.QueryEscape(*page.NextCursor)
+			}
+			if want := []int64{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+				t.Fatalf("following next_cursor: got %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("DefaultPageSize", func(t *testing.T) {
+		page := decodePage(t, get(t, handler, "/pets?envelope=true"))
+		if len(page.Items) != 5 || page.NextCursor != nil {
+			t.Fatalf("envelope without a limit: got %v and cursor %v, want all five pets on one page", ids(page.Items), page.NextCursor)
+		}
+	})
+
+	t.Run("EnvelopeFalse", func(t *testing.T) {
+		rec := get(t, handler, "/pets?envelope=false&limit=2", "Accept", `application/json;profile="envelope"`)
+		if !strings.HasPrefix(rec.Body.String(), "[") || rec.Header().Get("x-next") == "" {
+			t.Fatalf("envelope=false: got %s, want the bare array with x-next", rec.Body)
+		}
+	})
+}
+
+// TestListPetsCursorRoundTrip checks that a cursor from one shape resumes the other and
+// that it carries the keyset position rather than a raw id.
+func TestListPetsCursorRoundTrip(t *testing.T) {
+	handler := newPagingHandler(t)
+
+	page := decodePage(t, get(t, handler, "/pets?envelope=true&limit=2"))
+	if page.NextCursor == nil {
+		t.Fatal("first page: no next_cursor")
+	}
+	cursor := *page.NextCursor
+	if _, err := strconv.ParseInt(cursor, 10, 64); err == nil {
+		t.Fatalf("next_cursor %q is a raw id", cursor)
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(cursor); err != nil {
+		t.Fatalf("next_cursor %q is not base64url: %v", cursor, err)
+	}
+	decoded, err := petstore.DecodeCursor(cursor, cursorKey, time.Now())
+	if err != nil || decoded.Sort != "id" || !slices.Equal(decoded.Keys, []string{"2"}) {
+		t.Fatalf("DecodeCursor(next_cursor): got %+v, %v; want after id 2", decoded, err)
+	}
+
+	rec := get(t, handler, "/pets?limit=2&cursor="+url.QueryEscape(cursor))
+	var pets []petstore.Pet
+	if err := json.Unmarshal(rec.Body.Bytes(), &pets); err != nil || !slices.Equal(ids(pets), []int64{3, 4}) {
+		t.Fatalf("bare array from an envelope cursor: got %s, want pets 3 and 4", rec.Body)
+	}
+	next, err := url.Parse(rec.Header().Get("x-next"))
+	if err != nil {
+		t.Fatalf("x-next: %v", err)
+	}
+	page = decodePage(t, get(t, handler, "/pets?envelope=true&limit=2&cursor="+url.QueryEscape(next.Query().Get("cursor"))))
+	if !slices.Equal(ids(page.Items), []int64{5}) || page.NextCursor != nil {
+		t.Fatalf("envelope from an x-next cursor: got %v and cursor %v, want pet 5 and no cursor", ids(page.Items), page.NextCursor)
+	}
+}
+
+func TestListPetsRejectsCursors(t *testing.T) {
+	handler := newPagingHandler(t)
+	valid := decodePage(t, get(t, handler, "/pets?envelope=true&limit=2")).NextCursor
+	expired := petstore.Cursor{Sort: "id", Keys: []string{"2"}, Expires: time.Now().Add(-time.Minute).Unix()}.Encode(cursorKey, 0)
+	otherKey := petstore.Cursor{Sort: "id", Keys: []string{"2"}}.Encode([]byte("another-key-0123456789abcdefghij"), time.Hour)
+	descending := petstore.Cursor{Sort: "id", Desc: true, Keys: []string{"2"}}.Encode(cursorKey, time.Hour)
+
+	for _, tc := range []struct {
+		name, cursor, org, code string
+	}{
+		{"Tampered", *valid + "x", "", "INVALID_CURSOR"},
+		{"NotBase64", "!!!", "", "INVALID_CURSOR"},
+		{"OtherKey", otherKey, "", "INVALID_CURSOR"},
+		{"OtherOrdering", descending, "", "INVALID_CURSOR"},
+		{"OtherOrg", *valid, "acme", "INVALID_CURSOR"},
+		{"Expired", expired, "", "CURSOR_EXPIRED"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := get(t, handler, "/pets?envelope=true&cursor="+url.QueryEscape(tc.cursor), "X-Org", tc.org)
+			var apiErr petstore.Error
+			if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); rec.Code != http.StatusBadRequest || err != nil ||
+				apiErr.ErrorCode == nil || *apiErr.ErrorCode != tc.code {
+				t.Fatalf("GET /pets with a %s cursor: got %d %s, want 400 %s", tc.name, rec.Code, rec.Body, tc.code)
+			}
+		})
+	}
+}
+
+// TestListPetsWithoutCursorKey checks that a server with no key to sign cursors with
+// neither issues nor accepts them.
+func TestListPetsWithoutCursorKey(t *testing.T) {
+	pets := petstore.NewMemoryRepository()
+	for id := int64(1); id <= 3; id++ {
+		if err := pets.CreatePet(t.Context(), petstore.Pet{Id: id, Name: "Pet " + strconv.FormatInt(id, 10)}, ""); err != nil {
+			t.Fatalf("CreatePet(%d): %v", id, err)
+		}
+	}
+	handler := petstore.Handler(petstore.NewServer(pets, slog.New(slog.DiscardHandler), petstore.WithPager(pets)))
+
+	rec := get(t, handler, "/pets?limit=2")
+	var got []petstore.Pet
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != 2 || rec.Header().Get("x-next") != "" {
+		t.Fatalf("GET /pets?limit=2: got x-next %q and %s, want two pets and no link", rec.Header().Get("x-next"), rec.Body)
+	}
+	cursor := petstore.Cursor{Sort: "id", Keys: []string{"2"}}.Encode(nil, time.Hour)
+	for _, target := range []string{"/pets?envelope=true", "/pets?cursor=" + url.QueryEscape(cursor)} {
+		if rec := get(t, handler, target); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: got %d %s, want 404", target, rec.Code, rec.Body)
+		}
+	}
+}
+
+// TestListPetsLegacyAfter checks that ?after=<id> from pre-cursor x-next links, which
+// named the first pet of the next page, is only honoured behind legacy_after_cursor.
+func TestListPetsLegacyAfter(t *testing.T) {
+	flags := features.New(nil, slog.New(slog.DiscardHandler))
+	handler := flags.Middleware(newPagingHandler(t))
+
+	rec := get(t, handler, "/pets?limit=2&after=3")
+	var apiErr petstore.Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); rec.Code != http.StatusBadRequest || err != nil ||
+		apiErr.ErrorCode == nil || *apiErr.ErrorCode != "AFTER_UNSUPPORTED" {
+		t.Fatalf("GET /pets?after=3 with the flag off: got %d %s, want 400 AFTER_UNSUPPORTED", rec.Code, rec.Body)
+	}
+
+	flags.Set(map[string]bool{features.LegacyAfterCursor: true})
+	rec = get(t, handler, "/pets?limit=2&after=3")
+	var pets []petstore.Pet
+	if err := json.Unmarshal(rec.Body.Bytes(), &pets); err != nil || !slices.Equal(ids(pets), []int64{3, 4}) {
+		t.Fatalf("GET /pets?after=3 with the flag on: got %s, want pets 3 and 4", rec.Body)
+	}
+}
@@ -12,16 +12,130 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/go-chi/chi/v5"
 	"github.com/oapi-codegen/runtime"
 )
 
+// Defines values for ErrorCategory.
+const (
+	BODYTOOLARGE         ErrorCategory = "BODY_TOO_LARGE"
+	CONFLICT             ErrorCategory = "CONFLICT"
+	EXPORTNOTFOUND       ErrorCategory = "EXPORT_NOT_FOUND"
+	FORBIDDEN            ErrorCategory = "FORBIDDEN"
+	INTERNAL             ErrorCategory = "INTERNAL"
+	INVALIDREQUEST       ErrorCategory = "INVALID_REQUEST"
+	METHODNOTALLOWED     ErrorCategory = "METHOD_NOT_ALLOWED"
+	NOTFOUND             ErrorCategory = "NOT_FOUND"
+	NOTPETOWNER          ErrorCategory = "NOT_PET_OWNER"
+	PETEXISTS            ErrorCategory = "PET_EXISTS"
+	PETNOTFOUND          ErrorCategory = "PET_NOT_FOUND"
+	PHOTONOTFOUND        ErrorCategory = "PHOTO_NOT_FOUND"
+	RATELIMITED          ErrorCategory = "RATE_LIMITED"
+	TIMEOUT              ErrorCategory = "TIMEOUT"
+	UNAUTHENTICATED      ErrorCategory = "UNAUTHENTICATED"
+	UNAVAILABLE          ErrorCategory = "UNAVAILABLE"
+	UNSUPPORTEDMEDIATYPE ErrorCategory = "UNSUPPORTED_MEDIA_TYPE"
+	VALIDATIONFAILED     ErrorCategory = "VALIDATION_FAILED"
+)
+
+// Defines values for ExportFormat.
+const (
+	Csv    ExportFormat = "csv"
+	Ndjson ExportFormat = "ndjson"
+)
+
+// Defines values for ExportJobStatus.
+const (
+	Failed    ExportJobStatus = "failed"
+	Queued    ExportJobStatus = "queued"
+	Running   ExportJobStatus = "running"
+	Succeeded ExportJobStatus = "succeeded"
+)
+
+// Defines values for PetChangeOp.
+const (
+	Create PetChangeOp = "create"
+	Delete PetChangeOp = "delete"
+	Update PetChangeOp = "update"
+)
+
+// DuplicateGroup defines model for DuplicateGroup.
+type DuplicateGroup struct {
+	// Name The shared name, trimmed and case-folded
+	Name string `json:"name"`
+
+	// PetIds The pets in the group, in id order
+	PetIds []int64 `json:"pet_ids"`
+
+	// Tag The shared tag; absent when the pets have none
+	Tag *string `json:"tag,omitempty"`
+}
+
 // Error defines model for Error.
 type Error struct {
-	Code    int32  `json:"code"`
-	Message string `json:"message"`
+	// Category What went wrong, from a fixed list clients can branch on; error_code narrows it down
+	Category *ErrorCategory `json:"category,omitempty"`
+	Code     int32          `json:"code"`
+
+	// ErrorCode Stable machine-readable code for the error, e.g. PET_NOT_FOUND; message is rendered from it in the language Accept-Language prefers
+	ErrorCode *string `json:"error_code,omitempty"`
+	Message   string  `json:"message"`
+
+	// RequestId Identifier of the request that produced the error
+	RequestId *string `json:"request_id,omitempty"`
+}
+
+// ErrorCategory What went wrong, from a fixed list clients can branch on; error_code narrows it down
+type ErrorCategory string
+
+// ExportFormat csv has a header row of id,name,tag; ndjson has one Pet object per line
+type ExportFormat string
+
+// ExportJob defines model for ExportJob.
+type ExportJob struct {
+	CreatedAt time.Time `json:"created_at"`
+
+	// DownloadUrl Where to fetch the export once it succeeded
+	DownloadUrl *string `json:"download_url,omitempty"`
+
+	// Error Why the export failed
+	Error      *string    `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Format csv has a header row of id,name,tag; ndjson has one Pet object per line
+	Format ExportFormat `json:"format"`
+	Id     string       `json:"id"`
+
+	// RowsWritten Pets written so far
+	RowsWritten int64           `json:"rows_written"`
+	Status      ExportJobStatus `json:"status"`
+
+	// TotalRows Pets to export, known once the export is running
+	TotalRows *int64 `json:"total_rows,omitempty"`
+}
+
+// ExportJobStatus defines model for ExportJob.Status.
+type ExportJobStatus string
+
+// ExportRequest defines model for ExportRequest.
+type ExportRequest struct {
+	// Format csv has a header row of id,name,tag; ndjson has one Pet object per line
+	Format *ExportFormat `json:"format,omitempty"`
+
+	// Mine Only export pets created by the authenticated caller
+	Mine *bool `json:"mine,omitempty"`
+}
+
+// MergeRequest defines model for MergeRequest.
+type MergeRequest struct {
+	// DuplicateIds The pets merged into the survivor and soft-deleted
+	DuplicateIds []int64 `json:"duplicate_ids"`
+
+	// SurvivorId The pet that remains
+	SurvivorId int64 `json:"survivor_id"`
 }
 
 // Pet defines model for Pet.
@@ -31,6 +145,39 @@ type Pet struct {
 	Tag  *string `json:"tag,omitempty"`
 }
 
+// PetChange A write to a pet; deletes are tombstones without a payload
+type PetChange struct {
+	ChangedAt time.Time   `json:"changed_at"`
+	Op        PetChangeOp `json:"op"`
+	Payload   *Pet        `json:"payload,omitempty"`
+	PetId     int64       `json:"pet_id"`
+
+	// Seq Position in the feed; strictly increasing in commit order
+	Seq int64 `json:"seq"`
+}
+
+// PetChangeOp defines model for PetChange.Op.
+type PetChangeOp string
+
+// PetChanges defines model for PetChanges.
+type PetChanges struct {
+	Changes []PetChange `json:"changes"`
+
+	// NextSince The since value for the next poll: the last change's seq, or the request's since when there are none
+	NextSince int64 `json:"next_since"`
+}
+
+// PetPage A page of pets with an opaque cursor for the next one
+type PetPage struct {
+	Items Pets `json:"items"`
+
+	// NextCursor Pass as cursor to fetch the next page; absent on the last page
+	NextCursor *string `json:"next_cursor,omitempty"`
+
+	// Total How many pets match the request across all pages
+	Total int64 `json:"total"`
+}
+
 // Pets defines model for Pets.
 type Pets = []Pet
 
@@ -38,11 +185,47 @@ type Pets = []Pet
 type ListPetsParams struct {
 	// Limit How many items to return at one time (max 100)
 	Limit *int32 `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Mine Only return pets created by the authenticated caller
+	Mine *bool `form:"mine,omitempty" json:"mine,omitempty"`
+
+	// Cursor Opaque, signed cursor from x-next or a PetPage's next_cursor; returns the pets after that position
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// After Pet id from x-next links issued before cursors; only accepted while the legacy_after_cursor feature flag is on
+	After *int64 `form:"after,omitempty" json:"after,omitempty"`
+
+	// Envelope Answer with a PetPage instead of a bare array; Accept: application/json;profile="envelope" does the same. A PetPage holds 100 pets unless limit says otherwise
+	Envelope *bool `form:"envelope,omitempty" json:"envelope,omitempty"`
+}
+
+// ListPetChangesParams defines parameters for ListPetChanges.
+type ListPetChangesParams struct {
+	// Since Return changes after this sequence number; 0 or absent replays from the start
+	Since *int64 `form:"since,omitempty" json:"since,omitempty"`
+
+	// Limit How many changes to return at one time (default 100, max 1000)
+	Limit *int32 `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ListPetDuplicatesParams defines parameters for ListPetDuplicates.
+type ListPetDuplicatesParams struct {
+	// Limit How many groups to return at one time (default 100, max 100)
+	Limit *int32 `form:"limit,omitempty" json:"limit,omitempty"`
 }
 
 // CreatePetsJSONRequestBody defines body for CreatePets for application/json ContentType.
 type CreatePetsJSONRequestBody = Pet
 
+// CreatePetExportJSONRequestBody defines body for CreatePetExport for application/json ContentType.
+type CreatePetExportJSONRequestBody = ExportRequest
+
+// MergePetsJSONRequestBody defines body for MergePets for application/json ContentType.
+type MergePetsJSONRequestBody = MergeRequest
+
+// UpdatePetJSONRequestBody defines body for UpdatePet for application/json ContentType.
+type UpdatePetJSONRequestBody = Pet
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 	// List all pets
@@ -51,9 +234,42 @@ type ServerInterface interface {
 	// Create a pet
 	// (POST /pets)
 	CreatePets(w http.ResponseWriter, r *http.Request)
+	// Changes to pets in commit order
+	// (GET /pets/changes)
+	ListPetChanges(w http.ResponseWriter, r *http.Request, params ListPetChangesParams)
+	// Groups of pets that look like duplicates
+	// (GET /pets/duplicates)
+	ListPetDuplicates(w http.ResponseWriter, r *http.Request, params ListPetDuplicatesParams)
+	// Start an asynchronous export of pets
+	// (POST /pets/exports)
+	CreatePetExport(w http.ResponseWriter, r *http.Request)
+	// Status of a pet export
+	// (GET /pets/exports/{exportId})
+	ShowPetExport(w http.ResponseWriter, r *http.Request, exportId string)
+	// Download a finished pet export
+	// (GET /pets/exports/{exportId}/download)
+	DownloadPetExport(w http.ResponseWriter, r *http.Request, exportId string)
+	// Merge duplicate pets into one
+	// (POST /pets/merge)
+	MergePets(w http.ResponseWriter, r *http.Request)
+	// Delete a pet
+	// (DELETE /pets/{petId})
+	DeletePet(w http.ResponseWriter, r *http.Request, petId string)
 	// Info for a specific pet
 	// (GET /pets/{petId})
 	ShowPetById(w http.ResponseWriter, r *http.Request, petId string)
+	// Update a pet
+	// (PUT /pets/{petId})
+	UpdatePet(w http.ResponseWriter, r *http.Request, petId string)
+	// Delete a pet's photo
+	// (DELETE /pets/{petId}/photo)
+	DeletePetPhoto(w http.ResponseWriter, r *http.Request, petId string)
+	// Download a pet's photo
+	// (GET /pets/{petId}/photo)
+	ShowPetPhoto(w http.ResponseWriter, r *http.Request, petId string)
+	// Upload or replace a pet's photo
+	// (PUT /pets/{petId}/photo)
+	UploadPetPhoto(w http.ResponseWriter, r *http.Request, petId string)
 }
 
 // Unimplemented server implementation that returns http.StatusNotImplemented for each endpoint.
@@ -72,12 +288,78 @@ func (_ Unimplemented) CreatePets(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Changes to pets in commit order
+// (GET /pets/changes)
+func (_ Unimplemented) ListPetChanges(w http.ResponseWriter, r *http.Request, params ListPetChangesParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Groups of pets that look like duplicates
+// (GET /pets/duplicates)
+func (_ Unimplemented) ListPetDuplicates(w http.ResponseWriter, r *http.Request, params ListPetDuplicatesParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Start an asynchronous export of pets
+// (POST /pets/exports)
+func (_ Unimplemented) CreatePetExport(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Status of a pet export
+// (GET /pets/exports/{exportId})
+func (_ Unimplemented) ShowPetExport(w http.ResponseWriter, r *http.Request, exportId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Download a finished pet export
+// (GET /pets/exports/{exportId}/download)
+func (_ Unimplemented) DownloadPetExport(w http.ResponseWriter, r *http.Request, exportId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Merge duplicate pets into one
+// (POST /pets/merge)
+func (_ Unimplemented) MergePets(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Delete a pet
+// (DELETE /pets/{petId})
+func (_ Unimplemented) DeletePet(w http.ResponseWriter, r *http.Request, petId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // Info for a specific pet
 // (GET /pets/{petId})
 func (_ Unimplemented) ShowPetById(w http.ResponseWriter, r *http.Request, petId string) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Update a pet
+// (PUT /pets/{petId})
+func (_ Unimplemented) UpdatePet(w http.ResponseWriter, r *http.Request, petId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Delete a pet's photo
+// (DELETE /pets/{petId}/photo)
+func (_ Unimplemented) DeletePetPhoto(w http.ResponseWriter, r *http.Request, petId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Download a pet's photo
+// (GET /pets/{petId}/photo)
+func (_ Unimplemented) ShowPetPhoto(w http.ResponseWriter, r *http.Request, petId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Upload or replace a pet's photo
+// (PUT /pets/{petId}/photo)
+func (_ Unimplemented) UploadPetPhoto(w http.ResponseWriter, r *http.Request, petId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // ServerInterfaceWrapper converts contexts to parameters.
 type ServerInterfaceWrapper struct {
 	Handler            ServerInterface
@@ -103,6 +385,38 @@ func (siw *ServerInterfaceWrapper) ListPets(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// ------------- Optional query parameter "mine" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "mine", r.URL.Query(), &params.Mine)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "mine", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "after" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "after", r.URL.Query(), &params.After)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "after", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "envelope" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "envelope", r.URL.Query(), &params.Envelope)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "envelope", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		siw.Handler.ListPets(w, r, params)
 	}))
@@ -128,6 +442,171 @@ func (siw *ServerInterfaceWrapper) CreatePets(w http.ResponseWriter, r *http.Req
 	handler.ServeHTTP(w, r)
 }
 
+// ListPetChanges operation middleware
+func (siw *ServerInterfaceWrapper) ListPetChanges(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListPetChangesParams
+
+	// ------------- Optional query parameter "since" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "since", r.URL.Query(), &params.Since)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "since", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListPetChanges(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListPetDuplicates operation middleware
+func (siw *ServerInterfaceWrapper) ListPetDuplicates(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListPetDuplicatesParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListPetDuplicates(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreatePetExport operation middleware
+func (siw *ServerInterfaceWrapper) CreatePetExport(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreatePetExport(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ShowPetExport operation middleware
+func (siw *ServerInterfaceWrapper) ShowPetExport(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "exportId" -------------
+	var exportId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "exportId", chi.URLParam(r, "exportId"), &exportId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "exportId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ShowPetExport(w, r, exportId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DownloadPetExport operation middleware
+func (siw *ServerInterfaceWrapper) DownloadPetExport(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "exportId" -------------
+	var exportId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "exportId", chi.URLParam(r, "exportId"), &exportId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "exportId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DownloadPetExport(w, r, exportId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// MergePets operation middleware
+func (siw *ServerInterfaceWrapper) MergePets(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.MergePets(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeletePet operation middleware
+func (siw *ServerInterfaceWrapper) DeletePet(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "petId" -------------
+	var petId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "petId", chi.URLParam(r, "petId"), &petId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "petId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeletePet(w, r, petId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // ShowPetById operation middleware
 func (siw *ServerInterfaceWrapper) ShowPetById(w http.ResponseWriter, r *http.Request) {
 
@@ -153,6 +632,106 @@ func (siw *ServerInterfaceWrapper) ShowPetById(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r)
 }
 
+// UpdatePet operation middleware
+func (siw *ServerInterfaceWrapper) UpdatePet(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "petId" -------------
+	var petId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "petId", chi.URLParam(r, "petId"), &petId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "petId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.UpdatePet(w, r, petId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeletePetPhoto operation middleware
+func (siw *ServerInterfaceWrapper) DeletePetPhoto(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "petId" -------------
+	var petId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "petId", chi.URLParam(r, "petId"), &petId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "petId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeletePetPhoto(w, r, petId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ShowPetPhoto operation middleware
+func (siw *ServerInterfaceWrapper) ShowPetPhoto(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "petId" -------------
+	var petId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "petId", chi.URLParam(r, "petId"), &petId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "petId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ShowPetPhoto(w, r, petId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// UploadPetPhoto operation middleware
+func (siw *ServerInterfaceWrapper) UploadPetPhoto(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "petId" -------------
+	var petId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "petId", chi.URLParam(r, "petId"), &petId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "petId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.UploadPetPhoto(w, r, petId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -272,9 +851,42 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 	r.Group(func(r chi.Router) {
 		r.Post(options.BaseURL+"/pets", wrapper.CreatePets)
 	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/pets/changes", wrapper.ListPetChanges)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/pets/duplicates", wrapper.ListPetDuplicates)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/pets/exports", wrapper.CreatePetExport)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/pets/exports/{exportId}", wrapper.ShowPetExport)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/pets/exports/{exportId}/download", wrapper.DownloadPetExport)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/pets/merge", wrapper.MergePets)
+	})
+	r.Group(func(r chi.Router) {
+		r.Delete(options.BaseURL+"/pets/{petId}", wrapper.DeletePet)
+	})
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/pets/{petId}", wrapper.ShowPetById)
 	})
+	r.Group(func(r chi.Router) {
+		r.Put(options.BaseURL+"/pets/{petId}", wrapper.UpdatePet)
+	})
+	r.Group(func(r chi.Router) {
+		r.Delete(options.BaseURL+"/pets/{petId}/photo", wrapper.DeletePetPhoto)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/pets/{petId}/photo", wrapper.ShowPetPhoto)
+	})
+	r.Group(func(r chi.Router) {
+		r.Put(options.BaseURL+"/pets/{petId}/photo", wrapper.UploadPetPhoto)
+	})
 
 	return r
 }
@@ -282,18 +894,60 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/8xVTW/bMAz9KwK3wwZ4sdMOO/i2DgUWYBsKtLciB82mHXXWRyU6TRD4vw+UnKRN0vXS",
-	"AT1FUijyvcdHawOV1c4aNBSg3ECoFqhlXF56bz0vnLcOPSmMx5WtkX8b67UkKEEZOj+DDGjtMG2xRQ9D",
-	"BhpDkG2MHv8M5JVpYRgy8HjfK481lLcp5z5+vktmf99hRZzrCukYi6oPkXz5fBKJkfoUjAxIti/DUzWM",
-	"KZ5BlsAQ6rh477GBEt7le2nzUdecabAycjVL4dOi2OWU3ss1DFxemcZyrk5VaELEnjjAz9lNBK6o4+31",
-	"g2xb9IJRkPUs4xJ9UNZACdNJMSk42jo00iko4TweZeAkLSLa3I3426Qw6ytJWTOroYQfKlAkyDe81Ejo",
-	"A5S3G6gxVF45SpW+2wehpVmLqIIgKzxS742QJKxBQUqj+KDlSkyL4iMwQSjhvke/3mpbQqe0IshGE540",
-	"mZYrpXv9VLddo4c5Ny44a0IyyFlRJM8aQhPpSec6VUWC+V1g7JtH9V5oXUjNeUr9q3CyxVrE7gnbCJf0",
-	"WqCso1gbWH0yuIrlD692yvxhtWiBgmNiLk6yp/FYj0OfJjSN7Dt6NZ5p7k8Q7Q2uHFaEtcAxJoPQay39",
-	"erSKkF235U+yZadA3M6HDJwNJxz2zaMkHD3GU4eBLmy9fs2+JTb7kSbf43Bklelxg371XbdrBbwhsZNq",
-	"QrLYx1oPWRrrfOOQZvXw7HhfL+zDFdLFela/NOE3CxSqZmuyVx3SOORe4RK3A81flf08x+JwKPy//Pyf",
-	"5/eU0JdbmbeFmZcUS9kpPot+fEudn5nGisZ6IUVwWKlGVc+YgK+hX27b2fsOSlgQuTKP7uDnYhLS+zFR",
-	"Nl9OYZgPfwMAAP//Nzyf0hIIAAA=",
+	"H4sIAAAAAAAC/+xb/2/bOLL/Vwi+B+Q9QPm27R1wDu4Ht3FbL9LYlzjbXewWBiONbW4lUiWpOL4i//th",
+	"hpQsW7Jjd9Pd29z9FNsSyeHMZ2Y+M2S+8FhnuVagnOWdL9zGM8gEfTwv8lTGwsFbo4scf8mNzsE4CfRc",
+	"iQzwbwI2NjJ3Uive4aMZMDsTBhKGL0TMGZllkDChEhYLC4cTnSaQ8Ii7RQ68w60zUk35Q8RzcGOZ2PZJ",
+	"c3CWScXcDNgUJYrwm0yYNgkYHnHpIKOxE20y4XiHS+X++nK5kFQOpmD4Q/WLMEYs6LuYbt2KE9MzJm4t",
+	"KMfmM/BSkEQzcQdMaQXNDT1E3MDnQhpIeOdnr6/lJj9W7+vbXyF2KEbPGG2amkYjTLVZNEX8MBOOzUkq",
+	"o9U0YhOjMybYRN5DwlJpHYtTicZlsVDs1ggVz5hWZwxwqXGsE2BKGKPnlknHEj1XPOKgigxFfjU4/2k8",
+	"GgzGF92rtz0e8deDyzcX/dcjHvHej8PB1Wh8ORiN3wxuLs95xN8Mrl71z897lzzi/ctR7+qye0Eff+he",
+	"9M/HV71/3PSucez73ujd4JzGdi8uBh96OLo+E34e9kbjwYfL3hWPOH7u/di/Hl2HL/WXh+8Go8HKL1fd",
+	"UW980X/fH9HMo/773uAGF7657N6M3vUuR/3XXf/s5rL7Q7d/0X110aNv1zdD3FfvfPy+d97vjkc/DfEB",
+	"7aA76g8ux2+6/YveOf/YsHfEUZ3rCHzxXSsCl/pvWvXaidsUWCbimVRwaEAk9ANZa6INoY8miBgcTY/Y",
+	"ikbOWAbWiikwaZkBlQAimIAhXelBqVDTAt/pxjHk7vCi/J4bmICxbe4ZpkV5G88Q6GAR2c3t9BNQTk4k",
+	"GKYntHp4mzmEb250UsSQLHf1qCuR2pYCNVwp4vdZuoxRXtk0S+8+18a9CfZZlzS2d2wmLBNsBiIBw4ye",
+	"o8wyiSiYURhQya9WK3pPK2BDcMwvy3IwLJUKah4U2zsecT+kFTJeoO/1bYvbGxAOkrFwK5hKhINDJzNo",
+	"sxE6cKpFMi5M2hYuwABzmk3AxTOvcFqfaRUDwsMWcQywIT5DGZ7WZ13Up5oImbaPn0gl7WzPHU0qW/2v",
+	"gQnv8P85Xqas45Cvjlfs+hBxj8MmTPXcjudGOgequZEhOMvCU2Y1mwjDo13SiXXCFWSz0u6fCyhICaZQ",
+	"ChePeF21QUdtgHDaiXSMgm4Q0Omg6Yh9UnquvO1qBkC3r1Z9VPo115IJr/ZTjV5TXFSHZmseI0GuvJc3",
+	"gf11Js3QsxoqGah0UW6cEnIQjd16UIrCzTD8xPRjLNIUavHlVusUhCItNHbxHswUNm4iKenRI5Qlw1kS",
+	"JpXTJJAtzJ2804YIkdUTd5hACg6SPSlMJu77/vXTkxPSTvm1SW/KNVvDc5DUB2MDmZDKfgVw6mtEa9pp",
+	"w8gQWpTq5dth9yX5bLqPmLb83gZymuLR1JGDo+FDcK9nQk1bINilmEFxVaAmz5i3qGWCom12a51WYNlc",
+	"upkuHL4lFhilebQe8mmJ/QKkzuuBx8OfR7zIE//BC9Maa0oxHvFENFVFz3c0kIXPLfFLW4kfSxYyAUjO",
+	"GMoTu3TBpELprVRTfCHWWSZdRe/3xiN85qScSvCort4NkPQ2ti3JePmg8tJHdObnais3FNy7sZUq3lRA",
+	"4SN2J9JiyfdwDMt1mnYCgUNuTyscWGbhc8TCi4FcHdgwTVmvGCA8hlplX3WW+18RfoMSh6LdTXIkmHri",
+	"4yI6AxOK6Vx8LoDFhbHarO7WS7oWIXZUvq307GduAaOwlglbrrzCiryyxRSqqk+rpdrxAd+UupsLvdNz",
+	"lgm1CPlAlIuULFjERqMoaUozf03w9VopJdglqI1pEyGy7aXZluSzgu+WxVC0uRF5jtI6U8DDA64s1UTj",
+	"i6mMQVmojXnfH5FCpUvx6/VcTKdgkGtbpw3wiN+BsV69p0cnRyc+EIISueQd/oJ+wvjmZrSl4zxscuqz",
+	"DiJKoHn6Ce/wC2nd0EuZCyMycGAs7/y80ZCkKkSMAVcYxQRhlWGEZv+XiXt2enLy/xw3yDtIBc2izDcd",
+	"nspMokW8RlurxUzcy6zIVpVbM30rBwqi7MGB2qTLfPmyFK5JlBqrkwNHzMqpgqR0J6o37w+9HxsmWIgL",
+	"B5bVvPIsiG2XDRUxcWBCZRjSxQZZ/RRt0i7TPgqbG6Cde+hFTU6NXaS6vKlUnyyT1haoQ5hoU8Yne8Y0",
+	"KltQ1QwJm89k6sl3ClMRL8Yk/rhUAghXGGCTVEyRlm/cCo3aCIoNEaARYJWdgwmBtdQ3k8o6EAnGXcFu",
+	"BWUBIxZnofLvMJF7qia1OsYy9Sw3eiJT+PsvHNQdpDqHXzhLNHgjWZHBEetW8890mlgEvDdfoVKwlhHK",
+	"mRULyzTmnrm0sGHv5SLbYfcx4gZsrpX1eeC7kxP8E2vlQJFPr+9j2c7ET1rBYEI+vUPiePSlIQXPjxjE",
+	"6suGwPfNV32IWnNr4k1bpthoxfE8Dwg5h6oN3+MgbfZGbQ3QD4gkaiLic8tAYRsqiZhgcxCfkKDIRDgd",
+	"3DVQhDKzL5a0GHGjzVQo+U9S1IElAZstVYIGtVaWgdj3JcEypR2L9R084vLce3EbAUHHLuWpUjyqq4JW",
+	"xGJhzAIp6Do32boqrvvi5OWWOhA9r1CBgQZqhnKgaqlVjjUi608OL7WCw/fIEqihAxNRpG4vrG+tqqtW",
+	"2Fbg7jJFA4WFgvscYoyLy46bLbJMmEVIs57l+FzrxBSzrCcIH7HA0LYlO7+mZBbyc8DvK50snkwlxGlW",
+	"GRVxlEbEOW2a97JI0wo9z8xeXvG+pm2a6yHyrOq4VhltY1evqwJiK8e68jSmjCUlG5BU4xSAXqOK7BbM",
+	"GTuh+OYjiIE8xWRDaZzSlBPGbcg4vnjZmm0zqTwF24mAVcSwFHsDNQzgwGwZscATn4oontSEPm0R+rcm",
+	"0J2KXdsGs1EZbSujKt/sr5e5TlNxS8njufnREhTlIeZab2OTa1VdtLp3tbSEhQlnopBUuVQayiplwRkc",
+	"CQ9jfXLzp7EMT2PLH/woFIZmlIkvGtIzBiKe+RWID1iEdQpYC7u5LuN5q9ufL7ewa3VF6+zlQ09Wa31T",
+	"D9qpwF47dW80j5roq0YExUUMD9itYxNprHtmvvTWY6NsIhHvTLX+xFL5CVhSB9smp/KHBb7Ft51w+BOI",
+	"b8Q6Vs9IHgIBWYHXd0+8GJ4ybojP4QRlLizzR1dnPhxjmL7Qfk1qzklnWXU8VKsgypfaGbCf/qAcym6u",
+	"Lh6l088ItddOGIcFhbALFc+MVrqwpc4DlmuILRHaBO3xF/+hnzxs5FvXMz2vg3dr0EXjyCQE2lr2qAMi",
+	"dJPKGItNtVr1HuTh6/R5m3m/JRfZEepLLAqVRM3z74iQXh6nE2CfHSZx99QWysEFteyLwuNSQxvheB5e",
+	"2BeSJFhljqV4fwwG7w/DHY7Oly1zRtzBvTvGSx9b39uCS0h8NHiI+MuTv/0pgFbLHzPh2zSV3Z6Z05Rg",
+	"pit2/jLLzs5DFwHqrGPt0tfyKoBvtC7JDJFzA7E2ifVUXGy+VxBhTxpPmMQnsAzbZUws5zqwTM8VGB/o",
+	"ZWkxhS3d0JlhWWGdv/i4MvGBXU7DPsGiwfjpvsQ37NOs3MfYqWFz8vQ9oib0vX6whsrhudFtUnnN7KF+",
+	"dTqczW7i2F9yKDmKh3RLXqDfh7BHPnDlTRUdbli0pwNa+zfmgpf/Wa0+b4xNrb5oK9F8tegnX2VDA85I",
+	"uPuGVnxq//8NltkQQHqlVUrJ/S0iOlkpD2ueGdb6aqKplBTM5hDLiYw3wS4vWmB3QxebvjZwVNeing5y",
+	"f+CJxL8/wNEIXufJM8yPHouPHpGEdHicz7TTOyXFIb25N8DnM22B0TL/zZLfNEseWK/mfbPl0xj290+d",
+	"MhNTOP41h+mqnquG+q1UgnrvzaLYj83V3kNbowkpIaruD9L5OaaTWKuErguJtMycVEa/8H3UlqsNNJM9",
+	"MpBIA7FjFhzebai+Uyq2M23cYSrvgLpA5UXA8C8f1mkqkwSONnehdCuRsXN/NDcQrk95E+/SH9183YCm",
+	"oGMfsM/6RkGtHH/EJTcwidCY+kqnRHw4J8J1ztI5fxdu8Ue542OEZCsksZlMDkNtoZenL/407S0vv7Qs",
+	"FWbqryeqMnxk4n58u3Dge3anf/nTbOpWJwsmfcNOsO+HvbdMGza8fMsIIc+OqlGk0MbfFYkfzeM0HGN6",
+	"iAb0H3V85lzeOSZa57SBI+uvJh9JfXx3ipfz/jUAiyaLoVI9AAA=",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file
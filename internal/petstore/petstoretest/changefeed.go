@@ -0,0 +1,149 @@
+package petstoretest
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"demo/internal/petstore"
+)
+
+// ChangeFeedRepository is a repository that records its own writes in a change feed.
+type ChangeFeedRepository interface {
+	petstore.PetRepository
+	petstore.ChangeFeed
+}
+
+// settleTimeout bounds how long the feed cases wait for changes to be sequenced; a feed
+// may hold changes back while older transactions are still running.
+const settleTimeout = 5 * time.Second
+
+// RunChangeFeedConformanceTests checks that a ChangeFeed reports every write to the
+// repository it belongs to: sequence numbers strictly increase, deletes appear as
+// tombstones, and a consumer replaying from zero ends up with the repository's contents.
+// newRepo must return an empty repository with an empty feed on every call.
+func RunChangeFeedConformanceTests(t *testing.T, newRepo func() ChangeFeedRepository) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo ChangeFeedRepository)
+	}{
+		{"ReplayReconstructsTable", testReplayReconstructsTable},
+		{"SeqStrictlyIncreasing", testSeqStrictlyIncreasing},
+		{"DeleteTombstone", testDeleteTombstone},
+		{"NothingAfterLast", testNothingAfterLast},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo())
+		})
+	}
+}
+
+func testReplayReconstructsTable(t *testing.T, repo ChangeFeedRepository) {
+	ctx := t.Context()
+	for id := int64(1); id <= 5; id++ {
+		mustCreate(t, repo, pet(id, "pet", "tag"), ownerA)
+	}
+	if err := repo.UpdatePet(ctx, petstore.Pet{Id: 2, Name: "renamed"}, ""); err != nil {
+		t.Fatalf("UpdatePet: %v", err)
+	}
+	if err := repo.DeletePet(ctx, 3, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+	mustCreate(t, repo, pet(3, "again", "cat"), ownerB)
+	if err := repo.DeletePet(ctx, 5, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+
+	// Small pages exercise next_since.
+	table := map[int64]petstore.Pet{}
+	for _, change := range collectChanges(t, repo, 0, 9, 2) {
+		switch change.Op {
+		case petstore.Delete:
+			delete(table, change.PetId)
+		default:
+			table[change.PetId] = *change.Payload
+		}
+	}
+
+	want, err := repo.ListPets(ctx, 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	got := slices.SortedFunc(maps.Values(table), func(a, b petstore.Pet) int { return cmp.Compare(a.Id, b.Id) })
+	assertPets(t, got, want)
+}
+
+func testSeqStrictlyIncreasing(t *testing.T, repo ChangeFeedRepository) {
+	for id := int64(1); id <= 4; id++ {
+		mustCreate(t, repo, pet(id, "pet", "tag"), "")
+	}
+	changes := collectChanges(t, repo, 0, 4, 100)
+	for i := 1; i < len(changes); i++ {
+		if changes[i].Seq <= changes[i-1].Seq {
+			t.Fatalf("seq %d follows %d", changes[i].Seq, changes[i-1].Seq)
+		}
+	}
+}
+
+func testDeleteTombstone(t *testing.T, repo ChangeFeedRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	if err := repo.DeletePet(t.Context(), 1, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+	changes := collectChanges(t, repo, 0, 2, 100)
+	last := changes[len(changes)-1]
+	if last.Op != petstore.Delete || last.PetId != 1 || last.Payload != nil {
+		t.Fatalf("got last change %+v, want a delete of pet 1 without payload", last)
+	}
+	if first := changes[0]; first.Op != petstore.Create || first.Payload == nil {
+		t.Fatalf("got first change %+v, want a create with payload", first)
+	} else {
+		assertPet(t, *first.Payload, pet(1, "Rex", "dog"))
+	}
+}
+
+func testNothingAfterLast(t *testing.T, repo ChangeFeedRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	changes := collectChanges(t, repo, 0, 1, 100)
+	rest, err := repo.ListChanges(t.Context(), changes[len(changes)-1].Seq, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("got %d changes after the last seq, want 0", len(rest))
+	}
+}
+
+// collectChanges polls the feed from since in pages of limit, the way a consumer would,
+// until it has returned want changes.
+func collectChanges(t *testing.T, feed petstore.ChangeFeed, since int64, want int, limit int32) []petstore.PetChange {
+	t.Helper()
+	return collectChangesIn(t, t.Context(), feed, since, want, limit)
+}
+
+// collectChangesIn is collectChanges with the feed read in ctx's organization.
+func collectChangesIn(t *testing.T, ctx context.Context, feed petstore.ChangeFeed, since int64, want int, limit int32) []petstore.PetChange {
+	t.Helper()
+	var out []petstore.PetChange
+	deadline := time.Now().Add(settleTimeout)
+	for len(out) < want {
+		page, err := feed.ListChanges(ctx, since, limit)
+		if err != nil {
+			t.Fatalf("ListChanges since %d: %v", since, err)
+		}
+		if len(page) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d changes, want %d", len(out), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		out = append(out, page...)
+		since = page[len(page)-1].Seq
+	}
+	return out
+}
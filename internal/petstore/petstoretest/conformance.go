@@ -0,0 +1,458 @@
+// Package petstoretest holds helpers for testing petstore code, kept out of the petstore
+// package so the server binary does not link the testing package.
+package petstoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"demo/internal/petstore"
+)
+
+const (
+	ownerA = "user-a"
+	ownerB = "user-b"
+)
+
+// RunRepositoryConformanceTests checks that a PetRepository implementation behaves like
+// every other: the errors it returns, ordering, limit semantics, owner restrictions, tag
+// round-trips, and context cancellation, plus keyset pages and owner lookups for
+// repositories that are also a petstore.Pager or petstore.PetOwnerLookup. newRepo must
+// return an empty repository on every call, since each case runs against its own. A new
+// PetRepository method gets its cases here in the same change.
+func RunRepositoryConformanceTests(t *testing.T, newRepo func() petstore.PetRepository) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo petstore.PetRepository)
+	}{
+		{"CreateThenGet", testCreateThenGet},
+		{"DuplicateCreate", testDuplicateCreate},
+		{"ConcurrentDuplicateCreate", testConcurrentDuplicateCreate},
+		{"NilTagRoundTrip", testNilTagRoundTrip},
+		{"EmptyTagRoundTrip", testEmptyTagRoundTrip},
+		{"GetMissing", testGetMissing},
+		{"UpdateMissing", testUpdateMissing},
+		{"UpdateReplacesTag", testUpdateReplacesTag},
+		{"DeleteMissing", testDeleteMissing},
+		{"DeleteThenGet", testDeleteThenGet},
+		{"ListEmpty", testListEmpty},
+		{"ListOrderedByID", testListOrderedByID},
+		{"ListLimit", testListLimit},
+		{"ListOwnedBy", testListOwnedBy},
+		{"OwnerRestrictedWrites", testOwnerRestrictedWrites},
+		{"AnonymousPetOwnerRestricted", testAnonymousPetOwnerRestricted},
+		{"CanceledContext", testCanceledContext},
+		{"PagerWalksEveryPet", testPagerWalksEveryPet},
+		{"PetOwners", testPetOwners},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo())
+		})
+	}
+}
+
+func testCreateThenGet(t *testing.T, repo petstore.PetRepository) {
+	ctx := t.Context()
+	want := pet(1, "Rex", "dog")
+	mustCreate(t, repo, want, ownerA)
+	got, err := repo.GetPet(ctx, want.Id)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, want)
+}
+
+func testDuplicateCreate(t *testing.T, repo petstore.PetRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), ownerA)
+	err := repo.CreatePet(t.Context(), pet(1, "Other", "cat"), ownerB)
+	if !errors.Is(err, petstore.ErrPetExists) {
+		t.Fatalf("CreatePet duplicate: got %v, want ErrPetExists", err)
+	}
+	got, err := repo.GetPet(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, pet(1, "Rex", "dog"))
+}
+
+// testConcurrentDuplicateCreate races creates of one id: exactly one may win and every
+// other must see ErrPetExists rather than a raw storage error.
+func testConcurrentDuplicateCreate(t *testing.T, repo petstore.PetRepository) {
+	const racers = 8
+	errs := make([]error, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range racers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = repo.CreatePet(t.Context(), pet(1, fmt.Sprintf("Racer %d", i), "dog"), "")
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	won := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, petstore.ErrPetExists):
+			t.Errorf("CreatePet racer %d: got %v, want nil or ErrPetExists", i, err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("CreatePet race: %d creates succeeded, want exactly 1", won)
+	}
+}
+
+func testNilTagRoundTrip(t *testing.T, repo petstore.PetRepository) {
+	want := petstore.Pet{Id: 1, Name: "Rex"}
+	mustCreate(t, repo, want, "")
+	got, err := repo.GetPet(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, want)
+	pets, err := repo.ListPets(t.Context(), 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	assertPets(t, pets, []petstore.Pet{want})
+}
+
+func testEmptyTagRoundTrip(t *testing.T, repo petstore.PetRepository) {
+	want := pet(1, "Rex", "")
+	mustCreate(t, repo, want, "")
+	got, err := repo.GetPet(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, want)
+}
+
+func testGetMissing(t *testing.T, repo petstore.PetRepository) {
+	if _, err := repo.GetPet(t.Context(), 42); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("GetPet missing: got %v, want ErrPetNotFound", err)
+	}
+}
+
+func testUpdateMissing(t *testing.T, repo petstore.PetRepository) {
+	for _, ownedBy := range []string{"", ownerA} {
+		if err := repo.UpdatePet(t.Context(), pet(42, "Rex", "dog"), ownedBy); !errors.Is(err, petstore.ErrPetNotFound) {
+			t.Fatalf("UpdatePet missing (ownedBy %q): got %v, want ErrPetNotFound", ownedBy, err)
+		}
+	}
+}
+
+func testUpdateReplacesTag(t *testing.T, repo petstore.PetRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), ownerA)
+	want := petstore.Pet{Id: 1, Name: "Rexy"}
+	if err := repo.UpdatePet(t.Context(), want, ""); err != nil {
+		t.Fatalf("UpdatePet: %v", err)
+	}
+	got, err := repo.GetPet(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, want)
+}
+
+func testDeleteMissing(t *testing.T, repo petstore.PetRepository) {
+	for _, ownedBy := range []string{"", ownerA} {
+		if err := repo.DeletePet(t.Context(), 42, ownedBy); !errors.Is(err, petstore.ErrPetNotFound) {
+			t.Fatalf("DeletePet missing (ownedBy %q): got %v, want ErrPetNotFound", ownedBy, err)
+		}
+	}
+}
+
+func testDeleteThenGet(t *testing.T, repo petstore.PetRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), ownerA)
+	if err := repo.DeletePet(t.Context(), 1, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+	if _, err := repo.GetPet(t.Context(), 1); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("GetPet after delete: got %v, want ErrPetNotFound", err)
+	}
+	if err := repo.DeletePet(t.Context(), 1, ""); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("DeletePet twice: got %v, want ErrPetNotFound", err)
+	}
+}
+
+func testListEmpty(t *testing.T, repo petstore.PetRepository) {
+	pets, err := repo.ListPets(t.Context(), 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	// The handler encodes the result directly, so an empty store must list as [] not null.
+	if pets == nil || len(pets) != 0 {
+		t.Fatalf("ListPets on empty repository: got %#v, want empty non-nil slice", pets)
+	}
+}
+
+func testListOrderedByID(t *testing.T, repo petstore.PetRepository) {
+	for _, id := range []int64{3, 1, 2} {
+		mustCreate(t, repo, pet(id, "Pet", "tag"), "")
+	}
+	pets, err := repo.ListPets(t.Context(), 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	assertPets(t, pets, []petstore.Pet{pet(1, "Pet", "tag"), pet(2, "Pet", "tag"), pet(3, "Pet", "tag")})
+}
+
+func testListLimit(t *testing.T, repo petstore.PetRepository) {
+	for id := int64(1); id <= 5; id++ {
+		mustCreate(t, repo, pet(id, "Pet", "tag"), "")
+	}
+	for _, tc := range []struct {
+		limit int32
+		want  []int64
+	}{
+		{0, []int64{1, 2, 3, 4, 5}},
+		{1, []int64{1}},
+		{3, []int64{1, 2, 3}},
+		{5, []int64{1, 2, 3, 4, 5}},
+		{10, []int64{1, 2, 3, 4, 5}},
+	} {
+		pets, err := repo.ListPets(t.Context(), tc.limit, "")
+		if err != nil {
+			t.Fatalf("ListPets limit %d: %v", tc.limit, err)
+		}
+		if got := ids(pets); !slices.Equal(got, tc.want) {
+			t.Errorf("ListPets limit %d: got ids %v, want %v", tc.limit, got, tc.want)
+		}
+	}
+}
+
+func testListOwnedBy(t *testing.T, repo petstore.PetRepository) {
+	mustCreate(t, repo, pet(1, "A1", "tag"), ownerA)
+	mustCreate(t, repo, pet(2, "B1", "tag"), ownerB)
+	mustCreate(t, repo, pet(3, "Anon", "tag"), "")
+	mustCreate(t, repo, pet(4, "A2", "tag"), ownerA)
+
+	for _, tc := range []struct {
+		ownedBy string
+		limit   int32
+		want    []int64
+	}{
+		{"", 0, []int64{1, 2, 3, 4}},
+		{ownerA, 0, []int64{1, 4}},
+		{ownerA, 1, []int64{1}},
+		{ownerB, 0, []int64{2}},
+		{"nobody", 0, []int64{}},
+	} {
+		pets, err := repo.ListPets(t.Context(), tc.limit, tc.ownedBy)
+		if err != nil {
+			t.Fatalf("ListPets ownedBy %q: %v", tc.ownedBy, err)
+		}
+		if got := ids(pets); !slices.Equal(got, tc.want) {
+			t.Errorf("ListPets ownedBy %q limit %d: got ids %v, want %v", tc.ownedBy, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func testOwnerRestrictedWrites(t *testing.T, repo petstore.PetRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), ownerA)
+
+	if err := repo.UpdatePet(ctx, pet(1, "Stolen", "dog"), ownerB); !errors.Is(err, petstore.ErrNotPetOwner) {
+		t.Fatalf("UpdatePet by other owner: got %v, want ErrNotPetOwner", err)
+	}
+	if err := repo.DeletePet(ctx, 1, ownerB); !errors.Is(err, petstore.ErrNotPetOwner) {
+		t.Fatalf("DeletePet by other owner: got %v, want ErrNotPetOwner", err)
+	}
+	got, err := repo.GetPet(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, pet(1, "Rex", "dog"))
+
+	if err := repo.UpdatePet(ctx, pet(1, "Rexy", "dog"), ownerA); err != nil {
+		t.Fatalf("UpdatePet by owner: %v", err)
+	}
+	if err := repo.DeletePet(ctx, 1, ownerA); err != nil {
+		t.Fatalf("DeletePet by owner: %v", err)
+	}
+}
+
+func testAnonymousPetOwnerRestricted(t *testing.T, repo petstore.PetRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	if err := repo.UpdatePet(t.Context(), pet(1, "Rexy", "dog"), ownerA); !errors.Is(err, petstore.ErrNotPetOwner) {
+		t.Fatalf("UpdatePet of anonymous pet with ownedBy: got %v, want ErrNotPetOwner", err)
+	}
+	if err := repo.UpdatePet(t.Context(), pet(1, "Rexy", "dog"), ""); err != nil {
+		t.Fatalf("UpdatePet of anonymous pet without ownedBy: %v", err)
+	}
+}
+
+func testCanceledContext(t *testing.T, repo petstore.PetRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, err := repo.ListPets(ctx, 0, ""); err == nil {
+		t.Error("ListPets with canceled context: got nil error")
+	}
+	if err := repo.CreatePet(ctx, pet(2, "Max", "dog"), ""); err == nil {
+		t.Error("CreatePet with canceled context: got nil error")
+	}
+	if _, err := repo.GetPet(ctx, 1); err == nil {
+		t.Error("GetPet with canceled context: got nil error")
+	}
+	if err := repo.UpdatePet(ctx, pet(1, "Rexy", "dog"), ""); err == nil {
+		t.Error("UpdatePet with canceled context: got nil error")
+	}
+	if err := repo.DeletePet(ctx, 1, ""); err == nil {
+		t.Error("DeletePet with canceled context: got nil error")
+	}
+
+	// None of the canceled writes may have taken effect.
+	got, err := repo.GetPet(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, pet(1, "Rex", "dog"))
+	if _, err := repo.GetPet(t.Context(), 2); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("GetPet of canceled create: got %v, want ErrPetNotFound", err)
+	}
+}
+
+// testPagerWalksEveryPet follows ListPetsAfter cursors page by page: the pages must
+// cover every pet exactly once, in order, whatever the page size, and agree with
+// CountPets.
+func testPagerWalksEveryPet(t *testing.T, repo petstore.PetRepository) {
+	pager, ok := repo.(petstore.Pager)
+	if !ok {
+		t.Skip("repository is not a petstore.Pager")
+	}
+	for _, id := range []int64{7, 2, 5, 1, 9, 3, 8} {
+		owner := ownerA
+		if id%2 == 0 {
+			owner = ownerB
+		}
+		mustCreate(t, repo, pet(id, "Pet", "tag"), owner)
+	}
+
+	for _, tc := range []struct {
+		ownedBy string
+		want    []int64
+	}{
+		{"", []int64{1, 2, 3, 5, 7, 8, 9}},
+		{ownerA, []int64{1, 3, 5, 7, 9}},
+		{ownerB, []int64{2, 8}},
+		{"nobody", []int64{}},
+	} {
+		for _, pageSize := range []int32{1, 2, 3, 7, 10} {
+			got := []int64{}
+			var after int64
+			for range len(tc.want) + 1 {
+				page, err := pager.ListPetsAfter(t.Context(), after, pageSize, tc.ownedBy)
+				if err != nil {
+					t.Fatalf("ListPetsAfter(%d, %d, %q): %v", after, pageSize, tc.ownedBy, err)
+				}
+				if len(page) > int(pageSize) {
+					t.Fatalf("ListPetsAfter(%d, %d, %q): got %d pets", after, pageSize, tc.ownedBy, len(page))
+				}
+				if len(page) == 0 {
+					break
+				}
+				got = append(got, ids(page)...)
+				after = page[len(page)-1].Id
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("pages of %d ownedBy %q: got ids %v, want %v", pageSize, tc.ownedBy, got, tc.want)
+			}
+		}
+
+		total, err := pager.CountPets(t.Context(), tc.ownedBy)
+		if err != nil {
+			t.Fatalf("CountPets(%q): %v", tc.ownedBy, err)
+		}
+		if total != int64(len(tc.want)) {
+			t.Errorf("CountPets(%q): got %d, want %d", tc.ownedBy, total, len(tc.want))
+		}
+	}
+}
+
+// testPetOwners looks up owners in bulk: anonymous, deleted, and unknown pets are left
+// out rather than reported with an empty owner.
+func testPetOwners(t *testing.T, repo petstore.PetRepository) {
+	lookup, ok := repo.(petstore.PetOwnerLookup)
+	if !ok {
+		t.Skip("repository is not a petstore.PetOwnerLookup")
+	}
+	mustCreate(t, repo, pet(1, "A", "tag"), ownerA)
+	mustCreate(t, repo, pet(2, "B", "tag"), ownerB)
+	mustCreate(t, repo, pet(3, "Anon", "tag"), "")
+	mustCreate(t, repo, pet(4, "Gone", "tag"), ownerA)
+	if err := repo.DeletePet(t.Context(), 4, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+
+	owners, err := lookup.PetOwners(t.Context(), []int64{1, 2, 3, 4, 42})
+	if err != nil {
+		t.Fatalf("PetOwners: %v", err)
+	}
+	if want := map[int64]string{1: ownerA, 2: ownerB}; !maps.Equal(owners, want) {
+		t.Fatalf("PetOwners: got %v, want %v", owners, want)
+	}
+}
+
+// pet builds a Pet with a non-nil tag.
+func pet(id int64, name, tag string) petstore.Pet {
+	return petstore.Pet{Id: id, Name: name, Tag: &tag}
+}
+
+func mustCreate(t *testing.T, repo petstore.PetRepository, p petstore.Pet, owner string) {
+	t.Helper()
+	if err := repo.CreatePet(t.Context(), p, owner); err != nil {
+		t.Fatalf("CreatePet %d: %v", p.Id, err)
+	}
+}
+
+func assertPet(t *testing.T, got, want petstore.Pet) {
+	t.Helper()
+	if got.Id != want.Id || got.Name != want.Name || !equalTags(got.Tag, want.Tag) {
+		t.Fatalf("got pet %s, want %s", describe(got), describe(want))
+	}
+}
+
+func assertPets(t *testing.T, got, want []petstore.Pet) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d pets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		assertPet(t, got[i], want[i])
+	}
+}
+
+func equalTags(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func describe(p petstore.Pet) string {
+	if p.Tag == nil {
+		return fmt.Sprintf("{id %d, name %q, tag <nil>}", p.Id, p.Name)
+	}
+	return fmt.Sprintf("{id %d, name %q, tag %q}", p.Id, p.Name, *p.Tag)
+}
+
+func ids(pets []petstore.Pet) []int64 {
+	out := make([]int64, len(pets))
+	for i, p := range pets {
+		out[i] = p.Id
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package petstoretest
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"demo/internal/petstore"
+)
+
+// DeduperRepository is a repository that detects and merges duplicate pets and records
+// its writes in a change feed.
+type DeduperRepository interface {
+	ChangeFeedRepository
+	petstore.Deduper
+}
+
+// RunDeduperConformanceTests checks duplicate detection and merges: names match after
+// trimming and case folding while tags must be identical, a merge hides the duplicates
+// and keeps the survivor, invalid merges change nothing, and the change feed still
+// replays to the table afterwards. newRepo must return an empty repository with an empty
+// feed on every call.
+func RunDeduperConformanceTests(t *testing.T, newRepo func() DeduperRepository) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo DeduperRepository)
+	}{
+		{"ListGroupsNormalizedNames", testListGroupsNormalizedNames},
+		{"ThreeWayMerge", testThreeWayMerge},
+		{"MergeHistoryPreserved", testMergeHistoryPreserved},
+		{"ChainedMerge", testChainedMerge},
+		{"MergeAdoptsOwner", testMergeAdoptsOwner},
+		{"MergeNotDuplicates", testMergeNotDuplicates},
+		{"MergeMissing", testMergeMissing},
+		{"MergeOwnerRestricted", testMergeOwnerRestricted},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo())
+		})
+	}
+}
+
+func testListGroupsNormalizedNames(t *testing.T, repo DeduperRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex ", "dog"), "")
+	mustCreate(t, repo, pet(3, " REX", "dog"), "")
+	mustCreate(t, repo, pet(4, "Rex", "cat"), "")
+	mustCreate(t, repo, pet(5, "Max", "dog"), "")
+	mustCreate(t, repo, petstore.Pet{Id: 6, Name: "Max"}, "")
+	mustCreate(t, repo, petstore.Pet{Id: 7, Name: "max"}, "")
+
+	groups, err := repo.ListDuplicates(t.Context(), 10)
+	if err != nil {
+		t.Fatalf("ListDuplicates: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	if g := groups[0]; g.Name != "rex" || g.Tag == nil || *g.Tag != "dog" || !slices.Equal(g.PetIds, []int64{1, 2, 3}) {
+		t.Fatalf("got first group %+v, want rex/dog with pets 1, 2, 3", g)
+	}
+	if g := groups[1]; g.Name != "max" || g.Tag != nil || !slices.Equal(g.PetIds, []int64{6, 7}) {
+		t.Fatalf("got second group %+v, want max without tag with pets 6, 7", g)
+	}
+
+	groups, err = repo.ListDuplicates(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("ListDuplicates: %v", err)
+	}
+	if len(groups) != 1 || groups[0].PetIds[0] != 1 {
+		t.Fatalf("got %+v with limit 1, want only the group of pet 1", groups)
+	}
+}
+
+func testThreeWayMerge(t *testing.T, repo DeduperRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex ", "dog"), "")
+	mustCreate(t, repo, pet(3, " REX", "dog"), "")
+	mustCreate(t, repo, pet(4, "Max", "dog"), "")
+
+	survivor, err := repo.MergePets(ctx, 1, []int64{2, 3}, "", "")
+	if err != nil {
+		t.Fatalf("MergePets: %v", err)
+	}
+	assertPet(t, survivor, pet(1, "Rex", "dog"))
+
+	for _, id := range []int64{2, 3} {
+		if _, err := repo.GetPet(ctx, id); !errors.Is(err, petstore.ErrPetNotFound) {
+			t.Fatalf("GetPet %d after merge: got %v, want ErrPetNotFound", id, err)
+		}
+		if err := repo.UpdatePet(ctx, pet(id, "Rex", "dog"), ""); !errors.Is(err, petstore.ErrPetNotFound) {
+			t.Fatalf("UpdatePet %d after merge: got %v, want ErrPetNotFound", id, err)
+		}
+	}
+	got, err := repo.ListPets(ctx, 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	assertPets(t, got, []petstore.Pet{pet(1, "Rex", "dog"), pet(4, "Max", "dog")})
+
+	groups, err := repo.ListDuplicates(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListDuplicates: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Fatalf("got %d duplicate groups after the merge, want 0", len(groups))
+	}
+}
+
+func testMergeHistoryPreserved(t *testing.T, repo DeduperRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), "")
+	mustCreate(t, repo, pet(3, "REX", "dog"), "")
+	if err := repo.UpdatePet(ctx, pet(1, "Rex", "dog"), ""); err != nil {
+		t.Fatalf("UpdatePet: %v", err)
+	}
+	if _, err := repo.MergePets(ctx, 1, []int64{2, 3}, "", ""); err != nil {
+		t.Fatalf("MergePets: %v", err)
+	}
+
+	// Three creates, the update, and a tombstone for each duplicate.
+	changes := collectChanges(t, repo, 0, 6, 100)
+	var survivorChanges int
+	table := map[int64]petstore.Pet{}
+	for _, change := range changes {
+		if change.PetId == 1 {
+			survivorChanges++
+		}
+		switch change.Op {
+		case petstore.Delete:
+			delete(table, change.PetId)
+		default:
+			table[change.PetId] = *change.Payload
+		}
+	}
+	if survivorChanges != 2 {
+		t.Fatalf("got %d changes for the survivor, want its create and update", survivorChanges)
+	}
+	for _, change := range changes[len(changes)-2:] {
+		if change.Op != petstore.Delete || change.Payload != nil {
+			t.Fatalf("got change %+v, want the merge to end in tombstones", change)
+		}
+	}
+	got := slices.SortedFunc(maps.Values(table), func(a, b petstore.Pet) int { return cmp.Compare(a.Id, b.Id) })
+	assertPets(t, got, []petstore.Pet{pet(1, "Rex", "dog")})
+}
+
+func testChainedMerge(t *testing.T, repo DeduperRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), "")
+	mustCreate(t, repo, pet(3, "REX", "dog"), "")
+	if _, err := repo.MergePets(ctx, 2, []int64{3}, "", ""); err != nil {
+		t.Fatalf("MergePets 3 into 2: %v", err)
+	}
+	if _, err := repo.MergePets(ctx, 1, []int64{2}, "", ""); err != nil {
+		t.Fatalf("MergePets 2 into 1: %v", err)
+	}
+	if _, err := repo.MergePets(ctx, 1, []int64{3}, "", ""); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("MergePets of an already merged pet: got %v, want ErrPetNotFound", err)
+	}
+	got, err := repo.ListPets(ctx, 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	assertPets(t, got, []petstore.Pet{pet(1, "Rex", "dog")})
+}
+
+func testMergeAdoptsOwner(t *testing.T, repo DeduperRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), ownerA)
+	if _, err := repo.MergePets(ctx, 1, []int64{2}, "", ""); err != nil {
+		t.Fatalf("MergePets: %v", err)
+	}
+	got, err := repo.ListPets(ctx, 0, ownerA)
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	assertPets(t, got, []petstore.Pet{pet(1, "Rex", "dog")})
+}
+
+func testMergeNotDuplicates(t *testing.T, repo DeduperRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), "")
+	mustCreate(t, repo, pet(3, "Rex", "cat"), "")
+	if _, err := repo.MergePets(ctx, 1, []int64{2, 3}, "", ""); !errors.Is(err, petstore.ErrNotDuplicates) {
+		t.Fatalf("MergePets: got %v, want ErrNotDuplicates", err)
+	}
+	assertUnchanged(t, repo, 3)
+}
+
+func testMergeMissing(t *testing.T, repo DeduperRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), "")
+	if _, err := repo.MergePets(t.Context(), 1, []int64{2, 99}, "", ""); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("MergePets: got %v, want ErrPetNotFound", err)
+	}
+	assertUnchanged(t, repo, 2)
+}
+
+func testMergeOwnerRestricted(t *testing.T, repo DeduperRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), ownerA)
+	mustCreate(t, repo, pet(2, "rex", "dog"), ownerB)
+	if _, err := repo.MergePets(ctx, 1, []int64{2}, ownerA, ownerA); !errors.Is(err, petstore.ErrNotPetOwner) {
+		t.Fatalf("MergePets: got %v, want ErrNotPetOwner", err)
+	}
+	assertUnchanged(t, repo, 2)
+}
+
+// assertUnchanged checks that pets 1 to n are all still listed after a failed merge.
+func assertUnchanged(t *testing.T, repo DeduperRepository, n int64) {
+	t.Helper()
+	got, err := repo.ListPets(t.Context(), 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	want := make([]int64, 0, n)
+	for id := int64(1); id <= n; id++ {
+		want = append(want, id)
+	}
+	if !slices.Equal(ids(got), want) {
+		t.Fatalf("got pets %v after a failed merge, want %v", ids(got), want)
+	}
+}
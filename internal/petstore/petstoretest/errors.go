@@ -0,0 +1,225 @@
+package petstoretest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+	"demo/internal/httpmw"
+	"demo/internal/petstore"
+	"demo/pkg/petstoreclient"
+)
+
+// errorCase is a request and the error it must be answered with. errorCode is empty when
+// the error carries none, as for middleware rejections.
+type errorCase struct {
+	name      string
+	method    string
+	target    string
+	body      string
+	user      string
+	header    []string
+	status    int
+	category  apierr.Category
+	errorCode string
+}
+
+// RunErrorCategoryConformanceTests checks that every endpoint reports each failure with
+// its documented category and error_code: handler errors over a repository seeded with
+// pet 1 owned by alice, middleware rejections through the stack the application mounts,
+// the same categories decoded by pkg/petstoreclient and matched with errors.Is, and the
+// category lists of apierr, the spec, and the client agreeing. newRepo must return an
+// empty repository on every call.
+func RunErrorCategoryConformanceTests(t *testing.T, newRepo func() petstore.PetRepository) {
+	t.Helper()
+	t.Run("Handlers", func(t *testing.T) {
+		handler := newErrorHandler(t, newRepo(), nil)
+		for _, tc := range []errorCase{
+			{name: "ListPetsNegativeLimit", method: http.MethodGet, target: "/pets?limit=-1", status: http.StatusBadRequest, category: apierr.InvalidRequest, errorCode: "LIMIT_NEGATIVE"},
+			{name: "ListPetsMalformedLimit", method: http.MethodGet, target: "/pets?limit=abc", status: http.StatusBadRequest, category: apierr.InvalidRequest},
+			{name: "ListPetsMineAnonymous", method: http.MethodGet, target: "/pets?mine=true", status: http.StatusUnauthorized, category: apierr.Unauthenticated, errorCode: "AUTH_REQUIRED_FOR_MINE"},
+			{name: "CreatePetExists", method: http.MethodPost, target: "/pets", body: `{"id": 1, "name": "Rex"}`, status: http.StatusConflict, category: apierr.PetExists, errorCode: "PET_EXISTS"},
+			{name: "CreatePetInvalid", method: http.MethodPost, target: "/pets", body: `{"id": 2, "name": ""}`, status: http.StatusBadRequest, category: apierr.ValidationFailed, errorCode: "PET_NAME_REQUIRED"},
+			{name: "CreatePetMalformed", method: http.MethodPost, target: "/pets", body: `{"id":`, status: http.StatusBadRequest, category: apierr.InvalidRequest, errorCode: "INVALID_JSON"},
+			{name: "ShowPetMissing", method: http.MethodGet, target: "/pets/99", status: http.StatusNotFound, category: apierr.PetNotFound, errorCode: "PET_NOT_FOUND"},
+			{name: "ShowPetBadID", method: http.MethodGet, target: "/pets/abc", status: http.StatusBadRequest, category: apierr.InvalidRequest, errorCode: "INVALID_PET_ID"},
+			{name: "UpdatePetIDMismatch", method: http.MethodPut, target: "/pets/1", body: `{"id": 2, "name": "Max"}`, user: "alice", status: http.StatusBadRequest, category: apierr.InvalidRequest, errorCode: "PET_ID_MISMATCH"},
+			{name: "UpdatePetNotOwner", method: http.MethodPut, target: "/pets/1", body: `{"id": 1, "name": "Max"}`, user: "bob", status: http.StatusForbidden, category: apierr.NotPetOwner, errorCode: "NOT_PET_OWNER"},
+			{name: "DeletePetMissing", method: http.MethodDelete, target: "/pets/99", status: http.StatusNotFound, category: apierr.PetNotFound, errorCode: "PET_NOT_FOUND"},
+			{name: "DeletePetNotOwner", method: http.MethodDelete, target: "/pets/1", user: "bob", status: http.StatusForbidden, category: apierr.NotPetOwner, errorCode: "NOT_PET_OWNER"},
+			{name: "PhotosDisabled", method: http.MethodGet, target: "/pets/1/photo", status: http.StatusNotFound, category: apierr.NotFound, errorCode: "PHOTOS_DISABLED"},
+			{name: "ExportsDisabled", method: http.MethodPost, target: "/pets/exports", body: `{"format": "csv"}`, status: http.StatusNotFound, category: apierr.NotFound, errorCode: "EXPORTS_DISABLED"},
+			{name: "ChangeFeedUnavailable", method: http.MethodGet, target: "/pets/changes", status: http.StatusNotFound, category: apierr.NotFound, errorCode: "CHANGE_FEED_UNAVAILABLE"},
+			{name: "DeduplicationUnavailable", method: http.MethodGet, target: "/pets/duplicates", status: http.StatusNotFound, category: apierr.NotFound, errorCode: "DEDUPLICATION_UNAVAILABLE"},
+			{name: "CategoryIgnoresLanguage", method: http.MethodGet, target: "/pets/99", header: []string{"Accept-Language", "de"}, status: http.StatusNotFound, category: apierr.PetNotFound, errorCode: "PET_NOT_FOUND"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				assertErrorResponse(t, serveError(t, handler, tc), tc)
+			})
+		}
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		handler := newErrorHandler(t, newRepo(), nil)
+		rec := serveError(t, handler, errorCase{method: http.MethodGet, target: "/pets/99", header: []string{"Accept", "application/xml"}})
+		assertStatus(t, rec, http.StatusNotFound)
+		var doc struct {
+			Category  string `xml:"category"`
+			ErrorCode string `xml:"error_code"`
+		}
+		if err := xml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+		if doc.Category != string(apierr.PetNotFound) || doc.ErrorCode != "PET_NOT_FOUND" {
+			t.Fatalf("got category %q, error_code %q, want PET_NOT_FOUND for both", doc.Category, doc.ErrorCode)
+		}
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		spec, err := petstore.GetSwagger()
+		if err != nil {
+			t.Fatalf("GetSwagger: %v", err)
+		}
+		limiter := httpmw.NewRateLimiter("errors", 0.001, 1, time.Minute, func(*http.Request) string { return "client" })
+		handler := newErrorHandler(t, newRepo(), []petstore.MiddlewareFunc{
+			petstore.NewRequestValidator(spec, petstore.ValidatorOptions{}),
+			httpmw.BodyLimit(64, nil),
+		})
+		for _, tc := range []errorCase{
+			{name: "SchemaViolation", method: http.MethodPost, target: "/pets", body: `{"id": "one", "name": "Rex"}`, status: http.StatusBadRequest, category: apierr.ValidationFailed},
+			{name: "BodyTooLarge", method: http.MethodPost, target: "/pets", body: `{"id": 2, "name": "` + strings.Repeat("x", 100) + `"}`, status: http.StatusRequestEntityTooLarge, category: apierr.BodyTooLarge},
+			{name: "MethodNotAllowed", method: http.MethodPatch, target: "/pets/1", status: http.StatusMethodNotAllowed, category: apierr.MethodNotAllowed},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				assertErrorResponse(t, serveError(t, handler, tc), tc)
+			})
+		}
+		t.Run("RateLimited", func(t *testing.T) {
+			limited := limiter.Middleware(handler)
+			tc := errorCase{method: http.MethodGet, target: "/pets", status: http.StatusTooManyRequests, category: apierr.RateLimited}
+			assertStatus(t, serveError(t, limited, tc), http.StatusOK)
+			assertErrorResponse(t, serveError(t, limited, tc), tc)
+		})
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		srv := httptest.NewServer(newErrorHandler(t, newRepo(), nil))
+		t.Cleanup(srv.Close)
+		client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL), petstoreclient.WithRetry(0, 0))
+
+		_, err := client.GetPet(t.Context(), 99)
+		assertClientCategory(t, "GetPet(99)", err, petstoreclient.PetNotFound, petstoreclient.NotFound)
+		err = client.CreatePet(t.Context(), petstoreclient.Pet{ID: 1, Name: "Rex"})
+		assertClientCategory(t, "CreatePet(1)", err, petstoreclient.PetExists, petstoreclient.Conflict)
+		err = client.CreatePet(t.Context(), petstoreclient.Pet{ID: 2})
+		assertClientCategory(t, "CreatePet without a name", err, petstoreclient.ValidationFailed, petstoreclient.InvalidRequest)
+	})
+
+	t.Run("CategoriesAgree", func(t *testing.T) {
+		want := apierr.Categories()
+		spec, err := petstore.GetSwagger()
+		if err != nil {
+			t.Fatalf("GetSwagger: %v", err)
+		}
+		var inSpec []apierr.Category
+		for _, v := range spec.Components.Schemas["Error"].Value.Properties["category"].Value.Enum {
+			inSpec = append(inSpec, apierr.Category(v.(string)))
+		}
+		slices.Sort(inSpec)
+		if !slices.Equal(inSpec, want) {
+			t.Errorf("spec Error.category enum is %q, apierr has %q", inSpec, want)
+		}
+		var inClient []apierr.Category
+		for _, c := range petstoreclient.Categories() {
+			inClient = append(inClient, apierr.Category(c))
+		}
+		if !slices.Equal(inClient, want) {
+			t.Errorf("petstoreclient has categories %q, apierr has %q", inClient, want)
+		}
+	})
+}
+
+// newErrorHandler serves repo, seeded with pet 1 owned by alice, the way the application
+// does: owner checks on, malformed parameters answered by ParamErrorHandler, unrouted
+// methods by httpmw.MethodNotAllowed, and middlewares applied innermost first.
+func newErrorHandler(t *testing.T, repo petstore.PetRepository, middlewares []petstore.MiddlewareFunc) http.Handler {
+	t.Helper()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "alice")
+	router := chi.NewRouter()
+	router.MethodNotAllowed(httpmw.MethodNotAllowed)
+	return petstore.HandlerWithOptions(petstore.NewServer(repo, nil, petstore.WithOwnerChecks()), petstore.ChiServerOptions{
+		BaseRouter:       router,
+		Middlewares:      middlewares,
+		ErrorHandlerFunc: petstore.ParamErrorHandler,
+	})
+}
+
+// serveError serves tc's request, as tc.user when set.
+func serveError(t *testing.T, handler http.Handler, tc errorCase) *httptest.ResponseRecorder {
+	t.Helper()
+	ctx := t.Context()
+	if tc.user != "" {
+		ctx = auth.ContextWithUser(ctx, auth.User{ID: tc.user})
+	}
+	req := httptest.NewRequestWithContext(ctx, tc.method, tc.target, strings.NewReader(tc.body))
+	if tc.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(tc.header); i += 2 {
+		req.Header.Set(tc.header[i], tc.header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func assertErrorResponse(t *testing.T, rec *httptest.ResponseRecorder, tc errorCase) {
+	t.Helper()
+	assertStatus(t, rec, tc.status)
+	var body petstore.Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: decoding %s: %v", tc.method, tc.target, rec.Body, err)
+	}
+	if body.Code != int32(tc.status) {
+		t.Errorf("%s %s: got code %d, want %d", tc.method, tc.target, body.Code, tc.status)
+	}
+	if body.Category == nil || apierr.Category(*body.Category) != tc.category {
+		t.Errorf("%s %s: got category %v in %s, want %s", tc.method, tc.target, body.Category, rec.Body, tc.category)
+	}
+	var errorCode string
+	if body.ErrorCode != nil {
+		errorCode = *body.ErrorCode
+	}
+	if errorCode != tc.errorCode {
+		t.Errorf("%s %s: got error_code %q, want %q", tc.method, tc.target, errorCode, tc.errorCode)
+	}
+	if body.Message == "" || body.Message == errorCode {
+		t.Errorf("%s %s: got message %q, want text rendered from the code", tc.method, tc.target, body.Message)
+	}
+}
+
+// assertClientCategory requires err to be an APIError matching want, and only want among
+// it and other, with errors.Is.
+func assertClientCategory(t *testing.T, call string, err error, want, other petstoreclient.ErrorCategory) {
+	t.Helper()
+	var apiErr *petstoreclient.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("%s: got %v, want an APIError", call, err)
+	}
+	if !errors.Is(err, want) {
+		t.Errorf("%s: errors.Is(%v, %s) is false (category %q)", call, err, want, apiErr.Category)
+	}
+	if errors.Is(err, other) {
+		t.Errorf("%s: errors.Is(%v, %s) is true, want only %s", call, err, other, want)
+	}
+}
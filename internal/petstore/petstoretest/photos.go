@@ -0,0 +1,379 @@
+package petstoretest
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/petstore"
+	"demo/internal/tenant"
+)
+
+// petPNG is a 4x4 PNG, small enough to upload under any sensible limit.
+//
+//go:embed pet.png
+var petPNG []byte
+
+// photoMaxBytes is the upload limit RunPhotoConformanceTests configures.
+const photoMaxBytes = 1 << 10
+
+// largeBlobBytes is the size of the blob LargeBlob streams: more than two 5MiB parts, the
+// smallest an S3 multipart upload allows, so a store configured with that part size
+// uploads it in three.
+const largeBlobBytes = 12<<20 + 1
+
+// RunBlobStoreConformanceTests checks that a BlobStore implementation behaves like every
+// other: blobs round-trip with their content type and ETag, Put replaces, keys are
+// distinct however they nest, bodies of unknown length stream in whole, and missing blobs
+// are ErrBlobNotFound. newStore must return an empty store on every call.
+func RunBlobStoreConformanceTests(t *testing.T, newStore func() petstore.BlobStore) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, store petstore.BlobStore)
+	}{
+		{"PutThenGet", testBlobPutThenGet},
+		{"PutReplaces", testBlobPutReplaces},
+		{"EmptyBlob", testBlobEmpty},
+		{"NestedKeys", testBlobNestedKeys},
+		{"LargeBlob", testBlobLarge},
+		{"GetMissing", testBlobGetMissing},
+		{"DeleteThenGet", testBlobDeleteThenGet},
+		{"DeleteMissing", testBlobDeleteMissing},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newStore())
+		})
+	}
+}
+
+func testBlobPutThenGet(t *testing.T, store petstore.BlobStore) {
+	mustPut(t, store, "photos/default/1", "image/png", "etag-1", petPNG)
+	assertBlob(t, store, "photos/default/1", "image/png", "etag-1", petPNG)
+}
+
+func testBlobPutReplaces(t *testing.T, store petstore.BlobStore) {
+	mustPut(t, store, "photos/default/1", "image/png", "etag-1", petPNG)
+	mustPut(t, store, "photos/default/1", "image/jpeg", "etag-2", []byte("\xff\xd8\xffjpeg"))
+	assertBlob(t, store, "photos/default/1", "image/jpeg", "etag-2", []byte("\xff\xd8\xffjpeg"))
+}
+
+func testBlobEmpty(t *testing.T, store petstore.BlobStore) {
+	mustPut(t, store, "empty", "application/octet-stream", "etag-0", nil)
+	assertBlob(t, store, "empty", "application/octet-stream", "etag-0", nil)
+}
+
+func testBlobNestedKeys(t *testing.T, store petstore.BlobStore) {
+	mustPut(t, store, "photos/org-a/1", "image/png", "a", []byte("a"))
+	mustPut(t, store, "photos/org-b/1", "image/png", "b", []byte("b"))
+	mustPut(t, store, "photos/org-a/10", "image/png", "a10", []byte("a10"))
+	assertBlob(t, store, "photos/org-a/1", "image/png", "a", []byte("a"))
+	assertBlob(t, store, "photos/org-b/1", "image/png", "b", []byte("b"))
+	assertBlob(t, store, "photos/org-a/10", "image/png", "a10", []byte("a10"))
+}
+
+func testBlobLarge(t *testing.T, store petstore.BlobStore) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), largeBlobBytes/16+1)[:largeBlobBytes]
+	// Hiding bytes.Reader's Len and Seek makes stores stream without knowing the size.
+	body := struct{ io.Reader }{bytes.NewReader(data)}
+	if err := store.Put(t.Context(), "exports/large.csv", petstore.BlobInfo{ContentType: "text/csv", ETag: "large"}, body); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	assertBlob(t, store, "exports/large.csv", "text/csv", "large", data)
+}
+
+func testBlobGetMissing(t *testing.T, store petstore.BlobStore) {
+	if _, _, err := store.Get(t.Context(), "photos/default/99"); !errors.Is(err, petstore.ErrBlobNotFound) {
+		t.Fatalf("Get of a missing blob: got %v, want ErrBlobNotFound", err)
+	}
+}
+
+func testBlobDeleteThenGet(t *testing.T, store petstore.BlobStore) {
+	mustPut(t, store, "photos/default/1", "image/png", "etag-1", petPNG)
+	if err := store.Delete(t.Context(), "photos/default/1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := store.Get(t.Context(), "photos/default/1"); !errors.Is(err, petstore.ErrBlobNotFound) {
+		t.Fatalf("Get after Delete: got %v, want ErrBlobNotFound", err)
+	}
+}
+
+func testBlobDeleteMissing(t *testing.T, store petstore.BlobStore) {
+	if err := store.Delete(t.Context(), "photos/default/99"); !errors.Is(err, petstore.ErrBlobNotFound) {
+		t.Fatalf("Delete of a missing blob: got %v, want ErrBlobNotFound", err)
+	}
+}
+
+// RunBlobPresignerConformanceTests checks a store that presigns downloads: the URL serves
+// the blob's bytes with its content type to a client holding no credentials, and missing
+// blobs are ErrBlobNotFound rather than a URL that 404s. newStore must return an empty
+// store on every call.
+func RunBlobPresignerConformanceTests(t *testing.T, newStore func() petstore.BlobStore) {
+	t.Helper()
+	t.Run("PresignedURLServesBlob", func(t *testing.T) {
+		store := newStore()
+		presigner := mustPresigner(t, store)
+		mustPut(t, store, "photos/default/1", "image/png", "etag-1", petPNG)
+		location, err := presigner.PresignGet(t.Context(), "photos/default/1")
+		if err != nil {
+			t.Fatalf("PresignGet: %v", err)
+		}
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, location, nil)
+		if err != nil {
+			t.Fatalf("presigned URL %q: %v", location, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET presigned URL: %v", err)
+		}
+		defer resp.Body.Close()
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading presigned URL: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(got, petPNG) {
+			t.Fatalf("GET presigned URL: status %d with %d bytes, want 200 with %d", resp.StatusCode, len(got), len(petPNG))
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+			t.Fatalf("presigned URL served Content-Type %q, want image/png", ct)
+		}
+	})
+	t.Run("PresignMissing", func(t *testing.T) {
+		presigner := mustPresigner(t, newStore())
+		if _, err := presigner.PresignGet(t.Context(), "photos/default/99"); !errors.Is(err, petstore.ErrBlobNotFound) {
+			t.Fatalf("PresignGet of a missing blob: got %v, want ErrBlobNotFound", err)
+		}
+	})
+}
+
+func mustPresigner(t *testing.T, store petstore.BlobStore) petstore.BlobPresigner {
+	t.Helper()
+	presigner, ok := store.(petstore.BlobPresigner)
+	if !ok {
+		t.Fatalf("%T does not implement petstore.BlobPresigner", store)
+	}
+	return presigner
+}
+
+func mustPut(t *testing.T, store petstore.BlobStore, key, contentType, etag string, data []byte) {
+	t.Helper()
+	if err := store.Put(t.Context(), key, petstore.BlobInfo{ContentType: contentType, ETag: etag}, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Put %s: %v", key, err)
+	}
+}
+
+func assertBlob(t *testing.T, store petstore.BlobStore, key, contentType, etag string, data []byte) {
+	t.Helper()
+	info, body, err := store.Get(t.Context(), key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", key, err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes under %s, want %d", len(got), key, len(data))
+	}
+	if info.ContentType != contentType || info.ETag != etag || info.Size != int64(len(data)) {
+		t.Fatalf("got %+v for %s, want content type %s, ETag %s, size %d", info, key, contentType, etag, len(data))
+	}
+}
+
+// RunPhotoConformanceTests checks the /pets/{petId}/photo routes end to end over a
+// repository and a blob store: a real PNG round-trips with its type and ETag, uploads
+// are judged by their bytes and capped at the configured size, and photos go away with
+// their pet and stay within its organization. newRepo and newStore must return empty ones
+// on every call.
+func RunPhotoConformanceTests(t *testing.T, newRepo func() petstore.PetRepository, newStore func() petstore.BlobStore) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, h *photoHarness)
+	}{
+		{"UploadThenDownload", testPhotoUploadThenDownload},
+		{"ConditionalDownload", testPhotoConditionalDownload},
+		{"UploadReplaces", testPhotoUploadReplaces},
+		{"UploadTooLarge", testPhotoUploadTooLarge},
+		{"UploadContentTypeMismatch", testPhotoContentTypeMismatch},
+		{"UploadNotAnImage", testPhotoNotAnImage},
+		{"PetMissing", testPhotoPetMissing},
+		{"DownloadMissing", testPhotoDownloadMissing},
+		{"DeletePhoto", testPhotoDelete},
+		{"DeletePetDeletesPhoto", testPhotoDeletedWithPet},
+		{"ScopedToOrg", testPhotoScopedToOrg},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStore()
+			repo := petstore.NewPhotoCleanupRepository(newRepo(), store, nil)
+			server := petstore.NewServer(repo, nil, petstore.WithPhotos(store, nil, petstore.PhotoOptions{MaxBytes: photoMaxBytes}))
+			tc.run(t, &photoHarness{
+				repo:    repo,
+				handler: petstore.HandlerWithOptions(server, petstore.ChiServerOptions{BaseRouter: chi.NewRouter()}),
+			})
+		})
+	}
+}
+
+type photoHarness struct {
+	repo    petstore.PetRepository
+	handler http.Handler
+}
+
+// do serves a request in ctx's organization.
+func (h *photoHarness) do(ctx context.Context, method, path, contentType string, body []byte, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func (h *photoHarness) upload(t *testing.T, ctx context.Context, id string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := h.do(ctx, http.MethodPut, "/pets/"+id+"/photo", "image/png", data)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT photo of pet %s: got %d %s, want 204", id, rec.Code, rec.Body)
+	}
+	return rec
+}
+
+func assertStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("got %d %s, want %d", rec.Code, strings.TrimSpace(rec.Body.String()), want)
+	}
+}
+
+func testPhotoUploadThenDownload(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	etag := h.upload(t, t.Context(), "1", petPNG).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("upload answered without an ETag")
+	}
+
+	rec := h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil)
+	assertStatus(t, rec, http.StatusOK)
+	if !bytes.Equal(rec.Body.Bytes(), petPNG) {
+		t.Fatalf("downloaded %d bytes, want the %d uploaded", rec.Body.Len(), len(petPNG))
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("got Content-Type %q, want image/png", got)
+	}
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Fatalf("got ETag %q, want the upload's %q", got, etag)
+	}
+	if got := rec.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private") {
+		t.Fatalf("got Cache-Control %q, want a private response", got)
+	}
+}
+
+func testPhotoConditionalDownload(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	etag := h.upload(t, t.Context(), "1", petPNG).Header().Get("ETag")
+
+	rec := h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil, "If-None-Match", `"other", `+etag)
+	assertStatus(t, rec, http.StatusNotModified)
+	if rec.Body.Len() != 0 {
+		t.Fatalf("304 carried %d bytes", rec.Body.Len())
+	}
+	rec = h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil, "If-None-Match", `"other"`)
+	assertStatus(t, rec, http.StatusOK)
+}
+
+func testPhotoUploadReplaces(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	first := h.upload(t, t.Context(), "1", petPNG).Header().Get("ETag")
+	jpeg := append([]byte{0xff, 0xd8, 0xff, 0xe0}, "not really decoded"...)
+	rec := h.do(t.Context(), http.MethodPut, "/pets/1/photo", "image/jpeg", jpeg)
+	assertStatus(t, rec, http.StatusNoContent)
+	if rec.Header().Get("ETag") == first {
+		t.Fatal("a different photo got the same ETag")
+	}
+
+	rec = h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil, "If-None-Match", first)
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Content-Type"); got != "image/jpeg" || !bytes.Equal(rec.Body.Bytes(), jpeg) {
+		t.Fatalf("got %s of %d bytes, want the replacing JPEG", got, rec.Body.Len())
+	}
+}
+
+func testPhotoUploadTooLarge(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	oversized := append(bytes.Clone(petPNG), make([]byte, photoMaxBytes)...)
+	rec := h.do(t.Context(), http.MethodPut, "/pets/1/photo", "image/png", oversized)
+	assertStatus(t, rec, http.StatusRequestEntityTooLarge)
+	assertStatus(t, h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil), http.StatusNotFound)
+}
+
+func testPhotoContentTypeMismatch(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	assertStatus(t, h.do(t.Context(), http.MethodPut, "/pets/1/photo", "image/jpeg", petPNG), http.StatusUnsupportedMediaType)
+}
+
+func testPhotoNotAnImage(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	for _, body := range [][]byte{nil, []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>"), petPNG[:4]} {
+		assertStatus(t, h.do(t.Context(), http.MethodPut, "/pets/1/photo", "image/png", body), http.StatusUnsupportedMediaType)
+	}
+}
+
+func testPhotoPetMissing(t *testing.T, h *photoHarness) {
+	assertStatus(t, h.do(t.Context(), http.MethodPut, "/pets/1/photo", "image/png", petPNG), http.StatusNotFound)
+	assertStatus(t, h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil), http.StatusNotFound)
+	assertStatus(t, h.do(t.Context(), http.MethodGet, "/pets/abc/photo", "", nil), http.StatusBadRequest)
+}
+
+func testPhotoDownloadMissing(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	assertStatus(t, h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil), http.StatusNotFound)
+	assertStatus(t, h.do(t.Context(), http.MethodDelete, "/pets/1/photo", "", nil), http.StatusNotFound)
+}
+
+func testPhotoDelete(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	h.upload(t, t.Context(), "1", petPNG)
+	assertStatus(t, h.do(t.Context(), http.MethodDelete, "/pets/1/photo", "", nil), http.StatusNoContent)
+	assertStatus(t, h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil), http.StatusNotFound)
+	if _, err := h.repo.GetPet(t.Context(), 1); err != nil {
+		t.Fatalf("GetPet after deleting its photo: %v", err)
+	}
+}
+
+func testPhotoDeletedWithPet(t *testing.T, h *photoHarness) {
+	mustCreate(t, h.repo, pet(1, "Rex", "dog"), "")
+	h.upload(t, t.Context(), "1", petPNG)
+	assertStatus(t, h.do(t.Context(), http.MethodDelete, "/pets/1", "", nil), http.StatusNoContent)
+
+	// A new pet with the same id must not inherit the photo.
+	mustCreate(t, h.repo, pet(1, "Max", "cat"), "")
+	assertStatus(t, h.do(t.Context(), http.MethodGet, "/pets/1/photo", "", nil), http.StatusNotFound)
+}
+
+func testPhotoScopedToOrg(t *testing.T, h *photoHarness) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, h.repo, pet(1, "Rex", "dog"), "")
+	mustCreateIn(t, b, h.repo, pet(1, "Tom", "cat"), "")
+	h.upload(t, a, "1", petPNG)
+
+	assertStatus(t, h.do(b, http.MethodGet, "/pets/1/photo", "", nil), http.StatusNotFound)
+	assertStatus(t, h.do(b, http.MethodDelete, "/pets/1/photo", "", nil), http.StatusNotFound)
+	if err := h.repo.DeletePet(b, 1, ""); err != nil {
+		t.Fatalf("DeletePet in %s: %v", tenant.FromContext(b), err)
+	}
+	assertStatus(t, h.do(a, http.MethodGet, "/pets/1/photo", "", nil), http.StatusOK)
+}
@@ -0,0 +1,160 @@
+package petstoretest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"demo/internal/petstore"
+)
+
+// PurgerRepository is a repository that soft-deletes pets by merging them and purges
+// them afterwards.
+type PurgerRepository interface {
+	DeduperRepository
+	petstore.Purger
+}
+
+// purgeGap separates soft-deletes on either side of a purge cutoff. The repository may
+// stamp deleted_at with its own clock, so the gap must exceed any skew between the test
+// and the database.
+const purgeGap = 100 * time.Millisecond
+
+// RunPurgeConformanceTests checks purging of soft-deleted pets: every pet past the cutoff
+// goes however the work is split into batches, nothing deleted at or after the cutoff and
+// no live pet is touched, purged ids may be reused and merged again, and the change feed
+// reports nothing new. newRepo must return an empty repository with an empty feed on every
+// call.
+func RunPurgeConformanceTests(t *testing.T, newRepo func() PurgerRepository) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo PurgerRepository)
+	}{
+		{"PurgesInBatches", testPurgesInBatches},
+		{"BatchSizeDividesBacklog", testBatchSizeDividesBacklog},
+		{"RetentionBoundary", testPurgeRetentionBoundary},
+		{"LivePetsUntouched", testPurgeLivePetsUntouched},
+		{"PurgedIDsReusable", testPurgedIDsReusable},
+		{"ChangeFeedUnchanged", testPurgeChangeFeedUnchanged},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo())
+		})
+	}
+}
+
+func testPurgesInBatches(t *testing.T, repo PurgerRepository) {
+	mergeDuplicates(t, repo, 5)
+	// Five pets in batches of two take three batches, the last one short.
+	assertPurged(t, repo, time.Now().Add(time.Minute), 2, 5)
+	assertPurged(t, repo, time.Now().Add(time.Minute), 2, 0)
+	assertListed(t, repo, 1)
+}
+
+func testBatchSizeDividesBacklog(t *testing.T, repo PurgerRepository) {
+	mergeDuplicates(t, repo, 4)
+	// Two full batches, then an empty one to notice the backlog is gone.
+	assertPurged(t, repo, time.Now().Add(time.Minute), 2, 4)
+	assertListed(t, repo, 1)
+}
+
+func testPurgeRetentionBoundary(t *testing.T, repo PurgerRepository) {
+	ctx := t.Context()
+	start := time.Now().Add(-purgeGap)
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), "")
+	mustCreate(t, repo, pet(3, "Max", "cat"), "")
+	mustCreate(t, repo, pet(4, "max", "cat"), "")
+	if _, err := repo.MergePets(ctx, 1, []int64{2}, "", ""); err != nil {
+		t.Fatalf("MergePets 2 into 1: %v", err)
+	}
+	time.Sleep(purgeGap)
+	cutoff := time.Now()
+	time.Sleep(purgeGap)
+	if _, err := repo.MergePets(ctx, 3, []int64{4}, "", ""); err != nil {
+		t.Fatalf("MergePets 4 into 3: %v", err)
+	}
+
+	assertPurged(t, repo, start, 10, 0)
+	assertPurged(t, repo, cutoff, 10, 1)
+	// Pet 2 is gone for good, so its id is free; pet 4 is still soft-deleted and holds its.
+	mustCreate(t, repo, pet(2, "Tom", "cat"), "")
+	if err := repo.CreatePet(ctx, pet(4, "Tom", "cat"), ""); !errors.Is(err, petstore.ErrPetExists) {
+		t.Fatalf("CreatePet of a pet deleted after the cutoff: got %v, want ErrPetExists", err)
+	}
+}
+
+func testPurgeLivePetsUntouched(t *testing.T, repo PurgerRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "Max", "cat"), "")
+	assertPurged(t, repo, time.Now().Add(time.Minute), 10, 0)
+	assertListed(t, repo, 1, 2)
+}
+
+func testPurgedIDsReusable(t *testing.T, repo PurgerRepository) {
+	ctx := t.Context()
+	mergeDuplicates(t, repo, 1)
+	assertPurged(t, repo, time.Now().Add(time.Minute), 10, 1)
+
+	// The merge record went with the pet, so the new pet 2 can be merged in turn.
+	mustCreate(t, repo, pet(2, "REX", "dog"), "")
+	if _, err := repo.MergePets(ctx, 1, []int64{2}, "", ""); err != nil {
+		t.Fatalf("MergePets of a reused id: %v", err)
+	}
+	assertListed(t, repo, 1)
+}
+
+func testPurgeChangeFeedUnchanged(t *testing.T, repo PurgerRepository) {
+	mergeDuplicates(t, repo, 2)
+	// Three creates and a tombstone for each duplicate.
+	changes := collectChanges(t, repo, 0, 5, 100)
+	assertPurged(t, repo, time.Now().Add(time.Minute), 10, 2)
+
+	after, err := repo.ListChanges(t.Context(), changes[len(changes)-1].Seq, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	if len(after) != 0 {
+		t.Fatalf("got changes %+v after the purge, want none", after)
+	}
+}
+
+// mergeDuplicates creates pet 1 and n duplicates of it, ids 2 to n+1, and merges them into
+// pet 1, soft-deleting the duplicates.
+func mergeDuplicates(t *testing.T, repo PurgerRepository, n int) {
+	t.Helper()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	duplicates := make([]int64, n)
+	for i := range duplicates {
+		duplicates[i] = int64(i) + 2
+		mustCreate(t, repo, pet(duplicates[i], "rex", "dog"), "")
+	}
+	if _, err := repo.MergePets(t.Context(), 1, duplicates, "", ""); err != nil {
+		t.Fatalf("MergePets: %v", err)
+	}
+}
+
+func assertPurged(t *testing.T, repo PurgerRepository, before time.Time, batchSize int, want int64) {
+	t.Helper()
+	n, err := repo.PurgeDeletedPets(t.Context(), before, batchSize)
+	if err != nil {
+		t.Fatalf("PurgeDeletedPets: %v", err)
+	}
+	if n != want {
+		t.Fatalf("purged %d pets before %s in batches of %d, want %d", n, before.Format(time.RFC3339Nano), batchSize, want)
+	}
+}
+
+// assertListed checks that exactly the pets with the given ids are live.
+func assertListed(t *testing.T, repo PurgerRepository, want ...int64) {
+	t.Helper()
+	got, err := repo.ListPets(t.Context(), 0, "")
+	if err != nil {
+		t.Fatalf("ListPets: %v", err)
+	}
+	if !slices.Equal(ids(got), want) {
+		t.Fatalf("got pets %v, want %v", ids(got), want)
+	}
+}
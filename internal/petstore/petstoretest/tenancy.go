@@ -0,0 +1,232 @@
+package petstoretest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"demo/internal/petstore"
+	"demo/internal/tenant"
+)
+
+const (
+	orgA = "org-a"
+	orgB = "org-b"
+)
+
+// RunTenancyConformanceTests checks that a repository keeps organizations apart: the
+// same id may exist in two organizations, a pet is invisible and untouchable from any
+// organization but its own, and listings, the change feed, duplicate groups, and merges
+// only ever see the organization on the context. newRepo must return an empty repository
+// with an empty feed on every call.
+func RunTenancyConformanceTests(t *testing.T, newRepo func() DeduperRepository) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo DeduperRepository)
+	}{
+		{"SameIDPerOrg", testSameIDPerOrg},
+		{"GetAcrossOrgs", testGetAcrossOrgs},
+		{"UpdateAcrossOrgs", testUpdateAcrossOrgs},
+		{"DeleteAcrossOrgs", testDeleteAcrossOrgs},
+		{"ListScopedToOrg", testListScopedToOrg},
+		{"ListAfterScopedToOrg", testListAfterScopedToOrg},
+		{"DefaultOrgWithoutContext", testDefaultOrgWithoutContext},
+		{"ChangeFeedScopedToOrg", testChangeFeedScopedToOrg},
+		{"DuplicatesScopedToOrg", testDuplicatesScopedToOrg},
+		{"MergeAcrossOrgs", testMergeAcrossOrgs},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo())
+		})
+	}
+}
+
+func testSameIDPerOrg(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), "")
+	mustCreateIn(t, b, repo, pet(1, "Tom", "cat"), "")
+
+	got, err := repo.GetPet(a, 1)
+	if err != nil {
+		t.Fatalf("GetPet in %s: %v", orgA, err)
+	}
+	assertPet(t, got, pet(1, "Rex", "dog"))
+	if got, err = repo.GetPet(b, 1); err != nil {
+		t.Fatalf("GetPet in %s: %v", orgB, err)
+	}
+	assertPet(t, got, pet(1, "Tom", "cat"))
+
+	if err := repo.CreatePet(a, pet(1, "Max", "dog"), ""); !errors.Is(err, petstore.ErrPetExists) {
+		t.Fatalf("second CreatePet in %s: got %v, want ErrPetExists", orgA, err)
+	}
+}
+
+func testGetAcrossOrgs(t *testing.T, repo DeduperRepository) {
+	mustCreateIn(t, inOrg(t, orgA), repo, pet(1, "Rex", "dog"), ownerA)
+	for _, ctx := range []context.Context{inOrg(t, orgB), t.Context()} {
+		if _, err := repo.GetPet(ctx, 1); !errors.Is(err, petstore.ErrPetNotFound) {
+			t.Fatalf("GetPet in %s: got %v, want ErrPetNotFound", tenant.FromContext(ctx), err)
+		}
+	}
+}
+
+func testUpdateAcrossOrgs(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), ownerA)
+	if err := repo.UpdatePet(b, pet(1, "Max", "cat"), ""); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("UpdatePet in %s: got %v, want ErrPetNotFound", orgB, err)
+	}
+	if err := repo.UpdatePet(b, pet(1, "Max", "cat"), ownerA); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("owner-restricted UpdatePet in %s: got %v, want ErrPetNotFound", orgB, err)
+	}
+	got, err := repo.GetPet(a, 1)
+	if err != nil {
+		t.Fatalf("GetPet: %v", err)
+	}
+	assertPet(t, got, pet(1, "Rex", "dog"))
+}
+
+func testDeleteAcrossOrgs(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), ownerA)
+	if err := repo.DeletePet(b, 1, ""); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("DeletePet in %s: got %v, want ErrPetNotFound", orgB, err)
+	}
+	if _, err := repo.GetPet(a, 1); err != nil {
+		t.Fatalf("GetPet after a DeletePet in another org: %v", err)
+	}
+}
+
+func testListScopedToOrg(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), ownerA)
+	mustCreateIn(t, a, repo, pet(3, "Max", "dog"), ownerB)
+	mustCreateIn(t, b, repo, pet(2, "Tom", "cat"), ownerA)
+
+	got, err := repo.ListPets(a, 0, "")
+	if err != nil {
+		t.Fatalf("ListPets in %s: %v", orgA, err)
+	}
+	assertPets(t, got, []petstore.Pet{pet(1, "Rex", "dog"), pet(3, "Max", "dog")})
+	if got, err = repo.ListPets(b, 0, ownerA); err != nil {
+		t.Fatalf("ListPets in %s: %v", orgB, err)
+	}
+	assertPets(t, got, []petstore.Pet{pet(2, "Tom", "cat")})
+	if got, err = repo.ListPets(t.Context(), 0, ""); err != nil {
+		t.Fatalf("ListPets in %s: %v", tenant.DefaultOrg, err)
+	}
+	assertPets(t, got, nil)
+}
+
+func testListAfterScopedToOrg(t *testing.T, repo DeduperRepository) {
+	pager, ok := petstore.PetRepository(repo).(interface {
+		ListPetsAfter(ctx context.Context, after int64, limit int32, ownedBy string) ([]petstore.Pet, error)
+	})
+	if !ok {
+		t.Skip("repository does not page by keyset")
+	}
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	for id := int64(1); id <= 4; id++ {
+		ctx := a
+		if id%2 == 0 {
+			ctx = b
+		}
+		mustCreateIn(t, ctx, repo, pet(id, "Rex", "dog"), "")
+	}
+	got, err := pager.ListPetsAfter(b, 1, 10, "")
+	if err != nil {
+		t.Fatalf("ListPetsAfter in %s: %v", orgB, err)
+	}
+	assertPets(t, got, []petstore.Pet{pet(2, "Rex", "dog"), pet(4, "Rex", "dog")})
+}
+
+func testDefaultOrgWithoutContext(t *testing.T, repo DeduperRepository) {
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	got, err := repo.GetPet(inOrg(t, tenant.DefaultOrg), 1)
+	if err != nil {
+		t.Fatalf("GetPet in %s: %v", tenant.DefaultOrg, err)
+	}
+	assertPet(t, got, pet(1, "Rex", "dog"))
+	if _, err := repo.GetPet(inOrg(t, orgA), 1); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("GetPet in %s: got %v, want ErrPetNotFound", orgA, err)
+	}
+}
+
+func testChangeFeedScopedToOrg(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), "")
+	mustCreateIn(t, b, repo, pet(1, "Tom", "cat"), "")
+	mustCreateIn(t, b, repo, pet(2, "Max", "dog"), "")
+	if err := repo.DeletePet(a, 1, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+
+	changes := collectChangesIn(t, b, repo, 0, 2, 100)
+	for i, want := range []petstore.Pet{pet(1, "Tom", "cat"), pet(2, "Max", "dog")} {
+		if changes[i].Op != petstore.Create || changes[i].Payload == nil {
+			t.Fatalf("got change %+v in %s, want a create", changes[i], orgB)
+		}
+		assertPet(t, *changes[i].Payload, want)
+	}
+	if rest, err := repo.ListChanges(b, changes[1].Seq, 100); err != nil || len(rest) != 0 {
+		t.Fatalf("got %d more changes in %s (err %v), want none from %s", len(rest), orgB, err, orgA)
+	}
+
+	changes = collectChangesIn(t, a, repo, 0, 2, 100)
+	if changes[0].Op != petstore.Create || changes[1].Op != petstore.Delete || changes[1].PetId != 1 {
+		t.Fatalf("got changes %+v in %s, want the create and delete of pet 1", changes, orgA)
+	}
+}
+
+func testDuplicatesScopedToOrg(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), "")
+	mustCreateIn(t, b, repo, pet(2, "rex", "dog"), "")
+	mustCreateIn(t, b, repo, pet(3, "REX", "dog"), "")
+
+	groups, err := repo.ListDuplicates(a, 10)
+	if err != nil {
+		t.Fatalf("ListDuplicates in %s: %v", orgA, err)
+	}
+	if len(groups) != 0 {
+		t.Fatalf("got %+v in %s, want no groups across organizations", groups, orgA)
+	}
+	if groups, err = repo.ListDuplicates(b, 10); err != nil {
+		t.Fatalf("ListDuplicates in %s: %v", orgB, err)
+	}
+	if len(groups) != 1 || len(groups[0].PetIds) != 2 {
+		t.Fatalf("got %+v in %s, want one group of pets 2 and 3", groups, orgB)
+	}
+}
+
+func testMergeAcrossOrgs(t *testing.T, repo DeduperRepository) {
+	a, b := inOrg(t, orgA), inOrg(t, orgB)
+	mustCreateIn(t, a, repo, pet(1, "Rex", "dog"), "")
+	mustCreateIn(t, b, repo, pet(2, "rex", "dog"), "")
+	if _, err := repo.MergePets(a, 1, []int64{2}, "", ""); !errors.Is(err, petstore.ErrPetNotFound) {
+		t.Fatalf("MergePets across organizations: got %v, want ErrPetNotFound", err)
+	}
+	if _, err := repo.GetPet(b, 2); err != nil {
+		t.Fatalf("GetPet after a failed cross-organization merge: %v", err)
+	}
+
+	mustCreateIn(t, a, repo, pet(2, "REX", "dog"), "")
+	if _, err := repo.MergePets(a, 1, []int64{2}, "", ""); err != nil {
+		t.Fatalf("MergePets in %s: %v", orgA, err)
+	}
+	if _, err := repo.GetPet(b, 2); err != nil {
+		t.Fatalf("GetPet of the same id in %s after a merge in %s: %v", orgB, orgA, err)
+	}
+}
+
+func inOrg(t *testing.T, org string) context.Context {
+	return tenant.WithOrg(t.Context(), org)
+}
+
+func mustCreateIn(t *testing.T, ctx context.Context, repo petstore.PetRepository, p petstore.Pet, owner string) {
+	t.Helper()
+	if err := repo.CreatePet(ctx, p, owner); err != nil {
+		t.Fatalf("CreatePet %d in %s: %v", p.Id, tenant.FromContext(ctx), err)
+	}
+}
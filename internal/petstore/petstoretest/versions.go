@@ -0,0 +1,278 @@
+package petstoretest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"demo/internal/petstore"
+)
+
+// VersionedRepository is a repository that versions each organization's pets and pages
+// them for envelope lists.
+type VersionedRepository interface {
+	PurgerRepository
+	petstore.Pager
+	petstore.CollectionVersions
+}
+
+// RunCollectionVersionConformanceTests checks the pets version behind list ETags: every
+// write a list would show raises it, failed writes and purges of soft-deleted pets leave
+// it alone, and organizations have versions of their own. newRepo must return an empty
+// repository on every call.
+func RunCollectionVersionConformanceTests(t *testing.T, newRepo func() VersionedRepository) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo VersionedRepository)
+	}{
+		{"RaisedByWrites", testVersionRaisedByWrites},
+		{"RaisedByMerge", testVersionRaisedByMerge},
+		{"FailedWritesKeepVersion", testFailedWritesKeepVersion},
+		{"PurgeKeepsVersion", testPurgeKeepsVersion},
+		{"ScopedToOrg", testVersionScopedToOrg},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo())
+		})
+	}
+}
+
+func testVersionRaisedByWrites(t *testing.T, repo VersionedRepository) {
+	ctx := t.Context()
+	v := mustVersion(t, ctx, repo)
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	v = assertVersionRaised(t, ctx, repo, v, "create")
+	if err := repo.UpdatePet(ctx, pet(1, "Max", "dog"), ""); err != nil {
+		t.Fatalf("UpdatePet: %v", err)
+	}
+	v = assertVersionRaised(t, ctx, repo, v, "update")
+	if err := repo.DeletePet(ctx, 1, ""); err != nil {
+		t.Fatalf("DeletePet: %v", err)
+	}
+	assertVersionRaised(t, ctx, repo, v, "delete")
+}
+
+func testVersionRaisedByMerge(t *testing.T, repo VersionedRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, repo, pet(2, "rex", "dog"), "")
+	v := mustVersion(t, ctx, repo)
+	if _, err := repo.MergePets(ctx, 1, []int64{2}, "", ""); err != nil {
+		t.Fatalf("MergePets: %v", err)
+	}
+	assertVersionRaised(t, ctx, repo, v, "merge")
+}
+
+func testFailedWritesKeepVersion(t *testing.T, repo VersionedRepository) {
+	ctx := t.Context()
+	mustCreate(t, repo, pet(1, "Rex", "dog"), "")
+	v := mustVersion(t, ctx, repo)
+	_ = repo.CreatePet(ctx, pet(1, "Max", "cat"), "")
+	_ = repo.UpdatePet(ctx, pet(2, "Max", "cat"), "")
+	_ = repo.DeletePet(ctx, 2, "")
+	_ = repo.DeletePet(ctx, 1, "someone-else")
+	assertVersion(t, ctx, repo, v, "failed writes")
+}
+
+func testPurgeKeepsVersion(t *testing.T, repo VersionedRepository) {
+	ctx := t.Context()
+	mergeDuplicates(t, repo, 2)
+	v := mustVersion(t, ctx, repo)
+	assertPurged(t, repo, time.Now().Add(time.Minute), 10, 2)
+	assertVersion(t, ctx, repo, v, "a purge of soft-deleted pets")
+}
+
+func testVersionScopedToOrg(t *testing.T, repo VersionedRepository) {
+	acme, globex := inOrg(t, "acme"), inOrg(t, "globex")
+	v := mustVersion(t, globex, repo)
+	mustCreateIn(t, acme, repo, pet(1, "Rex", "dog"), "")
+	assertVersion(t, globex, repo, v, "a write to another organization")
+}
+
+func mustVersion(t *testing.T, ctx context.Context, versions petstore.CollectionVersions) int64 {
+	t.Helper()
+	v, err := versions.PetsVersion(ctx)
+	if err != nil {
+		t.Fatalf("PetsVersion: %v", err)
+	}
+	return v
+}
+
+func assertVersionRaised(t *testing.T, ctx context.Context, versions petstore.CollectionVersions, before int64, after string) int64 {
+	t.Helper()
+	v := mustVersion(t, ctx, versions)
+	if v <= before {
+		t.Fatalf("version %d after %s, want more than %d", v, after, before)
+	}
+	return v
+}
+
+func assertVersion(t *testing.T, ctx context.Context, versions petstore.CollectionVersions, want int64, after string) {
+	t.Helper()
+	if v := mustVersion(t, ctx, versions); v != want {
+		t.Fatalf("version %d after %s, want %d unchanged", v, after, want)
+	}
+}
+
+// RunListETagConformanceTests drives GET /pets on two servers standing in for replicas,
+// each with its own VersionCache over one shared repository: a repeated list answers 304,
+// a write through either replica turns the next conditional list into a 200 with a new
+// ETag, both replicas issue the same ETag for the same request, and queries the version
+// does not cover get none. refresh is passed to both caches and must be short enough for
+// the test to wait out. newRepo must return an empty repository on every call.
+func RunListETagConformanceTests(t *testing.T, newRepo func() VersionedRepository, refresh time.Duration) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, a, b *etagReplica)
+	}{
+		{"Hit", testListETagHit},
+		{"MissAfterWrite", testListETagMissAfterWrite},
+		{"MissAfterWriteElsewhere", testListETagMissAfterWriteElsewhere},
+		{"ReplicasAgree", testListETagReplicasAgree},
+		{"VariantsDiffer", testListETagVariantsDiffer},
+		{"UncoveredQuery", testListETagUncoveredQuery},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newRepo()
+			tc.run(t, newETagReplica(repo, refresh), newETagReplica(repo, refresh))
+		})
+	}
+}
+
+// etagReplica is one instance: a VersionCache in front of the shared repository and the
+// API served over it.
+type etagReplica struct {
+	repo    *petstore.VersionCache
+	handler http.Handler
+	refresh time.Duration
+}
+
+func newETagReplica(repo VersionedRepository, refresh time.Duration) *etagReplica {
+	cache := petstore.NewVersionCache(repo, repo, refresh)
+	server := petstore.NewServer(cache, nil, petstore.WithPager(repo), petstore.WithListETags(cache))
+	return &etagReplica{
+		repo:    cache,
+		handler: petstore.HandlerWithOptions(server, petstore.ChiServerOptions{BaseRouter: chi.NewRouter()}),
+		refresh: refresh,
+	}
+}
+
+// list serves GET target, with header given as name, value pairs.
+func (r *etagReplica) list(t *testing.T, target string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	r.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// etag lists target and returns the weak ETag of the 200.
+func (r *etagReplica) etag(t *testing.T, target string, header ...string) string {
+	t.Helper()
+	rec := r.list(t, target, header...)
+	assertStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("GET %s: got ETag %q, want a weak one", target, etag)
+	}
+	return etag
+}
+
+func testListETagHit(t *testing.T, a, _ *etagReplica) {
+	mustCreate(t, a.repo, pet(1, "Rex", "dog"), "")
+	etag := a.etag(t, "/pets")
+
+	rec := a.list(t, "/pets", "If-None-Match", `W/"other", `+etag)
+	assertStatus(t, rec, http.StatusNotModified)
+	if rec.Body.Len() != 0 {
+		t.Fatalf("304 carried %d bytes", rec.Body.Len())
+	}
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Fatalf("304 carried ETag %q, want %q", got, etag)
+	}
+	// A strong comparison of the same tag also matches, as If-None-Match compares weakly.
+	assertStatus(t, a.list(t, "/pets", "If-None-Match", strings.TrimPrefix(etag, "W/")), http.StatusNotModified)
+}
+
+func testListETagMissAfterWrite(t *testing.T, a, _ *etagReplica) {
+	mustCreate(t, a.repo, pet(1, "Rex", "dog"), "")
+	etag := a.etag(t, "/pets")
+	mustCreate(t, a.repo, pet(2, "Max", "cat"), "")
+
+	// The write went through a, so a notices at once, however long its refresh.
+	rec := a.list(t, "/pets", "If-None-Match", etag)
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("ETag"); got == etag || got == "" {
+		t.Fatalf("got ETag %q after a write, want a new one", got)
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte("Max")) {
+		t.Fatalf("list after a write misses the new pet: %s", rec.Body)
+	}
+}
+
+func testListETagMissAfterWriteElsewhere(t *testing.T, a, b *etagReplica) {
+	mustCreate(t, a.repo, pet(1, "Rex", "dog"), "")
+	etag := a.etag(t, "/pets")
+	mustCreate(t, b.repo, pet(2, "Max", "cat"), "")
+
+	// a learns of b's write from the shared version once its own has aged out.
+	time.Sleep(a.refresh)
+	rec := a.list(t, "/pets", "If-None-Match", etag)
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("ETag"); got == etag {
+		t.Fatalf("got ETag %q after a write through another replica, want a new one", got)
+	}
+}
+
+func testListETagReplicasAgree(t *testing.T, a, b *etagReplica) {
+	mustCreate(t, a.repo, pet(1, "Rex", "dog"), "")
+	time.Sleep(b.refresh)
+	etag := a.etag(t, "/pets?limit=10")
+	if got := b.etag(t, "/pets?limit=10"); got != etag {
+		t.Fatalf("replicas disagree: got ETags %q and %q", etag, got)
+	}
+	// So a client may revalidate against whichever replica it reaches.
+	assertStatus(t, b.list(t, "/pets?limit=10", "If-None-Match", etag), http.StatusNotModified)
+}
+
+func testListETagVariantsDiffer(t *testing.T, a, _ *etagReplica) {
+	mustCreate(t, a.repo, pet(1, "Rex", "dog"), "")
+	mustCreate(t, a.repo, pet(2, "Max", "cat"), "")
+	seen := make(map[string]string)
+	for _, variant := range []struct {
+		target string
+		header []string
+	}{
+		{"/pets", nil},
+		{"/pets?limit=1", nil},
+		{"/pets?envelope=true", nil},
+		{"/pets", []string{"Accept", "application/xml"}},
+	} {
+		etag := a.etag(t, variant.target, variant.header...)
+		name := variant.target + " " + strings.Join(variant.header, ": ")
+		if other, ok := seen[etag]; ok {
+			t.Fatalf("%s and %s share ETag %s", other, name, etag)
+		}
+		seen[etag] = name
+	}
+}
+
+func testListETagUncoveredQuery(t *testing.T, a, _ *etagReplica) {
+	mustCreate(t, a.repo, pet(1, "Rex", "dog"), "")
+	rec := a.list(t, "/pets?q=rex", "If-None-Match", "*")
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("ETag"); got != "" {
+		t.Fatalf("got ETag %q for a query the version does not cover, want none", got)
+	}
+}
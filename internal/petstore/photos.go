@@ -0,0 +1,352 @@
+package petstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo/internal/apierr"
+	"demo/internal/tenant"
+)
+
+// ErrBlobNotFound indicates a BlobStore holds nothing under the requested key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	ContentType string
+	// ETag identifies the content, unquoted; the writer chooses it and stores keep it.
+	ETag string
+	// Size and ModTime are filled in by the store on Get.
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore holds binary objects such as pet photos by key; internal/blob implements it
+// on PostgreSQL and on a local directory. Put replaces any blob under the key, and readers
+// see either the old blob or the new one, never a mix.
+type BlobStore interface {
+	Put(ctx context.Context, key string, info BlobInfo, body io.Reader) error
+	// Get returns the blob under key, or ErrBlobNotFound; the caller closes it.
+	Get(ctx context.Context, key string) (BlobInfo, io.ReadCloser, error)
+	// Delete removes the blob under key, or returns ErrBlobNotFound.
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobPresigner is implemented by blob stores that can hand out short-lived URLs for a
+// blob, so downloads can be redirected to the store instead of passing through the server.
+type BlobPresigner interface {
+	// PresignGet returns a URL serving the blob under key, or ErrBlobNotFound.
+	PresignGet(ctx context.Context, key string) (string, error)
+}
+
+// PetOwnerLookup reports who created each pet, for owner checks on writes that do not go
+// through PetRepository; PostgresRepository implements it.
+type PetOwnerLookup interface {
+	PetOwners(ctx context.Context, ids []int64) (map[int64]string, error)
+}
+
+// PhotoOptions configures the /pets/{petId}/photo routes.
+type PhotoOptions struct {
+	// MaxBytes caps uploads; larger ones get 413.
+	MaxBytes int64
+	// CacheMaxAge is the max-age clients may reuse a downloaded photo for.
+	CacheMaxAge time.Duration
+	// Redirect answers downloads with a redirect to a presigned URL when the store is a
+	// BlobPresigner, rather than streaming the photo.
+	Redirect bool
+}
+
+// defaultPhotoMaxBytes applies when PhotoOptions.MaxBytes is not positive.
+const defaultPhotoMaxBytes = 5 << 20
+
+// WithPhotos enables the /pets/{petId}/photo routes, keeping photos in store. owners
+// enforces WithOwnerChecks on uploads and deletes.
+func WithPhotos(store BlobStore, owners PetOwnerLookup, opts PhotoOptions) ServerOption {
+	return func(s *Server) {
+		if opts.MaxBytes <= 0 {
+			opts.MaxBytes = defaultPhotoMaxBytes
+		}
+		s.photos = store
+		s.photoOwners = owners
+		s.photoOpts = opts
+	}
+}
+
+// photoKey is where the photo of the pet id in ctx's organization is stored.
+func photoKey(ctx context.Context, id int64) string {
+	return "photos/" + tenant.FromContext(ctx) + "/" + strconv.FormatInt(id, 10)
+}
+
+// photoSignatures are the leading bytes of the image formats photos may be in.
+var photoSignatures = []struct {
+	contentType string
+	magic       []byte
+}{
+	{"image/png", []byte("\x89PNG\r\n\x1a\n")},
+	{"image/jpeg", []byte{0xff, 0xd8, 0xff}},
+}
+
+// photoContentType returns the image type data starts with, or "" when it is neither a
+// PNG nor a JPEG.
+func photoContentType(data []byte) string {
+	for _, sig := range photoSignatures {
+		if bytes.HasPrefix(data, sig.magic) {
+			return sig.contentType
+		}
+	}
+	return ""
+}
+
+// UploadPetPhoto stores the request body as the pet's photo, replacing any earlier one. The
+// body must be a PNG or JPEG, judged by its leading bytes, and Content-Type must agree.
+func (s *Server) UploadPetPhoto(w http.ResponseWriter, r *http.Request, petId string) {
+	id, ok := s.photoPet(w, r, "UploadPetPhoto", petId, true)
+	if !ok {
+		return
+	}
+
+	defer r.Body.Close()
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.photoOpts.MaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.logger.InfoContext(r.Context(), "UploadPetPhoto: body too large", "limit", tooLarge.Limit)
+			s.writeError(w, r, apierr.ErrBodyTooLarge.With(msgBodyTooLarge, "limit", tooLarge.Limit))
+			return
+		}
+		s.logger.InfoContext(r.Context(), "UploadPetPhoto: read error", "error", err)
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgPhotoUnreadable))
+		return
+	}
+	contentType := photoContentType(data)
+	declared, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "" || declared != contentType {
+		s.logger.InfoContext(r.Context(), "UploadPetPhoto: unsupported type", "pet_id", id, "content_type", declared, "detected", contentType)
+		s.writeError(w, r, apierr.ErrUnsupportedMediaType.With(msgPhotoUnsupportedType))
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	info := BlobInfo{ContentType: contentType, ETag: hex.EncodeToString(sum[:])}
+	if err := s.photos.Put(r.Context(), photoKey(r.Context(), id), info, bytes.NewReader(data)); err != nil {
+		s.writePhotoError(w, r, "UploadPetPhoto", err, msgStorePhotoFailed)
+		return
+	}
+
+	s.audit(r, "UploadPetPhoto", id, "bytes", len(data), "content_type", contentType)
+	w.Header().Set("ETag", strconv.Quote(info.ETag))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ShowPetPhoto streams the pet's photo, or answers 304 when If-None-Match names it. With
+// PhotoOptions.Redirect it redirects to the store instead.
+func (s *Server) ShowPetPhoto(w http.ResponseWriter, r *http.Request, petId string) {
+	id, ok := s.photoPet(w, r, "ShowPetPhoto", petId, false)
+	if !ok {
+		return
+	}
+	if presigner, ok := s.photos.(BlobPresigner); ok && s.photoOpts.Redirect {
+		s.redirectPetPhoto(w, r, presigner, id)
+		return
+	}
+
+	info, body, err := s.photos.Get(r.Context(), photoKey(r.Context(), id))
+	if err != nil {
+		s.writePhotoError(w, r, "ShowPetPhoto", err, msgFetchPhotoFailed)
+		return
+	}
+	defer body.Close()
+
+	etag := strconv.Quote(info.ETag)
+	h := w.Header()
+	h.Set("ETag", etag)
+	// Photos are only visible within their organization, so shared caches must not keep them.
+	h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(s.photoOpts.CacheMaxAge.Seconds())))
+	if !info.ModTime.IsZero() {
+		h.Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", info.ContentType)
+	h.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		s.logger.WarnContext(r.Context(), "ShowPetPhoto: copy error", "pet_id", id, "error", err)
+	}
+}
+
+// redirectPetPhoto sends the client to a presigned URL for the photo. The URL expires, so
+// the redirect itself must not be cached.
+func (s *Server) redirectPetPhoto(w http.ResponseWriter, r *http.Request, presigner BlobPresigner, id int64) {
+	location, err := presigner.PresignGet(r.Context(), photoKey(r.Context(), id))
+	if err != nil {
+		s.writePhotoError(w, r, "ShowPetPhoto", err, msgFetchPhotoFailed)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// DeletePetPhoto removes the pet's photo.
+func (s *Server) DeletePetPhoto(w http.ResponseWriter, r *http.Request, petId string) {
+	id, ok := s.photoPet(w, r, "DeletePetPhoto", petId, true)
+	if !ok {
+		return
+	}
+
+	if err := s.photos.Delete(r.Context(), photoKey(r.Context(), id)); err != nil {
+		s.writePhotoError(w, r, "DeletePetPhoto", err, msgDeletePhotoFailed)
+		return
+	}
+
+	s.audit(r, "DeletePetPhoto", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// photoPet resolves petId to a pet the caller may see, and for writes may change, writing
+// the error response and reporting false when there is none.
+func (s *Server) photoPet(w http.ResponseWriter, r *http.Request, op, petId string, write bool) (int64, bool) {
+	if s.photos == nil {
+		s.writeError(w, r, apierr.ErrNotFound.With(msgPhotosDisabled))
+		return 0, false
+	}
+	id, err := strconv.ParseInt(petId, 10, 64)
+	if err != nil {
+		s.logger.InfoContext(r.Context(), op+": invalid petId", "pet_id", petId, "error", err)
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidPetID))
+		return 0, false
+	}
+
+	if _, err := s.repo.GetPet(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, ErrPetNotFound):
+			s.logger.InfoContext(r.Context(), op+": pet not found", "pet_id", id)
+			s.writeError(w, r, apierr.ErrPetNotFound)
+		case s.writeCircuitOpen(w, r, op, err):
+		case isTimeout(err):
+			s.logger.WarnContext(r.Context(), op+": repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+		default:
+			s.logger.ErrorContext(r.Context(), op+": repo error", "error", err)
+			s.writeError(w, r, apierr.ErrInternal.With(msgFetchPetFailed))
+		}
+		return 0, false
+	}
+
+	ownedBy := s.requiredOwner(r)
+	if !write || ownedBy == "" || s.photoOwners == nil {
+		return id, true
+	}
+	owners, err := s.photoOwners.PetOwners(r.Context(), []int64{id})
+	if err != nil {
+		s.writePhotoError(w, r, op, err, msgFetchPetFailed)
+		return 0, false
+	}
+	if owners[id] != ownedBy {
+		s.logger.InfoContext(r.Context(), op+": not owner", "pet_id", id)
+		s.writeError(w, r, apierr.ErrNotPetOwner)
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *Server) writePhotoError(w http.ResponseWriter, r *http.Request, op string, err error, failed string) {
+	switch {
+	case errors.Is(err, ErrBlobNotFound):
+		s.logger.InfoContext(r.Context(), op+": photo not found")
+		s.writeError(w, r, apierr.ErrPhotoNotFound)
+	case isTimeout(err):
+		s.logger.WarnContext(r.Context(), op+": store timeout", "error", err)
+		s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+	default:
+		s.logger.ErrorContext(r.Context(), op+": store error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(failed))
+	}
+}
+
+// etagMatches reports whether an If-None-Match header names etag, comparing weakly as
+// RFC 9110 asks for GET.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// PhotoCleanupRepository deletes a pet's photo when the pet is deleted, whichever API the
+// delete came through, so a pet created later with the same id does not inherit it.
+type PhotoCleanupRepository struct {
+	next   PetRepository
+	photos BlobStore
+	logger *slog.Logger
+}
+
+// NewPhotoCleanupRepository wraps next so deletes also remove photos from photos.
+func NewPhotoCleanupRepository(next PetRepository, photos BlobStore, logger *slog.Logger) *PhotoCleanupRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &PhotoCleanupRepository{next: next, photos: photos, logger: logger}
+}
+
+// ListPets passes through.
+func (p *PhotoCleanupRepository) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	return p.next.ListPets(ctx, limit, ownedBy)
+}
+
+// GetPet passes through.
+func (p *PhotoCleanupRepository) GetPet(ctx context.Context, id int64) (Pet, error) {
+	return p.next.GetPet(ctx, id)
+}
+
+// CreatePet passes through.
+func (p *PhotoCleanupRepository) CreatePet(ctx context.Context, pet Pet, owner string) error {
+	return p.next.CreatePet(ctx, pet, owner)
+}
+
+// UpdatePet passes through.
+func (p *PhotoCleanupRepository) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
+	return p.next.UpdatePet(ctx, pet, ownedBy)
+}
+
+// DeletePet deletes the pet and then its photo. A photo that fails to delete is logged
+// rather than failing the delete, which has already happened.
+func (p *PhotoCleanupRepository) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	if err := p.next.DeletePet(ctx, id, ownedBy); err != nil {
+		return err
+	}
+	deletePhotos(ctx, p.photos, p.logger, id)
+	return nil
+}
+
+// deletePhotos removes the photos of deleted pets in ctx's organization, if they have any.
+func deletePhotos(ctx context.Context, photos BlobStore, logger *slog.Logger, ids ...int64) {
+	// Use a detached context so a cancelled request still removes the photos.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	for _, id := range ids {
+		if err := photos.Delete(ctx, photoKey(ctx, id)); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			logger.WarnContext(ctx, "photo_delete_failed", "pet_id", id, "error", err)
+		}
+	}
+}
+
+var _ PetRepository = (*PhotoCleanupRepository)(nil)
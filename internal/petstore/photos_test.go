@@ -0,0 +1,37 @@
+package petstore_test
+
+import (
+	"testing"
+
+	"demo/internal/blob"
+	"demo/internal/database/databasetest"
+	"demo/internal/petstore"
+	"demo/internal/petstore/petstoretest"
+)
+
+func TestPhotosOverMemoryRepository(t *testing.T) {
+	petstoretest.RunPhotoConformanceTests(t,
+		func() petstore.PetRepository { return petstore.NewMemoryRepository() },
+		func() petstore.BlobStore {
+			store, err := blob.NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStore: %v", err)
+			}
+			return store
+		},
+	)
+}
+
+func TestPhotosOverPostgres(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunPhotoConformanceTests(t,
+		func() petstore.PetRepository { return newPostgresRepository(t, pool) },
+		func() petstore.BlobStore {
+			store, err := blob.NewPostgresStore(t.Context(), pool)
+			if err != nil {
+				t.Fatalf("NewPostgresStore: %v", err)
+			}
+			return store
+		},
+	)
+}
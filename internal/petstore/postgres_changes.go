@@ -0,0 +1,130 @@
+package petstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"demo/internal/tenant"
+)
+
+// The change feed is filled by a trigger on pets, so every write, whichever code path
+// makes it, lands in pet_changes in the same transaction. Rows are inserted without a
+// sequence number; pet_changes_sequence numbers them later, and only rows written by
+// transactions older than the oldest one still running (the xmin of the current
+// snapshot). Such rows can no longer change or be joined by earlier ones, so a seq, once
+// assigned, is never followed by a lower one and a reader that has seen seq N has seen
+// every change numbered up to N. The price is latency: a change becomes visible once all
+// transactions that started before it have finished, so a long-running transaction
+// anywhere in the cluster holds the feed back.
+//
+// The DDL needs PostgreSQL 13 or newer for xid8.
+const changeFeedDDL = `
+        CREATE TABLE IF NOT EXISTS pet_changes (
+            id         BIGSERIAL PRIMARY KEY,
+            seq        BIGINT UNIQUE,
+            txid       XID8 NOT NULL DEFAULT pg_current_xact_id(),
+            op         TEXT NOT NULL,
+            pet_id     BIGINT NOT NULL,
+            payload    JSONB,
+            changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        );
+        CREATE INDEX IF NOT EXISTS pet_changes_unsequenced_idx ON pet_changes (txid, id) WHERE seq IS NULL;
+        ALTER TABLE pet_changes ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+        CREATE INDEX IF NOT EXISTS pet_changes_org_seq_idx ON pet_changes (org_id, seq);
+
+        CREATE OR REPLACE FUNCTION pets_record_change() RETURNS trigger LANGUAGE plpgsql AS $$
+        BEGIN
+            IF TG_OP = 'DELETE' THEN
+                -- Purging a soft-deleted pet was reported when it was soft-deleted.
+                IF OLD.deleted_at IS NULL THEN
+                    INSERT INTO pet_changes (org_id, op, pet_id) VALUES (OLD.org_id, 'delete', OLD.id);
+                END IF;
+                RETURN OLD;
+            END IF;
+            -- Soft-deleting a pet, as a merge does, reads as a delete to consumers.
+            IF NEW.deleted_at IS NOT NULL THEN
+                IF OLD.deleted_at IS NULL THEN
+                    INSERT INTO pet_changes (org_id, op, pet_id) VALUES (NEW.org_id, 'delete', NEW.id);
+                END IF;
+                RETURN NEW;
+            END IF;
+            INSERT INTO pet_changes (org_id, op, pet_id, payload)
+            VALUES (NEW.org_id, CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.id,
+                    jsonb_strip_nulls(jsonb_build_object('id', NEW.id, 'name', NEW.name, 'tag', NEW.tag)));
+            RETURN NEW;
+        END $$;
+
+        -- Numbers settled changes in xid order. The advisory lock serializes callers and
+        -- each statement of a function takes a fresh snapshot, so the UPDATE sees the
+        -- numbers the previous caller assigned.
+        CREATE OR REPLACE FUNCTION pet_changes_sequence() RETURNS void LANGUAGE plpgsql AS $$
+        BEGIN
+            PERFORM pg_advisory_xact_lock(hashtext('pet_changes_sequence'));
+            UPDATE pet_changes c SET seq = n.seq
+            FROM (
+                SELECT id, (SELECT COALESCE(max(seq), 0) FROM pet_changes)
+                           + row_number() OVER (ORDER BY txid, id) AS seq
+                FROM pet_changes
+                WHERE seq IS NULL AND txid < pg_snapshot_xmin(pg_current_snapshot())
+            ) n
+            WHERE c.id = n.id;
+        END $$;
+
+        -- The first start with the feed records existing pets as creates, so replaying
+        -- from zero reconstructs the table. Creating the trigger locks out writes to pets
+        -- until the backfill commits.
+        DO $$
+        BEGIN
+            IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'pets_record_change' AND tgrelid = 'pets'::regclass) THEN
+                CREATE TRIGGER pets_record_change AFTER INSERT OR UPDATE OR DELETE ON pets
+                    FOR EACH ROW EXECUTE FUNCTION pets_record_change();
+                INSERT INTO pet_changes (org_id, op, pet_id, payload)
+                SELECT org_id, 'create', id, jsonb_strip_nulls(jsonb_build_object('id', id, 'name', name, 'tag', tag))
+                FROM pets WHERE deleted_at IS NULL ORDER BY id;
+            END IF;
+        END $$;`
+
+// ListChanges implements ChangeFeed, listing the changes of the organization on ctx.
+// Sequence numbers are shared by all organizations, so one organization's feed skips
+// the numbers of the others.
+func (r *PostgresRepository) ListChanges(ctx context.Context, since int64, limit int32) ([]PetChange, error) {
+	ctx, cancel := r.timeouts.apply(ctx, "list")
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, `SELECT pet_changes_sequence()`); err != nil {
+		return nil, mapTimeout(ctx, fmt.Errorf("failed to sequence pet changes: %w", err))
+	}
+	rows, err := r.db.Query(ctx, `
+        SELECT seq, op, pet_id, payload, changed_at FROM pet_changes
+        WHERE org_id = $3 AND seq > $1 ORDER BY seq LIMIT $2`, since, limit, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, mapTimeout(ctx, fmt.Errorf("failed to list pet changes: %w", err))
+	}
+	defer rows.Close()
+
+	changes := make([]PetChange, 0)
+	for rows.Next() {
+		var (
+			change  PetChange
+			payload []byte
+		)
+		if err := rows.Scan(&change.Seq, &change.Op, &change.PetId, &payload, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pet change row: %w", err)
+		}
+		if payload != nil {
+			change.Payload = new(Pet)
+			if err := json.Unmarshal(payload, change.Payload); err != nil {
+				return nil, fmt.Errorf("failed to decode pet change payload: %w", err)
+			}
+		}
+		change.ChangedAt = change.ChangedAt.UTC()
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapTimeout(ctx, fmt.Errorf("failed during pet change iteration: %w", err))
+	}
+	return changes, nil
+}
+
+var _ ChangeFeed = (*PostgresRepository)(nil)
@@ -0,0 +1,167 @@
+package petstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"demo/internal/tenant"
+)
+
+// txBeginner is implemented by pgxpool.Pool; writes spanning several statements need it.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// ListDuplicates implements Deduper. Soft-deleted pets are never candidates.
+func (r *PostgresRepository) ListDuplicates(ctx context.Context, limit int32) ([]DuplicateGroup, error) {
+	ctx, cancel := r.timeouts.apply(ctx, "list")
+	defer cancel()
+
+	var groups []DuplicateGroup
+	err := r.withReadRetry(ctx, "ListDuplicates", func(db queryExecutor) error {
+		rows, err := db.Query(ctx, `
+            SELECT lower(btrim(name)), tag, array_agg(id ORDER BY id)
+            FROM pets WHERE org_id = $2 AND deleted_at IS NULL
+            GROUP BY 1, 2 HAVING count(*) > 1
+            ORDER BY min(id) LIMIT $1`, limit, tenant.FromContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to list duplicate pets: %w", err)
+		}
+		var (
+			name string
+			tag  sql.NullString
+			ids  []int64
+		)
+		groups = make([]DuplicateGroup, 0)
+		_, err = pgx.ForEachRow(rows, []any{&name, &tag, &ids}, func() error {
+			group := DuplicateGroup{Name: name, PetIds: ids}
+			if tag.Valid {
+				group.Tag = &tag.String
+			}
+			groups = append(groups, group)
+			ids = nil
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, mapTimeout(ctx, err)
+	}
+	return groups, nil
+}
+
+// MergePets implements Deduper. The survivor adopts the first duplicate's owner when it
+// has none, and merges recorded against a duplicate are re-pointed at the survivor so
+// pet_merges always names a live pet.
+func (r *PostgresRepository) MergePets(ctx context.Context, survivor int64, duplicates []int64, ownedBy, mergedBy string) (Pet, error) {
+	beginner, ok := r.db.(txBeginner)
+	if !ok {
+		return Pet{}, errors.New("merging pets needs a database that supports transactions")
+	}
+
+	ctx, cancel := r.timeouts.apply(ctx, "update")
+	defer cancel()
+
+	var pet Pet
+	err := r.withRetry(ctx, "MergePets", isSafeWriteRetry, func() error {
+		tx, err := beginner.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		pet, err = mergePets(ctx, tx, tenant.FromContext(ctx), survivor, duplicates, ownedBy, mergedBy)
+		if err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPetNotFound) || errors.Is(err, ErrNotPetOwner) || errors.Is(err, ErrNotDuplicates) {
+			return Pet{}, err
+		}
+		return Pet{}, mapTimeout(ctx, fmt.Errorf("failed to merge pets: %w", err))
+	}
+	return pet, nil
+}
+
+// mergeRow is a pet locked for a merge, with its duplicate key.
+type mergeRow struct {
+	name  string
+	key   string
+	tag   sql.NullString
+	owner sql.NullString
+}
+
+func mergePets(ctx context.Context, tx pgx.Tx, org string, survivor int64, duplicates []int64, ownedBy, mergedBy string) (Pet, error) {
+	ids := append([]int64{survivor}, duplicates...)
+	rows, err := tx.Query(ctx, `
+        SELECT id, name, lower(btrim(name)), tag, owner_id FROM pets
+        WHERE org_id = $1 AND id = ANY($2) AND deleted_at IS NULL
+        ORDER BY id FOR UPDATE`, org, ids)
+	if err != nil {
+		return Pet{}, err
+	}
+	var (
+		id  int64
+		row mergeRow
+	)
+	locked := make(map[int64]mergeRow, len(ids))
+	if _, err := pgx.ForEachRow(rows, []any{&id, &row.name, &row.key, &row.tag, &row.owner}, func() error {
+		locked[id] = row
+		return nil
+	}); err != nil {
+		return Pet{}, err
+	}
+
+	if len(locked) != len(ids) {
+		return Pet{}, ErrPetNotFound
+	}
+	if ownedBy != "" {
+		for _, row := range locked {
+			if row.owner.String != ownedBy {
+				return Pet{}, ErrNotPetOwner
+			}
+		}
+	}
+	kept := locked[survivor]
+	owner := kept.owner
+	for _, dup := range duplicates {
+		row := locked[dup]
+		if row.key != kept.key || row.tag != kept.tag {
+			return Pet{}, ErrNotDuplicates
+		}
+		if !owner.Valid {
+			owner = row.owner
+		}
+	}
+
+	if owner != kept.owner {
+		if _, err := tx.Exec(ctx, `UPDATE pets SET owner_id = $3 WHERE org_id = $1 AND id = $2`, org, survivor, owner); err != nil {
+			return Pet{}, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE pet_merges SET survivor_id = $2 WHERE org_id = $1 AND survivor_id = ANY($3)`, org, survivor, duplicates); err != nil {
+		return Pet{}, err
+	}
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO pet_merges (org_id, pet_id, survivor_id, merged_by)
+        SELECT $1, unnest($3::bigint[]), $2, $4`, org, survivor, duplicates, nullableOwner(mergedBy)); err != nil {
+		return Pet{}, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE pets SET deleted_at = now() WHERE org_id = $1 AND id = ANY($2)`, org, duplicates); err != nil {
+		return Pet{}, err
+	}
+
+	pet := Pet{Id: survivor, Name: kept.name}
+	if kept.tag.Valid {
+		pet.Tag = &kept.tag.String
+	}
+	return pet, nil
+}
+
+var _ Deduper = (*PostgresRepository)(nil)
@@ -0,0 +1,55 @@
+package petstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PurgeDeletedPets implements Purger. The merge records of purged pets go with them, so
+// their ids may be taken by new pets and merged again. Rows locked by a concurrent write
+// are skipped, and the change feed already reported these pets deleted when they were
+// soft-deleted, so purging adds nothing to it.
+func (r *PostgresRepository) PurgeDeletedPets(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("purge batch size %d must be positive", batchSize)
+	}
+	var total int64
+	for {
+		n, err := r.purgeBatch(ctx, before, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+func (r *PostgresRepository) purgeBatch(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	ctx, cancel := r.timeouts.apply(ctx, "delete")
+	defer cancel()
+
+	var n int64
+	err := r.withRetry(ctx, "PurgeDeletedPets", isSafeWriteRetry, func() error {
+		return r.db.QueryRow(ctx, `
+            WITH purged AS (
+                DELETE FROM pets WHERE (org_id, id) IN (
+                    SELECT org_id, id FROM pets
+                    WHERE deleted_at < $1
+                    ORDER BY deleted_at LIMIT $2
+                    FOR UPDATE SKIP LOCKED)
+                RETURNING org_id, id
+            ), merges AS (
+                DELETE FROM pet_merges m USING purged p WHERE m.org_id = p.org_id AND m.pet_id = p.id
+            )
+            SELECT count(*) FROM purged`, before, batchSize).Scan(&n)
+	})
+	if err != nil {
+		return 0, mapTimeout(ctx, fmt.Errorf("failed to purge deleted pets: %w", err))
+	}
+	return n, nil
+}
+
+var _ Purger = (*PostgresRepository)(nil)
@@ -5,10 +5,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/tenant"
 )
 
 // ErrPetExists indicates a pet with the given identifier already exists.
@@ -17,27 +22,84 @@ var ErrPetExists = errors.New("pet already exists")
 // ErrPetNotFound indicates the requested pet could not be located.
 var ErrPetNotFound = errors.New("pet not found")
 
+// ErrNotPetOwner indicates the pet exists but was created by someone other than the
+// owner an update or delete was restricted to.
+var ErrNotPetOwner = errors.New("pet is owned by another user")
+
+// ErrQueryTimeout indicates a repository operation exceeded its configured query timeout.
+var ErrQueryTimeout = errors.New("database query timed out")
+
 // PetRepository describes persistence operations for pets.
+//
+// Every operation is scoped to the organization tenant.FromContext finds on ctx: pet
+// identifiers are unique per organization, and pets of other organizations are never
+// returned, matched, or changed.
+//
+// owner is the ID of the user creating a pet, empty for anonymous writes. A non-empty
+// ownedBy limits ListPets to that owner's pets and makes UpdatePet and DeletePet fail with
+// ErrNotPetOwner for pets owned by anyone else.
 type PetRepository interface {
-	ListPets(ctx context.Context, limit int32) ([]Pet, error)
-	CreatePet(ctx context.Context, pet Pet) error
+	ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error)
+	CreatePet(ctx context.Context, pet Pet, owner string) error
 	GetPet(ctx context.Context, id int64) (Pet, error)
-	UpdatePet(ctx context.Context, pet Pet) error
-	DeletePet(ctx context.Context, id int64) error
+	UpdatePet(ctx context.Context, pet Pet, ownedBy string) error
+	DeletePet(ctx context.Context, id int64, ownedBy string) error
 }
 
 // PostgresRepository implements PetRepository using PostgreSQL for storage.
 type PostgresRepository struct {
-	pool *pgxpool.Pool
+	db       queryExecutor
+	readDB   queryExecutor
+	logger   *slog.Logger
+	retry    retryPolicy
+	retries  atomic.Uint64
+	timeouts queryTimeouts
+}
+
+// RepositoryOption customises a PostgresRepository at construction time.
+type RepositoryOption func(*PostgresRepository)
+
+// WithQueryTimeouts bounds each operation by a default timeout, optionally overridden per
+// operation name (list, get, create, update, delete). Zero disables the bound.
+func WithQueryTimeouts(def time.Duration, perOperation map[string]time.Duration) RepositoryOption {
+	return func(r *PostgresRepository) {
+		r.timeouts = queryTimeouts{def: def, perOperation: perOperation}
+	}
+}
+
+// WithLogger sets the logger used for retry and fallback warnings.
+func WithLogger(logger *slog.Logger) RepositoryOption {
+	return func(r *PostgresRepository) {
+		if logger != nil {
+			r.logger = logger
+		}
+	}
+}
+
+// WithReadReplica routes ListPets and GetPet to a read-replica pool, falling back to the
+// primary when the replica fails. A nil pool keeps all traffic on the primary.
+func WithReadReplica(pool *pgxpool.Pool) RepositoryOption {
+	return func(r *PostgresRepository) {
+		if pool != nil {
+			r.readDB = pool
+		}
+	}
 }
 
 // NewPostgresRepository prepares the required schema and returns a repository instance.
-func NewPostgresRepository(ctx context.Context, pool *pgxpool.Pool) (*PostgresRepository, error) {
+func NewPostgresRepository(ctx context.Context, pool *pgxpool.Pool, opts ...RepositoryOption) (*PostgresRepository, error) {
 	if pool == nil {
 		return nil, errors.New("pgx pool is nil")
 	}
 
-	repo := &PostgresRepository{pool: pool}
+	return newPostgresRepository(ctx, pool, opts...)
+}
+
+func newPostgresRepository(ctx context.Context, db queryExecutor, opts ...RepositoryOption) (*PostgresRepository, error) {
+	repo := &PostgresRepository{db: db, retry: defaultRetryPolicy(), logger: slog.Default()}
+	for _, opt := range opts {
+		opt(repo)
+	}
 	if err := repo.ensureSchema(ctx); err != nil {
 		return nil, err
 	}
@@ -51,70 +113,164 @@ func (r *PostgresRepository) ensureSchema(ctx context.Context) error {
             id   BIGINT PRIMARY KEY,
             name TEXT NOT NULL,
             tag  TEXT
-        );`
-
-	if _, err := r.pool.Exec(ctx, ddl); err != nil {
+        );
+        ALTER TABLE pets ADD COLUMN IF NOT EXISTS owner_id TEXT;
+        CREATE INDEX IF NOT EXISTS pets_owner_id_idx ON pets (owner_id);
+        ALTER TABLE pets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+        CREATE INDEX IF NOT EXISTS pets_deleted_at_idx ON pets (deleted_at) WHERE deleted_at IS NOT NULL;
+        CREATE TABLE IF NOT EXISTS pet_merges (
+            pet_id      BIGINT PRIMARY KEY,
+            survivor_id BIGINT NOT NULL,
+            merged_by   TEXT,
+            merged_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+        );` + orgScopedPetsDDL
+
+	if _, err := r.db.Exec(ctx, ddl); err != nil {
 		return fmt.Errorf("failed to ensure pets table: %w", err)
 	}
+	if _, err := r.db.Exec(ctx, changeFeedDDL); err != nil {
+		return fmt.Errorf("failed to ensure pet change feed: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, collectionVersionsDDL); err != nil {
+		return fmt.Errorf("failed to ensure collection versions: %w", err)
+	}
 
 	return nil
 }
 
+// orgScopedPetsDDL moves pets and pet_merges to per-organization keys; it matches
+// migration 0012 and is a no-op on current schemas. Rows from before organizations
+// existed belong to the default one.
+const orgScopedPetsDDL = `
+        ALTER TABLE pets ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+        ALTER TABLE pet_merges ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default';
+        DO $$
+        BEGIN
+            IF (SELECT indnatts FROM pg_index WHERE indrelid = 'pets'::regclass AND indisprimary) = 1 THEN
+                ALTER TABLE pets DROP CONSTRAINT pets_pkey, ADD CONSTRAINT pets_pkey PRIMARY KEY (org_id, id);
+            END IF;
+            IF (SELECT indnatts FROM pg_index WHERE indrelid = 'pet_merges'::regclass AND indisprimary) = 1 THEN
+                ALTER TABLE pet_merges DROP CONSTRAINT pet_merges_pkey, ADD CONSTRAINT pet_merges_pkey PRIMARY KEY (org_id, pet_id);
+            END IF;
+        END $$;
+        DROP INDEX IF EXISTS pets_duplicate_key_idx;
+        DROP INDEX IF EXISTS pet_merges_survivor_id_idx;
+        CREATE INDEX IF NOT EXISTS pets_org_duplicate_key_idx ON pets (org_id, lower(btrim(name)), tag) WHERE deleted_at IS NULL;
+        CREATE INDEX IF NOT EXISTS pet_merges_org_survivor_id_idx ON pet_merges (org_id, survivor_id);`
+
 // ListPets returns pets ordered by identifier; limit==0 fetches all records.
-func (r *PostgresRepository) ListPets(ctx context.Context, limit int32) ([]Pet, error) {
-	const baseQuery = `SELECT id, name, tag FROM pets ORDER BY id ASC`
+func (r *PostgresRepository) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	return r.listPets(ctx, "ListPets", nil, limit, ownedBy)
+}
 
-	var (
-		rows pgx.Rows
-		err  error
-	)
+// ListPetsAfter is ListPets for keyset pagination: it returns pets with an identifier
+// greater than after.
+func (r *PostgresRepository) ListPetsAfter(ctx context.Context, after int64, limit int32, ownedBy string) ([]Pet, error) {
+	return r.listPets(ctx, "ListPetsAfter", &after, limit, ownedBy)
+}
 
-	if limit > 0 {
-		rows, err = r.pool.Query(ctx, baseQuery+" LIMIT $1", limit)
-	} else {
-		rows, err = r.pool.Query(ctx, baseQuery)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to list pets: %w", err)
-	}
-	defer rows.Close()
+func (r *PostgresRepository) listPets(ctx context.Context, op string, after *int64, limit int32, ownedBy string) ([]Pet, error) {
+	const baseQuery = `SELECT id, name, tag FROM pets WHERE org_id = $1 AND deleted_at IS NULL`
+
+	ctx, cancel := r.timeouts.apply(ctx, "list")
+	defer cancel()
 
-	pets := make([]Pet, 0)
-	for rows.Next() {
+	var pets []Pet
+	err := r.withReadRetry(ctx, op, func(db queryExecutor) error {
 		var (
-			pet Pet
-			tag sql.NullString
+			rows pgx.Rows
+			err  error
 		)
 
-		if err := rows.Scan(&pet.Id, &pet.Name, &tag); err != nil {
-			return nil, fmt.Errorf("failed to scan pet row: %w", err)
+		query, args := baseQuery, []any{tenant.FromContext(ctx)}
+		if ownedBy != "" {
+			args = append(args, ownedBy)
+			query += fmt.Sprintf(" AND owner_id = $%d", len(args))
 		}
-		if tag.Valid {
-			pet.Tag = &tag.String
+		if after != nil {
+			args = append(args, *after)
+			query += fmt.Sprintf(" AND id > $%d", len(args))
+		}
+		query += " ORDER BY id ASC"
+		if limit > 0 {
+			args = append(args, limit)
+			query += fmt.Sprintf(" LIMIT $%d", len(args))
+		}
+		rows, err = db.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to list pets: %w", err)
+		}
+		defer rows.Close()
+
+		pets = make([]Pet, 0)
+		for rows.Next() {
+			var (
+				pet Pet
+				tag sql.NullString
+			)
+
+			if err := rows.Scan(&pet.Id, &pet.Name, &tag); err != nil {
+				return fmt.Errorf("failed to scan pet row: %w", err)
+			}
+			if tag.Valid {
+				pet.Tag = &tag.String
+			}
+			pets = append(pets, pet)
 		}
-		pets = append(pets, pet)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed during pet iteration: %w", err)
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed during pet iteration: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, mapTimeout(ctx, err)
 	}
 
 	return pets, nil
 }
 
+// CountPets counts all pets, or only those created by ownedBy when it is set.
+func (r *PostgresRepository) CountPets(ctx context.Context, ownedBy string) (int64, error) {
+	ctx, cancel := r.timeouts.apply(ctx, "list")
+	defer cancel()
+
+	var total int64
+	err := r.withReadRetry(ctx, "CountPets", func(db queryExecutor) error {
+		query, args := `SELECT count(*) FROM pets WHERE org_id = $1 AND deleted_at IS NULL`, []any{tenant.FromContext(ctx)}
+		if ownedBy != "" {
+			query += ` AND owner_id = $2`
+			args = append(args, ownedBy)
+		}
+		return db.QueryRow(ctx, query, args...).Scan(&total)
+	})
+	if err != nil {
+		return 0, mapTimeout(ctx, fmt.Errorf("failed to count pets: %w", err))
+	}
+	return total, nil
+}
+
 // CreatePet inserts a new pet record.
-func (r *PostgresRepository) CreatePet(ctx context.Context, pet Pet) error {
+func (r *PostgresRepository) CreatePet(ctx context.Context, pet Pet, owner string) error {
 	var tag any
 	if pet.Tag != nil {
 		tag = *pet.Tag
 	}
 
-	if _, err := r.pool.Exec(ctx, `INSERT INTO pets (id, name, tag) VALUES ($1, $2, $3)`, pet.Id, pet.Name, tag); err != nil {
+	ctx, cancel := r.timeouts.apply(ctx, "create")
+	defer cancel()
+
+	err := r.withRetry(ctx, "CreatePet", isSafeWriteRetry, func() error {
+		_, err := r.db.Exec(ctx, `INSERT INTO pets (org_id, id, name, tag, owner_id) VALUES ($1, $2, $3, $4, $5)`,
+			tenant.FromContext(ctx), pet.Id, pet.Name, tag, nullableOwner(owner))
+		return err
+	})
+	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 			return ErrPetExists
 		}
-		return fmt.Errorf("failed to create pet: %w", err)
+		return mapTimeout(ctx, fmt.Errorf("failed to create pet: %w", err))
 	}
 
 	return nil
@@ -127,11 +283,18 @@ func (r *PostgresRepository) GetPet(ctx context.Context, id int64) (Pet, error)
 		tag sql.NullString
 	)
 
-	if err := r.pool.QueryRow(ctx, `SELECT id, name, tag FROM pets WHERE id = $1`, id).Scan(&pet.Id, &pet.Name, &tag); err != nil {
+	ctx, cancel := r.timeouts.apply(ctx, "get")
+	defer cancel()
+
+	err := r.withReadRetry(ctx, "GetPet", func(db queryExecutor) error {
+		return db.QueryRow(ctx, `SELECT id, name, tag FROM pets WHERE org_id = $1 AND id = $2 AND deleted_at IS NULL`, tenant.FromContext(ctx), id).
+			Scan(&pet.Id, &pet.Name, &tag)
+	})
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Pet{}, ErrPetNotFound
 		}
-		return Pet{}, fmt.Errorf("failed to fetch pet: %w", err)
+		return Pet{}, mapTimeout(ctx, fmt.Errorf("failed to fetch pet: %w", err))
 	}
 
 	if tag.Valid {
@@ -142,34 +305,120 @@ func (r *PostgresRepository) GetPet(ctx context.Context, id int64) (Pet, error)
 }
 
 // UpdatePet replaces an existing pet record.
-func (r *PostgresRepository) UpdatePet(ctx context.Context, pet Pet) error {
+func (r *PostgresRepository) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
 	var tag any
 	if pet.Tag != nil {
 		tag = *pet.Tag
 	}
 
-	cmdTag, err := r.pool.Exec(ctx, `UPDATE pets SET name = $2, tag = $3 WHERE id = $1`, pet.Id, pet.Name, tag)
+	ctx, cancel := r.timeouts.apply(ctx, "update")
+	defer cancel()
+
+	var cmdTag pgconn.CommandTag
+	err := r.withRetry(ctx, "UpdatePet", isSafeWriteRetry, func() error {
+		var err error
+		cmdTag, err = r.db.Exec(ctx, `
+            UPDATE pets SET name = $2, tag = $3
+            WHERE org_id = $5 AND id = $1 AND deleted_at IS NULL AND ($4::text IS NULL OR owner_id = $4)`,
+			pet.Id, pet.Name, tag, nullableOwner(ownedBy), tenant.FromContext(ctx))
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update pet: %w", err)
+		return mapTimeout(ctx, fmt.Errorf("failed to update pet: %w", err))
 	}
 	if cmdTag.RowsAffected() == 0 {
-		return ErrPetNotFound
+		return r.missingOrNotOwned(ctx, pet.Id, ownedBy)
 	}
 
 	return nil
 }
 
 // DeletePet removes a pet by identifier.
-func (r *PostgresRepository) DeletePet(ctx context.Context, id int64) error {
-	cmdTag, err := r.pool.Exec(ctx, `DELETE FROM pets WHERE id = $1`, id)
+func (r *PostgresRepository) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	ctx, cancel := r.timeouts.apply(ctx, "delete")
+	defer cancel()
+
+	var cmdTag pgconn.CommandTag
+	err := r.withRetry(ctx, "DeletePet", isSafeWriteRetry, func() error {
+		var err error
+		cmdTag, err = r.db.Exec(ctx, `
+            DELETE FROM pets WHERE org_id = $3 AND id = $1 AND deleted_at IS NULL AND ($2::text IS NULL OR owner_id = $2)`,
+			id, nullableOwner(ownedBy), tenant.FromContext(ctx))
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete pet: %w", err)
+		return mapTimeout(ctx, fmt.Errorf("failed to delete pet: %w", err))
 	}
 	if cmdTag.RowsAffected() == 0 {
-		return ErrPetNotFound
+		return r.missingOrNotOwned(ctx, id, ownedBy)
 	}
 
 	return nil
 }
 
+// missingOrNotOwned explains a write that matched no rows. The ownership condition is part
+// of the write itself, so no concurrent change can slip between check and write; this
+// lookup only picks the error.
+func (r *PostgresRepository) missingOrNotOwned(ctx context.Context, id int64, ownedBy string) error {
+	if ownedBy == "" {
+		return ErrPetNotFound
+	}
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pets WHERE org_id = $1 AND id = $2 AND deleted_at IS NULL)`,
+		tenant.FromContext(ctx), id).Scan(&exists)
+	if err != nil {
+		return mapTimeout(ctx, fmt.Errorf("failed to fetch pet: %w", err))
+	}
+	if exists {
+		return ErrNotPetOwner
+	}
+	return ErrPetNotFound
+}
+
+func nullableOwner(owner string) any {
+	if owner == "" {
+		return nil
+	}
+	return owner
+}
+
+// PetOwners returns the owner of each listed pet that exists and has one.
+func (r *PostgresRepository) PetOwners(ctx context.Context, ids []int64) (map[int64]string, error) {
+	ctx, cancel := r.timeouts.apply(ctx, "get")
+	defer cancel()
+
+	owners := make(map[int64]string, len(ids))
+	err := r.withReadRetry(ctx, "PetOwners", func(db queryExecutor) error {
+		rows, err := db.Query(ctx, `
+            SELECT id, owner_id FROM pets
+            WHERE org_id = $1 AND id = ANY($2) AND owner_id IS NOT NULL AND deleted_at IS NULL`, tenant.FromContext(ctx), ids)
+		if err != nil {
+			return fmt.Errorf("failed to fetch pet owners: %w", err)
+		}
+		var (
+			id    int64
+			owner string
+		)
+		_, err = pgx.ForEachRow(rows, []any{&id, &owner}, func() error {
+			owners[id] = owner
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, mapTimeout(ctx, err)
+	}
+	return owners, nil
+}
+
+// Ping reports connectivity of the primary pool and, when configured, the read replica,
+// keyed by "primary" and "replica".
+func (r *PostgresRepository) Ping(ctx context.Context) map[string]error {
+	results := map[string]error{"primary": r.db.Ping(ctx)}
+	if r.readDB != nil {
+		results["replica"] = r.readDB.Ping(ctx)
+	}
+	return results
+}
+
 var _ PetRepository = (*PostgresRepository)(nil)
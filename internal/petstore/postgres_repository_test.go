@@ -0,0 +1,160 @@
+package petstore_test
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"demo/internal/database"
+	"demo/internal/database/databasetest"
+	"demo/internal/petstore"
+	"demo/internal/petstore/petstoretest"
+)
+
+func TestPostgresRepositoryConformance(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunRepositoryConformanceTests(t, func() petstore.PetRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+// TestPostgresRepositoryConformanceSimpleProtocol reruns the suite as the repository runs
+// behind PgBouncer in transaction pooling mode: no prepared statements, with arguments
+// encoded into the query text by pgx.
+func TestPostgresRepositoryConformanceSimpleProtocol(t *testing.T) {
+	cfg := databasetest.Config(t)
+	cfg.DSN = withDSNParam(cfg.DSN, "default_query_exec_mode", "simple_protocol")
+	pool := databasetest.NewPoolFor(t, cfg)
+	if mode := pool.Config().ConnConfig.DefaultQueryExecMode; mode != pgx.QueryExecModeSimpleProtocol {
+		t.Fatalf("DefaultQueryExecMode: got %v, want simple_protocol", mode)
+	}
+	petstoretest.RunRepositoryConformanceTests(t, func() petstore.PetRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+// withDSNParam sets a connection parameter on a URL or keyword/value DSN.
+func withDSNParam(dsn, key, value string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		query := u.Query()
+		query.Set(key, value)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return dsn + " " + key + "=" + value
+}
+
+func TestPostgresRepositoryChangeFeed(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunChangeFeedConformanceTests(t, func() petstoretest.ChangeFeedRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+func TestPostgresRepositoryDeduper(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunDeduperConformanceTests(t, func() petstoretest.DeduperRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+func TestPostgresRepositoryTenancy(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunTenancyConformanceTests(t, func() petstoretest.DeduperRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+func TestPostgresRepositoryPurge(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunPurgeConformanceTests(t, func() petstoretest.PurgerRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+func TestPostgresRepositoryCollectionVersions(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunCollectionVersionConformanceTests(t, func() petstoretest.VersionedRepository {
+		return newPostgresRepository(t, pool)
+	})
+}
+
+func TestPostgresRepositoryListETags(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	petstoretest.RunListETagConformanceTests(t, func() petstoretest.VersionedRepository {
+		return newPostgresRepository(t, pool)
+	}, 100*time.Millisecond)
+}
+
+// newPostgresRepository empties the test database and returns a repository over it.
+func newPostgresRepository(t *testing.T, pool *pgxpool.Pool, opts ...petstore.RepositoryOption) *petstore.PostgresRepository {
+	t.Helper()
+	databasetest.Truncate(t, pool)
+	repo, err := petstore.NewPostgresRepository(t.Context(), pool, opts...)
+	if err != nil {
+		t.Fatalf("NewPostgresRepository: %v", err)
+	}
+	return repo
+}
+
+// TestPostgresRepositorySchema starts repositories on a database migrations already set
+// up and on one they did not: ensureSchema must accept the first and build the second
+// into something the migrations then accept.
+func TestPostgresRepositorySchema(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	newPostgresRepository(t, pool)
+
+	if _, err := pool.Exec(t.Context(), `DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("dropping schema: %v", err)
+	}
+	if _, err := petstore.NewPostgresRepository(t.Context(), pool); err != nil {
+		t.Fatalf("NewPostgresRepository on an empty database: %v", err)
+	}
+	if err := database.MigrateUp(t.Context(), pool, slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatalf("MigrateUp after ensureSchema: %v", err)
+	}
+}
+
+func TestPostgresRepositoryPing(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	results := newPostgresRepository(t, pool).Ping(t.Context())
+	if err, ok := results["primary"]; !ok || err != nil {
+		t.Fatalf("Ping: got %v, want a nil primary result", results)
+	}
+	if _, ok := results["replica"]; ok {
+		t.Fatalf("Ping without a replica: got %v, want only primary", results)
+	}
+}
+
+// TestPostgresRepositoryQueryTimeout holds a pet's row lock from another transaction, so
+// an update of that pet waits on the server until the repository's timeout cancels it.
+func TestPostgresRepositoryQueryTimeout(t *testing.T) {
+	pool := databasetest.NewPool(t)
+	repo := newPostgresRepository(t, pool, petstore.WithQueryTimeouts(time.Minute, map[string]time.Duration{"update": 100 * time.Millisecond}))
+	if err := repo.CreatePet(t.Context(), petstore.Pet{Id: 1, Name: "Rex"}, ""); err != nil {
+		t.Fatalf("CreatePet: %v", err)
+	}
+
+	tx, err := pool.Begin(t.Context())
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	defer tx.Rollback(t.Context())
+	if _, err := tx.Exec(t.Context(), `SELECT 1 FROM pets WHERE id = 1 FOR UPDATE`); err != nil {
+		t.Fatalf("locking pet: %v", err)
+	}
+
+	started := time.Now()
+	err = repo.UpdatePet(t.Context(), petstore.Pet{Id: 1, Name: "Max"}, "")
+	if !errors.Is(err, petstore.ErrQueryTimeout) {
+		t.Fatalf("UpdatePet on a locked row: got %v, want ErrQueryTimeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("UpdatePet on a locked row: returned after %s, want about the 100ms timeout", elapsed)
+	}
+}
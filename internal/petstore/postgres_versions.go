@@ -0,0 +1,68 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"demo/internal/tenant"
+)
+
+// collectionVersionsDDL keeps a version per organization in collection_versions that a
+// trigger on pets bumps with every write, in the writing transaction, whichever code path
+// makes it. Purging a soft-deleted pet changes no list and leaves the version alone. The
+// bump locks the organization's row until the write commits, so writes to one
+// organization's pets are serialized on it; it matches migration 0015.
+const collectionVersionsDDL = `
+        CREATE TABLE IF NOT EXISTS collection_versions (
+            org_id     TEXT NOT NULL,
+            collection TEXT NOT NULL,
+            version    BIGINT NOT NULL,
+            PRIMARY KEY (org_id, collection)
+        );
+
+        CREATE OR REPLACE FUNCTION pets_bump_version() RETURNS trigger LANGUAGE plpgsql AS $$
+        BEGIN
+            IF TG_OP = 'DELETE' AND OLD.deleted_at IS NOT NULL THEN
+                RETURN NULL;
+            END IF;
+            INSERT INTO collection_versions AS v (org_id, collection, version)
+            VALUES (COALESCE(NEW.org_id, OLD.org_id), 'pets', 1)
+            ON CONFLICT (org_id, collection) DO UPDATE SET version = v.version + 1;
+            RETURN NULL;
+        END $$;
+
+        DO $$
+        BEGIN
+            IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'pets_bump_version' AND tgrelid = 'pets'::regclass) THEN
+                CREATE TRIGGER pets_bump_version AFTER INSERT OR UPDATE OR DELETE ON pets
+                    FOR EACH ROW EXECUTE FUNCTION pets_bump_version();
+            END IF;
+        END $$;`
+
+// PetsVersion implements CollectionVersions for the organization on ctx; one that never
+// had a pet is at version 0. It reads where ListPets reads, so with a lagging replica the
+// version is never newer than the pets listed after it.
+func (r *PostgresRepository) PetsVersion(ctx context.Context) (int64, error) {
+	ctx, cancel := r.timeouts.apply(ctx, "get")
+	defer cancel()
+
+	var version int64
+	err := r.withReadRetry(ctx, "PetsVersion", func(db queryExecutor) error {
+		err := db.QueryRow(ctx, `SELECT version FROM collection_versions WHERE org_id = $1 AND collection = 'pets'`,
+			tenant.FromContext(ctx)).Scan(&version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			version = 0
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return 0, mapTimeout(ctx, fmt.Errorf("failed to read pets version: %w", err))
+	}
+	return version, nil
+}
+
+var _ CollectionVersions = (*PostgresRepository)(nil)
@@ -0,0 +1,16 @@
+package petstore
+
+import (
+	"context"
+	"time"
+)
+
+// Purger hard-deletes pets that were soft-deleted, as merged duplicates are, once nobody
+// needs them any more. PostgresRepository implements it.
+type Purger interface {
+	// PurgeDeletedPets deletes, in every organization, the pets soft-deleted before
+	// before, batchSize at a time with each batch in its own transaction so no lock is
+	// held for long. It returns how many it deleted, including on error, when the batches
+	// already committed stay deleted.
+	PurgeDeletedPets(ctx context.Context, before time.Time, batchSize int) (int64, error)
+}
@@ -0,0 +1,127 @@
+package petstore_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"demo/internal/petstore"
+)
+
+// redisImage is the Redis server the cache tests start on first use.
+const redisImage = "redis:7-alpine"
+
+var (
+	redisOnce            sync.Once
+	redisContainer       *testcontainers.DockerContainer
+	redisAddr, redisSkip string
+	// redisPrefixes gives every cache its own key prefix and channel.
+	redisPrefixes atomic.Int64
+)
+
+func startRedis() {
+	ctx := context.Background()
+	var err error
+	redisContainer, err = testcontainers.Run(ctx, redisImage,
+		testcontainers.WithExposedPorts("6379/tcp"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("6379/tcp")),
+	)
+	if err == nil {
+		redisAddr, err = redisContainer.PortEndpoint(ctx, "6379/tcp", "")
+	}
+	if err != nil {
+		redisAddr, redisSkip = "", fmt.Sprintf("no %s container could be started (%v); skipping Redis tests", redisImage, err)
+	}
+}
+
+// newRedisCache skips t without Redis and otherwise wraps next in a RedisCachingRepository
+// with keys of its own, returning the client and the key prefix too.
+func newRedisCache(t *testing.T, next petstore.PetRepository) (*petstore.RedisCachingRepository, *redis.Client, string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("-short set; skipping Redis tests")
+	}
+	redisOnce.Do(startRedis)
+	if redisAddr == "" {
+		t.Skip(redisSkip)
+	}
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	t.Cleanup(func() { client.Close() })
+
+	n := redisPrefixes.Add(1)
+	prefix := fmt.Sprintf("test-%d:pet:", n)
+	cache, err := petstore.NewRedisCachingRepository(next, client, petstore.RedisCacheOptions{
+		TTL:       time.Minute,
+		KeyPrefix: prefix,
+		Channel:   fmt.Sprintf("test-%d:invalidations", n),
+		Logger:    slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatalf("NewRedisCachingRepository: %v", err)
+	}
+	return cache, client, prefix
+}
+
+// gatedRepository holds a GetPet after it has read from the wrapped repository, once
+// armed, until released, so a write can be slipped in between a cache miss and its fill.
+type gatedRepository struct {
+	petstore.PetRepository
+	armed   atomic.Bool
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedRepository) GetPet(ctx context.Context, id int64) (petstore.Pet, error) {
+	pet, err := g.PetRepository.GetPet(ctx, id)
+	if g.armed.CompareAndSwap(true, false) {
+		close(g.read)
+		<-g.release
+	}
+	return pet, err
+}
+
+// TestRedisCacheFillRacingInvalidation checks that a GetPet which read a pet before a
+// write invalidated it does not store what it read: the next GetPet sees the write.
+func TestRedisCacheFillRacingInvalidation(t *testing.T) {
+	pets := petstore.NewMemoryRepository()
+	gated := &gatedRepository{PetRepository: pets, read: make(chan struct{}), release: make(chan struct{})}
+	cache, client, prefix := newRedisCache(t, gated)
+	ctx := t.Context()
+	if err := cache.CreatePet(ctx, petstore.Pet{Id: 1, Name: "Rex"}, ""); err != nil {
+		t.Fatalf("CreatePet: %v", err)
+	}
+
+	gated.armed.Store(true)
+	filled := make(chan error, 1)
+	go func() {
+		_, err := cache.GetPet(ctx, 1)
+		filled <- err
+	}()
+	<-gated.read
+	if err := cache.UpdatePet(ctx, petstore.Pet{Id: 1, Name: "Max"}, ""); err != nil {
+		t.Fatalf("UpdatePet: %v", err)
+	}
+	close(gated.release)
+	if err := <-filled; err != nil {
+		t.Fatalf("racing GetPet: %v", err)
+	}
+
+	if n, err := client.Exists(ctx, prefix+"1").Result(); err != nil || n != 0 {
+		t.Fatalf("entry after the racing fill: exists %d, %v; want none", n, err)
+	}
+	if pet, err := cache.GetPet(ctx, 1); err != nil || pet.Name != "Max" {
+		t.Fatalf("GetPet after the update: got %+v, %v; want Max", pet, err)
+	}
+	// With nothing racing it, that read was stored.
+	if cached, err := client.Get(ctx, prefix+"1").Result(); err != nil || cached == "" {
+		t.Fatalf("entry after an unraced fill: got %q, %v; want the pet", cached, err)
+	}
+}
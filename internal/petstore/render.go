@@ -0,0 +1,132 @@
+package petstore
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// render writes payload as XML when the request's Accept header prefers application/xml
+// and the payload is a Pet, []Pet, PetPage, or Error; everything else, including Accept: */* and
+// a missing header, gets JSON.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, status int, payload any) {
+	w.Header().Add("Vary", "Accept")
+	if !prefersXML(r.Header.Get("Accept")) {
+		s.writeJSON(w, r, status, payload)
+		return
+	}
+	var doc any
+	switch p := payload.(type) {
+	case Pet:
+		doc = toXMLPet(p)
+	case []Pet:
+		list := xmlPets{Pets: make([]xmlPet, len(p))}
+		for i, pet := range p {
+			list.Pets[i] = toXMLPet(pet)
+		}
+		doc = list
+	case PetPage:
+		page := xmlPetPage{Items: make([]xmlPet, len(p.Items)), NextCursor: p.NextCursor, Total: p.Total}
+		for i, pet := range p.Items {
+			page.Items[i] = toXMLPet(pet)
+		}
+		doc = page
+	case Error:
+		doc = xmlError{Category: p.Category, Code: p.Code, ErrorCode: p.ErrorCode, Message: p.Message, RequestID: p.RequestId}
+	default:
+		s.writeJSON(w, r, status, payload)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(doc); err != nil {
+		s.logger.ErrorContext(r.Context(), "render: xml encode error", "error", err)
+	}
+}
+
+// xmlPet is Pet as <pet>; a nil Tag leaves out the <tag> element.
+type xmlPet struct {
+	XMLName xml.Name `xml:"pet"`
+	Id      int64    `xml:"id"`
+	Name    string   `xml:"name"`
+	Tag     *string  `xml:"tag,omitempty"`
+}
+
+func toXMLPet(p Pet) xmlPet {
+	return xmlPet{Id: p.Id, Name: p.Name, Tag: p.Tag}
+}
+
+type xmlPets struct {
+	XMLName xml.Name `xml:"pets"`
+	Pets    []xmlPet `xml:"pet"`
+}
+
+// xmlPetPage nests the page's pets as <items><pet>…</pet></items>.
+type xmlPetPage struct {
+	XMLName    xml.Name `xml:"pet_page"`
+	Items      []xmlPet `xml:"items>pet"`
+	NextCursor *string  `xml:"next_cursor,omitempty"`
+	Total      int64    `xml:"total"`
+}
+
+type xmlError struct {
+	XMLName   xml.Name       `xml:"error"`
+	Category  *ErrorCategory `xml:"category,omitempty"`
+	Code      int32          `xml:"code"`
+	ErrorCode *string        `xml:"error_code,omitempty"`
+	Message   string         `xml:"message"`
+	RequestID *string        `xml:"request_id,omitempty"`
+}
+
+// prefersXML reports whether header ranks application/xml (or text/xml) strictly above
+// application/json. Each type takes the q-value of its most specific matching range, so
+// wildcards rank both equally and ties go to JSON.
+func prefersXML(header string) bool {
+	if header == "" {
+		return false
+	}
+	xmlQ := max(acceptQuality(header, "application", "xml"), acceptQuality(header, "text", "xml"))
+	return xmlQ > acceptQuality(header, "application", "json")
+}
+
+// acceptQuality returns the q-value header gives typ/subtype, or 0 when no range matches.
+func acceptQuality(header, typ, subtype string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(header, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		rangeType, rangeSubtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+		if !ok {
+			continue
+		}
+		var match int
+		switch {
+		case rangeType == typ && rangeSubtype == subtype:
+			match = 2
+		case rangeType == typ && rangeSubtype == "*":
+			match = 1
+		case rangeType == "*" && rangeSubtype == "*":
+			match = 0
+		default:
+			continue
+		}
+		if match <= specificity {
+			continue
+		}
+		rangeQ := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					rangeQ = 0
+				} else {
+					rangeQ = parsed
+				}
+			}
+		}
+		q, specificity = rangeQ, match
+	}
+	return q
+}
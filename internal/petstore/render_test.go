@@ -0,0 +1,185 @@
+package petstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// renderAs renders payload for a request with the given Accept header.
+func renderAs(t *testing.T, accept string, status int, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+	server := NewServer(NewMemoryRepository(), slog.New(slog.DiscardHandler))
+	req := httptest.NewRequest(http.MethodGet, "/pets", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	server.render(rec, req, status, payload)
+	if rec.Code != status {
+		t.Fatalf("render: got status %d, want %d", rec.Code, status)
+	}
+	return rec
+}
+
+// decodeXML checks rec is an XML document and decodes it into v.
+func decodeXML(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+		t.Fatalf("Content-Type: got %q, want application/xml; charset=utf-8", ct)
+	}
+	if !bytes.HasPrefix(rec.Body.Bytes(), []byte(xml.Header)) {
+		t.Fatalf("XML body: got %s, want it to start with the XML declaration", rec.Body)
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+}
+
+func TestRenderXMLRoundTrip(t *testing.T) {
+	dog, cursor, requestID := "dog", "next-page", "req-1"
+	category, errorCode := PETNOTFOUND, "PET_NOT_FOUND"
+	rex, tom := Pet{Id: 1, Name: "Rex", Tag: &dog}, Pet{Id: 2, Name: "Tom"}
+
+	t.Run("Pet", func(t *testing.T) {
+		rec := renderAs(t, "application/xml", http.StatusOK, rex)
+		var got xmlPet
+		decodeXML(t, rec, &got)
+		if got.XMLName.Local != "pet" || got.Id != 1 || got.Name != "Rex" || got.Tag == nil || *got.Tag != "dog" {
+			t.Fatalf("pet: got %+v from %s", got, rec.Body)
+		}
+	})
+
+	t.Run("PetWithoutTag", func(t *testing.T) {
+		rec := renderAs(t, "application/xml", http.StatusOK, tom)
+		if strings.Contains(rec.Body.String(), "<tag") {
+			t.Fatalf("pet without a tag: got %s, want no <tag> element", rec.Body)
+		}
+		var got xmlPet
+		decodeXML(t, rec, &got)
+		if got.Id != 2 || got.Name != "Tom" || got.Tag != nil {
+			t.Fatalf("pet without a tag: got %+v", got)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		rec := renderAs(t, "application/xml", http.StatusOK, []Pet{rex, tom})
+		var got xmlPets
+		decodeXML(t, rec, &got)
+		want := xmlPets{XMLName: xml.Name{Local: "pets"}, Pets: []xmlPet{toXMLPet(rex), toXMLPet(tom)}}
+		for i := range want.Pets {
+			want.Pets[i].XMLName = xml.Name{Local: "pet"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("list: got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		rec := renderAs(t, "application/xml", http.StatusOK, PetPage{Items: []Pet{rex}, NextCursor: &cursor, Total: 2})
+		var got xmlPetPage
+		decodeXML(t, rec, &got)
+		if len(got.Items) != 1 || got.Items[0].Name != "Rex" || got.NextCursor == nil || *got.NextCursor != cursor || got.Total != 2 {
+			t.Fatalf("page: got %+v from %s", got, rec.Body)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		payload := Error{Category: &category, Code: http.StatusNotFound, ErrorCode: &errorCode, Message: "pet not found", RequestId: &requestID}
+		rec := renderAs(t, "application/xml", http.StatusNotFound, payload)
+		var got xmlError
+		decodeXML(t, rec, &got)
+		if got.XMLName.Local != "error" || got.Category == nil || *got.Category != category || got.Code != http.StatusNotFound ||
+			got.ErrorCode == nil || *got.ErrorCode != errorCode || got.Message != "pet not found" ||
+			got.RequestID == nil || *got.RequestID != requestID {
+			t.Fatalf("error: got %+v from %s", got, rec.Body)
+		}
+	})
+}
+
+func TestRenderNegotiation(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		xml    bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"application/xml", true},
+		{"text/xml", true},
+		{"application/xml;q=0.9, application/json;q=0.8", true},
+		{"application/json;q=0.9, application/xml;q=0.8", false},
+		{"application/xml, application/json", false},
+		{"application/xml, */*;q=0.1", true},
+		{"application/*;q=0.5, application/xml", true},
+		{"application/xml;q=0", false},
+		{"application/xml;q=bogus, application/json;q=0.1", false},
+	} {
+		t.Run(tc.accept, func(t *testing.T) {
+			rec := renderAs(t, tc.accept, http.StatusOK, Pet{Id: 1, Name: "Rex"})
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Fatalf("Vary: got %q, want Accept", got)
+			}
+			if tc.xml {
+				var got xmlPet
+				decodeXML(t, rec, &got)
+				if got.Id != 1 || got.Name != "Rex" {
+					t.Fatalf("pet: got %+v", got)
+				}
+				return
+			}
+			var got Pet
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Fatalf("Content-Type: got %q, want JSON", ct)
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Id != 1 || got.Name != "Rex" {
+				t.Fatalf("pet as JSON: got %+v, %v from %s", got, err, rec.Body)
+			}
+		})
+	}
+}
+
+// TestRenderXMLOnlyForModels checks that payloads without an XML form fall back to JSON
+// even when the client asks for XML.
+func TestRenderXMLOnlyForModels(t *testing.T) {
+	rec := renderAs(t, "application/xml", http.StatusOK, map[string]int{"count": 1})
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type: got %q, want JSON", ct)
+	}
+}
+
+// TestShowPetByIdAsXML goes through a handler, so the error path uses writeError.
+func TestShowPetByIdAsXML(t *testing.T) {
+	pets := NewMemoryRepository()
+	if err := pets.CreatePet(t.Context(), Pet{Id: 1, Name: "Rex"}, ""); err != nil {
+		t.Fatalf("CreatePet: %v", err)
+	}
+	server := NewServer(pets, slog.New(slog.DiscardHandler))
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/pets/"+id, nil)
+		req.Header.Set("Accept", "application/xml;q=0.9, application/json;q=0.8")
+		rec := httptest.NewRecorder()
+		server.ShowPetById(rec, req, id)
+		return rec
+	}
+
+	rec := get("1")
+	var pet xmlPet
+	decodeXML(t, rec, &pet)
+	if rec.Code != http.StatusOK || pet.Id != 1 || pet.Name != "Rex" || pet.Tag != nil {
+		t.Fatalf("GET /pets/1: got %d %+v", rec.Code, pet)
+	}
+
+	rec = get("42")
+	var apiErr xmlError
+	decodeXML(t, rec, &apiErr)
+	if rec.Code != http.StatusNotFound || apiErr.Code != http.StatusNotFound || apiErr.Category == nil || *apiErr.Category != PETNOTFOUND || apiErr.Message == "" {
+		t.Fatalf("GET /pets/42: got %d %+v", rec.Code, apiErr)
+	}
+}
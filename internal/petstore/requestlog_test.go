@@ -0,0 +1,62 @@
+package petstore
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
+
+	appconfig "demo/internal/config"
+	"demo/internal/logging"
+)
+
+// TestRepositoryLogsCarryRequestID checks that the repository's retry warnings and the
+// handler's error, logged while serving a request, name the request that caused them.
+func TestRepositoryLogsCarryRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, appconfig.LoggingConfig{Level: "info", Format: "text"}, nil)
+	if err != nil {
+		t.Fatalf("logging.New: %v", err)
+	}
+	db := &fakeExecutor{queryRow: func(context.Context, int) pgx.Row { return fakeRow{err: errConnReset} }}
+	repo, err := newPostgresRepository(t.Context(), db, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("newPostgresRepository: %v", err)
+	}
+	repo.retry = retryPolicy{attempts: 2, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, logging.RequestFields)
+	HandlerFromMux(NewServer(repo, logger), r)
+	req := httptest.NewRequest(http.MethodGet, "/pets/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("GET /pets/7: got %d, want 500", rec.Code)
+	}
+	for _, msg := range []string{`msg="PostgresRepository: retrying after transient error"`, `msg="ShowPetById: repo error"`} {
+		var line string
+		for l := range strings.Lines(buf.String()) {
+			if strings.Contains(l, msg) {
+				line = l
+			}
+		}
+		if line == "" {
+			t.Fatalf("no %s line in:\n%s", msg, buf.String())
+		}
+		for _, field := range []string{"request_id=req-1", "method=GET", "route=/pets/{petId}"} {
+			if !strings.Contains(line, field) {
+				t.Errorf("%s: got %q, want %s", msg, line, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 50 * time.Millisecond
+	defaultRetryMaxDelay  = time.Second
+)
+
+// queryExecutor is the subset of pgxpool.Pool used by the repository, kept small so it can be faked.
+type queryExecutor interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Ping(ctx context.Context) error
+}
+
+// retryPolicy describes how transient database errors are retried.
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{
+		attempts:  defaultRetryAttempts,
+		baseDelay: defaultRetryBaseDelay,
+		maxDelay:  defaultRetryMaxDelay,
+	}
+}
+
+// withRetry runs fn until it succeeds, returns a non-retryable error, exhausts the attempt
+// budget, or the context deadline would pass before the next attempt.
+func (r *PostgresRepository) withRetry(ctx context.Context, op string, retryable func(error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= r.retry.attempts || !retryable(err) {
+			return err
+		}
+
+		delay := backoffDelay(r.retry, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		r.retries.Add(1)
+		r.logger.WarnContext(ctx, "PostgresRepository: retrying after transient error", "operation", op, "attempt", attempt, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// withReadRetry runs a read against the replica when one is configured, falling back to the
+// primary if the replica keeps failing for reasons other than the query's own outcome.
+func (r *PostgresRepository) withReadRetry(ctx context.Context, op string, fn func(db queryExecutor) error) error {
+	if r.readDB == nil {
+		return r.withRetry(ctx, op, isTransientReadError, func() error { return fn(r.db) })
+	}
+
+	err := r.withRetry(ctx, op, isTransientReadError, func() error { return fn(r.readDB) })
+	if err == nil || !shouldFallBackToPrimary(ctx, err) {
+		return err
+	}
+
+	r.logger.WarnContext(ctx, "PostgresRepository: read replica failed, falling back to primary", "operation", op, "error", err)
+	return r.withRetry(ctx, op, isTransientReadError, func() error { return fn(r.db) })
+}
+
+func shouldFallBackToPrimary(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Errors raised by the query itself would fail identically on the primary;
+		// only connection-level and replica-specific failures are worth rerouting.
+		return isTransientReadError(err) || pgErr.Code == "57P01" || pgErr.Code == "25006"
+	}
+	return true
+}
+
+// RetryCount reports how many retries have been issued since the repository was created.
+func (r *PostgresRepository) RetryCount() uint64 {
+	return r.retries.Load()
+}
+
+// backoffDelay returns a full-jitter exponential delay for the given attempt.
+func backoffDelay(policy retryPolicy, attempt int) time.Duration {
+	delay := policy.baseDelay << (attempt - 1)
+	if delay <= 0 || delay > policy.maxDelay {
+		delay = policy.maxDelay
+	}
+	return time.Duration(rand.Int64N(int64(delay)) + 1)
+}
+
+// isTransientReadError reports whether a failed read can be retried.
+func isTransientReadError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", pgErr.Code == "40P01":
+			return true
+		case len(pgErr.Code) == 5 && pgErr.Code[:2] == "08":
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isSafeWriteRetry reports whether a failed write certainly never reached the server.
+func isSafeWriteRetry(err error) bool {
+	return err != nil && pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,234 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeExecutor is a queryExecutor whose Exec and QueryRow results are scripted per call.
+// The schema statements newPostgresRepository runs are answered without being scripted.
+type fakeExecutor struct {
+	exec     func(ctx context.Context, call int) (pgconn.CommandTag, error)
+	queryRow func(ctx context.Context, call int) pgx.Row
+	execs    atomic.Int64
+	queries  atomic.Int64
+}
+
+func (f *fakeExecutor) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if f.exec == nil {
+		return pgconn.NewCommandTag(""), nil
+	}
+	return f.exec(ctx, int(f.execs.Add(1)))
+}
+
+func (f *fakeExecutor) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, errors.New("fakeExecutor: Query not scripted")
+}
+
+func (f *fakeExecutor) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return f.queryRow(ctx, int(f.queries.Add(1)))
+}
+
+func (f *fakeExecutor) Ping(ctx context.Context) error { return nil }
+
+// fakeRow is a pgx.Row that fails with err, or else scans id and name into the first two
+// destinations and leaves the rest zero.
+type fakeRow struct {
+	err  error
+	id   int64
+	name string
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*int64) = r.id
+	*dest[1].(*string) = r.name
+	return nil
+}
+
+// unsentError is a failure pgx guarantees happened before anything reached the server.
+type unsentError struct{}
+
+func (unsentError) Error() string     { return "dial: connection refused" }
+func (unsentError) SafeToRetry() bool { return true }
+
+var (
+	errConnReset     = &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	errSerialization = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+	errUndefinedCol  = &pgconn.PgError{Code: "42703", Message: "column does not exist"}
+)
+
+// newFakeRepository returns a repository over primary (and replica, when non-nil) that
+// retries three times without waiting noticeably between attempts.
+func newFakeRepository(t *testing.T, primary, replica *fakeExecutor, opts ...RepositoryOption) *PostgresRepository {
+	t.Helper()
+	repo, err := newPostgresRepository(t.Context(), primary, append(opts, WithLogger(slog.New(slog.DiscardHandler)))...)
+	if err != nil {
+		t.Fatalf("newPostgresRepository: %v", err)
+	}
+	if replica != nil {
+		repo.readDB = replica
+	}
+	repo.retry = retryPolicy{attempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+	return repo
+}
+
+func TestGetPetRetriesTransientErrors(t *testing.T) {
+	for _, transient := range []error{errConnReset, errSerialization, unsentError{}} {
+		t.Run(transient.Error(), func(t *testing.T) {
+			db := &fakeExecutor{queryRow: func(_ context.Context, call int) pgx.Row {
+				if call < 3 {
+					return fakeRow{err: transient}
+				}
+				return fakeRow{id: 7, name: "Rex"}
+			}}
+			repo := newFakeRepository(t, db, nil)
+
+			pet, err := repo.GetPet(t.Context(), 7)
+			if err != nil {
+				t.Fatalf("GetPet: %v", err)
+			}
+			if pet.Id != 7 || pet.Name != "Rex" {
+				t.Fatalf("GetPet: got %+v, want pet 7 named Rex", pet)
+			}
+			if got := db.queries.Load(); got != 3 {
+				t.Fatalf("GetPet: ran %d queries, want 3", got)
+			}
+			if got := repo.RetryCount(); got != 2 {
+				t.Fatalf("RetryCount: got %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestGetPetStopsAfterAttempts(t *testing.T) {
+	db := &fakeExecutor{queryRow: func(context.Context, int) pgx.Row { return fakeRow{err: errConnReset} }}
+	repo := newFakeRepository(t, db, nil)
+
+	if _, err := repo.GetPet(t.Context(), 7); !errors.Is(err, errConnReset) {
+		t.Fatalf("GetPet: got %v, want the last transient error", err)
+	}
+	if got := db.queries.Load(); got != 3 {
+		t.Fatalf("GetPet: ran %d queries, want one per attempt (3)", got)
+	}
+}
+
+func TestGetPetDoesNotRetryPermanentErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"NoRows", pgx.ErrNoRows, ErrPetNotFound},
+		{"QueryError", errUndefinedCol, errUndefinedCol},
+		{"Canceled", context.Canceled, context.Canceled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &fakeExecutor{queryRow: func(context.Context, int) pgx.Row { return fakeRow{err: tc.err} }}
+			repo := newFakeRepository(t, db, nil)
+
+			if _, err := repo.GetPet(t.Context(), 7); !errors.Is(err, tc.want) {
+				t.Fatalf("GetPet: got %v, want %v", err, tc.want)
+			}
+			if got := db.queries.Load(); got != 1 {
+				t.Fatalf("GetPet: ran %d queries, want 1", got)
+			}
+		})
+	}
+}
+
+func TestRetryRespectsDeadline(t *testing.T) {
+	db := &fakeExecutor{queryRow: func(context.Context, int) pgx.Row { return fakeRow{err: errConnReset} }}
+	repo := newFakeRepository(t, db, nil)
+	repo.retry.baseDelay, repo.retry.maxDelay = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+
+	if _, err := repo.GetPet(ctx, 7); !errors.Is(err, errConnReset) {
+		t.Fatalf("GetPet: got %v, want the transient error", err)
+	}
+	if got := db.queries.Load(); got != 1 {
+		t.Fatalf("GetPet: ran %d queries, want 1 since no backoff fits before the deadline", got)
+	}
+}
+
+func TestWritesRetryOnlyUnsentErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		execs int64
+	}{
+		{"Unsent", unsentError{}, 2},
+		// The insert may have been applied before the connection dropped.
+		{"ConnectionReset", errConnReset, 1},
+		{"Serialization", errSerialization, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &fakeExecutor{}
+			repo := newFakeRepository(t, db, nil)
+			db.exec = func(_ context.Context, call int) (pgconn.CommandTag, error) {
+				if call == 1 {
+					return pgconn.CommandTag{}, tc.err
+				}
+				return pgconn.NewCommandTag("INSERT 0 1"), nil
+			}
+
+			err := repo.CreatePet(t.Context(), Pet{Id: 7, Name: "Rex"}, "")
+			if got := db.execs.Load(); got != tc.execs {
+				t.Fatalf("CreatePet: ran %d statements, want %d (err %v)", got, tc.execs, err)
+			}
+			if (tc.execs > 1) != (err == nil) {
+				t.Fatalf("CreatePet: got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadReplicaFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		replicaErr     error
+		primaryQueries int64
+		wantReplicaErr bool
+	}{
+		{"ConnectionFailure", errConnReset, 1, false},
+		{"ReplicaConflict", &pgconn.PgError{Code: "40001"}, 1, false},
+		// The primary would reject the same query, so it is not asked.
+		{"QueryError", errUndefinedCol, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			primary := &fakeExecutor{queryRow: func(context.Context, int) pgx.Row { return fakeRow{id: 7, name: "Rex"} }}
+			replica := &fakeExecutor{queryRow: func(context.Context, int) pgx.Row { return fakeRow{err: tc.replicaErr} }}
+			repo := newFakeRepository(t, primary, replica)
+
+			_, err := repo.GetPet(t.Context(), 7)
+			if tc.wantReplicaErr != (err != nil) {
+				t.Fatalf("GetPet: got %v", err)
+			}
+			if got := primary.queries.Load(); got != tc.primaryQueries {
+				t.Fatalf("GetPet: primary ran %d queries, want %d", got, tc.primaryQueries)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	policy := retryPolicy{attempts: 5, baseDelay: 10 * time.Millisecond, maxDelay: 30 * time.Millisecond}
+	for attempt, ceiling := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 30 * time.Millisecond, 10: 30 * time.Millisecond} {
+		for range 100 {
+			if got := backoffDelay(policy, attempt); got <= 0 || got > ceiling {
+				t.Fatalf("backoffDelay(attempt %d): got %s, want within (0, %s]", attempt, got, ceiling)
+			}
+		}
+	}
+}
@@ -1,31 +1,116 @@
 package petstore
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+	"demo/internal/errreport"
+	"demo/internal/features"
+	"demo/internal/httpmw"
+	"demo/internal/i18n"
+	"demo/internal/tenant"
 )
 
 // Server implements the Petstore API backed by a PetRepository.
 type Server struct {
-	repo PetRepository
+	repo     PetRepository
+	logger   *slog.Logger
+	basePath string
+	reporter errreport.Reporter
+	// ownerChecks restricts updates and deletes by non-admins to pets they created.
+	ownerChecks bool
+	// exports runs POST /pets/exports; nil answers the export routes with 404.
+	exports Exports
+	// changes serves GET /pets/changes; nil answers it with 404.
+	changes ChangeFeed
+	// versions sends ETags on GET /pets; nil sends none.
+	versions CollectionVersions
+	// pager serves PetPage envelopes and cursor pages on GET /pets; nil answers them with 404.
+	pager Pager
+	// deduper serves /pets/duplicates and /pets/merge; nil answers them with 404.
+	deduper Deduper
+	// photos serves /pets/{petId}/photo; nil answers it with 404. photoOwners enforces
+	// ownerChecks on photo writes.
+	photos      BlobStore
+	photoOwners PetOwnerLookup
+	photoOpts   PhotoOptions
+	// invalidate drops a pet a merge changed from caches in front of repo.
+	invalidate func(ctx context.Context, id int64)
+	// cursorKey signs pagination cursors, which expire after cursorTTL.
+	cursorKey []byte
+	cursorTTL time.Duration
+}
+
+// ServerOption customises a Server at construction time.
+type ServerOption func(*Server)
+
+// WithBasePath prefixes URLs the server generates (x-next, Location) with a normalized
+// mount path such as "/api/petstore".
+func WithBasePath(basePath string) ServerOption {
+	return func(s *Server) {
+		s.basePath = basePath
+	}
+}
+
+// WithErrorReporter sends every 5xx response to rep, tagged with the request ID and route.
+func WithErrorReporter(rep errreport.Reporter) ServerOption {
+	return func(s *Server) {
+		s.reporter = rep
+	}
+}
+
+// WithOwnerChecks lets only admins update or delete pets created by someone else; other
+// users get 403.
+func WithOwnerChecks() ServerOption {
+	return func(s *Server) {
+		s.ownerChecks = true
+	}
 }
 
-// NewServer constructs a server using the supplied repository.
-func NewServer(repo PetRepository) *Server {
-	return &Server{repo: repo}
+// NewServer constructs a server using the supplied repository and logger.
+func NewServer(repo PetRepository, logger *slog.Logger, opts ...ServerOption) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &Server{repo: repo, logger: logger}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.cursorTTL <= 0 {
+		s.cursorTTL = defaultCursorTTL
+	}
+	return s
 }
 
-// ListPets returns pets up to the provided limit.
+// ListPets returns pets up to the provided limit; mine=true restricts them to the caller's.
 func (s *Server) ListPets(w http.ResponseWriter, r *http.Request, params ListPetsParams) {
+	var ownedBy string
+	if params.Mine != nil && *params.Mine {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			s.writeError(w, r, apierr.ErrUnauthenticated.With(msgAuthRequiredForMine))
+			return
+		}
+		ownedBy = user.ID
+	}
+
 	var limit int32
 	if params.Limit != nil {
 		limit = *params.Limit
 		if limit < 0 {
-			writeError(w, http.StatusBadRequest, "limit must be non-negative")
+			s.writeError(w, r, apierr.ErrInvalidRequest.With(msgLimitNegative))
 			return
 		}
 		if limit > 100 {
@@ -33,55 +118,106 @@ func (s *Server) ListPets(w http.ResponseWriter, r *http.Request, params ListPet
 		}
 	}
 
+	after, ok := s.pageStart(w, r, params)
+	if !ok {
+		return
+	}
+	if s.listNotModified(w, r, ownedBy) {
+		return
+	}
+	if wantsEnvelope(r, params) {
+		s.listPetPage(w, r, after, limit, ownedBy)
+		return
+	}
+
 	fetchLimit := limit
 	if limit > 0 {
 		fetchLimit = limit + 1
 	}
 
-	pets, err := s.repo.ListPets(r.Context(), fetchLimit)
+	var (
+		pets []Pet
+		err  error
+	)
+	switch {
+	case after == nil:
+		pets, err = s.repo.ListPets(r.Context(), fetchLimit, ownedBy)
+	case s.pager == nil:
+		s.writeError(w, r, apierr.ErrNotFound.With(msgPagingUnavailable))
+		return
+	default:
+		pets, err = s.pager.ListPetsAfter(r.Context(), *after, fetchLimit, ownedBy)
+	}
 	if err != nil {
-		log.Printf("ListPets: repo error: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to list pets")
+		s.writeListError(w, r, err)
 		return
 	}
 
 	result := pets
 	if limit > 0 && len(pets) > int(limit) {
 		result = pets[:limit]
-		nextID := pets[limit].Id
-		w.Header().Set("x-next", fmt.Sprintf("/pets?limit=%d&after=%d", limit, nextID))
+	}
+	if len(result) < len(pets) && len(s.cursorKey) > 0 {
+		next := idCursor(tenant.FromContext(r.Context()), result[limit-1].Id).Encode(s.cursorKey, s.cursorTTL)
+		w.Header().Set("x-next", fmt.Sprintf("%s/pets?limit=%d&cursor=%s", s.basePath, limit, next))
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	s.render(w, r, http.StatusOK, result)
+}
+
+// writeListError answers a failed ListPets repository call.
+func (s *Server) writeListError(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Del("ETag")
+	if s.writeCircuitOpen(w, r, "ListPets", err) {
+		return
+	}
+	if isTimeout(err) {
+		s.logger.WarnContext(r.Context(), "ListPets: repo timeout", "error", err)
+		s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+		return
+	}
+	s.logger.ErrorContext(r.Context(), "ListPets: repo error", "error", err)
+	s.writeError(w, r, apierr.ErrInternal.With(msgListPetsFailed))
 }
 
 // CreatePets stores a new pet using the provided payload.
 func (s *Server) CreatePets(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-
 	var pet Pet
-	if err := json.NewDecoder(r.Body).Decode(&pet); err != nil {
-		log.Printf("CreatePets: decode error: %v", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	if !s.decodeJSON(w, r, "CreatePets", &pet) {
 		return
 	}
+	pet = pet.Normalize()
 
-	if err := validatePet(pet); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	if err := ValidatePet(pet); err != nil {
+		s.writeValidationError(w, r, err)
 		return
 	}
 
-	if err := s.repo.CreatePet(r.Context(), pet); err != nil {
+	var owner string
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		owner = user.ID
+	}
+	if err := s.repo.CreatePet(r.Context(), pet, owner); err != nil {
 		if errors.Is(err, ErrPetExists) {
-			log.Printf("CreatePets: pet already exists: %v", err)
-			writeError(w, http.StatusConflict, "pet already exists")
+			s.logger.InfoContext(r.Context(), "CreatePets: pet already exists", "pet_id", pet.Id)
+			s.writeError(w, r, apierr.ErrPetExists)
 			return
 		}
-		log.Printf("CreatePets: repo error: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to create pet")
+		if s.writeCircuitOpen(w, r, "CreatePets", err) {
+			return
+		}
+		if isTimeout(err) {
+			s.logger.WarnContext(r.Context(), "CreatePets: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "CreatePets: repo error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgCreatePetFailed))
 		return
 	}
 
+	s.audit(r, "CreatePets", pet.Id)
+	w.Header().Set("Location", fmt.Sprintf("%s/pets/%d", s.basePath, pet.Id))
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -89,55 +225,260 @@ func (s *Server) CreatePets(w http.ResponseWriter, r *http.Request) {
 func (s *Server) ShowPetById(w http.ResponseWriter, r *http.Request, petId string) {
 	id, err := strconv.ParseInt(petId, 10, 64)
 	if err != nil {
-		log.Printf("ShowPetById: invalid petId %q: %v", petId, err)
-		writeError(w, http.StatusBadRequest, "petId must be an integer")
+		s.logger.InfoContext(r.Context(), "ShowPetById: invalid petId", "pet_id", petId, "error", err)
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidPetID))
 		return
 	}
 
 	pet, err := s.repo.GetPet(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, ErrPetNotFound) {
-			log.Printf("ShowPetById: pet not found: %v", err)
-			writeError(w, http.StatusNotFound, "pet not found")
+			s.logger.InfoContext(r.Context(), "ShowPetById: pet not found", "pet_id", id)
+			s.writeError(w, r, apierr.ErrPetNotFound)
+			return
+		}
+		if s.writeCircuitOpen(w, r, "ShowPetById", err) {
+			return
+		}
+		if isTimeout(err) {
+			s.logger.WarnContext(r.Context(), "ShowPetById: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "ShowPetById: repo error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgFetchPetFailed))
+		return
+	}
+
+	s.render(w, r, http.StatusOK, pet)
+}
+
+// UpdatePet replaces the name and tag of an existing pet.
+func (s *Server) UpdatePet(w http.ResponseWriter, r *http.Request, petId string) {
+	id, err := strconv.ParseInt(petId, 10, 64)
+	if err != nil {
+		s.logger.InfoContext(r.Context(), "UpdatePet: invalid petId", "pet_id", petId, "error", err)
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidPetID))
+		return
+	}
+
+	var pet Pet
+	if !s.decodeJSON(w, r, "UpdatePet", &pet) {
+		return
+	}
+	pet = pet.Normalize()
+	if err := ValidatePet(pet); err != nil {
+		s.writeValidationError(w, r, err)
+		return
+	}
+	if pet.Id != id {
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgPetIDMismatch))
+		return
+	}
+
+	if err := s.repo.UpdatePet(r.Context(), pet, s.requiredOwner(r)); err != nil {
+		if errors.Is(err, ErrPetNotFound) {
+			s.logger.InfoContext(r.Context(), "UpdatePet: pet not found", "pet_id", id)
+			s.writeError(w, r, apierr.ErrPetNotFound)
+			return
+		}
+		if errors.Is(err, ErrNotPetOwner) {
+			s.logger.InfoContext(r.Context(), "UpdatePet: not owner", "pet_id", id)
+			s.writeError(w, r, apierr.ErrNotPetOwner)
+			return
+		}
+		if s.writeCircuitOpen(w, r, "UpdatePet", err) {
+			return
+		}
+		if isTimeout(err) {
+			s.logger.WarnContext(r.Context(), "UpdatePet: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "UpdatePet: repo error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgUpdatePetFailed))
+		return
+	}
+
+	s.audit(r, "UpdatePet", id)
+	s.render(w, r, http.StatusOK, pet)
+}
+
+// DeletePet removes the requested pet.
+func (s *Server) DeletePet(w http.ResponseWriter, r *http.Request, petId string) {
+	id, err := strconv.ParseInt(petId, 10, 64)
+	if err != nil {
+		s.logger.InfoContext(r.Context(), "DeletePet: invalid petId", "pet_id", petId, "error", err)
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidPetID))
+		return
+	}
+
+	if err := s.repo.DeletePet(r.Context(), id, s.requiredOwner(r)); err != nil {
+		if errors.Is(err, ErrPetNotFound) {
+			s.logger.InfoContext(r.Context(), "DeletePet: pet not found", "pet_id", id)
+			s.writeError(w, r, apierr.ErrPetNotFound)
+			return
+		}
+		if errors.Is(err, ErrNotPetOwner) {
+			s.logger.InfoContext(r.Context(), "DeletePet: not owner", "pet_id", id)
+			s.writeError(w, r, apierr.ErrNotPetOwner)
+			return
+		}
+		if s.writeCircuitOpen(w, r, "DeletePet", err) {
+			return
+		}
+		if isTimeout(err) {
+			s.logger.WarnContext(r.Context(), "DeletePet: repo timeout", "error", err)
+			s.writeError(w, r, apierr.ErrTimeout.With(msgQueryTimeout))
 			return
 		}
-		log.Printf("ShowPetById: repo error: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to fetch pet")
+		s.logger.ErrorContext(r.Context(), "DeletePet: repo error", "error", err)
+		s.writeError(w, r, apierr.ErrInternal.With(msgDeletePetFailed))
 		return
 	}
 
-	writeJSON(w, http.StatusOK, pet)
+	s.audit(r, "DeletePet", id)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func validatePet(pet Pet) error {
+// requiredOwner is the owner an update or delete by the caller is restricted to, or ""
+// when it may touch any pet.
+func (s *Server) requiredOwner(r *http.Request) string {
+	if !s.ownerChecks {
+		return ""
+	}
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok || user.Role.Allows(auth.RoleAdmin) {
+		return ""
+	}
+	return user.ID
+}
+
+// audit records a successful write together with the authenticated caller, if any.
+func (s *Server) audit(r *http.Request, op string, petID int64, extra ...any) {
+	attrs := append([]any{"pet_id", petID}, extra...)
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		attrs = append(attrs, "user_id", user.ID, "auth_method", user.Method)
+	}
+	s.logger.InfoContext(r.Context(), op+": audit", attrs...)
+}
+
+// ValidatePet applies the rules every pet write must pass, whichever API it arrives through.
+// It expects a pet that has been through Normalize.
+func ValidatePet(pet Pet) error {
 	if pet.Id == 0 {
-		return errors.New("id is required")
+		return invalidPet(msgPetIDRequired)
 	}
 	if pet.Id < 0 {
-		return errors.New("id must be non-negative")
+		return invalidPet(msgPetIDNegative)
 	}
 	if pet.Name == "" {
-		return errors.New("name is required")
+		return invalidPet(msgPetNameRequired)
+	}
+	if len(pet.Name) > maxNameLength {
+		return invalidPet(msgPetNameTooLong, "max", maxNameLength)
 	}
-	if len(pet.Name) > 100 {
-		return errors.New("name must be 100 characters or fewer")
+	if hasControl(pet.Name) {
+		return invalidPet(msgPetNameControlCharacters)
 	}
-	if pet.Tag != nil && len(*pet.Tag) > 50 {
-		return errors.New("tag must be 50 characters or fewer")
+	if pet.Tag != nil && len(*pet.Tag) > maxTagLength {
+		return invalidPet(msgPetTagTooLong, "max", maxTagLength)
+	}
+	if pet.Tag != nil && hasControl(*pet.Tag) {
+		return invalidPet(msgPetTagControlCharacters)
 	}
 	return nil
 }
 
-func writeJSON(w http.ResponseWriter, status int, payload any) {
+// Length limits ValidatePet enforces, in bytes.
+const (
+	maxNameLength = 100
+	maxTagLength  = 50
+)
+
+// writeCircuitOpen answers 503 with Retry-After when err is the database circuit breaker
+// failing fast, and reports whether it did.
+func (s *Server) writeCircuitOpen(w http.ResponseWriter, r *http.Request, op string, err error) bool {
+	var open *CircuitOpenError
+	if !errors.As(err, &open) {
+		return false
+	}
+	s.logger.WarnContext(r.Context(), op+": circuit open")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(open.RetryAfter.Seconds()))))
+	s.writeError(w, r, apierr.ErrUnavailable.With(msgDatabaseUnavailable))
+	return true
+}
+
+// writeValidationError answers 400 with the rule a pet failed; err comes from ValidatePet.
+func (s *Server) writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		s.writeError(w, r, apierr.ErrValidationFailed.With(invalid.Code, invalid.Args...))
+		return
+	}
+	s.writeError(w, r, apierr.ErrValidationFailed)
+}
+
+// decodeJSON reads the request body into dst, answering 413 when the body exceeds the
+// configured limit and 400 for malformed JSON, or for unknown fields with the strict_json
+// feature flag. It reports whether decoding succeeded.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, op string, dst any) bool {
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(r.Body)
+	if features.Enabled(r.Context(), features.StrictJSON) {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(dst)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.logger.InfoContext(r.Context(), op+": body too large", "limit", tooLarge.Limit)
+		s.writeError(w, r, apierr.ErrBodyTooLarge.With(msgBodyTooLarge, "limit", tooLarge.Limit))
+		return false
+	}
+	s.logger.InfoContext(r.Context(), op+": decode error", "error", err)
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		s.writeError(w, r, apierr.ErrInvalidRequest.With(msgUnknownField, "field", field))
+		return false
+	}
+	s.writeError(w, r, apierr.ErrInvalidRequest.With(msgInvalidJSON))
+	return false
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
-		log.Printf("writeJSON: encode error: %v", err)
+		s.logger.ErrorContext(r.Context(), "writeJSON: encode error", "error", err)
 	}
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, Error{Code: int32(status), Message: message})
+// writeError answers with err's status and an Error payload, as JSON or XML per render,
+// tagged with the request ID so clients can quote it when reporting problems, or a
+// problem+json document with the problem_json feature flag. category is err's category,
+// error_code its message code, and the message that code rendered with its arguments in
+// the language Accept-Language prefers. 5xx responses are also passed to the error
+// reporter, in English.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err *apierr.Error) {
+	errreport.CaptureStatus(s.reporter, r, err.Status, err.Error())
+	locale := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	message := i18n.Message(locale, err.Message, err.Args...)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", locale)
+	if features.Enabled(r.Context(), features.ProblemJSON) {
+		httpmw.WriteProblem(w, r, err, message)
+		return
+	}
+	category := ErrorCategory(err.Category)
+	payload := Error{Category: &category, Code: int32(err.Status), ErrorCode: &err.Message, Message: message}
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		payload.RequestId = &id
+	}
+	s.render(w, r, err.Status, payload)
 }
 
 var _ ServerInterface = (*Server)(nil)
@@ -0,0 +1,62 @@
+package petstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// queryTimeouts holds the per-operation deadlines enforced by the repository.
+type queryTimeouts struct {
+	def          time.Duration
+	perOperation map[string]time.Duration
+}
+
+type queryTimeoutKey struct{}
+
+// apply derives a context bounded by the operation's timeout. context.WithTimeout keeps
+// the earlier deadline, so an already-shorter request deadline is never extended.
+func (t queryTimeouts) apply(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	timeout := t.def
+	if override, ok := t.perOperation[op]; ok {
+		timeout = override
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return context.WithValue(ctx, queryTimeoutKey{}, timeout), cancel
+}
+
+// mapTimeout converts a deadline hit caused by the repository's own timeout into
+// ErrQueryTimeout. Any error after ctx ended also wraps ctx.Err(): a query the pool
+// canceled on the server fails with PostgreSQL's query_canceled rather than the context's
+// error, and callers still need to tell a caller that gave up from a failing database.
+func mapTimeout(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	if !errors.Is(err, ctx.Err()) {
+		err = fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	timeout, ok := ctx.Value(queryTimeoutKey{}).(time.Duration)
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", ErrQueryTimeout, timeout, err)
+}
+
+// isTimeout reports whether err came from the repository's own timeout or from the request
+// deadline (e.g. the request timeout middleware) expiring mid-query.
+func isTimeout(err error) bool {
+	return errors.Is(err, ErrQueryTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
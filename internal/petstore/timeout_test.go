@@ -0,0 +1,103 @@
+package petstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// blockingRow is a query that never answers: it fails only when ctx ends, as pgx does
+// once it cancels the query on the server.
+func blockingRow(ctx context.Context, _ int) pgx.Row {
+	<-ctx.Done()
+	return fakeRow{err: ctx.Err()}
+}
+
+func TestQueryTimeoutReturnsErrQueryTimeout(t *testing.T) {
+	db := &fakeExecutor{queryRow: blockingRow}
+	repo := newFakeRepository(t, db, nil, WithQueryTimeouts(time.Hour, map[string]time.Duration{"get": 10 * time.Millisecond}))
+
+	_, err := repo.GetPet(t.Context(), 7)
+	if !errors.Is(err, ErrQueryTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetPet past its timeout: got %v, want ErrQueryTimeout wrapping context.DeadlineExceeded", err)
+	}
+	if got := db.queries.Load(); got != 1 {
+		t.Fatalf("GetPet: ran %d queries, want 1 since a timed-out query is not retried", got)
+	}
+}
+
+func TestCallerDeadlineIsNotAQueryTimeout(t *testing.T) {
+	db := &fakeExecutor{queryRow: blockingRow}
+	repo := newFakeRepository(t, db, nil, WithQueryTimeouts(time.Hour, nil))
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := repo.GetPet(ctx, 7)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrQueryTimeout) {
+		t.Fatalf("GetPet past the caller's deadline: got %v, want context.DeadlineExceeded only", err)
+	}
+}
+
+func TestQueryTimeoutsApply(t *testing.T) {
+	timeouts := queryTimeouts{def: time.Minute, perOperation: map[string]time.Duration{"list": time.Hour, "delete": 0}}
+	for _, tc := range []struct {
+		op   string
+		want time.Duration
+	}{
+		{"get", time.Minute},
+		{"list", time.Hour},
+		{"delete", 0},
+	} {
+		t.Run(tc.op, func(t *testing.T) {
+			ctx, cancel := timeouts.apply(t.Context(), tc.op)
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if tc.want == 0 {
+				if ok {
+					t.Fatalf("apply(%q): got deadline %s, want none", tc.op, deadline)
+				}
+				return
+			}
+			if got := time.Until(deadline); !ok || got > tc.want || got < tc.want-time.Second {
+				t.Fatalf("apply(%q): got deadline in %s, want %s", tc.op, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("KeepsEarlierDeadline", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(t.Context(), time.Second)
+		defer cancelParent()
+		ctx, cancel := timeouts.apply(parent, "get")
+		defer cancel()
+		if ctx != parent {
+			t.Fatal("apply: replaced a caller deadline earlier than the operation timeout")
+		}
+	})
+}
+
+func TestServerAnswersQueryTimeoutWith504(t *testing.T) {
+	db := &fakeExecutor{queryRow: blockingRow}
+	repo := newFakeRepository(t, db, nil, WithQueryTimeouts(10*time.Millisecond, nil))
+	server := NewServer(repo, slog.New(slog.DiscardHandler))
+
+	rec := httptest.NewRecorder()
+	server.ShowPetById(rec, httptest.NewRequest(http.MethodGet, "/pets/7", nil), "7")
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("ShowPetById: got status %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	var body Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error body %q: %v", rec.Body, err)
+	}
+	if body.Category == nil || *body.Category != "TIMEOUT" || body.ErrorCode == nil || *body.ErrorCode != msgQueryTimeout {
+		t.Fatalf("ShowPetById: got error body %s, want category TIMEOUT and error_code %s", rec.Body, msgQueryTimeout)
+	}
+}
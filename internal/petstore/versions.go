@@ -0,0 +1,172 @@
+package petstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"demo/internal/tenant"
+)
+
+// CollectionVersions reports a version of the pets of the organization on ctx that
+// changes with every write to them. PostgresRepository implements it from the
+// collection_versions table, so all instances agree on it.
+type CollectionVersions interface {
+	PetsVersion(ctx context.Context) (int64, error)
+}
+
+// WithListETags sends GET /pets responses with a weak ETag built from versions and
+// answers an If-None-Match naming it with 304 without listing pets.
+func WithListETags(versions CollectionVersions) ServerOption {
+	return func(s *Server) {
+		s.versions = versions
+	}
+}
+
+// listETagParams are the GET /pets query parameters an ETag covers. The version only
+// says whether any pet changed, which settles every page, owner filter, and format;
+// a filter it cannot vouch for, such as a search, must stay out of this list, and
+// requests using one get no ETag.
+var listETagParams = map[string]bool{
+	"limit":    true,
+	"mine":     true,
+	"cursor":   true,
+	"after":    true,
+	"envelope": true,
+}
+
+// listNotModified sends the ETag of this GET /pets request and reports whether
+// If-None-Match names it, after writing the 304. It must run before the pets are listed,
+// so an ETag never names a version older than the pets it is sent with.
+func (s *Server) listNotModified(w http.ResponseWriter, r *http.Request, ownedBy string) bool {
+	if s.versions == nil {
+		return false
+	}
+	for name := range r.URL.Query() {
+		if !listETagParams[name] {
+			return false
+		}
+	}
+	version, err := s.versions.PetsVersion(r.Context())
+	if err != nil {
+		// The list itself may still work; it just goes out without an ETag.
+		s.logger.WarnContext(r.Context(), "ListPets: version lookup failed", "error", err)
+		return false
+	}
+
+	// The version covers the pets, the digest which of them were asked for and how they
+	// are rendered.
+	h := sha256.New()
+	for _, part := range []string{tenant.FromContext(r.Context()), ownedBy, r.URL.RawQuery, r.Header.Get("Accept")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	etag := fmt.Sprintf(`W/"%d-%s"`, version, hex.EncodeToString(h.Sum(nil)[:8]))
+
+	w.Header().Set("ETag", etag)
+	// Lists depend on the caller, so shared caches must not keep them, and clients must
+	// revalidate before reusing one.
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// VersionCache keeps each organization's pets version in memory for refresh, so
+// conditional lists usually cost no query. It also wraps a PetRepository and drops the
+// organization's version after writes through it, so this instance never answers 304 for
+// a list it changed; writes through other instances show within refresh.
+type VersionCache struct {
+	next     PetRepository
+	versions CollectionVersions
+	refresh  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]versionEntry
+	// generation changes with every invalidation, so a version read before a write is not
+	// stored after it.
+	generation uint64
+}
+
+type versionEntry struct {
+	version int64
+	read    time.Time
+}
+
+// NewVersionCache wraps next and caches the versions from versions for refresh; zero reads
+// them on every call.
+func NewVersionCache(next PetRepository, versions CollectionVersions, refresh time.Duration) *VersionCache {
+	return &VersionCache{next: next, versions: versions, refresh: refresh, entries: make(map[string]versionEntry)}
+}
+
+// PetsVersion implements CollectionVersions.
+func (c *VersionCache) PetsVersion(ctx context.Context) (int64, error) {
+	org := tenant.FromContext(ctx)
+	c.mu.Lock()
+	entry, ok := c.entries[org]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Since(entry.read) < c.refresh {
+		return entry.version, nil
+	}
+
+	read := time.Now()
+	version, err := c.versions.PetsVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[org] = versionEntry{version: version, read: read}
+	}
+	c.mu.Unlock()
+	return version, nil
+}
+
+// Invalidate drops the cached version of org, as after a merge, which writes pets without
+// going through the repository.
+func (c *VersionCache) Invalidate(org string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, org)
+	c.generation++
+}
+
+// ListPets passes through.
+func (c *VersionCache) ListPets(ctx context.Context, limit int32, ownedBy string) ([]Pet, error) {
+	return c.next.ListPets(ctx, limit, ownedBy)
+}
+
+// GetPet passes through.
+func (c *VersionCache) GetPet(ctx context.Context, id int64) (Pet, error) {
+	return c.next.GetPet(ctx, id)
+}
+
+// CreatePet creates the pet and drops the cached version.
+func (c *VersionCache) CreatePet(ctx context.Context, pet Pet, owner string) error {
+	defer c.Invalidate(tenant.FromContext(ctx))
+	return c.next.CreatePet(ctx, pet, owner)
+}
+
+// UpdatePet updates the pet and drops the cached version.
+func (c *VersionCache) UpdatePet(ctx context.Context, pet Pet, ownedBy string) error {
+	defer c.Invalidate(tenant.FromContext(ctx))
+	return c.next.UpdatePet(ctx, pet, ownedBy)
+}
+
+// DeletePet deletes the pet and drops the cached version.
+func (c *VersionCache) DeletePet(ctx context.Context, id int64, ownedBy string) error {
+	defer c.Invalidate(tenant.FromContext(ctx))
+	return c.next.DeletePet(ctx, id, ownedBy)
+}
+
+var (
+	_ PetRepository      = (*VersionCache)(nil)
+	_ CollectionVersions = (*VersionCache)(nil)
+)
@@ -0,0 +1,108 @@
+// Package purge hard-deletes soft-deleted pets on a schedule. Every instance runs a
+// Scheduler, and the dblock lock named LockKey elects the one that purges: the leader
+// holds the lock for as long as it runs, and the others campaign again every interval,
+// so one takes over within an interval of the leader going away.
+package purge
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"demo/internal/dblock"
+	"demo/internal/petstore"
+)
+
+// LockKey names the lock held by the leader.
+const LockKey = "purge"
+
+// Options configures a Scheduler.
+type Options struct {
+	// Retention is how long soft-deleted pets are kept before they are purged.
+	Retention time.Duration
+	// Interval is how often the leader purges and the others campaign.
+	Interval  time.Duration
+	BatchSize int
+	Logger    *slog.Logger
+}
+
+// Scheduler purges soft-deleted pets older than Options.Retention every interval while
+// it is the leader.
+type Scheduler struct {
+	locks  *dblock.Locker
+	purger petstore.Purger
+	opts   Options
+	logger *slog.Logger
+	// release gives up the lock while this instance leads.
+	release func()
+}
+
+// NewScheduler builds a Scheduler purging through purger and campaigning through locks.
+func NewScheduler(locks *dblock.Locker, purger petstore.Purger, opts Options) *Scheduler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Scheduler{locks: locks, purger: purger, opts: opts, logger: logger}
+}
+
+// Run campaigns and purges until ctx is done, then gives up the lock.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.resign()
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if s.lead(ctx) {
+			s.purge(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) purge(ctx context.Context) {
+	before := time.Now().Add(-s.opts.Retention)
+	n, err := s.purger.PurgeDeletedPets(ctx, before, s.opts.BatchSize)
+	if err != nil && ctx.Err() == nil {
+		s.logger.Error("pets_purge_failed", "scheduled", true, "before", before, "purged", n, "error", err)
+		return
+	}
+	s.logger.Info("pets_purged", "scheduled", true, "retention", s.opts.Retention.String(), "before", before, "purged", n)
+}
+
+// lead reports whether this instance leads, campaigning when it does not. A leader whose
+// lock went with its connection campaigns again.
+func (s *Scheduler) lead(ctx context.Context) bool {
+	if s.release != nil {
+		if s.locks.Held(LockKey) {
+			return true
+		}
+		s.logger.Warn("purge_leadership_lost")
+		s.resign()
+	}
+
+	release, ok, err := s.locks.Acquire(ctx, LockKey)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("purge_campaign_failed", "error", err)
+		}
+		return false
+	}
+	if !ok {
+		return false
+	}
+	s.release = release
+	s.logger.Info("purge_leader_elected")
+	return true
+}
+
+func (s *Scheduler) resign() {
+	if s.release == nil {
+		return
+	}
+	s.release()
+	s.release = nil
+}
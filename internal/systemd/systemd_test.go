@@ -0,0 +1,148 @@
+package systemd_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"demo/internal/systemd"
+)
+
+// helperEnv marks the copy of the test binary TestListenerInheritsSocket starts as the
+// socket-activated service.
+const helperEnv = "SYSTEMD_TEST_ACTIVATED"
+
+// TestListenerInheritsSocket passes a bound socket to a child process as fd 3 with the
+// LISTEN_* variables set, as systemd does, and talks to the child through it.
+func TestListenerInheritsSocket(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	file, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("listener file: %v", err)
+	}
+	defer file.Close()
+	defer ln.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestActivatedService$")
+	cmd.Env = append(os.Environ(), helperEnv+"=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=http")
+	cmd.ExtraFiles = []*os.File{file}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting activated service: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	conn, err := net.DialTimeout("tcp", ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading from activated service: %v", err)
+	}
+	if want := fmt.Sprintf("served by %d\n", cmd.Process.Pid); line != want {
+		t.Fatalf("activated service: got %q, want %q", line, want)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("activated service: %v", err)
+	}
+}
+
+// TestActivatedService is the child side of TestListenerInheritsSocket.
+func TestActivatedService(t *testing.T) {
+	if os.Getenv(helperEnv) == "" {
+		t.Skip("run by TestListenerInheritsSocket")
+	}
+	// systemd fills in LISTEN_PID between fork and exec, once the pid is known.
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	ln, err := systemd.Listener()
+	if err != nil || ln == nil {
+		t.Fatalf("Listener: got %v, %v; want the inherited socket", ln, err)
+	}
+	defer ln.Close()
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		if value, ok := os.LookupEnv(name); ok {
+			t.Fatalf("Listener left %s=%q for child processes", name, value)
+		}
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "served by %d\n", os.Getpid())
+}
+
+func TestListenerWithoutActivation(t *testing.T) {
+	for _, tc := range []struct {
+		name, pid, fds string
+	}{
+		{"Unset", "", ""},
+		{"OtherProcess", strconv.Itoa(os.Getpid() + 1), "1"},
+		{"NoSockets", strconv.Itoa(os.Getpid()), "0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", tc.pid)
+			t.Setenv("LISTEN_FDS", tc.fds)
+
+			ln, err := systemd.Listener()
+			if ln != nil || err != nil {
+				t.Fatalf("Listener: got %v, %v; want nil, nil", ln, err)
+			}
+			if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+				t.Fatal("Listener left LISTEN_FDS set")
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if err := systemd.Notify("READY=1"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("reading notification: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Fatalf("notification: got %q, want READY=1", got)
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := systemd.Notify("READY=1"); err != nil {
+		t.Fatalf("Notify without NOTIFY_SOCKET: %v", err)
+	}
+}
+
+func TestNotifyUnreachableSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+	if err := systemd.Notify("READY=1"); err == nil || !strings.Contains(err.Error(), "sd_notify") {
+		t.Fatalf("Notify to a missing socket: got %v, want an sd_notify error", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package tenant scopes requests to an organization. Middleware resolves the caller's
+// organization once per request and attaches it to the context, where repositories read
+// it with FromContext, so every query is scoped without the organization appearing in
+// each method signature.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"demo/internal/apierr"
+	"demo/internal/auth"
+	"demo/internal/httpmw"
+	"demo/internal/logging"
+)
+
+const (
+	// DefaultOrg owns every row written before organizations existed, and is the
+	// organization of anonymous callers, API tokens without one, and work that runs
+	// outside a request.
+	DefaultOrg = "default"
+	// Header selects one of the caller's organizations for a request.
+	Header = "X-Org-Id"
+)
+
+// ErrNotMember is returned by Resolve when the caller may not act in the organization
+// they asked for. Callers answer it like a missing resource, so the response does not
+// reveal whether the organization exists.
+var ErrNotMember = errors.New("not a member of the organization")
+
+type contextKey struct{}
+
+// WithOrg attaches org to ctx, as Middleware does, for transports other than HTTP and for
+// work done on an organization's behalf outside a request.
+func WithOrg(ctx context.Context, org string) context.Context {
+	return logging.With(context.WithValue(ctx, contextKey{}, org), "org_id", org)
+}
+
+// FromContext returns the organization attached to ctx, or DefaultOrg when there is
+// none.
+func FromContext(ctx context.Context) string {
+	if org, ok := ctx.Value(contextKey{}).(string); ok {
+		return org
+	}
+	return DefaultOrg
+}
+
+// Memberships lists the organizations a signed-in user belongs to, their primary one
+// first. store.PostgresUserRepository implements it.
+type Memberships interface {
+	Orgs(ctx context.Context, userID int64) ([]string, error)
+}
+
+// Resolver decides which organization a caller acts in.
+type Resolver struct {
+	memberships Memberships
+	logger      *slog.Logger
+}
+
+// NewResolver builds a Resolver. With nil memberships, as when login is disabled, every
+// caller without a token organization belongs to DefaultOrg only.
+func NewResolver(memberships Memberships, logger *slog.Logger) *Resolver {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Resolver{memberships: memberships, logger: logger}
+}
+
+// Resolve returns the organization user, nil for anonymous callers, acts in: requested
+// when it is one of theirs, or their primary organization when requested is empty.
+// Admins may act in any organization.
+func (r *Resolver) Resolve(ctx context.Context, user *auth.User, requested string) (string, error) {
+	orgs, err := r.orgs(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case requested == "":
+		return orgs[0], nil
+	case slices.Contains(orgs, requested), user != nil && user.Role == auth.RoleAdmin:
+		return requested, nil
+	}
+	return "", ErrNotMember
+}
+
+func (r *Resolver) orgs(ctx context.Context, user *auth.User) ([]string, error) {
+	if user == nil {
+		return []string{DefaultOrg}, nil
+	}
+	if user.Org != "" {
+		return []string{user.Org}, nil
+	}
+	// Session users are users rows; token users without an organization are not.
+	userID, err := strconv.ParseInt(user.ID, 10, 64)
+	if err != nil || r.memberships == nil {
+		return []string{DefaultOrg}, nil
+	}
+	orgs, err := r.memberships.Orgs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 {
+		// The user was deleted while their session lives on.
+		return nil, ErrNotMember
+	}
+	return orgs, nil
+}
+
+// Middleware attaches the caller's organization, chosen with the X-Org-Id header, to the
+// request context. It must run inside authentication, which attaches the user it reads.
+// An organization the caller does not belong to gets 404.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", Header)
+		var user *auth.User
+		if u, ok := auth.UserFromContext(req.Context()); ok {
+			user = &u
+		}
+		org, err := r.Resolve(req.Context(), user, req.Header.Get(Header))
+		if errors.Is(err, ErrNotMember) {
+			r.logger.InfoContext(req.Context(), "org_access_denied", "requested_org", req.Header.Get(Header))
+			httpmw.WriteError(w, req, apierr.ErrNotFound, "not found")
+			return
+		}
+		if err != nil {
+			r.logger.ErrorContext(req.Context(), "org_resolution_failed", "error", err)
+			httpmw.WriteError(w, req, apierr.ErrInternal, "failed to resolve organization")
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithOrg(req.Context(), org)))
+	})
+}
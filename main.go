@@ -2,22 +2,17 @@ package main
 
 import (
 	"context"
-	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/jackc/pgx/v5/pgxpool"
-
-	googleauth "demo/internal/auth/google"
+	"demo/internal/app"
+	"demo/internal/buildinfo"
 	"demo/internal/config"
-	"demo/internal/petstore"
 )
 
 const banner = `
@@ -28,77 +23,80 @@ const banner = `
 |_|   \___|\__|____/ \__\___/|_|  \___|
 `
 
-func main() {
-	fmt.Print(banner)
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("failed to load configuration: %v", err)
-	}
-
-	router := chi.NewRouter()
-	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
-	router.Use(middleware.Recoverer)
+const usage = `Usage: petstore [command] [flags]
 
-	if cfg.Database.DSN == "" {
-		log.Fatal("database.dsn configuration is required")
-	}
-
-	pool, err := pgxpool.New(context.Background(), cfg.Database.DSN)
-	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
-	}
-	defer pool.Close()
-
-	repo, err := petstore.NewPostgresRepository(context.Background(), pool)
-	if err != nil {
-		log.Fatalf("failed to initialize pet repository: %v", err)
-	}
+Commands:
+  serve        run the HTTP server (default)
+  migrate      apply or roll back schema migrations: migrate [up|down] [--steps N]
+  healthcheck  query the local /readyz endpoint and exit 0 when ready
+  seed         load pets from a JSON or CSV file: seed --file pets.json
+  normalize    rewrite stored pet names and tags in normalized form: normalize [--dry-run]
 
-	serverImpl := petstore.NewServer(repo)
-
-	if cfg.GoogleOAuth.Enabled {
-		googleHandler, err := googleauth.NewHandler(cfg.GoogleOAuth)
-		if err != nil {
-			log.Fatalf("failed to initialize google oauth handler: %v", err)
-		}
-		router.Group(func(r chi.Router) {
-			r.Get("/auth/google/login", googleHandler.Login)
-			r.Get("/auth/google/callback", googleHandler.Callback)
-		})
-	}
-
-	handler := petstore.HandlerFromMux(serverImpl, router)
+Every command accepts --config <file or directory>, defaulting to $DEMO_CONFIG_FILE.
+`
 
-	addr := cfg.Server.Address
-	if addr == "" {
-		addr = ":8080"
+func main() {
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	httpServer := &http.Server{
-		Addr:    addr,
-		Handler: handler,
+	switch command {
+	case "serve":
+		serve(args)
+	case "migrate":
+		migrate(args)
+	case "healthcheck":
+		healthcheck(args)
+	case "seed":
+		seed(args)
+	case "normalize":
+		normalize(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
+}
 
-	go func() {
-		log.Printf("event=server_listen addr=%q pid=%d", addr, os.Getpid())
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("server error: %v", err)
-		}
-	}()
+func serve(args []string) {
+	fmt.Print(banner)
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfg, logger := loadConfig(fs, args)
+	configPath := fs.Lookup("config").Value.String()
+
+	build := buildinfo.Get()
+	configChecksum := cfg.Checksum()
+	logger.Info("build_info",
+		"version", build.Version,
+		"commit", build.Commit,
+		"date", build.Date,
+		"go_version", build.GoVersion,
+		"config_checksum", configChecksum,
+	)
+	logger.Info("effective_config", "config", cfg.Redacted().Settings())
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	<-ctx.Done()
-	log.Println("Shutdown signal received, closing server...")
-
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("graceful shutdown failed: %v", err)
+	server, err := app.New(cfg,
+		app.WithLogger(logger, logLevel),
+		app.WithReload(func() (config.Config, error) { return config.Load(configPath) }),
+	)
+	if err != nil {
+		fatal(logger, "invalid configuration", err)
+	}
+	if err := server.Run(ctx); err != nil {
+		stop()
+		fatal(logger, "server exited with error", err)
 	}
+	logger.Info("Server exited cleanly")
+}
 
-	log.Println("Server exited cleanly")
+// fatal logs err and exits; it is reserved for startup and shutdown failures.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,391 @@
+// Package petstoreclient is a typed Go client for the petstore API.
+package petstoreclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pet mirrors the API's Pet schema.
+type Pet struct {
+	ID   int64   `json:"id"`
+	Name string  `json:"name"`
+	Tag  *string `json:"tag,omitempty"`
+}
+
+// ErrorCategory is the API's coarse classification of an error, sent with every error
+// response. Each category is also an error that errors.Is matches against an APIError
+// of that category:
+//
+//	if errors.Is(err, petstoreclient.PetNotFound) { ... }
+type ErrorCategory string
+
+// Error categories, as in the API's Error schema.
+const (
+	BodyTooLarge         ErrorCategory = "BODY_TOO_LARGE"
+	Conflict             ErrorCategory = "CONFLICT"
+	ExportNotFound       ErrorCategory = "EXPORT_NOT_FOUND"
+	Forbidden            ErrorCategory = "FORBIDDEN"
+	Internal             ErrorCategory = "INTERNAL"
+	InvalidRequest       ErrorCategory = "INVALID_REQUEST"
+	MethodNotAllowed     ErrorCategory = "METHOD_NOT_ALLOWED"
+	NotFound             ErrorCategory = "NOT_FOUND"
+	NotPetOwner          ErrorCategory = "NOT_PET_OWNER"
+	PetExists            ErrorCategory = "PET_EXISTS"
+	PetNotFound          ErrorCategory = "PET_NOT_FOUND"
+	PhotoNotFound        ErrorCategory = "PHOTO_NOT_FOUND"
+	RateLimited          ErrorCategory = "RATE_LIMITED"
+	Timeout              ErrorCategory = "TIMEOUT"
+	Unauthenticated      ErrorCategory = "UNAUTHENTICATED"
+	Unavailable          ErrorCategory = "UNAVAILABLE"
+	UnsupportedMediaType ErrorCategory = "UNSUPPORTED_MEDIA_TYPE"
+	ValidationFailed     ErrorCategory = "VALIDATION_FAILED"
+)
+
+// Categories returns every category the client knows, sorted.
+func Categories() []ErrorCategory {
+	return []ErrorCategory{
+		BodyTooLarge, Conflict, ExportNotFound, Forbidden, Internal, InvalidRequest,
+		MethodNotAllowed, NotFound, NotPetOwner, PetExists, PetNotFound, PhotoNotFound,
+		RateLimited, Timeout, Unauthenticated, Unavailable, UnsupportedMediaType,
+		ValidationFailed,
+	}
+}
+
+func (c ErrorCategory) Error() string {
+	return "petstore: " + string(c)
+}
+
+// APIError is a non-2xx response decoded from the API's Error schema.
+type APIError struct {
+	StatusCode int
+	// Category is empty when the response did not carry one, e.g. from a proxy.
+	Category ErrorCategory `json:"category"`
+	Code     int32         `json:"code"`
+	// ErrorCode is the stable code behind Message, e.g. PET_NOT_FOUND; Message itself may
+	// be localized per the client's Accept-Language.
+	ErrorCode string `json:"error_code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("petstore: %d %s (request_id %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("petstore: %d %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether target is e's category, so errors.Is(err, PetNotFound) holds for a
+// PET_NOT_FOUND response.
+func (e *APIError) Is(target error) bool {
+	category, ok := target.(ErrorCategory)
+	return ok && e.Category != "" && category == e.Category
+}
+
+// IsNotFound reports whether err is a 404 from the API.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the API, e.g. a duplicate pet ID.
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// Client calls the petstore API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      func(ctx context.Context) (string, error)
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// Option customises a Client at construction time.
+type Option func(*Client)
+
+// WithBaseURL sets the API root including any base path, e.g.
+// "https://api.example.com/api/petstore". The default is http://localhost:8080.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient replaces the underlying http.Client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout bounds each attempt, including reading the response body.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = d
+		c.httpClient = &hc
+	}
+}
+
+// WithToken sends a static bearer token on every request.
+func WithToken(token string) Option {
+	return WithTokenSource(func(context.Context) (string, error) { return token, nil })
+}
+
+// WithTokenSource fetches a bearer token per request, allowing rotation.
+func WithTokenSource(fn func(ctx context.Context) (string, error)) Option {
+	return func(c *Client) {
+		c.token = fn
+	}
+}
+
+// WithRetry retries 429 and 5xx responses and transport errors up to maxRetries times,
+// waiting for Retry-After when the server sends it and otherwise backing off
+// exponentially from backoff. Non-idempotent requests (CreatePet) are only retried on
+// 429 and 503, which the server sends before doing any work. The default is 2 retries
+// starting at 100ms; maxRetries 0 disables retrying.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New builds a client from opts.
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    "http://localhost:8080",
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 2,
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListOptions controls ListPets paging. A zero Limit uses the server default.
+type ListOptions struct {
+	Limit int32
+	// After is the opaque cursor returned by a previous ListPets call.
+	After string
+	// Mine lists only pets created by the authenticated caller.
+	Mine bool
+}
+
+// ListPets returns one page of pets and the cursor for the next page, which is empty on
+// the last page.
+func (c *Client) ListPets(ctx context.Context, opts ListOptions) ([]Pet, string, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(int(opts.Limit)))
+	}
+	if opts.After != "" {
+		query.Set("cursor", opts.After)
+	}
+	if opts.Mine {
+		query.Set("mine", "true")
+	}
+
+	var pets []Pet
+	resp, err := c.do(ctx, http.MethodGet, "/pets", query, nil, &pets)
+	if err != nil {
+		return nil, "", err
+	}
+	return pets, nextCursor(resp.Header.Get("x-next")), nil
+}
+
+// CreatePet stores pet; a duplicate ID fails with an APIError for which IsConflict is true.
+func (c *Client) CreatePet(ctx context.Context, pet Pet) error {
+	_, err := c.do(ctx, http.MethodPost, "/pets", nil, pet, nil)
+	return err
+}
+
+// GetPet fetches a pet by ID.
+func (c *Client) GetPet(ctx context.Context, id int64) (Pet, error) {
+	var pet Pet
+	_, err := c.do(ctx, http.MethodGet, petPath(id), nil, nil, &pet)
+	return pet, err
+}
+
+// UpdatePet replaces the name and tag of the pet with pet.ID and returns the stored pet.
+func (c *Client) UpdatePet(ctx context.Context, pet Pet) (Pet, error) {
+	var updated Pet
+	_, err := c.do(ctx, http.MethodPut, petPath(pet.ID), nil, pet, &updated)
+	return updated, err
+}
+
+// DeletePet removes a pet by ID.
+func (c *Client) DeletePet(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, petPath(id), nil, nil, nil)
+	return err
+}
+
+func petPath(id int64) string {
+	return "/pets/" + strconv.FormatInt(id, 10)
+}
+
+// nextCursor extracts the cursor parameter from an x-next link.
+func nextCursor(link string) string {
+	if link == "" {
+		return ""
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("cursor")
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("petstore: encode request: %w", err)
+		}
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, method, target, payload)
+		retry, wait := c.shouldRetry(method, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+			return resp, decodeResponse(resp, out)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, target string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("petstore: build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("petstore: fetch token: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("petstore: %s %s: %w", method, target, err)
+	}
+	return resp, nil
+}
+
+// shouldRetry decides whether attempt may be repeated and how long to wait first.
+func (c *Client) shouldRetry(method string, resp *http.Response, err error, attempt int) (bool, time.Duration) {
+	if attempt >= c.maxRetries {
+		return false, 0
+	}
+	idempotent := method != http.MethodPost
+	switch {
+	case err != nil:
+		// Context cancellation is final; other transport errors are retried when the
+		// request can safely be repeated.
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || !idempotent {
+			return false, 0
+		}
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+	case resp.StatusCode >= 500 && idempotent:
+	default:
+		return false, 0
+	}
+
+	wait := c.backoff << attempt
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = d
+		}
+	}
+	if wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	return true, wait
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if err := json.Unmarshal(data, apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+			if apiErr.Message == "" {
+				apiErr.Message = http.StatusText(resp.StatusCode)
+			}
+		}
+		apiErr.StatusCode = resp.StatusCode
+		return apiErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("petstore: decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package petstoreclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"demo/internal/auth"
+	"demo/internal/petstore"
+	"demo/pkg/petstoreclient"
+)
+
+// newServer serves the real API over an in-memory repository. The bearer token, when
+// present, is taken as the caller's user ID.
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	pets := petstore.NewMemoryRepository()
+	server := petstore.NewServer(pets, slog.New(slog.DiscardHandler),
+		petstore.WithPager(pets),
+		petstore.WithCursorKey([]byte("test-cursor-key-0123456789abcdef"), time.Hour),
+	)
+	handler := petstore.HandlerWithOptions(server, petstore.ChiServerOptions{
+		Middlewares:      []petstore.MiddlewareFunc{bearerUser, middleware.RequestID},
+		ErrorHandlerFunc: petstore.ParamErrorHandler,
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func bearerUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			r = r.WithContext(auth.ContextWithUser(r.Context(), auth.User{ID: id}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestClientPetLifecycle(t *testing.T) {
+	srv := newServer(t)
+	client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL+"/"), petstoreclient.WithToken("alice"))
+	ctx := t.Context()
+
+	tag := "dog"
+	if err := client.CreatePet(ctx, petstoreclient.Pet{ID: 1, Name: "Rex", Tag: &tag}); err != nil {
+		t.Fatalf("CreatePet: %v", err)
+	}
+	pet, err := client.GetPet(ctx, 1)
+	if err != nil || pet.Name != "Rex" || pet.Tag == nil || *pet.Tag != "dog" {
+		t.Fatalf("GetPet(1): got %+v, %v; want Rex the dog", pet, err)
+	}
+	updated, err := client.UpdatePet(ctx, petstoreclient.Pet{ID: 1, Name: "Max"})
+	if err != nil || updated.Name != "Max" || updated.Tag != nil {
+		t.Fatalf("UpdatePet(1): got %+v, %v; want Max without a tag", updated, err)
+	}
+	if err := client.DeletePet(ctx, 1); err != nil {
+		t.Fatalf("DeletePet(1): %v", err)
+	}
+	_, err = client.GetPet(ctx, 1)
+	if !petstoreclient.IsNotFound(err) || !errors.Is(err, petstoreclient.PetNotFound) {
+		t.Fatalf("GetPet after DeletePet: got %v, want PET_NOT_FOUND", err)
+	}
+}
+
+func TestClientListPets(t *testing.T) {
+	srv := newServer(t)
+	ctx := t.Context()
+	for id, owner := range map[int64]string{1: "alice", 2: "bob", 3: "alice", 4: "alice", 5: "bob"} {
+		client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL), petstoreclient.WithToken(owner))
+		if err := client.CreatePet(ctx, petstoreclient.Pet{ID: id, Name: "Pet"}); err != nil {
+			t.Fatalf("CreatePet(%d): %v", id, err)
+		}
+	}
+
+	var rotated atomic.Int64
+	client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL), petstoreclient.WithTokenSource(func(context.Context) (string, error) {
+		rotated.Add(1)
+		return "alice", nil
+	}))
+	for _, tc := range []struct {
+		name string
+		mine bool
+		want []int64
+	}{
+		{"All", false, []int64{1, 2, 3, 4, 5}},
+		{"Mine", true, []int64{1, 3, 4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int64
+			opts := petstoreclient.ListOptions{Limit: 2, Mine: tc.mine}
+			for range 10 {
+				pets, next, err := client.ListPets(ctx, opts)
+				if err != nil {
+					t.Fatalf("ListPets(%+v): %v", opts, err)
+				}
+				for _, pet := range pets {
+					got = append(got, pet.ID)
+				}
+				if next == "" {
+					break
+				}
+				opts.After = next
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("ListPets pages: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if rotated.Load() == 0 {
+		t.Fatal("WithTokenSource: the token source was never called")
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	srv := newServer(t)
+	client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL))
+	if err := client.CreatePet(t.Context(), petstoreclient.Pet{ID: 1, Name: "Rex"}); err != nil {
+		t.Fatalf("CreatePet: %v", err)
+	}
+
+	err := client.CreatePet(t.Context(), petstoreclient.Pet{ID: 1, Name: "Rex"})
+	var apiErr *petstoreclient.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("CreatePet of a duplicate: got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusConflict || apiErr.Code != http.StatusConflict ||
+		apiErr.Category != petstoreclient.PetExists || apiErr.ErrorCode != "PET_EXISTS" ||
+		apiErr.Message == "" || apiErr.RequestID == "" {
+		t.Fatalf("CreatePet of a duplicate: got %+v, want a 409 PET_EXISTS with message and request ID", apiErr)
+	}
+	if !petstoreclient.IsConflict(err) || !errors.Is(err, petstoreclient.PetExists) || errors.Is(err, petstoreclient.PetNotFound) {
+		t.Fatalf("CreatePet of a duplicate: %v does not match only PET_EXISTS", err)
+	}
+	if !strings.Contains(err.Error(), apiErr.RequestID) {
+		t.Fatalf("APIError.Error(): %q lacks the request ID", err)
+	}
+}
+
+func TestClientAPIErrorWithoutErrorBody(t *testing.T) {
+	// A proxy in front of the API answers with plain text.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no route to upstream", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL))
+
+	_, err := client.GetPet(t.Context(), 1)
+	var apiErr *petstoreclient.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "no route to upstream" || apiErr.Category != "" {
+		t.Fatalf("GetPet through a proxy 404: got %#v, want the body as message and no category", err)
+	}
+	if !petstoreclient.IsNotFound(err) || errors.Is(err, petstoreclient.NotFound) {
+		t.Fatalf("GetPet through a proxy 404: IsNotFound should hold, and no category should match")
+	}
+}
+
+// flaky answers the first failures requests with status, then passes requests to next.
+type flaky struct {
+	next     http.Handler
+	status   int
+	failures int64
+	requests atomic.Int64
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.requests.Add(1) <= f.failures {
+		io.Copy(io.Discard, r.Body)
+		// Retry-After overrides the client's own, much longer, backoff.
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, http.StatusText(f.status), f.status)
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+func TestClientRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		failures int64
+		post     bool
+		wantErr  int
+		requests int64
+	}{
+		{name: "GetAfter500", status: http.StatusInternalServerError, failures: 2, requests: 3},
+		{name: "GetAfter429", status: http.StatusTooManyRequests, failures: 1, requests: 2},
+		{name: "GetExhausted", status: http.StatusServiceUnavailable, failures: 10, wantErr: http.StatusServiceUnavailable, requests: 3},
+		{name: "GetNotOn4xx", status: http.StatusBadRequest, failures: 1, wantErr: http.StatusBadRequest, requests: 1},
+		// A POST that failed with a 500 may have stored the pet.
+		{name: "PostNotAfter500", status: http.StatusInternalServerError, failures: 1, post: true, wantErr: http.StatusInternalServerError, requests: 1},
+		{name: "PostAfter503", status: http.StatusServiceUnavailable, failures: 1, post: true, requests: 2},
+		{name: "PostAfter429", status: http.StatusTooManyRequests, failures: 1, post: true, requests: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newServer(t)
+			seed := petstoreclient.New(petstoreclient.WithBaseURL(api.URL))
+			if err := seed.CreatePet(t.Context(), petstoreclient.Pet{ID: 1, Name: "Rex"}); err != nil {
+				t.Fatalf("CreatePet: %v", err)
+			}
+			handler := &flaky{next: api.Config.Handler, status: tc.status, failures: tc.failures}
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+			client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL), petstoreclient.WithRetry(2, time.Hour))
+
+			var err error
+			if tc.post {
+				err = client.CreatePet(t.Context(), petstoreclient.Pet{ID: 2, Name: "Max"})
+			} else {
+				_, err = client.GetPet(t.Context(), 1)
+			}
+			var apiErr *petstoreclient.APIError
+			switch {
+			case tc.wantErr == 0 && err != nil:
+				t.Fatalf("got %v, want success", err)
+			case tc.wantErr != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tc.wantErr):
+				t.Fatalf("got %v, want a %d APIError", err, tc.wantErr)
+			}
+			if got := handler.requests.Load(); got != tc.requests {
+				t.Fatalf("server saw %d requests, want %d", got, tc.requests)
+			}
+		})
+	}
+}
+
+func TestClientRetryStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	client := petstoreclient.New(petstoreclient.WithBaseURL(srv.URL), petstoreclient.WithRetry(5, time.Hour))
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if _, err := client.GetPet(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetPet while backing off: got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("GetPet kept backing off for %s after its context ended", elapsed)
+	}
+}